@@ -134,6 +134,8 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"targets", "status for discovered active targets"},
 			{"service-discovery", "labels before and after relabeling for discovered targets"},
 			{"metric-relabel-debug", "debug metric relabeling"},
+			{"stream-agg", "stream aggregation status"},
+			{"stream-agg-dry-run", "debug stream aggregation"},
 			{"expand-with-exprs", "WITH expressions' tutorial"},
 			{"api/v1/targets", "advanced information about discovered targets in JSON format"},
 			{"config", "-promscrape.config contents"},
@@ -12,6 +12,8 @@ import (
 
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/cloudwatch"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/collectd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/csvimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/datadogsketches"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/datadogv1"
@@ -35,6 +37,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/influxutils"
+	collectdserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/collectd"
 	graphiteserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/graphite"
 	influxserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/influx"
 	opentsdbserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/opentsdb"
@@ -42,6 +45,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape"
+	collectdparser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/collectd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/opentelemetry/firehose"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/pushmetrics"
@@ -61,10 +65,16 @@ var (
 		"See also -influxListenAddr.useProxyProtocol")
 	influxUseProxyProtocol = flag.Bool("influxListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted at -influxListenAddr . "+
 		"See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
+	collectdListenAddr = flag.String("collectdListenAddr", "", "UDP address to listen for collectd binary network protocol data. Usually :25826 must be set. Doesn't work if empty. "+
+		"See also -collectd.typesDB, -collectd.authFile and -collectd.securityLevel")
 	graphiteListenAddr = flag.String("graphiteListenAddr", "", "TCP and UDP address to listen for Graphite plaintext data. Usually :2003 must be set. Doesn't work if empty. "+
 		"See also -graphiteListenAddr.useProxyProtocol")
 	graphiteUseProxyProtocol = flag.Bool("graphiteListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted at -graphiteListenAddr . "+
 		"See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
+	graphitePickleListenAddr = flag.String("graphitePickleListenAddr", "", "TCP address to listen for Graphite pickle data. Usually :2004 must be set. Doesn't work if empty. "+
+		"See also -graphitePickleListenAddr.useProxyProtocol")
+	graphitePickleUseProxyProtocol = flag.Bool("graphitePickleListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted at -graphitePickleListenAddr . "+
+		"See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
 	opentsdbListenAddr = flag.String("opentsdbListenAddr", "", "TCP and UDP address to listen for OpenTSDB metrics. "+
 		"Telnet put messages and HTTP /api/put messages are simultaneously served on TCP port. "+
 		"Usually :4242 must be set. Doesn't work if empty. See also -opentsdbListenAddr.useProxyProtocol")
@@ -79,16 +89,20 @@ var (
 	dryRun        = flag.Bool("dryRun", false, "Whether to check config files without running vmagent. The following files are checked: "+
 		"-promscrape.config, -remoteWrite.relabelConfig, -remoteWrite.urlRelabelConfig, -remoteWrite.streamAggr.config . "+
 		"Unknown config entries aren't allowed in -promscrape.config by default. This can be changed by passing -promscrape.config.strictParse=false command-line flag")
+	dumpPersistentQueue = flag.String("dumpPersistentQueue", "", "Path to persistent queue directory to dump in JSON lines format to stdout without running vmagent. "+
+		"The queue mustn't be used by the running vmagent. See https://docs.victoriametrics.com/vmagent/#corrupted-pending-data")
 	maxLabelsPerTimeseries = flag.Int("maxLabelsPerTimeseries", 0, "The maximum number of labels per time series to be accepted. Series with superfluous labels are ignored. In this case the vm_rows_ignored_total{reason=\"too_many_labels\"} metric at /metrics page is incremented")
 	maxLabelNameLen        = flag.Int("maxLabelNameLen", 0, "The maximum length of label names in the accepted time series. Series with longer label name are ignored. In this case the vm_rows_ignored_total{reason=\"too_long_label_name\"} metric at /metrics page is incremented")
 	maxLabelValueLen       = flag.Int("maxLabelValueLen", 0, "The maximum length of label values in the accepted time series. Series with longer label value are ignored. In this case the vm_rows_ignored_total{reason=\"too_long_label_value\"} metric at /metrics page is incremented")
 )
 
 var (
-	influxServer       *influxserver.Server
-	graphiteServer     *graphiteserver.Server
-	opentsdbServer     *opentsdbserver.Server
-	opentsdbhttpServer *opentsdbhttpserver.Server
+	influxServer         *influxserver.Server
+	collectdServer       *collectdserver.Server
+	graphiteServer       *graphiteserver.Server
+	graphitePickleServer *graphiteserver.PickleServer
+	opentsdbServer       *opentsdbserver.Server
+	opentsdbhttpServer   *opentsdbhttpserver.Server
 )
 
 var (
@@ -116,6 +130,12 @@ func main() {
 	logger.Init()
 	timeserieslimits.Init(*maxLabelsPerTimeseries, *maxLabelNameLen, *maxLabelValueLen)
 
+	if *dumpPersistentQueue != "" {
+		if err := remotewrite.DumpPersistentQueue(*dumpPersistentQueue, os.Stdout); err != nil {
+			logger.Fatalf("cannot dump -dumpPersistentQueue=%q: %s", *dumpPersistentQueue, err)
+		}
+		return
+	}
 	if promscrape.IsDryRun() {
 		if err := promscrape.CheckConfig(); err != nil {
 			logger.Fatalf("error when checking -promscrape.config: %s", err)
@@ -151,9 +171,16 @@ func main() {
 			return influx.InsertHandlerForReader(nil, r, false)
 		})
 	}
+	if len(*collectdListenAddr) > 0 {
+		collectdparser.MustInit()
+		collectdServer = collectdserver.MustStart(*collectdListenAddr, collectd.InsertHandler)
+	}
 	if len(*graphiteListenAddr) > 0 {
 		graphiteServer = graphiteserver.MustStart(*graphiteListenAddr, *graphiteUseProxyProtocol, graphite.InsertHandler)
 	}
+	if len(*graphitePickleListenAddr) > 0 {
+		graphitePickleServer = graphiteserver.MustStartPickle(*graphitePickleListenAddr, *graphitePickleUseProxyProtocol, graphite.InsertHandlerPickle)
+	}
 	if len(*opentsdbListenAddr) > 0 {
 		httpInsertHandler := getOpenTSDBHTTPInsertHandler()
 		opentsdbServer = opentsdbserver.MustStart(*opentsdbListenAddr, *opentsdbUseProxyProtocol, opentsdb.InsertHandler, httpInsertHandler)
@@ -186,9 +213,15 @@ func main() {
 	if len(*influxListenAddr) > 0 {
 		influxServer.MustStop()
 	}
+	if len(*collectdListenAddr) > 0 {
+		collectdServer.MustStop()
+	}
 	if len(*graphiteListenAddr) > 0 {
 		graphiteServer.MustStop()
 	}
+	if len(*graphitePickleListenAddr) > 0 {
+		graphitePickleServer.MustStop()
+	}
 	if len(*opentsdbListenAddr) > 0 {
 		opentsdbServer.MustStop()
 	}
@@ -248,6 +281,8 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"targets", "status for discovered active targets"},
 			{"service-discovery", "labels before and after relabeling for discovered targets"},
 			{"metric-relabel-debug", "debug metric relabeling"},
+			{"stream-agg", "stream aggregation status"},
+			{"stream-agg-dry-run", "debug stream aggregation"},
 			{"api/v1/targets", "advanced information about discovered targets in JSON format"},
 			{"config", "-promscrape.config contents"},
 			{"metrics", "available service metrics"},
@@ -346,6 +381,15 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		firehose.WriteSuccessResponse(w, r)
 		return true
+	case "/cloudwatch/firehose":
+		cloudwatchFirehoseRequests.Inc()
+		if err := cloudwatch.InsertHandler(nil, r); err != nil {
+			cloudwatchFirehoseErrors.Inc()
+			firehose.WriteErrorResponse(w, r, err)
+			return true
+		}
+		firehose.WriteSuccessResponse(w, r)
+		return true
 	case "/newrelic":
 		newrelicCheckRequest.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -440,6 +484,14 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promscrapeTargetRelabelDebugRequests.Inc()
 		promscrape.WriteTargetRelabelDebug(w, r)
 		return true
+	case "/prometheus/stream-agg", "/stream-agg":
+		streamAggrStatusRequests.Inc()
+		remotewrite.WriteStreamAggrStatus(w, r)
+		return true
+	case "/prometheus/stream-agg-dry-run", "/stream-agg-dry-run":
+		streamAggrDryRunRequests.Inc()
+		remotewrite.WriteStreamAggrDryRun(w, r)
+		return true
 	case "/prometheus/api/v1/targets", "/api/v1/targets":
 		promscrapeAPIV1TargetsRequests.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -602,6 +654,15 @@ func processMultitenantRequest(w http.ResponseWriter, r *http.Request, path stri
 		}
 		firehose.WriteSuccessResponse(w, r)
 		return true
+	case "cloudwatch/firehose":
+		cloudwatchFirehoseRequests.Inc()
+		if err := cloudwatch.InsertHandler(at, r); err != nil {
+			cloudwatchFirehoseErrors.Inc()
+			firehose.WriteErrorResponse(w, r, err)
+			return true
+		}
+		firehose.WriteSuccessResponse(w, r)
+		return true
 	case "newrelic":
 		newrelicCheckRequest.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -723,6 +784,9 @@ var (
 	opentelemetryPushRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/opentelemetry/v1/metrics", protocol="opentelemetry"}`)
 	opentelemetryPushErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/opentelemetry/v1/metrics", protocol="opentelemetry"}`)
 
+	cloudwatchFirehoseRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/cloudwatch/firehose", protocol="cloudwatch"}`)
+	cloudwatchFirehoseErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/cloudwatch/firehose", protocol="cloudwatch"}`)
+
 	newrelicWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/newrelic/infra/v2/metrics/events/bulk", protocol="newrelic"}`)
 	newrelicWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/newrelic/infra/v2/metrics/events/bulk", protocol="newrelic"}`)
 
@@ -735,6 +799,9 @@ var (
 	promscrapeMetricRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/metric-relabel-debug"}`)
 	promscrapeTargetRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target-relabel-debug"}`)
 
+	streamAggrStatusRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/stream-agg"}`)
+	streamAggrDryRunRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/stream-agg-dry-run"}`)
+
 	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target_response"}`)
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/awsapi"
@@ -111,6 +112,10 @@ type client struct {
 	retriesCount    *metrics.Counter
 	sendDuration    *metrics.FloatCounter
 
+	// requestsFailed is the number of failed requests to remoteWriteURL.
+	// It is used for determining the health of remoteWriteURL for -remoteWrite.shardByURL.consistentHash.
+	requestsFailed atomic.Uint64
+
 	wg     sync.WaitGroup
 	stopCh chan struct{}
 }
@@ -416,6 +421,7 @@ again:
 	c.requestDuration.UpdateDuration(startTime)
 	if err != nil {
 		c.errorsCount.Inc()
+		c.requestsFailed.Add(1)
 		retryDuration *= 2
 		if retryDuration > maxRetryDuration {
 			retryDuration = maxRetryDuration
@@ -462,6 +468,7 @@ again:
 	}
 
 	// Unexpected status code returned
+	c.requestsFailed.Add(1)
 	retriesCount++
 	retryAfterHeader := parseRetryAfterHeader(resp.Header.Get("Retry-After"))
 	retryDuration = getRetryDuration(retryAfterHeader, retryDuration, maxRetryDuration)
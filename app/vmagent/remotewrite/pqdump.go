@@ -0,0 +1,132 @@
+package remotewrite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// DumpPersistentQueue writes pending data from the persistent queue at path to w in JSON lines format.
+//
+// Every line contains a single block of pending data. The queue at path mustn't be used by vmagent during the call.
+// -remoteWrite.tmpDataEncryptionKeyFile must be set for encrypted queues.
+func DumpPersistentQueue(path string, w io.Writer) error {
+	var enc *persistentqueue.Encryption
+	if *tmpDataEncryptionKeyFile != "" {
+		var err error
+		enc, err = persistentqueue.LoadEncryptionKeys(*tmpDataEncryptionKeyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load -remoteWrite.tmpDataEncryptionKeyFile: %w", err)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	var wr prompb.WriteRequest
+	var buf []byte
+	err := persistentqueue.Inspect(path, enc, func(bi *persistentqueue.BlockInfo, data []byte) error {
+		var err error
+		buf, err = marshalDumpBlock(buf[:0], bi, data, &wr)
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(buf)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot dump persistent queue at %q: %w", path, err)
+	}
+	return bw.Flush()
+}
+
+type dumpBlock struct {
+	Path       string           `json:"path"`
+	Offset     uint64           `json:"offset"`
+	Size       uint64           `json:"size"`
+	Timestamp  string           `json:"timestamp,omitempty"`
+	Checksum   bool             `json:"checksum"`
+	Error      string           `json:"error,omitempty"`
+	Timeseries []dumpTimeseries `json:"timeseries,omitempty"`
+}
+
+type dumpTimeseries struct {
+	Labels map[string]string `json:"labels"`
+
+	// Samples contains [timestamp, "value"] pairs in the same format as Prometheus querying API uses.
+	// Values are marshaled as strings, since JSON doesn't support NaN used for staleness markers.
+	Samples [][2]any `json:"samples"`
+}
+
+// marshalDumpBlock appends JSON line for the block with the given bi and data to dst and returns the result.
+func marshalDumpBlock(dst []byte, bi *persistentqueue.BlockInfo, data []byte, wr *prompb.WriteRequest) ([]byte, error) {
+	db := &dumpBlock{
+		Path:     bi.Path,
+		Offset:   bi.Offset,
+		Size:     bi.Size,
+		Checksum: bi.HasChecksum,
+	}
+	if bi.Timestamp > 0 {
+		db.Timestamp = time.Unix(int64(bi.Timestamp), 0).UTC().Format(time.RFC3339)
+	}
+	if bi.Err != nil {
+		db.Error = bi.Err.Error()
+	} else if err := unmarshalPendingBlock(wr, data); err != nil {
+		db.Error = err.Error()
+	} else {
+		db.Timeseries = make([]dumpTimeseries, len(wr.Timeseries))
+		for i, ts := range wr.Timeseries {
+			labels := make(map[string]string, len(ts.Labels))
+			for _, label := range ts.Labels {
+				labels[label.Name] = label.Value
+			}
+			samples := make([][2]any, len(ts.Samples))
+			for j, s := range ts.Samples {
+				samples[j] = [2]any{s.Timestamp, strconv.FormatFloat(s.Value, 'g', -1, 64)}
+			}
+			db.Timeseries[i] = dumpTimeseries{
+				Labels:  labels,
+				Samples: samples,
+			}
+		}
+	}
+	line, err := json.Marshal(db)
+	if err != nil {
+		return dst, fmt.Errorf("cannot marshal block at offset %d: %w", bi.Offset, err)
+	}
+	dst = append(dst, line...)
+	return append(dst, '\n'), nil
+}
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// unmarshalPendingBlock unmarshals the block stored in persistent queue into wr.
+//
+// The block contains either zstd-compressed data for VictoriaMetrics remote write protocol
+// or snappy-compressed data for Prometheus remote write protocol.
+func unmarshalPendingBlock(wr *prompb.WriteRequest, data []byte) error {
+	var b []byte
+	var err error
+	if bytes.HasPrefix(data, zstdMagic) {
+		b, err = zstd.Decompress(nil, data)
+		if err != nil {
+			return fmt.Errorf("cannot decompress zstd-compressed block: %w", err)
+		}
+	} else {
+		b, err = snappy.Decode(nil, data)
+		if err != nil {
+			return fmt.Errorf("cannot decompress snappy-compressed block: %w", err)
+		}
+	}
+	if err := wr.UnmarshalProtobuf(b); err != nil {
+		return fmt.Errorf("cannot unmarshal block: %w", err)
+	}
+	return nil
+}
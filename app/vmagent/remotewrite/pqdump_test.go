@@ -0,0 +1,68 @@
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestDumpPersistentQueue(t *testing.T) {
+	path := t.TempDir()
+	fq := persistentqueue.MustOpenFastQueue(path, "foo", 0, 0, false)
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{
+			{
+				Labels: []prompbmarshal.Label{
+					{Name: "__name__", Value: "foo"},
+					{Name: "job", Value: "bar"},
+				},
+				Samples: []prompbmarshal.Sample{
+					{Value: 1.5, Timestamp: 1000},
+					{Value: math.NaN(), Timestamp: 2000},
+				},
+			},
+		},
+	}
+	for _, isVMRemoteWrite := range []bool{false, true} {
+		if !tryPushWriteRequest(wr, fq.TryWriteBlock, isVMRemoteWrite) {
+			t.Fatalf("cannot write block to the queue")
+		}
+	}
+	fq.MustClose()
+
+	var bb bytes.Buffer
+	if err := DumpPersistentQueue(path, &bb); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(bb.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected number of lines; got %d; want 2; output:\n%s", len(lines), bb.String())
+	}
+	for _, line := range lines {
+		var db dumpBlock
+		if err := json.Unmarshal([]byte(line), &db); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", line, err)
+		}
+		if db.Error != "" {
+			t.Fatalf("unexpected error in %q", line)
+		}
+		if !db.Checksum || db.Timestamp == "" {
+			t.Fatalf("missing checksum or timestamp in %q", line)
+		}
+		if len(db.Timeseries) != 1 {
+			t.Fatalf("unexpected number of time series in %q", line)
+		}
+		ts := db.Timeseries[0]
+		if ts.Labels["__name__"] != "foo" || ts.Labels["job"] != "bar" {
+			t.Fatalf("unexpected labels in %q", line)
+		}
+		if !strings.Contains(line, `"samples":[[1000,"1.5"],[2000,"NaN"]]`) {
+			t.Fatalf("unexpected samples in %q", line)
+		}
+	}
+}
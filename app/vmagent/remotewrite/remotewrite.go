@@ -57,6 +57,13 @@ var (
 
 	tmpDataPath = flag.String("remoteWrite.tmpDataPath", "vmagent-remotewrite-data", "Path to directory for storing pending data, which isn't sent to the configured -remoteWrite.url . "+
 		"See also -remoteWrite.maxDiskUsagePerURL and -remoteWrite.disableOnDiskQueue")
+	tmpDataEncryptionKeyFile = flag.String("remoteWrite.tmpDataEncryptionKeyFile", "", "Optional path to file with AES keys for encrypting pending data "+
+		"stored at -remoteWrite.tmpDataPath. The file must contain a hex- or base64-encoded 16, 24 or 32-byte key per line. The first key is used for encrypting new data, "+
+		"while the remaining keys are used only for decrypting the data encrypted before the key rotation. "+
+		"See https://docs.victoriametrics.com/vmagent/#encryption-at-rest")
+	quarantineCorruptedData = flag.Bool("remoteWrite.quarantineCorruptedData", false, "Whether to copy corrupted pending data found at -remoteWrite.tmpDataPath "+
+		"to the quarantine directory inside the corresponding persistent queue directory before skipping it. By default corrupted data is just skipped. "+
+		"See https://docs.victoriametrics.com/vmagent/#corrupted-pending-data")
 	keepDanglingQueues = flag.Bool("remoteWrite.keepDanglingQueues", false, "Keep persistent queues contents at -remoteWrite.tmpDataPath in case there are no matching -remoteWrite.url. "+
 		"Useful when -remoteWrite.url is changed temporarily and persistent queue files will be needed later on.")
 	queues = flag.Int("remoteWrite.queues", cgroup.AvailableCPUs()*2, "The number of concurrent queues to each -remoteWrite.url. Set more queues if default number of queues "+
@@ -92,6 +99,16 @@ var (
 		"See https://docs.victoriametrics.com/vmagent#disabling-on-disk-persistence . See also -remoteWrite.dropSamplesOnOverload")
 	dropSamplesOnOverload = flag.Bool("remoteWrite.dropSamplesOnOverload", false, "Whether to drop samples when -remoteWrite.disableOnDiskQueue is set and if the samples "+
 		"cannot be pushed into the configured -remoteWrite.url systems in a timely manner. See https://docs.victoriametrics.com/vmagent#disabling-on-disk-persistence")
+
+	maxBlockAge = flagutil.NewArrayDuration("remoteWrite.maxBlockAge", 0, "The maximum age of pending data stored at -remoteWrite.tmpDataPath "+
+		"for the corresponding -remoteWrite.url. Older data is dropped instead of sending it to the remote storage. This may be useful when the remote storage "+
+		"rejects too old samples because of the retention. Zero value means unlimited age. See https://docs.victoriametrics.com/vmagent/#replaying-pending-data")
+	replayNewestFirst = flagutil.NewArrayBool("remoteWrite.replayNewestFirst", "Whether to send fresh data to the corresponding -remoteWrite.url "+
+		"before the pending data stored at -remoteWrite.tmpDataPath. By default the pending data is sent first in order to preserve the order of samples. "+
+		"See also -remoteWrite.backlogReplayRateLimit and https://docs.victoriametrics.com/vmagent/#replaying-pending-data")
+	backlogReplayRateLimit = flagutil.NewArrayBytes("remoteWrite.backlogReplayRateLimit", 0, "The maximum number of bytes per second to read "+
+		"from the pending data stored at -remoteWrite.tmpDataPath for the corresponding -remoteWrite.url when -remoteWrite.replayNewestFirst is set. "+
+		"Zero value means unlimited rate. See https://docs.victoriametrics.com/vmagent/#replaying-pending-data")
 )
 
 var (
@@ -205,7 +222,18 @@ func Init() {
 
 	initStreamAggrConfigGlobal()
 
+	if *tmpDataEncryptionKeyFile != "" {
+		enc, err := persistentqueue.LoadEncryptionKeys(*tmpDataEncryptionKeyFile)
+		if err != nil {
+			logger.Fatalf("cannot load -remoteWrite.tmpDataEncryptionKeyFile: %s", err)
+		}
+		tmpDataEncryption = enc
+	}
+
 	rwctxsGlobal = newRemoteWriteCtxs(*remoteWriteURLs)
+	if *shardByURL && *shardByURLConsistentHash {
+		initShardRing()
+	}
 
 	disableOnDiskQueues := []bool(*disableOnDiskQueue)
 	disableOnDiskQueueAny = slices.Contains(disableOnDiskQueues, true)
@@ -367,6 +395,12 @@ func Stop() {
 		deduplicatorGlobal = nil
 	}
 
+	if urlHealthCheckerGlobal != nil {
+		urlHealthCheckerGlobal.mustStop()
+		urlHealthCheckerGlobal = nil
+	}
+	shardRingGlobal = nil
+
 	for _, rwctx := range rwctxsGlobal {
 		rwctx.MustStop()
 	}
@@ -574,30 +608,17 @@ func tryPushBlockToRemoteStorages(rwctxs []*remoteWriteCtx, tssBlock []prompbmar
 }
 
 func tryShardingBlockAmongRemoteStorages(rwctxs []*remoteWriteCtx, tssBlock []prompbmarshal.TimeSeries, replicas int, forceDropSamplesOnFailure bool) bool {
+	if shardRingGlobal != nil {
+		return tryShardingBlockViaRing(shardRingGlobal, rwctxs, tssBlock, replicas, forceDropSamplesOnFailure)
+	}
+
 	x := getTSSShards(len(rwctxs))
 	defer putTSSShards(x)
 
 	shards := x.shards
 	tmpLabels := promutils.GetLabels()
 	for _, ts := range tssBlock {
-		hashLabels := ts.Labels
-		if len(shardByURLLabelsMap) > 0 {
-			hashLabels = tmpLabels.Labels[:0]
-			for _, label := range ts.Labels {
-				if _, ok := shardByURLLabelsMap[label.Name]; ok {
-					hashLabels = append(hashLabels, label)
-				}
-			}
-			tmpLabels.Labels = hashLabels
-		} else if len(shardByURLIgnoreLabelsMap) > 0 {
-			hashLabels = tmpLabels.Labels[:0]
-			for _, label := range ts.Labels {
-				if _, ok := shardByURLIgnoreLabelsMap[label.Name]; !ok {
-					hashLabels = append(hashLabels, label)
-				}
-			}
-			tmpLabels.Labels = hashLabels
-		}
+		hashLabels := getShardingLabels(tmpLabels, ts.Labels)
 		h := getLabelsHash(hashLabels)
 		idx := h % uint64(len(shards))
 		i := 0
@@ -615,13 +636,95 @@ func tryShardingBlockAmongRemoteStorages(rwctxs []*remoteWriteCtx, tssBlock []pr
 	}
 	promutils.PutLabels(tmpLabels)
 
+	return pushShardsToRemoteStorages(rwctxs, shards, forceDropSamplesOnFailure)
+}
+
+// tryShardingBlockViaRing shards tssBlock among rwctxs according to sr.
+//
+// Series for unhealthy remote storage systems are rerouted to the next healthy systems on the ring.
+func tryShardingBlockViaRing(sr *shardRing, rwctxs []*remoteWriteCtx, tssBlock []prompbmarshal.TimeSeries, replicas int, forceDropSamplesOnFailure bool) bool {
+	// The ring contains all the -remoteWrite.url, while rwctxs may contain only a subset of them
+	// if some of them are blocked because of -remoteWrite.disableOnDiskQueue.
+	// Shards are indexed by the index of the remote storage at rwctxsGlobal.
+	n := len(sr.unhealthy)
+	x := getTSSShards(n)
+	defer putTSSShards(x)
+
+	var isEligible func(idx int) bool
+	if len(rwctxs) < n {
+		eligible := make([]bool, n)
+		for _, rwctx := range rwctxs {
+			eligible[rwctx.idx] = true
+		}
+		isEligible = func(idx int) bool {
+			return eligible[idx]
+		}
+	}
+
+	shards := x.shards
+	tmpLabels := promutils.GetLabels()
+	var idxs []int
+	reroutedRows := 0
+	for _, ts := range tssBlock {
+		hashLabels := getShardingLabels(tmpLabels, ts.Labels)
+		h := getLabelsHash(hashLabels)
+		var rerouted bool
+		idxs, rerouted = sr.getNodes(idxs[:0], h, replicas, isEligible)
+		if rerouted {
+			reroutedRows += len(ts.Samples)
+		}
+		for _, idx := range idxs {
+			shards[idx] = append(shards[idx], ts)
+		}
+	}
+	promutils.PutLabels(tmpLabels)
+	shardByURLReroutedRows.Add(reroutedRows)
+
+	rwctxsByIdx := make([]*remoteWriteCtx, n)
+	for _, rwctx := range rwctxs {
+		rwctxsByIdx[rwctx.idx] = rwctx
+	}
+	return pushShardsToRemoteStorages(rwctxsByIdx, shards, forceDropSamplesOnFailure)
+}
+
+var shardByURLReroutedRows = metrics.NewCounter(`vmagent_remotewrite_shard_rerouted_rows_total`)
+
+// getShardingLabels returns labels, which must be used for sharding the series with the given labels.
+//
+// tmpLabels is used as a buffer for the returned labels.
+func getShardingLabels(tmpLabels *promutils.Labels, labels []prompbmarshal.Label) []prompbmarshal.Label {
+	hashLabels := labels
+	if len(shardByURLLabelsMap) > 0 {
+		hashLabels = tmpLabels.Labels[:0]
+		for _, label := range labels {
+			if _, ok := shardByURLLabelsMap[label.Name]; ok {
+				hashLabels = append(hashLabels, label)
+			}
+		}
+		tmpLabels.Labels = hashLabels
+	} else if len(shardByURLIgnoreLabelsMap) > 0 {
+		hashLabels = tmpLabels.Labels[:0]
+		for _, label := range labels {
+			if _, ok := shardByURLIgnoreLabelsMap[label.Name]; !ok {
+				hashLabels = append(hashLabels, label)
+			}
+		}
+		tmpLabels.Labels = hashLabels
+	}
+	return hashLabels
+}
+
+// pushShardsToRemoteStorages pushes shards[i] to rwctxs[i].
+//
+// rwctxs may contain nil items for remote storage systems without shards.
+func pushShardsToRemoteStorages(rwctxs []*remoteWriteCtx, shards [][]prompbmarshal.TimeSeries, forceDropSamplesOnFailure bool) bool {
 	// Push sharded samples to remote storage systems in parallel in order to reduce
 	// the time needed for sending the data to multiple remote storage systems.
 	var wg sync.WaitGroup
 	var anyPushFailed atomic.Bool
 	for i, rwctx := range rwctxs {
 		shard := shards[i]
-		if len(shard) == 0 {
+		if len(shard) == 0 || rwctx == nil {
 			continue
 		}
 		wg.Add(1)
@@ -758,6 +861,9 @@ type remoteWriteCtx struct {
 	rowsDroppedOnPushFailure *metrics.Counter
 }
 
+// tmpDataEncryption is used for encrypting pending data at -remoteWrite.tmpDataPath if -remoteWrite.tmpDataEncryptionKeyFile is set.
+var tmpDataEncryption *persistentqueue.Encryption
+
 func newRemoteWriteCtx(argIdx int, remoteWriteURL *url.URL, maxInmemoryBlocks int, sanitizedURL string) *remoteWriteCtx {
 	// strip query params, otherwise changing params resets pq
 	pqURL := *remoteWriteURL
@@ -773,7 +879,14 @@ func newRemoteWriteCtx(argIdx int, remoteWriteURL *url.URL, maxInmemoryBlocks in
 	}
 
 	isPQDisabled := disableOnDiskQueue.GetOptionalArg(argIdx)
-	fq := persistentqueue.MustOpenFastQueue(queuePath, sanitizedURL, maxInmemoryBlocks, maxPendingBytes, isPQDisabled)
+	pqOpts := &persistentqueue.Options{
+		Encryption:              tmpDataEncryption,
+		MaxBlockAge:             maxBlockAge.GetOptionalArg(argIdx),
+		NewestFirst:             replayNewestFirst.GetOptionalArg(argIdx),
+		BacklogReadRateLimit:    backlogReplayRateLimit.GetOptionalArg(argIdx),
+		QuarantineCorruptedData: *quarantineCorruptedData,
+	}
+	fq := persistentqueue.MustOpenFastQueueWithOptions(queuePath, sanitizedURL, maxInmemoryBlocks, maxPendingBytes, isPQDisabled, pqOpts)
 	_ = metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_pending_data_bytes{path=%q, url=%q}`, queuePath, sanitizedURL), func() float64 {
 		return float64(fq.GetPendingBytes())
 	})
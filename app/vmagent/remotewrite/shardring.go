@@ -0,0 +1,289 @@
+package remotewrite
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	shardByURLConsistentHash = flag.Bool("remoteWrite.shardByURL.consistentHash", false, "Whether to use consistent hashing for sharding outgoing series "+
+		"among -remoteWrite.url when -remoteWrite.shardByURL is set. In this mode only a small share of series is moved to other -remoteWrite.url "+
+		"when -remoteWrite.url list changes, while series for unhealthy -remoteWrite.url are temporarily rerouted to the next -remoteWrite.url on the hash ring. "+
+		"See https://docs.victoriametrics.com/vmagent/#consistent-hashing")
+	shardByURLVirtualNodes = flag.Int("remoteWrite.shardByURL.virtualNodes", 128, "The number of points per every -remoteWrite.url on the consistent hash ring. "+
+		"Higher values improve the evenness of series distribution at the cost of higher memory usage. See -remoteWrite.shardByURL.consistentHash")
+	shardByURLMaxErrorRatio = flag.Float64("remoteWrite.shardByURL.maxErrorRatio", 0.5, "The maximum ratio of failed requests to -remoteWrite.url "+
+		"during -remoteWrite.shardByURL.healthCheckInterval before the -remoteWrite.url is considered unhealthy and its series are rerouted to other -remoteWrite.url. "+
+		"Zero value disables error-based health checks. See -remoteWrite.shardByURL.consistentHash")
+	shardByURLMaxPendingBytes = flagutil.NewBytes("remoteWrite.shardByURL.maxPendingBytes", 0, "The maximum size of pending data in the queue for -remoteWrite.url "+
+		"before the -remoteWrite.url is considered unhealthy and its series are rerouted to other -remoteWrite.url. "+
+		"Zero value disables queue-based health checks. See -remoteWrite.shardByURL.consistentHash")
+	shardByURLHealthCheckInterval = flag.Duration("remoteWrite.shardByURL.healthCheckInterval", 10*time.Second, "How frequently to re-evaluate the health "+
+		"of -remoteWrite.url for -remoteWrite.shardByURL.consistentHash")
+)
+
+// shardRing is a consistent hash ring for sharding series among remote storage systems.
+type shardRing struct {
+	// points contains virtual nodes sorted by hash.
+	points []ringPoint
+
+	// unhealthy contains per-node health state.
+	//
+	// Series for unhealthy nodes are rerouted to the next healthy nodes on the ring.
+	unhealthy []atomic.Bool
+}
+
+type ringPoint struct {
+	hash uint64
+	idx  int
+}
+
+// newShardRing returns a ring for nodes identified by the given keys.
+//
+// Keys must be stable across restarts, so the majority of series stays at the same nodes after nodes are added or removed.
+func newShardRing(keys []string, virtualNodes int) *shardRing {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+	points := make([]ringPoint, 0, len(keys)*virtualNodes)
+	seen := make(map[string]int, len(keys))
+	for idx, key := range keys {
+		// Make keys unique if the same url is passed multiple times.
+		n := seen[key]
+		seen[key] = n + 1
+		if n > 0 {
+			key = key + "#" + strconv.Itoa(n)
+		}
+		for i := 0; i < virtualNodes; i++ {
+			h := xxhash.Sum64String(key + "/" + strconv.Itoa(i))
+			points = append(points, ringPoint{
+				hash: h,
+				idx:  idx,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].idx < points[j].idx
+	})
+	return &shardRing{
+		points:    points,
+		unhealthy: make([]atomic.Bool, len(keys)),
+	}
+}
+
+// getNodes appends up to replicas distinct node indexes for the given hash h to dst and returns the result.
+//
+// The returned bool is set to true if at least a single unhealthy node has been skipped.
+// Nodes, which are unhealthy or aren't eligible according to isEligible, are skipped.
+// If there are no enough healthy nodes, then unhealthy eligible nodes are used, so the data isn't lost.
+// isEligible may be nil; in this case all the nodes are eligible.
+func (sr *shardRing) getNodes(dst []int, h uint64, replicas int, isEligible func(idx int) bool) ([]int, bool) {
+	dstLen := len(dst)
+	rerouted := false
+	points := sr.points
+	start := sort.Search(len(points), func(i int) bool {
+		return points[i].hash >= h
+	})
+	for i := 0; i < len(points) && len(dst)-dstLen < replicas; i++ {
+		idx := points[(start+i)%len(points)].idx
+		if (isEligible != nil && !isEligible(idx)) || containsInt(dst[dstLen:], idx) {
+			continue
+		}
+		if sr.unhealthy[idx].Load() {
+			rerouted = true
+			continue
+		}
+		dst = append(dst, idx)
+	}
+	if len(dst)-dstLen >= replicas {
+		return dst, rerouted
+	}
+
+	// Fall back to unhealthy nodes.
+	for i := 0; i < len(points) && len(dst)-dstLen < replicas; i++ {
+		idx := points[(start+i)%len(points)].idx
+		if (isEligible != nil && !isEligible(idx)) || containsInt(dst[dstLen:], idx) {
+			continue
+		}
+		dst = append(dst, idx)
+	}
+	return dst, rerouted
+}
+
+func containsInt(a []int, n int) bool {
+	for _, x := range a {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
+
+// urlHealthStats contains cumulative stats used for determining the health of remote storage.
+type urlHealthStats struct {
+	requestsOK     uint64
+	requestsFailed uint64
+	pendingBytes   uint64
+}
+
+// unhealthyRetryChecks is the number of consecutive health checks without requests to unhealthy node
+// before its series are routed back to it.
+//
+// Unhealthy node may receive no requests at all, since its series are rerouted to other nodes,
+// so it is given a chance to recover periodically. If it is still unhealthy, then its series are rerouted again at the next check.
+const unhealthyRetryChecks = 3
+
+// urlHealthChecker periodically updates the health state of shardRing nodes.
+type urlHealthChecker struct {
+	sr       *shardRing
+	getStats func(idx int) urlHealthStats
+	names    []string
+
+	maxErrorRatio   float64
+	maxPendingBytes uint64
+
+	prevStats []urlHealthStats
+
+	// idleChecks contains the number of consecutive checks without requests per every unhealthy node.
+	idleChecks []int
+
+	wg     sync.WaitGroup
+	stopCh chan struct{}
+}
+
+func newURLHealthChecker(sr *shardRing, names []string, getStats func(idx int) urlHealthStats, maxErrorRatio float64, maxPendingBytes uint64) *urlHealthChecker {
+	hc := &urlHealthChecker{
+		sr:              sr,
+		getStats:        getStats,
+		names:           names,
+		maxErrorRatio:   maxErrorRatio,
+		maxPendingBytes: maxPendingBytes,
+		prevStats:       make([]urlHealthStats, len(names)),
+		idleChecks:      make([]int, len(names)),
+		stopCh:          make(chan struct{}),
+	}
+	for idx := range names {
+		hc.prevStats[idx] = getStats(idx)
+		_ = metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_shard_unhealthy{url=%q}`, names[idx]), func() float64 {
+			if sr.unhealthy[idx].Load() {
+				return 1
+			}
+			return 0
+		})
+	}
+	return hc
+}
+
+func (hc *urlHealthChecker) start(interval time.Duration) {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-hc.stopCh:
+				return
+			case <-t.C:
+				hc.check()
+			}
+		}
+	}()
+}
+
+func (hc *urlHealthChecker) mustStop() {
+	close(hc.stopCh)
+	hc.wg.Wait()
+}
+
+// check updates the health state for every node according to the stats collected since the previous check.
+func (hc *urlHealthChecker) check() {
+	for idx := range hc.prevStats {
+		stats := hc.getStats(idx)
+		prev := hc.prevStats[idx]
+		hc.prevStats[idx] = stats
+
+		ok := stats.requestsOK - prev.requestsOK
+		failed := stats.requestsFailed - prev.requestsFailed
+		reason := ""
+		if hc.maxErrorRatio > 0 && failed > 0 && float64(failed)/float64(ok+failed) > hc.maxErrorRatio {
+			reason = fmt.Sprintf("%d out of %d requests failed", failed, ok+failed)
+		} else if hc.maxPendingBytes > 0 && stats.pendingBytes > hc.maxPendingBytes {
+			reason = fmt.Sprintf("pending data size %d bytes exceeds -remoteWrite.shardByURL.maxPendingBytes=%d", stats.pendingBytes, hc.maxPendingBytes)
+		}
+
+		wasUnhealthy := hc.sr.unhealthy[idx].Load()
+		isUnhealthy := reason != ""
+		if wasUnhealthy && !isUnhealthy && ok == 0 {
+			// There were no requests during the interval, e.g. the client sleeps in retry backoff.
+			// Keep the node unhealthy in order to prevent series from flapping between nodes,
+			// until it stays idle for unhealthyRetryChecks intervals.
+			hc.idleChecks[idx]++
+			if hc.idleChecks[idx] < unhealthyRetryChecks {
+				continue
+			}
+			logger.Infof("routing series back to -remoteWrite.url=%q after %d health checks without requests in order to verify whether it has been recovered",
+				hc.names[idx], hc.idleChecks[idx])
+		}
+		hc.idleChecks[idx] = 0
+		if wasUnhealthy == isUnhealthy {
+			continue
+		}
+		hc.sr.unhealthy[idx].Store(isUnhealthy)
+		if isUnhealthy {
+			logger.Warnf("rerouting series from unhealthy -remoteWrite.url=%q to other urls: %s", hc.names[idx], reason)
+		} else {
+			logger.Infof("-remoteWrite.url=%q has been recovered; routing its series back", hc.names[idx])
+		}
+	}
+}
+
+var (
+	shardRingGlobal        *shardRing
+	urlHealthCheckerGlobal *urlHealthChecker
+)
+
+// initShardRing initializes consistent hash ring for -remoteWrite.shardByURL.consistentHash.
+//
+// It must be called after rwctxsGlobal initialization.
+func initShardRing() {
+	keys := make([]string, len(rwctxsGlobal))
+	names := make([]string, len(rwctxsGlobal))
+	for i, rwctx := range rwctxsGlobal {
+		// Use the url without query args as a ring key, so the ring stays stable when only query args are changed.
+		u, err := url.Parse((*remoteWriteURLs)[i])
+		if err != nil {
+			logger.Fatalf("BUG: cannot parse -remoteWrite.url: %s", err)
+		}
+		u.RawQuery = ""
+		u.Fragment = ""
+		keys[i] = u.String()
+		names[i] = rwctx.c.sanitizedURL
+	}
+	shardRingGlobal = newShardRing(keys, *shardByURLVirtualNodes)
+	getStats := func(idx int) urlHealthStats {
+		rwctx := rwctxsGlobal[idx]
+		return urlHealthStats{
+			requestsOK:     rwctx.c.requestsOKCount.Get(),
+			requestsFailed: rwctx.c.requestsFailed.Load(),
+			pendingBytes:   rwctx.fq.GetPendingBytes(),
+		}
+	}
+	urlHealthCheckerGlobal = newURLHealthChecker(shardRingGlobal, names, getStats, *shardByURLMaxErrorRatio, uint64(shardByURLMaxPendingBytes.IntN()))
+	urlHealthCheckerGlobal.start(*shardByURLHealthCheckInterval)
+}
@@ -0,0 +1,213 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestShardRing_Distribution(t *testing.T) {
+	f := func(nodesCount int) {
+		t.Helper()
+
+		keys := make([]string, nodesCount)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("http://vminsert-%d:8480/insert/0/prometheus/api/v1/write", i)
+		}
+		sr := newShardRing(keys, 128)
+
+		itemsCount := 10_000 * nodesCount
+		m := make([]int, nodesCount)
+		var idxs []int
+		for i := 0; i < itemsCount; i++ {
+			h := getLabelsHash(newTestLabels(i))
+			idxs, _ = sr.getNodes(idxs[:0], h, 1, nil)
+			m[idxs[0]]++
+		}
+
+		expectedItemsPerNode := itemsCount / nodesCount
+		for _, n := range m {
+			if math.Abs(1-float64(n)/float64(expectedItemsPerNode)) > 0.2 {
+				t.Fatalf("unexpected items at the node for %d nodes; got %d; want around %d", nodesCount, n, expectedItemsPerNode)
+			}
+		}
+	}
+
+	f(2)
+	f(3)
+	f(5)
+	f(10)
+}
+
+func TestShardRing_AddNode(t *testing.T) {
+	keys := []string{"http://foo/api/v1/write", "http://bar/api/v1/write", "http://baz/api/v1/write"}
+	sr := newShardRing(keys, 128)
+	srNew := newShardRing(append(keys, "http://qux/api/v1/write"), 128)
+
+	// Only the series for the added node must be moved.
+	itemsCount := 10_000
+	moved := 0
+	var idxs, idxsNew []int
+	for i := 0; i < itemsCount; i++ {
+		h := getLabelsHash(newTestLabels(i))
+		idxs, _ = sr.getNodes(idxs[:0], h, 1, nil)
+		idxsNew, _ = srNew.getNodes(idxsNew[:0], h, 1, nil)
+		if idxs[0] != idxsNew[0] {
+			if idxsNew[0] != 3 {
+				t.Fatalf("series %d is moved from node %d to node %d instead of the added node", i, idxs[0], idxsNew[0])
+			}
+			moved++
+		}
+	}
+	if moved > itemsCount/3 {
+		t.Fatalf("too many series moved after adding a node; got %d out of %d", moved, itemsCount)
+	}
+}
+
+func TestShardRing_GetNodes(t *testing.T) {
+	keys := []string{"http://foo/api/v1/write", "http://bar/api/v1/write", "http://baz/api/v1/write", "http://qux/api/v1/write"}
+	sr := newShardRing(keys, 16)
+	h := getLabelsHash(newTestLabels(42))
+
+	// Replicas must be placed at distinct nodes.
+	all, rerouted := sr.getNodes(nil, h, 4, nil)
+	if rerouted {
+		t.Fatalf("unexpected rerouting without unhealthy nodes")
+	}
+	seen := make(map[int]bool)
+	for _, idx := range all {
+		if seen[idx] {
+			t.Fatalf("duplicate node %d in %v", idx, all)
+		}
+		seen[idx] = true
+	}
+	if len(all) != 4 {
+		t.Fatalf("unexpected number of nodes; got %v; want 4 nodes", all)
+	}
+
+	f := func(replicas int, unhealthy []int, isEligible func(idx int) bool, resultExpected []int, reroutedExpected bool) {
+		t.Helper()
+		for i := range sr.unhealthy {
+			sr.unhealthy[i].Store(false)
+		}
+		for _, idx := range unhealthy {
+			sr.unhealthy[idx].Store(true)
+		}
+		result, rerouted := sr.getNodes(nil, h, replicas, isEligible)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected nodes; got %v; want %v", result, resultExpected)
+		}
+		if rerouted != reroutedExpected {
+			t.Fatalf("unexpected rerouted; got %v; want %v", rerouted, reroutedExpected)
+		}
+	}
+
+	// all the nodes are healthy
+	f(1, nil, nil, all[:1], false)
+	f(2, nil, nil, all[:2], false)
+
+	// the primary node is unhealthy
+	f(1, all[:1], nil, all[1:2], true)
+	f(2, all[:1], nil, all[1:3], true)
+
+	// the secondary node is unhealthy
+	f(1, all[1:2], nil, all[:1], false)
+	f(2, all[1:2], nil, []int{all[0], all[2]}, true)
+
+	// all the nodes are unhealthy - fall back to the original nodes
+	f(2, all, nil, all[:2], true)
+
+	// the primary node isn't eligible
+	f(1, nil, func(idx int) bool { return idx != all[0] }, all[1:2], false)
+}
+
+func TestURLHealthChecker(t *testing.T) {
+	sr := newShardRing([]string{"http://foo/api/v1/write", "http://bar/api/v1/write"}, 16)
+	stats := make([]urlHealthStats, 2)
+	getStats := func(idx int) urlHealthStats {
+		return stats[idx]
+	}
+	hc := newURLHealthChecker(sr, []string{"1:test-health-foo", "2:test-health-bar"}, getStats, 0.5, 1000)
+
+	f := func(foo, bar urlHealthStats, unhealthyFoo, unhealthyBar bool) {
+		t.Helper()
+		stats[0] = foo
+		stats[1] = bar
+		hc.check()
+		if sr.unhealthy[0].Load() != unhealthyFoo || sr.unhealthy[1].Load() != unhealthyBar {
+			t.Fatalf("unexpected health state; got foo=%v, bar=%v; want foo=%v, bar=%v",
+				sr.unhealthy[0].Load(), sr.unhealthy[1].Load(), unhealthyFoo, unhealthyBar)
+		}
+	}
+
+	// no requests
+	f(urlHealthStats{}, urlHealthStats{}, false, false)
+
+	// foo returns errors
+	f(urlHealthStats{requestsOK: 1, requestsFailed: 9}, urlHealthStats{requestsOK: 10}, true, false)
+
+	// foo continues returning errors
+	f(urlHealthStats{requestsOK: 1, requestsFailed: 19}, urlHealthStats{requestsOK: 20}, true, false)
+
+	// foo doesn't recover without new requests, e.g. during retry backoff - its cumulative stats are the same as at the previous check
+	f(urlHealthStats{requestsOK: 1, requestsFailed: 19}, urlHealthStats{requestsOK: 30}, true, false)
+
+	// foo recovers, while bar accumulates pending data
+	f(urlHealthStats{requestsOK: 11, requestsFailed: 19}, urlHealthStats{requestsOK: 40, pendingBytes: 2000}, false, true)
+
+	// bar drains pending data
+	f(urlHealthStats{requestsOK: 21, requestsFailed: 19}, urlHealthStats{requestsOK: 50, pendingBytes: 10}, false, false)
+}
+
+func TestURLHealthCheckerIdleRecovery(t *testing.T) {
+	sr := newShardRing([]string{"http://foo/api/v1/write", "http://bar/api/v1/write"}, 16)
+	stats := make([]urlHealthStats, 2)
+	getStats := func(idx int) urlHealthStats {
+		return stats[idx]
+	}
+	hc := newURLHealthChecker(sr, []string{"1:test-idle-foo", "2:test-idle-bar"}, getStats, 0.5, 0)
+
+	f := func(foo urlHealthStats, unhealthyExpected bool) {
+		t.Helper()
+		stats[0] = foo
+		hc.check()
+		if unhealthy := sr.unhealthy[0].Load(); unhealthy != unhealthyExpected {
+			t.Fatalf("unexpected health state for foo; got %v; want %v", unhealthy, unhealthyExpected)
+		}
+	}
+
+	// foo returns errors
+	fooStats := urlHealthStats{requestsOK: 1, requestsFailed: 9}
+	f(fooStats, true)
+
+	// foo receives no requests, since its series are rerouted to bar
+	for i := 1; i < unhealthyRetryChecks; i++ {
+		f(fooStats, true)
+	}
+
+	// foo gets series back after unhealthyRetryChecks idle checks
+	f(fooStats, false)
+
+	// foo is still broken, so its series are rerouted again
+	fooStats = urlHealthStats{requestsOK: 1, requestsFailed: 19}
+	f(fooStats, true)
+
+	// foo accepts the retried requests and recovers
+	f(urlHealthStats{requestsOK: 11, requestsFailed: 19}, false)
+}
+
+func newTestLabels(i int) []prompbmarshal.Label {
+	return []prompbmarshal.Label{
+		{
+			Name:  "__name__",
+			Value: fmt.Sprintf("some_name_%d", i),
+		},
+		{
+			Name:  "instance",
+			Value: fmt.Sprintf("host-%d:9100", i%100),
+		},
+	}
+}
@@ -3,6 +3,7 @@ package remotewrite
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
@@ -35,6 +36,9 @@ var (
 		"clients pushing data into the vmagent. See https://docs.victoriametrics.com/stream-aggregation/#ignore-aggregation-intervals-on-start")
 	streamAggrGlobalDropInputLabels = flagutil.NewArrayString("streamAggr.dropInputLabels", "An optional list of labels to drop from samples for aggregator "+
 		"before stream de-duplication and aggregation . See https://docs.victoriametrics.com/stream-aggregation/#dropping-unneeded-labels")
+	streamAggrStateDir = flag.String("streamAggr.stateDir", "", "Optional path to directory for persisting stream aggregation state across vmagent restarts "+
+		"for -streamAggr.config and -remoteWrite.streamAggr.config. By default, the aggregation state is lost on restart. "+
+		"See https://docs.victoriametrics.com/stream-aggregation/#persisting-aggregation-state")
 
 	// Per URL config
 	streamAggrConfig = flagutil.NewArrayString("remoteWrite.streamAggr.config", "Optional path to file with stream aggregation config for the corresponding -remoteWrite.url. "+
@@ -207,6 +211,7 @@ func newStreamAggrConfigGlobal() (*streamaggr.Aggregators, error) {
 		IgnoreOldSamples:     *streamAggrGlobalIgnoreOldSamples,
 		IgnoreFirstIntervals: *streamAggrGlobalIgnoreFirstIntervals,
 		KeepInput:            *streamAggrGlobalKeepInput,
+		StateDir:             *streamAggrStateDir,
 	}
 
 	sas, err := streamaggr.LoadFromFile(path, pushToRemoteStoragesTrackDropped, opts, "global")
@@ -240,6 +245,7 @@ func newStreamAggrConfigPerURL(idx int, pushFunc streamaggr.PushFunc) (*streamag
 		IgnoreOldSamples:     streamAggrIgnoreOldSamples.GetOptionalArg(idx),
 		IgnoreFirstIntervals: streamAggrIgnoreFirstIntervals.GetOptionalArg(idx),
 		KeepInput:            streamAggrKeepInput.GetOptionalArg(idx),
+		StateDir:             *streamAggrStateDir,
 	}
 
 	sas, err := streamaggr.LoadFromFile(path, pushFunc, opts, alias)
@@ -248,3 +254,25 @@ func newStreamAggrConfigPerURL(idx int, pushFunc streamaggr.PushFunc) (*streamag
 	}
 	return sas, nil
 }
+
+// WriteStreamAggrStatus writes /stream-agg page with the status of -streamAggr.config and -remoteWrite.streamAggr.config aggregators to w.
+func WriteStreamAggrStatus(w http.ResponseWriter, r *http.Request) {
+	sas := []*streamaggr.Aggregators{sasGlobal.Load()}
+	for _, rwctx := range rwctxsGlobal {
+		sas = append(sas, rwctx.sas.Load())
+	}
+	streamaggr.WriteAggregatorsStatus(w, r, sas)
+}
+
+// WriteStreamAggrDryRun writes /stream-agg-dry-run page to w.
+//
+// The dry run uses the settings for -streamAggr.config.
+func WriteStreamAggrDryRun(w http.ResponseWriter, r *http.Request) {
+	opts := &streamaggr.Options{
+		DedupInterval:    *streamAggrGlobalDedupInterval,
+		DropInputLabels:  *streamAggrGlobalDropInputLabels,
+		IgnoreOldSamples: *streamAggrGlobalIgnoreOldSamples,
+		KeepInput:        *streamAggrGlobalKeepInput,
+	}
+	streamaggr.WriteDryRun(w, r, opts)
+}
@@ -0,0 +1,488 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+)
+
+// Names of checks
+const (
+	checkInvalidConfig          = "invalid-config"
+	checkCounterWithoutRate     = "counter-without-rate"
+	checkForShorterThanInterval = "for-shorter-than-interval"
+	checkRecordingRuleName      = "recording-rule-name"
+	checkDuplicateSeries        = "duplicate-series"
+	checkMissingLabel           = "missing-label"
+	checkRegexpCouldBeExact     = "regexp-could-be-exact"
+)
+
+// checkDescriptions contains descriptions for all the checks
+var checkDescriptions = map[string]string{
+	checkInvalidConfig:          "Rules file can't be parsed or contains invalid groups",
+	checkCounterWithoutRate:     "Counter is used without rate() or increase() over a lookbehind window",
+	checkForShorterThanInterval: "Alerting rule has `for` shorter than the group evaluation interval",
+	checkRecordingRuleName:      "Recording rule name doesn't follow `level:metric:operations` naming convention",
+	checkDuplicateSeries:        "Recording rules produce the same series",
+	checkMissingLabel:           "Template references a label, which is missing in the expression result",
+	checkRegexpCouldBeExact:     "Regexp label filter can be replaced with exact match",
+}
+
+func (l *linter) lintRule(r *config.Rule) {
+	interval := l.group.Interval.Duration()
+	if interval <= 0 {
+		interval = l.evaluationInterval
+	}
+	if r.Alert != "" && r.For.Duration() > 0 && r.For.Duration() < interval {
+		l.report(r, checkForShorterThanInterval, SeverityWarning,
+			"`for: %s` is shorter than the group evaluation interval %s, so the alert becomes firing only after %s",
+			r.For.Duration(), interval, interval)
+	}
+	if r.Record != "" {
+		l.lintRecordingRule(r)
+	}
+
+	if l.group.Type.String() != "prometheus" {
+		return
+	}
+	expr, err := metricsql.Parse(r.Expr)
+	if err != nil {
+		// expressions are validated during parsing of the file
+		return
+	}
+	metricsql.VisitAll(expr, func(e metricsql.Expr) {
+		me, ok := e.(*metricsql.MetricExpr)
+		if !ok {
+			return
+		}
+		for _, lfs := range me.LabelFilterss {
+			for _, lf := range lfs {
+				if !lf.IsRegexp || regexp.QuoteMeta(lf.Value) != lf.Value {
+					continue
+				}
+				op := "="
+				if lf.IsNegative {
+					op = "!="
+				}
+				l.report(r, checkRegexpCouldBeExact, SeverityInfo,
+					"regexp filter on label %q with value %q doesn't contain special chars and can be replaced with `%s` filter",
+					lf.Label, lf.Value, op)
+			}
+		}
+	})
+	for _, c := range findRawCounters(expr, false, nil) {
+		if c.missingWindow {
+			l.report(r, checkCounterWithoutRate, SeverityInfo,
+				"counter %q is passed to %s() without lookbehind window in square brackets, so the window depends on the evaluation interval",
+				c.name, c.fn)
+			continue
+		}
+		l.report(r, checkCounterWithoutRate, SeverityWarning,
+			"counter %q is used without rate() or increase() over a lookbehind window; raw counter values depend on restarts of the monitored service",
+			c.name)
+	}
+	if r.Alert != "" {
+		l.lintTemplateLabels(r, expr)
+	}
+}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func (l *linter) lintRecordingRule(r *config.Rule) {
+	if !metricNameRe.MatchString(r.Record) {
+		l.report(r, checkRecordingRuleName, SeverityWarning, "%q isn't a valid metric name", r.Record)
+	} else if parts := strings.Split(r.Record, ":"); len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		l.report(r, checkRecordingRuleName, SeverityWarning,
+			"%q doesn't follow `level:metric:operations` naming convention; see https://prometheus.io/docs/practices/rules/", r.Record)
+	}
+	for k := range r.Labels {
+		if !labelNameRe.MatchString(k) {
+			l.report(r, checkRecordingRuleName, SeverityWarning, "%q isn't a valid label name", k)
+		}
+	}
+
+	labels := make(map[string]string)
+	for k, v := range l.group.Labels {
+		labels[k] = v
+	}
+	// rule labels have priority over group labels
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	series := r.Record + formatLabels(labels)
+	// Rules with distinct label filters in expressions are likely to produce distinct series,
+	// e.g. when the filtered labels are preserved in the results.
+	key := series
+	if l.group.Type.String() == "prometheus" {
+		key += getLabelFilters(r.Expr)
+	}
+	if ref, ok := l.recordingSeries[key]; ok {
+		l.report(r, checkDuplicateSeries, SeverityWarning,
+			"rule produces the same series %s with the same label filters in the expression as recording rule in group %q in file %q", series, ref.group, ref.file)
+		return
+	}
+	l.recordingSeries[key] = seriesRef{
+		file:  l.file,
+		group: l.group.Name,
+	}
+}
+
+// getLabelFilters returns sorted label filters from all the series selectors in expr except of metric name filters.
+func getLabelFilters(expr string) string {
+	e, err := metricsql.Parse(expr)
+	if err != nil {
+		// expressions are validated during parsing of the file
+		return ""
+	}
+	var filters []string
+	metricsql.VisitAll(e, func(e metricsql.Expr) {
+		me, ok := e.(*metricsql.MetricExpr)
+		if !ok {
+			return
+		}
+		for _, lfs := range me.LabelFilterss {
+			for i := range lfs {
+				lf := &lfs[i]
+				if lf.Label == "__name__" {
+					continue
+				}
+				filters = append(filters, string(lf.AppendString(nil)))
+			}
+		}
+	})
+	sort.Strings(filters)
+	return "{" + strings.Join(filters, ",") + "}"
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var keys []string
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%s=%q", k, labels[k])
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// counterSafeFuncs contains functions, which make sense for counters
+var counterSafeFuncs = map[string]bool{
+	"rate":                   true,
+	"irate":                  true,
+	"rate_prometheus":        true,
+	"increase":               true,
+	"increase_pure":          true,
+	"increase_prometheus":    true,
+	"increases_over_time":    true,
+	"decreases_over_time":    true,
+	"rollup_rate":            true,
+	"rollup_increase":        true,
+	"delta":                  true,
+	"delta_prometheus":       true,
+	"idelta":                 true,
+	"deriv":                  true,
+	"deriv_fast":             true,
+	"ideriv":                 true,
+	"resets":                 true,
+	"changes":                true,
+	"changes_prometheus":     true,
+	"lifetime":               true,
+	"lag":                    true,
+	"present_over_time":      true,
+	"absent_over_time":       true,
+	"count_over_time":        true,
+	"tlast_change_over_time": true,
+	"timestamp":              true,
+	"absent":                 true,
+	"count":                  true,
+	"group":                  true,
+}
+
+// windowedFuncs contains counterSafeFuncs, which require lookbehind window
+var windowedFuncs = map[string]bool{
+	"rate":                true,
+	"irate":               true,
+	"rate_prometheus":     true,
+	"increase":            true,
+	"increase_pure":       true,
+	"increase_prometheus": true,
+	"delta":               true,
+	"delta_prometheus":    true,
+	"idelta":              true,
+	"deriv":               true,
+	"deriv_fast":          true,
+	"ideriv":              true,
+}
+
+type rawCounter struct {
+	name string
+	// fn and missingWindow are set if the counter is passed to fn without lookbehind window
+	fn            string
+	missingWindow bool
+}
+
+func isCounterName(name string) bool {
+	// results of recording rules are skipped, since they are usually aggregated already
+	if strings.Contains(name, ":") {
+		return false
+	}
+	for _, suffix := range []string{"_total", "_count", "_sum", "_bucket"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func metricName(me *metricsql.MetricExpr) string {
+	for _, lfs := range me.LabelFilterss {
+		for _, lf := range lfs {
+			if lf.Label == "__name__" && !lf.IsRegexp && !lf.IsNegative {
+				return lf.Value
+			}
+		}
+	}
+	return ""
+}
+
+// findRawCounters appends to dst counters from e, which aren't passed to counterSafeFuncs.
+func findRawCounters(e metricsql.Expr, safe bool, dst []rawCounter) []rawCounter {
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		if name := metricName(t); !safe && isCounterName(name) {
+			dst = append(dst, rawCounter{name: name})
+		}
+	case *metricsql.RollupExpr:
+		dst = findRawCounters(t.Expr, safe, dst)
+	case *metricsql.FuncExpr:
+		name := strings.ToLower(t.Name)
+		for _, arg := range t.Args {
+			if windowedFuncs[name] {
+				if me := unwrapMetricExpr(arg); me != nil && isCounterName(metricName(me)) {
+					dst = append(dst, rawCounter{name: metricName(me), fn: name, missingWindow: true})
+					continue
+				}
+			}
+			dst = findRawCounters(arg, safe || counterSafeFuncs[name], dst)
+		}
+	case *metricsql.AggrFuncExpr:
+		name := strings.ToLower(t.Name)
+		for _, arg := range t.Args {
+			dst = findRawCounters(arg, safe || counterSafeFuncs[name], dst)
+		}
+	case *metricsql.BinaryOpExpr:
+		dst = findRawCounters(t.Left, safe, dst)
+		dst = findRawCounters(t.Right, safe, dst)
+	}
+	return dst
+}
+
+// unwrapMetricExpr returns MetricExpr from e if e has no lookbehind window.
+func unwrapMetricExpr(e metricsql.Expr) *metricsql.MetricExpr {
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		return t
+	case *metricsql.RollupExpr:
+		if t.Window != nil {
+			return nil
+		}
+		me, _ := t.Expr.(*metricsql.MetricExpr)
+		return me
+	}
+	return nil
+}
+
+var templateLabelRes = []*regexp.Regexp{
+	regexp.MustCompile(`\$labels\.([a-zA-Z_][a-zA-Z0-9_]*)`),
+	regexp.MustCompile(`\.Labels\.([a-zA-Z_][a-zA-Z0-9_]*)`),
+	regexp.MustCompile(`index\s+\$labels\s+"([^"]+)"`),
+}
+
+func (l *linter) lintTemplateLabels(r *config.Rule, expr metricsql.Expr) {
+	labels, ok := outputLabels(expr)
+	if !ok {
+		return
+	}
+	possible := make(map[string]struct{})
+	for k := range labels {
+		possible[k] = struct{}{}
+	}
+	for k := range l.group.Labels {
+		possible[k] = struct{}{}
+	}
+	for k := range r.Labels {
+		possible[k] = struct{}{}
+	}
+	for _, k := range l.externalLabels {
+		possible[k] = struct{}{}
+	}
+	possible["alertname"] = struct{}{}
+	possible["alertgroup"] = struct{}{}
+
+	var exprLabels []string
+	for k := range labels {
+		exprLabels = append(exprLabels, k)
+	}
+	sort.Strings(exprLabels)
+
+	reported := make(map[string]struct{})
+	for _, tpls := range []map[string]string{r.Labels, r.Annotations} {
+		var keys []string
+		for k := range tpls {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, re := range templateLabelRes {
+				for _, m := range re.FindAllStringSubmatch(tpls[k], -1) {
+					name := m[1]
+					if _, ok := possible[name]; ok {
+						continue
+					}
+					if _, ok := reported[name]; ok {
+						continue
+					}
+					reported[name] = struct{}{}
+					l.report(r, checkMissingLabel, SeverityWarning,
+						"template %q references label %q, which is missing in the expression result; the expression returns only the following labels: [%s]",
+						k, name, strings.Join(exprLabels, ", "))
+				}
+			}
+		}
+	}
+}
+
+// labelsDroppingAggrFuncs contains aggregate functions, which return only labels from `by` modifier
+var labelsDroppingAggrFuncs = map[string]bool{
+	"sum":      true,
+	"min":      true,
+	"max":      true,
+	"avg":      true,
+	"count":    true,
+	"group":    true,
+	"stddev":   true,
+	"stdvar":   true,
+	"quantile": true,
+	"median":   true,
+	"geomean":  true,
+}
+
+// noLabelsFuncs contains functions, which return series without labels
+var noLabelsFuncs = map[string]bool{
+	"vector": true,
+	"time":   true,
+	"now":    true,
+	"pi":     true,
+	"scalar": true,
+}
+
+// outputLabels returns all the labels, which can be present in the result of e.
+//
+// It returns false if labels can't be determined without executing e.
+func outputLabels(e metricsql.Expr) (map[string]struct{}, bool) {
+	switch t := e.(type) {
+	case *metricsql.NumberExpr, *metricsql.StringExpr:
+		return map[string]struct{}{}, true
+	case *metricsql.RollupExpr:
+		return outputLabels(t.Expr)
+	case *metricsql.AggrFuncExpr:
+		if !labelsDroppingAggrFuncs[strings.ToLower(t.Name)] {
+			return nil, false
+		}
+		switch strings.ToLower(t.Modifier.Op) {
+		case "":
+			return map[string]struct{}{}, true
+		case "by":
+			labels := make(map[string]struct{})
+			for _, arg := range t.Modifier.Args {
+				labels[arg] = struct{}{}
+			}
+			return labels, true
+		}
+		return nil, false
+	case *metricsql.FuncExpr:
+		name := strings.ToLower(t.Name)
+		if noLabelsFuncs[name] {
+			return map[string]struct{}{}, true
+		}
+		if strings.HasPrefix(name, "label_") || name == "absent" || name == "alias" || name == "union" {
+			return nil, false
+		}
+		var vectorArg metricsql.Expr
+		for _, arg := range t.Args {
+			switch arg.(type) {
+			case *metricsql.NumberExpr, *metricsql.StringExpr:
+				continue
+			}
+			if vectorArg != nil {
+				return nil, false
+			}
+			vectorArg = arg
+		}
+		if vectorArg == nil {
+			return map[string]struct{}{}, true
+		}
+		return outputLabels(vectorArg)
+	case *metricsql.BinaryOpExpr:
+		left, leftOK := outputLabels(t.Left)
+		right, rightOK := outputLabels(t.Right)
+		if _, ok := t.Left.(*metricsql.NumberExpr); ok {
+			return right, rightOK
+		}
+		if _, ok := t.Right.(*metricsql.NumberExpr); ok {
+			return left, leftOK
+		}
+		switch strings.ToLower(t.Op) {
+		case "or":
+			if !leftOK || !rightOK {
+				return nil, false
+			}
+			for k := range right {
+				left[k] = struct{}{}
+			}
+			return left, true
+		case "and", "unless":
+			return left, leftOK
+		}
+		switch strings.ToLower(t.JoinModifier.Op) {
+		case "group_left":
+			return joinLabels(left, leftOK, t.JoinModifier.Args)
+		case "group_right":
+			return joinLabels(right, rightOK, t.JoinModifier.Args)
+		}
+		if t.GroupModifier.Op != "" || leftOK {
+			return left, leftOK
+		}
+		// one-to-one matching requires the same labels on both sides
+		return right, rightOK
+	}
+	return nil, false
+}
+
+func joinLabels(labels map[string]struct{}, ok bool, extra []string) (map[string]struct{}, bool) {
+	if !ok {
+		return nil, false
+	}
+	for _, k := range extra {
+		labels[k] = struct{}{}
+	}
+	return labels, true
+}
@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/templates"
+)
+
+// Severity levels of problems
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Problem describes an issue found in rules configuration
+type Problem struct {
+	File string `json:"file"`
+	// Line is the line of the group or rule definition in File. It is 0 if unknown.
+	Line     int    `json:"line,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Lint checks rules from files and writes found problems to w in the given format.
+//
+// Supported formats are `text`, `json` and `sarif`.
+// externalLabels contains names of labels, which are added to all the alerts via `-external.label` vmalert flag.
+// It returns true if problems with error or warning severity were found.
+func Lint(files []string, format string, evaluationInterval time.Duration, externalLabels []string, w io.Writer) (bool, error) {
+	writeFn, ok := writers[format]
+	if !ok {
+		return false, fmt.Errorf("unsupported output format %q; supported formats: text, json, sarif", format)
+	}
+	if err := templates.Load([]string{}, url.URL{}); err != nil {
+		return false, fmt.Errorf("failed to load templates: %w", err)
+	}
+	ruleFiles, err := config.ReadFromFS(files)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rule files %q: %w", files, err)
+	}
+	if len(ruleFiles) == 0 {
+		return false, fmt.Errorf("no rule files found in %q", files)
+	}
+	problems := lintFiles(ruleFiles, evaluationInterval, externalLabels)
+	if err := writeFn(w, problems); err != nil {
+		return false, fmt.Errorf("cannot write lint results: %w", err)
+	}
+	for _, p := range problems {
+		if p.Severity != SeverityInfo {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func lintFiles(files map[string][]byte, evaluationInterval time.Duration, externalLabels []string) []Problem {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	l := &linter{
+		evaluationInterval: evaluationInterval,
+		externalLabels:     externalLabels,
+		recordingSeries:    make(map[string]seriesRef),
+	}
+	for _, name := range names {
+		l.lintFile(name, files[name])
+	}
+	return l.problems
+}
+
+// seriesRef refers to the recording rule, which produces series
+type seriesRef struct {
+	file  string
+	group string
+}
+
+type linter struct {
+	evaluationInterval time.Duration
+	externalLabels     []string
+	// recordingSeries contains series produced by recording rules from all the files
+	recordingSeries map[string]seriesRef
+	problems        []Problem
+
+	// file, lines and group are set for the currently checked group
+	file  string
+	lines []string
+	group *config.Group
+	// groupLine is the line of the currently checked group definition
+	groupLine int
+}
+
+func (l *linter) lintFile(file string, data []byte) {
+	l.file = file
+	l.lines = strings.Split(string(data), "\n")
+	l.group = nil
+	l.groupLine = 0
+	groups, err := config.ParseFiles(map[string][]byte{file: data}, notifier.ValidateTemplates, true)
+	if err != nil {
+		l.report(nil, checkInvalidConfig, SeverityError, "%s", err)
+		return
+	}
+	for i := range groups {
+		g := &groups[i]
+		l.group = g
+		l.groupLine = findLine(l.lines, 0, "name", g.Name)
+		for j := range g.Rules {
+			l.lintRule(&g.Rules[j])
+		}
+	}
+}
+
+func (l *linter) report(r *config.Rule, check, severity, format string, args ...any) {
+	p := Problem{
+		File:     l.file,
+		Check:    check,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if l.group != nil {
+		p.Group = l.group.Name
+		p.Line = l.groupLine
+	}
+	if r != nil {
+		p.Rule = r.Name()
+		key := "record"
+		if r.Alert != "" {
+			key = "alert"
+		}
+		if line := findLine(l.lines, l.groupLine, key, r.Name()); line > 0 {
+			p.Line = line
+		}
+	}
+	l.problems = append(l.problems, p)
+}
+
+// findLine returns the number of the first line after the line start, which sets key to value.
+// It returns 0 if there is no such line.
+func findLine(lines []string, start int, key, value string) int {
+	re := regexp.MustCompile(`^\s*(-\s*)?` + regexp.QuoteMeta(key) + `:\s*["']?` + regexp.QuoteMeta(value) + `["']?\s*(#.*)?$`)
+	for i := start; i < len(lines); i++ {
+		if re.MatchString(lines[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
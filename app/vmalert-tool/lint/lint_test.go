@@ -0,0 +1,137 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+func TestLint(t *testing.T) {
+	f := func(file string, failedExpected bool, checksExpected []string) {
+		t.Helper()
+
+		var bb bytes.Buffer
+		failed, err := Lint([]string{file}, "json", time.Minute, []string{"cluster"}, &bb)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if failed != failedExpected {
+			t.Fatalf("unexpected lint result; got %v; want %v", failed, failedExpected)
+		}
+		var problems []Problem
+		if err := json.Unmarshal(bb.Bytes(), &problems); err != nil {
+			t.Fatalf("cannot parse json output: %s", err)
+		}
+		checks := make([]string, 0, len(problems))
+		for _, p := range problems {
+			if p.File != file {
+				t.Fatalf("unexpected file %q in problem %+v", p.File, p)
+			}
+			checks = append(checks, p.Check)
+		}
+		if !reflect.DeepEqual(checks, checksExpected) {
+			t.Fatalf("unexpected checks; got %v; want %v", checks, checksExpected)
+		}
+	}
+
+	f("testdata/good.yaml", false, []string{})
+	f("testdata/bad.yaml", true, []string{checkInvalidConfig})
+	f("testdata/rules.yaml", true, []string{
+		checkRecordingRuleName,
+		checkRegexpCouldBeExact,
+		checkCounterWithoutRate,
+		checkForShorterThanInterval,
+		checkCounterWithoutRate,
+		checkMissingLabel,
+		checkDuplicateSeries,
+	})
+}
+
+func TestLintSARIF(t *testing.T) {
+	var bb bytes.Buffer
+	if _, err := Lint([]string{"testdata/rules.yaml"}, "sarif", time.Minute, nil, &bb); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(bb.Bytes(), &log); err != nil {
+		t.Fatalf("cannot parse sarif output: %s", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected sarif log: %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 7 {
+		t.Fatalf("unexpected number of results; got %d; want 7", len(results))
+	}
+	r := results[0]
+	if r.RuleID != checkRecordingRuleName || r.Level != "warning" || r.Locations[0].PhysicalLocation.Region.StartLine != 7 {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if name := r.Locations[0].LogicalLocations[0].FullyQualifiedName; name != "requests/requests_errors_rate" {
+		t.Fatalf("unexpected logical location %q", name)
+	}
+
+	if _, err := Lint([]string{"testdata/rules.yaml"}, "xml", time.Minute, nil, &bb); err == nil {
+		t.Fatalf("expecting error for unsupported format")
+	}
+}
+
+func TestOutputLabels(t *testing.T) {
+	f := func(expr string, labelsExpected string) {
+		t.Helper()
+
+		e, err := metricsql.Parse(expr)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", expr, err)
+		}
+		labels, ok := outputLabels(e)
+		if !ok {
+			if labelsExpected != "unknown" {
+				t.Fatalf("unexpected unknown labels for %q", expr)
+			}
+			return
+		}
+		var names []string
+		for k := range labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		if s := strings.Join(names, ","); s != labelsExpected {
+			t.Fatalf("unexpected labels for %q; got %q; want %q", expr, s, labelsExpected)
+		}
+	}
+
+	f(`up`, "unknown")
+	f(`sum(up)`, "")
+	f(`sum(up) by (job, instance) > 0`, "instance,job")
+	f(`sum(up) without (job)`, "unknown")
+	f(`topk(3, up)`, "unknown")
+	f(`histogram_quantile(0.9, sum(rate(foo_bucket[5m])) by (le, job))`, "job,le")
+	f(`label_replace(sum(up) by (job), "foo", "$1", "job", "(.+)")`, "unknown")
+	f(`sum(foo) by (job) / sum(bar) by (job)`, "job")
+	f(`sum(foo) by (job) / on(job) group_left(team) bar`, "job,team")
+	f(`sum(foo) by (job) or sum(bar) by (instance)`, "instance,job")
+	f(`sum(foo) by (job) or bar`, "unknown")
+	f(`vector(1)`, "")
+}
+
+func TestGetLabelFilters(t *testing.T) {
+	f := func(expr, resultExpected string) {
+		t.Helper()
+		result := getLabelFilters(expr)
+		if result != resultExpected {
+			t.Fatalf("unexpected label filters for %q; got %s; want %s", expr, result, resultExpected)
+		}
+	}
+
+	f(`sum(rate(requests_total[5m])) by (job)`, `{}`)
+	f(`rate(requests_total{env="prod"}[1m])`, `{env="prod"}`)
+	f(`foo{job="a",env=~"prod|dev"} / bar{instance!="x"}`, `{env=~"prod|dev",instance!="x",job="a"}`)
+	f(`{__name__="foo",job="a"}`, `{job="a"}`)
+}
@@ -0,0 +1,176 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+)
+
+// writers contains functions for writing problems in supported formats
+var writers = map[string]func(w io.Writer, problems []Problem) error{
+	"text":  writeText,
+	"json":  writeJSON,
+	"sarif": writeSARIF,
+}
+
+func writeText(w io.Writer, problems []Problem) error {
+	for _, p := range problems {
+		location := p.File
+		if p.Line > 0 {
+			location = fmt.Sprintf("%s:%d", p.File, p.Line)
+		}
+		var ctx string
+		if p.Group != "" {
+			ctx = fmt.Sprintf("group %q: ", p.Group)
+		}
+		if p.Rule != "" {
+			ctx = fmt.Sprintf("group %q, rule %q: ", p.Group, p.Rule)
+		}
+		if _, err := fmt.Fprintf(w, "%s: [%s] %s: %s%s\n", location, p.Severity, p.Check, ctx, p.Message); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d problem(s) found\n", len(problems))
+	return err
+}
+
+func writeJSON(w io.Writer, problems []Problem) error {
+	if problems == nil {
+		problems = []Problem{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(problems)
+}
+
+// SARIF types contain only the fields used by vmalert-tool.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+// sarifLevels maps severity to SARIF result level
+var sarifLevels = map[string]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "note",
+}
+
+func writeSARIF(w io.Writer, problems []Problem) error {
+	var checks []string
+	for check := range checkDescriptions {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	driver := sarifDriver{
+		Name:           "vmalert-tool",
+		Version:        buildinfo.Version,
+		InformationURI: "https://docs.victoriametrics.com/vmalert-tool/#linting-rules",
+	}
+	for _, check := range checks {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               check,
+			ShortDescription: sarifMessage{Text: checkDescriptions[check]},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(problems))
+	for _, p := range problems {
+		loc := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: p.File},
+			},
+		}
+		if p.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: p.Line}
+		}
+		if p.Group != "" {
+			ll := sarifLogicalLocation{
+				Name:               p.Group,
+				FullyQualifiedName: p.Group,
+			}
+			if p.Rule != "" {
+				ll.Name = p.Rule
+				ll.FullyQualifiedName = p.Group + "/" + p.Rule
+			}
+			loc.LogicalLocations = []sarifLogicalLocation{ll}
+		}
+		results = append(results, sarifResult{
+			RuleID:    p.Check,
+			Level:     sarifLevels[p.Severity],
+			Message:   sarifMessage{Text: p.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	})
+}
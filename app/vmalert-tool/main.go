@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert-tool/lint"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert-tool/unittest"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
 )
@@ -64,6 +66,59 @@ Examples:
 					return nil
 				},
 			},
+			{
+				Name:      "lint",
+				Usage:     "Check alerting and recording rules for common mistakes.",
+				UsageText: "More info in https://docs.victoriametrics.com/vmalert-tool.html#linting-rules",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name: "files",
+						Usage: `File path or http url with rule files. Supports an array of values separated by comma or specified via multiple flags.
+						Supports hierarchical patterns and regexpes.
+Examples:
+ -files="/path/to/file". Path to a single rule file.
+ -files="dir/**/*.yaml". Includes all the .yaml files in "dir" subfolders recursively.
+ `,
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "format",
+						Usage:    `Output format for found problems. Possible values: text, json, sarif.`,
+						Value:    "text",
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "evaluationInterval",
+						Usage:    `Default evaluation interval for groups without interval param. Must be equal to -evaluationInterval flag of vmalert.`,
+						Value:    time.Minute,
+						Required: false,
+					},
+					&cli.StringSliceFlag{
+						Name:     "external.label",
+						Usage:    `Optional label in the form 'name=value', which is added to all the alerts by vmalert. Supports an array of values separated by comma or specified via multiple flags.`,
+						Required: false,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var labels []string
+					for _, s := range c.StringSlice("external.label") {
+						if n := strings.IndexByte(s, '='); n > 0 {
+							s = s[:n]
+						}
+						if s != "" {
+							labels = append(labels, s)
+						}
+					}
+					failed, err := lint.Lint(c.StringSlice("files"), c.String("format"), c.Duration("evaluationInterval"), labels, os.Stdout)
+					if err != nil {
+						return err
+					}
+					if failed {
+						return fmt.Errorf("lint failed")
+					}
+					return nil
+				},
+			},
 		},
 	}
 
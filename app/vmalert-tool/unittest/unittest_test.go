@@ -35,4 +35,7 @@ func TestUnitTest_Success(t *testing.T) {
 	// disable group label
 	// template with null external values
 	f(true, []string{"./testdata/disable-group-label.yaml"}, nil, "")
+
+	// rules generated from slo
+	f(false, []string{"./testdata/slo.yaml"}, nil, "")
 }
@@ -50,6 +50,8 @@ type Group struct {
 	NotifierHeaders []Header `yaml:"notifier_headers,omitempty"`
 	// EvalAlignment will make the timestamp of group query requests be aligned with interval
 	EvalAlignment *bool `yaml:"eval_alignment,omitempty"`
+	// DependsOn contains names of groups, which must finish evaluation before this group is evaluated.
+	DependsOn []string `yaml:"depends_on,omitempty"`
 	// Catches all undefined fields and must be empty after parsing.
 	XXX map[string]any `yaml:",inline"`
 }
@@ -94,6 +96,19 @@ func (g *Group) Validate(validateTplFn ValidateTplFn, validateExpressions bool)
 	if g.Concurrency < 0 {
 		return fmt.Errorf("invalid concurrency %d, shouldn't be less than 0", g.Concurrency)
 	}
+	uniqueDeps := map[string]struct{}{}
+	for _, name := range g.DependsOn {
+		if name == "" {
+			return fmt.Errorf("depends_on can't contain empty group name")
+		}
+		if name == g.Name {
+			return fmt.Errorf("group can't depend on itself")
+		}
+		if _, ok := uniqueDeps[name]; ok {
+			return fmt.Errorf("%q is a duplicate in depends_on", name)
+		}
+		uniqueDeps[name] = struct{}{}
+	}
 
 	uniqueRules := map[uint64]struct{}{}
 	for _, r := range g.Rules {
@@ -261,6 +276,12 @@ func Parse(pathPatterns []string, validateTplFn ValidateTplFn, validateExpressio
 	return groups, nil
 }
 
+// ParseFiles parses rule configs from the given files,
+// where key is a file name and value is file's content.
+func ParseFiles(files map[string][]byte, validateTplFn ValidateTplFn, validateExpressions bool) ([]Group, error) {
+	return parse(files, validateTplFn, validateExpressions)
+}
+
 func parse(files map[string][]byte, validateTplFn ValidateTplFn, validateExpressions bool) ([]Group, error) {
 	errGroup := new(utils.ErrGroup)
 	var groups []Group
@@ -306,6 +327,8 @@ func parseConfig(data []byte) ([]Group, error) {
 	var result []Group
 	type cfgFile struct {
 		Groups []Group `yaml:"groups"`
+		// SLOs are converted into groups with multi-window multi-burn-rate rules.
+		SLOs []SLO `yaml:"slos"`
 		// Catches all undefined fields and must be empty after parsing.
 		XXX map[string]any `yaml:",inline"`
 	}
@@ -323,6 +346,13 @@ func parseConfig(data []byte) ([]Group, error) {
 			return nil, err
 		}
 		result = append(result, cf.Groups...)
+		for _, slo := range cf.SLOs {
+			g, err := slo.Group()
+			if err != nil {
+				return nil, fmt.Errorf("invalid slo %q: %w", slo.Name, err)
+			}
+			result = append(result, g)
+		}
 	}
 
 	return result, nil
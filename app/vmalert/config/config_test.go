@@ -126,6 +126,7 @@ func TestParse_Failure(t *testing.T) {
 	f([]string{"testdata/dir/rules6-bad.rules"}, "missing ':' in header")
 	f([]string{"testdata/rules/rules-multi-doc-bad.rules"}, "unknown fields")
 	f([]string{"testdata/rules/rules-multi-doc-duplicates-bad.rules"}, "duplicate")
+	f([]string{"testdata/rules/slo-bad.rules"}, "invalid slo")
 	f([]string{"http://unreachable-url"}, "failed to")
 }
 
@@ -173,6 +174,16 @@ func TestGroupValidate_Failure(t *testing.T) {
 		Limit: -1,
 	}, false, "invalid limit")
 
+	f(&Group{
+		Name:      "self dependency",
+		DependsOn: []string{"self dependency"},
+	}, false, "group can't depend on itself")
+
+	f(&Group{
+		Name:      "duplicated dependency",
+		DependsOn: []string{"foo", "foo"},
+	}, false, "duplicate in depends_on")
+
 	f(&Group{
 		Name:        "wrong concurrency",
 		Concurrency: -1,
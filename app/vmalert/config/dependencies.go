@@ -0,0 +1,168 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+var autoDetectDependencies = flag.Bool("rule.autoDetectDependencies", false, "Whether to detect dependencies between groups automatically. "+
+	"If enabled, the group depends on other groups containing recording rules, which results are used in the group's expressions. "+
+	"Only groups with `prometheus` type are checked. See https://docs.victoriametrics.com/vmalert/#group-dependencies")
+
+// ResolveDependencies returns indexes of upstream groups for every group in groups.
+//
+// Upstream groups are set explicitly via `depends_on` param and, if -rule.autoDetectDependencies is set,
+// are detected by metric names produced by recording rules.
+// An error is returned if `depends_on` refers to unknown group or if dependencies contain a cycle.
+func ResolveDependencies(groups []Group) ([][]int, error) {
+	return resolveDependencies(groups, *autoDetectDependencies)
+}
+
+func resolveDependencies(groups []Group, autoDetect bool) ([][]int, error) {
+	deps := make([][]int, len(groups))
+	for i, g := range groups {
+		upstreams := make(map[int]struct{})
+		for _, name := range g.DependsOn {
+			j, err := findGroup(groups, name, g.File)
+			if err != nil {
+				return nil, fmt.Errorf("invalid `depends_on` in group %q in file %q: %w", g.Name, g.File, err)
+			}
+			upstreams[j] = struct{}{}
+		}
+		if autoDetect {
+			for _, j := range detectDependencies(groups, i) {
+				upstreams[j] = struct{}{}
+			}
+		}
+		for j := range upstreams {
+			deps[i] = append(deps[i], j)
+		}
+		sort.Ints(deps[i])
+	}
+	if err := checkCycles(groups, deps); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// findGroup returns index of the group with the given name.
+// Groups from the given file have priority over groups with the same name from other files.
+func findGroup(groups []Group, name, file string) (int, error) {
+	var found []int
+	for i, g := range groups {
+		if g.Name != name {
+			continue
+		}
+		if g.File == file {
+			return i, nil
+		}
+		found = append(found, i)
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("group %q not found", name)
+	case 1:
+		return found[0], nil
+	default:
+		var files []string
+		for _, i := range found {
+			files = append(files, groups[i].File)
+		}
+		return 0, fmt.Errorf("group name %q is ambiguous, since it is defined in multiple files: %s", name, strings.Join(files, ", "))
+	}
+}
+
+// detectDependencies returns indexes of groups with recording rules,
+// which results are used in expressions of groups[idx].
+func detectDependencies(groups []Group, idx int) []int {
+	g := groups[idx]
+	if g.Type.String() != "prometheus" {
+		return nil
+	}
+	names := make(map[string]struct{})
+	for _, r := range g.Rules {
+		expr, err := metricsql.Parse(r.Expr)
+		if err != nil {
+			// invalid expressions are reported during rule evaluation
+			continue
+		}
+		metricsql.VisitAll(expr, func(e metricsql.Expr) {
+			me, ok := e.(*metricsql.MetricExpr)
+			if !ok {
+				return
+			}
+			for _, lfs := range me.LabelFilterss {
+				for _, lf := range lfs {
+					if lf.Label == "__name__" && !lf.IsRegexp && !lf.IsNegative {
+						names[lf.Value] = struct{}{}
+					}
+				}
+			}
+		})
+	}
+	var result []int
+	for i, ug := range groups {
+		if i == idx || ug.Type.String() != "prometheus" {
+			continue
+		}
+		for _, r := range ug.Rules {
+			if _, ok := names[r.Record]; ok && r.Record != "" {
+				result = append(result, i)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// checkCycles returns an error if deps contain a cycle.
+func checkCycles(groups []Group, deps [][]int) error {
+	const (
+		unvisited = iota
+		inProgress
+		visited
+	)
+	state := make([]int, len(groups))
+	var path []int
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case inProgress:
+			var names []string
+			start := 0
+			for n, j := range path {
+				if j == i {
+					start = n
+					break
+				}
+			}
+			for _, j := range path[start:] {
+				names = append(names, fmt.Sprintf("%q", groups[j].Name))
+			}
+			names = append(names, fmt.Sprintf("%q", groups[i].Name))
+			return fmt.Errorf("cyclic dependency between groups: %s", strings.Join(names, " -> "))
+		}
+		state[i] = inProgress
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range groups {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveDependencies_Success(t *testing.T) {
+	f := func(groups []Group, autoDetect bool, depsExpected [][]int) {
+		t.Helper()
+
+		deps, err := resolveDependencies(groups, autoDetect)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(deps, depsExpected) {
+			t.Fatalf("unexpected dependencies; got %v; want %v", deps, depsExpected)
+		}
+	}
+
+	recording := Group{
+		Name: "recording",
+		File: "a.yaml",
+		Rules: []Rule{
+			{Record: "job:requests:rate5m", Expr: "sum(rate(requests_total[5m])) by (job)"},
+		},
+	}
+	alerting := Group{
+		Name: "alerting",
+		File: "b.yaml",
+		Rules: []Rule{
+			{Alert: "HighRequestRate", Expr: "job:requests:rate5m > 100"},
+		},
+	}
+
+	// no dependencies
+	f([]Group{recording, alerting}, false, [][]int{nil, nil})
+
+	// explicit dependency
+	explicit := alerting
+	explicit.DependsOn = []string{"recording"}
+	f([]Group{recording, explicit}, false, [][]int{nil, {0}})
+
+	// auto-detected dependency
+	f([]Group{recording, alerting}, true, [][]int{nil, {0}})
+
+	// auto-detection ignores regexp filters and groups with other types
+	regexp := alerting
+	regexp.Rules = []Rule{{Alert: "HighRequestRate", Expr: `{__name__=~"job:requests:rate5m"} > 100`}}
+	graphite := alerting
+	graphite.Type = NewGraphiteType()
+	f([]Group{recording, regexp, graphite}, true, [][]int{nil, nil, nil})
+
+	// group from the same file has priority
+	sameName := recording
+	sameName.File = "b.yaml"
+	f([]Group{recording, sameName, explicit}, false, [][]int{nil, nil, {1}})
+
+	// dependencies chain
+	chained := Group{
+		Name:      "chained",
+		File:      "c.yaml",
+		DependsOn: []string{"alerting"},
+	}
+	f([]Group{chained, recording, explicit}, false, [][]int{{2}, nil, {1}})
+}
+
+func TestResolveDependencies_Failure(t *testing.T) {
+	f := func(groups []Group, autoDetect bool, errStrExpected string) {
+		t.Helper()
+
+		_, err := resolveDependencies(groups, autoDetect)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errStrExpected) {
+			t.Fatalf("missing %q in the returned error %q", errStrExpected, err)
+		}
+	}
+
+	// unknown group
+	f([]Group{
+		{Name: "foo", DependsOn: []string{"bar"}},
+	}, false, `group "bar" not found`)
+
+	// ambiguous group
+	f([]Group{
+		{Name: "foo", File: "a.yaml", DependsOn: []string{"bar"}},
+		{Name: "bar", File: "b.yaml"},
+		{Name: "bar", File: "c.yaml"},
+	}, false, `group name "bar" is ambiguous`)
+
+	// explicit cycle
+	f([]Group{
+		{Name: "foo", DependsOn: []string{"bar"}},
+		{Name: "bar", DependsOn: []string{"baz"}},
+		{Name: "baz", DependsOn: []string{"bar"}},
+	}, false, `cyclic dependency between groups: "bar" -> "baz" -> "bar"`)
+
+	// auto-detected cycle
+	f([]Group{
+		{Name: "foo", Rules: []Rule{{Record: "foo:sum", Expr: "sum(bar:sum)"}}},
+		{Name: "bar", Rules: []Rule{{Record: "bar:sum", Expr: "sum(foo:sum)"}}},
+	}, true, `cyclic dependency between groups: "foo" -> "bar" -> "foo"`)
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// SLO describes service level objective, for which multi-window multi-burn-rate
+// recording and alerting rules are generated.
+//
+// See https://sre.google/workbook/alerting-on-slos/
+type SLO struct {
+	// Name is used as the name of the generated group and as the value of `slo` label.
+	Name string `yaml:"name"`
+	// Objective is the target percentage of good events, e.g. 99.9.
+	Objective float64 `yaml:"objective"`
+	// ErrorQuery returns the rate of bad events over {{.window}}.
+	ErrorQuery string `yaml:"error_query"`
+	// TotalQuery returns the rate of all events over {{.window}}.
+	TotalQuery string              `yaml:"total_query"`
+	Interval   *promutils.Duration `yaml:"interval,omitempty"`
+	// Labels are added to every generated rule.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Alert  SLOAlert          `yaml:"alert,omitempty"`
+	// Windows contains windows for alerting rules. defaultSLOWindows are used if empty.
+	Windows []SLOWindow `yaml:"windows,omitempty"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]any `yaml:",inline"`
+}
+
+// SLOAlert contains params for the generated alerting rules.
+type SLOAlert struct {
+	// Name is the name of alerting rules. By default, `SLOErrorBudgetBurn` is used.
+	Name        string            `yaml:"name,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]any `yaml:",inline"`
+}
+
+// SLOWindow describes a pair of windows for alerting rule.
+// The alert fires if the error budget burn rate exceeds BurnRate over both Long and Short windows.
+type SLOWindow struct {
+	Long     *promutils.Duration `yaml:"long"`
+	Short    *promutils.Duration `yaml:"short"`
+	BurnRate float64             `yaml:"burn_rate"`
+	For      *promutils.Duration `yaml:"for,omitempty"`
+	Labels   map[string]string   `yaml:"labels,omitempty"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]any `yaml:",inline"`
+}
+
+const defaultSLOAlertName = "SLOErrorBudgetBurn"
+
+// defaultSLOWindows contains windows recommended for 30d SLO period.
+// See https://sre.google/workbook/alerting-on-slos/#6-multiwindow-multi-burn-rate-alerts
+var defaultSLOWindows = []SLOWindow{
+	{Long: promutils.NewDuration(time.Hour), Short: promutils.NewDuration(5 * time.Minute), BurnRate: 14.4, Labels: map[string]string{"severity": "critical"}},
+	{Long: promutils.NewDuration(6 * time.Hour), Short: promutils.NewDuration(30 * time.Minute), BurnRate: 6, Labels: map[string]string{"severity": "critical"}},
+	{Long: promutils.NewDuration(24 * time.Hour), Short: promutils.NewDuration(2 * time.Hour), BurnRate: 3, Labels: map[string]string{"severity": "warning"}},
+	{Long: promutils.NewDuration(3 * 24 * time.Hour), Short: promutils.NewDuration(6 * time.Hour), BurnRate: 1, Labels: map[string]string{"severity": "warning"}},
+}
+
+// Validate checks configuration errors for SLO
+func (s *SLO) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("slo name must be set")
+	}
+	if s.Objective <= 0 || s.Objective >= 100 {
+		return fmt.Errorf("objective must be in range (0, 100); got %v", s.Objective)
+	}
+	if s.ErrorQuery == "" {
+		return fmt.Errorf("error_query can't be empty")
+	}
+	if s.TotalQuery == "" {
+		return fmt.Errorf("total_query can't be empty")
+	}
+	if s.Interval.Duration() < 0 {
+		return fmt.Errorf("interval shouldn't be lower than 0")
+	}
+	for i, w := range s.Windows {
+		if w.Short.Duration() <= 0 {
+			return fmt.Errorf("short window #%d must be positive", i)
+		}
+		if w.Long.Duration() <= w.Short.Duration() {
+			return fmt.Errorf("long window #%d must be bigger than short window; long: %v, short: %v", i, w.Long.Duration(), w.Short.Duration())
+		}
+		if w.BurnRate <= 0 {
+			return fmt.Errorf("burn_rate for window #%d must be positive; got %v", i, w.BurnRate)
+		}
+		if w.For.Duration() < 0 {
+			return fmt.Errorf("for for window #%d shouldn't be lower than 0", i)
+		}
+		if err := checkOverflow(w.XXX, fmt.Sprintf("window #%d", i)); err != nil {
+			return err
+		}
+	}
+	if err := checkOverflow(s.Alert.XXX, "alert"); err != nil {
+		return err
+	}
+	return checkOverflow(s.XXX, fmt.Sprintf("slo %q", s.Name))
+}
+
+// Group returns the group with recording and alerting rules for s.
+//
+// A recording rule `slo:sli_error:ratio_rate<window>` is generated for every distinct window,
+// and an alerting rule is generated for every pair of windows.
+func (s *SLO) Group() (Group, error) {
+	if err := s.Validate(); err != nil {
+		return Group{}, err
+	}
+	errorTpl, err := template.New("error_query").Parse(s.ErrorQuery)
+	if err != nil {
+		return Group{}, fmt.Errorf("cannot parse error_query: %w", err)
+	}
+	totalTpl, err := template.New("total_query").Parse(s.TotalQuery)
+	if err != nil {
+		return Group{}, fmt.Errorf("cannot parse total_query: %w", err)
+	}
+
+	windows := s.Windows
+	if len(windows) == 0 {
+		windows = defaultSLOWindows
+	}
+	var durations []time.Duration
+	seen := make(map[time.Duration]struct{})
+	for _, w := range windows {
+		for _, d := range []time.Duration{w.Short.Duration(), w.Long.Duration()} {
+			if _, ok := seen[d]; !ok {
+				seen[d] = struct{}{}
+				durations = append(durations, d)
+			}
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	g := Group{
+		Type:     NewPrometheusType(),
+		Name:     s.Name,
+		Interval: s.Interval,
+		Labels:   map[string]string{"slo": s.Name},
+	}
+	for k, v := range s.Labels {
+		g.Labels[k] = v
+	}
+	for _, d := range durations {
+		window := formatSLOWindow(d)
+		errorQuery, err := executeSLOQuery(errorTpl, window)
+		if err != nil {
+			return Group{}, err
+		}
+		totalQuery, err := executeSLOQuery(totalTpl, window)
+		if err != nil {
+			return Group{}, err
+		}
+		g.Rules = append(g.Rules, Rule{
+			Record: sloErrorRatioName(d),
+			Expr:   fmt.Sprintf("(%s)\n/\n(%s)", errorQuery, totalQuery),
+		})
+	}
+
+	alertName := s.Alert.Name
+	if alertName == "" {
+		alertName = defaultSLOAlertName
+	}
+	// use percents in expression in order to avoid float precision issues in the rule definition
+	objective := strconv.FormatFloat(s.Objective, 'f', -1, 64)
+	selector := fmt.Sprintf("{slo=%q}", s.Name)
+	for _, w := range windows {
+		threshold := fmt.Sprintf("(%s * (100 - %s) / 100)", strconv.FormatFloat(w.BurnRate, 'f', -1, 64), objective)
+		labels := map[string]string{
+			"long_window":  formatSLOWindow(w.Long.Duration()),
+			"short_window": formatSLOWindow(w.Short.Duration()),
+		}
+		for k, v := range w.Labels {
+			labels[k] = v
+		}
+		for k, v := range s.Alert.Labels {
+			labels[k] = v
+		}
+		g.Rules = append(g.Rules, Rule{
+			Alert: alertName,
+			Expr: fmt.Sprintf("%s%s > %s\nand\n%s%s > %s",
+				sloErrorRatioName(w.Long.Duration()), selector, threshold,
+				sloErrorRatioName(w.Short.Duration()), selector, threshold),
+			For:         w.For,
+			Labels:      labels,
+			Annotations: s.Alert.Annotations,
+		})
+	}
+	for i := range g.Rules {
+		g.Rules[i].ID = HashRule(g.Rules[i])
+	}
+
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return Group{}, fmt.Errorf("failed to marshal slo configuration for checksum: %w", err)
+	}
+	h := md5.New()
+	h.Write(b)
+	g.Checksum = fmt.Sprintf("%x", h.Sum(nil))
+	return g, nil
+}
+
+func sloErrorRatioName(d time.Duration) string {
+	return "slo:sli_error:ratio_rate" + formatSLOWindow(d)
+}
+
+func executeSLOQuery(t *template.Template, window string) (string, error) {
+	var bb bytes.Buffer
+	if err := t.Execute(&bb, map[string]string{"window": window}); err != nil {
+		return "", fmt.Errorf("cannot execute %s: %w", t.Name(), err)
+	}
+	return bb.String(), nil
+}
+
+// formatSLOWindow returns d in the biggest unit supported by MetricsQL, which represents d without loss of precision.
+func formatSLOWindow(d time.Duration) string {
+	units := []struct {
+		d      time.Duration
+		suffix string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d%s", d/u.d, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
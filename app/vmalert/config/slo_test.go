@@ -0,0 +1,128 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSLOGroup_Success(t *testing.T) {
+	data := `
+name: api
+objective: 99.5
+labels:
+  team: api
+error_query: sum(rate(errors_total[{{.window}}]))
+total_query: sum(rate(requests_total[{{ .window }}]))
+alert:
+  annotations:
+    summary: foo
+windows:
+  - long: 1h
+    short: 5m
+    burn_rate: 14.4
+    for: 2m
+    labels:
+      severity: critical
+  - long: 1d
+    short: 1h
+    burn_rate: 2
+`
+	var slo SLO
+	if err := yaml.Unmarshal([]byte(data), &slo); err != nil {
+		t.Fatalf("cannot parse slo: %s", err)
+	}
+	g, err := slo.Group()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := g.Validate(nil, true); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	if g.Name != "api" || g.Checksum == "" {
+		t.Fatalf("unexpected group name %q or empty checksum", g.Name)
+	}
+	if g.Labels["slo"] != "api" || g.Labels["team"] != "api" {
+		t.Fatalf("unexpected group labels: %v", g.Labels)
+	}
+
+	recordsExpected := map[string]string{
+		"slo:sli_error:ratio_rate5m": "(sum(rate(errors_total[5m])))\n/\n(sum(rate(requests_total[5m])))",
+		"slo:sli_error:ratio_rate1h": "(sum(rate(errors_total[1h])))\n/\n(sum(rate(requests_total[1h])))",
+		"slo:sli_error:ratio_rate1d": "(sum(rate(errors_total[1d])))\n/\n(sum(rate(requests_total[1d])))",
+	}
+	alertsExpected := []string{
+		"slo:sli_error:ratio_rate1h{slo=\"api\"} > (14.4 * (100 - 99.5) / 100)\nand\nslo:sli_error:ratio_rate5m{slo=\"api\"} > (14.4 * (100 - 99.5) / 100)",
+		"slo:sli_error:ratio_rate1d{slo=\"api\"} > (2 * (100 - 99.5) / 100)\nand\nslo:sli_error:ratio_rate1h{slo=\"api\"} > (2 * (100 - 99.5) / 100)",
+	}
+	var alerts []Rule
+	for _, r := range g.Rules {
+		if r.Record != "" {
+			if r.Expr != recordsExpected[r.Record] {
+				t.Fatalf("unexpected expr for %q; got\n%s\nwant\n%s", r.Record, r.Expr, recordsExpected[r.Record])
+			}
+			delete(recordsExpected, r.Record)
+			continue
+		}
+		alerts = append(alerts, r)
+	}
+	if len(recordsExpected) > 0 {
+		t.Fatalf("missing recording rules: %v", recordsExpected)
+	}
+	if len(alerts) != len(alertsExpected) {
+		t.Fatalf("unexpected number of alerting rules; got %d; want %d", len(alerts), len(alertsExpected))
+	}
+	for i, r := range alerts {
+		if r.Alert != defaultSLOAlertName {
+			t.Fatalf("unexpected alert name %q", r.Alert)
+		}
+		if r.Expr != alertsExpected[i] {
+			t.Fatalf("unexpected expr for alert #%d; got\n%s\nwant\n%s", i, r.Expr, alertsExpected[i])
+		}
+		if r.Annotations["summary"] != "foo" {
+			t.Fatalf("unexpected annotations for alert #%d: %v", i, r.Annotations)
+		}
+	}
+	if alerts[0].For.Duration() == 0 || alerts[0].Labels["severity"] != "critical" || alerts[0].Labels["long_window"] != "1h" || alerts[0].Labels["short_window"] != "5m" {
+		t.Fatalf("unexpected params for alert #0: %+v", alerts[0])
+	}
+
+	// default windows must be used if windows aren't set
+	slo.Windows = nil
+	g, err = slo.Group()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// 7 recording rules for 5m, 30m, 1h, 2h, 6h, 1d and 3d windows and 4 alerting rules
+	if len(g.Rules) != 11 {
+		t.Fatalf("unexpected number of rules for default windows; got %d; want 11", len(g.Rules))
+	}
+}
+
+func TestSLOGroup_Failure(t *testing.T) {
+	f := func(data, errStrExpected string) {
+		t.Helper()
+
+		var slo SLO
+		if err := yaml.Unmarshal([]byte(data), &slo); err != nil {
+			t.Fatalf("cannot parse slo: %s", err)
+		}
+		_, err := slo.Group()
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errStrExpected) {
+			t.Fatalf("expected err to contain %q; got %q instead", errStrExpected, err)
+		}
+	}
+
+	f(`{objective: 99, error_query: foo, total_query: bar}`, "name must be set")
+	f(`{name: foo, objective: 100, error_query: foo, total_query: bar}`, "objective must be in range")
+	f(`{name: foo, objective: 99, total_query: bar}`, "error_query can't be empty")
+	f(`{name: foo, objective: 99, error_query: foo}`, "total_query can't be empty")
+	f(`{name: foo, objective: 99, error_query: foo, total_query: bar, windows: [{long: 5m, short: 1h, burn_rate: 1}]}`, "must be bigger than short window")
+	f(`{name: foo, objective: 99, error_query: foo, total_query: bar, windows: [{long: 1h, short: 5m}]}`, "burn_rate")
+	f(`{name: foo, objective: 99, error_query: "foo[{{.window}]", total_query: bar}`, "cannot parse error_query")
+	f(`{name: foo, objective: 99, error_query: foo, total_query: bar, unknown: baz}`, "unknown fields")
+}
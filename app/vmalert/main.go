@@ -117,6 +117,9 @@ func main() {
 		if len(groups) == 0 {
 			logger.Fatalf("No rules for validation. Please specify path to file(s) with alerting and/or recording rules using `-rule` flag")
 		}
+		if _, err := config.ResolveDependencies(groups); err != nil {
+			logger.Fatalf("failed to resolve group dependencies in %q: %s", *rulePath, err)
+		}
 		return
 	}
 
@@ -155,6 +158,12 @@ func main() {
 		return
 	}
 
+	if err := rule.InitStateFile(); err != nil {
+		logger.Fatalf("failed to init alerts state: %s", err)
+	}
+	if err := notifier.InitSilences(); err != nil {
+		logger.Fatalf("failed to init silences: %s", err)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	manager, err := newManager(ctx)
 	if err != nil {
@@ -165,6 +174,18 @@ func main() {
 	if err != nil {
 		logger.Fatalf("cannot parse configuration file: %s", err)
 	}
+	rs, err := newRulerStore(*rulerAPIStorePath, validateTplFn)
+	if err != nil {
+		logger.Fatalf("failed to init ruler API: %s", err)
+	}
+	if err := rs.checkRulePaths(*rulePath); err != nil {
+		logger.Fatalf("failed to init ruler API: %s", err)
+	}
+	storeGroupsCfg, err := rs.groups()
+	if err != nil {
+		logger.Fatalf("cannot load rule groups managed via ruler API: %s", err)
+	}
+	groupsCfg = append(groupsCfg, storeGroupsCfg...)
 
 	// Register SIGHUP handler for config re-read just before manager.start call.
 	// This guarantees that the config will be re-read if the signal arrives during manager.start call.
@@ -175,13 +196,13 @@ func main() {
 		logger.Fatalf("failed to start: %s", err)
 	}
 
-	go configReload(ctx, manager, groupsCfg, sighupCh)
+	go configReload(ctx, manager, groupsCfg, sighupCh, rs)
 
 	listenAddrs := *httpListenAddrs
 	if len(listenAddrs) == 0 {
 		listenAddrs = []string{":8880"}
 	}
-	rh := &requestHandler{m: manager}
+	rh := &requestHandler{m: manager, rs: rs}
 	go httpserver.Serve(listenAddrs, useProxyProtocol, rh.handler)
 
 	pushmetrics.Init()
@@ -192,6 +213,8 @@ func main() {
 	if err := httpserver.Stop(listenAddrs); err != nil {
 		logger.Fatalf("cannot stop the webservice: %s", err)
 	}
+	// write the state of active alerts before stopping groups
+	rule.StopStateFile()
 	cancel()
 	manager.close()
 }
@@ -323,7 +346,7 @@ See the docs at https://docs.victoriametrics.com/vmalert/ .
 	flagutil.Usage(s)
 }
 
-func configReload(ctx context.Context, m *manager, groupsCfg []config.Group, sighupCh <-chan os.Signal) {
+func configReload(ctx context.Context, m *manager, groupsCfg []config.Group, sighupCh <-chan os.Signal, rs *rulerStore) {
 	var configCheckCh <-chan time.Time
 	checkInterval := *configCheckInterval
 	if checkInterval > 0 {
@@ -342,6 +365,7 @@ func configReload(ctx context.Context, m *manager, groupsCfg []config.Group, sig
 
 	parseFn := config.Parse
 	for {
+		storeUpdated := false
 		select {
 		case <-ctx.Done():
 			return
@@ -357,24 +381,43 @@ func configReload(ctx context.Context, m *manager, groupsCfg []config.Group, sig
 		case <-configCheckCh:
 			// disable logs emitting during per-interval config reload
 			parseFn = config.ParseSilent
+		case <-rs.updates():
+			storeUpdated = true
 		}
-		if err := notifier.Reload(); err != nil {
-			setConfigError(err)
-			logger.Errorf("failed to reload notifier config: %s", err)
-			continue
-		}
-		err := templates.Load(*ruleTemplatesPath, *extURL)
-		if err != nil {
-			setConfigError(err)
-			logger.Errorf("failed to load new templates: %s", err)
-			continue
+		var newGroupsCfg []config.Group
+		if storeUpdated {
+			// keep file-based groups as is, since only groups managed via ruler API have been changed
+			for _, g := range groupsCfg {
+				if !rs.isStoreFile(g.File) {
+					newGroupsCfg = append(newGroupsCfg, g)
+				}
+			}
+		} else {
+			if err := notifier.Reload(); err != nil {
+				setConfigError(err)
+				logger.Errorf("failed to reload notifier config: %s", err)
+				continue
+			}
+			err := templates.Load(*ruleTemplatesPath, *extURL)
+			if err != nil {
+				setConfigError(err)
+				logger.Errorf("failed to load new templates: %s", err)
+				continue
+			}
+			newGroupsCfg, err = parseFn(*rulePath, validateTplFn, *validateExpressions)
+			if err != nil {
+				setConfigError(err)
+				logger.Errorf("cannot parse configuration file: %s", err)
+				continue
+			}
 		}
-		newGroupsCfg, err := parseFn(*rulePath, validateTplFn, *validateExpressions)
+		storeGroupsCfg, err := rs.groups()
 		if err != nil {
 			setConfigError(err)
-			logger.Errorf("cannot parse configuration file: %s", err)
+			logger.Errorf("cannot load rule groups managed via ruler API: %s", err)
 			continue
 		}
+		newGroupsCfg = append(newGroupsCfg, storeGroupsCfg...)
 		if configsEqual(newGroupsCfg, groupsCfg) {
 			templates.Reload()
 			// set success to 1 since previous reload could have been unsuccessful
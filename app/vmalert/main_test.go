@@ -126,7 +126,7 @@ groups:
 	syncCh := make(chan struct{})
 	sighupCh := procutil.NewSighupChan()
 	go func() {
-		configReload(ctx, m, nil, sighupCh)
+		configReload(ctx, m, nil, sighupCh, nil)
 		close(syncCh)
 	}()
 
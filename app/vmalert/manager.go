@@ -40,7 +40,7 @@ func (m *manager) ruleAPI(gID, rID uint64) (apiRule, error) {
 	}
 	for _, rule := range g.Rules {
 		if rule.ID() == rID {
-			return ruleToAPI(rule), nil
+			return ruleToAPI(rule, notifier.NewInhibitor()), nil
 		}
 	}
 	return apiRule{}, fmt.Errorf("can't find rule with id %d in group %q", rID, g.Name)
@@ -55,12 +55,13 @@ func (m *manager) alertAPI(gID, aID uint64) (*apiAlert, error) {
 	if !ok {
 		return nil, fmt.Errorf("can't find group with id %d", gID)
 	}
+	inh := notifier.NewInhibitor()
 	for _, r := range g.Rules {
 		ar, ok := r.(*rule.AlertingRule)
 		if !ok {
 			continue
 		}
-		if apiAlert := alertToAPI(ar, aID); apiAlert != nil {
+		if apiAlert := alertToAPI(ar, aID, inh); apiAlert != nil {
 			return apiAlert, nil
 		}
 	}
@@ -82,6 +83,9 @@ func (m *manager) close() {
 }
 
 func (m *manager) startGroup(ctx context.Context, g *rule.Group, restore bool) error {
+	if restore {
+		g.RestoreState()
+	}
 	m.wg.Add(1)
 	id := g.ID()
 	go func() {
@@ -97,8 +101,14 @@ func (m *manager) startGroup(ctx context.Context, g *rule.Group, restore bool) e
 }
 
 func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore bool) error {
+	deps, err := config.ResolveDependencies(groupsCfg)
+	if err != nil {
+		return err
+	}
+
 	var rrPresent, arPresent bool
 	groupsRegistry := make(map[uint64]*rule.Group)
+	newGroups := make([]*rule.Group, 0, len(groupsCfg))
 	for _, cfg := range groupsCfg {
 		for _, r := range cfg.Rules {
 			if rrPresent && arPresent {
@@ -113,6 +123,7 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 		}
 		ng := rule.NewGroup(cfg, m.querierBuilder, *evaluationInterval, m.labels)
 		groupsRegistry[ng.ID()] = ng
+		newGroups = append(newGroups, ng)
 	}
 
 	if rrPresent && m.rw == nil {
@@ -144,6 +155,20 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 			toUpdate = append(toUpdate, updateItem{old: og, new: ng})
 		}
 	}
+	// set dependencies before starting new groups, so they are respected since the first evaluation.
+	// Updated groups keep running with the old group object, so dependencies must refer to it.
+	for i, ng := range newGroups {
+		if og, ok := m.groups[ng.ID()]; ok {
+			newGroups[i] = og
+		}
+	}
+	for i, g := range newGroups {
+		var upstreams []*rule.Group
+		for _, j := range deps[i] {
+			upstreams = append(upstreams, newGroups[j])
+		}
+		g.SetDependencies(upstreams)
+	}
 	for _, ng := range groupsRegistry {
 		if err := m.startGroup(ctx, ng, restore); err != nil {
 			m.groupsMu.Unlock()
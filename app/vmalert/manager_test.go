@@ -320,6 +320,14 @@ func TestManagerUpdate_Failure(t *testing.T) {
 			{Alert: "alert", Expr: "up > 0"},
 		},
 	}, "contains alerting rules")
+
+	f(nil, &remotewrite.Client{}, config.Group{
+		Name:      "Unknown dependency",
+		DependsOn: []string{"foo"},
+		Rules: []config.Rule{
+			{Record: "record", Expr: "max(up)"},
+		},
+	}, `group "foo" not found`)
 }
 
 func loadCfg(t *testing.T, path []string, validateAnnotations, validateExpressions bool) []config.Group {
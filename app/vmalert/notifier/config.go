@@ -37,6 +37,14 @@ type Config struct {
 	// StaticConfigs contains list of static targets
 	StaticConfigs []StaticConfig `yaml:"static_configs,omitempty"`
 
+	// WebhookConfigs contains list of generic webhooks for sending alerts
+	// with the request body generated from Go templates
+	WebhookConfigs []WebhookConfig `yaml:"webhook_configs,omitempty"`
+
+	// InhibitRules contains list of rules for muting notifications
+	// for alerts while other alerts are firing
+	InhibitRules []InhibitRule `yaml:"inhibit_rules,omitempty"`
+
 	// HTTPClientConfig contains HTTP configuration for Notifier clients
 	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
 	// RelabelConfigs contains list of relabeling rules for entities discovered via SD
@@ -18,6 +18,7 @@ func TestParseConfig_Success(t *testing.T) {
 	f("testdata/consul.good.yaml")
 	f("testdata/dns.good.yaml")
 	f("testdata/static.good.yaml")
+	f("testdata/webhook.good.yaml")
 }
 
 func TestParseConfig_Failure(t *testing.T) {
@@ -35,4 +36,5 @@ func TestParseConfig_Failure(t *testing.T) {
 
 	f("testdata/unknownFields.bad.yaml", "unknown field")
 	f("non-existing-file", "error reading")
+	f("testdata/webhook.bad.yaml", "unsupported webhook method")
 }
@@ -160,6 +160,8 @@ func targetsFromLabels(labelsFn getLabels, cfg *Config, genFn AlertURLGenerator)
 type getLabels func() ([]*promutils.Labels, error)
 
 func (cw *configWatcher) start() error {
+	setInhibitRules(cw.cfg.InhibitRules)
+
 	if len(cw.cfg.StaticConfigs) > 0 {
 		var targets []Target
 		for _, cfg := range cw.cfg.StaticConfigs {
@@ -182,6 +184,25 @@ func (cw *configWatcher) start() error {
 		cw.setTargets(TargetStatic, targets)
 	}
 
+	if len(cw.cfg.WebhookConfigs) > 0 {
+		var targets []Target
+		for i := range cw.cfg.WebhookConfigs {
+			wc := cw.cfg.WebhookConfigs[i]
+			wc.HTTPClientConfig = mergeHTTPClientConfigs(cw.cfg.HTTPClientConfig, wc.HTTPClientConfig)
+			if wc.Timeout.Duration() == 0 {
+				wc.Timeout = cw.cfg.Timeout
+			}
+			notifier, err := NewWebhook(&wc, cw.genFn, cw.cfg.parsedAlertRelabelConfigs)
+			if err != nil {
+				return fmt.Errorf("failed to init webhook for url %q: %w", wc.URL, err)
+			}
+			targets = append(targets, Target{
+				Notifier: notifier,
+			})
+		}
+		cw.setTargets(TargetWebhook, targets)
+	}
+
 	if len(cw.cfg.ConsulSDConfigs) > 0 {
 		err := cw.add(TargetConsul, *consul.SDCheckInterval, func() ([]*promutils.Labels, error) {
 			var labels []*promutils.Labels
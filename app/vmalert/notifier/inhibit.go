@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// InhibitRule mutes notifications for alerts matching TargetMatchers
+// if there is a firing alert matching SourceMatchers with the same values for Equal labels.
+//
+// See https://prometheus.io/docs/alerting/latest/configuration/#inhibit_rule
+type InhibitRule struct {
+	SourceMatchers []string `yaml:"source_matchers"`
+	TargetMatchers []string `yaml:"target_matchers"`
+	Equal          []string `yaml:"equal,omitempty"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]any `yaml:",inline"`
+
+	sourceMatchers []*Matcher
+	targetMatchers []*Matcher
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (ir *InhibitRule) UnmarshalYAML(unmarshal func(any) error) error {
+	type inhibitRule InhibitRule
+	if err := unmarshal((*inhibitRule)(ir)); err != nil {
+		return err
+	}
+	if len(ir.XXX) > 0 {
+		return fmt.Errorf("unknown fields in inhibit rule: %v", ir.XXX)
+	}
+	if len(ir.SourceMatchers) == 0 {
+		return fmt.Errorf("inhibit rule must contain at least one `source_matchers` entry")
+	}
+	if len(ir.TargetMatchers) == 0 {
+		return fmt.Errorf("inhibit rule must contain at least one `target_matchers` entry")
+	}
+	var err error
+	if ir.sourceMatchers, err = parseMatchers(ir.SourceMatchers); err != nil {
+		return fmt.Errorf("invalid `source_matchers`: %w", err)
+	}
+	if ir.targetMatchers, err = parseMatchers(ir.TargetMatchers); err != nil {
+		return fmt.Errorf("invalid `target_matchers`: %w", err)
+	}
+	return nil
+}
+
+func parseMatchers(ss []string) ([]*Matcher, error) {
+	matchers := make([]*Matcher, 0, len(ss))
+	for _, s := range ss {
+		m, err := parseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// inhibitRules contains rules from -notifier.config
+var inhibitRules atomic.Pointer[[]InhibitRule]
+
+func setInhibitRules(rules []InhibitRule) {
+	inhibitRules.Store(&rules)
+}
+
+func getInhibitRules() []InhibitRule {
+	p := inhibitRules.Load()
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+type alertKey struct {
+	groupID uint64
+	id      uint64
+}
+
+type firingAlert struct {
+	labels map[string]string
+	end    time.Time
+}
+
+// firingAlerts contains alerts, which can inhibit other alerts.
+//
+// It is updated with the alerts passed to Suppress, so it contains the same alerts
+// as the receiving Alertmanager would have.
+var firingAlerts = struct {
+	mu sync.Mutex
+	m  map[alertKey]firingAlert
+}{
+	m: make(map[alertKey]firingAlert),
+}
+
+func updateFiringAlerts(alerts []Alert, now time.Time) {
+	firingAlerts.mu.Lock()
+	defer firingAlerts.mu.Unlock()
+
+	for _, a := range alerts {
+		k := alertKey{
+			groupID: a.GroupID,
+			id:      a.ID,
+		}
+		if a.State == StateFiring {
+			firingAlerts.m[k] = firingAlert{
+				labels: a.Labels,
+				end:    a.End,
+			}
+		} else {
+			delete(firingAlerts.m, k)
+		}
+	}
+	for k, fa := range firingAlerts.m {
+		if !fa.end.IsZero() && fa.end.Before(now) {
+			delete(firingAlerts.m, k)
+		}
+	}
+}
+
+// Inhibitor checks whether alerts are inhibited by firing alerts according to inhibit_rules from -notifier.config.
+//
+// Inhibitor indexes firing alerts at creation time, so it must be re-created
+// for checking alerts against the up-to-date firing alerts.
+type Inhibitor struct {
+	rules []inhibitIndex
+}
+
+type inhibitIndex struct {
+	targetMatchers []*Matcher
+	equal          []string
+
+	// sources contains firing alerts matching source_matchers of the rule
+	// grouped by the values of equal labels.
+	sources map[string][]alertKey
+}
+
+// NewInhibitor returns Inhibitor for the current firing alerts.
+func NewInhibitor() *Inhibitor {
+	return newInhibitor(getInhibitRules(), time.Now())
+}
+
+func newInhibitor(rules []InhibitRule, now time.Time) *Inhibitor {
+	inh := &Inhibitor{}
+	if len(rules) == 0 {
+		return inh
+	}
+
+	firingAlerts.mu.Lock()
+	defer firingAlerts.mu.Unlock()
+
+	var buf []byte
+	for i := range rules {
+		ir := &rules[i]
+		idx := inhibitIndex{
+			targetMatchers: ir.targetMatchers,
+			equal:          ir.Equal,
+			sources:        make(map[string][]alertKey),
+		}
+		for k, fa := range firingAlerts.m {
+			if !fa.end.IsZero() && fa.end.Before(now) {
+				continue
+			}
+			if !matchesAll(ir.sourceMatchers, fa.labels) {
+				continue
+			}
+			buf = marshalEqualLabels(buf[:0], ir.Equal, fa.labels)
+			idx.sources[string(buf)] = append(idx.sources[string(buf)], k)
+		}
+		inh.rules = append(inh.rules, idx)
+	}
+	return inh
+}
+
+// IsInhibited returns true if a is inhibited by firing alerts other than a.
+func (inh *Inhibitor) IsInhibited(a *Alert) bool {
+	self := alertKey{
+		groupID: a.GroupID,
+		id:      a.ID,
+	}
+	var buf []byte
+	for _, idx := range inh.rules {
+		if !matchesAll(idx.targetMatchers, a.Labels) {
+			continue
+		}
+		buf = marshalEqualLabels(buf[:0], idx.equal, a.Labels)
+		for _, k := range idx.sources[string(buf)] {
+			if k != self {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// marshalEqualLabels appends values for the given label names to dst.
+//
+// Missing labels are treated as labels with empty values in the same way as Alertmanager does.
+func marshalEqualLabels(dst []byte, names []string, labels map[string]string) []byte {
+	for _, name := range names {
+		v := labels[name]
+		dst = encoding.MarshalVarUint64(dst, uint64(len(v)))
+		dst = append(dst, v...)
+	}
+	return dst
+}
+
+// Suppress returns alerts, which aren't silenced via /api/v1/silences and aren't inhibited by inhibit_rules.
+//
+// Suppress must be called for alerts before sending them to notifiers.
+func Suppress(alerts []Alert) []Alert {
+	now := time.Now()
+	// silenced alerts still inhibit other alerts in the same way as in Alertmanager
+	updateFiringAlerts(alerts, now)
+
+	inh := newInhibitor(getInhibitRules(), now)
+	result := alerts[:0:0]
+	for i := range alerts {
+		a := &alerts[i]
+		if len(SilencedBy(a.Labels)) > 0 {
+			alertsSilenced.Inc()
+			continue
+		}
+		if inh.IsInhibited(a) {
+			alertsInhibited.Inc()
+			continue
+		}
+		result = append(result, *a)
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestInhibitRule_Failure(t *testing.T) {
+	f := func(data, errExpected string) {
+		t.Helper()
+		var ir InhibitRule
+		err := yaml.Unmarshal([]byte(data), &ir)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("expected error to contain %q; got %q", errExpected, err)
+		}
+	}
+
+	f(`target_matchers: ['severity="warning"']`, "source_matchers")
+	f(`source_matchers: ['severity="critical"']`, "target_matchers")
+	f(`{source_matchers: ['severity'], target_matchers: ['severity="warning"']}`, "missing operator")
+	f(`{source_matchers: ['severity="critical"'], target_matchers: ['severity="warning"'], foo: bar}`, "unknown fields")
+}
+
+func TestSuppress(t *testing.T) {
+	originalSilences := silences
+	defer func() {
+		silences = originalSilences
+		setInhibitRules(nil)
+		firingAlerts.m = make(map[alertKey]firingAlert)
+	}()
+	silences = &silenceStore{
+		silences: make(map[string]*Silence),
+	}
+
+	var rules []InhibitRule
+	if err := yaml.Unmarshal([]byte(`
+- source_matchers: ['severity="critical"']
+  target_matchers: ['severity="warning"']
+  equal: [instance]
+`), &rules); err != nil {
+		t.Fatalf("cannot parse inhibit rules: %s", err)
+	}
+	setInhibitRules(rules)
+
+	end := time.Now().Add(time.Minute)
+	critical := Alert{GroupID: 1, ID: 1, State: StateFiring, End: end, Labels: map[string]string{"alertname": "HostDown", "severity": "critical", "instance": "foo"}}
+	warningFoo := Alert{GroupID: 1, ID: 2, State: StateFiring, End: end, Labels: map[string]string{"alertname": "HighLatency", "severity": "warning", "instance": "foo"}}
+	warningBar := Alert{GroupID: 2, ID: 3, State: StateFiring, End: end, Labels: map[string]string{"alertname": "HighLatency", "severity": "warning", "instance": "bar"}}
+
+	f := func(alerts []Alert, idsExpected ...uint64) {
+		t.Helper()
+		result := Suppress(alerts)
+		if len(result) != len(idsExpected) {
+			t.Fatalf("unexpected number of alerts; got %d; want %d", len(result), len(idsExpected))
+		}
+		for i, a := range result {
+			if a.ID != idsExpected[i] {
+				t.Fatalf("unexpected alert #%d; got ID %d; want %d", i, a.ID, idsExpected[i])
+			}
+		}
+	}
+
+	// warning alerts aren't inhibited without firing critical alert
+	f([]Alert{warningFoo, warningBar}, 2, 3)
+
+	// warning alert for the same instance is inhibited by critical alert
+	f([]Alert{critical}, 1)
+	f([]Alert{warningFoo, warningBar}, 3)
+	inh := NewInhibitor()
+	if !inh.IsInhibited(&warningFoo) || inh.IsInhibited(&warningBar) || inh.IsInhibited(&critical) {
+		t.Fatalf("unexpected inhibition state")
+	}
+
+	// silenced alerts aren't sent, but still inhibit other alerts
+	silences.silences["foo"] = &Silence{
+		ID:       "foo",
+		Matchers: []*Matcher{{Name: "alertname", Value: "HostDown", IsEqual: true}},
+		StartsAt: time.Now().Add(-time.Minute),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+	f([]Alert{critical, warningFoo, warningBar}, 3)
+
+	// resolved critical alert doesn't inhibit alerts anymore
+	resolved := critical
+	resolved.State = StateInactive
+	f([]Alert{resolved})
+	f([]Alert{warningFoo, warningBar}, 2, 3)
+}
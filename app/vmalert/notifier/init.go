@@ -201,6 +201,8 @@ const (
 	TargetConsul TargetType = "consulSD"
 	// TargetDNS is for targets discovered via DNS
 	TargetDNS TargetType = "DNSSD"
+	// TargetWebhook is for generic webhooks configured via webhook_configs
+	TargetWebhook TargetType = "webhook"
 )
 
 // GetTargets returns list of static or discovered targets
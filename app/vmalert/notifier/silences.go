@@ -0,0 +1,352 @@
+package notifier
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var silencesPath = flag.String("notifier.silencesPath", "", "Optional path to the file for persisting silences created via /api/v1/silences. "+
+	"Silences are kept only in memory if the flag isn't set. See https://docs.victoriametrics.com/vmalert/#silences-and-inhibition")
+
+// expiredSilencesRetention is the duration for keeping expired silences.
+const expiredSilencesRetention = 5 * 24 * time.Hour
+
+var (
+	alertsSilenced  = utils.GetOrCreateCounter(`vmalert_alerts_silenced_total`)
+	alertsInhibited = utils.GetOrCreateCounter(`vmalert_alerts_inhibited_total`)
+)
+
+// Matcher matches label value of the alert.
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	// IsEqual is false for negative matchers. It is true by default.
+	IsEqual bool `json:"isEqual"`
+
+	re *regexp.Regexp
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (m *Matcher) UnmarshalJSON(data []byte) error {
+	type matcher Matcher
+	mm := matcher{
+		IsEqual: true,
+	}
+	if err := json.Unmarshal(data, &mm); err != nil {
+		return err
+	}
+	*m = Matcher(mm)
+	return m.init()
+}
+
+func (m *Matcher) init() error {
+	if m.Name == "" {
+		return fmt.Errorf("matcher name can't be empty")
+	}
+	if !m.IsRegex {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return fmt.Errorf("cannot parse regexp %q for matcher %q: %w", m.Value, m.Name, err)
+	}
+	m.re = re
+	return nil
+}
+
+// parseMatcher parses matcher in Alertmanager format, e.g. `severity="critical"` or `instance=~"foo.+"`.
+func parseMatcher(s string) (*Matcher, error) {
+	s = strings.TrimSpace(s)
+	n := strings.IndexAny(s, "=!")
+	if n <= 0 {
+		return nil, fmt.Errorf("missing operator in matcher %q", s)
+	}
+	m := &Matcher{
+		Name:    strings.TrimSpace(s[:n]),
+		IsEqual: s[n] == '=',
+	}
+	tail := s[n+1:]
+	if s[n] == '!' {
+		if !strings.HasPrefix(tail, "=") && !strings.HasPrefix(tail, "~") {
+			return nil, fmt.Errorf("unsupported operator in matcher %q", s)
+		}
+		m.IsRegex = tail[0] == '~'
+		tail = tail[1:]
+	} else if strings.HasPrefix(tail, "~") {
+		m.IsRegex = true
+		tail = tail[1:]
+	}
+	m.Value = strings.TrimSpace(tail)
+	if strings.HasPrefix(m.Value, `"`) {
+		v, err := strconv.Unquote(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unquote value in matcher %q: %w", s, err)
+		}
+		m.Value = v
+	}
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Matcher) matches(labels map[string]string) bool {
+	v := labels[m.Name]
+	var ok bool
+	if m.IsRegex {
+		ok = m.re.MatchString(v)
+	} else {
+		ok = v == m.Value
+	}
+	return ok == m.IsEqual
+}
+
+func matchesAll(matchers []*Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Silence mutes notifications for alerts matching all the Matchers in [StartsAt, EndsAt) time range.
+type Silence struct {
+	ID        string     `json:"id"`
+	Matchers  []*Matcher `json:"matchers"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    time.Time  `json:"endsAt"`
+	CreatedBy string     `json:"createdBy"`
+	Comment   string     `json:"comment"`
+}
+
+// SilenceStatus is the Silence with its current state.
+type SilenceStatus struct {
+	Silence
+	// State is one of `pending`, `active` or `expired`.
+	State string `json:"state"`
+}
+
+func (s *Silence) isActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+func (s *Silence) state(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return "pending"
+	case now.Before(s.EndsAt):
+		return "active"
+	default:
+		return "expired"
+	}
+}
+
+// silenceStore holds silences and persists them to path if it is set.
+type silenceStore struct {
+	path string
+
+	mu       sync.RWMutex
+	silences map[string]*Silence
+}
+
+var silences = &silenceStore{
+	silences: make(map[string]*Silence),
+}
+
+// InitSilences loads silences from -notifier.silencesPath.
+//
+// InitSilences must be called before starting groups.
+func InitSilences() error {
+	if *silencesPath == "" {
+		return nil
+	}
+	s := &silenceStore{
+		path:     *silencesPath,
+		silences: make(map[string]*Silence),
+	}
+	if err := s.load(); err != nil {
+		return err
+	}
+	silences = s
+	return nil
+}
+
+func (ss *silenceStore) load() error {
+	data, err := os.ReadFile(ss.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read silences from -notifier.silencesPath=%q: %w", ss.path, err)
+	}
+	var list []*Silence
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("cannot parse silences from -notifier.silencesPath=%q: %w", ss.path, err)
+	}
+	for _, s := range list {
+		ss.silences[s.ID] = s
+	}
+	logger.Infof("loaded %d silences from -notifier.silencesPath=%q", len(list), ss.path)
+	return nil
+}
+
+// write persists silences to ss.path. ss.mu must be locked by the caller.
+func (ss *silenceStore) write() error {
+	if ss.path == "" {
+		return nil
+	}
+	list := make([]*Silence, 0, len(ss.silences))
+	for _, s := range ss.silences {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("cannot marshal silences: %w", err)
+	}
+	tmpPath := ss.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("cannot write silences to -notifier.silencesPath=%q: %w", ss.path, err)
+	}
+	if err := os.Rename(tmpPath, ss.path); err != nil {
+		return fmt.Errorf("cannot write silences to -notifier.silencesPath=%q: %w", ss.path, err)
+	}
+	if d, err := os.Open(filepath.Dir(ss.path)); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
+
+// gc deletes silences expired for longer than expiredSilencesRetention. ss.mu must be locked by the caller.
+func (ss *silenceStore) gc(now time.Time) {
+	for id, s := range ss.silences {
+		if now.Sub(s.EndsAt) > expiredSilencesRetention {
+			delete(ss.silences, id)
+		}
+	}
+}
+
+// AddSilence validates s and adds it to the list of silences.
+// It returns ID of the added silence.
+func AddSilence(s *Silence) (string, error) {
+	now := time.Now()
+	if len(s.Matchers) == 0 {
+		return "", fmt.Errorf("at least one matcher must be set")
+	}
+	for _, m := range s.Matchers {
+		if err := m.init(); err != nil {
+			return "", err
+		}
+	}
+	if s.StartsAt.IsZero() {
+		s.StartsAt = now
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return "", fmt.Errorf("endsAt must be after startsAt")
+	}
+	if !s.EndsAt.After(now) {
+		return "", fmt.Errorf("endsAt must be in the future")
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate silence ID: %w", err)
+	}
+	s.ID = hex.EncodeToString(b)
+
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	silences.gc(now)
+	silences.silences[s.ID] = s
+	if err := silences.write(); err != nil {
+		delete(silences.silences, s.ID)
+		return "", err
+	}
+	return s.ID, nil
+}
+
+// ExpireSilence expires the silence with the given id.
+//
+// ErrSilenceNotFound is returned if there is no silence with the given id.
+func ExpireSilence(id string) error {
+	now := time.Now()
+
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	s, ok := silences.silences[id]
+	if !ok {
+		return ErrSilenceNotFound
+	}
+	if !now.Before(s.EndsAt) {
+		return fmt.Errorf("silence %q is already expired", id)
+	}
+	// replace the silence instead of modifying it, since it may be read concurrently
+	expired := *s
+	if now.Before(expired.StartsAt) {
+		expired.StartsAt = now
+	}
+	expired.EndsAt = now
+	silences.silences[id] = &expired
+	if err := silences.write(); err != nil {
+		silences.silences[id] = s
+		return err
+	}
+	return nil
+}
+
+// ErrSilenceNotFound is returned when the requested silence doesn't exist.
+var ErrSilenceNotFound = fmt.Errorf("silence not found")
+
+// ListSilences returns all the silences. Silences ending later are returned first.
+func ListSilences() []SilenceStatus {
+	now := time.Now()
+	silences.mu.RLock()
+	defer silences.mu.RUnlock()
+
+	result := make([]SilenceStatus, 0, len(silences.silences))
+	for _, s := range silences.silences {
+		result = append(result, SilenceStatus{
+			Silence: *s,
+			State:   s.state(now),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].EndsAt.Equal(result[j].EndsAt) {
+			return result[i].EndsAt.After(result[j].EndsAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// SilencedBy returns IDs of active silences matching the given labels.
+func SilencedBy(labels map[string]string) []string {
+	now := time.Now()
+	silences.mu.RLock()
+	defer silences.mu.RUnlock()
+
+	var ids []string
+	for _, s := range silences.silences {
+		if s.isActive(now) && matchesAll(s.Matchers, labels) {
+			ids = append(ids, s.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMatcher_Success(t *testing.T) {
+	f := func(s string, labels map[string]string, matchExpected bool) {
+		t.Helper()
+		m, err := parseMatcher(s)
+		if err != nil {
+			t.Fatalf("cannot parse matcher %q: %s", s, err)
+		}
+		if ok := m.matches(labels); ok != matchExpected {
+			t.Fatalf("unexpected match result for %q and %v; got %v; want %v", s, labels, ok, matchExpected)
+		}
+	}
+
+	labels := map[string]string{
+		"alertname": "HostDown",
+		"severity":  "critical",
+	}
+	f(`severity="critical"`, labels, true)
+	f(`severity = critical`, labels, true)
+	f(`severity!="critical"`, labels, false)
+	f(`severity=~"crit.*"`, labels, true)
+	f(`severity=~"crit"`, labels, false)
+	f(`severity!~"warning|info"`, labels, true)
+	f(`instance=""`, labels, true)
+	f(`alertname="Host\"Down"`, labels, false)
+}
+
+func TestParseMatcher_Failure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseMatcher(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	f(`severity`)
+	f(`="critical"`)
+	f(`severity!"critical"`)
+	f(`severity=~"(crit"`)
+	f(`severity="critical`)
+}
+
+func TestSilences(t *testing.T) {
+	originalPath := *silencesPath
+	originalSilences := silences
+	defer func() {
+		*silencesPath = originalPath
+		silences = originalSilences
+	}()
+
+	*silencesPath = filepath.Join(t.TempDir(), "silences.json")
+	if err := InitSilences(); err != nil {
+		t.Fatalf("cannot init silences: %s", err)
+	}
+
+	var s Silence
+	data := `{"matchers":[{"name":"alertname","value":"HostDown"},{"name":"instance","value":"foo.+","isRegex":true}],` +
+		`"endsAt":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `","createdBy":"admin","comment":"maintenance"}`
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatalf("cannot parse silence: %s", err)
+	}
+	id, err := AddSilence(&s)
+	if err != nil {
+		t.Fatalf("cannot add silence: %s", err)
+	}
+
+	f := func(labels map[string]string, silencedExpected bool) {
+		t.Helper()
+		ids := SilencedBy(labels)
+		if silencedExpected && (len(ids) != 1 || ids[0] != id) {
+			t.Fatalf("expecting %v to be silenced by %q; got %v", labels, id, ids)
+		}
+		if !silencedExpected && len(ids) > 0 {
+			t.Fatalf("unexpected silences for %v: %v", labels, ids)
+		}
+	}
+	f(map[string]string{"alertname": "HostDown", "instance": "foo1"}, true)
+	f(map[string]string{"alertname": "HostDown", "instance": "bar1"}, false)
+	f(map[string]string{"alertname": "DiskFull", "instance": "foo1"}, false)
+
+	// silences must be restored from the file
+	if err := InitSilences(); err != nil {
+		t.Fatalf("cannot init silences: %s", err)
+	}
+	f(map[string]string{"alertname": "HostDown", "instance": "foo1"}, true)
+
+	if err := ExpireSilence(id); err != nil {
+		t.Fatalf("cannot expire silence: %s", err)
+	}
+	f(map[string]string{"alertname": "HostDown", "instance": "foo1"}, false)
+	if err := ExpireSilence(id); err == nil {
+		t.Fatalf("expecting error when expiring already expired silence")
+	}
+	if err := ExpireSilence("unknown"); err != ErrSilenceNotFound {
+		t.Fatalf("expecting ErrSilenceNotFound; got %v", err)
+	}
+
+	list := ListSilences()
+	if len(list) != 1 || list[0].ID != id || list[0].State != "expired" {
+		t.Fatalf("unexpected list of silences: %+v", list)
+	}
+
+	// invalid silences
+	for _, s := range []*Silence{
+		{EndsAt: time.Now().Add(time.Hour)},
+		{Matchers: []*Matcher{{Name: "foo", Value: "bar", IsEqual: true}}},
+		{Matchers: []*Matcher{{Name: "foo", Value: "bar", IsEqual: true}}, StartsAt: time.Now().Add(-2 * time.Hour), EndsAt: time.Now().Add(-time.Hour)},
+		{Matchers: []*Matcher{{Name: "foo", Value: "(bar", IsRegex: true}}, EndsAt: time.Now().Add(time.Hour)},
+	} {
+		if _, err := AddSilence(s); err == nil {
+			t.Fatalf("expecting non-nil error for silence %+v", s)
+		}
+	}
+}
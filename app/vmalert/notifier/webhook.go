@@ -0,0 +1,389 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	textTpl "text/template"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/templates"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// WebhookConfig contains settings for sending alerts to an arbitrary HTTP endpoint.
+// The request body is generated from Go templates.
+type WebhookConfig struct {
+	// URL is the address where alerts are sent
+	URL string `yaml:"url"`
+	// Method is the HTTP method used for sending alerts. POST is used by default
+	Method string `yaml:"method,omitempty"`
+
+	// BodyTemplate is the template for the request body with firing alerts
+	BodyTemplate string `yaml:"body_template"`
+	// ResolvedBodyTemplate is the template for the request body with resolved alerts.
+	// BodyTemplate is used for resolved alerts if it is empty
+	ResolvedBodyTemplate string `yaml:"resolved_body_template,omitempty"`
+
+	// MaxAlertsPerRequest is the maximum number of alerts sent in a single request.
+	// All the alerts are sent in a single request if it is zero
+	MaxAlertsPerRequest int `yaml:"max_alerts_per_request,omitempty"`
+
+	// MaxRetries is the maximum number of retries for failed requests
+	MaxRetries int `yaml:"max_retries"`
+	// RetryMinInterval is the interval before the first retry. It is doubled after every retry
+	RetryMinInterval *promutils.Duration `yaml:"retry_min_interval,omitempty"`
+	// RetryMaxInterval is the maximum interval between retries
+	RetryMaxInterval *promutils.Duration `yaml:"retry_max_interval,omitempty"`
+	// The timeout used for every request. Config.Timeout is used if it is empty
+	Timeout *promutils.Duration `yaml:"timeout,omitempty"`
+
+	// HTTPClientConfig contains HTTP configuration for the webhook
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+
+	// Catches all undefined fields and must be empty after parsing.
+	XXX map[string]any `yaml:",inline"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (wc *WebhookConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	type webhookConfig WebhookConfig
+	wc.MaxRetries = 3
+	if err := unmarshal((*webhookConfig)(wc)); err != nil {
+		return err
+	}
+	if len(wc.XXX) > 0 {
+		var keys []string
+		for k := range wc.XXX {
+			keys = append(keys, k)
+		}
+		return fmt.Errorf("unknown fields in webhook config: %s", strings.Join(keys, ", "))
+	}
+	if wc.URL == "" {
+		return fmt.Errorf("missing `url` in webhook config")
+	}
+	if _, err := url.Parse(wc.URL); err != nil {
+		return fmt.Errorf("cannot parse webhook url %q: %w", wc.URL, err)
+	}
+	if wc.Method == "" {
+		wc.Method = http.MethodPost
+	}
+	wc.Method = strings.ToUpper(wc.Method)
+	switch wc.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("unsupported webhook method %q; supported methods: POST, PUT, PATCH", wc.Method)
+	}
+	if wc.BodyTemplate == "" {
+		return fmt.Errorf("missing `body_template` in webhook config for url %q", wc.URL)
+	}
+	if wc.MaxAlertsPerRequest < 0 {
+		return fmt.Errorf("`max_alerts_per_request` cannot be negative; got %d", wc.MaxAlertsPerRequest)
+	}
+	if wc.MaxRetries < 0 {
+		return fmt.Errorf("`max_retries` cannot be negative; got %d", wc.MaxRetries)
+	}
+	if wc.RetryMinInterval.Duration() == 0 {
+		wc.RetryMinInterval = promutils.NewDuration(time.Second)
+	}
+	if wc.RetryMaxInterval.Duration() == 0 {
+		wc.RetryMaxInterval = promutils.NewDuration(10 * time.Second)
+	}
+	if wc.RetryMaxInterval.Duration() < wc.RetryMinInterval.Duration() {
+		return fmt.Errorf("`retry_max_interval` cannot be smaller than `retry_min_interval`")
+	}
+	return nil
+}
+
+// Webhook sends alerts to an arbitrary HTTP endpoint with the request body generated from Go templates.
+type Webhook struct {
+	addr    *url.URL
+	method  string
+	argFunc AlertURLGenerator
+	client  *http.Client
+	timeout time.Duration
+
+	bodyTemplate         string
+	resolvedBodyTemplate string
+
+	maxAlertsPerRequest int
+	maxRetries          int
+	retryMinInterval    time.Duration
+	retryMaxInterval    time.Duration
+
+	authCfg *promauth.Config
+	// stores already parsed RelabelConfigs object
+	relabelConfigs *promrelabel.ParsedConfigs
+
+	metrics *metrics
+	retries *utils.Counter
+}
+
+// NewWebhook is a constructor for Webhook
+func NewWebhook(wc *WebhookConfig, fn AlertURLGenerator, relabelCfg *promrelabel.ParsedConfigs) (*Webhook, error) {
+	for _, text := range []string{wc.BodyTemplate, wc.ResolvedBodyTemplate} {
+		if _, err := parseWebhookTemplate(text); err != nil {
+			return nil, fmt.Errorf("cannot parse body template for webhook url %q: %w", wc.URL, err)
+		}
+	}
+	client, aCfg, err := newHTTPClient(wc.URL, wc.HTTPClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := url.Parse(wc.URL)
+	if err != nil {
+		return nil, fmt.Errorf("provided incorrect webhook url: %w", err)
+	}
+	webhookURL := addr.Redacted()
+	if *showNotifierURL {
+		webhookURL = addr.String()
+	}
+	return &Webhook{
+		addr:                 addr,
+		method:               wc.Method,
+		argFunc:              fn,
+		client:               client,
+		timeout:              wc.Timeout.Duration(),
+		bodyTemplate:         wc.BodyTemplate,
+		resolvedBodyTemplate: wc.ResolvedBodyTemplate,
+		maxAlertsPerRequest:  wc.MaxAlertsPerRequest,
+		maxRetries:           wc.MaxRetries,
+		retryMinInterval:     wc.RetryMinInterval.Duration(),
+		retryMaxInterval:     wc.RetryMaxInterval.Duration(),
+		authCfg:              aCfg,
+		relabelConfigs:       relabelCfg,
+		metrics:              newMetrics(webhookURL),
+		retries:              utils.GetOrCreateCounter(fmt.Sprintf("vmalert_alerts_send_retries_total{addr=%q}", webhookURL)),
+	}, nil
+}
+
+// Close is a destructor method for Webhook
+func (wh *Webhook) Close() {
+	wh.metrics.alertsSent.Unregister()
+	wh.metrics.alertsSendErrors.Unregister()
+	wh.retries.Unregister()
+}
+
+// Addr returns address where alerts are sent.
+func (wh *Webhook) Addr() string {
+	if *showNotifierURL {
+		return wh.addr.String()
+	}
+	return wh.addr.Redacted()
+}
+
+// WebhookAlert is the alert passed to webhook body templates.
+type WebhookAlert struct {
+	// Name is the alert name
+	Name string
+	// Status is either "firing" or "resolved"
+	Status string
+	// Labels contains alert labels after applying alert_relabel_configs
+	Labels map[string]string
+	// Annotations contains alert annotations
+	Annotations map[string]string
+	// Value is the value returned from evaluating alerting rule expression
+	Value float64
+	// Expr is the alerting rule expression
+	Expr string
+	// ActiveAt is the moment when the alert has become active
+	ActiveAt time.Time
+	// StartsAt is the moment when the alert has become firing
+	StartsAt time.Time
+	// EndsAt is the moment when the alert has been resolved or is supposed to expire
+	EndsAt time.Time
+	// GeneratorURL is the link to the alert in vmalert UI
+	GeneratorURL string
+	// ID is the unique identifier of the alert
+	ID uint64
+	// GroupID is the ID of the alert's rules group
+	GroupID uint64
+}
+
+// WebhookTplData is the data passed to webhook body templates.
+type WebhookTplData struct {
+	// Status is either "firing" or "resolved"
+	Status string
+	// Alerts contains alerts with the given Status
+	Alerts         []WebhookAlert
+	ExternalLabels map[string]string
+	ExternalURL    string
+}
+
+const (
+	webhookStatusFiring   = "firing"
+	webhookStatusResolved = "resolved"
+)
+
+// Send sends firing and resolved alerts in separate requests
+//
+// Failed requests are retried until ctx deadline.
+func (wh *Webhook) Send(ctx context.Context, alerts []Alert, headers map[string]string) error {
+	var firing, resolved []WebhookAlert
+	for _, a := range alerts {
+		lbls := a.applyRelabelingIfNeeded(wh.relabelConfigs)
+		if len(lbls) == 0 {
+			continue
+		}
+		wa := WebhookAlert{
+			Name:         a.Name,
+			Status:       webhookStatusFiring,
+			Labels:       make(map[string]string, len(lbls)),
+			Annotations:  a.Annotations,
+			Value:        a.Value,
+			Expr:         a.Expr,
+			ActiveAt:     a.ActiveAt,
+			StartsAt:     a.Start,
+			EndsAt:       a.End,
+			GeneratorURL: wh.argFunc(a),
+			ID:           a.ID,
+			GroupID:      a.GroupID,
+		}
+		for _, l := range lbls {
+			wa.Labels[l.Name] = l.Value
+		}
+		if a.State == StateInactive {
+			wa.Status = webhookStatusResolved
+			resolved = append(resolved, wa)
+		} else {
+			firing = append(firing, wa)
+		}
+	}
+
+	// Count only alerts, which weren't dropped by relabeling.
+	wh.metrics.alertsSent.Add(len(firing) + len(resolved))
+
+	eg := new(utils.ErrGroup)
+	sendBatches := func(status string, was []WebhookAlert) {
+		batchSize := wh.maxAlertsPerRequest
+		if batchSize <= 0 {
+			batchSize = len(was)
+		}
+		for len(was) > 0 {
+			n := min(batchSize, len(was))
+			if err := wh.sendBatch(ctx, status, was[:n], headers); err != nil {
+				wh.metrics.alertsSendErrors.Add(n)
+				eg.Add(err)
+			}
+			was = was[n:]
+		}
+	}
+	sendBatches(webhookStatusFiring, firing)
+	sendBatches(webhookStatusResolved, resolved)
+	return eg.Err()
+}
+
+func (wh *Webhook) sendBatch(ctx context.Context, status string, was []WebhookAlert, headers map[string]string) error {
+	text := wh.bodyTemplate
+	if status == webhookStatusResolved && wh.resolvedBodyTemplate != "" {
+		text = wh.resolvedBodyTemplate
+	}
+	// The template is parsed on every call in order to pick up the changes in -rule.templates after the reload.
+	tmpl, err := parseWebhookTemplate(text)
+	if err != nil {
+		return fmt.Errorf("cannot parse body template: %w", err)
+	}
+	var bb bytes.Buffer
+	data := WebhookTplData{
+		Status:         status,
+		Alerts:         was,
+		ExternalLabels: externalLabels,
+		ExternalURL:    externalURL,
+	}
+	if err := tmpl.Execute(&bb, data); err != nil {
+		return fmt.Errorf("cannot execute body template: %w", err)
+	}
+	body := bb.Bytes()
+
+	retryInterval := wh.retryMinInterval
+	for retries := 0; ; retries++ {
+		err := wh.send(ctx, body, headers)
+		if err == nil {
+			return nil
+		}
+		var nre *nonRetriableError
+		if errors.As(err, &nre) || retries >= wh.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < retryInterval {
+			// There is no time left for the retry.
+			return err
+		}
+		wh.retries.Inc()
+		t := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		retryInterval = min(2*retryInterval, wh.retryMaxInterval)
+	}
+}
+
+// nonRetriableError is returned when the request mustn't be retried.
+type nonRetriableError struct {
+	err error
+}
+
+func (e *nonRetriableError) Error() string {
+	return e.err.Error()
+}
+
+func (wh *Webhook) send(ctx context.Context, body []byte, headers map[string]string) error {
+	if wh.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wh.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, wh.method, wh.addr.String(), bytes.NewReader(body))
+	if err != nil {
+		return &nonRetriableError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.authCfg != nil {
+		if err := wh.authCfg.SetHeaders(req, true); err != nil {
+			return err
+		}
+	}
+	// external headers have higher priority
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response from %q: %w", wh.Addr(), err)
+		}
+		err = fmt.Errorf("invalid SC %d from %q; response body: %s", resp.StatusCode, wh.Addr(), string(respBody))
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			// Client errors cannot be fixed by retrying the same request.
+			return &nonRetriableError{err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+func parseWebhookTemplate(text string) (*textTpl.Template, error) {
+	tmpl, err := templates.GetWithFuncs(nil)
+	if err != nil {
+		return nil, fmt.Errorf("error cloning template: %w", err)
+	}
+	return tmpl.Parse(text)
+}
@@ -0,0 +1,252 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func newTestWebhook(t *testing.T, data string) *Webhook {
+	t.Helper()
+	var wc WebhookConfig
+	if err := yaml.Unmarshal([]byte(data), &wc); err != nil {
+		t.Fatalf("cannot parse webhook config: %s", err)
+	}
+	wh, err := NewWebhook(&wc, func(a Alert) string {
+		return fmt.Sprintf("%d/%d", a.GroupID, a.ID)
+	}, nil)
+	if err != nil {
+		t.Fatalf("cannot create webhook: %s", err)
+	}
+	t.Cleanup(wh.Close)
+	return wh
+}
+
+type webhookRequest struct {
+	method string
+	header http.Header
+	body   string
+}
+
+type testWebhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []webhookRequest
+	// statusCodes contains response status codes for the subsequent requests.
+	// 200 is returned when it is empty.
+	statusCodes []int
+}
+
+func newTestWebhookServer(statusCodes ...int) *testWebhookServer {
+	ts := &testWebhookServer{
+		statusCodes: statusCodes,
+	}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts.mu.Lock()
+		ts.requests = append(ts.requests, webhookRequest{
+			method: r.Method,
+			header: r.Header,
+			body:   string(body),
+		})
+		statusCode := http.StatusOK
+		if len(ts.statusCodes) > 0 {
+			statusCode = ts.statusCodes[0]
+			ts.statusCodes = ts.statusCodes[1:]
+		}
+		ts.mu.Unlock()
+		w.WriteHeader(statusCode)
+	}))
+	return ts
+}
+
+func (ts *testWebhookServer) getRequests() []webhookRequest {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]webhookRequest{}, ts.requests...)
+}
+
+func TestWebhook_Send(t *testing.T) {
+	srv := newTestWebhookServer()
+	defer srv.Close()
+
+	wh := newTestWebhook(t, `
+url: `+srv.URL+`
+method: PUT
+headers:
+  - "X-Api-Key: foobar"
+max_alerts_per_request: 2
+body_template: '{{ .Status }}:{{ range .Alerts }} {{ .Name }}={{ .Labels.job }} {{ .GeneratorURL }}{{ end }}'
+resolved_body_template: 'resolved:{{ range .Alerts }} {{ .Name }}{{ end }}'
+`)
+	alerts := []Alert{
+		{Name: "alert1", ID: 1, GroupID: 1, State: StateFiring, Labels: map[string]string{"job": "foo"}},
+		{Name: "alert2", ID: 2, GroupID: 1, State: StateFiring, Labels: map[string]string{"job": "bar"}},
+		{Name: "alert3", ID: 3, GroupID: 1, State: StateInactive, Labels: map[string]string{"job": "baz"}},
+		{Name: "alert4", ID: 4, GroupID: 1, State: StateFiring, Labels: map[string]string{"job": "qux"}},
+	}
+	if err := wh.Send(context.Background(), alerts, map[string]string{"TenantID": "123"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	requests := srv.getRequests()
+	bodiesExpected := []string{
+		"firing: alert1=foo 1/1 alert2=bar 1/2",
+		"firing: alert4=qux 1/4",
+		"resolved: alert3",
+	}
+	if len(requests) != len(bodiesExpected) {
+		t.Fatalf("unexpected number of requests; got %d; want %d", len(requests), len(bodiesExpected))
+	}
+	for i, req := range requests {
+		if req.method != http.MethodPut {
+			t.Fatalf("unexpected method; got %q; want %q", req.method, http.MethodPut)
+		}
+		if v := req.header.Get("X-Api-Key"); v != "foobar" {
+			t.Fatalf("unexpected X-Api-Key header; got %q; want %q", v, "foobar")
+		}
+		if v := req.header.Get("TenantID"); v != "123" {
+			t.Fatalf("unexpected TenantID header; got %q; want %q", v, "123")
+		}
+		if req.body != bodiesExpected[i] {
+			t.Fatalf("unexpected body for request #%d; got %q; want %q", i, req.body, bodiesExpected[i])
+		}
+	}
+}
+
+func TestWebhook_SendRetries(t *testing.T) {
+	f := func(statusCodes []int, requestsExpected int, resultExpected bool) {
+		t.Helper()
+		srv := newTestWebhookServer(statusCodes...)
+		defer srv.Close()
+
+		wh := newTestWebhook(t, `
+url: `+srv.URL+`
+max_retries: 2
+retry_min_interval: 1ms
+retry_max_interval: 2ms
+body_template: '{{ len .Alerts }}'
+`)
+		err := wh.Send(context.Background(), []Alert{{Name: "alert", State: StateFiring, Labels: map[string]string{"alertname": "alert"}}}, nil)
+		if resultExpected && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !resultExpected && err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if n := len(srv.getRequests()); n != requestsExpected {
+			t.Fatalf("unexpected number of requests; got %d; want %d", n, requestsExpected)
+		}
+	}
+
+	// success on the first attempt
+	f(nil, 1, true)
+
+	// success after retries
+	f([]int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, true)
+
+	// retries are exhausted
+	f([]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3, false)
+
+	// client errors aren't retried
+	f([]int{http.StatusBadRequest}, 1, false)
+}
+
+func TestWebhook_SendRetriesDeadline(t *testing.T) {
+	srv := newTestWebhookServer(http.StatusInternalServerError, http.StatusInternalServerError)
+	defer srv.Close()
+
+	wh := newTestWebhook(t, `
+url: `+srv.URL+`
+max_retries: 1
+retry_min_interval: 1h
+retry_max_interval: 1h
+body_template: '{{ len .Alerts }}'
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	startTime := time.Now()
+	err := wh.Send(ctx, []Alert{{Name: "alert", State: StateFiring, Labels: map[string]string{"alertname": "alert"}}}, nil)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	// The retry mustn't be made, since it cannot be performed before the deadline.
+	if d := time.Since(startTime); d > time.Second {
+		t.Fatalf("the retry must be skipped after the deadline; Send took %s", d)
+	}
+	if n := len(srv.getRequests()); n != 1 {
+		t.Fatalf("unexpected number of requests; got %d; want 1", n)
+	}
+}
+
+func TestWebhook_SendRelabeling(t *testing.T) {
+	srv := newTestWebhookServer()
+	defer srv.Close()
+
+	var wc WebhookConfig
+	if err := yaml.Unmarshal([]byte(`{url: `+srv.URL+`, body_template: '{{ len .Alerts }}'}`), &wc); err != nil {
+		t.Fatalf("cannot parse webhook config: %s", err)
+	}
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- action: drop
+  source_labels: [job]
+  regex: bar
+`))
+	if err != nil {
+		t.Fatalf("cannot parse relabel configs: %s", err)
+	}
+	wh, err := NewWebhook(&wc, func(_ Alert) string { return "" }, pcs)
+	if err != nil {
+		t.Fatalf("cannot create webhook: %s", err)
+	}
+	defer wh.Close()
+
+	alerts := []Alert{
+		{Name: "alert1", State: StateFiring, Labels: map[string]string{"job": "foo"}},
+		{Name: "alert2", State: StateFiring, Labels: map[string]string{"job": "bar"}},
+	}
+	alertsSent := wh.metrics.alertsSent.Get()
+	if err := wh.Send(context.Background(), alerts, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// Alerts dropped by relabeling mustn't be counted as sent.
+	if n := wh.metrics.alertsSent.Get() - alertsSent; n != 1 {
+		t.Fatalf("unexpected number of sent alerts; got %d; want 1", n)
+	}
+	requests := srv.getRequests()
+	if len(requests) != 1 || requests[0].body != "1" {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+}
+
+func TestWebhookConfig_Failure(t *testing.T) {
+	f := func(data, errExpected string) {
+		t.Helper()
+		var wc WebhookConfig
+		err := yaml.Unmarshal([]byte(data), &wc)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("expected error to contain %q; got %q", errExpected, err)
+		}
+	}
+
+	f(`body_template: foo`, "missing `url`")
+	f(`url: http://localhost`, "missing `body_template`")
+	f(`{url: http://localhost, body_template: foo, method: DELETE}`, "unsupported webhook method")
+	f(`{url: http://localhost, body_template: foo, max_retries: -1}`, "cannot be negative")
+	f(`{url: http://localhost, body_template: foo, retry_min_interval: 1m, retry_max_interval: 1s}`, "cannot be smaller")
+	f(`{url: http://localhost, body_template: foo, unknown: bar}`, "unknown fields")
+}
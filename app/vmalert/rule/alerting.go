@@ -705,7 +705,17 @@ func (ar *AlertingRule) restore(ctx context.Context, q datasource.Querier, ts ti
 		return nil
 	}
 
-	if len(ar.alerts) < 1 {
+	ar.alertsMu.RLock()
+	needRestore := false
+	for _, a := range ar.alerts {
+		if !a.Restored && a.State == notifier.StatePending {
+			needRestore = true
+			break
+		}
+	}
+	ar.alertsMu.RUnlock()
+	if !needRestore {
+		// there are no alerts to restore or all of them are restored from -state.path
 		return nil
 	}
 
@@ -71,6 +71,19 @@ type Group struct {
 	// evalAlignment will make the timestamp of group query
 	// requests be aligned with interval
 	evalAlignment *bool
+
+	// depsMu protects upstreams
+	depsMu sync.RWMutex
+	// upstreams contains groups, which must finish evaluation
+	// before evaluating this group. See SetDependencies.
+	upstreams []*Group
+
+	// evalMu protects lastEvalTS and evaluatedCh
+	evalMu sync.Mutex
+	// lastEvalTS is the timestamp of the last finished evaluation
+	lastEvalTS time.Time
+	// evaluatedCh is closed when the next evaluation is finished
+	evaluatedCh chan struct{}
 }
 
 type groupMetrics struct {
@@ -78,6 +91,7 @@ type groupMetrics struct {
 	iterationDuration *utils.Summary
 	iterationMissed   *utils.Counter
 	iterationInterval *utils.Gauge
+	dependencyTimeout *utils.Counter
 }
 
 func newGroupMetrics(g *Group) *groupMetrics {
@@ -86,6 +100,7 @@ func newGroupMetrics(g *Group) *groupMetrics {
 	m.iterationTotal = utils.GetOrCreateCounter(fmt.Sprintf(`vmalert_iteration_total{%s}`, labels))
 	m.iterationDuration = utils.GetOrCreateSummary(fmt.Sprintf(`vmalert_iteration_duration_seconds{%s}`, labels))
 	m.iterationMissed = utils.GetOrCreateCounter(fmt.Sprintf(`vmalert_iteration_missed_total{%s}`, labels))
+	m.dependencyTimeout = utils.GetOrCreateCounter(fmt.Sprintf(`vmalert_iteration_dependency_timeouts_total{%s}`, labels))
 	m.iterationInterval = utils.GetOrCreateGauge(fmt.Sprintf(`vmalert_iteration_interval_seconds{%s}`, labels), func() float64 {
 		g.mu.RLock()
 		i := g.Interval.Seconds()
@@ -299,6 +314,7 @@ func (g *Group) Close() {
 	g.metrics.iterationTotal.Unregister()
 	g.metrics.iterationMissed.Unregister()
 	g.metrics.iterationInterval.Unregister()
+	g.metrics.dependencyTimeout.Unregister()
 	for _, rule := range g.Rules {
 		rule.close()
 	}
@@ -311,11 +327,16 @@ var SkipRandSleepOnGroupStart bool
 func (g *Group) Start(ctx context.Context, nts func() []notifier.Notifier, rw remotewrite.RWClient, rr datasource.QuerierBuilder) {
 	defer func() { close(g.finishedCh) }()
 
+	if sf != nil {
+		sf.register(g)
+		defer sf.unregister(g)
+	}
+
 	evalTS := time.Now()
 	// sleep random duration to spread group rules evaluation
 	// over time in order to reduce load on datasource.
 	if !SkipRandSleepOnGroupStart {
-		sleepBeforeStart := delayBeforeStart(evalTS, g.ID(), g.Interval, g.EvalOffset)
+		sleepBeforeStart := delayBeforeStart(evalTS, g.scheduleKey(), g.Interval, g.EvalOffset)
 		g.infof("will start in %v", sleepBeforeStart)
 
 		sleepTimer := time.NewTimer(sleepBeforeStart)
@@ -349,11 +370,15 @@ func (g *Group) Start(ctx context.Context, nts func() []notifier.Notifier, rw re
 		Rw:              rw,
 		Notifiers:       nts,
 		notifierHeaders: g.NotifierHeaders,
+		notifierTimeout: g.Interval,
 	}
 
 	g.infof("started")
 
 	eval := func(ctx context.Context, ts time.Time) {
+		g.waitForUpstreams(ctx, ts)
+		defer g.markEvaluated(ts)
+
 		g.metrics.iterationTotal.Inc()
 
 		start := time.Now()
@@ -422,6 +447,7 @@ func (g *Group) Start(ctx context.Context, nts func() []notifier.Notifier, rw re
 			}
 
 			e.notifierHeaders = g.NotifierHeaders
+			e.notifierTimeout = g.Interval
 			g.mu.Unlock()
 
 			g.infof("re-started")
@@ -442,6 +468,114 @@ func (g *Group) Start(ctx context.Context, nts func() []notifier.Notifier, rw re
 	}
 }
 
+// SetDependencies sets groups, which must finish evaluation for the same timestamp
+// before evaluating g. It is safe calling SetDependencies concurrently with Start.
+//
+// upstreams mustn't contain cyclic dependencies. See config.ResolveDependencies.
+func (g *Group) SetDependencies(upstreams []*Group) {
+	g.depsMu.Lock()
+	g.upstreams = upstreams
+	g.depsMu.Unlock()
+}
+
+func (g *Group) getDependencies() []*Group {
+	g.depsMu.RLock()
+	defer g.depsMu.RUnlock()
+	return g.upstreams
+}
+
+// scheduleKey returns the key for spreading group evaluations over time.
+//
+// Groups with the same interval and eval_offset as the upstream group share the schedule
+// with it, so they are evaluated for the same timestamps.
+func (g *Group) scheduleKey() uint64 {
+	interval, evalOffset := g.getSchedule()
+	for _, u := range g.getDependencies() {
+		uInterval, uEvalOffset := u.getSchedule()
+		if uInterval == interval && evalOffsetEqual(uEvalOffset, evalOffset) {
+			return u.scheduleKey()
+		}
+	}
+	return g.ID()
+}
+
+// getSchedule returns g.Interval and g.EvalOffset.
+//
+// It is safe calling it from other groups' goroutines.
+func (g *Group) getSchedule() (time.Duration, *time.Duration) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.Interval, g.EvalOffset
+}
+
+func evalOffsetEqual(a, b *time.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (g *Group) markEvaluated(ts time.Time) {
+	g.evalMu.Lock()
+	defer g.evalMu.Unlock()
+
+	g.lastEvalTS = ts
+	if g.evaluatedCh != nil {
+		close(g.evaluatedCh)
+		g.evaluatedCh = nil
+	}
+}
+
+// evaluatedFor returns true if g has finished the evaluation nearest to ts or a later one.
+// Otherwise, it returns a channel, which is closed when the next evaluation is finished.
+func (g *Group) evaluatedFor(ts time.Time) (bool, <-chan struct{}) {
+	interval, _ := g.getSchedule()
+
+	g.evalMu.Lock()
+	defer g.evalMu.Unlock()
+
+	if !g.lastEvalTS.IsZero() && g.lastEvalTS.Add(interval/2).After(ts) {
+		return true, nil
+	}
+	if g.evaluatedCh == nil {
+		g.evaluatedCh = make(chan struct{})
+	}
+	return false, g.evaluatedCh
+}
+
+// waitForUpstreams waits until all the upstream groups finish evaluation for ts.
+// It waits for at most g.Interval, so stuck upstream groups do not stop g evaluation.
+func (g *Group) waitForUpstreams(ctx context.Context, ts time.Time) {
+	upstreams := g.getDependencies()
+	if len(upstreams) == 0 {
+		return
+	}
+	t := time.NewTimer(g.Interval)
+	defer t.Stop()
+	for _, u := range upstreams {
+	wait:
+		for {
+			ok, ch := u.evaluatedFor(ts)
+			if ok {
+				break
+			}
+			select {
+			case <-ch:
+			case <-u.doneCh:
+				// the upstream group has been stopped
+				break wait
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				g.metrics.dependencyTimeout.Inc()
+				logger.Warnf("group %q: upstream group %q didn't finish evaluation for %s in %s; evaluating the group without waiting",
+					g.Name, u.Name, ts.Format(time.RFC3339), g.Interval)
+				return
+			}
+		}
+	}
+}
+
 // UpdateWith inserts new group to updateCh
 func (g *Group) UpdateWith(new *Group) {
 	g.updateCh <- new
@@ -623,6 +757,10 @@ func (g *Group) getEvalDelay() time.Duration {
 type executor struct {
 	Notifiers       func() []notifier.Notifier
 	notifierHeaders map[string]string
+	// notifierTimeout limits the time for sending alerts to notifiers including retries,
+	// so unavailable notifiers do not delay the next evaluation of the group.
+	// Zero value means no limit.
+	notifierTimeout time.Duration
 
 	Rw remotewrite.RWClient
 }
@@ -699,10 +837,16 @@ func (e *executor) exec(ctx context.Context, r Rule, ts time.Time, resolveDurati
 	}
 
 	alerts := ar.alertsToSend(resolveDuration, *resendDelay)
+	alerts = notifier.Suppress(alerts)
 	if len(alerts) < 1 {
 		return nil
 	}
 
+	if e.notifierTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.notifierTimeout)
+		defer cancel()
+	}
 	wg := sync.WaitGroup{}
 	errGr := new(utils.ErrGroup)
 	for _, nt := range e.Notifiers() {
@@ -542,6 +542,49 @@ func TestGroupStartDelay(t *testing.T) {
 	f("2023-01-01T00:30:00.000+00:00", "2023-01-01T01:16:00.000+00:00")
 }
 
+func TestGroupWaitForUpstreams(t *testing.T) {
+	newGroup := func(name string, interval time.Duration) *Group {
+		return NewGroup(config.Group{Name: name}, &datasource.FakeQuerier{}, interval, nil)
+	}
+	upstream := newGroup("upstream", time.Minute)
+	g := newGroup("dependent", time.Minute)
+	g.SetDependencies([]*Group{upstream})
+
+	if g.scheduleKey() != upstream.scheduleKey() {
+		t.Fatalf("dependent group must share the schedule with the upstream group")
+	}
+
+	ts := time.Now().Truncate(time.Minute)
+	upstream.markEvaluated(ts.Add(-time.Minute))
+
+	waitCh := make(chan struct{})
+	go func() {
+		g.waitForUpstreams(context.Background(), ts)
+		close(waitCh)
+	}()
+	select {
+	case <-waitCh:
+		t.Fatalf("dependent group mustn't be evaluated before the upstream group")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// evaluation with slightly different timestamp unblocks the dependent group
+	upstream.markEvaluated(ts.Add(time.Millisecond))
+	select {
+	case <-waitCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("dependent group must be unblocked after the upstream group evaluation")
+	}
+
+	// the upstream group doesn't finish evaluation in time
+	fast := newGroup("fast", 100*time.Millisecond)
+	fast.SetDependencies([]*Group{upstream})
+	fast.waitForUpstreams(context.Background(), ts.Add(time.Minute))
+	if n := fast.metrics.dependencyTimeout.Get(); n != 1 {
+		t.Fatalf("expecting 1 dependency timeout; got %d", n)
+	}
+}
+
 func TestGetPrometheusReqTimestamp(t *testing.T) {
 	f := func(g *Group, tsOrigin, tsExpected string) {
 		t.Helper()
@@ -0,0 +1,316 @@
+package rule
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	statePath = flag.String("state.path", "", "Optional path to the file for persisting the state of active alerts. "+
+		"vmalert periodically writes pending and firing alerts to this file and restores them on restart, "+
+		"while -remoteRead.url is used only for alerts missing in the file. See https://docs.victoriametrics.com/vmalert/#alerts-state-on-restarts")
+	stateCheckpointInterval = flag.Duration("state.checkpointInterval", 30*time.Second, "Interval for writing the state of active alerts to -state.path")
+)
+
+var (
+	stateCheckpointErrors = metrics.NewCounter(`vmalert_state_checkpoint_errors_total`)
+	stateAlertsRestored   = metrics.NewCounter(`vmalert_state_alerts_restored_total`)
+)
+
+// alertsStateFile is the on-disk representation of active alerts.
+type alertsStateFile struct {
+	// Timestamp is the time when the state has been written.
+	Timestamp time.Time         `json:"timestamp"`
+	Rules     []ruleAlertsState `json:"rules"`
+}
+
+type ruleAlertsState struct {
+	GroupID uint64       `json:"groupID"`
+	RuleID  uint64       `json:"ruleID"`
+	Alerts  []alertState `json:"alerts"`
+}
+
+type alertState struct {
+	ID              uint64            `json:"id"`
+	State           string            `json:"state"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Value           float64           `json:"value"`
+	ActiveAt        time.Time         `json:"activeAt"`
+	Start           time.Time         `json:"start"`
+	LastSent        time.Time         `json:"lastSent"`
+	KeepFiringSince time.Time         `json:"keepFiringSince"`
+}
+
+type ruleStateKey struct {
+	groupID uint64
+	ruleID  uint64
+}
+
+// stateFile periodically writes the state of active alerts for the registered groups to path.
+type stateFile struct {
+	path string
+
+	mu     sync.Mutex
+	groups map[*Group]struct{}
+	// restored contains the alerts loaded from path on start.
+	// The entries are deleted after restoring them.
+	restored map[ruleStateKey][]alertState
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// sf is non-nil only if -state.path is set.
+var sf *stateFile
+
+// InitStateFile loads the state of active alerts from -state.path and starts writing the state to it.
+//
+// InitStateFile must be called before starting groups. StopStateFile must be called for writing the final state.
+func InitStateFile() error {
+	if *statePath == "" {
+		return nil
+	}
+	if *stateCheckpointInterval <= 0 {
+		return fmt.Errorf("-state.checkpointInterval must be positive; got %s", *stateCheckpointInterval)
+	}
+	s := &stateFile{
+		path:     *statePath,
+		groups:   make(map[*Group]struct{}),
+		restored: make(map[ruleStateKey][]alertState),
+		stopCh:   make(chan struct{}),
+	}
+	if err := s.load(time.Now(), *remoteReadLookBack); err != nil {
+		return err
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(*stateCheckpointInterval)
+	}()
+	sf = s
+	return nil
+}
+
+// StopStateFile writes the state of active alerts to -state.path and stops the periodic writing.
+//
+// StopStateFile must be called before stopping groups, since the state is collected from the running groups.
+func StopStateFile() {
+	if sf == nil {
+		return
+	}
+	close(sf.stopCh)
+	sf.wg.Wait()
+	if err := sf.write(time.Now()); err != nil {
+		stateCheckpointErrors.Inc()
+		logger.Errorf("cannot write alerts state to -state.path=%q: %s", sf.path, err)
+	}
+}
+
+func (s *stateFile) run(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+			if err := s.write(time.Now()); err != nil {
+				stateCheckpointErrors.Inc()
+				logger.Errorf("cannot write alerts state to -state.path=%q: %s", s.path, err)
+			}
+		}
+	}
+}
+
+// load reads the state from s.path. The state older than maxAge is ignored.
+func (s *stateFile) load(now time.Time, maxAge time.Duration) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read alerts state from -state.path=%q: %w", s.path, err)
+	}
+	var asf alertsStateFile
+	if err := json.Unmarshal(data, &asf); err != nil {
+		// The state is an optimization over restoring alerts via -remoteRead.url,
+		// so do not prevent vmalert from starting if it is corrupted.
+		logger.Errorf("cannot parse alerts state from -state.path=%q; ignoring it: %s", s.path, err)
+		return nil
+	}
+	if age := now.Sub(asf.Timestamp); age > maxAge {
+		logger.Infof("ignoring alerts state from -state.path=%q, since it is older than -remoteRead.lookback=%s; age: %s", s.path, maxAge, age)
+		return nil
+	}
+	for _, rs := range asf.Rules {
+		k := ruleStateKey{
+			groupID: rs.GroupID,
+			ruleID:  rs.RuleID,
+		}
+		s.restored[k] = rs.Alerts
+	}
+	return nil
+}
+
+func (s *stateFile) write(now time.Time) error {
+	s.mu.Lock()
+	groups := make([]*Group, 0, len(s.groups))
+	for g := range s.groups {
+		groups = append(groups, g)
+	}
+	s.mu.Unlock()
+
+	asf := &alertsStateFile{
+		Timestamp: now,
+	}
+	for _, g := range groups {
+		asf.Rules = append(asf.Rules, g.alertsState()...)
+	}
+	data, err := json.Marshal(asf)
+	if err != nil {
+		return fmt.Errorf("cannot marshal alerts state: %w", err)
+	}
+	// Write the state to temporary file at first, so the previous state remains intact on errors.
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	if d, err := os.Open(filepath.Dir(s.path)); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
+
+func (s *stateFile) register(g *Group) {
+	s.mu.Lock()
+	s.groups[g] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *stateFile) unregister(g *Group) {
+	s.mu.Lock()
+	delete(s.groups, g)
+	s.mu.Unlock()
+}
+
+// takeRestored returns alerts for the given groupID and ruleID loaded on start.
+// The alerts are returned only once.
+func (s *stateFile) takeRestored(groupID, ruleID uint64) []alertState {
+	k := ruleStateKey{
+		groupID: groupID,
+		ruleID:  ruleID,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := s.restored[k]
+	delete(s.restored, k)
+	return alerts
+}
+
+// alertsState returns the state of active alerts for g rules.
+func (g *Group) alertsState() []ruleAlertsState {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	groupID := g.ID()
+	var result []ruleAlertsState
+	for _, r := range g.Rules {
+		ar, ok := r.(*AlertingRule)
+		if !ok {
+			continue
+		}
+		alerts := ar.alertsState()
+		if len(alerts) == 0 {
+			continue
+		}
+		result = append(result, ruleAlertsState{
+			GroupID: groupID,
+			RuleID:  ar.ID(),
+			Alerts:  alerts,
+		})
+	}
+	return result
+}
+
+// RestoreState restores active alerts for g rules from -state.path.
+//
+// Restored alerts are skipped when restoring the state via -remoteRead.url.
+// RestoreState must be called before Start.
+func (g *Group) RestoreState() {
+	if sf == nil {
+		return
+	}
+	groupID := g.ID()
+	for _, r := range g.Rules {
+		ar, ok := r.(*AlertingRule)
+		if !ok {
+			continue
+		}
+		alerts := sf.takeRestored(groupID, ar.ID())
+		if len(alerts) == 0 {
+			continue
+		}
+		ar.restoreAlertsState(alerts)
+		stateAlertsRestored.Add(len(alerts))
+		g.infof("restored %d alerts for rule %q from -state.path", len(alerts), ar.Name)
+	}
+}
+
+func (ar *AlertingRule) alertsState() []alertState {
+	ar.alertsMu.RLock()
+	defer ar.alertsMu.RUnlock()
+
+	var result []alertState
+	for _, a := range ar.alerts {
+		if a.State == notifier.StateInactive {
+			continue
+		}
+		result = append(result, alertState{
+			ID:              a.ID,
+			State:           a.State.String(),
+			Labels:          a.Labels,
+			Annotations:     a.Annotations,
+			Value:           a.Value,
+			ActiveAt:        a.ActiveAt,
+			Start:           a.Start,
+			LastSent:        a.LastSent,
+			KeepFiringSince: a.KeepFiringSince,
+		})
+	}
+	return result
+}
+
+func (ar *AlertingRule) restoreAlertsState(alerts []alertState) {
+	ar.alertsMu.Lock()
+	defer ar.alertsMu.Unlock()
+
+	for _, as := range alerts {
+		state := notifier.StatePending
+		if as.State == notifier.StateFiring.String() {
+			state = notifier.StateFiring
+		}
+		a := ar.newAlert(datasource.Metric{Values: []float64{as.Value}}, as.ActiveAt, as.Labels, as.Annotations)
+		a.ID = as.ID
+		a.State = state
+		a.Start = as.Start
+		a.LastSent = as.LastSent
+		a.KeepFiringSince = as.KeepFiringSince
+		a.Restored = true
+		ar.alerts[a.ID] = a
+	}
+}
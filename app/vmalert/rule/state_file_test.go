@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+)
+
+func TestStateFile(t *testing.T) {
+	const rules = `
+  - name: groupTest
+    rules:
+      - alert: VMRows
+        for: 5m
+        keep_firing_for: 10m
+        expr: vm_rows > 0
+`
+	var groups []config.Group
+	if err := yaml.Unmarshal([]byte(rules), &groups); err != nil {
+		t.Fatalf("failed to parse rules: %s", err)
+	}
+	fq := &datasource.FakeQuerier{}
+	ts := time.Now().Truncate(time.Second)
+
+	g := NewGroup(groups[0], fq, time.Minute, nil)
+	ar := g.Rules[0].(*AlertingRule)
+	fq.Add(metricWithValueAndLabels(t, 1, "instance", "foo"))
+	if _, err := ar.exec(context.Background(), ts.Add(-10*time.Minute), 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	pending := ar.GetAlerts()[0]
+	firing := ar.newAlert(datasource.Metric{Values: []float64{2}}, ts.Add(-time.Hour), map[string]string{"instance": "bar"}, map[string]string{"summary": "bar"})
+	firing.ID = hash(firing.Labels)
+	firing.State = notifier.StateFiring
+	firing.Start = ts.Add(-50 * time.Minute)
+	firing.LastSent = ts.Add(-time.Minute)
+	firing.KeepFiringSince = ts.Add(-2 * time.Minute)
+	inactive := ar.newAlert(datasource.Metric{Values: []float64{3}}, ts.Add(-time.Hour), map[string]string{"instance": "baz"}, nil)
+	inactive.ID = hash(inactive.Labels)
+	inactive.State = notifier.StateInactive
+	for _, a := range []*notifier.Alert{firing, inactive} {
+		ar.alerts[a.ID] = a
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	s := &stateFile{
+		path:   path,
+		groups: make(map[*Group]struct{}),
+	}
+	s.register(g)
+	if err := s.write(ts); err != nil {
+		t.Fatalf("cannot write state: %s", err)
+	}
+
+	// the state older than maxAge must be ignored
+	s = &stateFile{
+		path:     path,
+		restored: make(map[ruleStateKey][]alertState),
+	}
+	if err := s.load(ts.Add(time.Hour), 30*time.Minute); err != nil {
+		t.Fatalf("cannot load state: %s", err)
+	}
+	if len(s.restored) != 0 {
+		t.Fatalf("unexpected restored state: %v", s.restored)
+	}
+
+	if err := s.load(ts.Add(time.Minute), 30*time.Minute); err != nil {
+		t.Fatalf("cannot load state: %s", err)
+	}
+	sf = s
+	defer func() { sf = nil }()
+
+	gRestored := NewGroup(groups[0], fq, time.Minute, nil)
+	gRestored.RestoreState()
+	arRestored := gRestored.Rules[0].(*AlertingRule)
+	if len(arRestored.alerts) != 2 {
+		t.Fatalf("unexpected number of restored alerts; got %d; want 2", len(arRestored.alerts))
+	}
+	for _, a := range []*notifier.Alert{pending, firing} {
+		got := arRestored.GetAlert(a.ID)
+		if got == nil {
+			t.Fatalf("alert %v isn't restored", a.Labels)
+		}
+		a.Restored = true
+		compareAlerts(t, []notifier.Alert{*a}, []notifier.Alert{*got})
+		if !got.Start.Equal(a.Start) || !got.LastSent.Equal(a.LastSent) || !got.KeepFiringSince.Equal(a.KeepFiringSince) {
+			t.Fatalf("unexpected timestamps for restored alert; got %+v; want %+v", got, a)
+		}
+	}
+
+	// the state must be restored only once
+	if alerts := s.takeRestored(gRestored.ID(), arRestored.ID()); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts restored for the second time: %v", alerts)
+	}
+
+	// the restored pending alert must become firing on the first evaluation,
+	// since it has been active for longer than `for`
+	if _, err := arRestored.exec(context.Background(), ts.Add(time.Minute), 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a := arRestored.GetAlert(pending.ID); a == nil || a.State != notifier.StateFiring {
+		t.Fatalf("expecting restored pending alert to become firing; got %+v", a)
+	}
+	// the restored firing alert must keep firing because of keep_firing_for
+	if a := arRestored.GetAlert(firing.ID); a == nil || a.State != notifier.StateFiring {
+		t.Fatalf("expecting restored firing alert to keep firing; got %+v", a)
+	}
+}
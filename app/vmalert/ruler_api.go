@@ -0,0 +1,482 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var rulerAPIStorePath = flag.String("rule.apiStorePath", "", "Optional path to the directory for storing rule groups managed via Mimir/Cortex-compatible ruler API. "+
+	"The API is disabled if the flag isn't set. The directory must not be matched by -rule. See https://docs.victoriametrics.com/vmalert/#ruler-api")
+var rulerAPIAuthKey = flagutil.NewPassword("rule.apiAuthKey", "Auth key for modifying rule groups via ruler API. It must be passed via authKey query arg. It overrides -httpAuth.*")
+
+// rulerAPIPaths contains path prefixes served by ruler API.
+//
+// `/api/v1/rules` is the legacy Cortex path, while `/prometheus/config/v1/rules` is used by mimirtool and Grafana.
+// The exact `/api/v1/rules` path isn't served by ruler API, since it lists the loaded groups in Prometheus format.
+var rulerAPIPaths = []string{"/api/v1/rules", "/config/v1/rules", "/prometheus/config/v1/rules"}
+
+// rulerStore stores rule groups managed via ruler API in the local directory.
+//
+// Every namespace is stored in a separate file in the same format as files from -rule,
+// so stored groups are parsed and validated by the config package.
+type rulerStore struct {
+	path          string
+	validateTplFn config.ValidateTplFn
+
+	// mu serializes modifications of the stored namespaces.
+	mu sync.Mutex
+	// updateCh is notified on every change of the stored groups.
+	updateCh chan struct{}
+}
+
+// rulerNamespace is the content of the namespace file.
+//
+// Groups are stored as yaml.MapSlice in order to preserve them exactly as they were sent by the user.
+type rulerNamespace struct {
+	Groups []yaml.MapSlice `yaml:"groups"`
+}
+
+// newRulerStore returns nil if path is empty.
+func newRulerStore(path string, validateTplFn config.ValidateTplFn) (*rulerStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	path = filepath.Clean(path)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory for ruler API store: %w", err)
+	}
+	return &rulerStore{
+		path:          path,
+		validateTplFn: validateTplFn,
+		updateCh:      make(chan struct{}, 1),
+	}, nil
+}
+
+// updates returns a channel, which is notified on every change of the stored groups.
+func (rs *rulerStore) updates() <-chan struct{} {
+	if rs == nil {
+		return nil
+	}
+	return rs.updateCh
+}
+
+func (rs *rulerStore) notify() {
+	select {
+	case rs.updateCh <- struct{}{}:
+	default:
+		// the update is already pending
+	}
+}
+
+// checkRulePaths returns an error if files at rs may be matched by the given -rule patterns.
+//
+// Such files would be loaded both as file-based groups and as groups managed via ruler API.
+func (rs *rulerStore) checkRulePaths(patterns []string) error {
+	if rs == nil {
+		return nil
+	}
+	storePath, err := filepath.Abs(rs.path)
+	if err != nil {
+		return fmt.Errorf("cannot resolve -rule.apiStorePath=%q: %w", rs.path, err)
+	}
+	for _, pattern := range patterns {
+		path := pattern
+		if n := strings.Index(path, "://"); n >= 0 {
+			if path[:n] != "fs" {
+				// Remote rules cannot match local files.
+				continue
+			}
+			path = path[n+len("://"):]
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("cannot resolve -rule=%q: %w", pattern, err)
+		}
+		ok, err := filepath.Match(filepath.Dir(path), storePath)
+		if err != nil {
+			return fmt.Errorf("cannot parse -rule=%q: %w", pattern, err)
+		}
+		if ok || path == storePath {
+			return fmt.Errorf("-rule=%q matches files at -rule.apiStorePath=%q; the directory for -rule.apiStorePath must be located outside of -rule paths", pattern, rs.path)
+		}
+	}
+	return nil
+}
+
+// isStoreFile returns true if the rules file belongs to rs.
+func (rs *rulerStore) isStoreFile(file string) bool {
+	return rs != nil && filepath.Dir(file) == rs.path
+}
+
+// groups parses and returns all the groups from rs.
+func (rs *rulerStore) groups() ([]config.Group, error) {
+	if rs == nil {
+		return nil, nil
+	}
+	groups, err := config.ParseSilent([]string{filepath.Join(rs.path, "*.yaml")}, rs.validateTplFn, *validateExpressions)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse groups from -rule.apiStorePath=%q: %w", rs.path, err)
+	}
+	return groups, nil
+}
+
+func (rs *rulerStore) namespaceFile(ns string) string {
+	return filepath.Join(rs.path, url.PathEscape(ns)+".yaml")
+}
+
+// readNamespace returns groups for the given namespace.
+// It returns nil if the namespace doesn't exist.
+func (rs *rulerStore) readNamespace(ns string) ([]yaml.MapSlice, error) {
+	data, err := os.ReadFile(rs.namespaceFile(ns))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errResponse(fmt.Errorf("cannot read namespace %q: %w", ns, err), http.StatusInternalServerError)
+	}
+	var rn rulerNamespace
+	if err := yaml.Unmarshal(data, &rn); err != nil {
+		return nil, errResponse(fmt.Errorf("cannot parse namespace %q: %w", ns, err), http.StatusInternalServerError)
+	}
+	return rn.Groups, nil
+}
+
+// namespaces returns all the stored namespaces with their groups.
+func (rs *rulerStore) namespaces() (map[string][]yaml.MapSlice, error) {
+	des, err := os.ReadDir(rs.path)
+	if err != nil {
+		return nil, errResponse(fmt.Errorf("cannot read ruler API store: %w", err), http.StatusInternalServerError)
+	}
+	result := make(map[string][]yaml.MapSlice)
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, ".yaml") {
+			continue
+		}
+		ns, err := url.PathUnescape(strings.TrimSuffix(name, ".yaml"))
+		if err != nil {
+			logger.Warnf("skipping unexpected file %q in -rule.apiStorePath=%q", name, rs.path)
+			continue
+		}
+		groups, err := rs.readNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) > 0 {
+			result[ns] = groups
+		}
+	}
+	return result, nil
+}
+
+// setGroup stores the group from data in the namespace ns.
+//
+// The group with the same name is replaced. If name isn't empty, then it must match the group name from data.
+func (rs *rulerStore) setGroup(ns, name string, data []byte) error {
+	var g yaml.MapSlice
+	if err := yaml.Unmarshal(data, &g); err != nil {
+		return fmt.Errorf("cannot parse rule group: %w", err)
+	}
+	groupName := rulerGroupName(g)
+	if groupName == "" {
+		return fmt.Errorf("group name must be set")
+	}
+	if name != "" && name != groupName {
+		return fmt.Errorf("group name %q doesn't match %q from the request path", groupName, name)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	groups, err := rs.readNamespace(ns)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range groups {
+		if rulerGroupName(groups[i]) == groupName {
+			groups[i] = g
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		groups = append(groups, g)
+	}
+	return rs.writeNamespace(ns, groups)
+}
+
+// deleteGroup deletes the group with the given name from the namespace ns.
+func (rs *rulerStore) deleteGroup(ns, name string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	groups, err := rs.readNamespace(ns)
+	if err != nil {
+		return err
+	}
+	for i := range groups {
+		if rulerGroupName(groups[i]) == name {
+			groups = append(groups[:i], groups[i+1:]...)
+			return rs.writeNamespace(ns, groups)
+		}
+	}
+	return errResponse(fmt.Errorf("group %q not found in namespace %q", name, ns), http.StatusNotFound)
+}
+
+// deleteNamespace deletes the namespace ns with all its groups.
+func (rs *rulerStore) deleteNamespace(ns string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, err := os.Stat(rs.namespaceFile(ns)); err != nil {
+		if os.IsNotExist(err) {
+			return errResponse(fmt.Errorf("namespace %q not found", ns), http.StatusNotFound)
+		}
+		return errResponse(fmt.Errorf("cannot delete namespace %q: %w", ns, err), http.StatusInternalServerError)
+	}
+	return rs.writeNamespace(ns, nil)
+}
+
+// writeNamespace validates groups and writes them to the namespace file.
+// The file is deleted if groups are empty.
+//
+// rs.mu must be locked by the caller.
+func (rs *rulerStore) writeNamespace(ns string, groups []yaml.MapSlice) error {
+	file := rs.namespaceFile(ns)
+	if len(groups) == 0 {
+		if err := rs.validateNamespace(ns, nil); err != nil {
+			return err
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return errResponse(fmt.Errorf("cannot delete namespace %q: %w", ns, err), http.StatusInternalServerError)
+		}
+		rs.notify()
+		return nil
+	}
+	data, err := yaml.Marshal(&rulerNamespace{Groups: groups})
+	if err != nil {
+		return fmt.Errorf("cannot marshal namespace %q: %w", ns, err)
+	}
+	if err := rs.validateNamespace(ns, data); err != nil {
+		return err
+	}
+	// Write to temporary file at first, so it isn't read by config reload until it is complete.
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return errResponse(fmt.Errorf("cannot write namespace %q: %w", ns, err), http.StatusInternalServerError)
+	}
+	if err := os.Rename(tmpFile, file); err != nil {
+		return errResponse(fmt.Errorf("cannot write namespace %q: %w", ns, err), http.StatusInternalServerError)
+	}
+	rs.notify()
+	return nil
+}
+
+// validateNamespace checks whether the config remains valid after replacing the namespace ns with data.
+// The namespace is considered deleted if data is nil.
+//
+// Groups from all the namespaces are validated together with groups from -rule files,
+// since the config is rejected on reload if groups conflict with each other or have invalid dependencies.
+//
+// rs.mu must be locked by the caller.
+func (rs *rulerStore) validateNamespace(ns string, data []byte) error {
+	des, err := os.ReadDir(rs.path)
+	if err != nil {
+		return errResponse(fmt.Errorf("cannot read ruler API store: %w", err), http.StatusInternalServerError)
+	}
+	files := make(map[string][]byte)
+	for _, de := range des {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".yaml") {
+			continue
+		}
+		file := filepath.Join(rs.path, de.Name())
+		fileData, err := os.ReadFile(file)
+		if err != nil {
+			return errResponse(fmt.Errorf("cannot read %q: %w", file, err), http.StatusInternalServerError)
+		}
+		files[file] = fileData
+	}
+	file := rs.namespaceFile(ns)
+	if data == nil {
+		delete(files, file)
+	} else {
+		files[file] = data
+	}
+	// validate groups in the same way as groups from -rule files
+	storeGroups, err := config.ParseFiles(files, rs.validateTplFn, *validateExpressions)
+	if err != nil {
+		return fmt.Errorf("invalid rule group: %w", err)
+	}
+
+	fileGroups, err := config.ParseSilent(*rulePath, rs.validateTplFn, *validateExpressions)
+	if err != nil {
+		return errResponse(fmt.Errorf("cannot parse -rule files: %w", err), http.StatusInternalServerError)
+	}
+	groupFiles := make(map[string]string, len(fileGroups))
+	for _, g := range fileGroups {
+		groupFiles[g.Name] = g.File
+	}
+	for _, g := range storeGroups {
+		if f, ok := groupFiles[g.Name]; ok {
+			return fmt.Errorf("group name %q conflicts with the group from -rule file %q", g.Name, f)
+		}
+	}
+	if _, err := config.ResolveDependencies(append(fileGroups, storeGroups...)); err != nil {
+		return fmt.Errorf("invalid group dependencies: %w", err)
+	}
+	return nil
+}
+
+func rulerGroupName(g yaml.MapSlice) string {
+	for _, item := range g {
+		if item.Key == "name" && item.Value != nil {
+			return fmt.Sprintf("%v", item.Value)
+		}
+	}
+	return ""
+}
+
+// parseRulerAPIPath returns namespace and group from the ruler API path.
+//
+// ok is false if path isn't served by ruler API.
+func parseRulerAPIPath(path string) (ns, group string, ok bool, err error) {
+	for _, prefix := range rulerAPIPaths {
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		s := strings.Trim(path[len(prefix):], "/")
+		if s == "" {
+			return "", "", true, nil
+		}
+		parts := strings.Split(s, "/")
+		if len(parts) > 2 {
+			return "", "", true, fmt.Errorf("unsupported path %q", path)
+		}
+		if ns, err = url.PathUnescape(parts[0]); err != nil {
+			return "", "", true, fmt.Errorf("cannot unescape namespace: %w", err)
+		}
+		if len(parts) == 2 {
+			if group, err = url.PathUnescape(parts[1]); err != nil {
+				return "", "", true, fmt.Errorf("cannot unescape group: %w", err)
+			}
+		}
+		return ns, group, true, nil
+	}
+	return "", "", false, nil
+}
+
+// handleRulerAPI serves Mimir/Cortex-compatible ruler API.
+// See https://grafana.com/docs/mimir/latest/references/http-api/#ruler
+func (rh *requestHandler) handleRulerAPI(w http.ResponseWriter, r *http.Request) bool {
+	ns, group, ok, err := parseRulerAPIPath(r.URL.EscapedPath())
+	if !ok {
+		return false
+	}
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+	rs := rh.rs
+	if rs == nil {
+		httpserver.Errorf(w, r, "%s", errResponse(fmt.Errorf("ruler API is disabled; set -rule.apiStorePath command-line flag for enabling it"), http.StatusNotFound))
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var resp any
+		switch {
+		case ns == "":
+			resp, err = rs.namespaces()
+		case group == "":
+			var groups []yaml.MapSlice
+			groups, err = rs.readNamespace(ns)
+			if err == nil && len(groups) == 0 {
+				err = errResponse(fmt.Errorf("namespace %q not found", ns), http.StatusNotFound)
+			}
+			resp = map[string][]yaml.MapSlice{ns: groups}
+		default:
+			var groups []yaml.MapSlice
+			groups, err = rs.readNamespace(ns)
+			if err != nil {
+				break
+			}
+			err = errResponse(fmt.Errorf("group %q not found in namespace %q", group, ns), http.StatusNotFound)
+			for _, g := range groups {
+				if rulerGroupName(g) == group {
+					resp, err = g, nil
+					break
+				}
+			}
+		}
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		data, err := yaml.Marshal(resp)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot marshal response: %s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+		return true
+	case http.MethodPost:
+		// Read the request body before checking the auth key,
+		// since the body with form content type is consumed by the authKey query arg lookup.
+		var data []byte
+		data, err = io.ReadAll(r.Body)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot read request body: %s", err)
+			return true
+		}
+		if !httpserver.CheckAuthFlag(w, r, rulerAPIAuthKey) {
+			return true
+		}
+		if ns == "" {
+			httpserver.Errorf(w, r, "namespace must be set")
+			return true
+		}
+		err = rs.setGroup(ns, group, data)
+	case http.MethodDelete:
+		if !httpserver.CheckAuthFlag(w, r, rulerAPIAuthKey) {
+			return true
+		}
+		switch {
+		case ns == "":
+			err = fmt.Errorf("namespace must be set")
+		case group == "":
+			err = rs.deleteNamespace(ns)
+		default:
+			err = rs.deleteGroup(ns, group)
+		}
+	default:
+		httpserver.Errorf(w, r, "%s", errResponse(fmt.Errorf("unsupported method %q", r.Method), http.StatusMethodNotAllowed))
+		return true
+	}
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+	logger.Infof("rule groups in namespace %q have been changed via ruler API", ns)
+	// changes are applied asynchronously by configReload, so respond in the same way as Mimir does
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"status":"success","data":null,"errorType":"","error":""}`)
+	return true
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRulerAPIPath(t *testing.T) {
+	f := func(path, nsExpected, groupExpected string, okExpected, errExpected bool) {
+		t.Helper()
+		ns, group, ok, err := parseRulerAPIPath(path)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok for %q; got %v; want %v", path, ok, okExpected)
+		}
+		if (err != nil) != errExpected {
+			t.Fatalf("unexpected error for %q: %v", path, err)
+		}
+		if ns != nsExpected || group != groupExpected {
+			t.Fatalf("unexpected result for %q; got %q, %q; want %q, %q", path, ns, group, nsExpected, groupExpected)
+		}
+	}
+
+	f("/api/v1/alerts", "", "", false, false)
+	f("/api/v1/rulesfoo", "", "", false, false)
+	f("/prometheus/config/v1/rules", "", "", true, false)
+	f("/config/v1/rules/", "", "", true, false)
+	f("/api/v1/rules/foo", "foo", "", true, false)
+	f("/prometheus/config/v1/rules/foo%2Fbar/group%201", "foo/bar", "group 1", true, false)
+	f("/api/v1/rules/foo/bar/baz", "", "", true, true)
+}
+
+func TestRulerAPI(t *testing.T) {
+	rs, err := newRulerStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("cannot create ruler store: %s", err)
+	}
+	rh := &requestHandler{m: &manager{}, rs: rs}
+
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(ruleFile, []byte(`
+groups:
+  - name: file-group
+    rules:
+      - record: job:up:count
+        expr: count(up) by (job)
+`), 0644); err != nil {
+		t.Fatalf("cannot write rules file: %s", err)
+	}
+	origRulePath := *rulePath
+	*rulePath = []string{ruleFile}
+	defer func() {
+		*rulePath = origRulePath
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { rh.handler(w, r) }))
+	defer ts.Close()
+
+	do := func(method, path, body string, codeExpected int) string {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("cannot read response: %s", err)
+		}
+		if resp.StatusCode != codeExpected {
+			t.Fatalf("unexpected status code for %s %s; got %d; want %d; response: %s", method, path, resp.StatusCode, codeExpected, data)
+		}
+		return string(data)
+	}
+	checkUpdated := func(updatedExpected bool) {
+		t.Helper()
+		select {
+		case <-rs.updates():
+			if !updatedExpected {
+				t.Fatalf("unexpected update notification")
+			}
+		default:
+			if updatedExpected {
+				t.Fatalf("expecting update notification")
+			}
+		}
+	}
+
+	do(http.MethodGet, "/api/v1/rules/team-a", "", http.StatusNotFound)
+
+	// create groups
+	do(http.MethodPost, "/api/v1/rules/team-a", `
+name: group-1
+interval: 1m
+rules:
+  - alert: Down
+    expr: up == 0
+`, http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodPost, "/prometheus/config/v1/rules/team-a/group-2", `
+name: group-2
+rules:
+  - record: job:up:sum
+    expr: sum(up) by (job)
+`, http.StatusAccepted)
+	checkUpdated(true)
+
+	// invalid groups must be rejected
+	do(http.MethodPost, "/api/v1/rules/team-a", `rules: [{alert: Down, expr: up == 0}]`, http.StatusBadRequest)
+	do(http.MethodPost, "/api/v1/rules/team-a", `{name: group-3, rules: [{alert: Down, expr: "up | 0"}]}`, http.StatusBadRequest)
+	do(http.MethodPost, "/api/v1/rules/team-a", `{name: group-3, rules: [{alert: Down, expr: up, unknown: foo}]}`, http.StatusBadRequest)
+	do(http.MethodPost, "/api/v1/rules/team-a/group-4", `{name: group-3, rules: [{alert: Down, expr: up}]}`, http.StatusBadRequest)
+	checkUpdated(false)
+
+	// update the existing group
+	do(http.MethodPost, "/api/v1/rules/team-a", `
+name: group-1
+rules:
+  - alert: Down
+    expr: up == 0
+    for: 5m
+`, http.StatusAccepted)
+	checkUpdated(true)
+
+	resp := do(http.MethodGet, "/api/v1/rules/team-a/group-1", "", http.StatusOK)
+	respExpected := `name: group-1
+rules:
+- alert: Down
+  expr: up == 0
+  for: 5m
+`
+	if resp != respExpected {
+		t.Fatalf("unexpected response; got\n%s\nwant\n%s", resp, respExpected)
+	}
+	resp = do(http.MethodGet, "/prometheus/config/v1/rules", "", http.StatusOK)
+	if !strings.HasPrefix(resp, "team-a:\n- name: group-1\n") || !strings.Contains(resp, "- name: group-2\n") {
+		t.Fatalf("unexpected response for namespaces list:\n%s", resp)
+	}
+
+	groups, err := rs.groups()
+	if err != nil {
+		t.Fatalf("cannot parse stored groups: %s", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("unexpected number of stored groups; got %d; want 2", len(groups))
+	}
+	for _, g := range groups {
+		if !rs.isStoreFile(g.File) {
+			t.Fatalf("unexpected file %q for group %q", g.File, g.Name)
+		}
+	}
+
+	// delete groups
+	do(http.MethodDelete, "/api/v1/rules/team-a/group-3", "", http.StatusNotFound)
+	do(http.MethodDelete, "/api/v1/rules/team-a/group-1", "", http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodGet, "/api/v1/rules/team-a/group-1", "", http.StatusNotFound)
+	do(http.MethodDelete, "/api/v1/rules/team-a", "", http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodGet, "/api/v1/rules/team-a", "", http.StatusNotFound)
+	do(http.MethodDelete, "/api/v1/rules/team-a", "", http.StatusNotFound)
+	if resp := do(http.MethodGet, "/config/v1/rules", "", http.StatusOK); resp != "{}\n" {
+		t.Fatalf("unexpected response for empty namespaces list: %q", resp)
+	}
+
+	// groups must be validated together with groups from -rule files and other namespaces
+	do(http.MethodPost, "/api/v1/rules/team-b", `{name: file-group, rules: [{alert: Down, expr: up == 0}]}`, http.StatusBadRequest)
+	do(http.MethodPost, "/api/v1/rules/team-b", `{name: group-1, depends_on: [unknown], rules: [{alert: Down, expr: up == 0}]}`, http.StatusBadRequest)
+	do(http.MethodPost, "/api/v1/rules/team-b", `{name: group-1, depends_on: [file-group], rules: [{alert: Down, expr: up == 0}]}`, http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodPost, "/api/v1/rules/team-c", `{name: group-2, depends_on: [group-1], rules: [{alert: Down, expr: up == 0}]}`, http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodPost, "/api/v1/rules/team-b", `{name: group-1, depends_on: [group-2], rules: [{alert: Down, expr: up == 0}]}`, http.StatusBadRequest)
+	do(http.MethodDelete, "/api/v1/rules/team-b/group-1", "", http.StatusBadRequest)
+	do(http.MethodDelete, "/api/v1/rules/team-b", "", http.StatusBadRequest)
+	checkUpdated(false)
+	do(http.MethodDelete, "/api/v1/rules/team-c", "", http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodDelete, "/api/v1/rules/team-b", "", http.StatusAccepted)
+	checkUpdated(true)
+
+	// modifications must be protected by -rule.apiAuthKey
+	if err := rulerAPIAuthKey.Set("secret"); err != nil {
+		t.Fatalf("cannot set -rule.apiAuthKey: %s", err)
+	}
+	defer func() {
+		if err := rulerAPIAuthKey.Set(""); err != nil {
+			t.Fatalf("cannot reset -rule.apiAuthKey: %s", err)
+		}
+	}()
+	group := `{name: group-1, rules: [{alert: Down, expr: up == 0}]}`
+	do(http.MethodPost, "/api/v1/rules/team-a", group, http.StatusUnauthorized)
+	do(http.MethodPost, "/api/v1/rules/team-a?authKey=foo", group, http.StatusUnauthorized)
+	checkUpdated(false)
+	do(http.MethodPost, "/api/v1/rules/team-a?authKey=secret", group, http.StatusAccepted)
+	checkUpdated(true)
+	do(http.MethodGet, "/api/v1/rules/team-a/group-1", "", http.StatusOK)
+	do(http.MethodDelete, "/api/v1/rules/team-a", "", http.StatusUnauthorized)
+	do(http.MethodDelete, "/api/v1/rules/team-a?authKey=secret", "", http.StatusAccepted)
+	checkUpdated(true)
+
+	// ruler API must return 404 if it is disabled
+	rh.rs = nil
+	do(http.MethodGet, "/api/v1/rules/team-a", "", http.StatusNotFound)
+}
+
+func TestRulerStoreCheckRulePaths(t *testing.T) {
+	dir := t.TempDir()
+	rs, err := newRulerStore(filepath.Join(dir, "api"), nil)
+	if err != nil {
+		t.Fatalf("cannot create ruler store: %s", err)
+	}
+	f := func(patterns []string, errExpected bool) {
+		t.Helper()
+		err := rs.checkRulePaths(patterns)
+		if (err != nil) != errExpected {
+			t.Fatalf("unexpected error for %q: %v", patterns, err)
+		}
+	}
+
+	// rules outside of the store directory
+	f(nil, false)
+	f([]string{filepath.Join(dir, "*.yaml")}, false)
+	f([]string{filepath.Join(dir, "rules", "*.yaml")}, false)
+	f([]string{"http://localhost:8080/rules.yaml"}, false)
+
+	// rules matching files at the store directory
+	f([]string{filepath.Join(dir, "api", "*.yaml")}, true)
+	f([]string{filepath.Join(dir, "api", "team-a.yaml")}, true)
+	f([]string{filepath.Join(dir, "*", "*.yaml")}, true)
+	f([]string{"fs://" + filepath.Join(dir, "api", "*.yaml")}, true)
+	f([]string{filepath.Join(dir, "*.yaml"), filepath.Join(dir, "api", "*")}, true)
+
+	// ruler API is disabled
+	rs = nil
+	f([]string{filepath.Join(dir, "api", "*.yaml")}, false)
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/rule"
@@ -21,6 +24,9 @@ import (
 
 var reloadAuthKey = flagutil.NewPassword("reloadAuthKey", "Auth key for /-/reload http endpoint. It must be passed via authKey query arg. It overrides -httpAuth.*")
 
+var silencesAuthKey = flagutil.NewPassword("notifier.silencesAuthKey", "Auth key for creating and expiring silences via /api/v1/silences and /api/v1/silence. "+
+	"It must be passed via authKey query arg. It overrides -httpAuth.*")
+
 var (
 	apiLinks = [][2]string{
 		// api links are relative since they can be used by external clients,
@@ -28,6 +34,7 @@ var (
 		{"api/v1/rules", "list all loaded groups and rules"},
 		{"api/v1/alerts", "list all active alerts"},
 		{fmt.Sprintf("api/v1/alert?%s=<int>&%s=<int>", paramGroupID, paramAlertID), "get alert status by group and alert ID"},
+		{"api/v1/silences", "list all silences"},
 	}
 	systemLinks = [][2]string{
 		{"flags", "command-line flags"},
@@ -45,6 +52,8 @@ var (
 
 type requestHandler struct {
 	m *manager
+	// rs is nil if ruler API is disabled
+	rs *rulerStore
 }
 
 var (
@@ -166,6 +175,29 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(data)
 		return true
+	case "/vmalert/api/v1/silences", "/api/v1/silences":
+		rh.handleSilences(w, r)
+		return true
+	case "/vmalert/api/v1/silence", "/api/v1/silence":
+		if r.Method != http.MethodDelete {
+			httpserver.Errorf(w, r, "%s", errResponse(fmt.Errorf("path %q supports only DELETE method", r.URL.Path), http.StatusMethodNotAllowed))
+			return true
+		}
+		if !httpserver.CheckAuthFlag(w, r, silencesAuthKey) {
+			return true
+		}
+		id := r.FormValue(paramSilenceID)
+		if err := notifier.ExpireSilence(id); err != nil {
+			if errors.Is(err, notifier.ErrSilenceNotFound) {
+				err = errResponse(fmt.Errorf("cannot find silence %q", id), http.StatusNotFound)
+			}
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		logger.Infof("silence %q was expired via API", id)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success"}`))
+		return true
 	case "/-/reload":
 		if !httpserver.CheckAuthFlag(w, r, reloadAuthKey) {
 			return true
@@ -176,7 +208,7 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 
 	default:
-		return false
+		return rh.handleRulerAPI(w, r)
 	}
 }
 
@@ -265,6 +297,7 @@ func (rh *requestHandler) groups(rf rulesFilter) []apiGroup {
 		return false
 	}
 
+	inh := notifier.NewInhibitor()
 	groups := make([]apiGroup, 0)
 	for _, group := range rh.m.groups {
 		if !isInList(rf.groupNames, group.Name) {
@@ -274,7 +307,7 @@ func (rh *requestHandler) groups(rf rulesFilter) []apiGroup {
 			continue
 		}
 
-		g := groupToAPI(group)
+		g := groupToAPI(group, inh)
 		// the returned list should always be non-nil
 		// https://github.com/VictoriaMetrics/VictoriaMetrics/issues/4221
 		filteredRules := make([]apiRule, 0)
@@ -324,10 +357,69 @@ type listAlertsResponse struct {
 	} `json:"data"`
 }
 
+type listSilencesResponse struct {
+	Status string                   `json:"status"`
+	Data   []notifier.SilenceStatus `json:"data"`
+}
+
+type createSilenceResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		SilenceID string `json:"silenceID"`
+	} `json:"data"`
+}
+
+func (rh *requestHandler) handleSilences(w http.ResponseWriter, r *http.Request) {
+	var resp any
+	switch r.Method {
+	case http.MethodGet:
+		resp = listSilencesResponse{
+			Status: "success",
+			Data:   notifier.ListSilences(),
+		}
+	case http.MethodPost:
+		// Read the request body before checking the auth key,
+		// since the body with form content type is consumed by the authKey query arg lookup.
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot read request body: %s", err)
+			return
+		}
+		if !httpserver.CheckAuthFlag(w, r, silencesAuthKey) {
+			return
+		}
+		var s notifier.Silence
+		if err := json.Unmarshal(data, &s); err != nil {
+			httpserver.Errorf(w, r, "cannot parse silence: %s", err)
+			return
+		}
+		id, err := notifier.AddSilence(&s)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot create silence: %s", err)
+			return
+		}
+		logger.Infof("silence %q was created via API by %q till %s", id, s.CreatedBy, s.EndsAt.Format(time.RFC3339))
+		cr := createSilenceResponse{Status: "success"}
+		cr.Data.SilenceID = id
+		resp = cr
+	default:
+		httpserver.Errorf(w, r, "%s", errResponse(fmt.Errorf("path %q supports only GET and POST methods", r.URL.Path), http.StatusMethodNotAllowed))
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		httpserver.Errorf(w, r, "failed to marshal silences: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
 func (rh *requestHandler) groupAlerts() []groupAlerts {
 	rh.m.groupsMu.RLock()
 	defer rh.m.groupsMu.RUnlock()
 
+	inh := notifier.NewInhibitor()
 	var gAlerts []groupAlerts
 	for _, g := range rh.m.groups {
 		var alerts []*apiAlert
@@ -336,11 +428,11 @@ func (rh *requestHandler) groupAlerts() []groupAlerts {
 			if !ok {
 				continue
 			}
-			alerts = append(alerts, ruleToAPIAlert(a)...)
+			alerts = append(alerts, ruleToAPIAlert(a, inh)...)
 		}
 		if len(alerts) > 0 {
 			gAlerts = append(gAlerts, groupAlerts{
-				Group:  groupToAPI(g),
+				Group:  groupToAPI(g, inh),
 				Alerts: alerts,
 			})
 		}
@@ -356,6 +448,7 @@ func (rh *requestHandler) listAlerts() ([]byte, error) {
 	defer rh.m.groupsMu.RUnlock()
 
 	lr := listAlertsResponse{Status: "success"}
+	inh := notifier.NewInhibitor()
 	lr.Data.Alerts = make([]*apiAlert, 0)
 	for _, g := range rh.m.groups {
 		for _, r := range g.Rules {
@@ -363,7 +456,7 @@ func (rh *requestHandler) listAlerts() ([]byte, error) {
 			if !ok {
 				continue
 			}
-			lr.Data.Alerts = append(lr.Data.Alerts, ruleToAPIAlert(a)...)
+			lr.Data.Alerts = append(lr.Data.Alerts, ruleToAPIAlert(a, inh)...)
 		}
 	}
 
@@ -856,187 +856,207 @@ func StreamListAlerts(qw422016 *qt422016.Writer, r *http.Request, groupAlerts []
 //line app/vmalert/web.qtpl:255
 					qw422016.N().S(`
                                   </td>
-                                  <td>`)
-//line app/vmalert/web.qtpl:257
-					streambadgeState(qw422016, ar.State)
-//line app/vmalert/web.qtpl:257
-					qw422016.N().S(`</td>
                                   <td>
                                       `)
+//line app/vmalert/web.qtpl:258
+					streambadgeState(qw422016, ar.State)
+//line app/vmalert/web.qtpl:258
+					qw422016.N().S(`
+                                      `)
 //line app/vmalert/web.qtpl:259
-					qw422016.E().S(ar.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
+					if len(ar.SilencedBy) > 0 {
+//line app/vmalert/web.qtpl:259
+						streambadgeSilenced(qw422016)
+//line app/vmalert/web.qtpl:259
+					}
 //line app/vmalert/web.qtpl:259
 					qw422016.N().S(`
                                       `)
 //line app/vmalert/web.qtpl:260
-					if ar.Restored {
+					if ar.Inhibited {
 //line app/vmalert/web.qtpl:260
-						streambadgeRestored(qw422016)
+						streambadgeInhibited(qw422016)
 //line app/vmalert/web.qtpl:260
 					}
 //line app/vmalert/web.qtpl:260
 					qw422016.N().S(`
+                                  </td>
+                                  <td>
+                                      `)
+//line app/vmalert/web.qtpl:263
+					qw422016.E().S(ar.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
+//line app/vmalert/web.qtpl:263
+					qw422016.N().S(`
+                                      `)
+//line app/vmalert/web.qtpl:264
+					if ar.Restored {
+//line app/vmalert/web.qtpl:264
+						streambadgeRestored(qw422016)
+//line app/vmalert/web.qtpl:264
+					}
+//line app/vmalert/web.qtpl:264
+					qw422016.N().S(`
                                       `)
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:265
 					if ar.Stabilizing {
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:265
 						streambadgeStabilizing(qw422016)
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:265
 					}
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:265
 					qw422016.N().S(`
                                   </td>
                                   <td>`)
-//line app/vmalert/web.qtpl:263
+//line app/vmalert/web.qtpl:267
 					qw422016.E().S(ar.Value)
-//line app/vmalert/web.qtpl:263
+//line app/vmalert/web.qtpl:267
 					qw422016.N().S(`</td>
                                   <td>
                                       <a href="`)
-//line app/vmalert/web.qtpl:265
+//line app/vmalert/web.qtpl:269
 					qw422016.E().S(prefix + ar.WebLink())
-//line app/vmalert/web.qtpl:265
+//line app/vmalert/web.qtpl:269
 					qw422016.N().S(`">Details</a>
                                   </td>
                               </tr>
                           `)
-//line app/vmalert/web.qtpl:268
+//line app/vmalert/web.qtpl:272
 				}
-//line app/vmalert/web.qtpl:268
+//line app/vmalert/web.qtpl:272
 				qw422016.N().S(`
                        </tbody>
                       </table>
                     </div>
                 `)
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:276
 			}
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:276
 			qw422016.N().S(`
             </div>
         `)
-//line app/vmalert/web.qtpl:274
+//line app/vmalert/web.qtpl:278
 		}
-//line app/vmalert/web.qtpl:274
+//line app/vmalert/web.qtpl:278
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:280
 	} else {
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:280
 		qw422016.N().S(`
         <div>
             <p>No active alerts...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:280
+//line app/vmalert/web.qtpl:284
 	}
-//line app/vmalert/web.qtpl:280
+//line app/vmalert/web.qtpl:284
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:282
+//line app/vmalert/web.qtpl:286
 	tpl.StreamFooter(qw422016, r)
-//line app/vmalert/web.qtpl:282
+//line app/vmalert/web.qtpl:286
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 }
 
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 func WriteListAlerts(qq422016 qtio422016.Writer, r *http.Request, groupAlerts []groupAlerts) {
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	StreamListAlerts(qw422016, r, groupAlerts)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 }
 
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 func ListAlerts(r *http.Request, groupAlerts []groupAlerts) string {
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	WriteListAlerts(qb422016, r, groupAlerts)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 	return qs422016
-//line app/vmalert/web.qtpl:284
+//line app/vmalert/web.qtpl:288
 }
 
-//line app/vmalert/web.qtpl:286
+//line app/vmalert/web.qtpl:290
 func StreamListTargets(qw422016 *qt422016.Writer, r *http.Request, targets map[notifier.TargetType][]notifier.Target) {
-//line app/vmalert/web.qtpl:286
+//line app/vmalert/web.qtpl:290
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:287
+//line app/vmalert/web.qtpl:291
 	tpl.StreamHeader(qw422016, r, navItems, "Notifiers", getLastConfigError())
-//line app/vmalert/web.qtpl:287
+//line app/vmalert/web.qtpl:291
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:288
+//line app/vmalert/web.qtpl:292
 	if len(targets) > 0 {
-//line app/vmalert/web.qtpl:288
+//line app/vmalert/web.qtpl:292
 		qw422016.N().S(`
          <a class="btn btn-primary" role="button" onclick="collapseAll()">Collapse All</a>
          <a class="btn btn-primary" role="button" onclick="expandAll()">Expand All</a>
 
          `)
-//line app/vmalert/web.qtpl:293
+//line app/vmalert/web.qtpl:297
 		var keys []string
 		for key := range targets {
 			keys = append(keys, string(key))
 		}
 		sort.Strings(keys)
 
-//line app/vmalert/web.qtpl:298
+//line app/vmalert/web.qtpl:302
 		qw422016.N().S(`
 
          `)
-//line app/vmalert/web.qtpl:300
+//line app/vmalert/web.qtpl:304
 		for i := range keys {
-//line app/vmalert/web.qtpl:300
+//line app/vmalert/web.qtpl:304
 			qw422016.N().S(`
            `)
-//line app/vmalert/web.qtpl:301
+//line app/vmalert/web.qtpl:305
 			typeK, ns := keys[i], targets[notifier.TargetType(keys[i])]
 			count := len(ns)
 
-//line app/vmalert/web.qtpl:303
+//line app/vmalert/web.qtpl:307
 			qw422016.N().S(`
            <div class="group-heading" data-bs-target="notifiers-`)
-//line app/vmalert/web.qtpl:304
+//line app/vmalert/web.qtpl:308
 			qw422016.E().S(typeK)
-//line app/vmalert/web.qtpl:304
+//line app/vmalert/web.qtpl:308
 			qw422016.N().S(`">
              <span class="anchor" id="group-`)
-//line app/vmalert/web.qtpl:305
+//line app/vmalert/web.qtpl:309
 			qw422016.E().S(typeK)
-//line app/vmalert/web.qtpl:305
+//line app/vmalert/web.qtpl:309
 			qw422016.N().S(`"></span>
              <a href="#group-`)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.E().S(typeK)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.E().S(typeK)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.N().D(count)
-//line app/vmalert/web.qtpl:306
+//line app/vmalert/web.qtpl:310
 			qw422016.N().S(`)</a>
          </div>
          <div class="collapse show" id="notifiers-`)
-//line app/vmalert/web.qtpl:308
+//line app/vmalert/web.qtpl:312
 			qw422016.E().S(typeK)
-//line app/vmalert/web.qtpl:308
+//line app/vmalert/web.qtpl:312
 			qw422016.N().S(`">
              <table class="table table-striped table-hover table-sm">
                  <thead>
@@ -1047,119 +1067,119 @@ func StreamListTargets(qw422016 *qt422016.Writer, r *http.Request, targets map[n
                  </thead>
                  <tbody>
                  `)
-//line app/vmalert/web.qtpl:317
+//line app/vmalert/web.qtpl:321
 			for _, n := range ns {
-//line app/vmalert/web.qtpl:317
+//line app/vmalert/web.qtpl:321
 				qw422016.N().S(`
                      <tr>
                          <td>
                               `)
-//line app/vmalert/web.qtpl:320
+//line app/vmalert/web.qtpl:324
 				for _, l := range n.Labels.GetLabels() {
-//line app/vmalert/web.qtpl:320
+//line app/vmalert/web.qtpl:324
 					qw422016.N().S(`
                                       <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:321
+//line app/vmalert/web.qtpl:325
 					qw422016.E().S(l.Name)
-//line app/vmalert/web.qtpl:321
+//line app/vmalert/web.qtpl:325
 					qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:321
+//line app/vmalert/web.qtpl:325
 					qw422016.E().S(l.Value)
-//line app/vmalert/web.qtpl:321
+//line app/vmalert/web.qtpl:325
 					qw422016.N().S(`</span>
                               `)
-//line app/vmalert/web.qtpl:322
+//line app/vmalert/web.qtpl:326
 				}
-//line app/vmalert/web.qtpl:322
+//line app/vmalert/web.qtpl:326
 				qw422016.N().S(`
                           </td>
                          <td>`)
-//line app/vmalert/web.qtpl:324
+//line app/vmalert/web.qtpl:328
 				qw422016.E().S(n.Notifier.Addr())
-//line app/vmalert/web.qtpl:324
+//line app/vmalert/web.qtpl:328
 				qw422016.N().S(`</td>
                      </tr>
                  `)
-//line app/vmalert/web.qtpl:326
+//line app/vmalert/web.qtpl:330
 			}
-//line app/vmalert/web.qtpl:326
+//line app/vmalert/web.qtpl:330
 			qw422016.N().S(`
               </tbody>
              </table>
          </div>
      `)
-//line app/vmalert/web.qtpl:330
+//line app/vmalert/web.qtpl:334
 		}
-//line app/vmalert/web.qtpl:330
+//line app/vmalert/web.qtpl:334
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:332
+//line app/vmalert/web.qtpl:336
 	} else {
-//line app/vmalert/web.qtpl:332
+//line app/vmalert/web.qtpl:336
 		qw422016.N().S(`
         <div>
             <p>No targets...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:336
+//line app/vmalert/web.qtpl:340
 	}
-//line app/vmalert/web.qtpl:336
+//line app/vmalert/web.qtpl:340
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:338
+//line app/vmalert/web.qtpl:342
 	tpl.StreamFooter(qw422016, r)
-//line app/vmalert/web.qtpl:338
+//line app/vmalert/web.qtpl:342
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 }
 
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 func WriteListTargets(qq422016 qtio422016.Writer, r *http.Request, targets map[notifier.TargetType][]notifier.Target) {
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	StreamListTargets(qw422016, r, targets)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 }
 
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 func ListTargets(r *http.Request, targets map[notifier.TargetType][]notifier.Target) string {
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	WriteListTargets(qb422016, r, targets)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 	return qs422016
-//line app/vmalert/web.qtpl:340
+//line app/vmalert/web.qtpl:344
 }
 
-//line app/vmalert/web.qtpl:342
+//line app/vmalert/web.qtpl:346
 func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
-//line app/vmalert/web.qtpl:342
+//line app/vmalert/web.qtpl:346
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:343
+//line app/vmalert/web.qtpl:347
 	prefix := utils.Prefix(r.URL.Path)
 
-//line app/vmalert/web.qtpl:343
+//line app/vmalert/web.qtpl:347
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:344
+//line app/vmalert/web.qtpl:348
 	tpl.StreamHeader(qw422016, r, navItems, "", getLastConfigError())
-//line app/vmalert/web.qtpl:344
+//line app/vmalert/web.qtpl:348
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:346
+//line app/vmalert/web.qtpl:350
 	var labelKeys []string
 	for k := range alert.Labels {
 		labelKeys = append(labelKeys, k)
@@ -1172,29 +1192,51 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
 	}
 	sort.Strings(annotationKeys)
 
-//line app/vmalert/web.qtpl:357
+//line app/vmalert/web.qtpl:361
 	qw422016.N().S(`
     <div class="display-6 pb-3 mb-3">Alert: `)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	qw422016.E().S(alert.Name)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	qw422016.N().S(`<span class="ms-2 badge `)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	if alert.State == "firing" {
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 		qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	} else {
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 		qw422016.N().S(` bg-warning text-dark`)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	}
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:358
+//line app/vmalert/web.qtpl:362
 	qw422016.E().S(alert.State)
-//line app/vmalert/web.qtpl:358
-	qw422016.N().S(`</span></div>
+//line app/vmalert/web.qtpl:362
+	qw422016.N().S(`</span>`)
+//line app/vmalert/web.qtpl:362
+	if len(alert.SilencedBy) > 0 {
+//line app/vmalert/web.qtpl:362
+		qw422016.N().S(`<span class="ms-2">`)
+//line app/vmalert/web.qtpl:362
+		streambadgeSilenced(qw422016)
+//line app/vmalert/web.qtpl:362
+		qw422016.N().S(`</span>`)
+//line app/vmalert/web.qtpl:362
+	}
+//line app/vmalert/web.qtpl:362
+	if alert.Inhibited {
+//line app/vmalert/web.qtpl:362
+		qw422016.N().S(`<span class="ms-2">`)
+//line app/vmalert/web.qtpl:362
+		streambadgeInhibited(qw422016)
+//line app/vmalert/web.qtpl:362
+		qw422016.N().S(`</span>`)
+//line app/vmalert/web.qtpl:362
+	}
+//line app/vmalert/web.qtpl:362
+	qw422016.N().S(`</div>
     <div class="container border-bottom p-2">
       <div class="row">
         <div class="col-2">
@@ -1202,9 +1244,9 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
           `)
-//line app/vmalert/web.qtpl:365
+//line app/vmalert/web.qtpl:369
 	qw422016.E().S(alert.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
-//line app/vmalert/web.qtpl:365
+//line app/vmalert/web.qtpl:369
 	qw422016.N().S(`
         </div>
       </div>
@@ -1216,9 +1258,9 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
           <code><pre>`)
-//line app/vmalert/web.qtpl:375
+//line app/vmalert/web.qtpl:379
 	qw422016.E().S(alert.Expression)
-//line app/vmalert/web.qtpl:375
+//line app/vmalert/web.qtpl:379
 	qw422016.N().S(`</pre></code>
         </div>
       </div>
@@ -1230,23 +1272,23 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:385
+//line app/vmalert/web.qtpl:389
 	for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:385
+//line app/vmalert/web.qtpl:389
 		qw422016.N().S(`
                 <span class="m-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:386
+//line app/vmalert/web.qtpl:390
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:386
+//line app/vmalert/web.qtpl:390
 		qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:386
+//line app/vmalert/web.qtpl:390
 		qw422016.E().S(alert.Labels[k])
-//line app/vmalert/web.qtpl:386
+//line app/vmalert/web.qtpl:390
 		qw422016.N().S(`</span>
           `)
-//line app/vmalert/web.qtpl:387
+//line app/vmalert/web.qtpl:391
 	}
-//line app/vmalert/web.qtpl:387
+//line app/vmalert/web.qtpl:391
 	qw422016.N().S(`
         </div>
       </div>
@@ -1258,24 +1300,24 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:397
+//line app/vmalert/web.qtpl:401
 	for _, k := range annotationKeys {
-//line app/vmalert/web.qtpl:397
+//line app/vmalert/web.qtpl:401
 		qw422016.N().S(`
                 <b>`)
-//line app/vmalert/web.qtpl:398
+//line app/vmalert/web.qtpl:402
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:398
+//line app/vmalert/web.qtpl:402
 		qw422016.N().S(`:</b><br>
                 <p>`)
-//line app/vmalert/web.qtpl:399
+//line app/vmalert/web.qtpl:403
 		qw422016.E().S(alert.Annotations[k])
-//line app/vmalert/web.qtpl:399
+//line app/vmalert/web.qtpl:403
 		qw422016.N().S(`</p>
           `)
-//line app/vmalert/web.qtpl:400
+//line app/vmalert/web.qtpl:404
 	}
-//line app/vmalert/web.qtpl:400
+//line app/vmalert/web.qtpl:404
 	qw422016.N().S(`
         </div>
       </div>
@@ -1287,17 +1329,17 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
            <a target="_blank" href="`)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.E().S(prefix)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.N().S(`groups#group-`)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:410
+//line app/vmalert/web.qtpl:414
 	qw422016.N().S(`</a>
         </div>
       </div>
@@ -1309,66 +1351,66 @@ func StreamAlert(qw422016 *qt422016.Writer, r *http.Request, alert *apiAlert) {
         </div>
         <div class="col">
            <a target="_blank" href="`)
-//line app/vmalert/web.qtpl:420
+//line app/vmalert/web.qtpl:424
 	qw422016.E().S(alert.SourceLink)
-//line app/vmalert/web.qtpl:420
+//line app/vmalert/web.qtpl:424
 	qw422016.N().S(`">Link</a>
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:424
+//line app/vmalert/web.qtpl:428
 	tpl.StreamFooter(qw422016, r)
-//line app/vmalert/web.qtpl:424
+//line app/vmalert/web.qtpl:428
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 }
 
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 func WriteAlert(qq422016 qtio422016.Writer, r *http.Request, alert *apiAlert) {
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	StreamAlert(qw422016, r, alert)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 }
 
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 func Alert(r *http.Request, alert *apiAlert) string {
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	WriteAlert(qb422016, r, alert)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 	return qs422016
-//line app/vmalert/web.qtpl:426
+//line app/vmalert/web.qtpl:430
 }
 
-//line app/vmalert/web.qtpl:429
+//line app/vmalert/web.qtpl:433
 func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule) {
-//line app/vmalert/web.qtpl:429
+//line app/vmalert/web.qtpl:433
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:430
+//line app/vmalert/web.qtpl:434
 	prefix := utils.Prefix(r.URL.Path)
 
-//line app/vmalert/web.qtpl:430
+//line app/vmalert/web.qtpl:434
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:431
+//line app/vmalert/web.qtpl:435
 	tpl.StreamHeader(qw422016, r, navItems, "", getLastConfigError())
-//line app/vmalert/web.qtpl:431
+//line app/vmalert/web.qtpl:435
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:433
+//line app/vmalert/web.qtpl:437
 	var labelKeys []string
 	for k := range rule.Labels {
 		labelKeys = append(labelKeys, k)
@@ -1392,28 +1434,28 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
 		}
 	}
 
-//line app/vmalert/web.qtpl:456
+//line app/vmalert/web.qtpl:460
 	qw422016.N().S(`
     <div class="display-6 pb-3 mb-3">Rule: `)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	qw422016.E().S(rule.Name)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	qw422016.N().S(`<span class="ms-2 badge `)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	if rule.Health != "ok" {
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 		qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	} else {
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 		qw422016.N().S(` bg-success text-dark`)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	}
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	qw422016.E().S(rule.Health)
-//line app/vmalert/web.qtpl:457
+//line app/vmalert/web.qtpl:461
 	qw422016.N().S(`</span></div>
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1422,17 +1464,17 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
           <code><pre>`)
-//line app/vmalert/web.qtpl:464
+//line app/vmalert/web.qtpl:468
 	qw422016.E().S(rule.Query)
-//line app/vmalert/web.qtpl:464
+//line app/vmalert/web.qtpl:468
 	qw422016.N().S(`</pre></code>
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:468
+//line app/vmalert/web.qtpl:472
 	if rule.Type == "alerting" {
-//line app/vmalert/web.qtpl:468
+//line app/vmalert/web.qtpl:472
 		qw422016.N().S(`
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1441,17 +1483,17 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
          `)
-//line app/vmalert/web.qtpl:475
+//line app/vmalert/web.qtpl:479
 		qw422016.E().V(rule.Duration)
-//line app/vmalert/web.qtpl:475
+//line app/vmalert/web.qtpl:479
 		qw422016.N().S(` seconds
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:479
+//line app/vmalert/web.qtpl:483
 		if rule.KeepFiringFor > 0 {
-//line app/vmalert/web.qtpl:479
+//line app/vmalert/web.qtpl:483
 			qw422016.N().S(`
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1460,22 +1502,22 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
          `)
-//line app/vmalert/web.qtpl:486
+//line app/vmalert/web.qtpl:490
 			qw422016.E().V(rule.KeepFiringFor)
-//line app/vmalert/web.qtpl:486
+//line app/vmalert/web.qtpl:490
 			qw422016.N().S(` seconds
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:490
+//line app/vmalert/web.qtpl:494
 		}
-//line app/vmalert/web.qtpl:490
+//line app/vmalert/web.qtpl:494
 		qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:491
+//line app/vmalert/web.qtpl:495
 	}
-//line app/vmalert/web.qtpl:491
+//line app/vmalert/web.qtpl:495
 	qw422016.N().S(`
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1484,31 +1526,31 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
           `)
-//line app/vmalert/web.qtpl:498
+//line app/vmalert/web.qtpl:502
 	for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:498
+//line app/vmalert/web.qtpl:502
 		qw422016.N().S(`
                 <span class="m-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:499
+//line app/vmalert/web.qtpl:503
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:499
+//line app/vmalert/web.qtpl:503
 		qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:499
+//line app/vmalert/web.qtpl:503
 		qw422016.E().S(rule.Labels[k])
-//line app/vmalert/web.qtpl:499
+//line app/vmalert/web.qtpl:503
 		qw422016.N().S(`</span>
           `)
-//line app/vmalert/web.qtpl:500
+//line app/vmalert/web.qtpl:504
 	}
-//line app/vmalert/web.qtpl:500
+//line app/vmalert/web.qtpl:504
 	qw422016.N().S(`
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:504
+//line app/vmalert/web.qtpl:508
 	if rule.Type == "alerting" {
-//line app/vmalert/web.qtpl:504
+//line app/vmalert/web.qtpl:508
 		qw422016.N().S(`
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1517,24 +1559,24 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
           `)
-//line app/vmalert/web.qtpl:511
+//line app/vmalert/web.qtpl:515
 		for _, k := range annotationKeys {
-//line app/vmalert/web.qtpl:511
+//line app/vmalert/web.qtpl:515
 			qw422016.N().S(`
                 <b>`)
-//line app/vmalert/web.qtpl:512
+//line app/vmalert/web.qtpl:516
 			qw422016.E().S(k)
-//line app/vmalert/web.qtpl:512
+//line app/vmalert/web.qtpl:516
 			qw422016.N().S(`:</b><br>
                 <p>`)
-//line app/vmalert/web.qtpl:513
+//line app/vmalert/web.qtpl:517
 			qw422016.E().S(rule.Annotations[k])
-//line app/vmalert/web.qtpl:513
+//line app/vmalert/web.qtpl:517
 			qw422016.N().S(`</p>
           `)
-//line app/vmalert/web.qtpl:514
+//line app/vmalert/web.qtpl:518
 		}
-//line app/vmalert/web.qtpl:514
+//line app/vmalert/web.qtpl:518
 		qw422016.N().S(`
         </div>
       </div>
@@ -1546,17 +1588,17 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:524
+//line app/vmalert/web.qtpl:528
 		qw422016.E().V(rule.Debug)
-//line app/vmalert/web.qtpl:524
+//line app/vmalert/web.qtpl:528
 		qw422016.N().S(`
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:528
+//line app/vmalert/web.qtpl:532
 	}
-//line app/vmalert/web.qtpl:528
+//line app/vmalert/web.qtpl:532
 	qw422016.N().S(`
     <div class="container border-bottom p-2">
       <div class="row">
@@ -1565,17 +1607,17 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
         </div>
         <div class="col">
            <a target="_blank" href="`)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.E().S(prefix)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.N().S(`groups#group-`)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.E().S(rule.GroupID)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.E().S(rule.GroupID)
-//line app/vmalert/web.qtpl:535
+//line app/vmalert/web.qtpl:539
 	qw422016.N().S(`</a>
         </div>
       </div>
@@ -1583,9 +1625,9 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
 
     <br>
     `)
-//line app/vmalert/web.qtpl:541
+//line app/vmalert/web.qtpl:545
 	if seriesFetchedWarning {
-//line app/vmalert/web.qtpl:541
+//line app/vmalert/web.qtpl:545
 		qw422016.N().S(`
     <div class="alert alert-warning" role="alert">
        <strong>Warning:</strong> some of updates have "Series fetched" equal to 0.<br>
@@ -1599,18 +1641,18 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
        See more details about this detection <a target="_blank" href="https://github.com/VictoriaMetrics/VictoriaMetrics/issues/4039">here</a>.
     </div>
     `)
-//line app/vmalert/web.qtpl:553
+//line app/vmalert/web.qtpl:557
 	}
-//line app/vmalert/web.qtpl:553
+//line app/vmalert/web.qtpl:557
 	qw422016.N().S(`
     <div class="display-6 pb-3">Last `)
-//line app/vmalert/web.qtpl:554
+//line app/vmalert/web.qtpl:558
 	qw422016.N().D(len(rule.Updates))
-//line app/vmalert/web.qtpl:554
+//line app/vmalert/web.qtpl:558
 	qw422016.N().S(`/`)
-//line app/vmalert/web.qtpl:554
+//line app/vmalert/web.qtpl:558
 	qw422016.N().D(rule.MaxUpdates)
-//line app/vmalert/web.qtpl:554
+//line app/vmalert/web.qtpl:558
 	qw422016.N().S(` updates</span>:</div>
         <table class="table table-striped table-hover table-sm">
             <thead>
@@ -1618,13 +1660,13 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
                     <th scope="col" title="The time when event was created">Updated at</th>
                     <th scope="col" style="width: 10%" class="text-center" title="How many samples were returned">Samples</th>
                     `)
-//line app/vmalert/web.qtpl:560
+//line app/vmalert/web.qtpl:564
 	if seriesFetchedEnabled {
-//line app/vmalert/web.qtpl:560
+//line app/vmalert/web.qtpl:564
 		qw422016.N().S(`<th scope="col" style="width: 10%" class="text-center" title="How many series were scanned by datasource during the evaluation">Series fetched</th>`)
-//line app/vmalert/web.qtpl:560
+//line app/vmalert/web.qtpl:564
 	}
-//line app/vmalert/web.qtpl:560
+//line app/vmalert/web.qtpl:564
 	qw422016.N().S(`
                     <th scope="col" style="width: 10%" class="text-center" title="How many seconds request took">Duration</th>
                     <th scope="col" class="text-center" title="Time used for rule execution">Executed at</th>
@@ -1634,285 +1676,355 @@ func StreamRuleDetails(qw422016 *qt422016.Writer, r *http.Request, rule apiRule)
             <tbody>
 
      `)
-//line app/vmalert/web.qtpl:568
+//line app/vmalert/web.qtpl:572
 	for _, u := range rule.Updates {
-//line app/vmalert/web.qtpl:568
+//line app/vmalert/web.qtpl:572
 		qw422016.N().S(`
              <tr`)
-//line app/vmalert/web.qtpl:569
+//line app/vmalert/web.qtpl:573
 		if u.Err != nil {
-//line app/vmalert/web.qtpl:569
+//line app/vmalert/web.qtpl:573
 			qw422016.N().S(` class="alert-danger"`)
-//line app/vmalert/web.qtpl:569
+//line app/vmalert/web.qtpl:573
 		}
-//line app/vmalert/web.qtpl:569
+//line app/vmalert/web.qtpl:573
 		qw422016.N().S(`>
                  <td>
                     <span class="badge bg-primary rounded-pill me-3" title="Updated at">`)
-//line app/vmalert/web.qtpl:571
+//line app/vmalert/web.qtpl:575
 		qw422016.E().S(u.Time.Format(time.RFC3339))
-//line app/vmalert/web.qtpl:571
+//line app/vmalert/web.qtpl:575
 		qw422016.N().S(`</span>
                  </td>
                  <td class="text-center">`)
-//line app/vmalert/web.qtpl:573
+//line app/vmalert/web.qtpl:577
 		qw422016.N().D(u.Samples)
-//line app/vmalert/web.qtpl:573
+//line app/vmalert/web.qtpl:577
 		qw422016.N().S(`</td>
                  `)
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 		if seriesFetchedEnabled {
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 			qw422016.N().S(`<td class="text-center">`)
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 			if u.SeriesFetched != nil {
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 				qw422016.N().D(*u.SeriesFetched)
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 			}
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 			qw422016.N().S(`</td>`)
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 		}
-//line app/vmalert/web.qtpl:574
+//line app/vmalert/web.qtpl:578
 		qw422016.N().S(`
                  <td class="text-center">`)
-//line app/vmalert/web.qtpl:575
+//line app/vmalert/web.qtpl:579
 		qw422016.N().FPrec(u.Duration.Seconds(), 3)
-//line app/vmalert/web.qtpl:575
+//line app/vmalert/web.qtpl:579
 		qw422016.N().S(`s</td>
                  <td class="text-center">`)
-//line app/vmalert/web.qtpl:576
+//line app/vmalert/web.qtpl:580
 		qw422016.E().S(u.At.Format(time.RFC3339))
-//line app/vmalert/web.qtpl:576
+//line app/vmalert/web.qtpl:580
 		qw422016.N().S(`</td>
                  <td>
                     <textarea class="curl-area" rows="1" onclick="this.focus();this.select()">`)
-//line app/vmalert/web.qtpl:578
+//line app/vmalert/web.qtpl:582
 		qw422016.E().S(u.Curl)
-//line app/vmalert/web.qtpl:578
+//line app/vmalert/web.qtpl:582
 		qw422016.N().S(`</textarea>
                 </td>
              </tr>
           </li>
           `)
-//line app/vmalert/web.qtpl:582
+//line app/vmalert/web.qtpl:586
 		if u.Err != nil {
-//line app/vmalert/web.qtpl:582
+//line app/vmalert/web.qtpl:586
 			qw422016.N().S(`
              <tr`)
-//line app/vmalert/web.qtpl:583
+//line app/vmalert/web.qtpl:587
 			if u.Err != nil {
-//line app/vmalert/web.qtpl:583
+//line app/vmalert/web.qtpl:587
 				qw422016.N().S(` class="alert-danger"`)
-//line app/vmalert/web.qtpl:583
+//line app/vmalert/web.qtpl:587
 			}
-//line app/vmalert/web.qtpl:583
+//line app/vmalert/web.qtpl:587
 			qw422016.N().S(`>
                <td colspan="`)
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 			if seriesFetchedEnabled {
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 				qw422016.N().S(`6`)
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 			} else {
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 				qw422016.N().S(`5`)
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 			}
-//line app/vmalert/web.qtpl:584
+//line app/vmalert/web.qtpl:588
 			qw422016.N().S(`">
                    <span class="alert-danger">`)
-//line app/vmalert/web.qtpl:585
+//line app/vmalert/web.qtpl:589
 			qw422016.E().V(u.Err)
-//line app/vmalert/web.qtpl:585
+//line app/vmalert/web.qtpl:589
 			qw422016.N().S(`</span>
                </td>
              </tr>
           `)
-//line app/vmalert/web.qtpl:588
+//line app/vmalert/web.qtpl:592
 		}
-//line app/vmalert/web.qtpl:588
+//line app/vmalert/web.qtpl:592
 		qw422016.N().S(`
      `)
-//line app/vmalert/web.qtpl:589
+//line app/vmalert/web.qtpl:593
 	}
-//line app/vmalert/web.qtpl:589
+//line app/vmalert/web.qtpl:593
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:591
+//line app/vmalert/web.qtpl:595
 	tpl.StreamFooter(qw422016, r)
-//line app/vmalert/web.qtpl:591
+//line app/vmalert/web.qtpl:595
 	qw422016.N().S(`
 `)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 }
 
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 func WriteRuleDetails(qq422016 qtio422016.Writer, r *http.Request, rule apiRule) {
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	StreamRuleDetails(qw422016, r, rule)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 }
 
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 func RuleDetails(r *http.Request, rule apiRule) string {
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	WriteRuleDetails(qb422016, r, rule)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 	return qs422016
-//line app/vmalert/web.qtpl:592
+//line app/vmalert/web.qtpl:596
 }
 
-//line app/vmalert/web.qtpl:596
+//line app/vmalert/web.qtpl:600
 func streambadgeState(qw422016 *qt422016.Writer, state string) {
-//line app/vmalert/web.qtpl:596
+//line app/vmalert/web.qtpl:600
 	qw422016.N().S(`
 `)
-//line app/vmalert/web.qtpl:598
+//line app/vmalert/web.qtpl:602
 	badgeClass := "bg-warning text-dark"
 	if state == "firing" {
 		badgeClass = "bg-danger"
 	}
 
-//line app/vmalert/web.qtpl:602
+//line app/vmalert/web.qtpl:606
 	qw422016.N().S(`
 <span class="badge `)
-//line app/vmalert/web.qtpl:603
+//line app/vmalert/web.qtpl:607
 	qw422016.E().S(badgeClass)
-//line app/vmalert/web.qtpl:603
+//line app/vmalert/web.qtpl:607
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:603
+//line app/vmalert/web.qtpl:607
 	qw422016.E().S(state)
-//line app/vmalert/web.qtpl:603
+//line app/vmalert/web.qtpl:607
 	qw422016.N().S(`</span>
 `)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 }
 
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 func writebadgeState(qq422016 qtio422016.Writer, state string) {
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	streambadgeState(qw422016, state)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 }
 
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 func badgeState(state string) string {
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	writebadgeState(qb422016, state)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 	return qs422016
-//line app/vmalert/web.qtpl:604
+//line app/vmalert/web.qtpl:608
 }
 
-//line app/vmalert/web.qtpl:606
+//line app/vmalert/web.qtpl:610
 func streambadgeRestored(qw422016 *qt422016.Writer) {
-//line app/vmalert/web.qtpl:606
+//line app/vmalert/web.qtpl:610
 	qw422016.N().S(`
 <span class="badge bg-warning text-dark" title="Alert state was restored after the service restart from remote storage">restored</span>
 `)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 }
 
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 func writebadgeRestored(qq422016 qtio422016.Writer) {
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	streambadgeRestored(qw422016)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 }
 
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 func badgeRestored() string {
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	writebadgeRestored(qb422016)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 	return qs422016
-//line app/vmalert/web.qtpl:608
+//line app/vmalert/web.qtpl:612
 }
 
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:614
+func streambadgeSilenced(qw422016 *qt422016.Writer) {
+//line app/vmalert/web.qtpl:614
+	qw422016.N().S(`
+<span class="badge bg-secondary" title="Notifications for this alert are muted via silence">silenced</span>
+`)
+//line app/vmalert/web.qtpl:616
+}
+
+//line app/vmalert/web.qtpl:616
+func writebadgeSilenced(qq422016 qtio422016.Writer) {
+//line app/vmalert/web.qtpl:616
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmalert/web.qtpl:616
+	streambadgeSilenced(qw422016)
+//line app/vmalert/web.qtpl:616
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmalert/web.qtpl:616
+}
+
+//line app/vmalert/web.qtpl:616
+func badgeSilenced() string {
+//line app/vmalert/web.qtpl:616
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmalert/web.qtpl:616
+	writebadgeSilenced(qb422016)
+//line app/vmalert/web.qtpl:616
+	qs422016 := string(qb422016.B)
+//line app/vmalert/web.qtpl:616
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmalert/web.qtpl:616
+	return qs422016
+//line app/vmalert/web.qtpl:616
+}
+
+//line app/vmalert/web.qtpl:618
+func streambadgeInhibited(qw422016 *qt422016.Writer) {
+//line app/vmalert/web.qtpl:618
+	qw422016.N().S(`
+<span class="badge bg-secondary" title="Notifications for this alert are muted via inhibit rule">inhibited</span>
+`)
+//line app/vmalert/web.qtpl:620
+}
+
+//line app/vmalert/web.qtpl:620
+func writebadgeInhibited(qq422016 qtio422016.Writer) {
+//line app/vmalert/web.qtpl:620
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmalert/web.qtpl:620
+	streambadgeInhibited(qw422016)
+//line app/vmalert/web.qtpl:620
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmalert/web.qtpl:620
+}
+
+//line app/vmalert/web.qtpl:620
+func badgeInhibited() string {
+//line app/vmalert/web.qtpl:620
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmalert/web.qtpl:620
+	writebadgeInhibited(qb422016)
+//line app/vmalert/web.qtpl:620
+	qs422016 := string(qb422016.B)
+//line app/vmalert/web.qtpl:620
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmalert/web.qtpl:620
+	return qs422016
+//line app/vmalert/web.qtpl:620
+}
+
+//line app/vmalert/web.qtpl:622
 func streambadgeStabilizing(qw422016 *qt422016.Writer) {
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:622
 	qw422016.N().S(`
 <span class="badge bg-warning text-dark" title="This firing state is kept because of `)
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:622
 	qw422016.N().S("`")
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:622
 	qw422016.N().S(`keep_firing_for`)
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:622
 	qw422016.N().S("`")
-//line app/vmalert/web.qtpl:610
+//line app/vmalert/web.qtpl:622
 	qw422016.N().S(`">stabilizing</span>
 `)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 }
 
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 func writebadgeStabilizing(qq422016 qtio422016.Writer) {
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	streambadgeStabilizing(qw422016)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 }
 
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 func badgeStabilizing() string {
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	writebadgeStabilizing(qb422016)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 	return qs422016
-//line app/vmalert/web.qtpl:612
+//line app/vmalert/web.qtpl:624
 }
 
-//line app/vmalert/web.qtpl:614
+//line app/vmalert/web.qtpl:626
 func streamseriesFetchedWarn(qw422016 *qt422016.Writer, r apiRule) {
-//line app/vmalert/web.qtpl:614
+//line app/vmalert/web.qtpl:626
 	qw422016.N().S(`
 `)
-//line app/vmalert/web.qtpl:615
+//line app/vmalert/web.qtpl:627
 	if isNoMatch(r) {
-//line app/vmalert/web.qtpl:615
+//line app/vmalert/web.qtpl:627
 		qw422016.N().S(`
 <svg xmlns="http://www.w3.org/2000/svg"
     data-bs-toggle="tooltip"
@@ -1923,41 +2035,41 @@ func streamseriesFetchedWarn(qw422016 *qt422016.Writer, r apiRule) {
        <path d="M8 16A8 8 0 1 0 8 0a8 8 0 0 0 0 16zm.93-9.412-1 4.705c-.07.34.029.533.304.533.194 0 .487-.07.686-.246l-.088.416c-.287.346-.92.598-1.465.598-.703 0-1.002-.422-.808-1.319l.738-3.468c.064-.293.006-.399-.287-.47l-.451-.081.082-.381 2.29-.287zM8 5.5a1 1 0 1 1 0-2 1 1 0 0 1 0 2z"/>
 </svg>
 `)
-//line app/vmalert/web.qtpl:624
+//line app/vmalert/web.qtpl:636
 	}
-//line app/vmalert/web.qtpl:624
+//line app/vmalert/web.qtpl:636
 	qw422016.N().S(`
 `)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 }
 
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 func writeseriesFetchedWarn(qq422016 qtio422016.Writer, r apiRule) {
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	streamseriesFetchedWarn(qw422016, r)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 }
 
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 func seriesFetchedWarn(r apiRule) string {
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	writeseriesFetchedWarn(qb422016, r)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 	return qs422016
-//line app/vmalert/web.qtpl:625
+//line app/vmalert/web.qtpl:637
 }
 
-//line app/vmalert/web.qtpl:628
+//line app/vmalert/web.qtpl:640
 func isNoMatch(r apiRule) bool {
 	return r.LastSamples == 0 && r.LastSeriesFetched != nil && *r.LastSeriesFetched == 0
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -69,13 +70,13 @@ func TestHandler(t *testing.T) {
 	})
 
 	t.Run("/vmalert/rule", func(t *testing.T) {
-		a := ruleToAPI(ar)
+		a := ruleToAPI(ar, notifier.NewInhibitor())
 		getResp(t, ts.URL+"/vmalert/"+a.WebLink(), nil, 200)
-		r := ruleToAPI(rr)
+		r := ruleToAPI(rr, notifier.NewInhibitor())
 		getResp(t, ts.URL+"/vmalert/"+r.WebLink(), nil, 200)
 	})
 	t.Run("/vmalert/alert", func(t *testing.T) {
-		alerts := ruleToAPIAlert(ar)
+		alerts := ruleToAPIAlert(ar, notifier.NewInhibitor())
 		for _, a := range alerts {
 			getResp(t, ts.URL+"/vmalert/"+a.WebLink(), nil, 200)
 		}
@@ -102,7 +103,7 @@ func TestHandler(t *testing.T) {
 		}
 	})
 	t.Run("/api/v1/alert?alertID&groupID", func(t *testing.T) {
-		expAlert := newAlertAPI(ar, ar.GetAlerts()[0])
+		expAlert := newAlertAPI(ar, ar.GetAlerts()[0], notifier.NewInhibitor())
 		alert := &apiAlert{}
 		getResp(t, ts.URL+"/"+expAlert.APILink(), alert, 200)
 		if !reflect.DeepEqual(alert, expAlert) {
@@ -145,7 +146,7 @@ func TestHandler(t *testing.T) {
 		}
 	})
 	t.Run("/api/v1/rule?ruleID&groupID", func(t *testing.T) {
-		expRule := ruleToAPI(ar)
+		expRule := ruleToAPI(ar, notifier.NewInhibitor())
 		gotRule := apiRule{}
 		getResp(t, ts.URL+"/"+expRule.APILink(), &gotRule, 200)
 
@@ -312,3 +313,104 @@ func TestEmptyResponse(t *testing.T) {
 		}
 	})
 }
+
+func TestSilences(t *testing.T) {
+	fq := &datasource.FakeQuerier{}
+	fq.Add(datasource.Metric{
+		Values: []float64{1}, Timestamps: []int64{0},
+	})
+	g := &rule.Group{
+		Name:        "group",
+		File:        "rules.yaml",
+		Concurrency: 1,
+	}
+	ar := rule.NewAlertingRule(fq, g, config.Rule{ID: 0, Alert: "alert"})
+	g.Rules = []rule.Rule{ar}
+	g.ExecOnce(context.Background(), func() []notifier.Notifier { return nil }, nil, time.Time{})
+
+	rh := &requestHandler{m: &manager{groups: map[uint64]*rule.Group{
+		g.ID(): g,
+	}}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { rh.handler(w, r) }))
+	defer ts.Close()
+
+	do := func(method, url, body string, to any, code int) {
+		t.Helper()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		defer resp.Body.Close()
+		if code != resp.StatusCode {
+			t.Fatalf("unexpected status code %d want %d", resp.StatusCode, code)
+		}
+		if to != nil {
+			if err = json.NewDecoder(resp.Body).Decode(to); err != nil {
+				t.Fatalf("unexpected err %s", err)
+			}
+		}
+	}
+	silencedBy := func() []string {
+		t.Helper()
+		lr := listAlertsResponse{}
+		do(http.MethodGet, ts.URL+"/api/v1/alerts", "", &lr, http.StatusOK)
+		if len(lr.Data.Alerts) != 1 {
+			t.Fatalf("expected 1 alert got %d", len(lr.Data.Alerts))
+		}
+		return lr.Data.Alerts[0].SilencedBy
+	}
+
+	do(http.MethodPost, ts.URL+"/api/v1/silences", `{"matchers":[]}`, nil, http.StatusBadRequest)
+
+	cr := createSilenceResponse{}
+	body := fmt.Sprintf(`{"matchers":[{"name":"alertname","value":"alert"}],"endsAt":%q,"createdBy":"test"}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	do(http.MethodPost, ts.URL+"/vmalert/api/v1/silences", body, &cr, http.StatusOK)
+	if cr.Data.SilenceID == "" {
+		t.Fatalf("expected non-empty silence ID")
+	}
+	if ids := silencedBy(); len(ids) != 1 || ids[0] != cr.Data.SilenceID {
+		t.Fatalf("expected alert to be silenced by %q; got %v", cr.Data.SilenceID, ids)
+	}
+
+	lr := listSilencesResponse{}
+	do(http.MethodGet, ts.URL+"/api/v1/silences", "", &lr, http.StatusOK)
+	if len(lr.Data) == 0 || lr.Data[0].ID != cr.Data.SilenceID || lr.Data[0].State != "active" {
+		t.Fatalf("unexpected list of silences: %+v", lr.Data)
+	}
+
+	do(http.MethodDelete, ts.URL+"/api/v1/silence?id=unknown", "", nil, http.StatusNotFound)
+	do(http.MethodDelete, ts.URL+"/api/v1/silence?id="+cr.Data.SilenceID, "", nil, http.StatusOK)
+	if ids := silencedBy(); len(ids) != 0 {
+		t.Fatalf("expected alert not to be silenced; got %v", ids)
+	}
+
+	// modifications must be protected by -notifier.silencesAuthKey
+	if err := silencesAuthKey.Set("secret"); err != nil {
+		t.Fatalf("cannot set -notifier.silencesAuthKey: %s", err)
+	}
+	defer func() {
+		if err := silencesAuthKey.Set(""); err != nil {
+			t.Fatalf("cannot reset -notifier.silencesAuthKey: %s", err)
+		}
+	}()
+	do(http.MethodPost, ts.URL+"/api/v1/silences", body, nil, http.StatusUnauthorized)
+	do(http.MethodPost, ts.URL+"/api/v1/silences?authKey=foo", body, nil, http.StatusUnauthorized)
+	if ids := silencedBy(); len(ids) != 0 {
+		t.Fatalf("expected alert not to be silenced; got %v", ids)
+	}
+	cr = createSilenceResponse{}
+	do(http.MethodPost, ts.URL+"/api/v1/silences?authKey=secret", body, &cr, http.StatusOK)
+	if ids := silencedBy(); len(ids) != 1 || ids[0] != cr.Data.SilenceID {
+		t.Fatalf("expected alert to be silenced by %q; got %v", cr.Data.SilenceID, ids)
+	}
+	do(http.MethodGet, ts.URL+"/api/v1/silences", "", nil, http.StatusOK)
+	do(http.MethodDelete, ts.URL+"/api/v1/silence?id="+cr.Data.SilenceID, "", nil, http.StatusUnauthorized)
+	do(http.MethodDelete, ts.URL+"/api/v1/silence?id="+cr.Data.SilenceID+"&authKey=secret", "", nil, http.StatusOK)
+	if ids := silencedBy(); len(ids) != 0 {
+		t.Fatalf("expected alert not to be silenced; got %v", ids)
+	}
+}
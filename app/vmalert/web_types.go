@@ -18,6 +18,8 @@ const (
 	paramAlertID = "alert_id"
 	// ParamRuleID is rule id key in url parameter
 	paramRuleID = "rule_id"
+	// paramSilenceID is silence id key in url parameter
+	paramSilenceID = "id"
 )
 
 // apiAlert represents a notifier.AlertingRule state
@@ -50,6 +52,10 @@ type apiAlert struct {
 	// Stabilizing shows when firing state is kept because of
 	// `keep_firing_for` instead of real alert
 	Stabilizing bool `json:"stabilizing"`
+	// SilencedBy contains IDs of active silences matching the Alert
+	SilencedBy []string `json:"silencedBy,omitempty"`
+	// Inhibited shows whether notifications for Alert are muted via inhibit rules
+	Inhibited bool `json:"inhibited,omitempty"`
 }
 
 // WebLink returns a link to the alert which can be used in UI.
@@ -183,9 +189,9 @@ func (ar apiRule) WebLink() string {
 		paramGroupID, ar.GroupID, paramRuleID, ar.ID)
 }
 
-func ruleToAPI(r any) apiRule {
+func ruleToAPI(r any, inh *notifier.Inhibitor) apiRule {
 	if ar, ok := r.(*rule.AlertingRule); ok {
-		return alertingToAPI(ar)
+		return alertingToAPI(ar, inh)
 	}
 	if rr, ok := r.(*rule.RecordingRule); ok {
 		return recordingToAPI(rr)
@@ -228,7 +234,7 @@ func recordingToAPI(rr *rule.RecordingRule) apiRule {
 }
 
 // alertingToAPI returns Rule representation in form of apiRule
-func alertingToAPI(ar *rule.AlertingRule) apiRule {
+func alertingToAPI(ar *rule.AlertingRule, inh *notifier.Inhibitor) apiRule {
 	lastState := rule.GetLastEntry(ar)
 	r := apiRule{
 		Type:              ruleTypeAlerting,
@@ -243,7 +249,7 @@ func alertingToAPI(ar *rule.AlertingRule) apiRule {
 		EvaluationTime:    lastState.Duration.Seconds(),
 		Health:            "ok",
 		State:             "inactive",
-		Alerts:            ruleToAPIAlert(ar),
+		Alerts:            ruleToAPIAlert(ar, inh),
 		LastSamples:       lastState.Samples,
 		LastSeriesFetched: lastState.SeriesFetched,
 		MaxUpdates:        rule.GetRuleStateSize(ar),
@@ -275,28 +281,31 @@ func alertingToAPI(ar *rule.AlertingRule) apiRule {
 }
 
 // ruleToAPIAlert generates list of apiAlert objects from existing alerts
-func ruleToAPIAlert(ar *rule.AlertingRule) []*apiAlert {
+func ruleToAPIAlert(ar *rule.AlertingRule, inh *notifier.Inhibitor) []*apiAlert {
 	var alerts []*apiAlert
 	for _, a := range ar.GetAlerts() {
 		if a.State == notifier.StateInactive {
 			continue
 		}
-		alerts = append(alerts, newAlertAPI(ar, a))
+		alerts = append(alerts, newAlertAPI(ar, a, inh))
 	}
 	return alerts
 }
 
 // alertToAPI generates apiAlert object from alert by its id(hash)
-func alertToAPI(ar *rule.AlertingRule, id uint64) *apiAlert {
+func alertToAPI(ar *rule.AlertingRule, id uint64, inh *notifier.Inhibitor) *apiAlert {
 	a := ar.GetAlert(id)
 	if a == nil {
 		return nil
 	}
-	return newAlertAPI(ar, a)
+	return newAlertAPI(ar, a, inh)
 }
 
 // NewAlertAPI creates apiAlert for notifier.Alert
-func newAlertAPI(ar *rule.AlertingRule, a *notifier.Alert) *apiAlert {
+//
+// inh must be shared between all the alerts generated for a single request,
+// since its creation is proportional to the number of firing alerts.
+func newAlertAPI(ar *rule.AlertingRule, a *notifier.Alert, inh *notifier.Inhibitor) *apiAlert {
 	aa := &apiAlert{
 		// encode as strings to avoid rounding
 		ID:      fmt.Sprintf("%d", a.ID),
@@ -318,10 +327,12 @@ func newAlertAPI(ar *rule.AlertingRule, a *notifier.Alert) *apiAlert {
 	if a.State == notifier.StateFiring && !a.KeepFiringSince.IsZero() {
 		aa.Stabilizing = true
 	}
+	aa.SilencedBy = notifier.SilencedBy(a.Labels)
+	aa.Inhibited = inh.IsInhibited(a)
 	return aa
 }
 
-func groupToAPI(g *rule.Group) apiGroup {
+func groupToAPI(g *rule.Group, inh *notifier.Inhibitor) apiGroup {
 	g = g.DeepCopy()
 	ag := apiGroup{
 		// encode as string to avoid rounding
@@ -347,7 +358,7 @@ func groupToAPI(g *rule.Group) apiGroup {
 	}
 	ag.Rules = make([]apiRule, 0)
 	for _, r := range g.Rules {
-		ag.Rules = append(ag.Rules, ruleToAPI(r))
+		ag.Rules = append(ag.Rules, ruleToAPI(r, inh))
 	}
 	return ag
 }
@@ -0,0 +1,71 @@
+package cloudwatch
+
+import (
+	"net/http"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/cloudwatch"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/cloudwatch/stream"
+	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+)
+
+var (
+	rowsInserted  = metrics.NewCounter(`vm_rows_inserted_total{type="cloudwatch"}`)
+	rowsPerInsert = metrics.NewHistogram(`vm_rows_per_insert{type="cloudwatch"}`)
+)
+
+// InsertHandler processes CloudWatch Metric Streams JSON data delivered via AWS Firehose to /cloudwatch/firehose.
+func InsertHandler(req *http.Request) error {
+	extraLabels, err := parserCommon.GetExtraLabels(req)
+	if err != nil {
+		return err
+	}
+	isGzip := req.Header.Get("Content-Encoding") == "gzip"
+	return stream.Parse(req.Body, isGzip, func(rows []cloudwatch.Row) error {
+		return insertRows(rows, extraLabels)
+	})
+}
+
+func insertRows(rows []cloudwatch.Row, extraLabels []prompbmarshal.Label) error {
+	ctx := common.GetInsertCtx()
+	defer common.PutInsertCtx(ctx)
+
+	samplesCount := 0
+	for i := range rows {
+		samplesCount += len(rows[i].Samples)
+	}
+	ctx.Reset(samplesCount)
+
+	hasRelabeling := relabel.HasRelabeling()
+	for i := range rows {
+		r := &rows[i]
+		samples := r.Samples
+		for j := range samples {
+			s := &samples[j]
+
+			ctx.Labels = ctx.Labels[:0]
+			ctx.AddLabel("", s.Name)
+			for k := range r.Tags {
+				t := &r.Tags[k]
+				ctx.AddLabel(t.Key, t.Value)
+			}
+			for k := range extraLabels {
+				label := &extraLabels[k]
+				ctx.AddLabel(label.Name, label.Value)
+			}
+			if !ctx.TryPrepareLabels(hasRelabeling) {
+				continue
+			}
+			if err := ctx.WriteDataPoint(nil, ctx.Labels, r.Timestamp, s.Value); err != nil {
+				return err
+			}
+		}
+	}
+	rowsInserted.Add(samplesCount)
+	rowsPerInsert.Update(float64(samplesCount))
+	return ctx.FlushBufs()
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 
@@ -34,6 +35,9 @@ var (
 		"before stream de-duplication and aggregation . See https://docs.victoriametrics.com/stream-aggregation/#dropping-unneeded-labels")
 	streamAggrIgnoreOldSamples = flag.Bool("streamAggr.ignoreOldSamples", false, "Whether to ignore input samples with old timestamps outside the current aggregation interval. "+
 		"See https://docs.victoriametrics.com/stream-aggregation/#ignoring-old-samples")
+	streamAggrStateDir = flag.String("streamAggr.stateDir", "", "Optional path to directory for persisting stream aggregation state across restarts "+
+		"for -streamAggr.config. By default, the aggregation state is lost on restart. "+
+		"See https://docs.victoriametrics.com/stream-aggregation/#persisting-aggregation-state")
 	streamAggrIgnoreFirstIntervals = flag.Int("streamAggr.ignoreFirstIntervals", 0, "Number of aggregation intervals to skip after the start. Increase this value if you observe incorrect aggregation results after restarts. It could be caused by receiving unordered delayed data from clients pushing data into the database. "+
 		"See https://docs.victoriametrics.com/stream-aggregation/#ignore-aggregation-intervals-on-start")
 )
@@ -90,6 +94,7 @@ func InitStreamAggr() {
 		DropInputLabels:      *streamAggrDropInputLabels,
 		IgnoreOldSamples:     *streamAggrIgnoreOldSamples,
 		IgnoreFirstIntervals: *streamAggrIgnoreFirstIntervals,
+		StateDir:             *streamAggrStateDir,
 	}
 	sas, err := streamaggr.LoadFromFile(*streamAggrConfig, pushAggregateSeries, opts, "global")
 	if err != nil {
@@ -124,6 +129,7 @@ func reloadStreamAggrConfig() {
 		DropInputLabels:      *streamAggrDropInputLabels,
 		IgnoreOldSamples:     *streamAggrIgnoreOldSamples,
 		IgnoreFirstIntervals: *streamAggrIgnoreFirstIntervals,
+		StateDir:             *streamAggrStateDir,
 	}
 	sasNew, err := streamaggr.LoadFromFile(*streamAggrConfig, pushAggregateSeries, opts, "global")
 	if err != nil {
@@ -279,3 +285,20 @@ func pushAggregateSeries(tss []prompbmarshal.TimeSeries) {
 		logger.Errorf("cannot flush aggregate series: %s", err)
 	}
 }
+
+// WriteStreamAggrStatus writes /stream-agg page with the status of -streamAggr.config aggregators to w.
+func WriteStreamAggrStatus(w http.ResponseWriter, r *http.Request) {
+	streamaggr.WriteAggregatorsStatus(w, r, []*streamaggr.Aggregators{sasGlobal.Load()})
+}
+
+// WriteStreamAggrDryRun writes /stream-agg-dry-run page to w.
+//
+// The dry run uses the settings for -streamAggr.config.
+func WriteStreamAggrDryRun(w http.ResponseWriter, r *http.Request) {
+	opts := &streamaggr.Options{
+		DedupInterval:    *streamAggrDedupInterval,
+		DropInputLabels:  *streamAggrDropInputLabels,
+		IgnoreOldSamples: *streamAggrIgnoreOldSamples,
+	}
+	streamaggr.WriteDryRun(w, r, opts)
+}
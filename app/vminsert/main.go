@@ -10,6 +10,8 @@ import (
 
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/cloudwatch"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/collectd"
 	vminsertCommon "github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/csvimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/datadogsketches"
@@ -32,6 +34,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/influxutils"
+	collectdserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/collectd"
 	graphiteserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/graphite"
 	influxserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/influx"
 	opentsdbserver "github.com/VictoriaMetrics/VictoriaMetrics/lib/ingestserver/opentsdb"
@@ -39,6 +42,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape"
+	collectdparser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/collectd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/opentelemetry/firehose"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
@@ -46,10 +50,16 @@ import (
 )
 
 var (
+	collectdListenAddr = flag.String("collectdListenAddr", "", "UDP address to listen for collectd binary network protocol data. Usually :25826 must be set. Doesn't work if empty. "+
+		"See also -collectd.typesDB, -collectd.authFile and -collectd.securityLevel")
 	graphiteListenAddr = flag.String("graphiteListenAddr", "", "TCP and UDP address to listen for Graphite plaintext data. Usually :2003 must be set. Doesn't work if empty. "+
 		"See also -graphiteListenAddr.useProxyProtocol")
 	graphiteUseProxyProtocol = flag.Bool("graphiteListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted at -graphiteListenAddr . "+
 		"See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
+	graphitePickleListenAddr = flag.String("graphitePickleListenAddr", "", "TCP address to listen for Graphite pickle data. Usually :2004 must be set. Doesn't work if empty. "+
+		"See also -graphitePickleListenAddr.useProxyProtocol")
+	graphitePickleUseProxyProtocol = flag.Bool("graphitePickleListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted at -graphitePickleListenAddr . "+
+		"See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
 	influxListenAddr = flag.String("influxListenAddr", "", "TCP and UDP address to listen for InfluxDB line protocol data. Usually :8089 must be set. Doesn't work if empty. "+
 		"This flag isn't needed when ingesting data over HTTP - just send it to http://<victoriametrics>:8428/write . "+
 		"See also -influxListenAddr.useProxyProtocol")
@@ -73,10 +83,12 @@ var (
 )
 
 var (
-	graphiteServer     *graphiteserver.Server
-	influxServer       *influxserver.Server
-	opentsdbServer     *opentsdbserver.Server
-	opentsdbhttpServer *opentsdbhttpserver.Server
+	collectdServer       *collectdserver.Server
+	graphiteServer       *graphiteserver.Server
+	graphitePickleServer *graphiteserver.PickleServer
+	influxServer         *influxserver.Server
+	opentsdbServer       *opentsdbserver.Server
+	opentsdbhttpServer   *opentsdbhttpserver.Server
 )
 
 //go:embed static
@@ -89,9 +101,16 @@ func Init() {
 	relabel.Init()
 	vminsertCommon.InitStreamAggr()
 	common.StartUnmarshalWorkers()
+	if len(*collectdListenAddr) > 0 {
+		collectdparser.MustInit()
+		collectdServer = collectdserver.MustStart(*collectdListenAddr, collectd.InsertHandler)
+	}
 	if len(*graphiteListenAddr) > 0 {
 		graphiteServer = graphiteserver.MustStart(*graphiteListenAddr, *graphiteUseProxyProtocol, graphite.InsertHandler)
 	}
+	if len(*graphitePickleListenAddr) > 0 {
+		graphitePickleServer = graphiteserver.MustStartPickle(*graphitePickleListenAddr, *graphitePickleUseProxyProtocol, graphite.InsertHandlerPickle)
+	}
 	if len(*influxListenAddr) > 0 {
 		influxServer = influxserver.MustStart(*influxListenAddr, *influxUseProxyProtocol, influx.InsertHandlerForReader)
 	}
@@ -110,9 +129,15 @@ func Init() {
 // Stop stops vminsert.
 func Stop() {
 	promscrape.Stop()
+	if len(*collectdListenAddr) > 0 {
+		collectdServer.MustStop()
+	}
 	if len(*graphiteListenAddr) > 0 {
 		graphiteServer.MustStop()
 	}
+	if len(*graphitePickleListenAddr) > 0 {
+		graphitePickleServer.MustStop()
+	}
 	if len(*influxListenAddr) > 0 {
 		influxServer.MustStop()
 	}
@@ -231,6 +256,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		firehose.WriteSuccessResponse(w, r)
 		return true
+	case "/cloudwatch/firehose":
+		cloudwatchFirehoseRequests.Inc()
+		if err := cloudwatch.InsertHandler(r); err != nil {
+			cloudwatchFirehoseErrors.Inc()
+			firehose.WriteErrorResponse(w, r, err)
+			return true
+		}
+		firehose.WriteSuccessResponse(w, r)
+		return true
 	case "/newrelic":
 		newrelicCheckRequest.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -317,6 +351,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promscrapeServiceDiscoveryRequests.Inc()
 		promscrape.WriteServiceDiscovery(w, r)
 		return true
+	case "/prometheus/stream-agg", "/stream-agg":
+		streamAggrStatusRequests.Inc()
+		vminsertCommon.WriteStreamAggrStatus(w, r)
+		return true
+	case "/prometheus/stream-agg-dry-run", "/stream-agg-dry-run":
+		streamAggrDryRunRequests.Inc()
+		vminsertCommon.WriteStreamAggrDryRun(w, r)
+		return true
 	case "/prometheus/api/v1/targets", "/api/v1/targets":
 		promscrapeAPIV1TargetsRequests.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -421,6 +463,9 @@ var (
 	opentelemetryPushRequests = metrics.NewCounter(`vm_http_requests_total{path="/opentelemetry/v1/metrics", protocol="opentelemetry"}`)
 	opentelemetryPushErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/opentelemetry/v1/metrics", protocol="opentelemetry"}`)
 
+	cloudwatchFirehoseRequests = metrics.NewCounter(`vm_http_requests_total{path="/cloudwatch/firehose", protocol="cloudwatch"}`)
+	cloudwatchFirehoseErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/cloudwatch/firehose", protocol="cloudwatch"}`)
+
 	newrelicWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/newrelic/infra/v2/metrics/events/bulk", protocol="newrelic"}`)
 	newrelicWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/newrelic/infra/v2/metrics/events/bulk", protocol="newrelic"}`)
 
@@ -430,6 +475,9 @@ var (
 	promscrapeTargetsRequests          = metrics.NewCounter(`vm_http_requests_total{path="/targets"}`)
 	promscrapeServiceDiscoveryRequests = metrics.NewCounter(`vm_http_requests_total{path="/service-discovery"}`)
 
+	streamAggrStatusRequests = metrics.NewCounter(`vm_http_requests_total{path="/stream-agg"}`)
+	streamAggrDryRunRequests = metrics.NewCounter(`vm_http_requests_total{path="/stream-agg-dry-run"}`)
+
 	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vm_http_requests_total{path="/target_response"}`)
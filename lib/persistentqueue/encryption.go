@@ -0,0 +1,205 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// Encryption encrypts persistent queue data at rest with AES-GCM.
+//
+// The first key is used for encrypting new data, while the remaining keys are used only for decrypting data
+// written before the key rotation.
+type Encryption struct {
+	keys []*encryptionKey
+}
+
+type encryptionKey struct {
+	id   uint32
+	aead cipher.AEAD
+}
+
+const (
+	encryptionKeyIDSize = 4
+	encryptionNonceSize = 12
+	encryptionTagSize   = 16
+
+	// encryptionOverhead is the number of bytes added to every encrypted block.
+	encryptionOverhead = encryptionKeyIDSize + encryptionNonceSize + encryptionTagSize
+)
+
+// LoadEncryptionKeys loads encryption keys from the file at path.
+//
+// The file must contain a key per line. Every key must be 16, 24 or 32 bytes long encoded in hex or base64.
+// The first key is used for encrypting new data. The remaining keys are used for decrypting the data encrypted with these keys
+// before the key rotation. Empty lines and lines starting with # are ignored.
+func LoadEncryptionKeys(path string) (*Encryption, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read encryption keys: %w", err)
+	}
+	var keys [][]byte
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := parseEncryptionKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse encryption key at line %d of %q: %w", i+1, path, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("missing encryption keys in %q", path)
+	}
+	return NewEncryption(keys)
+}
+
+func parseEncryptionKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("the key must be encoded in hex or base64")
+		}
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected key size: %d bytes; supported sizes: 16, 24 or 32 bytes", len(key))
+	}
+}
+
+// NewEncryption returns Encryption for the given keys.
+//
+// The first key is used for encrypting new data.
+func NewEncryption(keys [][]byte) (*Encryption, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least a single encryption key must be passed")
+	}
+	var e Encryption
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize AES cipher for key #%d: %w", i+1, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize AES-GCM for key #%d: %w", i+1, err)
+		}
+		h := sha256.Sum256(key)
+		id := encoding.UnmarshalUint32(h[:])
+		if e.getKey(id) != nil {
+			return nil, fmt.Errorf("duplicate encryption key #%d", i+1)
+		}
+		e.keys = append(e.keys, &encryptionKey{
+			id:   id,
+			aead: aead,
+		})
+	}
+	return &e, nil
+}
+
+func (e *Encryption) getKey(id uint32) *encryptionKey {
+	for _, k := range e.keys {
+		if k.id == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// seal appends encrypted src to dst and returns the result.
+//
+// additionalData is authenticated, but isn't stored in the result, so it must be passed to open.
+func (e *Encryption) seal(dst, src, additionalData []byte) []byte {
+	k := e.keys[0]
+	dst = encoding.MarshalUint32(dst, k.id)
+	nonceStart := len(dst)
+	dst = append(dst, make([]byte, encryptionNonceSize)...)
+	nonce := dst[nonceStart:]
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand.Read never returns errors according to its docs.
+		panic(fmt.Errorf("BUG: cannot generate nonce: %w", err))
+	}
+	return k.aead.Seal(dst, nonce, src, additionalData)
+}
+
+// open appends decrypted src to dst and returns the result.
+//
+// An error is returned if src cannot be authenticated.
+func (e *Encryption) open(dst, src, additionalData []byte) ([]byte, error) {
+	if len(src) < encryptionOverhead {
+		return dst, fmt.Errorf("too short encrypted data; got %d bytes; want at least %d bytes", len(src), encryptionOverhead)
+	}
+	id := encoding.UnmarshalUint32(src)
+	k := e.getKey(id)
+	if k == nil {
+		return dst, fmt.Errorf("the data is encrypted with unknown key %08X; make sure the key hasn't been removed during key rotation", id)
+	}
+	nonce := src[encryptionKeyIDSize : encryptionKeyIDSize+encryptionNonceSize]
+	ciphertext := src[encryptionKeyIDSize+encryptionNonceSize:]
+	result, err := k.aead.Open(dst, nonce, ciphertext, additionalData)
+	if err != nil {
+		return dst, fmt.Errorf("cannot authenticate encrypted data: %w", err)
+	}
+	return result, nil
+}
+
+// encryptedMetainfoPrefix is the prefix for encrypted metainfo file contents.
+//
+// The prefix is followed by reader and writer offsets in plaintext, so the queue can be checked for emptiness
+// without the encryption key. The offsets are authenticated together with the encrypted metainfo.
+var encryptedMetainfoPrefix = []byte("VMPQENC1")
+
+var metainfoAdditionalData = []byte("metainfo")
+
+func (e *Encryption) sealMetainfo(data []byte, readerOffset, writerOffset uint64) []byte {
+	dst := append([]byte{}, encryptedMetainfoPrefix...)
+	dst = encoding.MarshalUint64(dst, readerOffset)
+	dst = encoding.MarshalUint64(dst, writerOffset)
+	ad := append(append([]byte{}, metainfoAdditionalData...), dst...)
+	return e.seal(dst, data, ad)
+}
+
+func (e *Encryption) openMetainfo(data []byte) ([]byte, error) {
+	if _, _, err := unmarshalEncryptedMetainfoOffsets(data); err != nil {
+		return nil, err
+	}
+	n := len(encryptedMetainfoPrefix) + 16
+	ad := append(append([]byte{}, metainfoAdditionalData...), data[:n]...)
+	return e.open(nil, data[n:], ad)
+}
+
+// unmarshalEncryptedMetainfoOffsets returns reader and writer offsets stored in plaintext in the encrypted metainfo data.
+func unmarshalEncryptedMetainfoOffsets(data []byte) (uint64, uint64, error) {
+	if !bytes.HasPrefix(data, encryptedMetainfoPrefix) {
+		return 0, 0, fmt.Errorf("metainfo isn't encrypted")
+	}
+	tail := data[len(encryptedMetainfoPrefix):]
+	if len(tail) < 16 {
+		return 0, 0, fmt.Errorf("too short encrypted metainfo; got %d bytes; want at least %d bytes", len(data), len(encryptedMetainfoPrefix)+16)
+	}
+	readerOffset := encoding.UnmarshalUint64(tail)
+	writerOffset := encoding.UnmarshalUint64(tail[8:])
+	return readerOffset, writerOffset, nil
+}
+
+// blockAdditionalData appends additional data for authenticating the block stored at the given queue offset.
+//
+// This prevents from moving encrypted blocks among queues and within the queue.
+func blockAdditionalData(dst []byte, name string, offset uint64) []byte {
+	dst = append(dst, name...)
+	return encoding.MarshalUint64(dst, offset)
+}
@@ -0,0 +1,366 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestEncryption(t *testing.T, keys ...string) *Encryption {
+	t.Helper()
+	var bs [][]byte
+	for _, key := range keys {
+		bs = append(bs, []byte(key))
+	}
+	enc, err := NewEncryption(bs)
+	if err != nil {
+		t.Fatalf("cannot create encryption: %s", err)
+	}
+	return enc
+}
+
+func TestEncryptionSealOpen(t *testing.T) {
+	enc := newTestEncryption(t, "0123456789abcdef")
+	ad := []byte("foo")
+	for _, s := range []string{"", "a", "foo bar baz"} {
+		data := enc.seal(nil, []byte(s), ad)
+		if len(data) != len(s)+encryptionOverhead {
+			t.Fatalf("unexpected encrypted data size; got %d; want %d", len(data), len(s)+encryptionOverhead)
+		}
+		result, err := enc.open([]byte("prefix"), data, ad)
+		if err != nil {
+			t.Fatalf("cannot open encrypted data: %s", err)
+		}
+		if string(result) != "prefix"+s {
+			t.Fatalf("unexpected result; got %q; want %q", result, "prefix"+s)
+		}
+
+		// additional data mismatch
+		if _, err := enc.open(nil, data, []byte("bar")); err == nil {
+			t.Fatalf("expecting non-nil error for additional data mismatch")
+		}
+
+		// corrupted data
+		data[len(data)-1]++
+		if _, err := enc.open(nil, data, ad); err == nil {
+			t.Fatalf("expecting non-nil error for corrupted data")
+		}
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	encOld := newTestEncryption(t, "0123456789abcdef")
+	encNew := newTestEncryption(t, "fedcba9876543210", "0123456789abcdef")
+	ad := []byte("foo")
+
+	data := encOld.seal(nil, []byte("bar"), ad)
+	result, err := encNew.open(nil, data, ad)
+	if err != nil {
+		t.Fatalf("cannot open data encrypted with the old key: %s", err)
+	}
+	if string(result) != "bar" {
+		t.Fatalf("unexpected result; got %q; want %q", result, "bar")
+	}
+
+	// The new data must be encrypted with the first key.
+	data = encNew.seal(nil, []byte("baz"), ad)
+	if _, err := encOld.open(nil, data, ad); err == nil {
+		t.Fatalf("expecting non-nil error when opening data encrypted with unknown key")
+	}
+}
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	f := func(data string, resultExpected bool) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("cannot write keys file: %s", err)
+		}
+		enc, err := LoadEncryptionKeys(path)
+		if resultExpected {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		if err == nil {
+			t.Fatalf("expecting non-nil error; got %d keys", len(enc.keys))
+		}
+	}
+
+	// valid keys
+	f("000102030405060708090a0b0c0d0e0f", true)
+	f("# comment\n\n000102030405060708090a0b0c0d0e0f\nAAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=\n", true)
+
+	// missing keys
+	f("", false)
+	f("# comment\n", false)
+
+	// invalid keys
+	f("foobar", false)
+	f("000102", false)
+
+	// duplicate keys
+	f("000102030405060708090a0b0c0d0e0f\n000102030405060708090a0b0c0d0e0f", false)
+}
+
+func TestQueueEncryption(t *testing.T) {
+	path := t.TempDir()
+	const chunkFileSize = 1000
+	const maxBlockSize = 20
+	enc := newTestEncryption(t, "0123456789abcdef")
+
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	var blocks []string
+	for i := 0; i < 50; i++ {
+		block := fmt.Sprintf("block %d", i)
+		q.MustWriteBlock([]byte(block))
+		blocks = append(blocks, block)
+	}
+	q.MustClose()
+
+	// Verify the data on disk isn't stored in plaintext.
+	for _, name := range []string{metainfoFilename, fmt.Sprintf("%016X", 0)} {
+		data, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			t.Fatalf("cannot read %q: %s", name, err)
+		}
+		if bytes.Contains(data, []byte("block")) || bytes.Contains(data, []byte("foobar")) {
+			t.Fatalf("unexpected plaintext data at %q", name)
+		}
+	}
+
+	q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	defer q.MustClose()
+	for _, block := range blocks {
+		data, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if block != string(data) {
+			t.Fatalf("unexpected block read; got %q; want %q", data, block)
+		}
+	}
+	if n := q.GetPendingBytes(); n != 0 {
+		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+	}
+}
+
+func TestQueueEncryptionTamperedBlock(t *testing.T) {
+	path := t.TempDir()
+	const chunkFileSize = 1000
+	const maxBlockSize = 20
+	enc := newTestEncryption(t, "0123456789abcdef")
+
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	for i := 0; i < 3; i++ {
+		q.MustWriteBlock([]byte(fmt.Sprintf("block %d", i)))
+	}
+	q.MustClose()
+
+	// Corrupt the ciphertext of the second block.
+	chunkPath := filepath.Join(path, fmt.Sprintf("%016X", 0))
+	data, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatalf("cannot read chunk file: %s", err)
+	}
+	// Update the block checksum, since it doesn't protect from intentional modifications.
+	blockSize := blockHeaderSize + encryptionOverhead + len("block 0")
+	data[blockSize+blockHeaderSize+encryptionKeyIDSize+encryptionNonceSize]++
+	mustUpdateBlockChecksum(data[blockSize:])
+	if err := os.WriteFile(chunkPath, data, 0600); err != nil {
+		t.Fatalf("cannot write chunk file: %s", err)
+	}
+
+	q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	defer q.MustClose()
+	authFailed := q.blocksAuthFailed.Get()
+	for _, block := range []string{"block 0", "block 2"} {
+		data, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if block != string(data) {
+			t.Fatalf("unexpected block read; got %q; want %q", data, block)
+		}
+	}
+	if n := q.blocksAuthFailed.Get() - authFailed; n != 1 {
+		t.Fatalf("unexpected number of blocks with failed authentication; got %d; want 1", n)
+	}
+	if n := q.GetPendingBytes(); n != 0 {
+		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+	}
+}
+
+func TestQueueEncryptionMismatch(t *testing.T) {
+	f := func(encWrite, encRead *Encryption) {
+		t.Helper()
+		path := t.TempDir()
+		q := mustOpenInternal(path, "foobar", 1000, 20, 0, encWrite)
+		q.MustWriteBlock([]byte("foo"))
+		q.MustClose()
+
+		// The queue mustn't be opened, since its contents cannot be read.
+		if _, err := tryOpeningQueue(path, "foobar", 1000, 20, 0, encRead); !errors.Is(err, errEncryptionMismatch) {
+			t.Fatalf("unexpected error; got %v; want %v", err, errEncryptionMismatch)
+		}
+
+		// The pending data must be preserved.
+		q = mustOpenInternal(path, "foobar", 1000, 20, 0, encWrite)
+		defer q.MustClose()
+		data, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("the pending data has been dropped")
+		}
+		if string(data) != "foo" {
+			t.Fatalf("unexpected data read; got %q; want %q", data, "foo")
+		}
+	}
+
+	enc := newTestEncryption(t, "0123456789abcdef")
+
+	// encryption is disabled for the existing queue
+	f(enc, nil)
+
+	// the key is removed
+	f(enc, newTestEncryption(t, "fedcba9876543210"))
+}
+
+func TestQueueEncryptionChangeEmptyQueue(t *testing.T) {
+	f := func(encWrite, encRead *Encryption) {
+		t.Helper()
+		path := t.TempDir()
+		q := mustOpenInternal(path, "foobar", 1000, 20, 0, encWrite)
+		q.MustWriteBlock([]byte("foo"))
+		if _, ok := q.MustReadBlockNonblocking(nil); !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		q.MustClose()
+
+		// The empty queue must be re-created with the new encryption settings.
+		q, err := tryOpeningQueue(path, "foobar", 1000, 20, 0, encRead)
+		if err != nil {
+			t.Fatalf("cannot open empty queue with the new encryption settings: %s", err)
+		}
+		q.MustWriteBlock([]byte("bar"))
+		q.MustClose()
+
+		data, err := os.ReadFile(filepath.Join(path, metainfoFilename))
+		if err != nil {
+			t.Fatalf("cannot read metainfo: %s", err)
+		}
+		if isEncrypted := bytes.HasPrefix(data, encryptedMetainfoPrefix); isEncrypted != (encRead != nil) {
+			t.Fatalf("unexpected metainfo encryption; got %v; want %v", isEncrypted, encRead != nil)
+		}
+
+		q = mustOpenInternal(path, "foobar", 1000, 20, 0, encRead)
+		defer q.MustClose()
+		block, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if string(block) != "bar" {
+			t.Fatalf("unexpected data read; got %q; want %q", block, "bar")
+		}
+	}
+
+	enc := newTestEncryption(t, "0123456789abcdef")
+
+	// encryption is enabled for the existing queue
+	f(nil, enc)
+
+	// encryption is disabled for the existing queue
+	f(enc, nil)
+
+	// the key is removed
+	f(enc, newTestEncryption(t, "fedcba9876543210"))
+}
+
+func TestQueueEncryptionEnabledForNonEmptyQueue(t *testing.T) {
+	path := t.TempDir()
+	const chunkFileSize = 1000
+	const maxBlockSize = 20
+	enc := newTestEncryption(t, "0123456789abcdef")
+
+	// Write plaintext blocks spanning multiple chunk files and read some of them.
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+	var blocks []string
+	for i := 0; i < 100; i++ {
+		block := fmt.Sprintf("plaintext %d", i)
+		q.MustWriteBlock([]byte(block))
+		blocks = append(blocks, block)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := q.MustReadBlockNonblocking(nil); !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+	}
+	blocks = blocks[10:]
+	q.MustClose()
+
+	// The pending plaintext blocks must be readable after enabling the encryption, while new blocks must be encrypted.
+	q, err := tryOpeningQueue(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	if err != nil {
+		t.Fatalf("cannot open non-empty queue after enabling the encryption: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		block := fmt.Sprintf("encrypted %d", i)
+		q.MustWriteBlock([]byte(block))
+		blocks = append(blocks, block)
+	}
+	for i := 0; i < 50; i++ {
+		data, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if string(data) != blocks[i] {
+			t.Fatalf("unexpected block read; got %q; want %q", data, blocks[i])
+		}
+	}
+	blocks = blocks[50:]
+	q.MustClose()
+
+	data, err := os.ReadFile(filepath.Join(path, metainfoFilename))
+	if err != nil {
+		t.Fatalf("cannot read metainfo: %s", err)
+	}
+	if !bytes.HasPrefix(data, encryptedMetainfoPrefix) {
+		t.Fatalf("metainfo must be encrypted after enabling the encryption")
+	}
+
+	// The remaining plaintext blocks must be readable after the restart.
+	var inspected []string
+	err = inspect(path, chunkFileSize, maxBlockSize, enc, func(bi *BlockInfo, data []byte) error {
+		if bi.Err != nil {
+			return fmt.Errorf("unexpected error for the block at offset %d: %w", bi.Offset, bi.Err)
+		}
+		inspected = append(inspected, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot inspect the queue: %s", err)
+	}
+	if fmt.Sprint(inspected) != fmt.Sprint(blocks) {
+		t.Fatalf("unexpected inspected blocks;\ngot\n%q\nwant\n%q", inspected, blocks)
+	}
+	q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+	defer q.MustClose()
+	for _, block := range blocks {
+		data, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if string(data) != block {
+			t.Fatalf("unexpected block read; got %q; want %q", data, block)
+		}
+	}
+	if n := q.GetPendingBytes(); n != 0 {
+		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+	}
+	if n := q.blocksAuthFailed.Get(); n != 0 {
+		t.Fatalf("unexpected number of blocks with failed authentication: %d", n)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
@@ -36,6 +37,17 @@ type FastQueue struct {
 	lastInmemoryBlockReadTime uint64
 
 	stopDeadline uint64
+
+	// newestFirst is set to true if in-memory blocks must be read before the blocks from the file-based queue.
+	newestFirst bool
+
+	// backlogReadRateLimit is the maximum number of bytes per second to read from the file-based queue if newestFirst is set.
+	//
+	// Zero value means unlimited rate.
+	backlogReadRateLimit uint64
+
+	// backlogNextReadTime is the time when the next block can be read from the file-based queue according to backlogReadRateLimit.
+	backlogNextReadTime time.Time
 }
 
 // MustOpenFastQueue opens persistent queue at the given path.
@@ -48,11 +60,61 @@ type FastQueue struct {
 // if isPQDisabled is set to true, then write requests that exceed in-memory buffer capacity are rejected.
 // in-memory queue part can be stored on disk during gracefull shutdown.
 func MustOpenFastQueue(path, name string, maxInmemoryBlocks int, maxPendingBytes int64, isPQDisabled bool) *FastQueue {
-	pq := mustOpen(path, name, maxPendingBytes)
+	return MustOpenFastQueueWithOptions(path, name, maxInmemoryBlocks, maxPendingBytes, isPQDisabled, nil)
+}
+
+// Options contains optional settings for FastQueue.
+type Options struct {
+	// Encryption is used for encrypting the data stored on disk if set.
+	//
+	// The data stored in memory isn't encrypted.
+	Encryption *Encryption
+
+	// MaxBlockAge is the maximum age of blocks stored on disk. Older blocks are dropped when reading them.
+	//
+	// Zero value means unlimited age.
+	MaxBlockAge time.Duration
+
+	// NewestFirst instructs reading the newest blocks from memory before reading the blocks stored on disk.
+	//
+	// By default the blocks are read in the order they were written.
+	NewestFirst bool
+
+	// BacklogReadRateLimit is the maximum number of bytes per second to read from disk when NewestFirst is set.
+	//
+	// Zero value means unlimited rate.
+	BacklogReadRateLimit int64
+
+	// QuarantineCorruptedData instructs copying corrupted data to quarantine directory inside the queue directory before skipping it.
+	//
+	// By default corrupted data is skipped.
+	QuarantineCorruptedData bool
+}
+
+// MustOpenFastQueueWithOptions opens persistent queue at the given path with the given opts.
+//
+// See MustOpenFastQueue for details on the remaining args. opts may be nil.
+func MustOpenFastQueueWithOptions(path, name string, maxInmemoryBlocks int, maxPendingBytes int64, isPQDisabled bool, opts *Options) *FastQueue {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if maxPendingBytes < 0 {
+		maxPendingBytes = 0
+	}
+	enc := opts.Encryption
+	pq := mustOpenInternal(path, name, DefaultChunkFileSize, MaxBlockSize, uint64(maxPendingBytes), enc)
+	pq.maxBlockAge = opts.MaxBlockAge
+	if opts.QuarantineCorruptedData {
+		pq.quarantineDir = filepath.Join(path, quarantineDirname)
+	}
 	fq := &FastQueue{
 		pq:           pq,
 		isPQDisabled: isPQDisabled,
 		ch:           make(chan *bytesutil.ByteBuffer, maxInmemoryBlocks),
+		newestFirst:  opts.NewestFirst,
+	}
+	if opts.BacklogReadRateLimit > 0 {
+		fq.backlogReadRateLimit = uint64(opts.BacklogReadRateLimit)
 	}
 	fq.cond.L = &fq.mu
 	fq.lastInmemoryBlockReadTime = fasttime.UnixTimestamp()
@@ -68,7 +130,12 @@ func MustOpenFastQueue(path, name string, maxInmemoryBlocks int, maxPendingBytes
 	if isPQDisabled {
 		persistenceStatus = "disabled"
 	}
-	logger.Infof("opened fast queue at %q with maxInmemoryBlocks=%d, it contains %d pending bytes, persistence is %s", path, maxInmemoryBlocks, pendingBytes, persistenceStatus)
+	encryptionStatus := "disabled"
+	if enc != nil {
+		encryptionStatus = "enabled"
+	}
+	logger.Infof("opened fast queue at %q with maxInmemoryBlocks=%d, it contains %d pending bytes, persistence is %s, encryption is %s",
+		path, maxInmemoryBlocks, pendingBytes, persistenceStatus, encryptionStatus)
 	return fq
 }
 
@@ -84,6 +151,10 @@ func (fq *FastQueue) IsWriteBlocked() bool {
 	}
 	fq.mu.Lock()
 	defer fq.mu.Unlock()
+	if fq.newestFirst {
+		// Fresh blocks can be written to the in-memory queue even if the file-based queue isn't drained yet.
+		return len(fq.ch) == cap(fq.ch)
+	}
 	return len(fq.ch) == cap(fq.ch) || fq.pq.GetPendingBytes() > 0
 }
 
@@ -182,6 +253,9 @@ func (fq *FastQueue) tryWriteBlock(block []byte, ignoreDisabledPQ bool) bool {
 	isPQWriteAllowed := !fq.isPQDisabled || ignoreDisabledPQ
 
 	fq.flushInmemoryBlocksToFileIfNeededLocked()
+	if fq.newestFirst {
+		return fq.tryWriteBlockNewestFirstLocked(block, isPQWriteAllowed)
+	}
 	if n := fq.pq.GetPendingBytes(); n > 0 {
 		// The file-based queue isn't drained yet. This means that in-memory queue cannot be used yet.
 		// So put the block to file-based queue.
@@ -204,6 +278,31 @@ func (fq *FastQueue) tryWriteBlock(block []byte, ignoreDisabledPQ bool) bool {
 		return true
 	}
 	// Fast path - put the block to in-memory queue.
+	fq.writeInmemoryBlockLocked(block)
+	return true
+}
+
+// tryWriteBlockNewestFirstLocked writes block to fq in newest-first mode.
+//
+// In this mode the in-memory queue is used for fresh blocks even if the file-based queue isn't drained yet.
+func (fq *FastQueue) tryWriteBlockNewestFirstLocked(block []byte, isPQWriteAllowed bool) bool {
+	if len(fq.ch) == cap(fq.ch) {
+		// There is no space left in the in-memory queue. Move the oldest in-memory blocks to file-based queue
+		// in order to free up space for the fresh block.
+		if !isPQWriteAllowed {
+			return false
+		}
+		fq.flushInmemoryBlocksToFileLocked()
+		if cap(fq.ch) == 0 {
+			fq.pq.MustWriteBlock(block)
+			return true
+		}
+	}
+	fq.writeInmemoryBlockLocked(block)
+	return true
+}
+
+func (fq *FastQueue) writeInmemoryBlockLocked(block []byte) {
 	bb := blockBufPool.Get()
 	bb.B = append(bb.B[:0], block...)
 	fq.ch <- bb
@@ -212,7 +311,6 @@ func (fq *FastQueue) tryWriteBlock(block []byte, ignoreDisabledPQ bool) bool {
 	// Notify potentially blocked reader.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/pull/484 for the context.
 	fq.cond.Signal()
-	return true
 }
 
 // MustReadBlock reads the next block from fq to dst and returns it.
@@ -225,7 +323,7 @@ func (fq *FastQueue) MustReadBlock(dst []byte) ([]byte, bool) {
 			return dst, false
 		}
 		if len(fq.ch) > 0 {
-			if n := fq.pq.GetPendingBytes(); n > 0 {
+			if n := fq.pq.GetPendingBytes(); n > 0 && !fq.newestFirst {
 				logger.Panicf("BUG: the file-based queue must be empty when the inmemory queue is non-empty; it contains %d pending bytes", n)
 			}
 			bb := <-fq.ch
@@ -236,8 +334,18 @@ func (fq *FastQueue) MustReadBlock(dst []byte) ([]byte, bool) {
 			return dst, true
 		}
 		if n := fq.pq.GetPendingBytes(); n > 0 {
+			if d := fq.backlogReadDelayLocked(); d > 0 {
+				if fq.stopDeadline > 0 {
+					return dst, false
+				}
+				// Wait until the backlog can be read according to the rate limit or until a fresh block arrives.
+				fq.waitLocked(d)
+				continue
+			}
+			dstLen := len(dst)
 			data, ok := fq.pq.MustReadBlockNonblocking(dst)
 			if ok {
+				fq.registerBacklogReadLocked(len(data) - dstLen)
 				return data, true
 			}
 			dst = data
@@ -252,6 +360,43 @@ func (fq *FastQueue) MustReadBlock(dst []byte) ([]byte, bool) {
 	}
 }
 
+// backlogReadDelayLocked returns the duration to wait before reading the next block from the file-based queue
+// according to fq.backlogReadRateLimit.
+func (fq *FastQueue) backlogReadDelayLocked() time.Duration {
+	if !fq.newestFirst || fq.backlogReadRateLimit == 0 {
+		return 0
+	}
+	d := time.Until(fq.backlogNextReadTime)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (fq *FastQueue) registerBacklogReadLocked(n int) {
+	if !fq.newestFirst || fq.backlogReadRateLimit == 0 {
+		return
+	}
+	now := time.Now()
+	if fq.backlogNextReadTime.Before(now) {
+		fq.backlogNextReadTime = now
+	}
+	d := time.Duration(float64(n) / float64(fq.backlogReadRateLimit) * float64(time.Second))
+	fq.backlogNextReadTime = fq.backlogNextReadTime.Add(d)
+}
+
+// waitLocked waits for up to d for new blocks or for UnblockAllReaders call.
+func (fq *FastQueue) waitLocked(d time.Duration) {
+	t := time.AfterFunc(d, func() {
+		// Lock fq.mu in order to make sure the caller already waits on fq.cond.
+		fq.mu.Lock()
+		fq.cond.Broadcast()
+		fq.mu.Unlock()
+	})
+	fq.cond.Wait()
+	t.Stop()
+}
+
 // Dirname returns the directory name for persistent queue.
 func (fq *FastQueue) Dirname() string {
 	return filepath.Base(fq.pq.dir)
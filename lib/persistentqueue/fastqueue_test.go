@@ -366,3 +366,79 @@ func TestFastQueueWriteReadWithIgnoreDisabledPQ(t *testing.T) {
 	fq.MustClose()
 	mustDeleteDir(path)
 }
+
+func TestFastQueueNewestFirst(t *testing.T) {
+	path := t.TempDir()
+	capacity := 10
+	opts := &Options{
+		NewestFirst: true,
+	}
+	fq := MustOpenFastQueueWithOptions(path, "foobar", capacity, 0, false, opts)
+	defer fq.MustClose()
+
+	// Overflow the in-memory queue, so the oldest blocks are moved to the file-based queue.
+	var blocks []string
+	for i := 0; i < capacity+5; i++ {
+		block := fmt.Sprintf("block %d", i)
+		if !fq.TryWriteBlock([]byte(block)) {
+			t.Fatalf("TryWriteBlock must return true in this context")
+		}
+		blocks = append(blocks, block)
+	}
+	if n := fq.GetInmemoryQueueLen(); n != 5 {
+		t.Fatalf("unexpected size of inmemory queue; got %d; want 5", n)
+	}
+
+	// Fresh blocks must be read from the in-memory queue before the backlog.
+	fresh := blocks[capacity:]
+	backlog := blocks[:capacity]
+	for _, block := range append(fresh, backlog...) {
+		buf, ok := fq.MustReadBlock(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if string(buf) != block {
+			t.Fatalf("unexpected block read; got %q; want %q", buf, block)
+		}
+	}
+	if n := fq.GetPendingBytes(); n != 0 {
+		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+	}
+}
+
+func TestFastQueueBacklogReadRateLimit(t *testing.T) {
+	path := t.TempDir()
+	opts := &Options{
+		NewestFirst:          true,
+		BacklogReadRateLimit: 100,
+	}
+	fq := MustOpenFastQueueWithOptions(path, "foobar", 0, 0, false, opts)
+	defer fq.MustClose()
+
+	block := make([]byte, 50)
+	for i := 0; i < 3; i++ {
+		fq.MustWriteBlockIgnoreDisabledPQ(block)
+	}
+
+	// Every read block delays reading the next block by len(block)/BacklogReadRateLimit seconds.
+	startTime := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, ok := fq.MustReadBlock(nil); !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+	}
+	if d := time.Since(startTime); d < 900*time.Millisecond {
+		t.Fatalf("the backlog must be read at the limited rate; read duration: %s", d)
+	}
+
+	// Reading from the rate-limited backlog must be interrupted by UnblockAllReaders.
+	fq.MustWriteBlockIgnoreDisabledPQ(block)
+	fq.MustWriteBlockIgnoreDisabledPQ(block)
+	if _, ok := fq.MustReadBlock(nil); !ok {
+		t.Fatalf("unexpected ok=false")
+	}
+	fq.UnblockAllReaders()
+	if _, ok := fq.MustReadBlock(nil); ok {
+		t.Fatalf("expecting ok=false after UnblockAllReaders")
+	}
+}
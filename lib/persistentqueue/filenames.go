@@ -1,5 +1,6 @@
 package persistentqueue
 
 const (
-	metainfoFilename = "metainfo.json"
+	metainfoFilename  = "metainfo.json"
+	quarantineDirname = "quarantine"
 )
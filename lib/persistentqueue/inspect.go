@@ -0,0 +1,211 @@
+package persistentqueue
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// BlockInfo contains information about a block stored in persistent queue.
+type BlockInfo struct {
+	// Path is the path to the chunk file with the block.
+	Path string
+
+	// Offset is the offset of the block in the queue.
+	Offset uint64
+
+	// Size is the size of the block contents on disk.
+	Size uint64
+
+	// Timestamp is unix timestamp in seconds when the block has been written.
+	//
+	// It is zero for blocks written by previous releases.
+	Timestamp uint64
+
+	// HasChecksum is set to true if the block contents are protected by checksum.
+	HasChecksum bool
+
+	// Err is set if the block is corrupted or cannot be decrypted.
+	//
+	// The remaining data in the chunk file is skipped after the corrupted block, since it cannot be parsed reliably.
+	// Blocks, which cannot be decrypted, are skipped individually.
+	Err error
+}
+
+// Inspect calls f for every pending block in the persistent queue at path.
+//
+// data contains the block contents. It is nil if bi.Err is set. f mustn't hold references to bi and data after returning.
+// enc must be set for encrypted queues.
+//
+// Inspect doesn't modify the queue. The queue mustn't be modified by other processes during the call.
+func Inspect(path string, enc *Encryption, f func(bi *BlockInfo, data []byte) error) error {
+	return inspect(path, DefaultChunkFileSize, MaxBlockSize, enc, f)
+}
+
+func inspect(path string, chunkFileSize, maxBlockSize uint64, enc *Encryption, f func(bi *BlockInfo, data []byte) error) error {
+	var mi metainfo
+	if err := mi.ReadFromFile(filepath.Join(path, metainfoFilename), enc); err != nil {
+		return fmt.Errorf("cannot read metainfo: %w", err)
+	}
+	ins := &inspector{
+		chunkFileSize:   chunkFileSize,
+		maxBlockSize:    maxBlockSize,
+		blockHeaderSize: mi.BlockHeaderSize,
+		enc:             enc,
+		encryptedOffset: mi.EncryptedOffset,
+		name:            mi.Name,
+		f:               f,
+	}
+	if ins.blockHeaderSize == 0 {
+		ins.blockHeaderSize = legacyBlockHeaderSize
+	}
+	if enc != nil {
+		ins.blockOverhead = encryptionOverhead
+	}
+
+	offset := mi.ReaderOffset
+	for offset < mi.WriterOffset {
+		chunkOffset := offset - offset%chunkFileSize
+		chunkPath := filepath.Join(path, fmt.Sprintf("%016X", chunkOffset))
+		if err := ins.inspectChunk(chunkPath, offset, min(chunkOffset+chunkFileSize, mi.WriterOffset)); err != nil {
+			return err
+		}
+		offset = chunkOffset + chunkFileSize
+	}
+	return nil
+}
+
+type inspector struct {
+	chunkFileSize   uint64
+	maxBlockSize    uint64
+	blockOverhead   uint64
+	blockHeaderSize uint64
+	enc             *Encryption
+	encryptedOffset uint64
+	name            string
+	f               func(bi *BlockInfo, data []byte) error
+}
+
+// inspectChunk inspects blocks in the chunk file at path in the range [offset ... endOffset).
+func (ins *inspector) inspectChunk(path string, offset, endOffset uint64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		bi := &BlockInfo{
+			Path:   path,
+			Offset: offset,
+			Err:    err,
+		}
+		return ins.f(bi, nil)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if _, err := file.Seek(int64(offset%ins.chunkFileSize), io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek %q to offset %d: %w", path, offset%ins.chunkFileSize, err)
+	}
+	cr := &chunkReader{
+		r:         bufio.NewReader(file),
+		offset:    offset,
+		endOffset: endOffset,
+	}
+	// The writer switches to the next chunk file when the current chunk file has no space for the block with the maximum size.
+	for cr.offset < endOffset && cr.offset%ins.chunkFileSize+ins.getMaxBlockLen(cr.offset)+ins.blockHeaderSize <= ins.chunkFileSize {
+		bi := &BlockInfo{
+			Path:   path,
+			Offset: cr.offset,
+		}
+		data, canContinue, blockErr := cr.readBlock(bi, ins)
+		if blockErr != nil {
+			bi.Err = blockErr
+			data = nil
+		}
+		if err := ins.f(bi, data); err != nil {
+			return err
+		}
+		if blockErr != nil && !canContinue {
+			return nil
+		}
+	}
+	return nil
+}
+
+// getMaxBlockLen returns the maximum length for the block at the given offset.
+//
+// Blocks below encryptedOffset are stored in plaintext, since they have been written before enabling the encryption.
+func (ins *inspector) getMaxBlockLen(offset uint64) uint64 {
+	if offset < ins.encryptedOffset {
+		return ins.maxBlockSize
+	}
+	return ins.maxBlockSize + ins.blockOverhead
+}
+
+type chunkReader struct {
+	r         *bufio.Reader
+	offset    uint64
+	endOffset uint64
+	buf       []byte
+	dataBuf   []byte
+}
+
+func (cr *chunkReader) readFull(buf []byte) error {
+	if cr.offset+uint64(len(buf)) > cr.endOffset {
+		return fmt.Errorf("the block exceeds the queue end at offset %d", cr.endOffset)
+	}
+	if _, err := io.ReadFull(cr.r, buf); err != nil {
+		return err
+	}
+	cr.offset += uint64(len(buf))
+	return nil
+}
+
+// readBlock reads the next block from cr and fills bi.
+//
+// The returned bool is set to true if the next block can be read after the error.
+func (cr *chunkReader) readBlock(bi *BlockInfo, ins *inspector) ([]byte, bool, error) {
+	cr.buf = append(cr.buf[:0], make([]byte, legacyBlockHeaderSize)...)
+	if err := cr.readFull(cr.buf); err != nil {
+		return nil, false, fmt.Errorf("cannot read block header: %w", err)
+	}
+	blockLen, flags := unmarshalBlockLen(cr.buf)
+	bi.Size = blockLen
+	headerTailSize, err := getBlockHeaderTailSize(flags)
+	if err != nil {
+		return nil, false, err
+	}
+	if maxBlockLen := ins.getMaxBlockLen(bi.Offset); blockLen > maxBlockLen {
+		return nil, false, fmt.Errorf("too big block size: %d bytes; cannot exceed %d bytes", blockLen, maxBlockLen)
+	}
+	cr.buf = append(cr.buf, make([]byte, headerTailSize)...)
+	if err := cr.readFull(cr.buf[legacyBlockHeaderSize:]); err != nil {
+		return nil, false, fmt.Errorf("cannot read block header: %w", err)
+	}
+	if flags&blockFlagTimestamp != 0 {
+		bi.Timestamp = encoding.UnmarshalUint64(cr.buf[legacyBlockHeaderSize:])
+	}
+
+	cr.dataBuf = append(cr.dataBuf[:0], make([]byte, blockLen)...)
+	if err := cr.readFull(cr.dataBuf); err != nil {
+		return nil, false, fmt.Errorf("cannot read block contents with size %d bytes: %w", blockLen, err)
+	}
+	if flags&blockFlagChecksum != 0 {
+		bi.HasChecksum = true
+		checksum := encoding.UnmarshalUint64(cr.buf[timestampBlockHeaderSize:])
+		if checksumExpected := blockChecksum(cr.buf[:timestampBlockHeaderSize], cr.dataBuf); checksum != checksumExpected {
+			return nil, false, fmt.Errorf("checksum mismatch; got 0x%016X; want 0x%016X", checksum, checksumExpected)
+		}
+	}
+	if ins.enc == nil || bi.Offset < ins.encryptedOffset {
+		return cr.dataBuf, true, nil
+	}
+	ad := blockAdditionalData(nil, ins.name, bi.Offset)
+	data, err := ins.enc.open(nil, cr.dataBuf, ad)
+	if err != nil {
+		// Authentication failure doesn't break the chunk structure, so the next block can be read.
+		return nil, true, err
+	}
+	return data, true, nil
+}
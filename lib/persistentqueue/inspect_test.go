@@ -0,0 +1,95 @@
+package persistentqueue
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	f := func(enc *Encryption) {
+		t.Helper()
+		path := t.TempDir()
+		const chunkFileSize = 200
+		const maxBlockSize = 20
+
+		// Write blocks to multiple chunk files and read some of them.
+		q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, enc)
+		for i := 0; i < 10; i++ {
+			q.MustWriteBlock([]byte(fmt.Sprintf("block %d", i)))
+		}
+		for i := 0; i < 3; i++ {
+			if _, ok := q.MustReadBlockNonblocking(nil); !ok {
+				t.Fatalf("unexpected ok=false")
+			}
+		}
+		q.MustClose()
+
+		var blocks []string
+		err := inspect(path, chunkFileSize, maxBlockSize, enc, func(bi *BlockInfo, data []byte) error {
+			if bi.Err != nil {
+				return fmt.Errorf("unexpected error for block at offset %d: %w", bi.Offset, bi.Err)
+			}
+			if bi.Timestamp == 0 || !bi.HasChecksum {
+				return fmt.Errorf("missing timestamp or checksum for block at offset %d", bi.Offset)
+			}
+			blocks = append(blocks, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(blocks) != 7 {
+			t.Fatalf("unexpected number of blocks; got %d; want 7", len(blocks))
+		}
+		for i, block := range blocks {
+			if blockExpected := fmt.Sprintf("block %d", i+3); block != blockExpected {
+				t.Fatalf("unexpected block #%d; got %q; want %q", i, block, blockExpected)
+			}
+		}
+	}
+
+	f(nil)
+	f(newTestEncryption(t, "0123456789abcdef"))
+}
+
+func TestInspectCorruptedBlock(t *testing.T) {
+	path := t.TempDir()
+	const chunkFileSize = 1000
+	const maxBlockSize = 20
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+	for i := 0; i < 3; i++ {
+		q.MustWriteBlock([]byte(fmt.Sprintf("block %d", i)))
+	}
+	q.MustClose()
+
+	chunkPath := filepath.Join(path, fmt.Sprintf("%016X", 0))
+	data, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatalf("cannot read chunk file: %s", err)
+	}
+	blockSize := blockHeaderSize + len("block 0")
+	data[blockSize+blockHeaderSize] ^= 1
+	if err := os.WriteFile(chunkPath, data, 0600); err != nil {
+		t.Fatalf("cannot write chunk file: %s", err)
+	}
+
+	var bis []BlockInfo
+	err = inspect(path, chunkFileSize, maxBlockSize, nil, func(bi *BlockInfo, _ []byte) error {
+		bis = append(bis, *bi)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(bis) != 2 {
+		t.Fatalf("unexpected number of blocks; got %d; want 2", len(bis))
+	}
+	if bis[0].Err != nil {
+		t.Fatalf("unexpected error for the first block: %s", bis[0].Err)
+	}
+	if bis[1].Err == nil || bis[1].Offset != uint64(blockSize) {
+		t.Fatalf("expecting error for the second block at offset %d; got %+v", blockSize, bis[1])
+	}
+}
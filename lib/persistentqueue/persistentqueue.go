@@ -1,7 +1,9 @@
 package persistentqueue
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +12,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
+
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
@@ -40,6 +44,22 @@ type queue struct {
 
 	flockF *os.File
 
+	// enc is used for encrypting blocks and metainfo if set.
+	enc *Encryption
+
+	// blockOverhead is the number of bytes added to every block on disk in addition to the block header.
+	blockOverhead uint64
+
+	// encryptedOffset is the offset starting from which blocks are encrypted.
+	//
+	// Blocks below this offset are stored in plaintext, since they have been written before enabling the encryption for the existing queue.
+	encryptedOffset uint64
+
+	// blockHeaderSize is the size of the header for blocks written to the queue.
+	//
+	// It may be smaller than blockHeaderSize for queues created by previous releases until they become empty.
+	blockHeaderSize uint64
+
 	reader            *filestream.Reader
 	readerPath        string
 	readerOffset      uint64
@@ -61,6 +81,23 @@ type queue struct {
 
 	blocksRead *metrics.Counter
 	bytesRead  *metrics.Counter
+
+	blocksAuthFailed *metrics.Counter
+
+	// maxBlockAge is the maximum age of blocks to read. Older blocks are dropped.
+	//
+	// Zero value means unlimited age.
+	maxBlockAge time.Duration
+
+	blocksExpired *metrics.Counter
+	bytesExpired  *metrics.Counter
+
+	// quarantineDir is the directory for copying corrupted data to. Corrupted data is just skipped if quarantineDir is empty.
+	quarantineDir string
+
+	corruptedRegions *metrics.Counter
+	bytesCorrupted   *metrics.Counter
+	bytesQuarantined *metrics.Counter
 }
 
 // ResetIfEmpty resets q if it is empty.
@@ -71,6 +108,7 @@ func (q *queue) ResetIfEmpty() {
 		// The queue isn't empty.
 		return
 	}
+	q.mustUpgradeBlockFormatIfEmpty()
 	if q.readerOffset < 16*1024*1024 {
 		// The file is too small to drop. Leave it as is in order to reduce filesystem load.
 		return
@@ -101,6 +139,7 @@ func (q *queue) mustResetFiles() {
 	r := filestream.MustOpen(q.readerPath, true)
 	q.reader = r
 
+	q.blockHeaderSize = blockHeaderSize
 	if err := q.flushMetainfo(); err != nil {
 		logger.Panicf("FATAL: cannot flush metainfo: %s", err)
 	}
@@ -123,21 +162,29 @@ func mustOpen(path, name string, maxPendingBytes int64) *queue {
 	if maxPendingBytes < 0 {
 		maxPendingBytes = 0
 	}
-	return mustOpenInternal(path, name, DefaultChunkFileSize, MaxBlockSize, uint64(maxPendingBytes))
+	return mustOpenInternal(path, name, DefaultChunkFileSize, MaxBlockSize, uint64(maxPendingBytes), nil)
 }
 
-func mustOpenInternal(path, name string, chunkFileSize, maxBlockSize, maxPendingBytes uint64) *queue {
-	if chunkFileSize < 8 || chunkFileSize-8 < maxBlockSize {
+func mustOpenInternal(path, name string, chunkFileSize, maxBlockSize, maxPendingBytes uint64, enc *Encryption) *queue {
+	overhead := uint64(blockHeaderSize)
+	if enc != nil {
+		overhead += encryptionOverhead
+	}
+	if chunkFileSize < overhead || chunkFileSize-overhead < maxBlockSize {
 		logger.Panicf("BUG: too small chunkFileSize=%d for maxBlockSize=%d; chunkFileSize must fit at least one block", chunkFileSize, maxBlockSize)
 	}
 	if maxBlockSize <= 0 {
 		logger.Panicf("BUG: maxBlockSize must be greater than 0; got %d", maxBlockSize)
 	}
-	q, err := tryOpeningQueue(path, name, chunkFileSize, maxBlockSize, maxPendingBytes)
+	q, err := tryOpeningQueue(path, name, chunkFileSize, maxBlockSize, maxPendingBytes, enc)
+	if errors.Is(err, errEncryptionMismatch) {
+		logger.Fatalf("cannot open persistent queue at %q: %s; the queue isn't removed in order to prevent from data loss; "+
+			"restore the previously used encryption settings in order to send the pending data, or remove %q manually", path, err, path)
+	}
 	if err != nil {
 		logger.Errorf("cannot open persistent queue at %q: %s; cleaning it up and trying again", path, err)
 		fs.RemoveDirContents(path)
-		q, err = tryOpeningQueue(path, name, chunkFileSize, maxBlockSize, maxPendingBytes)
+		q, err = tryOpeningQueue(path, name, chunkFileSize, maxBlockSize, maxPendingBytes, enc)
 		if err != nil {
 			logger.Panicf("FATAL: %s", err)
 		}
@@ -145,7 +192,7 @@ func mustOpenInternal(path, name string, chunkFileSize, maxBlockSize, maxPending
 	return q
 }
 
-func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingBytes uint64) (*queue, error) {
+func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingBytes uint64, enc *Encryption) (*queue, error) {
 	// Protect from concurrent opens.
 	var q queue
 	q.chunkFileSize = chunkFileSize
@@ -153,6 +200,10 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 	q.maxPendingBytes = maxPendingBytes
 	q.dir = path
 	q.name = name
+	q.enc = enc
+	if enc != nil {
+		q.blockOverhead = encryptionOverhead
+	}
 
 	q.blocksDropped = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_blocks_dropped_total{path=%q}`, path))
 	q.bytesDropped = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_dropped_total{path=%q}`, path))
@@ -160,6 +211,12 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 	q.bytesWritten = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_written_total{path=%q}`, path))
 	q.blocksRead = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_blocks_read_total{path=%q}`, path))
 	q.bytesRead = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_read_total{path=%q}`, path))
+	q.blocksAuthFailed = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_blocks_authentication_failed_total{path=%q}`, path))
+	q.corruptedRegions = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_corrupted_regions_total{path=%q}`, path))
+	q.bytesCorrupted = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_corrupted_total{path=%q}`, path))
+	q.bytesQuarantined = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_quarantined_total{path=%q}`, path))
+	q.blocksExpired = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_blocks_expired_total{path=%q}`, path))
+	q.bytesExpired = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_persistentqueue_bytes_expired_total{path=%q}`, path))
 
 	cleanOnError := func() {
 		if q.reader != nil {
@@ -182,8 +239,14 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 	// Read metainfo.
 	var mi metainfo
 	metainfoPath := q.metainfoPath()
-	if err := mi.ReadFromFile(metainfoPath); err != nil {
-		if !os.IsNotExist(err) {
+	if err := mi.ReadFromFile(metainfoPath, q.enc); err != nil {
+		if errors.Is(err, errEncryptionMismatch) {
+			if mi.ReaderOffset != mi.WriterOffset {
+				// The pending data cannot be read with the current encryption settings.
+				return nil, err
+			}
+			logger.Infof("re-creating empty persistent queue at %q with the current encryption settings: %s", path, err)
+		} else if !os.IsNotExist(err) {
 			logger.Errorf("cannot read metainfo for persistent queue from %q: %s; re-creating %q", metainfoPath, err, path)
 		}
 
@@ -193,9 +256,11 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 		q.flockF = fs.MustCreateFlockFile(path)
 		mi.Reset()
 		mi.Name = q.name
-		if err := mi.WriteToFile(metainfoPath); err != nil {
+		mi.BlockHeaderSize = blockHeaderSize
+		if err := mi.WriteToFile(metainfoPath, q.enc); err != nil {
 			return nil, fmt.Errorf("cannot create %q: %w", metainfoPath, err)
 		}
+		mi.encrypted = q.enc != nil
 
 		// Create initial chunk file.
 		filepath := q.chunkFilePath(0)
@@ -204,6 +269,16 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 	if mi.Name != q.name {
 		return nil, fmt.Errorf("unexpected queue name; got %q; want %q", mi.Name, q.name)
 	}
+	switch mi.BlockHeaderSize {
+	case 0:
+		// The queue has been created by previous releases.
+		q.blockHeaderSize = legacyBlockHeaderSize
+	case timestampBlockHeaderSize, blockHeaderSize:
+		q.blockHeaderSize = mi.BlockHeaderSize
+	default:
+		return nil, fmt.Errorf("unsupported block header size: %d bytes", mi.BlockHeaderSize)
+	}
+	q.encryptedOffset = mi.EncryptedOffset
 
 	// Locate reader and writer chunks in the path.
 	des := fs.MustReadDir(path)
@@ -211,7 +286,9 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 		fname := de.Name()
 		filepath := filepath.Join(path, fname)
 		if de.IsDir() {
-			logger.Errorf("skipping unknown directory %q", filepath)
+			if fname != quarantineDirname {
+				logger.Errorf("skipping unknown directory %q", filepath)
+			}
 			continue
 		}
 		if fname == metainfoFilename {
@@ -310,10 +387,33 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 		cleanOnError()
 		return nil, fmt.Errorf("readerOffset=%d cannot exceed writerOffset=%d", q.readerOffset, q.writerOffset)
 	}
+	if q.enc != nil && !mi.encrypted {
+		// The encryption has been enabled for the existing queue. Encrypt its metainfo,
+		// while the pending blocks are read without decryption until the queue is drained.
+		if err := q.flushMetainfo(); err != nil {
+			cleanOnError()
+			return nil, err
+		}
+	}
 	mustCloseFlockF = false
+	q.mustUpgradeBlockFormatIfEmpty()
 	return &q, nil
 }
 
+// mustUpgradeBlockFormatIfEmpty switches q created by previous releases to the current block format if q is empty.
+//
+// The block format cannot be switched for non-empty q, since the reader must use the same block header size
+// for locating chunk file boundaries as the writer did.
+func (q *queue) mustUpgradeBlockFormatIfEmpty() {
+	if q.blockHeaderSize == blockHeaderSize || q.readerOffset != q.writerOffset {
+		return
+	}
+	q.blockHeaderSize = blockHeaderSize
+	if err := q.flushMetainfo(); err != nil {
+		logger.Panicf("FATAL: cannot flush metainfo: %s", err)
+	}
+}
+
 // MustClose closes q.
 //
 // MustWriteBlock mustn't be called during and after the call to MustClose.
@@ -356,7 +456,7 @@ func (q *queue) MustWriteBlock(block []byte) {
 	}
 	if q.maxPendingBytes > 0 {
 		// Drain the oldest blocks until the number of pending bytes becomes enough for the block.
-		blockSize := uint64(len(block) + 8)
+		blockSize := uint64(len(block)) + q.blockHeaderSize + q.blockOverhead
 		maxPendingBytes := q.maxPendingBytes
 		if blockSize < maxPendingBytes {
 			maxPendingBytes -= blockSize
@@ -394,20 +494,31 @@ func (q *queue) writeBlock(block []byte) error {
 	defer func() {
 		writeDurationSeconds.Add(time.Since(startTime).Seconds())
 	}()
-	if q.writerLocalOffset+q.maxBlockSize+8 > q.chunkFileSize {
+	if q.writerLocalOffset+q.maxBlockSize+q.blockHeaderSize+q.blockOverhead > q.chunkFileSize {
 		if err := q.nextChunkFileForWrite(); err != nil {
 			return fmt.Errorf("cannot create next chunk file: %w", err)
 		}
 	}
 
-	// Write block len.
-	blockLen := uint64(len(block))
+	blockSize := len(block)
+	if q.enc != nil {
+		// Encrypt the block and bind it to its offset in the queue, so it cannot be moved without detection.
+		ad := headerBufPool.Get()
+		ad.B = blockAdditionalData(ad.B[:0], q.name, q.writerOffset)
+		bb := blockBufPool.Get()
+		bb.B = q.enc.seal(bb.B[:0], block, ad.B)
+		headerBufPool.Put(ad)
+		defer blockBufPool.Put(bb)
+		block = bb.B
+	}
+
+	// Write block header.
 	header := headerBufPool.Get()
-	header.B = encoding.MarshalUint64(header.B, blockLen)
+	header.B = marshalBlockHeader(header.B[:0], q.blockHeaderSize, block, fasttime.UnixTimestamp())
 	err := q.write(header.B)
 	headerBufPool.Put(header)
 	if err != nil {
-		return fmt.Errorf("cannot write header with size 8 bytes to %q: %w", q.writerPath, err)
+		return fmt.Errorf("cannot write header with size %d bytes to %q: %w", len(header.B), q.writerPath, err)
 	}
 
 	// Write block contents.
@@ -415,7 +526,7 @@ func (q *queue) writeBlock(block []byte) error {
 		return fmt.Errorf("cannot write block contents with size %d bytes to %q: %w", len(block), q.writerPath, err)
 	}
 	q.blocksWritten.Inc()
-	q.bytesWritten.Add(len(block))
+	q.bytesWritten.Add(blockSize)
 	return q.flushWriterMetainfoIfNeeded()
 }
 
@@ -467,57 +578,186 @@ func (q *queue) readBlock(dst []byte) ([]byte, error) {
 	defer func() {
 		readDurationSeconds.Add(time.Since(startTime).Seconds())
 	}()
-	if q.readerLocalOffset+q.maxBlockSize+8 > q.chunkFileSize {
-		if err := q.nextChunkFileForRead(); err != nil {
-			return dst, fmt.Errorf("cannot open next chunk file: %w", err)
-		}
+	if err := q.nextChunkFileForReadIfNeeded(); err != nil {
+		return dst, err
 	}
 
 again:
-	// Read block len.
+	blockOffset := q.readerOffset
+
+	// Read block header.
 	header := headerBufPool.Get()
-	header.B = bytesutil.ResizeNoCopyMayOverallocate(header.B, 8)
+	header.B = bytesutil.ResizeNoCopyMayOverallocate(header.B, legacyBlockHeaderSize)
 	err := q.readFull(header.B)
-	blockLen := encoding.UnmarshalUint64(header.B)
-	headerBufPool.Put(header)
+	blockLen, flags := unmarshalBlockLen(header.B)
 	if err != nil {
+		headerBufPool.Put(header)
 		logger.Errorf("skipping corrupted %q, since header with size 8 bytes cannot be read from it: %s", q.readerPath, err)
-		if err := q.skipBrokenChunkFile(); err != nil {
+		if err := q.skipBrokenChunkFile(blockOffset); err != nil {
 			return dst, err
 		}
 		goto again
 	}
-	if blockLen > q.maxBlockSize {
-		logger.Errorf("skipping corrupted %q, since too big block size is read from it: %d bytes; cannot exceed %d bytes", q.readerPath, blockLen, q.maxBlockSize)
-		if err := q.skipBrokenChunkFile(); err != nil {
+	headerTailSize, err := getBlockHeaderTailSize(flags)
+	if err != nil {
+		headerBufPool.Put(header)
+		logger.Errorf("skipping corrupted %q at offset %d: %s", q.readerPath, blockOffset, err)
+		if err := q.skipBrokenChunkFile(blockOffset); err != nil {
+			return dst, err
+		}
+		goto again
+	}
+	enc := q.getEncryption(blockOffset)
+	if maxBlockLen := q.maxBlockSize + q.getBlockOverhead(blockOffset); blockLen > maxBlockLen {
+		headerBufPool.Put(header)
+		logger.Errorf("skipping corrupted %q, since too big block size is read from it: %d bytes; cannot exceed %d bytes", q.readerPath, blockLen, maxBlockLen)
+		if err := q.skipBrokenChunkFile(blockOffset); err != nil {
 			return dst, err
 		}
 		goto again
 	}
+	// Blocks written by previous releases have no timestamp and checksum.
+	var timestamp, checksum uint64
+	if headerTailSize > 0 {
+		header.B = bytesutil.ResizeWithCopyMayOverallocate(header.B, int(legacyBlockHeaderSize+headerTailSize))
+		if err := q.readFull(header.B[legacyBlockHeaderSize:]); err != nil {
+			headerBufPool.Put(header)
+			logger.Errorf("skipping corrupted %q, since block header with size %d bytes cannot be read from it: %s", q.readerPath, len(header.B), err)
+			if err := q.skipBrokenChunkFile(blockOffset); err != nil {
+				return dst, err
+			}
+			goto again
+		}
+		timestamp = encoding.UnmarshalUint64(header.B[legacyBlockHeaderSize:])
+		if flags&blockFlagChecksum != 0 {
+			checksum = encoding.UnmarshalUint64(header.B[timestampBlockHeaderSize:])
+		}
+	}
 
 	// Read block contents.
+	// Encrypted contents are read into a temporary buffer, while plaintext contents are read directly into dst.
 	dstLen := len(dst)
-	dst = bytesutil.ResizeWithCopyMayOverallocate(dst, dstLen+int(blockLen))
-	if err := q.readFull(dst[dstLen:]); err != nil {
-		logger.Errorf("skipping corrupted %q, since contents with size %d bytes cannot be read from it: %s", q.readerPath, blockLen, err)
-		if err := q.skipBrokenChunkFile(); err != nil {
-			return dst[:dstLen], err
+	var bb *bytesutil.ByteBuffer
+	var buf []byte
+	if enc != nil {
+		bb = blockBufPool.Get()
+		bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, int(blockLen))
+		buf = bb.B
+	} else {
+		dst = bytesutil.ResizeWithCopyMayOverallocate(dst, dstLen+int(blockLen))
+		buf = dst[dstLen:]
+	}
+	err = q.readFull(buf)
+	if err == nil && flags&blockFlagChecksum != 0 {
+		if checksumExpected := blockChecksum(header.B[:timestampBlockHeaderSize], buf); checksum != checksumExpected {
+			err = fmt.Errorf("checksum mismatch for the block with size %d bytes at offset %d; got 0x%016X; want 0x%016X", blockLen, blockOffset, checksum, checksumExpected)
+		}
+	}
+	headerBufPool.Put(header)
+	if err == nil && q.isExpiredBlock(timestamp) {
+		q.blocksExpired.Inc()
+		q.bytesExpired.Add(int(blockLen))
+		err = errSkipBlock
+	}
+	if err == nil && enc != nil {
+		ad := headerBufPool.Get()
+		ad.B = blockAdditionalData(ad.B[:0], q.name, blockOffset)
+		dst, err = enc.open(dst, buf, ad.B)
+		headerBufPool.Put(ad)
+		if err != nil {
+			// The block checksum is valid, so just skip the block, which cannot be authenticated.
+			q.blocksAuthFailed.Inc()
+			logger.Errorf("skipping the block with size %d bytes at offset %d in %q: %s", blockLen, blockOffset, q.readerPath, err)
+			err = errSkipBlock
+		}
+	}
+	if bb != nil {
+		blockBufPool.Put(bb)
+	}
+	if err != nil {
+		dst = dst[:dstLen]
+		if err != errSkipBlock {
+			logger.Errorf("skipping corrupted %q, since contents with size %d bytes cannot be read from it: %s", q.readerPath, blockLen, err)
+			if err := q.skipBrokenChunkFile(blockOffset); err != nil {
+				return dst, err
+			}
+			goto again
+		}
+		if err := q.skipBlock(); err != nil {
+			return dst, err
 		}
 		goto again
 	}
 	q.blocksRead.Inc()
-	q.bytesRead.Add(int(blockLen))
+	q.bytesRead.Add(len(dst) - dstLen)
 	if err := q.flushReaderMetainfoIfNeeded(); err != nil {
 		return dst, err
 	}
 	return dst, nil
 }
 
+// isExpiredBlock returns true if the block written at the given timestamp is older than q.maxBlockAge.
+func (q *queue) isExpiredBlock(timestamp uint64) bool {
+	if q.maxBlockAge <= 0 || timestamp == 0 {
+		return false
+	}
+	deadline := timestamp + uint64(q.maxBlockAge.Seconds())
+	return fasttime.UnixTimestamp() > deadline
+}
+
+// skipBlock prepares q for reading the next block after the current block has been skipped.
+//
+// errEmptyQueue is returned if there are no more blocks in q.
+func (q *queue) skipBlock() error {
+	if q.readerOffset >= q.writerOffset {
+		return errEmptyQueue
+	}
+	return q.nextChunkFileForReadIfNeeded()
+}
+
+func (q *queue) nextChunkFileForReadIfNeeded() error {
+	if q.readerLocalOffset+q.maxBlockSize+q.blockHeaderSize+q.getBlockOverhead(q.readerOffset) <= q.chunkFileSize {
+		return nil
+	}
+	if err := q.nextChunkFileForRead(); err != nil {
+		return fmt.Errorf("cannot open next chunk file: %w", err)
+	}
+	return nil
+}
+
 var readDurationSeconds = metrics.NewFloatCounter(`vm_persistentqueue_read_duration_seconds_total`)
 
-func (q *queue) skipBrokenChunkFile() error {
+// getEncryption returns the encryption for the block at the given offset.
+//
+// nil is returned for plaintext blocks.
+func (q *queue) getEncryption(offset uint64) *Encryption {
+	if offset < q.encryptedOffset {
+		return nil
+	}
+	return q.enc
+}
+
+// getBlockOverhead returns the blockOverhead for the block at the given offset.
+//
+// The reader must use the same overhead as the writer for locating chunk file boundaries.
+func (q *queue) getBlockOverhead(offset uint64) uint64 {
+	if offset < q.encryptedOffset {
+		return 0
+	}
+	return q.blockOverhead
+}
+
+// skipBrokenChunkFile skips the remaining part of the current chunk file starting from the corrupted block at corruptedOffset.
+func (q *queue) skipBrokenChunkFile(corruptedOffset uint64) error {
 	// Try to recover from broken chunk file by skipping it.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1030
+	chunkEndOffset := corruptedOffset - corruptedOffset%q.chunkFileSize + q.chunkFileSize
+	n := min(chunkEndOffset, q.writerOffset) - corruptedOffset
+	q.corruptedRegions.Inc()
+	q.bytesCorrupted.Add(int(n))
+	if q.quarantineDir != "" {
+		q.mustQuarantine(corruptedOffset, n)
+	}
 	q.readerOffset += q.chunkFileSize - q.readerOffset%q.chunkFileSize
 	if q.readerOffset >= q.writerOffset {
 		q.mustResetFiles()
@@ -528,6 +768,48 @@ func (q *queue) skipBrokenChunkFile() error {
 
 var errEmptyQueue = fmt.Errorf("the queue is empty")
 
+// mustQuarantine copies up to n bytes starting from the given offset in the current chunk file to q.quarantineDir.
+//
+// The copied data can be inspected later with Inspect.
+func (q *queue) mustQuarantine(offset, n uint64) {
+	if err := q.quarantine(offset, n); err != nil {
+		logger.Errorf("cannot quarantine corrupted data from %q: %s", q.readerPath, err)
+	}
+}
+
+func (q *queue) quarantine(offset, n uint64) error {
+	if q.writerPath == q.readerPath {
+		// Make sure all the data is written to the chunk file before copying it.
+		q.writer.MustFlush(false)
+		q.writerFlushedOffset = q.writerOffset
+	}
+	src, err := os.Open(q.readerPath)
+	if err != nil {
+		return err
+	}
+	defer fs.MustClose(src)
+	if _, err := src.Seek(int64(offset%q.chunkFileSize), io.SeekStart); err != nil {
+		return err
+	}
+	fs.MustMkdirIfNotExist(q.quarantineDir)
+	dstPath := filepath.Join(q.quarantineDir, fmt.Sprintf("%016X", offset))
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(dst, io.LimitReader(src, int64(n)))
+	fs.MustClose(dst)
+	q.bytesQuarantined.Add(int(written))
+	if err != nil {
+		return fmt.Errorf("cannot copy corrupted data to %q: %w", dstPath, err)
+	}
+	logger.Warnf("copied %d bytes of corrupted data from %q to %q", written, q.readerPath, dstPath)
+	return nil
+}
+
+// errSkipBlock is used internally by readBlock for blocks, which must be skipped without skipping the whole chunk file.
+var errSkipBlock = fmt.Errorf("the block must be skipped")
+
 func (q *queue) nextChunkFileForRead() error {
 	// Remove the current chunk and go to the next chunk.
 	q.reader.MustClose()
@@ -616,12 +898,16 @@ func (q *queue) flushWriterMetainfoIfNeeded() error {
 
 func (q *queue) flushMetainfo() error {
 	mi := &metainfo{
-		Name:         q.name,
-		ReaderOffset: q.readerOffset,
-		WriterOffset: q.writerOffset,
+		Name:            q.name,
+		ReaderOffset:    q.readerOffset,
+		WriterOffset:    q.writerOffset,
+		BlockHeaderSize: q.blockHeaderSize,
+	}
+	if q.readerOffset < q.encryptedOffset {
+		mi.EncryptedOffset = q.encryptedOffset
 	}
 	metainfoPath := q.metainfoPath()
-	if err := mi.WriteToFile(metainfoPath); err != nil {
+	if err := mi.WriteToFile(metainfoPath, q.enc); err != nil {
 		return fmt.Errorf("cannot write metainfo to %q: %w", metainfoPath, err)
 	}
 	return nil
@@ -629,22 +915,115 @@ func (q *queue) flushMetainfo() error {
 
 var headerBufPool bytesutil.ByteBufferPool
 
+const (
+	// blockHeaderSize is the size of the header for every block written to the queue.
+	//
+	// The header consists of 8-byte block length with flags in the most significant byte,
+	// 8-byte unix timestamp in seconds when the block has been written
+	// and 8-byte checksum for the preceding header fields and the block contents.
+	blockHeaderSize = 24
+
+	// timestampBlockHeaderSize is the size of the header for blocks with the timestamp, but without the checksum.
+	timestampBlockHeaderSize = 16
+
+	// legacyBlockHeaderSize is the size of the header for blocks without the timestamp and the checksum.
+	//
+	// The header consists of 8-byte block length.
+	legacyBlockHeaderSize = 8
+
+	// blockFlagTimestamp is set if the block length is followed by the timestamp.
+	blockFlagTimestamp = 1 << 0
+
+	// blockFlagChecksum is set if the timestamp is followed by the checksum.
+	blockFlagChecksum = 1 << 1
+
+	blockLenMask = 1<<56 - 1
+)
+
+// marshalBlockHeader appends the header with the given headerSize for the given block to dst and returns the result.
+func marshalBlockHeader(dst []byte, headerSize uint64, block []byte, timestamp uint64) []byte {
+	blockLen := uint64(len(block))
+	switch headerSize {
+	case legacyBlockHeaderSize:
+		return encoding.MarshalUint64(dst, blockLen)
+	case timestampBlockHeaderSize:
+		dst = encoding.MarshalUint64(dst, blockLen|blockFlagTimestamp<<56)
+		return encoding.MarshalUint64(dst, timestamp)
+	case blockHeaderSize:
+		dstLen := len(dst)
+		dst = encoding.MarshalUint64(dst, blockLen|(blockFlagTimestamp|blockFlagChecksum)<<56)
+		dst = encoding.MarshalUint64(dst, timestamp)
+		checksum := blockChecksum(dst[dstLen:], block)
+		return encoding.MarshalUint64(dst, checksum)
+	default:
+		logger.Panicf("BUG: unexpected block header size: %d", headerSize)
+		return dst
+	}
+}
+
+// blockChecksum returns the checksum for the given block and the header fields preceding the checksum.
+func blockChecksum(header, block []byte) uint64 {
+	var d xxhash.Digest
+	d.Reset()
+	_, _ = d.Write(header)
+	_, _ = d.Write(block)
+	return d.Sum64()
+}
+
+// getBlockHeaderTailSize returns the size of the header fields following the block length for the given flags.
+func getBlockHeaderTailSize(flags byte) (uint64, error) {
+	switch flags {
+	case 0:
+		return 0, nil
+	case blockFlagTimestamp:
+		return timestampBlockHeaderSize - legacyBlockHeaderSize, nil
+	case blockFlagTimestamp | blockFlagChecksum:
+		return blockHeaderSize - legacyBlockHeaderSize, nil
+	default:
+		return 0, fmt.Errorf("unsupported block flags: 0x%02X", flags)
+	}
+}
+
+func unmarshalBlockLen(src []byte) (uint64, byte) {
+	n := encoding.UnmarshalUint64(src)
+	return n & blockLenMask, byte(n >> 56)
+}
+
 type metainfo struct {
 	Name         string
 	ReaderOffset uint64
 	WriterOffset uint64
+
+	// BlockHeaderSize is the size of the header for blocks written to the queue.
+	//
+	// It is missing in queues created by previous releases.
+	BlockHeaderSize uint64 `json:",omitempty"`
+
+	// EncryptedOffset is the offset starting from which blocks are encrypted.
+	//
+	// It is set if the queue contains plaintext blocks written before enabling the encryption.
+	EncryptedOffset uint64 `json:",omitempty"`
+
+	// encrypted is set to true if the metainfo has been read from the encrypted file.
+	encrypted bool
 }
 
 func (mi *metainfo) Reset() {
 	mi.ReaderOffset = 0
 	mi.WriterOffset = 0
+	mi.BlockHeaderSize = 0
+	mi.EncryptedOffset = 0
+	mi.encrypted = false
 }
 
-func (mi *metainfo) WriteToFile(path string) error {
+func (mi *metainfo) WriteToFile(path string, enc *Encryption) error {
 	data, err := json.Marshal(mi)
 	if err != nil {
 		return fmt.Errorf("cannot marshal persistent queue metainfo %#v: %w", mi, err)
 	}
+	if enc != nil {
+		data = enc.sealMetainfo(data, mi.ReaderOffset, mi.WriterOffset)
+	}
 	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("cannot write persistent queue metainfo to %q: %w", path, err)
 	}
@@ -652,7 +1031,13 @@ func (mi *metainfo) WriteToFile(path string) error {
 	return nil
 }
 
-func (mi *metainfo) ReadFromFile(path string) error {
+// errEncryptionMismatch is returned when the queue has been written with other encryption settings.
+//
+// Such a queue mustn't be re-created if it contains the pending data, since the data can be read after restoring the encryption settings.
+// ReadFromFile sets reader and writer offsets for such a queue, so the caller can check whether it is empty.
+var errEncryptionMismatch = errors.New("encryption settings mismatch")
+
+func (mi *metainfo) ReadFromFile(path string, enc *Encryption) error {
 	mi.Reset()
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -661,9 +1046,27 @@ func (mi *metainfo) ReadFromFile(path string) error {
 		}
 		return fmt.Errorf("cannot read %q: %w", path, err)
 	}
+	mi.encrypted = bytes.HasPrefix(data, encryptedMetainfoPrefix)
+	if mi.encrypted {
+		mi.ReaderOffset, mi.WriterOffset, err = unmarshalEncryptedMetainfoOffsets(data)
+		if err != nil {
+			return fmt.Errorf("cannot read persistent queue metainfo from %q: %w", path, err)
+		}
+		if enc == nil {
+			return fmt.Errorf("%w: metainfo at %q is encrypted, while the encryption is disabled", errEncryptionMismatch, path)
+		}
+		data, err = enc.openMetainfo(data)
+		if err != nil {
+			return fmt.Errorf("%w: cannot decrypt persistent queue metainfo from %q: %s", errEncryptionMismatch, path, err)
+		}
+	}
 	if err := json.Unmarshal(data, mi); err != nil {
 		return fmt.Errorf("cannot unmarshal persistent queue metainfo from %q: %w", path, err)
 	}
+	if enc != nil && !mi.encrypted {
+		// The encryption has been enabled for the existing queue, so the pending blocks are stored in plaintext.
+		mi.EncryptedOffset = mi.WriterOffset
+	}
 	if mi.ReaderOffset > mi.WriterOffset {
 		return fmt.Errorf("invalid data read from %q: readerOffset=%d cannot exceed writerOffset=%d", path, mi.ReaderOffset, mi.WriterOffset)
 	}
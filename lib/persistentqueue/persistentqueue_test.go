@@ -6,6 +6,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 )
 
 func TestQueueOpenClose(t *testing.T) {
@@ -66,7 +69,7 @@ func TestQueueOpen(t *testing.T) {
 			ReaderOffset: DefaultChunkFileSize,
 			WriterOffset: DefaultChunkFileSize,
 		}
-		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename)); err != nil {
+		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename), nil); err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
 		mustCreateFile(filepath.Join(path, fmt.Sprintf("%016X", 0)), "adfsfd")
@@ -81,7 +84,7 @@ func TestQueueOpen(t *testing.T) {
 			Name:         "foobar",
 			ReaderOffset: DefaultChunkFileSize + 123,
 		}
-		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename)); err != nil {
+		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename), nil); err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
 		q := mustOpen(path, mi.Name, 0)
@@ -104,7 +107,7 @@ func TestQueueOpen(t *testing.T) {
 			ReaderOffset: 123,
 			WriterOffset: 123,
 		}
-		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename)); err != nil {
+		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename), nil); err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
 		mustCreateFile(filepath.Join(path, fmt.Sprintf("%016X", 0)), "sdf")
@@ -127,7 +130,7 @@ func TestQueueOpen(t *testing.T) {
 		mi := &metainfo{
 			Name: "foobar",
 		}
-		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename)); err != nil {
+		if err := mi.WriteToFile(filepath.Join(path, metainfoFilename), nil); err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
 		mustCreateFile(filepath.Join(path, fmt.Sprintf("%016X", 0)), "sdf")
@@ -250,7 +253,7 @@ func TestQueueChunkManagementSimple(t *testing.T) {
 	mustDeleteDir(path)
 	const chunkFileSize = 100
 	const maxBlockSize = 20
-	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0)
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
 	defer mustDeleteDir(path)
 	defer q.MustClose()
 	var blocks []string
@@ -281,7 +284,7 @@ func TestQueueChunkManagementPeriodicClose(t *testing.T) {
 	mustDeleteDir(path)
 	const chunkFileSize = 100
 	const maxBlockSize = 20
-	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0)
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
 	defer func() {
 		q.MustClose()
 		mustDeleteDir(path)
@@ -292,7 +295,7 @@ func TestQueueChunkManagementPeriodicClose(t *testing.T) {
 		q.MustWriteBlock([]byte(block))
 		blocks = append(blocks, block)
 		q.MustClose()
-		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0)
+		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
 	}
 	if n := q.GetPendingBytes(); n == 0 {
 		t.Fatalf("unexpected zero number of bytes pending")
@@ -306,7 +309,7 @@ func TestQueueChunkManagementPeriodicClose(t *testing.T) {
 			t.Fatalf("unexpected block read; got %q; want %q", data, block)
 		}
 		q.MustClose()
-		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0)
+		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
 	}
 	if n := q.GetPendingBytes(); n != 0 {
 		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
@@ -370,6 +373,205 @@ func TestQueueLimitedSize(t *testing.T) {
 	}
 }
 
+func TestQueueMaxBlockAge(t *testing.T) {
+	path := t.TempDir()
+	const chunkFileSize = 1000
+	const maxBlockSize = 20
+	q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+	for i := 0; i < 4; i++ {
+		q.MustWriteBlock([]byte(fmt.Sprintf("block %d", i)))
+	}
+	q.MustClose()
+
+	// Make all the blocks except of the third block stale.
+	chunkPath := filepath.Join(path, fmt.Sprintf("%016X", 0))
+	data, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatalf("cannot read chunk file: %s", err)
+	}
+	blockSize := blockHeaderSize + len("block 0")
+	for _, i := range []int{0, 1, 3} {
+		ts := uint64(time.Now().Add(-2 * time.Hour).Unix())
+		copy(data[i*blockSize+8:], encoding.MarshalUint64(nil, ts))
+		mustUpdateBlockChecksum(data[i*blockSize:])
+	}
+	if err := os.WriteFile(chunkPath, data, 0600); err != nil {
+		t.Fatalf("cannot write chunk file: %s", err)
+	}
+
+	q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+	defer q.MustClose()
+	q.maxBlockAge = time.Hour
+	blocksExpired := q.blocksExpired.Get()
+	buf, ok := q.MustReadBlockNonblocking(nil)
+	if !ok {
+		t.Fatalf("unexpected ok=false")
+	}
+	if string(buf) != "block 2" {
+		t.Fatalf("unexpected block read; got %q; want %q", buf, "block 2")
+	}
+	if n := q.blocksExpired.Get() - blocksExpired; n != 2 {
+		t.Fatalf("unexpected number of expired blocks; got %d; want 2", n)
+	}
+
+	// The stale block at the end of the queue must be dropped.
+	if buf, ok := q.MustReadBlockNonblocking(nil); ok {
+		t.Fatalf("unexpected block read: %q", buf)
+	}
+	if n := q.blocksExpired.Get() - blocksExpired; n != 3 {
+		t.Fatalf("unexpected number of expired blocks; got %d; want 3", n)
+	}
+	if n := q.GetPendingBytes(); n != 0 {
+		t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+	}
+}
+
+func TestQueueLegacyBlockFormat(t *testing.T) {
+	path := t.TempDir()
+
+	// Create the queue in the format used by previous releases.
+	var data []byte
+	for i := 0; i < 3; i++ {
+		block := fmt.Sprintf("block %d", i)
+		data = encoding.MarshalUint64(data, uint64(len(block)))
+		data = append(data, block...)
+	}
+	mustCreateFile(filepath.Join(path, fmt.Sprintf("%016X", 0)), string(data))
+	mustCreateFile(filepath.Join(path, metainfoFilename), fmt.Sprintf(`{"Name":"foobar","ReaderOffset":0,"WriterOffset":%d}`, len(data)))
+
+	q := mustOpen(path, "foobar", 0)
+	defer q.MustClose()
+	if q.blockHeaderSize != legacyBlockHeaderSize {
+		t.Fatalf("unexpected block header size for non-empty legacy queue; got %d; want %d", q.blockHeaderSize, legacyBlockHeaderSize)
+	}
+
+	// New blocks must be written in the legacy format until the queue is drained.
+	q.MustWriteBlock([]byte("block 3"))
+	for i := 0; i < 4; i++ {
+		buf, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if block := fmt.Sprintf("block %d", i); string(buf) != block {
+			t.Fatalf("unexpected block read; got %q; want %q", buf, block)
+		}
+	}
+
+	// The drained queue must be switched to the current format.
+	q.ResetIfEmpty()
+	if q.blockHeaderSize != blockHeaderSize {
+		t.Fatalf("unexpected block header size for drained queue; got %d; want %d", q.blockHeaderSize, blockHeaderSize)
+	}
+	var mi metainfo
+	if err := mi.ReadFromFile(q.metainfoPath(), nil); err != nil {
+		t.Fatalf("cannot read metainfo: %s", err)
+	}
+	if mi.BlockHeaderSize != blockHeaderSize {
+		t.Fatalf("unexpected block header size in metainfo; got %d; want %d", mi.BlockHeaderSize, blockHeaderSize)
+	}
+	q.MustWriteBlock([]byte("foo"))
+	buf, ok := q.MustReadBlockNonblocking(nil)
+	if !ok {
+		t.Fatalf("unexpected ok=false")
+	}
+	if string(buf) != "foo" {
+		t.Fatalf("unexpected block read; got %q; want %q", buf, "foo")
+	}
+}
+
+func TestQueueCorruptedBlock(t *testing.T) {
+	f := func(quarantine bool) {
+		t.Helper()
+		path := t.TempDir()
+		const chunkFileSize = 1000
+		const maxBlockSize = 20
+		q := mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+		for i := 0; i < 5; i++ {
+			q.MustWriteBlock([]byte(fmt.Sprintf("block %d", i)))
+		}
+		q.MustClose()
+
+		// Flip a bit in the contents of the second block.
+		chunkPath := filepath.Join(path, fmt.Sprintf("%016X", 0))
+		data, err := os.ReadFile(chunkPath)
+		if err != nil {
+			t.Fatalf("cannot read chunk file: %s", err)
+		}
+		blockSize := blockHeaderSize + len("block 0")
+		data[blockSize+blockHeaderSize] ^= 1
+		if err := os.WriteFile(chunkPath, data, 0600); err != nil {
+			t.Fatalf("cannot write chunk file: %s", err)
+		}
+
+		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+		defer func() {
+			q.MustClose()
+		}()
+		if quarantine {
+			q.quarantineDir = filepath.Join(path, quarantineDirname)
+		}
+		corruptedRegions := q.corruptedRegions.Get()
+		bytesCorrupted := q.bytesCorrupted.Get()
+		bytesQuarantined := q.bytesQuarantined.Get()
+
+		buf, ok := q.MustReadBlockNonblocking(nil)
+		if !ok {
+			t.Fatalf("unexpected ok=false")
+		}
+		if string(buf) != "block 0" {
+			t.Fatalf("unexpected block read; got %q; want %q", buf, "block 0")
+		}
+
+		// The data starting from the corrupted block must be skipped, since the next blocks cannot be located reliably.
+		if buf, ok := q.MustReadBlockNonblocking(nil); ok {
+			t.Fatalf("unexpected block read: %q", buf)
+		}
+		if n := q.corruptedRegions.Get() - corruptedRegions; n != 1 {
+			t.Fatalf("unexpected number of corrupted regions; got %d; want 1", n)
+		}
+		corruptedSize := uint64(4 * blockSize)
+		if n := q.bytesCorrupted.Get() - bytesCorrupted; n != corruptedSize {
+			t.Fatalf("unexpected number of corrupted bytes; got %d; want %d", n, corruptedSize)
+		}
+
+		quarantinePath := filepath.Join(path, quarantineDirname, fmt.Sprintf("%016X", blockSize))
+		quarantinedData, err := os.ReadFile(quarantinePath)
+		if !quarantine {
+			if err == nil {
+				t.Fatalf("unexpected quarantined data at %q", quarantinePath)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("cannot read quarantined data: %s", err)
+		}
+		if string(quarantinedData) != string(data[blockSize:]) {
+			t.Fatalf("unexpected quarantined data; got %X; want %X", quarantinedData, data[blockSize:])
+		}
+		if n := q.bytesQuarantined.Get() - bytesQuarantined; n != corruptedSize {
+			t.Fatalf("unexpected number of quarantined bytes; got %d; want %d", n, corruptedSize)
+		}
+
+		// The queue must be opened with quarantined data.
+		q.MustClose()
+		q = mustOpenInternal(path, "foobar", chunkFileSize, maxBlockSize, 0, nil)
+		if n := q.GetPendingBytes(); n != 0 {
+			t.Fatalf("unexpected non-zero number of pending bytes: %d", n)
+		}
+	}
+
+	f(false)
+	f(true)
+}
+
+// mustUpdateBlockChecksum updates the checksum for the block at the beginning of data after the block has been modified.
+func mustUpdateBlockChecksum(data []byte) {
+	blockLen, _ := unmarshalBlockLen(data)
+	block := data[blockHeaderSize : blockHeaderSize+blockLen]
+	checksum := blockChecksum(data[:timestampBlockHeaderSize], block)
+	copy(data[timestampBlockHeaderSize:], encoding.MarshalUint64(nil, checksum))
+}
+
 func mustCreateFile(path, contents string) {
 	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
 		panic(fmt.Errorf("cannot create file %q with %d bytes contents: %w", path, len(contents), err))
@@ -392,7 +594,7 @@ func mustDeleteDir(path string) {
 func mustCreateEmptyMetainfo(path, name string) {
 	var mi metainfo
 	mi.Name = name
-	if err := mi.WriteToFile(filepath.Join(path, metainfoFilename)); err != nil {
+	if err := mi.WriteToFile(filepath.Join(path, metainfoFilename), nil); err != nil {
 		panic(fmt.Errorf("cannot create metainfo: %w", err))
 	}
 }
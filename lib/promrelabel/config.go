@@ -42,6 +42,22 @@ type RelabelConfig struct {
 	//     job: '$1'
 	//     instance: '${2}:8080'
 	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Table is used for `action: lookup`. It must contain path to CSV, JSON or YAML file with the lookup table. For example:
+	// - action: lookup
+	//   source_labels: [instance]
+	//   table: /path/to/cmdb.csv
+	//   key_columns: [host]
+	//   columns: [team, datacenter]
+	Table string `yaml:"table,omitempty"`
+
+	// KeyColumns is used for `action: lookup`. It contains table columns, which are matched against source_labels.
+	// By default, the column names are equal to source_labels.
+	KeyColumns []string `yaml:"key_columns,flow,omitempty"`
+
+	// Columns is used for `action: lookup`. It contains table columns, which are set as labels on the matching row.
+	// By default, all the columns except of key_columns are set.
+	Columns []string `yaml:"columns,flow,omitempty"`
 }
 
 // MultiLineRegex contains a regex, which can be split into multiple lines.
@@ -260,6 +276,7 @@ func parseRelabelConfig(rc *RelabelConfig) (*parsedRelabelConfig, error) {
 	if rc.Labels != nil {
 		graphiteLabelRules = newGraphiteLabelRules(rc.Labels)
 	}
+	var lookupTable *lookupTableRef
 	switch action {
 	case "graphite":
 		if graphiteMatchTemplate == nil {
@@ -388,6 +405,34 @@ func parseRelabelConfig(rc *RelabelConfig) (*parsedRelabelConfig, error) {
 		if targetLabel == "" {
 			return nil, fmt.Errorf("missing `target_label` for `action=%s`", action)
 		}
+	case "lookup":
+		if len(sourceLabels) == 0 {
+			return nil, fmt.Errorf("missing `source_labels` for `action=lookup`")
+		}
+		if rc.Table == "" {
+			return nil, fmt.Errorf("missing `table` for `action=lookup`")
+		}
+		if targetLabel != "" {
+			return nil, fmt.Errorf("`target_label` cannot be used for `action=lookup`; use `columns` instead")
+		}
+		if rc.Regex != nil {
+			return nil, fmt.Errorf("`regex` cannot be used for `action=lookup`")
+		}
+		if rc.Replacement != nil {
+			return nil, fmt.Errorf("`replacement` cannot be used for `action=lookup`")
+		}
+		keyColumns := rc.KeyColumns
+		if len(keyColumns) == 0 {
+			keyColumns = sourceLabels
+		}
+		if len(keyColumns) != len(sourceLabels) {
+			return nil, fmt.Errorf("the number of `key_columns` must match the number of `source_labels` for `action=lookup`; got %d vs %d", len(keyColumns), len(sourceLabels))
+		}
+		lt, err := getLookupTable(rc.Table, keyColumns, rc.Columns, separator)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load `table` for `action=lookup`: %w", err)
+		}
+		lookupTable = lt
 	case "labelmap":
 	case "labelmap_all":
 	case "labeldrop":
@@ -395,6 +440,17 @@ func parseRelabelConfig(rc *RelabelConfig) (*parsedRelabelConfig, error) {
 	default:
 		return nil, fmt.Errorf("unknown `action` %q", action)
 	}
+	if action != "lookup" {
+		if rc.Table != "" {
+			return nil, fmt.Errorf("`table` config cannot be applied to `action=%s`; it is applied only to `action=lookup`", action)
+		}
+		if len(rc.KeyColumns) > 0 {
+			return nil, fmt.Errorf("`key_columns` config cannot be applied to `action=%s`; it is applied only to `action=lookup`", action)
+		}
+		if len(rc.Columns) > 0 {
+			return nil, fmt.Errorf("`columns` config cannot be applied to `action=%s`; it is applied only to `action=lookup`", action)
+		}
+	}
 	if action != "graphite" {
 		if graphiteMatchTemplate != nil {
 			return nil, fmt.Errorf("`match` config cannot be applied to `action=%s`; it is applied only to `action=graphite`", action)
@@ -422,6 +478,8 @@ func parseRelabelConfig(rc *RelabelConfig) (*parsedRelabelConfig, error) {
 		graphiteMatchTemplate: graphiteMatchTemplate,
 		graphiteLabelRules:    graphiteLabelRules,
 
+		lookupTable: lookupTable,
+
 		regex:         promRegex,
 		regexOriginal: regexOriginalCompiled,
 
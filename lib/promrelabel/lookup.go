@@ -0,0 +1,356 @@
+package promrelabel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/metrics"
+	"gopkg.in/yaml.v2"
+)
+
+// lookupTablesCheckInterval is the interval for checking lookup table files for changes.
+var lookupTablesCheckInterval = 10 * time.Second
+
+// lookupTable is a table for `action: lookup`.
+//
+// It maps the key built from key columns to labels built from the remaining columns.
+// The table is automatically reloaded when the underlying file changes.
+type lookupTable struct {
+	// key is the key for the table at lookupTables.
+	key string
+
+	// refCount is the number of lookupTableRef references to the table.
+	// It is protected by lookupTablesLock.
+	refCount int
+
+	path       string
+	keyColumns []string
+	columns    []string
+	separator  string
+
+	// index holds the current map[string][]prompbmarshal.Label
+	index atomic.Pointer[map[string][]prompbmarshal.Label]
+
+	// modTime and size are used for detecting changes in the file at path.
+	// They are protected by reloadLock.
+	reloadLock sync.Mutex
+	modTime    time.Time
+	size       int64
+
+	hits         *metrics.Counter
+	misses       *metrics.Counter
+	reloads      *metrics.Counter
+	reloadErrors *metrics.Counter
+}
+
+// lookupTableRef is a reference to the lookupTable shared among relabeling rules.
+//
+// Relabeling rules have no explicit lifetime, so the reference is released when it is garbage collected.
+// The lookupTable is removed from lookupTables after all the references to it are released.
+type lookupTableRef struct {
+	*lookupTable
+}
+
+var (
+	lookupTablesLock sync.Mutex
+	lookupTables     = make(map[string]*lookupTable)
+
+	lookupTablesWatcherOnce sync.Once
+)
+
+// getLookupTable returns a reference to lookup table for the given args.
+//
+// Lookup tables are shared among relabeling rules with identical args, so they are loaded and reloaded only once.
+func getLookupTable(path string, keyColumns, columns []string, separator string) (*lookupTableRef, error) {
+	key := fmt.Sprintf("path=%q, keyColumns=%q, columns=%q, separator=%q", path, keyColumns, columns, separator)
+
+	lookupTablesLock.Lock()
+	defer lookupTablesLock.Unlock()
+
+	lt := lookupTables[key]
+	if lt == nil {
+		var err error
+		lt, err = newLookupTable(key, path, keyColumns, columns, separator)
+		if err != nil {
+			return nil, err
+		}
+		lookupTables[key] = lt
+		lookupTablesWatcherOnce.Do(func() {
+			go lookupTablesWatcher()
+		})
+	}
+	lt.refCount++
+	ref := &lookupTableRef{
+		lookupTable: lt,
+	}
+	runtime.SetFinalizer(ref, releaseLookupTable)
+	return ref, nil
+}
+
+func releaseLookupTable(ref *lookupTableRef) {
+	lt := ref.lookupTable
+
+	lookupTablesLock.Lock()
+	defer lookupTablesLock.Unlock()
+
+	lt.refCount--
+	if lt.refCount > 0 {
+		return
+	}
+	delete(lookupTables, lt.key)
+
+	// Metrics are shared among tables with the same path.
+	for _, x := range lookupTables {
+		if x.path == lt.path {
+			return
+		}
+	}
+	metrics.UnregisterMetric(fmt.Sprintf(`vm_relabel_lookup_hits_total{table=%q}`, lt.path))
+	metrics.UnregisterMetric(fmt.Sprintf(`vm_relabel_lookup_misses_total{table=%q}`, lt.path))
+	metrics.UnregisterMetric(fmt.Sprintf(`vm_relabel_lookup_table_reloads_total{table=%q}`, lt.path))
+	metrics.UnregisterMetric(fmt.Sprintf(`vm_relabel_lookup_table_reload_errors_total{table=%q}`, lt.path))
+}
+
+func newLookupTable(key, path string, keyColumns, columns []string, separator string) (*lookupTable, error) {
+	lt := &lookupTable{
+		key:        key,
+		path:       path,
+		keyColumns: keyColumns,
+		columns:    columns,
+		separator:  separator,
+
+		hits:         metrics.GetOrCreateCounter(fmt.Sprintf(`vm_relabel_lookup_hits_total{table=%q}`, path)),
+		misses:       metrics.GetOrCreateCounter(fmt.Sprintf(`vm_relabel_lookup_misses_total{table=%q}`, path)),
+		reloads:      metrics.GetOrCreateCounter(fmt.Sprintf(`vm_relabel_lookup_table_reloads_total{table=%q}`, path)),
+		reloadErrors: metrics.GetOrCreateCounter(fmt.Sprintf(`vm_relabel_lookup_table_reload_errors_total{table=%q}`, path)),
+	}
+	if _, err := lt.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return lt, nil
+}
+
+func lookupTablesWatcher() {
+	t := time.NewTicker(lookupTablesCheckInterval)
+	defer t.Stop()
+	for range t.C {
+		reloadLookupTables()
+	}
+}
+
+// reloadLookupTables reloads lookup tables with changed files.
+func reloadLookupTables() {
+	lookupTablesLock.Lock()
+	lts := make([]*lookupTable, 0, len(lookupTables))
+	for _, lt := range lookupTables {
+		lts = append(lts, lt)
+	}
+	lookupTablesLock.Unlock()
+
+	for _, lt := range lts {
+		ok, err := lt.reloadIfChanged()
+		if err != nil {
+			logger.Errorf("cannot reload lookup table; continuing using the previously loaded table; error: %s", err)
+			continue
+		}
+		if ok {
+			logger.Infof("reloaded lookup table from %q", lt.path)
+		}
+	}
+}
+
+// get returns labels for the given key.
+//
+// It returns nil if the key is missing in lt.
+func (lt *lookupTable) get(key []byte) []prompbmarshal.Label {
+	m := *lt.index.Load()
+	labels, ok := m[string(key)]
+	if !ok {
+		lt.misses.Inc()
+		return nil
+	}
+	lt.hits.Inc()
+	return labels
+}
+
+// reloadIfChanged reloads lt if the underlying file has been changed since the last load.
+//
+// It returns true if the table has been reloaded.
+func (lt *lookupTable) reloadIfChanged() (bool, error) {
+	lt.reloadLock.Lock()
+	defer lt.reloadLock.Unlock()
+
+	fi, err := os.Stat(lt.path)
+	if err != nil {
+		lt.reloadErrors.Inc()
+		return false, fmt.Errorf("cannot access lookup table %q: %w", lt.path, err)
+	}
+	if lt.index.Load() != nil && fi.ModTime().Equal(lt.modTime) && fi.Size() == lt.size {
+		return false, nil
+	}
+	data, err := os.ReadFile(lt.path)
+	if err != nil {
+		lt.reloadErrors.Inc()
+		return false, fmt.Errorf("cannot read lookup table %q: %w", lt.path, err)
+	}
+	m, err := lt.newIndex(data)
+	if err != nil {
+		lt.reloadErrors.Inc()
+		return false, fmt.Errorf("cannot parse lookup table %q: %w", lt.path, err)
+	}
+	lt.index.Store(&m)
+	lt.modTime = fi.ModTime()
+	lt.size = fi.Size()
+	lt.reloads.Inc()
+	return true, nil
+}
+
+func (lt *lookupTable) newIndex(data []byte) (map[string][]prompbmarshal.Label, error) {
+	header, rows, err := parseLookupTableData(data, filepath.Ext(lt.path))
+	if err != nil {
+		return nil, err
+	}
+	headerIdx := make(map[string]int, len(header))
+	for i, column := range header {
+		headerIdx[column] = i
+	}
+	keyIdxs := make([]int, len(lt.keyColumns))
+	for i, column := range lt.keyColumns {
+		idx, ok := headerIdx[column]
+		if !ok {
+			return nil, fmt.Errorf("missing key column %q; available columns: %q", column, header)
+		}
+		keyIdxs[i] = idx
+	}
+	columns := lt.columns
+	if len(columns) == 0 {
+		// Use all the non-key columns.
+		for _, column := range header {
+			if !slices.Contains(lt.keyColumns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	columnIdxs := make([]int, len(columns))
+	for i, column := range columns {
+		idx, ok := headerIdx[column]
+		if !ok {
+			return nil, fmt.Errorf("missing column %q; available columns: %q", column, header)
+		}
+		columnIdxs[i] = idx
+	}
+
+	m := make(map[string][]prompbmarshal.Label, len(rows))
+	keyParts := make([]string, len(keyIdxs))
+	for _, row := range rows {
+		for i, idx := range keyIdxs {
+			keyParts[i] = row[idx]
+		}
+		labels := make([]prompbmarshal.Label, len(columnIdxs))
+		for i, idx := range columnIdxs {
+			labels[i] = prompbmarshal.Label{
+				Name:  columns[i],
+				Value: row[idx],
+			}
+		}
+		// The last row wins for duplicate keys.
+		m[strings.Join(keyParts, lt.separator)] = labels
+	}
+	return m, nil
+}
+
+// parseLookupTableData parses lookup table data according to the given file extension.
+//
+// It returns table header and rows. Every row has the same number of values as the header.
+func parseLookupTableData(data []byte, ext string) ([]string, [][]string, error) {
+	switch strings.ToLower(ext) {
+	case ".csv":
+		return parseLookupTableCSV(data)
+	case ".json":
+		var records []map[string]any
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, nil, fmt.Errorf("cannot parse JSON array of objects: %w", err)
+		}
+		return lookupTableFromRecords(records)
+	case ".yaml", ".yml":
+		var records []map[string]any
+		if err := yaml.Unmarshal(data, &records); err != nil {
+			return nil, nil, fmt.Errorf("cannot parse YAML list of objects: %w", err)
+		}
+		return lookupTableFromRecords(records)
+	default:
+		return nil, nil, fmt.Errorf("unsupported file extension %q; supported extensions: .csv, .json, .yaml, .yml", ext)
+	}
+}
+
+func parseLookupTableCSV(data []byte) ([]string, [][]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("missing CSV header with column names")
+	}
+	return records[0], records[1:], nil
+}
+
+func lookupTableFromRecords(records []map[string]any) ([]string, [][]string, error) {
+	columnsSet := make(map[string]struct{})
+	for _, record := range records {
+		for column := range record {
+			columnsSet[column] = struct{}{}
+		}
+	}
+	header := make([]string, 0, len(columnsSet))
+	for column := range columnsSet {
+		header = append(header, column)
+	}
+	sort.Strings(header)
+
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		row := make([]string, len(header))
+		for j, column := range header {
+			s, err := lookupTableValue(record[column])
+			if err != nil {
+				return nil, nil, fmt.Errorf("unexpected value for column %q at row #%d: %w", column, i+1, err)
+			}
+			row[j] = s
+		}
+		rows[i] = row
+	}
+	return header, rows, nil
+}
+
+func lookupTableValue(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	default:
+		return "", fmt.Errorf("got %T; want string, number or bool", v)
+	}
+}
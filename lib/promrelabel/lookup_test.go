@@ -0,0 +1,279 @@
+package promrelabel
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestLookupApply(t *testing.T) {
+	f := func(config, metric, resultExpected string) {
+		t.Helper()
+		pcs, err := ParseRelabelConfigsData([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", config, err)
+		}
+		labels := promutils.MustNewLabelsFromString(metric)
+		resultLabels := pcs.Apply(labels.GetLabels(), 0)
+		SortLabels(resultLabels)
+		result := LabelsToString(resultLabels)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// csv with multiple key columns
+	config := `
+- action: lookup
+  source_labels: [host, port]
+  table: testdata/lookup_table.csv
+`
+	f(config, `up{host="host2",port="8080"}`, `up{datacenter="dc2",host="host2",port="8080",team="web"}`)
+	f(config, `up{host="host2",port="9100",team="foo"}`, `up{datacenter="dc2",host="host2",port="9100",team="db"}`)
+	f(config, `up{host="host3",port="9100"}`, `up{host="host3",port="9100"}`)
+	f(config, `up{host="host1"}`, `up{host="host1"}`)
+
+	// csv with key_columns, columns and custom separator
+	f(`
+- action: lookup
+  source_labels: [node, node_port]
+  separator: ":"
+  table: testdata/lookup_table.csv
+  key_columns: [host, port]
+  columns: [team]
+`, `up{node="host1",node_port="9100"}`, `up{node="host1",node_port="9100",team="infra"}`)
+
+	// json
+	config = `
+- action: lookup
+  source_labels: [instance]
+  table: testdata/lookup_table.json
+`
+	f(config, `up{instance="host1:9100"}`, `up{cost_center="42",instance="host1:9100",team="infra"}`)
+	f(config, `up{instance="host2:9100"}`, `up{enabled="true",instance="host2:9100",team="db"}`)
+
+	// yaml
+	config = `
+- action: lookup
+  source_labels: [instance]
+  table: testdata/lookup_table.yml
+  columns: [cost_center]
+`
+	f(config, `up{instance="host1:9100"}`, `up{cost_center="42",instance="host1:9100"}`)
+	f(config, `up{instance="host2:9100",cost_center="1"}`, `up{instance="host2:9100"}`)
+
+	// lookup with if
+	f(`
+- action: lookup
+  if: 'up{host!="host1"}'
+  source_labels: [host, port]
+  table: testdata/lookup_table.csv
+`, `up{host="host1",port="9100"}`, `up{host="host1",port="9100"}`)
+}
+
+func TestLookupParseFailure(t *testing.T) {
+	f := func(config string) {
+		t.Helper()
+		if _, err := ParseRelabelConfigsData([]byte(config)); err == nil {
+			t.Fatalf("expecting non-nil error for %q", config)
+		}
+	}
+
+	// missing source_labels
+	f(`
+- action: lookup
+  table: testdata/lookup_table.csv
+`)
+
+	// missing table
+	f(`
+- action: lookup
+  source_labels: [host]
+`)
+
+	// missing table file
+	f(`
+- action: lookup
+  source_labels: [host]
+  table: testdata/missing.csv
+`)
+
+	// unsupported table format
+	f(`
+- action: lookup
+  source_labels: [host]
+  table: testdata/relabel_configs_valid.yml
+`)
+
+	// mismatched key_columns
+	f(`
+- action: lookup
+  source_labels: [host]
+  key_columns: [host, port]
+  table: testdata/lookup_table.csv
+`)
+
+	// missing key column
+	f(`
+- action: lookup
+  source_labels: [instance]
+  table: testdata/lookup_table.csv
+`)
+
+	// missing column
+	f(`
+- action: lookup
+  source_labels: [host, port]
+  columns: [missing]
+  table: testdata/lookup_table.csv
+`)
+
+	// target_label and regex aren't supported
+	f(`
+- action: lookup
+  source_labels: [host, port]
+  target_label: foo
+  table: testdata/lookup_table.csv
+`)
+	f(`
+- action: lookup
+  source_labels: [host, port]
+  regex: foo
+  table: testdata/lookup_table.csv
+`)
+
+	// table for non-lookup action
+	f(`
+- action: replace
+  source_labels: [host]
+  target_label: foo
+  table: testdata/lookup_table.csv
+`)
+}
+
+func TestLookupTableReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.csv")
+	mustWriteFile(t, path, "instance,team\nhost1,infra\n")
+
+	pcs, err := ParseRelabelConfigsData([]byte(`
+- action: lookup
+  source_labels: [instance]
+  table: ` + path))
+	if err != nil {
+		t.Fatalf("cannot parse relabel configs: %s", err)
+	}
+	f := func(metric, resultExpected string) {
+		t.Helper()
+		labels := promutils.MustNewLabelsFromString(metric)
+		resultLabels := pcs.Apply(labels.GetLabels(), 0)
+		SortLabels(resultLabels)
+		result := LabelsToString(resultLabels)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	lt := pcs.prcs[0].lookupTable
+	f(`up{instance="host1"}`, `up{instance="host1",team="infra"}`)
+	f(`up{instance="host2"}`, `up{instance="host2"}`)
+	if n := lt.hits.Get(); n != 1 {
+		t.Fatalf("unexpected hits; got %d; want 1", n)
+	}
+	if n := lt.misses.Get(); n != 1 {
+		t.Fatalf("unexpected misses; got %d; want 1", n)
+	}
+
+	// Update the table
+	mustWriteFile(t, path, "instance,team\nhost1,infra\nhost2,db\n")
+	reloadLookupTables()
+	f(`up{instance="host2"}`, `up{instance="host2",team="db"}`)
+
+	// Invalid table must be ignored
+	mustWriteFile(t, path, "instance,team\nhost1\n")
+	reloadLookupTables()
+	f(`up{instance="host2"}`, `up{instance="host2",team="db"}`)
+	if n := lt.reloadErrors.Get(); n != 1 {
+		t.Fatalf("unexpected reload errors; got %d; want 1", n)
+	}
+}
+
+func TestLookupTableRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.csv")
+	mustWriteFile(t, path, "instance,team\nhost1,infra\n")
+	data := []byte(`
+- action: lookup
+  source_labels: [instance]
+  table: ` + path)
+
+	lookupTablesLen := func() int {
+		lookupTablesLock.Lock()
+		defer lookupTablesLock.Unlock()
+		n := 0
+		for _, lt := range lookupTables {
+			if lt.path == path {
+				n++
+			}
+		}
+		return n
+	}
+	waitForLookupTablesLen := func(nExpected int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			runtime.GC()
+			n := lookupTablesLen()
+			if n == nExpected {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("unexpected number of lookup tables; got %d; want %d", n, nExpected)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The table is shared among configs with identical args.
+	pcs1, err := ParseRelabelConfigsData(data)
+	if err != nil {
+		t.Fatalf("cannot parse relabel configs: %s", err)
+	}
+	pcs2, err := ParseRelabelConfigsData(data)
+	if err != nil {
+		t.Fatalf("cannot parse relabel configs: %s", err)
+	}
+	if pcs1.prcs[0].lookupTable.lookupTable != pcs2.prcs[0].lookupTable.lookupTable {
+		t.Fatalf("expecting the lookup table to be shared")
+	}
+	waitForLookupTablesLen(1)
+
+	// The table must remain while it is in use by pcs2, while pcs1 is no longer used.
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := lookupTablesLen(); n != 1 {
+		t.Fatalf("unexpected number of lookup tables; got %d; want 1", n)
+	}
+	if pcs2.Len() != 1 {
+		t.Fatalf("unexpected number of relabel configs; got %d; want 1", pcs2.Len())
+	}
+	runtime.KeepAlive(pcs2)
+
+	// The table must be dropped after all the configs referring it are released.
+	waitForLookupTablesLen(0)
+}
+
+func mustWriteFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	// Make sure the modification time changes on file systems with coarse timestamps.
+	mt := time.Now().Add(time.Duration(len(data)) * time.Second)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatalf("cannot update modification time for %q: %s", path, err)
+	}
+}
@@ -33,6 +33,8 @@ type parsedRelabelConfig struct {
 	graphiteMatchTemplate *graphiteMatchTemplate
 	graphiteLabelRules    []graphiteLabelRule
 
+	lookupTable *lookupTableRef
+
 	regex         *regexutil.PromRegex
 	regexOriginal *regexp.Regexp
 
@@ -408,6 +410,16 @@ func (prc *parsedRelabelConfig) apply(labels []prompbmarshal.Label, labelsOffset
 			}
 		}
 		return dst
+	case "lookup":
+		// Set labels from the lookup table row matching `source_labels` joined with `separator`
+		bb := relabelBufPool.Get()
+		bb.B = concatLabelValues(bb.B[:0], src, prc.SourceLabels, prc.Separator)
+		row := prc.lookupTable.get(bb.B)
+		relabelBufPool.Put(bb)
+		for _, label := range row {
+			labels = setLabelValue(labels, labelsOffset, label.Name, label.Value)
+		}
+		return labels
 	case "uppercase":
 		bb := relabelBufPool.Get()
 		bb.B = concatLabelValues(bb.B[:0], src, prc.SourceLabels, prc.Separator)
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
 )
 
 var (
@@ -36,12 +38,17 @@ type client struct {
 	ctx                     context.Context
 	scrapeURL               string
 	scrapeTimeoutSecondsStr string
+	acceptHeader            string
 	setHeaders              func(req *http.Request) error
 	setProxyHeaders         func(req *http.Request) error
 	maxScrapeSize           int64
 }
 
 func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
+	acceptHeader, err := getAcceptHeader(sw.ScrapeProtocols)
+	if err != nil {
+		return nil, err
+	}
 	ac := sw.AuthConfig
 	setHeaders := func(req *http.Request) error {
 		return sw.AuthConfig.SetHeaders(req, true)
@@ -101,6 +108,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 		ctx:                     ctx,
 		scrapeURL:               sw.ScrapeURL,
 		scrapeTimeoutSecondsStr: fmt.Sprintf("%.3f", sw.ScrapeTimeout.Seconds()),
+		acceptHeader:            acceptHeader,
 		setHeaders:              setHeaders,
 		setProxyHeaders:         setProxyHeaders,
 		maxScrapeSize:           sw.MaxScrapeSize,
@@ -108,31 +116,35 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 	return c, nil
 }
 
-func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
+// ReadData reads the response from the scrape target into dst.
+//
+// It returns true if the response is in Prometheus protobuf format. Otherwise the response is in text exposition format.
+func (c *client) ReadData(dst *bytesutil.ByteBuffer) (bool, error) {
 	deadline := time.Now().Add(c.c.Timeout)
 	ctx, cancel := context.WithDeadline(c.ctx, deadline)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scrapeURL, nil)
 	if err != nil {
 		cancel()
-		return fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
+		return false, fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
 	}
 	// The following `Accept` header has been copied from Prometheus sources.
 	// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
 	// This is needed as a workaround for scraping stupid Java-based servers such as Spring Boot.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/608 for details.
-	// Do not bloat the `Accept` header with OpenMetrics shit, since it looks like dead standard now.
-	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	// Do not bloat the default `Accept` header with OpenMetrics shit, since it looks like dead standard now.
+	// Other formats can be requested via `scrape_protocols` option at `scrape_config`.
+	req.Header.Set("Accept", c.acceptHeader)
 	// Set X-Prometheus-Scrape-Timeout-Seconds like Prometheus does, since it is used by some exporters such as PushProx.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1179#issuecomment-813117162
 	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeoutSecondsStr)
 	req.Header.Set("User-Agent", "vm_promscrape")
 	if err := c.setHeaders(req); err != nil {
 		cancel()
-		return fmt.Errorf("failed to set request headers for %q: %w", c.scrapeURL, err)
+		return false, fmt.Errorf("failed to set request headers for %q: %w", c.scrapeURL, err)
 	}
 	if err := c.setProxyHeaders(req); err != nil {
 		cancel()
-		return fmt.Errorf("failed to set proxy request headers for %q: %w", c.scrapeURL, err)
+		return false, fmt.Errorf("failed to set proxy request headers for %q: %w", c.scrapeURL, err)
 	}
 	scrapeRequests.Inc()
 	resp, err := c.c.Do(req)
@@ -141,19 +153,20 @@ func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
 		if ue, ok := err.(*url.Error); ok && ue.Timeout() {
 			scrapesTimedout.Inc()
 		}
-		return fmt.Errorf("cannot perform request to %q: %w", c.scrapeURL, err)
+		return false, fmt.Errorf("cannot perform request to %q: %w", c.scrapeURL, err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		metrics.GetOrCreateCounter(fmt.Sprintf(`vm_promscrape_scrapes_total{status_code="%d"}`, resp.StatusCode)).Inc()
 		respBody, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		cancel()
-		return fmt.Errorf("unexpected status code returned when scraping %q: %d; expecting %d; response body: %q",
+		return false, fmt.Errorf("unexpected status code returned when scraping %q: %d; expecting %d; response body: %q",
 			c.scrapeURL, resp.StatusCode, http.StatusOK, respBody)
 	}
 	scrapesOK.Inc()
 
 	// Read the data from resp.Body
+	isProtobuf := prometheus.IsProtobufContentType(resp.Header.Get("Content-Type"))
 	r := &io.LimitedReader{
 		R: resp.Body,
 		N: c.maxScrapeSize,
@@ -165,15 +178,57 @@ func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
 		if ue, ok := err.(*url.Error); ok && ue.Timeout() {
 			scrapesTimedout.Inc()
 		}
-		return fmt.Errorf("cannot read data from %s: %w", c.scrapeURL, err)
+		return false, fmt.Errorf("cannot read data from %s: %w", c.scrapeURL, err)
 	}
 	if int64(len(dst.B)) >= c.maxScrapeSize {
 		maxScrapeSizeExceeded.Inc()
-		return fmt.Errorf("the response from %q exceeds -promscrape.maxScrapeSize or max_scrape_size in the scrape config (%d bytes). "+
+		return false, fmt.Errorf("the response from %q exceeds -promscrape.maxScrapeSize or max_scrape_size in the scrape config (%d bytes). "+
 			"Possible solutions are: reduce the response size for the target, increase -promscrape.maxScrapeSize command-line flag, "+
 			"increase max_scrape_size value in scrape config for the given target", c.scrapeURL, c.maxScrapeSize)
 	}
-	return nil
+	return isProtobuf, nil
+}
+
+// defaultAcceptHeader is used when `scrape_protocols` isn't set at `scrape_config`.
+//
+// It has been copied from Prometheus sources.
+// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
+const defaultAcceptHeader = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// scrapeProtocolHeaders maps `scrape_protocols` values to the corresponding media types.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+var scrapeProtocolHeaders = map[string]string{
+	"PrometheusProto":      "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+	"PrometheusText0.0.4":  "text/plain;version=0.0.4",
+	"PrometheusText1.0.0":  "text/plain;version=1.0.0",
+	"OpenMetricsText0.0.1": "application/openmetrics-text;version=0.0.1",
+	"OpenMetricsText1.0.0": "application/openmetrics-text;version=1.0.0",
+}
+
+// getAcceptHeader returns `Accept` header value for the given scrape protocols in the order of preference.
+//
+// The returned header is built in the same way as Prometheus does.
+func getAcceptHeader(protocols []string) (string, error) {
+	if len(protocols) == 0 {
+		return defaultAcceptHeader, nil
+	}
+	a := make([]string, 0, len(protocols)+1)
+	weight := len(protocols) + 1
+	for i, p := range protocols {
+		if slices.Contains(protocols[:i], p) {
+			return "", fmt.Errorf("duplicate scrape protocol %q", p)
+		}
+		h, ok := scrapeProtocolHeaders[p]
+		if !ok {
+			return "", fmt.Errorf("unsupported scrape protocol %q; supported values: PrometheusProto, PrometheusText0.0.4, PrometheusText1.0.0, "+
+				"OpenMetricsText0.0.1, OpenMetricsText1.0.0", p)
+		}
+		a = append(a, fmt.Sprintf("%s;q=0.%d", h, weight))
+		weight--
+	}
+	a = append(a, fmt.Sprintf("*/*;q=0.%d", weight))
+	return strings.Join(a, ","), nil
 }
 
 var (
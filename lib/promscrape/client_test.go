@@ -8,9 +8,12 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/easyproto"
+
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
@@ -157,7 +160,7 @@ func TestClientProxyReadOk(t *testing.T) {
 		}
 
 		var bb bytesutil.ByteBuffer
-		if err = c.ReadData(&bb); err != nil {
+		if _, err = c.ReadData(&bb); err != nil {
 			t.Fatalf("unexpected error at ReadData: %s", err)
 		}
 		got, err := io.ReadAll(bb.NewReader())
@@ -200,3 +203,76 @@ func TestClientProxyReadOk(t *testing.T) {
 	// backend tls and proxy auth
 	f(true, false, nil, &promauth.BasicAuthConfig{Username: "proxy-test", Password: promauth.NewSecret("1234")})
 }
+
+func TestGetAcceptHeader(t *testing.T) {
+	f := func(protocols []string, resultExpected string) {
+		t.Helper()
+		result, err := getAcceptHeader(protocols)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(nil, "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	f([]string{"PrometheusText0.0.4"}, "text/plain;version=0.0.4;q=0.2,*/*;q=0.1")
+	f([]string{"PrometheusProto", "OpenMetricsText1.0.0", "PrometheusText0.0.4"}, "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.4,"+
+		"application/openmetrics-text;version=1.0.0;q=0.3,text/plain;version=0.0.4;q=0.2,*/*;q=0.1")
+
+	// invalid protocols
+	for _, protocols := range [][]string{{"foo"}, {"PrometheusProto", "PrometheusProto"}} {
+		if _, err := getAcceptHeader(protocols); err == nil {
+			t.Fatalf("expecting non-nil error for %q", protocols)
+		}
+	}
+}
+
+func TestClientReadDataProtobuf(t *testing.T) {
+	// MetricFamily{name: "foo", type: GAUGE, metric: [{label: [{name: "bar", value: "baz"}], gauge: {value: 1.5}}]}
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt32(3, 1)
+	metric := mm.AppendMessage(4)
+	label := metric.AppendMessage(1)
+	label.AppendString(1, "bar")
+	label.AppendString(2, "baz")
+	metric.AppendMessage(2).AppendDouble(1, 1.5)
+	data := m.MarshalWithLen(nil)
+
+	var acceptHeader string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptHeader = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited")
+		w.Write(data)
+	}))
+	defer s.Close()
+
+	c, err := newClient(context.Background(), &ScrapeWork{
+		ScrapeURL:       s.URL,
+		ScrapeTimeout:   5 * time.Second,
+		AuthConfig:      newTestAuthConfig(t, false, nil),
+		ProxyAuthConfig: newTestAuthConfig(t, false, nil),
+		MaxScrapeSize:   16000,
+		ScrapeProtocols: []string{"PrometheusProto", "PrometheusText0.0.4"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	var bb bytesutil.ByteBuffer
+	isProtobuf, err := c.ReadData(&bb)
+	if err != nil {
+		t.Fatalf("unexpected error at ReadData: %s", err)
+	}
+	if !strings.HasPrefix(acceptHeader, "application/vnd.google.protobuf;") {
+		t.Fatalf("unexpected Accept header: %q", acceptHeader)
+	}
+	if !isProtobuf {
+		t.Fatalf("expecting the response in protobuf format")
+	}
+	// The response must be returned as is, since it is parsed by scrapeWork.
+	if string(bb.B) != string(data) {
+		t.Fatalf("unexpected response\ngot\n%X\nwant\n%X", bb.B, data)
+	}
+}
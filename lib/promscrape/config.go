@@ -33,6 +33,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/docker"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/dockerswarm"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ec2"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/etcd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/eureka"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/hetzner"
@@ -46,6 +47,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/puppetdb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/vultr"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/yandexcloud"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/zookeeper"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/probe"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
 )
@@ -294,6 +297,7 @@ type ScrapeConfig struct {
 	RelabelConfigs       []promrelabel.RelabelConfig `yaml:"relabel_configs,omitempty"`
 	MetricRelabelConfigs []promrelabel.RelabelConfig `yaml:"metric_relabel_configs,omitempty"`
 	SampleLimit          int                         `yaml:"sample_limit,omitempty"`
+	ScrapeProtocols      []string                    `yaml:"scrape_protocols,omitempty"`
 
 	// This silly option is needed for compatibility with Prometheus.
 	// vmagent was supporting disable_compression option since the beginning, while Prometheus developers
@@ -301,29 +305,32 @@ type ScrapeConfig struct {
 	// That's why it needs to be supported too :(
 	EnableCompression *bool `yaml:"enable_compression,omitempty"`
 
-	AzureSDConfigs        []azure.SDConfig        `yaml:"azure_sd_configs,omitempty"`
-	ConsulSDConfigs       []consul.SDConfig       `yaml:"consul_sd_configs,omitempty"`
-	ConsulAgentSDConfigs  []consulagent.SDConfig  `yaml:"consulagent_sd_configs,omitempty"`
-	DigitaloceanSDConfigs []digitalocean.SDConfig `yaml:"digitalocean_sd_configs,omitempty"`
-	DNSSDConfigs          []dns.SDConfig          `yaml:"dns_sd_configs,omitempty"`
-	DockerSDConfigs       []docker.SDConfig       `yaml:"docker_sd_configs,omitempty"`
-	DockerSwarmSDConfigs  []dockerswarm.SDConfig  `yaml:"dockerswarm_sd_configs,omitempty"`
-	EC2SDConfigs          []ec2.SDConfig          `yaml:"ec2_sd_configs,omitempty"`
-	EurekaSDConfigs       []eureka.SDConfig       `yaml:"eureka_sd_configs,omitempty"`
-	FileSDConfigs         []FileSDConfig          `yaml:"file_sd_configs,omitempty"`
-	GCESDConfigs          []gce.SDConfig          `yaml:"gce_sd_configs,omitempty"`
-	HetznerSDConfigs      []hetzner.SDConfig      `yaml:"hetzner_sd_configs,omitempty"`
-	HTTPSDConfigs         []http.SDConfig         `yaml:"http_sd_configs,omitempty"`
-	KubernetesSDConfigs   []kubernetes.SDConfig   `yaml:"kubernetes_sd_configs,omitempty"`
-	KumaSDConfigs         []kuma.SDConfig         `yaml:"kuma_sd_configs,omitempty"`
-	MarathonSDConfigs     []marathon.SDConfig     `yaml:"marathon_sd_configs,omitempty"`
-	NomadSDConfigs        []nomad.SDConfig        `yaml:"nomad_sd_configs,omitempty"`
-	OpenStackSDConfigs    []openstack.SDConfig    `yaml:"openstack_sd_configs,omitempty"`
-	OVHCloudSDConfigs     []ovhcloud.SDConfig     `yaml:"ovhcloud_sd_configs,omitempty"`
-	PuppetDBSDConfigs     []puppetdb.SDConfig     `yaml:"puppetdb_sd_configs,omitempty"`
-	StaticConfigs         []StaticConfig          `yaml:"static_configs,omitempty"`
-	VultrSDConfigs        []vultr.SDConfig        `yaml:"vultr_configs,omitempty"`
-	YandexCloudSDConfigs  []yandexcloud.SDConfig  `yaml:"yandexcloud_sd_configs,omitempty"`
+	AzureSDConfigs        []azure.SDConfig              `yaml:"azure_sd_configs,omitempty"`
+	ConsulSDConfigs       []consul.SDConfig             `yaml:"consul_sd_configs,omitempty"`
+	ConsulAgentSDConfigs  []consulagent.SDConfig        `yaml:"consulagent_sd_configs,omitempty"`
+	DigitaloceanSDConfigs []digitalocean.SDConfig       `yaml:"digitalocean_sd_configs,omitempty"`
+	DNSSDConfigs          []dns.SDConfig                `yaml:"dns_sd_configs,omitempty"`
+	DockerSDConfigs       []docker.SDConfig             `yaml:"docker_sd_configs,omitempty"`
+	DockerSwarmSDConfigs  []dockerswarm.SDConfig        `yaml:"dockerswarm_sd_configs,omitempty"`
+	EC2SDConfigs          []ec2.SDConfig                `yaml:"ec2_sd_configs,omitempty"`
+	EtcdSDConfigs         []etcd.SDConfig               `yaml:"etcd_sd_configs,omitempty"`
+	EurekaSDConfigs       []eureka.SDConfig             `yaml:"eureka_sd_configs,omitempty"`
+	FileSDConfigs         []FileSDConfig                `yaml:"file_sd_configs,omitempty"`
+	GCESDConfigs          []gce.SDConfig                `yaml:"gce_sd_configs,omitempty"`
+	HetznerSDConfigs      []hetzner.SDConfig            `yaml:"hetzner_sd_configs,omitempty"`
+	HTTPSDConfigs         []http.SDConfig               `yaml:"http_sd_configs,omitempty"`
+	KubernetesSDConfigs   []kubernetes.SDConfig         `yaml:"kubernetes_sd_configs,omitempty"`
+	KumaSDConfigs         []kuma.SDConfig               `yaml:"kuma_sd_configs,omitempty"`
+	MarathonSDConfigs     []marathon.SDConfig           `yaml:"marathon_sd_configs,omitempty"`
+	NerveSDConfigs        []zookeeper.NerveSDConfig     `yaml:"nerve_sd_configs,omitempty"`
+	NomadSDConfigs        []nomad.SDConfig              `yaml:"nomad_sd_configs,omitempty"`
+	OpenStackSDConfigs    []openstack.SDConfig          `yaml:"openstack_sd_configs,omitempty"`
+	OVHCloudSDConfigs     []ovhcloud.SDConfig           `yaml:"ovhcloud_sd_configs,omitempty"`
+	PuppetDBSDConfigs     []puppetdb.SDConfig           `yaml:"puppetdb_sd_configs,omitempty"`
+	ServersetSDConfigs    []zookeeper.ServersetSDConfig `yaml:"serverset_sd_configs,omitempty"`
+	StaticConfigs         []StaticConfig                `yaml:"static_configs,omitempty"`
+	VultrSDConfigs        []vultr.SDConfig              `yaml:"vultr_configs,omitempty"`
+	YandexCloudSDConfigs  []yandexcloud.SDConfig        `yaml:"yandexcloud_sd_configs,omitempty"`
 
 	// These options are supported only by lib/promscrape.
 	DisableCompression  bool                       `yaml:"disable_compression,omitempty"`
@@ -333,16 +340,28 @@ type ScrapeConfig struct {
 	ScrapeOffset        *promutils.Duration        `yaml:"scrape_offset,omitempty"`
 	SeriesLimit         *int                       `yaml:"series_limit,omitempty"`
 	NoStaleMarkers      *bool                      `yaml:"no_stale_markers,omitempty"`
+	Probe               *probe.Config              `yaml:"probe,omitempty"`
 	ProxyClientConfig   promauth.ProxyClientConfig `yaml:",inline"`
 
 	// This is set in loadConfig
 	swc *scrapeWorkConfig
+
+	// This is set in mustStart
+	mswcs *monitorScrapeWorkConfigs
 }
 
 func (sc *ScrapeConfig) mustStart(baseDir string) {
-	swosFunc := func(metaLabels *promutils.Labels) any {
+	mswcs := newMonitorScrapeWorkConfigs(sc.swc, baseDir)
+	sc.mswcs = mswcs
+	swosFunc := func(metaLabels *promutils.Labels, me *kubernetes.MonitorEndpoint) any {
 		target := metaLabels.Get("__address__")
-		sw, err := sc.swc.getScrapeWork(target, nil, metaLabels)
+		var sw *ScrapeWork
+		var err error
+		if me != nil {
+			sw, err = mswcs.getScrapeWork(me, target, metaLabels)
+		} else {
+			sw, err = sc.swc.getScrapeWork(target, nil, metaLabels)
+		}
 		if err != nil {
 			logger.Errorf("cannot create kubernetes_sd_config target %q for job_name=%s: %s", target, sc.swc.jobName, err)
 			return nil
@@ -379,6 +398,9 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.EC2SDConfigs {
 		sc.EC2SDConfigs[i].MustStop()
 	}
+	for i := range sc.EtcdSDConfigs {
+		sc.EtcdSDConfigs[i].MustStop()
+	}
 	for i := range sc.EurekaSDConfigs {
 		sc.EurekaSDConfigs[i].MustStop()
 	}
@@ -397,6 +419,9 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.KumaSDConfigs {
 		sc.KumaSDConfigs[i].MustStop()
 	}
+	for i := range sc.NerveSDConfigs {
+		sc.NerveSDConfigs[i].MustStop()
+	}
 	for i := range sc.NomadSDConfigs {
 		sc.NomadSDConfigs[i].MustStop()
 	}
@@ -409,6 +434,9 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.PuppetDBSDConfigs {
 		sc.PuppetDBSDConfigs[i].MustStop()
 	}
+	for i := range sc.ServersetSDConfigs {
+		sc.ServersetSDConfigs[i].MustStop()
+	}
 	for i := range sc.VultrSDConfigs {
 		sc.VultrSDConfigs[i].MustStop()
 	}
@@ -662,6 +690,16 @@ func (cfg *Config) getEC2SDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "ec2_sd_config", prev)
 }
 
+// getEtcdSDScrapeWork returns `etcd_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getEtcdSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.EtcdSDConfigs {
+			visitor(&sc.EtcdSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "etcd_sd_config", prev)
+}
+
 // getEurekaSDScrapeWork returns `eureka_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getEurekaSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -737,7 +775,10 @@ func (cfg *Config) getKubernetesSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 		}
 		if !ok {
 			dst = sc.appendPrevTargets(dst[:dstLen], swsPrevByJob, discoveryType)
+			continue
 		}
+		// Drop cached configs for ServiceMonitor and PodMonitor endpoints without targets.
+		sc.mswcs.prune(dst[dstLen:])
 	}
 	return dst
 }
@@ -762,6 +803,16 @@ func (cfg *Config) getMarathonSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "marathon_sd_config", prev)
 }
 
+// getNerveSDScrapeWork returns `nerve_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getNerveSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.NerveSDConfigs {
+			visitor(&sc.NerveSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "nerve_sd_config", prev)
+}
+
 // getNomadSDScrapeWork returns `nomad_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getNomadSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -802,6 +853,16 @@ func (cfg *Config) getPuppetDBSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "puppetdb_sd_config", prev)
 }
 
+// getServersetSDScrapeWork returns `serverset_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getServersetSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.ServersetSDConfigs {
+			visitor(&sc.ServersetSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "serverset_sd_config", prev)
+}
+
 // getVultrSDScrapeWork returns `vultr_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getVultrSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -913,9 +974,21 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.HTTPClientConfig.FollowRedirects != nil {
 		denyRedirects = !*sc.HTTPClientConfig.FollowRedirects
 	}
+	var probeConfig *probe.ParsedConfig
+	if sc.Probe != nil {
+		pc, err := sc.Probe.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `probe` section for `job_name` %q: %w", jobName, err)
+		}
+		probeConfig = pc
+	}
 	metricsPath := sc.MetricsPath
 	if metricsPath == "" {
 		metricsPath = "/metrics"
+		if probeConfig != nil {
+			// Probe the root path by default.
+			metricsPath = "/"
+		}
 	}
 	scheme := strings.ToLower(sc.Scheme)
 	if scheme == "" {
@@ -966,6 +1039,9 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.EnableCompression != nil {
 		disableCompression = !*sc.EnableCompression
 	}
+	if _, err := getAcceptHeader(sc.ScrapeProtocols); err != nil {
+		return nil, fmt.Errorf("cannot parse `scrape_protocols` for `job_name` %q: %w", jobName, err)
+	}
 	swc := &scrapeWorkConfig{
 		scrapeInterval:       scrapeInterval,
 		scrapeIntervalString: scrapeInterval.String(),
@@ -985,7 +1061,9 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		externalLabels:       externalLabels,
 		relabelConfigs:       relabelConfigs,
 		metricRelabelConfigs: metricRelabelConfigs,
+		metricRelabelRules:   mrcs,
 		sampleLimit:          sc.SampleLimit,
+		scrapeProtocols:      sc.ScrapeProtocols,
 		disableCompression:   disableCompression,
 		disableKeepAlive:     sc.DisableKeepAlive,
 		streamParse:          sc.StreamParse,
@@ -993,6 +1071,7 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		scrapeOffset:         sc.ScrapeOffset.Duration(),
 		seriesLimit:          seriesLimit,
 		noStaleMarkers:       noStaleTracking,
+		probeConfig:          probeConfig,
 	}
 	return swc, nil
 }
@@ -1016,7 +1095,9 @@ type scrapeWorkConfig struct {
 	externalLabels       *promutils.Labels
 	relabelConfigs       *promrelabel.ParsedConfigs
 	metricRelabelConfigs *promrelabel.ParsedConfigs
+	metricRelabelRules   []promrelabel.RelabelConfig
 	sampleLimit          int
+	scrapeProtocols      []string
 	disableCompression   bool
 	disableKeepAlive     bool
 	streamParse          bool
@@ -1024,6 +1105,7 @@ type scrapeWorkConfig struct {
 	scrapeOffset         time.Duration
 	seriesLimit          int
 	noStaleMarkers       bool
+	probeConfig          *probe.ParsedConfig
 }
 
 func appendScrapeWorkForTargetLabels(dst []*ScrapeWork, swc *scrapeWorkConfig, targetLabels []*promutils.Labels, discoveryType string) []*ScrapeWork {
@@ -1300,6 +1382,7 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		RelabelConfigs:       swc.relabelConfigs,
 		MetricRelabelConfigs: swc.metricRelabelConfigs,
 		SampleLimit:          sampleLimit,
+		ScrapeProtocols:      swc.scrapeProtocols,
 		DisableCompression:   swc.disableCompression,
 		DisableKeepAlive:     swc.disableKeepAlive,
 		StreamParse:          streamParse,
@@ -1307,6 +1390,7 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		ScrapeOffset:         swc.scrapeOffset,
 		SeriesLimit:          seriesLimit,
 		NoStaleMarkers:       swc.noStaleMarkers,
+		ProbeConfig:          swc.probeConfig,
 		AuthToken:            at,
 
 		jobNameOriginal: swc.jobName,
@@ -10,6 +10,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/probe"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
@@ -663,6 +664,34 @@ scrape_configs:
   - targets: ["foo"]
 `, []*ScrapeWork{})
 
+	// Scrape config with unsupported scrape_protocols must be skipped
+	f(`
+scrape_configs:
+- job_name: x
+  scrape_protocols: [foobar]
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// Scrape config with duplicate scrape_protocols must be skipped
+	f(`
+scrape_configs:
+- job_name: x
+  scrape_protocols: [PrometheusProto, PrometheusProto]
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// Scrape config with invalid probe section must be skipped
+	f(`
+scrape_configs:
+- job_name: x
+  probe:
+    prober: foobar
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
 	// Scrape config with missing job_name must be skipped
 	f(`
 scrape_configs:
@@ -1188,6 +1217,47 @@ scrape_configs:
 	})
 	f(`
 scrape_configs:
+- job_name: proto
+  scrape_protocols: [PrometheusProto, OpenMetricsText1.0.0]
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:      "http://foo.bar:1234/metrics",
+			ScrapeInterval: defaultScrapeInterval,
+			ScrapeTimeout:  defaultScrapeTimeout,
+			MaxScrapeSize:  maxScrapeSize.N,
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "proto",
+			}),
+			ScrapeProtocols: []string{"PrometheusProto", "OpenMetricsText1.0.0"},
+			jobNameOriginal: "proto",
+		},
+	})
+	f(`
+scrape_configs:
+- job_name: blackbox
+  probe:
+    prober: tcp
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:      "http://foo.bar:1234/",
+			ScrapeInterval: defaultScrapeInterval,
+			ScrapeTimeout:  defaultScrapeTimeout,
+			MaxScrapeSize:  maxScrapeSize.N,
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "blackbox",
+			}),
+			ProbeConfig:     mustParseProbeConfig(&probe.Config{Prober: "tcp"}),
+			jobNameOriginal: "blackbox",
+		},
+	})
+	f(`
+scrape_configs:
 - job_name: path wo slash
   enable_compression: false
   static_configs: 
@@ -1342,6 +1412,14 @@ func TestScrapeConfigClone(t *testing.T) {
 	})
 }
 
+func mustParseProbeConfig(cfg *probe.Config) *probe.ParsedConfig {
+	pc, err := cfg.Parse()
+	if err != nil {
+		panic(fmt.Errorf("BUG: cannot parse probe config: %w", err))
+	}
+	return pc
+}
+
 func checkEqualScrapeWorks(t *testing.T, got, want []*ScrapeWork) {
 	t.Helper()
 
@@ -0,0 +1,315 @@
+package etcd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+)
+
+// apiConfig contains config for etcd discovery.
+type apiConfig struct {
+	pw *prefixWatcher
+}
+
+func (ac *apiConfig) mustStop() {
+	ac.pw.mustStop()
+}
+
+var configMap = discoveryutils.NewConfigMap()
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if len(sdc.Endpoints) == 0 {
+		return nil, fmt.Errorf("`endpoints` cannot be empty")
+	}
+	if sdc.Prefix == "" {
+		return nil, fmt.Errorf("`prefix` cannot be empty")
+	}
+	for _, endpoint := range sdc.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse endpoint %q: %w", endpoint, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported scheme in endpoint %q; supported schemes: http, https", endpoint)
+		}
+	}
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	var proxyURLFunc func(*http.Request) (*url.URL, error)
+	if pu := sdc.ProxyURL.GetURL(); pu != nil {
+		proxyURLFunc = http.ProxyURL(pu)
+	}
+	hc := &http.Client{
+		Transport: ac.NewRoundTripper(&http.Transport{
+			Proxy:               proxyURLFunc,
+			DialContext:         netutil.Dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 10,
+		}),
+	}
+	c := &client{
+		endpoints: sdc.Endpoints,
+		hc:        hc,
+		setHeaders: func(req *http.Request) error {
+			if err := ac.SetHeaders(req, true); err != nil {
+				return err
+			}
+			return sdc.ProxyURL.SetHeaders(proxyAC, req)
+		},
+	}
+	pw, err := newPrefixWatcher(c, sdc.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &apiConfig{
+		pw: pw,
+	}
+	return cfg, nil
+}
+
+// client is a client for etcd v3 HTTP/JSON gateway.
+//
+// See https://etcd.io/docs/v3.5/dev-guide/api_grpc_gateway/
+type client struct {
+	endpoints  []string
+	hc         *http.Client
+	setHeaders func(req *http.Request) error
+
+	// endpointIdx is the index of the endpoint at endpoints to send requests to.
+	// It is switched to the next endpoint on errors.
+	mu          sync.Mutex
+	endpointIdx int
+}
+
+// requestTimeout is the timeout for non-watch requests to etcd.
+const requestTimeout = 30 * time.Second
+
+// keyValue represents etcd key-value pair.
+//
+// See https://etcd.io/docs/v3.5/learning/api/#key-value-pair
+type keyValue struct {
+	Key            []byte `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"create_revision,string"`
+	ModRevision    int64  `json:"mod_revision,string"`
+	Version        int64  `json:"version,string"`
+}
+
+type responseHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []keyValue     `json:"kvs"`
+}
+
+type watchCreateRequest struct {
+	CreateRequest watchCreateRequestParams `json:"create_request"`
+}
+
+type watchCreateRequestParams struct {
+	Key           []byte `json:"key"`
+	RangeEnd      []byte `json:"range_end"`
+	StartRevision int64  `json:"start_revision,string"`
+}
+
+type watchResponseEnvelope struct {
+	Result *watchResponse `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type watchResponse struct {
+	Header          responseHeader `json:"header"`
+	Created         bool           `json:"created"`
+	Canceled        bool           `json:"canceled"`
+	CancelReason    string         `json:"cancel_reason"`
+	CompactRevision int64          `json:"compact_revision,string"`
+	Events          []watchEvent   `json:"events"`
+}
+
+type watchEvent struct {
+	// Type is empty for PUT events, since it is the default enum value.
+	Type string   `json:"type"`
+	Kv   keyValue `json:"kv"`
+}
+
+// errCompacted is returned from watchPrefix when the requested revision has been compacted.
+type errCompacted struct {
+	revision int64
+}
+
+func (e *errCompacted) Error() string {
+	return fmt.Sprintf("the requested revision has been compacted; the oldest available revision is %d", e.revision)
+}
+
+// getPrefixRangeEnd returns the range end for the given prefix.
+//
+// See https://etcd.io/docs/v3.5/learning/api/#key-ranges
+func getPrefixRangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix consists of 0xff bytes. Return the key, which means "all the keys greater than the prefix".
+	return []byte{0}
+}
+
+// rangePrefix returns all the key-value pairs with the given prefix together with the current revision.
+func (c *client) rangePrefix(ctx context.Context, prefix string) ([]keyValue, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	rr := &rangeRequest{
+		Key:      []byte(prefix),
+		RangeEnd: getPrefixRangeEnd(prefix),
+	}
+	resp, err := c.do(ctx, "/v3/kv/range", rr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read range response: %w", err)
+	}
+	var r rangeResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, 0, fmt.Errorf("cannot parse range response %q: %w", data, err)
+	}
+	return r.Kvs, r.Header.Revision, nil
+}
+
+// watchPrefix watches for changes of keys with the given prefix starting from the given revision.
+//
+// f is called for every batch of events together with the revision of the batch.
+// watchPrefix returns when ctx is canceled or on error.
+func (c *client) watchPrefix(ctx context.Context, prefix string, startRevision int64, f func(events []watchEvent, revision int64)) error {
+	wr := &watchCreateRequest{
+		CreateRequest: watchCreateRequestParams{
+			Key:           []byte(prefix),
+			RangeEnd:      getPrefixRangeEnd(prefix),
+			StartRevision: startRevision,
+		},
+	}
+	resp, err := c.do(ctx, "/v3/watch", wr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The gateway streams newline-delimited JSON messages.
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var env watchResponseEnvelope
+		if err := dec.Decode(&env); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("cannot read watch response: %w", err)
+		}
+		if env.Error != nil {
+			return fmt.Errorf("watch error: %s", env.Error.Message)
+		}
+		r := env.Result
+		if r == nil {
+			continue
+		}
+		if r.CompactRevision > 0 {
+			return &errCompacted{
+				revision: r.CompactRevision,
+			}
+		}
+		if r.Canceled {
+			return fmt.Errorf("the watch has been canceled by etcd: %s", r.CancelReason)
+		}
+		if len(r.Events) > 0 {
+			f(r.Events, r.Header.Revision)
+		}
+	}
+}
+
+// do sends the JSON-encoded body to the given path at the current endpoint.
+//
+// It switches to the next endpoint on errors, so the next request is sent to another etcd member.
+func (c *client) do(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("BUG: cannot marshal request: %w", err)
+	}
+	c.mu.Lock()
+	idx := c.endpointIdx
+	c.mu.Unlock()
+	endpoint := c.endpoints[idx]
+	requestURL := strings.TrimSuffix(endpoint, "/") + path
+
+	resp, err := c.doRequest(ctx, requestURL, data)
+	if err != nil {
+		c.mu.Lock()
+		if c.endpointIdx == idx {
+			c.endpointIdx = (idx + 1) % len(c.endpoints)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *client) doRequest(ctx context.Context, requestURL string, data []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request for %q: %w", requestURL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.setHeaders(req); err != nil {
+		return nil, fmt.Errorf("cannot set request headers for %q: %w", requestURL, err)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot perform request to %q: %w", requestURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code returned from %q: %d; expecting %d; response body: %q",
+			requestURL, resp.StatusCode, http.StatusOK, respBody)
+	}
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		if _, err := newAPIConfig(sdc, "."); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing endpoints
+	f(&SDConfig{
+		Prefix: "/services/",
+	})
+
+	// missing prefix
+	f(&SDConfig{
+		Endpoints: []string{"http://127.0.0.1:2379"},
+	})
+
+	// unsupported scheme
+	f(&SDConfig{
+		Endpoints: []string{"127.0.0.1:2379"},
+		Prefix:    "/services/",
+	})
+
+	// invalid auth config
+	f(&SDConfig{
+		Endpoints: []string{"http://127.0.0.1:2379"},
+		Prefix:    "/services/",
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BearerToken: promauth.NewSecret("foo"),
+			BasicAuth: &promauth.BasicAuthConfig{
+				Username: "foo",
+			},
+		},
+	})
+
+	// unavailable endpoint
+	ms := newMockServer()
+	endpoint := ms.s.URL
+	ms.close()
+	f(&SDConfig{
+		Endpoints: []string{endpoint},
+		Prefix:    "/services/",
+	})
+}
+
+func TestGetPrefixRangeEnd(t *testing.T) {
+	f := func(prefix, resultExpected string) {
+		t.Helper()
+		result := getPrefixRangeEnd(prefix)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected range end for %q; got %q; want %q", prefix, result, resultExpected)
+		}
+	}
+	f("/services/", "/services0")
+	f("a", "b")
+	f("a\xff", "b")
+	f("\xff\xff", "\x00")
+}
+
+func TestClientWatchPrefixCompacted(t *testing.T) {
+	ms := newMockServer()
+	defer ms.close()
+
+	ms.put("/services/foo", "10.0.0.1:80")
+	ms.compactRevision = 3
+
+	c := &client{
+		endpoints: []string{ms.s.URL},
+		hc:        ms.s.Client(),
+		setHeaders: func(_ *http.Request) error {
+			return nil
+		},
+	}
+	err := c.watchPrefix(context.Background(), "/services/", 1, func(_ []watchEvent, _ int64) {
+		t.Fatalf("unexpected events")
+	})
+	var ec *errCompacted
+	if !errors.As(err, &ec) {
+		t.Fatalf("expecting errCompacted; got %v", err)
+	}
+	if ec.revision != 3 {
+		t.Fatalf("unexpected compact revision; got %d; want 3", ec.revision)
+	}
+}
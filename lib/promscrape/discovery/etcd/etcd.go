@@ -0,0 +1,55 @@
+package etcd
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for refreshing targets discovered via etcd.
+//
+// The discovered keys are updated in background via etcd watches, so this interval only controls
+// how frequently the scrape targets are re-generated from the cached keys.
+var SDCheckInterval = flag.Duration("promscrape.etcdSDCheckInterval", 30*time.Second, "Interval for checking for changes in etcd. "+
+	"This works only if etcd_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#etcd_sd_configs for details")
+
+// SDConfig represents service discovery config for etcd.
+//
+// See https://docs.victoriametrics.com/sd_configs.html#etcd_sd_configs
+type SDConfig struct {
+	// Endpoints is a list of etcd v3 gateway URLs such as http://etcd:2379
+	Endpoints []string `yaml:"endpoints"`
+
+	// Prefix is the key prefix to watch.
+	Prefix string `yaml:"prefix"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.etcdSDCheckInterval` command-line option.
+}
+
+// GetLabels returns etcd labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getKeyValuesLabels(cfg.pw.getKeyValues(), sdc.Prefix), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		// v can be nil if GetLabels wasn't called yet.
+		cfg := v.(*apiConfig)
+		cfg.mustStop()
+	}
+}
@@ -0,0 +1,76 @@
+package etcd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+func TestSDConfigGetLabels(t *testing.T) {
+	ms := newMockServer()
+	ms.username = "user"
+	ms.password = "pass"
+	defer ms.close()
+
+	ms.put("/services/foo/1", `{"address":"10.0.0.1:8080","labels":{"env":"prod"}}`)
+	ms.put("/other/bar", "10.0.0.100:8080")
+
+	sdc := &SDConfig{
+		Endpoints: []string{"http://127.0.0.1:1", ms.s.URL},
+		Prefix:    "/services/",
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BasicAuth: &promauth.BasicAuthConfig{
+				Username: "user",
+				Password: promauth.NewSecret("pass"),
+			},
+		},
+	}
+	defer sdc.MustStop()
+
+	waitForAddresses(t, sdc, "10.0.0.1:8080")
+
+	// Add a target
+	ms.put("/services/foo/2", "10.0.0.2:8080")
+	waitForAddresses(t, sdc, "10.0.0.1:8080", "10.0.0.2:8080")
+
+	// Update a target
+	ms.put("/services/foo/1", `{"address":"10.0.0.3:8080"}`)
+	waitForAddresses(t, sdc, "10.0.0.3:8080", "10.0.0.2:8080")
+
+	// Delete a target
+	ms.delete("/services/foo/2")
+	waitForAddresses(t, sdc, "10.0.0.3:8080")
+
+	// Changes outside the prefix must be ignored
+	ms.put("/other/baz", "10.0.0.101:8080")
+	ms.put("/services/foo/3", "10.0.0.4:8080")
+	waitForAddresses(t, sdc, "10.0.0.3:8080", "10.0.0.4:8080")
+
+	// Break the watch and make sure the watcher recovers after compaction
+	ms.compact()
+	ms.put("/services/foo/4", "10.0.0.5:8080")
+	waitForAddresses(t, sdc, "10.0.0.3:8080", "10.0.0.4:8080", "10.0.0.5:8080")
+}
+
+func waitForAddresses(t *testing.T, sdc *SDConfig, addrsExpected ...string) {
+	t.Helper()
+	var addrs []string
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		labelss, err := sdc.GetLabels(".")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		addrs = addrs[:0]
+		for _, labels := range labelss {
+			addrs = append(addrs, labels.Get("__address__"))
+		}
+		if slices.Equal(addrs, addrsExpected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("unexpected addresses; got %q; want %q", addrs, addrsExpected)
+}
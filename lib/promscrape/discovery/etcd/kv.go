@@ -0,0 +1,72 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// target is the JSON representation of the scrape target stored in etcd value.
+//
+// Values, which aren't JSON objects, are treated as plain target addresses.
+type target struct {
+	Address string            `json:"address"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func parseTarget(value []byte) (*target, bool) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return nil, false
+	}
+	if value[0] != '{' {
+		return &target{
+			Address: string(value),
+		}, true
+	}
+	var t target
+	if err := json.Unmarshal(value, &t); err != nil || t.Address == "" {
+		return nil, false
+	}
+	return &t, true
+}
+
+func getKeyValuesLabels(kvs []*keyValue, prefix string) []*promutils.Labels {
+	ms := make([]*promutils.Labels, 0, len(kvs))
+	for _, kv := range kvs {
+		t, ok := parseTarget(kv.Value)
+		if !ok {
+			// Skip values without target address.
+			continue
+		}
+		ms = append(ms, kv.appendTargetLabels(t, prefix))
+	}
+	return ms
+}
+
+func (kv *keyValue) appendTargetLabels(t *target, prefix string) *promutils.Labels {
+	m := promutils.NewLabels(8 + len(t.Labels))
+	m.Add("__address__", t.Address)
+	m.Add("__meta_etcd_key", string(kv.Key))
+	m.Add("__meta_etcd_key_suffix", strings.TrimPrefix(string(kv.Key), prefix))
+	m.Add("__meta_etcd_prefix", prefix)
+	m.Add("__meta_etcd_value", string(kv.Value))
+	m.Add("__meta_etcd_create_revision", strconv.FormatInt(kv.CreateRevision, 10))
+	m.Add("__meta_etcd_mod_revision", strconv.FormatInt(kv.ModRevision, 10))
+	m.Add("__meta_etcd_version", strconv.FormatInt(kv.Version, 10))
+	for name, value := range t.Labels {
+		m.Add(discoveryutils.SanitizeLabelName("__meta_etcd_label_"+name), value)
+	}
+	return m
+}
+
+func sortKeyValues(kvs []*keyValue) {
+	sort.Slice(kvs, func(i, j int) bool {
+		return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0
+	})
+}
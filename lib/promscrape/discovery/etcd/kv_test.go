@@ -0,0 +1,98 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestParseTarget(t *testing.T) {
+	f := func(value, addressExpected string, labelsExpected map[string]string) {
+		t.Helper()
+		tg, ok := parseTarget([]byte(value))
+		if addressExpected == "" {
+			if ok {
+				t.Fatalf("expecting failed parsing for %q; got %+v", value, tg)
+			}
+			return
+		}
+		if !ok {
+			t.Fatalf("cannot parse %q", value)
+		}
+		if tg.Address != addressExpected {
+			t.Fatalf("unexpected address; got %q; want %q", tg.Address, addressExpected)
+		}
+		if len(tg.Labels) != len(labelsExpected) {
+			t.Fatalf("unexpected labels; got %v; want %v", tg.Labels, labelsExpected)
+		}
+		for k, v := range labelsExpected {
+			if tg.Labels[k] != v {
+				t.Fatalf("unexpected labels; got %v; want %v", tg.Labels, labelsExpected)
+			}
+		}
+	}
+
+	// plain address
+	f("10.0.0.1:8080", "10.0.0.1:8080", nil)
+	f(" foo:80\n", "foo:80", nil)
+
+	// JSON object
+	f(`{"address":"10.0.0.1:8080"}`, "10.0.0.1:8080", nil)
+	f(`{"address":"10.0.0.1:8080","labels":{"env":"prod"}}`, "10.0.0.1:8080", map[string]string{"env": "prod"})
+
+	// invalid values
+	f("", "", nil)
+	f("  ", "", nil)
+	f(`{"address":`, "", nil)
+	f(`{"labels":{"env":"prod"}}`, "", nil)
+}
+
+func TestGetKeyValuesLabels(t *testing.T) {
+	kvs := []*keyValue{
+		{
+			Key:            []byte("/services/foo/1"),
+			Value:          []byte(`{"address":"10.0.0.1:8080","labels":{"env":"prod","app.name":"foo"}}`),
+			CreateRevision: 10,
+			ModRevision:    12,
+			Version:        2,
+		},
+		{
+			Key:            []byte("/services/foo/2"),
+			Value:          []byte("10.0.0.2:8080"),
+			CreateRevision: 11,
+			ModRevision:    11,
+			Version:        1,
+		},
+		{
+			Key:   []byte("/services/foo/config"),
+			Value: []byte(`{"some":"config"}`),
+		},
+	}
+	labelss := getKeyValuesLabels(kvs, "/services/")
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                 "10.0.0.1:8080",
+			"__meta_etcd_key":             "/services/foo/1",
+			"__meta_etcd_key_suffix":      "foo/1",
+			"__meta_etcd_prefix":          "/services/",
+			"__meta_etcd_value":           `{"address":"10.0.0.1:8080","labels":{"env":"prod","app.name":"foo"}}`,
+			"__meta_etcd_create_revision": "10",
+			"__meta_etcd_mod_revision":    "12",
+			"__meta_etcd_version":         "2",
+			"__meta_etcd_label_env":       "prod",
+			"__meta_etcd_label_app_name":  "foo",
+		}),
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                 "10.0.0.2:8080",
+			"__meta_etcd_key":             "/services/foo/2",
+			"__meta_etcd_key_suffix":      "foo/2",
+			"__meta_etcd_prefix":          "/services/",
+			"__meta_etcd_value":           "10.0.0.2:8080",
+			"__meta_etcd_create_revision": "11",
+			"__meta_etcd_mod_revision":    "11",
+			"__meta_etcd_version":         "1",
+		}),
+	}
+	discoveryutils.TestEqualLabelss(t, labelss, expectedLabelss)
+}
@@ -0,0 +1,211 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// mockServer is a minimal in-memory etcd v3 gateway for tests.
+type mockServer struct {
+	s *httptest.Server
+
+	mu       sync.Mutex
+	kvs      map[string]*keyValue
+	revision int64
+	events   []watchEvent
+	// compactRevision is the oldest revision available for watches.
+	compactRevision int64
+	// changedCh is closed and re-created on every change.
+	changedCh chan struct{}
+
+	// username and password are checked if set.
+	username string
+	password string
+}
+
+func newMockServer() *mockServer {
+	ms := &mockServer{
+		kvs:       make(map[string]*keyValue),
+		revision:  1,
+		changedCh: make(chan struct{}),
+	}
+	ms.s = httptest.NewServer(http.HandlerFunc(ms.handler))
+	return ms
+}
+
+func (ms *mockServer) close() {
+	ms.s.CloseClientConnections()
+	ms.s.Close()
+}
+
+func (ms *mockServer) put(key, value string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.revision++
+	kv := ms.kvs[key]
+	if kv == nil {
+		kv = &keyValue{
+			Key:            []byte(key),
+			CreateRevision: ms.revision,
+		}
+		ms.kvs[key] = kv
+	}
+	kv.Value = []byte(value)
+	kv.ModRevision = ms.revision
+	kv.Version++
+	ms.events = append(ms.events, watchEvent{
+		Kv: *kv,
+	})
+	ms.notifyLocked()
+}
+
+func (ms *mockServer) delete(key string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.revision++
+	delete(ms.kvs, key)
+	ms.events = append(ms.events, watchEvent{
+		Type: "DELETE",
+		Kv: keyValue{
+			Key:         []byte(key),
+			ModRevision: ms.revision,
+		},
+	})
+	ms.notifyLocked()
+}
+
+// compact drops the event history and breaks active watches.
+func (ms *mockServer) compact() {
+	ms.mu.Lock()
+	ms.events = nil
+	ms.compactRevision = ms.revision + 1
+	ms.mu.Unlock()
+	ms.s.CloseClientConnections()
+}
+
+func (ms *mockServer) notifyLocked() {
+	close(ms.changedCh)
+	ms.changedCh = make(chan struct{})
+}
+
+func (ms *mockServer) handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+		return
+	}
+	if ms.username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != ms.username || password != ms.password {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		ms.handleRange(w, r)
+	case "/v3/watch":
+		ms.handleWatch(w, r)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (ms *mockServer) handleRange(w http.ResponseWriter, r *http.Request) {
+	var rr rangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ms.mu.Lock()
+	resp := rangeResponse{
+		Header: responseHeader{
+			Revision: ms.revision,
+		},
+	}
+	for _, kv := range ms.kvs {
+		if inRange(kv.Key, rr.Key, rr.RangeEnd) {
+			resp.Kvs = append(resp.Kvs, *kv)
+		}
+	}
+	ms.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&resp)
+}
+
+func (ms *mockServer) handleWatch(w http.ResponseWriter, r *http.Request) {
+	var wr watchCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&wr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cr := wr.CreateRequest
+	w.Header().Set("Content-Type", "application/json")
+	flusher := w.(http.Flusher)
+	send := func(resp *watchResponse) {
+		_ = json.NewEncoder(w).Encode(&watchResponseEnvelope{
+			Result: resp,
+		})
+		flusher.Flush()
+	}
+
+	ms.mu.Lock()
+	send(&watchResponse{
+		Header: responseHeader{
+			Revision: ms.revision,
+		},
+		Created: true,
+	})
+	if cr.StartRevision < ms.compactRevision {
+		send(&watchResponse{
+			Header: responseHeader{
+				Revision: ms.revision,
+			},
+			Canceled:        true,
+			CompactRevision: ms.compactRevision,
+		})
+		ms.mu.Unlock()
+		return
+	}
+	nextRevision := cr.StartRevision
+	for {
+		var events []watchEvent
+		for _, e := range ms.events {
+			if e.Kv.ModRevision >= nextRevision && inRange(e.Kv.Key, cr.Key, cr.RangeEnd) {
+				events = append(events, e)
+			}
+		}
+		nextRevision = ms.revision + 1
+		if len(events) > 0 {
+			send(&watchResponse{
+				Header: responseHeader{
+					Revision: ms.revision,
+				},
+				Events: events,
+			})
+		}
+		changedCh := ms.changedCh
+		ms.mu.Unlock()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changedCh:
+		}
+		ms.mu.Lock()
+	}
+}
+
+func inRange(key, start, end []byte) bool {
+	if len(end) == 0 {
+		return bytes.Equal(key, start)
+	}
+	if bytes.Compare(key, start) < 0 {
+		return false
+	}
+	return bytes.Equal(end, []byte{0}) || bytes.Compare(key, end) < 0
+}
@@ -0,0 +1,182 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// watchDuration is the maximum duration of a single watch request.
+//
+// The watch is periodically re-created from the last seen revision in order to detect silently broken connections.
+var watchDuration = 5 * time.Minute
+
+var (
+	etcdRangesTotal        = metrics.NewCounter(`vm_promscrape_discovery_etcd_ranges_total`)
+	etcdRangeErrorsTotal   = metrics.NewCounter(`vm_promscrape_discovery_etcd_range_errors_total`)
+	etcdWatchEventsTotal   = metrics.NewCounter(`vm_promscrape_discovery_etcd_watch_events_total`)
+	etcdWatchErrorsTotal   = metrics.NewCounter(`vm_promscrape_discovery_etcd_watch_errors_total`)
+	etcdWatchCompactsTotal = metrics.NewCounter(`vm_promscrape_discovery_etcd_watch_compactions_total`)
+)
+
+// prefixWatcher maintains an up-to-date snapshot of etcd keys with the given prefix.
+type prefixWatcher struct {
+	c      *client
+	prefix string
+
+	mu       sync.Mutex
+	kvs      map[string]*keyValue
+	revision int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newPrefixWatcher returns prefixWatcher for the given prefix.
+//
+// It returns an error if the initial snapshot cannot be obtained.
+func newPrefixWatcher(c *client, prefix string) (*prefixWatcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pw := &prefixWatcher{
+		c:      c,
+		prefix: prefix,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if err := pw.reload(); err != nil {
+		// Try other endpoints before giving up.
+		for i := 1; i < len(c.endpoints) && err != nil; i++ {
+			err = pw.reload()
+		}
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	pw.wg.Add(1)
+	go func() {
+		defer pw.wg.Done()
+		pw.watch()
+	}()
+	logger.Infof("started etcd watcher for prefix %q at %q", prefix, c.endpoints)
+	return pw, nil
+}
+
+func (pw *prefixWatcher) mustStop() {
+	pw.cancel()
+	pw.wg.Wait()
+	pw.c.hc.CloseIdleConnections()
+	logger.Infof("stopped etcd watcher for prefix %q at %q", pw.prefix, pw.c.endpoints)
+}
+
+// getKeyValues returns a snapshot of key-value pairs sorted by key.
+func (pw *prefixWatcher) getKeyValues() []*keyValue {
+	pw.mu.Lock()
+	kvs := make([]*keyValue, 0, len(pw.kvs))
+	for _, kv := range pw.kvs {
+		kvs = append(kvs, kv)
+	}
+	pw.mu.Unlock()
+	sortKeyValues(kvs)
+	return kvs
+}
+
+// reload re-reads all the keys with pw.prefix.
+func (pw *prefixWatcher) reload() error {
+	etcdRangesTotal.Inc()
+	kvs, revision, err := pw.c.rangePrefix(pw.ctx, pw.prefix)
+	if err != nil {
+		etcdRangeErrorsTotal.Inc()
+		return fmt.Errorf("cannot read keys with prefix %q: %w", pw.prefix, err)
+	}
+	m := make(map[string]*keyValue, len(kvs))
+	for i := range kvs {
+		kv := &kvs[i]
+		m[string(kv.Key)] = kv
+	}
+	pw.mu.Lock()
+	pw.kvs = m
+	pw.revision = revision
+	pw.mu.Unlock()
+	return nil
+}
+
+// watch applies changes to pw.kvs until pw.ctx is canceled.
+func (pw *prefixWatcher) watch() {
+	needReload := false
+	backoff := time.Second
+	for {
+		if needReload {
+			if err := pw.reload(); err != nil {
+				logger.Errorf("%s; retrying in %s", err, backoff)
+				if !discoveryutils.SleepCtx(pw.ctx, backoff) {
+					return
+				}
+				if backoff < time.Minute {
+					backoff *= 2
+				}
+				continue
+			}
+			needReload = false
+		}
+
+		pw.mu.Lock()
+		startRevision := pw.revision + 1
+		pw.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(pw.ctx, watchDuration)
+		err := pw.c.watchPrefix(ctx, pw.prefix, startRevision, pw.applyEvents)
+		cancel()
+		if pw.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// The watch has been finished because of watchDuration. Continue watching from the last seen revision.
+			backoff = time.Second
+			continue
+		}
+		var ec *errCompacted
+		if errors.As(err, &ec) {
+			// Some changes have been missed. Re-read all the keys.
+			etcdWatchCompactsTotal.Inc()
+			needReload = true
+			continue
+		}
+		etcdWatchErrorsTotal.Inc()
+		logger.Errorf("error when watching etcd keys with prefix %q: %s; retrying in %s", pw.prefix, err, backoff)
+		if !discoveryutils.SleepCtx(pw.ctx, backoff) {
+			return
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (pw *prefixWatcher) applyEvents(events []watchEvent, revision int64) {
+	etcdWatchEventsTotal.Add(len(events))
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	for i := range events {
+		e := &events[i]
+		key := string(e.Kv.Key)
+		switch e.Type {
+		case "", "PUT":
+			kv := e.Kv
+			pw.kvs[key] = &kv
+		case "DELETE":
+			delete(pw.kvs, key)
+		}
+	}
+	if revision > pw.revision {
+		pw.revision = revision
+	}
+}
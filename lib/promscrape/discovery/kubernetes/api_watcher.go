@@ -157,7 +157,7 @@ func (aw *apiWatcher) updateScrapeWorks(uw *urlWatcher, swosByKey map[string][]a
 }
 
 func (aw *apiWatcher) setScrapeWorks(uw *urlWatcher, key string, labelss []*promutils.Labels) {
-	swos := getScrapeWorkObjectsForLabels(aw.swcFunc, labelss)
+	swos := aw.getScrapeWorkObjectsForLabels(labelss)
 	aw.swosByURLWatcherLock.Lock()
 	swosByKey := aw.swosByURLWatcher[uw]
 	if swosByKey == nil {
@@ -183,11 +183,24 @@ func (aw *apiWatcher) removeScrapeWorks(uw *urlWatcher, key string) {
 	aw.swosByURLWatcherLock.Unlock()
 }
 
-func getScrapeWorkObjectsForLabels(swcFunc ScrapeWorkConstructorFunc, labelss []*promutils.Labels) []any {
+// getScrapeWorkObjectsForLabels must be called under aw.gw.mu lock.
+func (aw *apiWatcher) getScrapeWorkObjectsForLabels(labelss []*promutils.Labels) []any {
+	isMonitorRole := isMonitorRole(aw.role)
 	// Do not pre-allocate swos, since it is likely the swos will be empty because of relabeling
 	var swos []any
+	var mes map[string]*MonitorEndpoint
+	if isMonitorRole {
+		mes = make(map[string]*MonitorEndpoint)
+	}
 	for _, labels := range labelss {
-		swo := swcFunc(labels)
+		var me *MonitorEndpoint
+		if isMonitorRole {
+			me = aw.gw.getMonitorEndpointLocked(aw.role, labels, mes)
+			if me == nil {
+				continue
+			}
+		}
+		swo := aw.swcFunc(labels, me)
 		// The reflect check is needed because of https://mangatmodi.medium.com/go-check-nil-interface-the-right-way-d142776edef1
 		if swo != nil && !reflect.ValueOf(swo).IsNil() {
 			swos = append(swos, swo)
@@ -416,7 +429,7 @@ func (gw *groupWatcher) getScrapeWorkObjectsByAPIWatcherLocked(objectsByKey map[
 		limiterCh <- struct{}{}
 		go func(key string, labelss []*promutils.Labels) {
 			for aw, e := range swosByAPIWatcher {
-				swos := getScrapeWorkObjectsForLabels(aw.swcFunc, labelss)
+				swos := aw.getScrapeWorkObjectsForLabels(labelss)
 				e.mu.Lock()
 				e.swosByKey[key] = swos
 				e.mu.Unlock()
@@ -464,6 +477,15 @@ func (gw *groupWatcher) startWatchersForRole(role string, aw *apiWatcher) {
 		gw.startWatchersForRole("pod", nil)
 		gw.startWatchersForRole("service", nil)
 	}
+	if isMonitorRole(role) {
+		// servicemonitor and podmonitor watchers query endpoints, pod, service and secret objects. So start watchers for these roles as well.
+		if role == "servicemonitor" {
+			gw.startWatchersForRole("endpoints", nil)
+		} else {
+			gw.startWatchersForRole("pod", nil)
+		}
+		gw.startWatchersForRole("secret", nil)
+	}
 	if gw.attachNodeMetadata && (role == "pod" || role == "endpoints" || role == "endpointslice") {
 		gw.startWatchersForRole("node", nil)
 	}
@@ -485,7 +507,7 @@ func (gw *groupWatcher) startWatchersForRole(role string, aw *apiWatcher) {
 		if needStart {
 			uw.reloadObjects()
 			go uw.watchForUpdates()
-			if role == "endpoints" || role == "endpointslice" || (gw.attachNodeMetadata && role == "pod") {
+			if role == "endpoints" || role == "endpointslice" || isMonitorRole(role) || (gw.attachNodeMetadata && role == "pod") {
 				// Refresh targets in background, since they depend on other object types such as pod, service or node.
 				// This should guarantee that the ScrapeWork objects for these objects are properly updated
 				// as soon as the objects they depend on are updated.
@@ -950,12 +972,20 @@ func (uw *urlWatcher) removeObjectLocked(key string) {
 func (uw *urlWatcher) maybeUpdateDependedScrapeWorksLocked() {
 	role := uw.role
 	attachNodeMetadata := uw.gw.attachNodeMetadata
-	if !(role == "pod" || role == "service" || (attachNodeMetadata && role == "node")) {
+	if !(role == "pod" || role == "service" || role == "endpoints" || role == "secret" || (attachNodeMetadata && role == "node")) {
 		// Nothing to update
 		return
 	}
 	namespace := uw.namespace
 	for _, uwx := range uw.gw.m {
+		if isMonitorRole(uwx.role) {
+			// servicemonitor and podmonitor objects may select objects from other namespaces, so do not check for namespace match.
+			// podmonitor objects do not depend on endpoints and service objects.
+			if uwx.role == "servicemonitor" || (role != "endpoints" && role != "service") {
+				uwx.needRecreateScrapeWorks = true
+			}
+			continue
+		}
 		if namespace != "" && uwx.namespace != "" && uwx.namespace != namespace {
 			// Namespace mismatch
 			continue
@@ -1034,6 +1064,9 @@ func getAPIPath(objectType, namespace, query string) string {
 	if objectType == "endpointslices" {
 		return "/apis/discovery.k8s.io/v1/" + suffix
 	}
+	if objectType == "servicemonitors" || objectType == "podmonitors" {
+		return "/apis/monitoring.coreos.com/v1/" + suffix
+	}
 	return "/api/v1/" + suffix
 }
 
@@ -1074,6 +1107,12 @@ func getObjectTypeByRole(role string) string {
 		return "endpointslices"
 	case "ingress":
 		return "ingresses"
+	case "servicemonitor":
+		return "servicemonitors"
+	case "podmonitor":
+		return "podmonitors"
+	case "secret":
+		return "secrets"
 	default:
 		logger.Panicf("BUG: unknonw role=%q", role)
 		return ""
@@ -1094,12 +1133,23 @@ func getObjectParsersForRole(role string) (parseObjectFunc, parseObjectListFunc)
 		return parseEndpointSlice, parseEndpointSliceList
 	case "ingress":
 		return parseIngress, parseIngressList
+	case "servicemonitor":
+		return parseServiceMonitor, parseServiceMonitorList
+	case "podmonitor":
+		return parsePodMonitor, parsePodMonitorList
+	case "secret":
+		return parseSecret, parseSecretList
 	default:
 		logger.Panicf("BUG: unsupported role=%q", role)
 		return nil, nil
 	}
 }
 
+// isMonitorRole returns true if role discovers targets via prometheus-operator ServiceMonitor or PodMonitor objects.
+func isMonitorRole(role string) bool {
+	return role == "servicemonitor" || role == "podmonitor"
+}
+
 func getQueryArgsDelimiter(apiURL string) string {
 	if strings.Contains(apiURL, "?") {
 		return "&"
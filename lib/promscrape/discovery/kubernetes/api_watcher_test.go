@@ -166,6 +166,24 @@ func TestGetAPIPathsWithNamespaces(t *testing.T) {
 		"/apis/networking.k8s.io/v1/namespaces/x/ingresses?labelSelector=cde%2Cbaaa&fieldSelector=abc",
 		"/apis/networking.k8s.io/v1/namespaces/y/ingresses?labelSelector=cde%2Cbaaa&fieldSelector=abc",
 	})
+
+	// role=servicemonitor
+	f("servicemonitor", nil, nil, []string{"/apis/monitoring.coreos.com/v1/servicemonitors"})
+	f("servicemonitor", []string{"x"}, []Selector{
+		{
+			Role:  "servicemonitor",
+			Label: "team=infra",
+		},
+	}, []string{"/apis/monitoring.coreos.com/v1/namespaces/x/servicemonitors?labelSelector=team%3Dinfra"})
+
+	// role=podmonitor
+	f("podmonitor", []string{"x", "y"}, nil, []string{
+		"/apis/monitoring.coreos.com/v1/namespaces/x/podmonitors",
+		"/apis/monitoring.coreos.com/v1/namespaces/y/podmonitors",
+	})
+
+	// secrets are watched for servicemonitor and podmonitor roles
+	f("secret", nil, nil, []string{"/api/v1/secrets"})
 }
 
 func TestParseBookmark(t *testing.T) {
@@ -921,7 +939,7 @@ func TestGetScrapeWorkObjects(t *testing.T) {
 			}
 			testAPIServer := httptest.NewServer(mux)
 			tc.sdc.APIServer = testAPIServer.URL
-			ac, err := newAPIConfig(tc.sdc, "", func(metaLabels *promutils.Labels) any {
+			ac, err := newAPIConfig(tc.sdc, "", func(metaLabels *promutils.Labels, _ *MonitorEndpoint) any {
 				var res []any
 				for _, label := range metaLabels.Labels {
 					res = append(res, label.Name)
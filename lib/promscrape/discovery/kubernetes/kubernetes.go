@@ -70,7 +70,10 @@ type Selector struct {
 }
 
 // ScrapeWorkConstructorFunc must construct ScrapeWork object for the given metaLabels.
-type ScrapeWorkConstructorFunc func(metaLabels *promutils.Labels) any
+//
+// me contains additional scrape settings for targets discovered via `servicemonitor` and `podmonitor` roles.
+// It is nil for the rest of roles.
+type ScrapeWorkConstructorFunc func(metaLabels *promutils.Labels, me *MonitorEndpoint) any
 
 // GetScrapeWorkObjects returns ScrapeWork objects for the given sdc.
 //
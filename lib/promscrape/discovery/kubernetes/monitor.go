@@ -0,0 +1,472 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+var monitorAllowTLSFiles = flag.Bool("promscrape.kubernetes.monitorAllowTLSFiles", false, "Whether to allow `caFile`, `certFile` and `keyFile` options "+
+	"at `tlsConfig` of ServiceMonitor and PodMonitor endpoints discovered via kubernetes_sd_configs with `role: servicemonitor` and `role: podmonitor`. "+
+	"These options refer to local files, so they are rejected by default, since ServiceMonitor and PodMonitor objects may be created by less trusted users. "+
+	"See https://docs.victoriametrics.com/sd_configs/#kubernetes_sd_configs")
+
+// MonitorEndpoint contains scrape settings for targets discovered via ServiceMonitor and PodMonitor endpoints,
+// which cannot be passed to ScrapeWorkConstructorFunc via target labels.
+type MonitorEndpoint struct {
+	// Key uniquely identifies the endpoint. It has the form `<role>/<namespace>/<name>/<endpoint_index>`.
+	Key string
+
+	HonorLabels          bool
+	HonorTimestamps      *bool
+	MetricRelabelConfigs []promrelabel.RelabelConfig
+
+	// HTTPClientConfig contains tls, basic auth and bearer token settings for the endpoint.
+	// Secrets referred by the endpoint are already resolved.
+	HTTPClientConfig promauth.HTTPClientConfig
+}
+
+// MonitorEndpointSpec is a common part for ServiceMonitor endpoint and PodMonitor podMetricsEndpoint.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.Endpoint
+// and https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMetricsEndpoint
+type MonitorEndpointSpec struct {
+	Port              string
+	TargetPort        *IntOrString
+	Path              string
+	Scheme            string
+	Params            map[string][]string
+	Interval          string
+	ScrapeTimeout     string
+	HonorLabels       bool
+	HonorTimestamps   *bool
+	BasicAuth         *MonitorBasicAuth
+	BearerTokenSecret *SecretKeySelector
+	TLSConfig         *MonitorTLSConfig
+	Relabelings       []MonitorRelabelConfig
+	MetricRelabelings []MonitorRelabelConfig
+}
+
+// MonitorBasicAuth is basic auth config for ServiceMonitor and PodMonitor endpoints.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.BasicAuth
+type MonitorBasicAuth struct {
+	Username SecretKeySelector
+	Password SecretKeySelector
+}
+
+// MonitorTLSConfig is tls config for ServiceMonitor and PodMonitor endpoints.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.TLSConfig
+type MonitorTLSConfig struct {
+	CA                 SecretOrConfigMap
+	Cert               SecretOrConfigMap
+	KeySecret          *SecretKeySelector
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// SecretOrConfigMap refers either to a secret or to a config map key.
+//
+// Only secrets are supported at the moment.
+type SecretOrConfigMap struct {
+	Secret    *SecretKeySelector
+	ConfigMap *SecretKeySelector
+}
+
+// SecretKeySelector selects a key from a secret in the namespace of the referring object.
+type SecretKeySelector struct {
+	Name string
+	Key  string
+}
+
+// MonitorRelabelConfig is relabel config in prometheus-operator format.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.RelabelConfig
+type MonitorRelabelConfig struct {
+	SourceLabels []string
+	Separator    *string
+	TargetLabel  string
+	Regex        string
+	Modulus      uint64
+	Replacement  *string
+	Action       string
+}
+
+// IntOrString is either int or string value.
+type IntOrString struct {
+	IntVal   int
+	StrVal   string
+	IsString bool
+}
+
+// UnmarshalJSON unmarshals s from data.
+func (s *IntOrString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		s.IsString = true
+		return json.Unmarshal(data, &s.StrVal)
+	}
+	s.IsString = false
+	return json.Unmarshal(data, &s.IntVal)
+}
+
+// String returns string representation for s.
+func (s *IntOrString) String() string {
+	if s.IsString {
+		return s.StrVal
+	}
+	return strconv.Itoa(s.IntVal)
+}
+
+// LabelSelector implements k8s label selector.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.31/#labelselector-v1-meta
+type LabelSelector struct {
+	MatchLabels      map[string]string
+	MatchExpressions []LabelSelectorRequirement
+}
+
+// LabelSelectorRequirement implements k8s label selector requirement.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.31/#labelselectorrequirement-v1-meta
+type LabelSelectorRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// matches returns true if labels match ls.
+//
+// Empty ls matches all the labels.
+func (ls *LabelSelector) matches(labels *promutils.Labels) bool {
+	for k, v := range ls.MatchLabels {
+		value, ok := getLabelValue(labels, k)
+		if !ok || value != v {
+			return false
+		}
+	}
+	for _, e := range ls.MatchExpressions {
+		value, ok := getLabelValue(labels, e.Key)
+		switch e.Operator {
+		case "In":
+			if !ok || !slices.Contains(e.Values, value) {
+				return false
+			}
+		case "NotIn":
+			if ok && slices.Contains(e.Values, value) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func getLabelValue(labels *promutils.Labels, name string) (string, bool) {
+	for _, label := range labels.GetLabels() {
+		if label.Name == name {
+			return label.Value, true
+		}
+	}
+	return "", false
+}
+
+// NamespaceSelector selects namespaces for ServiceMonitor and PodMonitor targets.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.NamespaceSelector
+type NamespaceSelector struct {
+	Any        bool
+	MatchNames []string
+}
+
+// matches returns true if the given namespace is selected by ns.
+//
+// Only ownNamespace is selected if ns is empty.
+func (ns *NamespaceSelector) matches(namespace, ownNamespace string) bool {
+	if ns.Any {
+		return true
+	}
+	if len(ns.MatchNames) > 0 {
+		return slices.Contains(ns.MatchNames, namespace)
+	}
+	return namespace == ownNamespace
+}
+
+// matchesPort returns true if the target with labels m exposes the port selected by ep.
+//
+// portNameLabel is the name of the label containing the port name for the target.
+func (ep *MonitorEndpointSpec) matchesPort(m *promutils.Labels, portNameLabel string) bool {
+	if ep.Port != "" {
+		return m.Get(portNameLabel) == ep.Port
+	}
+	if ep.TargetPort != nil {
+		if ep.TargetPort.IsString {
+			return m.Get("__meta_kubernetes_pod_container_port_name") == ep.TargetPort.StrVal
+		}
+		return m.Get("__meta_kubernetes_pod_container_port_number") == strconv.Itoa(ep.TargetPort.IntVal)
+	}
+	return true
+}
+
+// endpointName returns the value for `endpoint` target label.
+func (ep *MonitorEndpointSpec) endpointName() string {
+	if ep.Port != "" {
+		return ep.Port
+	}
+	if ep.TargetPort != nil {
+		return ep.TargetPort.String()
+	}
+	return ""
+}
+
+// appendScrapeLabels adds scrape settings from ep to m.
+func (ep *MonitorEndpointSpec) appendScrapeLabels(m *promutils.Labels, sampleLimit int) {
+	scheme := ep.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	m.Set("__scheme__", scheme)
+	path := ep.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	m.Set("__metrics_path__", path)
+	if ep.Interval != "" {
+		m.Set("__scrape_interval__", ep.Interval)
+	}
+	if ep.ScrapeTimeout != "" {
+		m.Set("__scrape_timeout__", ep.ScrapeTimeout)
+	}
+	for k, vs := range ep.Params {
+		if len(vs) > 0 {
+			m.Set("__param_"+k, vs[0])
+		}
+	}
+	if sampleLimit > 0 {
+		m.Set("__sample_limit__", strconv.Itoa(sampleLimit))
+	}
+	if endpoint := ep.endpointName(); endpoint != "" {
+		m.Set("endpoint", endpoint)
+	}
+}
+
+// parseRelabelings returns parsed ep.Relabelings.
+func (ep *MonitorEndpointSpec) parseRelabelings() (*promrelabel.ParsedConfigs, error) {
+	pcs, err := promrelabel.ParseRelabelConfigs(toRelabelConfigs(ep.Relabelings))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `relabelings`: %w", err)
+	}
+	return pcs, nil
+}
+
+// newMonitorEndpoint returns MonitorEndpoint for ep with the given key.
+//
+// Secrets referred by ep are looked up in the given namespace.
+// This function must be called under gw.mu lock.
+func (ep *MonitorEndpointSpec) newMonitorEndpoint(gw *groupWatcher, key, namespace string) (*MonitorEndpoint, error) {
+	me := &MonitorEndpoint{
+		Key:                  key,
+		HonorLabels:          ep.HonorLabels,
+		HonorTimestamps:      ep.HonorTimestamps,
+		MetricRelabelConfigs: toRelabelConfigs(ep.MetricRelabelings),
+	}
+	if _, err := promrelabel.ParseRelabelConfigs(me.MetricRelabelConfigs); err != nil {
+		return nil, fmt.Errorf("cannot parse `metricRelabelings`: %w", err)
+	}
+	hcc := &me.HTTPClientConfig
+	if ba := ep.BasicAuth; ba != nil {
+		username, err := getSecretValueLocked(gw, namespace, &ba.Username)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain `basicAuth` username: %w", err)
+		}
+		password, err := getSecretValueLocked(gw, namespace, &ba.Password)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain `basicAuth` password: %w", err)
+		}
+		hcc.BasicAuth = &promauth.BasicAuthConfig{
+			Username: username,
+			Password: promauth.NewSecret(password),
+		}
+	}
+	if sks := ep.BearerTokenSecret; sks != nil && sks.Name != "" {
+		token, err := getSecretValueLocked(gw, namespace, sks)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain `bearerTokenSecret`: %w", err)
+		}
+		hcc.BearerToken = promauth.NewSecret(token)
+	}
+	if tc := ep.TLSConfig; tc != nil {
+		if (tc.CAFile != "" || tc.CertFile != "" || tc.KeyFile != "") && !*monitorAllowTLSFiles {
+			return nil, fmt.Errorf("`tlsConfig.caFile`, `tlsConfig.certFile` and `tlsConfig.keyFile` aren't allowed, since they refer to local files; " +
+				"pass -promscrape.kubernetes.monitorAllowTLSFiles command-line flag in order to allow them")
+		}
+		ca, err := getSecretOrConfigMapValueLocked(gw, namespace, &tc.CA)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain `tlsConfig.ca`: %w", err)
+		}
+		cert, err := getSecretOrConfigMapValueLocked(gw, namespace, &tc.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain `tlsConfig.cert`: %w", err)
+		}
+		var key string
+		if tc.KeySecret != nil {
+			key, err = getSecretValueLocked(gw, namespace, tc.KeySecret)
+			if err != nil {
+				return nil, fmt.Errorf("cannot obtain `tlsConfig.keySecret`: %w", err)
+			}
+		}
+		hcc.TLSConfig = &promauth.TLSConfig{
+			CA:                 ca,
+			CAFile:             tc.CAFile,
+			Cert:               cert,
+			CertFile:           tc.CertFile,
+			Key:                key,
+			KeyFile:            tc.KeyFile,
+			ServerName:         tc.ServerName,
+			InsecureSkipVerify: tc.InsecureSkipVerify,
+		}
+	}
+	return me, nil
+}
+
+func getSecretOrConfigMapValueLocked(gw *groupWatcher, namespace string, sc *SecretOrConfigMap) (string, error) {
+	if sc.ConfigMap != nil {
+		return "", fmt.Errorf("references to ConfigMap objects aren't supported; use Secret instead")
+	}
+	if sc.Secret == nil {
+		return "", nil
+	}
+	return getSecretValueLocked(gw, namespace, sc.Secret)
+}
+
+func getSecretValueLocked(gw *groupWatcher, namespace string, sks *SecretKeySelector) (string, error) {
+	o := gw.getObjectByRoleLocked("secret", namespace, sks.Name)
+	if o == nil {
+		return "", fmt.Errorf("cannot find secret %s/%s", namespace, sks.Name)
+	}
+	s := o.(*Secret)
+	v, ok := s.Data[sks.Key]
+	if !ok {
+		return "", fmt.Errorf("cannot find key %q in secret %s/%s", sks.Key, namespace, sks.Name)
+	}
+	return string(v), nil
+}
+
+func toRelabelConfigs(mrcs []MonitorRelabelConfig) []promrelabel.RelabelConfig {
+	if len(mrcs) == 0 {
+		return nil
+	}
+	rcs := make([]promrelabel.RelabelConfig, len(mrcs))
+	for i, mrc := range mrcs {
+		rc := &rcs[i]
+		// prometheus-operator accepts actions in CamelCase, e.g. `LabelMap`.
+		rc.Action = strings.ToLower(mrc.Action)
+		rc.SourceLabels = mrc.SourceLabels
+		rc.Separator = mrc.Separator
+		rc.TargetLabel = mrc.TargetLabel
+		if mrc.Regex != "" {
+			rc.Regex = &promrelabel.MultiLineRegex{
+				S: mrc.Regex,
+			}
+		}
+		rc.Modulus = mrc.Modulus
+		rc.Replacement = mrc.Replacement
+	}
+	return rcs
+}
+
+// appendMonitorTargetLabels applies pcs to m, adds labels identifying the monitor endpoint to m and appends m to ms.
+//
+// m is dropped if it doesn't survive relabeling.
+func appendMonitorTargetLabels(ms []*promutils.Labels, m *promutils.Labels, pcs *promrelabel.ParsedConfigs, role, namespace, name string, endpointIdx int) []*promutils.Labels {
+	m.RemoveDuplicates()
+	m.Labels = pcs.Apply(m.Labels, 0)
+	if len(m.Labels) == 0 {
+		promutils.PutLabels(m)
+		return ms
+	}
+	// Add labels identifying the monitor endpoint after the relabeling,
+	// so they could be used for obtaining MonitorEndpoint in getMonitorEndpointLocked.
+	prefix := "__meta_kubernetes_" + role + "_"
+	m.Set(prefix+"namespace", namespace)
+	m.Set(prefix+"name", name)
+	m.Set(prefix+"endpoint", strconv.Itoa(endpointIdx))
+	return append(ms, m)
+}
+
+// addTargetLabelsFromObject copies values for the given labelNames from om labels to m.
+func addTargetLabelsFromObject(m *promutils.Labels, om *ObjectMeta, labelNames []string) {
+	for _, name := range labelNames {
+		if v, ok := getLabelValue(om.Labels, name); ok {
+			m.Set(discoveryutils.SanitizeLabelName(name), v)
+		}
+	}
+}
+
+// getMonitorEndpointLocked returns MonitorEndpoint for the target with the given labels discovered via the given monitor role.
+//
+// mes caches MonitorEndpoint objects by their keys, so secrets are resolved and relabelings are parsed once per monitor endpoint
+// instead of once per target.
+// nil is returned if the MonitorEndpoint cannot be found.
+// This function must be called under gw.mu lock.
+func (gw *groupWatcher) getMonitorEndpointLocked(role string, labels *promutils.Labels, mes map[string]*MonitorEndpoint) *MonitorEndpoint {
+	prefix := "__meta_kubernetes_" + role + "_"
+	namespace := labels.Get(prefix + "namespace")
+	name := labels.Get(prefix + "name")
+	endpointIdx, err := strconv.Atoi(labels.Get(prefix + "endpoint"))
+	if err != nil {
+		return nil
+	}
+	key := fmt.Sprintf("%s/%s/%s/%d", role, namespace, name, endpointIdx)
+	if me, ok := mes[key]; ok {
+		return me
+	}
+	me := gw.newMonitorEndpointLocked(role, namespace, name, endpointIdx, key)
+	mes[key] = me
+	return me
+}
+
+func (gw *groupWatcher) newMonitorEndpointLocked(role, namespace, name string, endpointIdx int, key string) *MonitorEndpoint {
+	o := gw.getObjectByRoleLocked(role, namespace, name)
+	if o == nil {
+		return nil
+	}
+	var endpoints []MonitorEndpointSpec
+	switch t := o.(type) {
+	case *ServiceMonitor:
+		endpoints = t.Spec.Endpoints
+	case *PodMonitor:
+		endpoints = t.Spec.PodMetricsEndpoints
+	default:
+		return nil
+	}
+	if endpointIdx < 0 || endpointIdx >= len(endpoints) {
+		return nil
+	}
+	me, err := endpoints[endpointIdx].newMonitorEndpoint(gw, key, namespace)
+	if err != nil {
+		// The error is already logged at getTargetLabels.
+		return nil
+	}
+	return me
+}
@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func (pm *PodMonitor) key() string {
+	return pm.Metadata.key()
+}
+
+func parsePodMonitorList(r io.Reader) (map[string]object, ListMeta, error) {
+	var pml PodMonitorList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&pml); err != nil {
+		return nil, pml.Metadata, fmt.Errorf("cannot unmarshal PodMonitorList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, pm := range pml.Items {
+		objectsByKey[pm.key()] = pm
+	}
+	return objectsByKey, pml.Metadata, nil
+}
+
+func parsePodMonitor(data []byte) (object, error) {
+	var pm PodMonitor
+	if err := json.Unmarshal(data, &pm); err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
+// PodMonitorList is prometheus-operator PodMonitor list.
+type PodMonitorList struct {
+	Metadata ListMeta
+	Items    []*PodMonitor
+}
+
+// PodMonitor is prometheus-operator PodMonitor.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMonitor
+type PodMonitor struct {
+	Metadata ObjectMeta
+	Spec     PodMonitorSpec
+}
+
+// PodMonitorSpec is prometheus-operator PodMonitor spec.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMonitorSpec
+type PodMonitorSpec struct {
+	JobLabel            string
+	PodTargetLabels     []string
+	PodMetricsEndpoints []MonitorEndpointSpec
+	Selector            LabelSelector
+	NamespaceSelector   NamespaceSelector
+	SampleLimit         int
+}
+
+// getTargetLabels returns labels for container ports of pods selected by pm.
+//
+// The labels are generated in the same way as prometheus-operator does for `role: pod`.
+func (pm *PodMonitor) getTargetLabels(gw *groupWatcher) []*promutils.Labels {
+	var ms []*promutils.Labels
+	for i := range pm.Spec.PodMetricsEndpoints {
+		ep := &pm.Spec.PodMetricsEndpoints[i]
+		pcs, err := ep.parseRelabelings()
+		if err == nil {
+			_, err = ep.newMonitorEndpoint(gw, "", pm.Metadata.Namespace)
+		}
+		if err != nil {
+			logger.Errorf("skipping podMetricsEndpoint #%d at PodMonitor %s: %s", i+1, pm.key(), err)
+			continue
+		}
+		for _, uw := range gw.m {
+			if uw.role != "pod" {
+				continue
+			}
+			for _, o := range uw.objectsByKey {
+				p := o.(*Pod)
+				if !pm.Spec.NamespaceSelector.matches(p.Metadata.Namespace, pm.Metadata.Namespace) {
+					continue
+				}
+				if !pm.Spec.Selector.matches(p.Metadata.Labels) {
+					continue
+				}
+				for _, m := range p.getTargetLabels(gw) {
+					if !ep.matchesPort(m, "__meta_kubernetes_pod_container_port_name") {
+						promutils.PutLabels(m)
+						continue
+					}
+					pm.appendCommonLabels(m, ep, p)
+					ms = appendMonitorTargetLabels(ms, m, pcs, "podmonitor", pm.Metadata.Namespace, pm.Metadata.Name, i)
+				}
+			}
+		}
+	}
+	return ms
+}
+
+func (pm *PodMonitor) appendCommonLabels(m *promutils.Labels, ep *MonitorEndpointSpec, p *Pod) {
+	job := pm.Metadata.Namespace + "/" + pm.Metadata.Name
+	if pm.Spec.JobLabel != "" {
+		if v, ok := getLabelValue(p.Metadata.Labels, pm.Spec.JobLabel); ok {
+			job = v
+		}
+	}
+	m.Set("job", job)
+	m.Set("namespace", p.Metadata.Namespace)
+	m.Set("pod", p.Metadata.Name)
+	m.Set("container", m.Get("__meta_kubernetes_pod_container_name"))
+	addTargetLabelsFromObject(m, &p.Metadata, pm.Spec.PodTargetLabels)
+	ep.appendScrapeLabels(m, pm.Spec.SampleLimit)
+}
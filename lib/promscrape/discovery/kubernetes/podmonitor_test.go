@@ -0,0 +1,183 @@
+package kubernetes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestParsePodMonitorListFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		r := bytes.NewBufferString(s)
+		objectsByKey, _, err := parsePodMonitorList(r)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if len(objectsByKey) != 0 {
+			t.Fatalf("unexpected non-empty objectsByKey: %v", objectsByKey)
+		}
+	}
+	f(``)
+	f(`[1,23]`)
+	f(`{"items":[{"metadata":1}]}`)
+	f(`{"items":[{"spec":{"podMetricsEndpoints":1}}]}`)
+}
+
+func TestPodMonitorGetTargetLabels(t *testing.T) {
+	newPod := func(name, namespace, app, phase string) *Pod {
+		return &Pod{
+			Metadata: ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: promutils.NewLabelsFromMap(map[string]string{
+					"app": app,
+				}),
+			},
+			Spec: PodSpec{
+				Containers: []Container{
+					{
+						Name:  "app",
+						Image: "app-image",
+						Ports: []ContainerPort{
+							{
+								Name:          "metrics",
+								ContainerPort: 8080,
+								Protocol:      "TCP",
+							},
+							{
+								Name:          "http",
+								ContainerPort: 80,
+								Protocol:      "TCP",
+							},
+						},
+					},
+				},
+			},
+			Status: PodStatus{
+				Phase: phase,
+				PodIP: "10.0.0.1",
+			},
+		}
+	}
+	pm := &PodMonitor{
+		Metadata: ObjectMeta{
+			Name:      "app-monitor",
+			Namespace: "monitoring",
+		},
+		Spec: PodMonitorSpec{
+			Selector: LabelSelector{
+				MatchExpressions: []LabelSelectorRequirement{
+					{
+						Key:      "app",
+						Operator: "In",
+						Values:   []string{"foo"},
+					},
+				},
+			},
+			NamespaceSelector: NamespaceSelector{
+				MatchNames: []string{"default"},
+			},
+			SampleLimit: 100,
+			PodMetricsEndpoints: []MonitorEndpointSpec{
+				{
+					Port:   "metrics",
+					Scheme: "https",
+					Params: map[string][]string{
+						"format": {"prometheus"},
+					},
+					Relabelings: []MonitorRelabelConfig{
+						{
+							Action: "LabelDrop",
+							Regex:  "__meta_kubernetes_pod_(container|label|labelpresent|phase|ready)_?.*",
+						},
+					},
+				},
+			},
+		},
+	}
+	var gw groupWatcher
+	gw.m = map[string]*urlWatcher{
+		"pod": {
+			role: "pod",
+			objectsByKey: map[string]object{
+				"default/foo":        newPod("foo", "default", "foo", "Running"),
+				"default/foo-failed": newPod("foo-failed", "default", "foo", "Failed"),
+				"default/bar":        newPod("bar", "default", "bar", "Running"),
+				"other/foo":          newPod("foo", "other", "foo", "Running"),
+			},
+		},
+		"podmonitor": {
+			role: "podmonitor",
+			objectsByKey: map[string]object{
+				"monitoring/app-monitor": pm,
+			},
+		},
+	}
+
+	labelss := pm.getTargetLabels(&gw)
+	for _, labels := range labelss {
+		labels.Sort()
+	}
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                            "10.0.0.1:8080",
+			"__meta_kubernetes_namespace":            "default",
+			"__meta_kubernetes_pod_ip":               "10.0.0.1",
+			"__meta_kubernetes_pod_name":             "foo",
+			"__meta_kubernetes_podmonitor_endpoint":  "0",
+			"__meta_kubernetes_podmonitor_name":      "app-monitor",
+			"__meta_kubernetes_podmonitor_namespace": "monitoring",
+			"__metrics_path__":                       "/metrics",
+			"__param_format":                         "prometheus",
+			"__sample_limit__":                       "100",
+			"__scheme__":                             "https",
+			"container":                              "app",
+			"endpoint":                               "metrics",
+			"job":                                    "monitoring/app-monitor",
+			"namespace":                              "default",
+			"pod":                                    "foo",
+		}),
+	}
+	if !areEqualLabelss(labelss, expectedLabelss) {
+		t.Fatalf("unexpected labels:\ngot\n%v\nwant\n%v", labelss, expectedLabelss)
+	}
+
+	me := gw.getMonitorEndpointLocked("podmonitor", labelss[0], make(map[string]*MonitorEndpoint))
+	if me == nil {
+		t.Fatalf("cannot obtain MonitorEndpoint")
+	}
+	if me.Key != "podmonitor/monitoring/app-monitor/0" {
+		t.Fatalf("unexpected key; got %q; want %q", me.Key, "podmonitor/monitoring/app-monitor/0")
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	f := func(ls *LabelSelector, m map[string]string, resultExpected bool) {
+		t.Helper()
+		result := ls.matches(promutils.NewLabelsFromMap(m))
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+	labels := map[string]string{
+		"app":  "foo",
+		"tier": "backend",
+	}
+
+	// empty selector matches everything
+	f(&LabelSelector{}, labels, true)
+	f(&LabelSelector{}, nil, true)
+
+	f(&LabelSelector{MatchLabels: map[string]string{"app": "foo"}}, labels, true)
+	f(&LabelSelector{MatchLabels: map[string]string{"app": "bar"}}, labels, false)
+	f(&LabelSelector{MatchLabels: map[string]string{"app": "foo", "env": "prod"}}, labels, false)
+
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "In", Values: []string{"frontend", "backend"}}}}, labels, true)
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "NotIn", Values: []string{"backend"}}}}, labels, false)
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "env", Operator: "NotIn", Values: []string{"prod"}}}}, labels, true)
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "env", Operator: "Exists"}}}, labels, false)
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "env", Operator: "DoesNotExist"}}}, labels, true)
+	f(&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}}}, labels, false)
+}
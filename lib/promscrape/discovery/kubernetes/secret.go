@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func (s *Secret) key() string {
+	return s.Metadata.key()
+}
+
+func parseSecretList(r io.Reader) (map[string]object, ListMeta, error) {
+	var sl SecretList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&sl); err != nil {
+		return nil, sl.Metadata, fmt.Errorf("cannot unmarshal SecretList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, s := range sl.Items {
+		objectsByKey[s.key()] = s
+	}
+	return objectsByKey, sl.Metadata, nil
+}
+
+func parseSecret(data []byte) (object, error) {
+	var s Secret
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SecretList is k8s secret list.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.31/#secretlist-v1-core
+type SecretList struct {
+	Metadata ListMeta
+	Items    []*Secret
+}
+
+// Secret is k8s secret.
+//
+// Secrets are watched only for resolving references from ServiceMonitor and PodMonitor objects.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.31/#secret-v1-core
+type Secret struct {
+	Metadata ObjectMeta
+	Data     map[string][]byte
+}
+
+// getTargetLabels returns nil, since secrets cannot be scraped.
+func (s *Secret) getTargetLabels(_ *groupWatcher) []*promutils.Labels {
+	return nil
+}
@@ -0,0 +1,134 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func (sm *ServiceMonitor) key() string {
+	return sm.Metadata.key()
+}
+
+func parseServiceMonitorList(r io.Reader) (map[string]object, ListMeta, error) {
+	var sml ServiceMonitorList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&sml); err != nil {
+		return nil, sml.Metadata, fmt.Errorf("cannot unmarshal ServiceMonitorList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, sm := range sml.Items {
+		objectsByKey[sm.key()] = sm
+	}
+	return objectsByKey, sml.Metadata, nil
+}
+
+func parseServiceMonitor(data []byte) (object, error) {
+	var sm ServiceMonitor
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, err
+	}
+	return &sm, nil
+}
+
+// ServiceMonitorList is prometheus-operator ServiceMonitor list.
+type ServiceMonitorList struct {
+	Metadata ListMeta
+	Items    []*ServiceMonitor
+}
+
+// ServiceMonitor is prometheus-operator ServiceMonitor.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ServiceMonitor
+type ServiceMonitor struct {
+	Metadata ObjectMeta
+	Spec     ServiceMonitorSpec
+}
+
+// ServiceMonitorSpec is prometheus-operator ServiceMonitor spec.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ServiceMonitorSpec
+type ServiceMonitorSpec struct {
+	JobLabel          string
+	TargetLabels      []string
+	PodTargetLabels   []string
+	Endpoints         []MonitorEndpointSpec
+	Selector          LabelSelector
+	NamespaceSelector NamespaceSelector
+	SampleLimit       int
+}
+
+// getTargetLabels returns labels for endpoints of services selected by sm.
+//
+// The labels are generated in the same way as prometheus-operator does for `role: endpoints`.
+func (sm *ServiceMonitor) getTargetLabels(gw *groupWatcher) []*promutils.Labels {
+	var ms []*promutils.Labels
+	for i := range sm.Spec.Endpoints {
+		ep := &sm.Spec.Endpoints[i]
+		pcs, err := ep.parseRelabelings()
+		if err == nil {
+			_, err = ep.newMonitorEndpoint(gw, "", sm.Metadata.Namespace)
+		}
+		if err != nil {
+			logger.Errorf("skipping endpoint #%d at ServiceMonitor %s: %s", i+1, sm.key(), err)
+			continue
+		}
+		for _, uw := range gw.m {
+			if uw.role != "endpoints" {
+				continue
+			}
+			for _, o := range uw.objectsByKey {
+				eps := o.(*Endpoints)
+				if !sm.Spec.NamespaceSelector.matches(eps.Metadata.Namespace, sm.Metadata.Namespace) {
+					continue
+				}
+				so := gw.getObjectByRoleLocked("service", eps.Metadata.Namespace, eps.Metadata.Name)
+				if so == nil {
+					continue
+				}
+				svc := so.(*Service)
+				if !sm.Spec.Selector.matches(svc.Metadata.Labels) {
+					continue
+				}
+				for _, m := range eps.getTargetLabels(gw) {
+					if !ep.matchesPort(m, "__meta_kubernetes_endpoint_port_name") || isPodPhaseFinished(m.Get("__meta_kubernetes_pod_phase")) {
+						promutils.PutLabels(m)
+						continue
+					}
+					sm.appendCommonLabels(m, gw, ep, svc)
+					ms = appendMonitorTargetLabels(ms, m, pcs, "servicemonitor", sm.Metadata.Namespace, sm.Metadata.Name, i)
+				}
+			}
+		}
+	}
+	return ms
+}
+
+func (sm *ServiceMonitor) appendCommonLabels(m *promutils.Labels, gw *groupWatcher, ep *MonitorEndpointSpec, svc *Service) {
+	job := svc.Metadata.Name
+	if sm.Spec.JobLabel != "" {
+		if v, ok := getLabelValue(svc.Metadata.Labels, sm.Spec.JobLabel); ok {
+			job = v
+		}
+	}
+	m.Set("job", job)
+	m.Set("namespace", svc.Metadata.Namespace)
+	m.Set("service", svc.Metadata.Name)
+	pod := m.Get("__meta_kubernetes_pod_name")
+	if pod != "" {
+		m.Set("pod", pod)
+	}
+	if container := m.Get("__meta_kubernetes_pod_container_name"); container != "" {
+		m.Set("container", container)
+	}
+	addTargetLabelsFromObject(m, &svc.Metadata, sm.Spec.TargetLabels)
+	if len(sm.Spec.PodTargetLabels) > 0 && pod != "" {
+		if o := gw.getObjectByRoleLocked("pod", svc.Metadata.Namespace, pod); o != nil {
+			addTargetLabelsFromObject(m, &o.(*Pod).Metadata, sm.Spec.PodTargetLabels)
+		}
+	}
+	ep.appendScrapeLabels(m, sm.Spec.SampleLimit)
+}
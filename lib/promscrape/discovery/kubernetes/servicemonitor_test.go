@@ -0,0 +1,359 @@
+package kubernetes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestParseServiceMonitorListFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		r := bytes.NewBufferString(s)
+		objectsByKey, _, err := parseServiceMonitorList(r)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if len(objectsByKey) != 0 {
+			t.Fatalf("unexpected non-empty objectsByKey: %v", objectsByKey)
+		}
+	}
+	f(``)
+	f(`[1,23]`)
+	f(`{"items":[{"metadata":1}]}`)
+	f(`{"items":[{"spec":{"endpoints":[{"targetPort":[]}]}}]}`)
+}
+
+func TestParseServiceMonitorListSuccess(t *testing.T) {
+	data := `
+{
+  "apiVersion": "monitoring.coreos.com/v1",
+  "kind": "ServiceMonitorList",
+  "metadata": {
+    "resourceVersion": "1234"
+  },
+  "items": [
+    {
+      "metadata": {
+        "name": "app",
+        "namespace": "default"
+      },
+      "spec": {
+        "jobLabel": "app.kubernetes.io/name",
+        "selector": {
+          "matchLabels": {
+            "app": "foo"
+          }
+        },
+        "namespaceSelector": {
+          "any": true
+        },
+        "endpoints": [
+          {
+            "port": "http-metrics",
+            "interval": "15s",
+            "basicAuth": {
+              "username": {"name": "auth", "key": "user"},
+              "password": {"name": "auth", "key": "pass"}
+            },
+            "relabelings": [
+              {"action": "LabelDrop", "regex": "foo"}
+            ]
+          },
+          {
+            "targetPort": 8080
+          }
+        ]
+      }
+    }
+  ]
+}
+`
+	r := bytes.NewBufferString(data)
+	objectsByKey, meta, err := parseServiceMonitorList(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if meta.ResourceVersion != "1234" {
+		t.Fatalf("unexpected resource version; got %q; want %q", meta.ResourceVersion, "1234")
+	}
+	o := objectsByKey["default/app"]
+	if o == nil {
+		t.Fatalf("cannot find default/app in %v", objectsByKey)
+	}
+	sm := o.(*ServiceMonitor)
+	if sm.Spec.JobLabel != "app.kubernetes.io/name" || !sm.Spec.NamespaceSelector.Any || sm.Spec.Selector.MatchLabels["app"] != "foo" {
+		t.Fatalf("unexpected spec: %+v", sm.Spec)
+	}
+	if len(sm.Spec.Endpoints) != 2 {
+		t.Fatalf("unexpected number of endpoints; got %d; want 2", len(sm.Spec.Endpoints))
+	}
+	ep := sm.Spec.Endpoints[0]
+	if ep.Port != "http-metrics" || ep.Interval != "15s" || ep.BasicAuth.Password.Key != "pass" || ep.Relabelings[0].Action != "LabelDrop" {
+		t.Fatalf("unexpected endpoint #1: %+v", ep)
+	}
+	ep = sm.Spec.Endpoints[1]
+	if ep.TargetPort == nil || ep.TargetPort.IsString || ep.TargetPort.IntVal != 8080 {
+		t.Fatalf("unexpected endpoint #2: %+v", ep)
+	}
+}
+
+func TestServiceMonitorGetTargetLabels(t *testing.T) {
+	eps := &Endpoints{
+		Metadata: ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+		},
+		Subsets: []EndpointSubset{
+			{
+				Addresses: []EndpointAddress{
+					{
+						IP: "10.0.0.1",
+						TargetRef: ObjectReference{
+							Kind:      "Pod",
+							Name:      "app-pod",
+							Namespace: "default",
+						},
+					},
+				},
+				Ports: []EndpointPort{
+					{
+						Name:     "http-metrics",
+						Port:     8080,
+						Protocol: "TCP",
+					},
+					{
+						Name:     "grpc",
+						Port:     9090,
+						Protocol: "TCP",
+					},
+				},
+			},
+		},
+	}
+	svc := &Service{
+		Metadata: ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"app":                    "foo",
+				"app.kubernetes.io/name": "foo-app",
+				"team":                   "infra",
+			}),
+		},
+	}
+	otherSvc := &Service{
+		Metadata: ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"app": "bar",
+			}),
+		},
+	}
+	otherEps := &Endpoints{
+		Metadata: ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+		},
+		Subsets: eps.Subsets,
+	}
+	pod := &Pod{
+		Metadata: ObjectMeta{
+			Name:      "app-pod",
+			Namespace: "default",
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"version": "v1",
+			}),
+		},
+		Status: PodStatus{
+			Phase: "Running",
+			PodIP: "10.0.0.1",
+		},
+	}
+	secret := &Secret{
+		Metadata: ObjectMeta{
+			Name:      "auth",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"user": []byte("admin"),
+			"pass": []byte("secret-pass"),
+		},
+	}
+	sm := &ServiceMonitor{
+		Metadata: ObjectMeta{
+			Name:      "app-monitor",
+			Namespace: "default",
+		},
+		Spec: ServiceMonitorSpec{
+			JobLabel:        "app.kubernetes.io/name",
+			TargetLabels:    []string{"team"},
+			PodTargetLabels: []string{"version"},
+			Selector: LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "foo",
+				},
+			},
+			Endpoints: []MonitorEndpointSpec{
+				{
+					Port:     "http-metrics",
+					Path:     "/custom-metrics",
+					Interval: "15s",
+					BasicAuth: &MonitorBasicAuth{
+						Username: SecretKeySelector{
+							Name: "auth",
+							Key:  "user",
+						},
+						Password: SecretKeySelector{
+							Name: "auth",
+							Key:  "pass",
+						},
+					},
+					Relabelings: []MonitorRelabelConfig{
+						{
+							Action:       "Replace",
+							SourceLabels: []string{"__meta_kubernetes_pod_phase"},
+							TargetLabel:  "phase",
+						},
+					},
+				},
+			},
+		},
+	}
+	var gw groupWatcher
+	gw.m = map[string]*urlWatcher{
+		"endpoints": {
+			role: "endpoints",
+			objectsByKey: map[string]object{
+				"default/app":   eps,
+				"default/other": otherEps,
+			},
+		},
+		"service": {
+			role: "service",
+			objectsByKey: map[string]object{
+				"default/app":   svc,
+				"default/other": otherSvc,
+			},
+		},
+		"pod": {
+			role: "pod",
+			objectsByKey: map[string]object{
+				"default/app-pod": pod,
+			},
+		},
+		"secret": {
+			role: "secret",
+			objectsByKey: map[string]object{
+				"default/auth": secret,
+			},
+		},
+		"servicemonitor": {
+			role: "servicemonitor",
+			objectsByKey: map[string]object{
+				"default/app-monitor": sm,
+			},
+		},
+	}
+
+	labelss := sm.getTargetLabels(&gw)
+	for _, labels := range labelss {
+		labels.Sort()
+	}
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__": "10.0.0.1:8080",
+			"__meta_kubernetes_endpoint_address_target_kind":                "Pod",
+			"__meta_kubernetes_endpoint_address_target_name":                "app-pod",
+			"__meta_kubernetes_endpoint_port_name":                          "http-metrics",
+			"__meta_kubernetes_endpoint_port_protocol":                      "TCP",
+			"__meta_kubernetes_endpoint_ready":                              "true",
+			"__meta_kubernetes_endpoints_name":                              "app",
+			"__meta_kubernetes_namespace":                                   "default",
+			"__meta_kubernetes_pod_ip":                                      "10.0.0.1",
+			"__meta_kubernetes_pod_label_version":                           "v1",
+			"__meta_kubernetes_pod_labelpresent_version":                    "true",
+			"__meta_kubernetes_pod_name":                                    "app-pod",
+			"__meta_kubernetes_pod_phase":                                   "Running",
+			"__meta_kubernetes_pod_ready":                                   "unknown",
+			"__meta_kubernetes_service_label_app":                           "foo",
+			"__meta_kubernetes_service_label_app_kubernetes_io_name":        "foo-app",
+			"__meta_kubernetes_service_label_team":                          "infra",
+			"__meta_kubernetes_service_labelpresent_app":                    "true",
+			"__meta_kubernetes_service_labelpresent_app_kubernetes_io_name": "true",
+			"__meta_kubernetes_service_labelpresent_team":                   "true",
+			"__meta_kubernetes_service_name":                                "app",
+			"__meta_kubernetes_servicemonitor_endpoint":                     "0",
+			"__meta_kubernetes_servicemonitor_name":                         "app-monitor",
+			"__meta_kubernetes_servicemonitor_namespace":                    "default",
+			"__metrics_path__":                                              "/custom-metrics",
+			"__scheme__":                                                    "http",
+			"__scrape_interval__":                                           "15s",
+			"endpoint":                                                      "http-metrics",
+			"job":                                                           "foo-app",
+			"namespace":                                                     "default",
+			"phase":                                                         "Running",
+			"pod":                                                           "app-pod",
+			"service":                                                       "app",
+			"team":                                                          "infra",
+			"version":                                                       "v1",
+		}),
+	}
+	if !areEqualLabelss(labelss, expectedLabelss) {
+		t.Fatalf("unexpected labels:\ngot\n%v\nwant\n%v", labelss, expectedLabelss)
+	}
+
+	mes := make(map[string]*MonitorEndpoint)
+	me := gw.getMonitorEndpointLocked("servicemonitor", labelss[0], mes)
+	if me == nil {
+		t.Fatalf("cannot obtain MonitorEndpoint")
+	}
+	if meCached := gw.getMonitorEndpointLocked("servicemonitor", labelss[0], mes); meCached != me {
+		t.Fatalf("MonitorEndpoint must be built once per monitor endpoint")
+	}
+	if me.Key != "servicemonitor/default/app-monitor/0" {
+		t.Fatalf("unexpected key; got %q; want %q", me.Key, "servicemonitor/default/app-monitor/0")
+	}
+	ba := me.HTTPClientConfig.BasicAuth
+	if ba == nil || ba.Username != "admin" || ba.Password.String() != "secret-pass" {
+		t.Fatalf("unexpected basic auth config: %+v", ba)
+	}
+
+	// Missing secret must result in skipped endpoint
+	delete(gw.m["secret"].objectsByKey, "default/auth")
+	labelss = sm.getTargetLabels(&gw)
+	if len(labelss) != 0 {
+		t.Fatalf("expecting zero targets when the secret is missing; got %d targets", len(labelss))
+	}
+}
+
+func TestMonitorEndpointTLSFiles(t *testing.T) {
+	f := func(allowTLSFiles bool, tc *MonitorTLSConfig, resultExpected bool) {
+		t.Helper()
+		origValue := *monitorAllowTLSFiles
+		defer func() {
+			*monitorAllowTLSFiles = origValue
+		}()
+		*monitorAllowTLSFiles = allowTLSFiles
+		ep := &MonitorEndpointSpec{
+			TLSConfig: tc,
+		}
+		_, err := ep.newMonitorEndpoint(&groupWatcher{}, "servicemonitor/default/foo/0", "default")
+		if result := err == nil; result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v; error: %v", result, resultExpected, err)
+		}
+	}
+
+	// tlsConfig without local files
+	f(false, &MonitorTLSConfig{ServerName: "foo", InsecureSkipVerify: true}, true)
+
+	// local files are rejected by default
+	f(false, &MonitorTLSConfig{CAFile: "/etc/ssl/ca.crt"}, false)
+	f(false, &MonitorTLSConfig{CertFile: "/etc/ssl/tls.crt"}, false)
+	f(false, &MonitorTLSConfig{KeyFile: "/etc/ssl/tls.key"}, false)
+
+	// local files are allowed via -promscrape.kubernetes.monitorAllowTLSFiles
+	f(true, &MonitorTLSConfig{CAFile: "/etc/ssl/ca.crt", CertFile: "/etc/ssl/tls.crt", KeyFile: "/etc/ssl/tls.key"}, true)
+}
@@ -0,0 +1,67 @@
+package zookeeper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// apiConfig contains config for ZooKeeper discovery.
+type apiConfig struct {
+	tw *treeWatcher
+}
+
+func (ac *apiConfig) mustStop() {
+	ac.tw.mustStop()
+}
+
+var configMap = discoveryutils.NewConfigMap()
+
+// getAPIConfig returns apiConfig for the given sdc.
+//
+// sdc must be a pointer to ServersetSDConfig or NerveSDConfig.
+func getAPIConfig(sdc any, servers, paths []string, timeout *promutils.Duration) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(servers, paths, timeout) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func mustStopAPIConfig(sdc any) {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		// v can be nil if GetLabels wasn't called yet.
+		cfg := v.(*apiConfig)
+		cfg.mustStop()
+	}
+}
+
+func newAPIConfig(servers, paths []string, timeout *promutils.Duration) (*apiConfig, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("`servers` cannot be empty")
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("`paths` cannot be empty")
+	}
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("path %q must start with '/'", path)
+		}
+	}
+	d := timeout.Duration()
+	if d <= 0 {
+		d = 10 * time.Second
+	}
+	tw, err := newTreeWatcher(servers, paths, d)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &apiConfig{
+		tw: tw,
+	}
+	return cfg, nil
+}
@@ -0,0 +1,460 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// The subset of ZooKeeper client protocol needed for discovery.
+//
+// See https://github.com/apache/zookeeper/blob/master/zookeeper-jute/src/main/resources/zookeeper.jute
+const (
+	opExists      = 3
+	opGetData     = 4
+	opGetChildren = 8
+	opPing        = 11
+	opClose       = -11
+
+	xidWatchEvent = -1
+	xidPing       = -2
+
+	errNoNode = -101
+
+	// maxPacketSize limits the size of packets received from ZooKeeper in order to protect from memory exhaustion.
+	maxPacketSize = 16 * 1024 * 1024
+)
+
+// errNodeNotFound is returned when the requested node doesn't exist.
+var errNodeNotFound = errors.New("node doesn't exist")
+
+// conn is a connection to ZooKeeper server.
+//
+// Requests must be sent sequentially from a single goroutine, while pings are sent in background.
+type conn struct {
+	c       net.Conn
+	timeout time.Duration
+
+	// writeLock serializes writes to c
+	writeLock sync.Mutex
+	xid       int32
+
+	// responseCh receives responses to the sent requests in the order they were sent.
+	responseCh chan *response
+
+	// eventCh receives a notification when a watch is triggered.
+	// The triggered watch events can be obtained via getEvents.
+	eventCh chan struct{}
+
+	eventsLock sync.Mutex
+	events     []watchEvent
+
+	// doneCh is closed when the connection is broken or closed.
+	doneCh  chan struct{}
+	errLock sync.Mutex
+	err     error
+
+	wg sync.WaitGroup
+}
+
+// watchEvent is a notification from ZooKeeper about the change of the node at path.
+type watchEvent struct {
+	typ  int32
+	path string
+}
+
+// See https://github.com/apache/zookeeper/blob/master/zookeeper-server/src/main/java/org/apache/zookeeper/Watcher.java
+const (
+	eventNodeCreated         = 1
+	eventNodeDeleted         = 2
+	eventNodeDataChanged     = 3
+	eventNodeChildrenChanged = 4
+)
+
+type response struct {
+	xid  int32
+	code int32
+	data []byte
+}
+
+// dialConn establishes a new session with ZooKeeper server at addr.
+func dialConn(addr string, timeout time.Duration) (*conn, error) {
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	zc := &conn{
+		c:          c,
+		timeout:    timeout,
+		responseCh: make(chan *response, 1),
+		eventCh:    make(chan struct{}, 1),
+		doneCh:     make(chan struct{}),
+	}
+	if err := zc.handshake(); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("cannot establish session with %q: %w", addr, err)
+	}
+	zc.wg.Add(2)
+	go func() {
+		defer zc.wg.Done()
+		zc.readLoop()
+	}()
+	go func() {
+		defer zc.wg.Done()
+		zc.pingLoop()
+	}()
+	return zc, nil
+}
+
+func (zc *conn) handshake() error {
+	// ConnectRequest: protocolVersion, lastZxidSeen, timeOut, sessionId, passwd
+	var e encoder
+	e.int32(0)
+	e.int64(0)
+	e.int32(int32(zc.timeout.Milliseconds()))
+	e.int64(0)
+	e.bytes(make([]byte, 16))
+	deadline := time.Now().Add(zc.timeout)
+	if err := zc.c.SetDeadline(deadline); err != nil {
+		return err
+	}
+	if err := writePacket(zc.c, e.b); err != nil {
+		return fmt.Errorf("cannot send connect request: %w", err)
+	}
+	data, err := readPacket(zc.c)
+	if err != nil {
+		return fmt.Errorf("cannot read connect response: %w", err)
+	}
+	// ConnectResponse: protocolVersion, timeOut, sessionId, passwd
+	d := decoder{b: data}
+	d.int32()
+	sessionTimeout := d.int32()
+	d.int64()
+	if d.err != nil {
+		return fmt.Errorf("cannot parse connect response: %w", d.err)
+	}
+	if sessionTimeout <= 0 {
+		return fmt.Errorf("the server rejected the session")
+	}
+	// Use the negotiated session timeout for pings.
+	zc.timeout = time.Duration(sessionTimeout) * time.Millisecond
+	return zc.c.SetDeadline(time.Time{})
+}
+
+// close closes zc.
+func (zc *conn) close() {
+	var e encoder
+	e.int32(0)
+	e.int32(opClose)
+	zc.writeLock.Lock()
+	_ = zc.c.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = writePacket(zc.c, e.b)
+	zc.writeLock.Unlock()
+	zc.setErr(errors.New("connection closed"))
+	zc.wg.Wait()
+}
+
+func (zc *conn) setErr(err error) {
+	zc.errLock.Lock()
+	if zc.err == nil {
+		zc.err = err
+		close(zc.doneCh)
+		_ = zc.c.Close()
+	}
+	zc.errLock.Unlock()
+}
+
+func (zc *conn) getErr() error {
+	zc.errLock.Lock()
+	defer zc.errLock.Unlock()
+	return zc.err
+}
+
+func (zc *conn) readLoop() {
+	for {
+		// The server must send at least ping responses during the session timeout.
+		if err := zc.c.SetReadDeadline(time.Now().Add(zc.timeout)); err != nil {
+			zc.setErr(err)
+			return
+		}
+		data, err := readPacket(zc.c)
+		if err != nil {
+			zc.setErr(fmt.Errorf("cannot read response: %w", err))
+			return
+		}
+		// ReplyHeader: xid, zxid, err
+		d := decoder{b: data}
+		xid := d.int32()
+		d.int64()
+		code := d.int32()
+		if d.err != nil {
+			zc.setErr(fmt.Errorf("cannot parse response header: %w", d.err))
+			return
+		}
+		switch xid {
+		case xidPing:
+			continue
+		case xidWatchEvent:
+			// WatcherEvent: type, state, path
+			typ := d.int32()
+			d.int32()
+			p := d.string()
+			if d.err != nil {
+				zc.setErr(fmt.Errorf("cannot parse watch event: %w", d.err))
+				return
+			}
+			zc.eventsLock.Lock()
+			zc.events = append(zc.events, watchEvent{typ: typ, path: p})
+			zc.eventsLock.Unlock()
+			select {
+			case zc.eventCh <- struct{}{}:
+			default:
+			}
+			continue
+		}
+		select {
+		case zc.responseCh <- &response{xid: xid, code: code, data: d.b}:
+		case <-zc.doneCh:
+			return
+		}
+	}
+}
+
+// getEvents returns and resets the watch events received since the previous call.
+func (zc *conn) getEvents() []watchEvent {
+	zc.eventsLock.Lock()
+	defer zc.eventsLock.Unlock()
+	events := zc.events
+	zc.events = nil
+	return events
+}
+
+func (zc *conn) pingLoop() {
+	t := time.NewTicker(zc.timeout / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-zc.doneCh:
+			return
+		case <-t.C:
+		}
+		var e encoder
+		e.int32(xidPing)
+		e.int32(opPing)
+		if err := zc.write(e.b); err != nil {
+			zc.setErr(fmt.Errorf("cannot send ping: %w", err))
+			return
+		}
+	}
+}
+
+func (zc *conn) write(b []byte) error {
+	zc.writeLock.Lock()
+	defer zc.writeLock.Unlock()
+	if err := zc.c.SetWriteDeadline(time.Now().Add(zc.timeout)); err != nil {
+		return err
+	}
+	return writePacket(zc.c, b)
+}
+
+// do sends request with the given op and path and waits for the response.
+func (zc *conn) do(op int32, path string, watch bool) ([]byte, error) {
+	zc.writeLock.Lock()
+	zc.xid++
+	if zc.xid <= 0 {
+		zc.xid = 1
+	}
+	xid := zc.xid
+	zc.writeLock.Unlock()
+
+	var e encoder
+	e.int32(xid)
+	e.int32(op)
+	e.string(path)
+	e.bool(watch)
+	if err := zc.write(e.b); err != nil {
+		zc.setErr(fmt.Errorf("cannot send request: %w", err))
+		return nil, zc.getErr()
+	}
+	t := time.NewTimer(zc.timeout)
+	defer t.Stop()
+	select {
+	case resp := <-zc.responseCh:
+		if resp.xid != xid {
+			err := fmt.Errorf("unexpected xid in response; got %d; want %d", resp.xid, xid)
+			zc.setErr(err)
+			return nil, err
+		}
+		if resp.code == errNoNode {
+			return nil, errNodeNotFound
+		}
+		if resp.code != 0 {
+			return nil, fmt.Errorf("unexpected error code %d for %q", resp.code, path)
+		}
+		return resp.data, nil
+	case <-zc.doneCh:
+		return nil, zc.getErr()
+	case <-t.C:
+		err := fmt.Errorf("timeout when waiting for response for %q", path)
+		zc.setErr(err)
+		return nil, err
+	}
+}
+
+// getChildren returns children names for the node at the given path and sets a watch for children changes.
+func (zc *conn) getChildren(path string) ([]string, error) {
+	data, err := zc.do(opGetChildren, path, true)
+	if err != nil {
+		return nil, err
+	}
+	d := decoder{b: data}
+	n := d.int32()
+	var children []string
+	for i := int32(0); i < n && d.err == nil; i++ {
+		children = append(children, d.string())
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot parse children for %q: %w", path, d.err)
+	}
+	return children, nil
+}
+
+// getData returns data for the node at the given path and sets a watch for data changes.
+func (zc *conn) getData(path string) ([]byte, error) {
+	data, err := zc.do(opGetData, path, true)
+	if err != nil {
+		return nil, err
+	}
+	d := decoder{b: data}
+	b := d.bytes()
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot parse data for %q: %w", path, d.err)
+	}
+	return b, nil
+}
+
+// watchExists sets a watch for the creation of the node at the given path.
+func (zc *conn) watchExists(path string) error {
+	_, err := zc.do(opExists, path, true)
+	if err != nil && !errors.Is(err, errNodeNotFound) {
+		return err
+	}
+	return nil
+}
+
+func writePacket(w io.Writer, b []byte) error {
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > maxPacketSize {
+		return nil, fmt.Errorf("too big packet size: %d bytes; mustn't exceed %d bytes", n, maxPacketSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// encoder encodes data in jute format.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int32(v int32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, uint64(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.b = append(e.b, 1)
+	} else {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *encoder) bytes(v []byte) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) string(s string) {
+	e.int32(int32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// decoder decodes data in jute format.
+//
+// The first error is stored in err, and the subsequent calls return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = fmt.Errorf("unexpected end of data; want %d bytes; got %d bytes", n, len(d.b))
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) int32() int32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) bool() bool {
+	b := d.next(1)
+	return b != nil && b[0] != 0
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
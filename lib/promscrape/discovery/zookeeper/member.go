@@ -0,0 +1,103 @@
+package zookeeper
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// serversetMember represents Finagle serverset member.
+//
+// See https://github.com/twitter/finagle/blob/develop/finagle-serversets/src/main/thrift/com/twitter/thrift/endpoint.thrift
+type serversetMember struct {
+	ServiceEndpoint     serversetEndpoint            `json:"serviceEndpoint"`
+	AdditionalEndpoints map[string]serversetEndpoint `json:"additionalEndpoints"`
+	Status              string                       `json:"status"`
+	Shard               int                          `json:"shard"`
+}
+
+type serversetEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// nerveMember represents Nerve member.
+//
+// See https://github.com/airbnb/nerve
+type nerveMember struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	Name string `json:"name"`
+}
+
+func getServersetLabels(nodes map[string][]byte) []*promutils.Labels {
+	var ms []*promutils.Labels
+	for _, p := range getSortedPaths(nodes) {
+		var sm serversetMember
+		if err := json.Unmarshal(nodes[p], &sm); err != nil {
+			// Skip non-member nodes such as parent nodes with arbitrary data.
+			continue
+		}
+		if sm.ServiceEndpoint.Host == "" || sm.ServiceEndpoint.Port == 0 {
+			// Skip nodes without service endpoint such as `{}`, since they cannot be scraped.
+			continue
+		}
+		ms = append(ms, sm.appendTargetLabels(p))
+	}
+	return ms
+}
+
+func (sm *serversetMember) appendTargetLabels(p string) *promutils.Labels {
+	m := promutils.NewLabels(6 + 2*len(sm.AdditionalEndpoints))
+	m.Add("__address__", discoveryutils.JoinHostPort(sm.ServiceEndpoint.Host, sm.ServiceEndpoint.Port))
+	m.Add("__meta_serverset_path", p)
+	m.Add("__meta_serverset_endpoint_host", sm.ServiceEndpoint.Host)
+	m.Add("__meta_serverset_endpoint_port", strconv.Itoa(sm.ServiceEndpoint.Port))
+	for name, ep := range sm.AdditionalEndpoints {
+		name = discoveryutils.SanitizeLabelName(name)
+		m.Add("__meta_serverset_endpoint_host_"+name, ep.Host)
+		m.Add("__meta_serverset_endpoint_port_"+name, strconv.Itoa(ep.Port))
+	}
+	m.Add("__meta_serverset_status", sm.Status)
+	m.Add("__meta_serverset_shard", strconv.Itoa(sm.Shard))
+	return m
+}
+
+func getNerveLabels(nodes map[string][]byte) []*promutils.Labels {
+	var ms []*promutils.Labels
+	for _, p := range getSortedPaths(nodes) {
+		var nm nerveMember
+		if err := json.Unmarshal(nodes[p], &nm); err != nil {
+			// Skip non-member nodes such as parent nodes with arbitrary data.
+			continue
+		}
+		if nm.Host == "" || nm.Port == 0 {
+			// Skip nodes without host or port such as `{}`, since they cannot be scraped.
+			continue
+		}
+		ms = append(ms, nm.appendTargetLabels(p))
+	}
+	return ms
+}
+
+func (nm *nerveMember) appendTargetLabels(p string) *promutils.Labels {
+	m := promutils.NewLabels(5)
+	m.Add("__address__", discoveryutils.JoinHostPort(nm.Host, nm.Port))
+	m.Add("__meta_nerve_path", p)
+	m.Add("__meta_nerve_endpoint_host", nm.Host)
+	m.Add("__meta_nerve_endpoint_port", strconv.Itoa(nm.Port))
+	m.Add("__meta_nerve_endpoint_name", nm.Name)
+	return m
+}
+
+func getSortedPaths(nodes map[string][]byte) []string {
+	paths := make([]string, 0, len(nodes))
+	for p := range nodes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
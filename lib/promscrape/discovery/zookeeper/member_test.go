@@ -0,0 +1,60 @@
+package zookeeper
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestGetServersetLabels(t *testing.T) {
+	nodes := map[string][]byte{
+		"/services/foo/member_0000000001": []byte(`{"serviceEndpoint":{"host":"10.0.0.2","port":8080},"additionalEndpoints":{"admin-http":{"host":"10.0.0.2","port":9990}},"status":"ALIVE","shard":1}`),
+		"/services/foo/member_0000000000": []byte(`{"serviceEndpoint":{"host":"10.0.0.1","port":8080},"status":"ALIVE"}`),
+		"/services/foo":                   []byte(`not a member`),
+		"/services/foo/member_0000000002": []byte(`{}`),
+		"/services/foo/member_0000000003": []byte(`{"serviceEndpoint":{"host":"10.0.0.3"},"status":"ALIVE"}`),
+	}
+	labelss := getServersetLabels(nodes)
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                    "10.0.0.1:8080",
+			"__meta_serverset_path":          "/services/foo/member_0000000000",
+			"__meta_serverset_endpoint_host": "10.0.0.1",
+			"__meta_serverset_endpoint_port": "8080",
+			"__meta_serverset_status":        "ALIVE",
+			"__meta_serverset_shard":         "0",
+		}),
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                               "10.0.0.2:8080",
+			"__meta_serverset_path":                     "/services/foo/member_0000000001",
+			"__meta_serverset_endpoint_host":            "10.0.0.2",
+			"__meta_serverset_endpoint_port":            "8080",
+			"__meta_serverset_endpoint_host_admin_http": "10.0.0.2",
+			"__meta_serverset_endpoint_port_admin_http": "9990",
+			"__meta_serverset_status":                   "ALIVE",
+			"__meta_serverset_shard":                    "1",
+		}),
+	}
+	discoveryutils.TestEqualLabelss(t, labelss, expectedLabelss)
+}
+
+func TestGetNerveLabels(t *testing.T) {
+	nodes := map[string][]byte{
+		"/nerve/services/bar/services/i-1": []byte(`{"host":"10.0.1.1","port":3000,"name":"bar"}`),
+		"/nerve/services/bar/services/i-2": []byte(`{"host":"10.0.1.2"`),
+		"/nerve/services/bar/services/i-3": []byte(`{}`),
+		"/nerve/services/bar/services/i-4": []byte(`{"port":3000,"name":"bar"}`),
+	}
+	labelss := getNerveLabels(nodes)
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                "10.0.1.1:3000",
+			"__meta_nerve_path":          "/nerve/services/bar/services/i-1",
+			"__meta_nerve_endpoint_host": "10.0.1.1",
+			"__meta_nerve_endpoint_port": "3000",
+			"__meta_nerve_endpoint_name": "bar",
+		}),
+	}
+	discoveryutils.TestEqualLabelss(t, labelss, expectedLabelss)
+}
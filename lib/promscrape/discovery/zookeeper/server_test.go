@@ -0,0 +1,253 @@
+package zookeeper
+
+import (
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testServer is a minimal in-memory ZooKeeper server for tests.
+type testServer struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	nodes map[string][]byte
+	conns map[*testConn]struct{}
+
+	// reads is the number of getData and getChildren requests
+	reads int
+
+	wg sync.WaitGroup
+}
+
+type testConn struct {
+	c net.Conn
+
+	writeLock sync.Mutex
+
+	// watches are protected by testServer.mu
+	dataWatches     map[string]struct{}
+	childrenWatches map[string]struct{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start listener: %s", err)
+	}
+	ts := &testServer{
+		ln: ln,
+		nodes: map[string][]byte{
+			"/": nil,
+		},
+		conns: make(map[*testConn]struct{}),
+	}
+	ts.wg.Add(1)
+	go func() {
+		defer ts.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tc := &testConn{
+				c:               c,
+				dataWatches:     make(map[string]struct{}),
+				childrenWatches: make(map[string]struct{}),
+			}
+			ts.mu.Lock()
+			ts.conns[tc] = struct{}{}
+			ts.mu.Unlock()
+			ts.wg.Add(1)
+			go func() {
+				defer ts.wg.Done()
+				ts.serveConn(tc)
+				ts.mu.Lock()
+				delete(ts.conns, tc)
+				ts.mu.Unlock()
+				_ = c.Close()
+			}()
+		}
+	}()
+	return ts
+}
+
+func (ts *testServer) getReads() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.reads
+}
+
+func (ts *testServer) addr() string {
+	return ts.ln.Addr().String()
+}
+
+func (ts *testServer) close() {
+	_ = ts.ln.Close()
+	ts.closeConns()
+	ts.wg.Wait()
+}
+
+// closeConns breaks all the client connections.
+func (ts *testServer) closeConns() {
+	ts.mu.Lock()
+	for tc := range ts.conns {
+		_ = tc.c.Close()
+	}
+	ts.mu.Unlock()
+}
+
+// set creates or updates the node at p together with missing parent nodes.
+func (ts *testServer) set(p string, data []byte) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, ok := ts.nodes[p]; ok {
+		ts.nodes[p] = data
+		ts.fireLocked(p, eventNodeDataChanged)
+		return
+	}
+	parent := path.Dir(p)
+	if _, ok := ts.nodes[parent]; !ok {
+		ts.mu.Unlock()
+		ts.set(parent, nil)
+		ts.mu.Lock()
+	}
+	ts.nodes[p] = data
+	ts.fireLocked(p, eventNodeCreated)
+	ts.fireLocked(parent, eventNodeChildrenChanged)
+}
+
+// delete deletes the node at p.
+func (ts *testServer) delete(p string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	delete(ts.nodes, p)
+	ts.fireLocked(p, eventNodeDeleted)
+	ts.fireLocked(path.Dir(p), eventNodeChildrenChanged)
+}
+
+func (ts *testServer) fireLocked(p string, eventType int32) {
+	for tc := range ts.conns {
+		watches := tc.dataWatches
+		if eventType == eventNodeChildrenChanged {
+			watches = tc.childrenWatches
+		}
+		if _, ok := watches[p]; !ok {
+			continue
+		}
+		delete(watches, p)
+		var e encoder
+		e.int32(xidWatchEvent)
+		e.int64(-1)
+		e.int32(0)
+		e.int32(eventType)
+		e.int32(3)
+		e.string(p)
+		tc.write(e.b)
+	}
+}
+
+func (tc *testConn) write(b []byte) {
+	tc.writeLock.Lock()
+	_ = writePacket(tc.c, b)
+	tc.writeLock.Unlock()
+}
+
+func (ts *testServer) serveConn(tc *testConn) {
+	data, err := readPacket(tc.c)
+	if err != nil {
+		return
+	}
+	d := decoder{b: data}
+	d.int32()
+	d.int64()
+	timeout := d.int32()
+	var e encoder
+	e.int32(0)
+	e.int32(timeout)
+	e.int64(1)
+	e.bytes(make([]byte, 16))
+	tc.write(e.b)
+
+	for {
+		data, err := readPacket(tc.c)
+		if err != nil {
+			return
+		}
+		d := decoder{b: data}
+		xid := d.int32()
+		op := d.int32()
+		if op == opClose {
+			return
+		}
+		var resp encoder
+		code := int32(0)
+		if op != opPing {
+			p := d.string()
+			watch := d.bool()
+			code = ts.handleRequest(tc, op, p, watch, &resp)
+		}
+		var e encoder
+		e.int32(xid)
+		e.int64(1)
+		e.int32(code)
+		if code == 0 {
+			e.b = append(e.b, resp.b...)
+		}
+		tc.write(e.b)
+	}
+}
+
+func (ts *testServer) handleRequest(tc *testConn, op int32, p string, watch bool, resp *encoder) int32 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	data, ok := ts.nodes[p]
+	if op == opExists {
+		if watch {
+			tc.dataWatches[p] = struct{}{}
+		}
+		if !ok {
+			return errNoNode
+		}
+		resp.b = append(resp.b, make([]byte, 68)...)
+		return 0
+	}
+	if !ok {
+		return errNoNode
+	}
+	if op == opGetData || op == opGetChildren {
+		ts.reads++
+	}
+	switch op {
+	case opGetData:
+		if watch {
+			tc.dataWatches[p] = struct{}{}
+		}
+		resp.bytes(data)
+		resp.b = append(resp.b, make([]byte, 68)...)
+	case opGetChildren:
+		if watch {
+			tc.childrenWatches[p] = struct{}{}
+		}
+		prefix := strings.TrimSuffix(p, "/") + "/"
+		var children []string
+		for np := range ts.nodes {
+			if np != p && strings.HasPrefix(np, prefix) && !strings.Contains(np[len(prefix):], "/") {
+				children = append(children, np[len(prefix):])
+			}
+		}
+		resp.int32(int32(len(children)))
+		for _, child := range children {
+			resp.string(child)
+		}
+	default:
+		return -6
+	}
+	return 0
+}
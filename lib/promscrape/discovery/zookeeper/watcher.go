@@ -0,0 +1,286 @@
+package zookeeper
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// treeWatcher maintains an up-to-date snapshot of ZooKeeper nodes under the configured paths.
+//
+// Only the changed nodes are re-read every time ZooKeeper notifies about changes via watches.
+type treeWatcher struct {
+	servers []string
+	paths   []string
+	timeout time.Duration
+
+	mu    sync.Mutex
+	nodes map[string][]byte
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+var (
+	zookeeperRefreshesTotal       = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_refreshes_total`)
+	zookeeperRefreshErrorsTotal   = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_refresh_errors_total`)
+	zookeeperReconnectsTotal      = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_reconnects_total`)
+	zookeeperReconnectErrorsTotal = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_reconnect_errors_total`)
+)
+
+// newTreeWatcher returns treeWatcher for the given paths at the given ZooKeeper servers.
+//
+// It returns an error if the initial snapshot cannot be obtained.
+func newTreeWatcher(servers, paths []string, timeout time.Duration) (*treeWatcher, error) {
+	tw := &treeWatcher{
+		servers: servers,
+		paths:   paths,
+		timeout: timeout,
+		stopCh:  make(chan struct{}),
+	}
+	zc, err := tw.connect()
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.refresh(zc); err != nil {
+		zc.close()
+		return nil, err
+	}
+	tw.wg.Add(1)
+	go func() {
+		defer tw.wg.Done()
+		tw.watch(zc)
+	}()
+	logger.Infof("started ZooKeeper watcher for paths %q at %q", tw.paths, tw.servers)
+	return tw, nil
+}
+
+func (tw *treeWatcher) mustStop() {
+	close(tw.stopCh)
+	tw.wg.Wait()
+	logger.Infof("stopped ZooKeeper watcher for paths %q at %q", tw.paths, tw.servers)
+}
+
+// getNodes returns a snapshot of nodes data keyed by node path.
+//
+// The returned map mustn't be modified.
+func (tw *treeWatcher) getNodes() map[string][]byte {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.nodes
+}
+
+// connect connects to the first available server in random order.
+func (tw *treeWatcher) connect() (*conn, error) {
+	var errs []error
+	for _, i := range rand.Perm(len(tw.servers)) {
+		zc, err := dialConn(tw.servers[i], tw.timeout)
+		if err == nil {
+			return zc, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("cannot connect to ZooKeeper servers %q: %w", tw.servers, errors.Join(errs...))
+}
+
+// watch updates the snapshot on every watch event and re-connects to ZooKeeper on errors until tw.stopCh is closed.
+func (tw *treeWatcher) watch(zc *conn) {
+	backoff := time.Second
+	for {
+		if zc == nil {
+			select {
+			case <-tw.stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			zookeeperReconnectsTotal.Inc()
+			var err error
+			zc, err = tw.connect()
+			if err == nil {
+				err = tw.refresh(zc)
+				if err != nil {
+					zc.close()
+					zc = nil
+				}
+			}
+			if err != nil {
+				zookeeperReconnectErrorsTotal.Inc()
+				logger.Errorf("cannot re-establish ZooKeeper watcher for paths %q: %s; retrying in %s", tw.paths, err, backoff)
+				if backoff < time.Minute {
+					backoff *= 2
+				}
+				continue
+			}
+			backoff = time.Second
+		}
+		select {
+		case <-tw.stopCh:
+			zc.close()
+			return
+		case <-zc.eventCh:
+			if err := tw.handleEvents(zc, zc.getEvents()); err != nil {
+				logger.Errorf("cannot refresh ZooKeeper nodes for paths %q: %s; re-connecting", tw.paths, err)
+				zc.close()
+				zc = nil
+			}
+		case <-zc.doneCh:
+			logger.Errorf("lost connection to ZooKeeper: %s; re-connecting", zc.getErr())
+			zc.close()
+			zc = nil
+		}
+	}
+}
+
+// refresh re-reads all the nodes under tw.paths and sets watches on them.
+func (tw *treeWatcher) refresh(zc *conn) error {
+	zookeeperRefreshesTotal.Inc()
+	nodes := make(map[string][]byte)
+	for _, p := range tw.paths {
+		if err := tw.walkTree(zc, p, nodes); err != nil {
+			zookeeperRefreshErrorsTotal.Inc()
+			return fmt.Errorf("cannot read nodes under %q: %w", p, err)
+		}
+	}
+	tw.mu.Lock()
+	tw.nodes = nodes
+	tw.mu.Unlock()
+	return nil
+}
+
+// handleEvents re-reads only the nodes changed according to events and re-sets watches on them.
+func (tw *treeWatcher) handleEvents(zc *conn, events []watchEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	zookeeperRefreshesTotal.Inc()
+	tw.mu.Lock()
+	nodes := make(map[string][]byte, len(tw.nodes))
+	for p, data := range tw.nodes {
+		nodes[p] = data
+	}
+	tw.mu.Unlock()
+
+	for _, e := range events {
+		if err := tw.handleEvent(zc, e, nodes); err != nil {
+			zookeeperRefreshErrorsTotal.Inc()
+			return fmt.Errorf("cannot read nodes under %q: %w", e.path, err)
+		}
+	}
+	tw.mu.Lock()
+	tw.nodes = nodes
+	tw.mu.Unlock()
+	return nil
+}
+
+func (tw *treeWatcher) handleEvent(zc *conn, e watchEvent, nodes map[string][]byte) error {
+	switch e.typ {
+	case eventNodeDataChanged:
+		data, err := zc.getData(e.path)
+		if err != nil {
+			if errors.Is(err, errNodeNotFound) {
+				// The node has been deleted in the meantime.
+				return tw.deleteTree(zc, e.path, nodes)
+			}
+			return err
+		}
+		nodes[e.path] = data
+		return nil
+	case eventNodeChildrenChanged:
+		children, err := zc.getChildren(e.path)
+		if err != nil {
+			if errors.Is(err, errNodeNotFound) {
+				// The node has been deleted in the meantime.
+				return tw.deleteTree(zc, e.path, nodes)
+			}
+			return err
+		}
+		childPaths := make(map[string]struct{}, len(children))
+		for _, child := range children {
+			childPaths[path.Join(e.path, child)] = struct{}{}
+		}
+		// Deleted children are removed from the snapshot together with their subtrees.
+		for p := range nodes {
+			if _, ok := childPaths[p]; !ok && p != e.path && path.Dir(p) == e.path {
+				deleteSubtree(nodes, p)
+			}
+		}
+		// Only new children are read, since watches are already set on the existing ones.
+		for p := range childPaths {
+			if _, ok := nodes[p]; ok {
+				continue
+			}
+			if err := tw.walkTree(zc, p, nodes); err != nil {
+				return err
+			}
+		}
+		return nil
+	case eventNodeDeleted:
+		return tw.deleteTree(zc, e.path, nodes)
+	case eventNodeCreated:
+		deleteSubtree(nodes, e.path)
+		return tw.walkTree(zc, e.path, nodes)
+	default:
+		// Skip session events, since they aren't related to nodes.
+		// Session loss is detected via zc.doneCh.
+		return nil
+	}
+}
+
+// deleteTree removes the subtree at p from nodes.
+//
+// It waits until the node at p is created again if p is in tw.paths.
+// Other nodes are tracked by children watches on their parents.
+func (tw *treeWatcher) deleteTree(zc *conn, p string, nodes map[string][]byte) error {
+	deleteSubtree(nodes, p)
+	if !slices.Contains(tw.paths, p) {
+		return nil
+	}
+	return zc.watchExists(p)
+}
+
+// deleteSubtree removes p and all its descendants from nodes.
+func deleteSubtree(nodes map[string][]byte, p string) {
+	delete(nodes, p)
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for np := range nodes {
+		if strings.HasPrefix(np, prefix) {
+			delete(nodes, np)
+		}
+	}
+}
+
+// walkTree recursively reads nodes under p into dst.
+func (tw *treeWatcher) walkTree(zc *conn, p string, dst map[string][]byte) error {
+	children, err := zc.getChildren(p)
+	if err != nil {
+		if errors.Is(err, errNodeNotFound) {
+			// The node doesn't exist or has been deleted in the meantime.
+			return tw.deleteTree(zc, p, dst)
+		}
+		return err
+	}
+	data, err := zc.getData(p)
+	if err != nil {
+		if errors.Is(err, errNodeNotFound) {
+			// The node has been deleted in the meantime.
+			return tw.deleteTree(zc, p, dst)
+		}
+		return err
+	}
+	// Nodes with empty data are stored too, so new children could be distinguished from the known ones.
+	dst[p] = data
+	for _, child := range children {
+		if err := tw.walkTree(zc, path.Join(p, child), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
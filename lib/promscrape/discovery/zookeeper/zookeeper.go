@@ -0,0 +1,63 @@
+package zookeeper
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// SDCheckInterval defines interval for refreshing targets discovered via ZooKeeper.
+//
+// The discovered nodes are updated in background via ZooKeeper watches, so this interval only controls
+// how frequently the scrape targets are re-generated from the cached nodes.
+var SDCheckInterval = flag.Duration("promscrape.zookeeperSDCheckInterval", 30*time.Second, "Interval for checking for changes in ZooKeeper. "+
+	"This works only if serverset_sd_configs or nerve_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#serverset_sd_configs and https://docs.victoriametrics.com/sd_configs.html#nerve_sd_configs for details")
+
+// ServersetSDConfig represents service discovery config for Serverset.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#serverset_sd_config
+type ServersetSDConfig struct {
+	Servers []string            `yaml:"servers"`
+	Paths   []string            `yaml:"paths"`
+	Timeout *promutils.Duration `yaml:"timeout,omitempty"`
+}
+
+// GetLabels returns Serverset labels according to sdc.
+func (sdc *ServersetSDConfig) GetLabels(_ string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, sdc.Servers, sdc.Paths, sdc.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getServersetLabels(cfg.tw.getNodes()), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *ServersetSDConfig) MustStop() {
+	mustStopAPIConfig(sdc)
+}
+
+// NerveSDConfig represents service discovery config for AirBnB's Nerve.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#nerve_sd_config
+type NerveSDConfig struct {
+	Servers []string            `yaml:"servers"`
+	Paths   []string            `yaml:"paths"`
+	Timeout *promutils.Duration `yaml:"timeout,omitempty"`
+}
+
+// GetLabels returns Nerve labels according to sdc.
+func (sdc *NerveSDConfig) GetLabels(_ string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, sdc.Servers, sdc.Paths, sdc.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getNerveLabels(cfg.tw.getNodes()), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *NerveSDConfig) MustStop() {
+	mustStopAPIConfig(sdc)
+}
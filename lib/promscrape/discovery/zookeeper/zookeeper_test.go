@@ -0,0 +1,145 @@
+package zookeeper
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(servers, paths []string) {
+		t.Helper()
+		if _, err := newAPIConfig(servers, paths, nil); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(nil, []string{"/foo"})
+	f([]string{"127.0.0.1:2181"}, nil)
+	f([]string{"127.0.0.1:2181"}, []string{"foo"})
+
+	// unreachable server
+	ln := newTestServer(t)
+	addr := ln.addr()
+	ln.close()
+	f([]string{addr}, []string{"/foo"})
+}
+
+func TestServersetSDConfigGetLabels(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.set("/services/foo/member_0000000000", []byte(`{"serviceEndpoint":{"host":"10.0.0.1","port":8080},"status":"ALIVE"}`))
+
+	sdc := &ServersetSDConfig{
+		Servers: []string{"127.0.0.1:1", ts.addr()},
+		Paths:   []string{"/services/foo", "/services/missing"},
+		Timeout: promutils.NewDuration(time.Second),
+	}
+	defer sdc.MustStop()
+
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.1:8080")
+
+	// Add a member
+	ts.set("/services/foo/member_0000000001", []byte(`{"serviceEndpoint":{"host":"10.0.0.2","port":8080},"status":"ALIVE"}`))
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.1:8080", "10.0.0.2:8080")
+
+	// Update a member
+	ts.set("/services/foo/member_0000000000", []byte(`{"serviceEndpoint":{"host":"10.0.0.3","port":8080},"status":"ALIVE"}`))
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.3:8080", "10.0.0.2:8080")
+
+	// Delete a member
+	ts.delete("/services/foo/member_0000000001")
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.3:8080")
+
+	// Create the missing path
+	ts.set("/services/missing/member_0000000000", []byte(`{"serviceEndpoint":{"host":"10.0.0.4","port":8080},"status":"ALIVE"}`))
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.3:8080", "10.0.0.4:8080")
+
+	// Break the connection and make sure the watcher re-connects
+	ts.closeConns()
+	ts.set("/services/foo/member_0000000002", []byte(`{"serviceEndpoint":{"host":"10.0.0.5","port":8080},"status":"ALIVE"}`))
+	waitForAddresses(t, sdc.GetLabels, "10.0.0.3:8080", "10.0.0.5:8080", "10.0.0.4:8080")
+}
+
+func TestNerveSDConfigGetLabels(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.set("/nerve/services/bar/services/i-1", []byte(`{"host":"10.0.1.1","port":3000,"name":"bar"}`))
+
+	sdc := &NerveSDConfig{
+		Servers: []string{ts.addr()},
+		Paths:   []string{"/nerve/services/bar/services"},
+	}
+	defer sdc.MustStop()
+
+	waitForAddresses(t, sdc.GetLabels, "10.0.1.1:3000")
+
+	ts.set("/nerve/services/bar/services/i-2", []byte(`{"host":"10.0.1.2","port":3000,"name":"bar"}`))
+	waitForAddresses(t, sdc.GetLabels, "10.0.1.1:3000", "10.0.1.2:3000")
+}
+
+func TestTreeWatcherReadsOnlyChangedNodes(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	for i := 0; i < 10; i++ {
+		ts.set(fmt.Sprintf("/services/foo/member_%010d", i), []byte(fmt.Sprintf(`{"serviceEndpoint":{"host":"10.0.0.%d","port":8080}}`, i)))
+	}
+	sdc := &ServersetSDConfig{
+		Servers: []string{ts.addr()},
+		Paths:   []string{"/services/foo"},
+	}
+	defer sdc.MustStop()
+
+	addrs := []string{"10.0.0.0:8080", "10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080", "10.0.0.4:8080",
+		"10.0.0.5:8080", "10.0.0.6:8080", "10.0.0.7:8080", "10.0.0.8:8080", "10.0.0.9:8080"}
+	waitForAddresses(t, sdc.GetLabels, addrs...)
+
+	// Only the updated member must be re-read.
+	reads := ts.getReads()
+	ts.set("/services/foo/member_0000000000", []byte(`{"serviceEndpoint":{"host":"10.0.1.0","port":8080}}`))
+	addrs[0] = "10.0.1.0:8080"
+	waitForAddresses(t, sdc.GetLabels, addrs...)
+	if n := ts.getReads() - reads; n != 1 {
+		t.Fatalf("unexpected number of reads after updating a member; got %d; want 1", n)
+	}
+
+	// Only the parent children list and the new member must be read.
+	reads = ts.getReads()
+	ts.set("/services/foo/member_0000000010", []byte(`{"serviceEndpoint":{"host":"10.0.0.10","port":8080}}`))
+	addrs = append(addrs, "10.0.0.10:8080")
+	waitForAddresses(t, sdc.GetLabels, addrs...)
+	if n := ts.getReads() - reads; n != 3 {
+		t.Fatalf("unexpected number of reads after adding a member; got %d; want 3", n)
+	}
+
+	// Nodes without endpoint are skipped.
+	ts.set("/services/foo/member_0000000011", []byte(`{}`))
+	ts.delete("/services/foo/member_0000000010")
+	waitForAddresses(t, sdc.GetLabels, addrs[:10]...)
+}
+
+func waitForAddresses(t *testing.T, getLabels func(baseDir string) ([]*promutils.Labels, error), addrsExpected ...string) {
+	t.Helper()
+	var addrs []string
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		labelss, err := getLabels(".")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		addrs = addrs[:0]
+		for _, labels := range labelss {
+			addrs = append(addrs, labels.Get("__address__"))
+		}
+		if slices.Equal(addrs, addrsExpected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("unexpected addresses; got %q; want %q", addrs, addrsExpected)
+}
@@ -0,0 +1,134 @@
+package promscrape
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// monitorScrapeWorkConfigs holds scrapeWorkConfig objects for ServiceMonitor and PodMonitor endpoints
+// discovered via kubernetes_sd_configs with `role: servicemonitor` and `role: podmonitor`.
+type monitorScrapeWorkConfigs struct {
+	swc     *scrapeWorkConfig
+	baseDir string
+
+	mu sync.Mutex
+	m  map[string]*monitorScrapeWorkConfig
+
+	// swKeys contains monitor endpoint keys for ScrapeWork objects created via getScrapeWork.
+	//
+	// It is used for removing scrapeWorkConfig objects for monitor endpoints without targets at prune.
+	swKeys map[*ScrapeWork]string
+}
+
+type monitorScrapeWorkConfig struct {
+	me  *kubernetes.MonitorEndpoint
+	swc *scrapeWorkConfig
+}
+
+func newMonitorScrapeWorkConfigs(swc *scrapeWorkConfig, baseDir string) *monitorScrapeWorkConfigs {
+	return &monitorScrapeWorkConfigs{
+		swc:     swc,
+		baseDir: baseDir,
+		m:       make(map[string]*monitorScrapeWorkConfig),
+		swKeys:  make(map[*ScrapeWork]string),
+	}
+}
+
+// getScrapeWork returns ScrapeWork for the given target discovered via the given me.
+func (mswcs *monitorScrapeWorkConfigs) getScrapeWork(me *kubernetes.MonitorEndpoint, target string, metaLabels *promutils.Labels) (*ScrapeWork, error) {
+	swc, err := mswcs.get(me)
+	if err != nil {
+		return nil, err
+	}
+	sw, err := swc.getScrapeWork(target, nil, metaLabels)
+	if err != nil || sw == nil {
+		return sw, err
+	}
+	mswcs.mu.Lock()
+	mswcs.swKeys[sw] = me.Key
+	mswcs.mu.Unlock()
+	return sw, nil
+}
+
+// get returns scrapeWorkConfig for the given me.
+//
+// The returned scrapeWorkConfig is re-created only if me changes, so ScrapeWork objects for unchanged endpoints remain the same.
+func (mswcs *monitorScrapeWorkConfigs) get(me *kubernetes.MonitorEndpoint) (*scrapeWorkConfig, error) {
+	mswcs.mu.Lock()
+	defer mswcs.mu.Unlock()
+
+	if mswc := mswcs.m[me.Key]; mswc != nil && (mswc.me == me || reflect.DeepEqual(mswc.me, me)) {
+		return mswc.swc, nil
+	}
+	swc, err := newMonitorScrapeWorkConfig(mswcs.swc, mswcs.baseDir, me)
+	if err != nil {
+		return nil, err
+	}
+	mswcs.m[me.Key] = &monitorScrapeWorkConfig{
+		me:  me,
+		swc: swc,
+	}
+	return swc, nil
+}
+
+// prune removes scrapeWorkConfig objects for monitor endpoints, which have no targets in sws.
+//
+// sws must contain all the ScrapeWork objects obtained for the scrape config at the last discovery sync.
+func (mswcs *monitorScrapeWorkConfigs) prune(sws []*ScrapeWork) {
+	if mswcs == nil {
+		return
+	}
+	mswcs.mu.Lock()
+	defer mswcs.mu.Unlock()
+
+	keys := make(map[string]struct{}, len(mswcs.m))
+	swKeys := make(map[*ScrapeWork]string, len(mswcs.swKeys))
+	for _, sw := range sws {
+		key, ok := mswcs.swKeys[sw]
+		if !ok {
+			continue
+		}
+		keys[key] = struct{}{}
+		swKeys[sw] = key
+	}
+	mswcs.swKeys = swKeys
+	for key := range mswcs.m {
+		if _, ok := keys[key]; !ok {
+			delete(mswcs.m, key)
+		}
+	}
+}
+
+// newMonitorScrapeWorkConfig returns a copy of swc with the settings overridden by me.
+func newMonitorScrapeWorkConfig(swc *scrapeWorkConfig, baseDir string, me *kubernetes.MonitorEndpoint) (*scrapeWorkConfig, error) {
+	swcCopy := *swc
+	swcCopy.honorLabels = me.HonorLabels
+	if me.HonorTimestamps != nil {
+		swcCopy.honorTimestamps = *me.HonorTimestamps
+	}
+	if !reflect.DeepEqual(me.HTTPClientConfig, promauth.HTTPClientConfig{}) {
+		ac, err := me.HTTPClientConfig.NewConfig(baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse auth config for %s: %w", me.Key, err)
+		}
+		swcCopy.authConfig = ac
+	}
+	if len(me.MetricRelabelConfigs) > 0 {
+		// Apply metricRelabelings from the monitor endpoint after the metric_relabel_configs from the scrape config.
+		mrcs := append([]promrelabel.RelabelConfig{}, swc.metricRelabelRules...)
+		mrcs = append(mrcs, me.MetricRelabelConfigs...)
+		metricRelabelConfigs, err := promrelabel.ParseRelabelConfigs(mrcs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `metricRelabelings` for %s: %w", me.Key, err)
+		}
+		swcCopy.metricRelabelConfigs = metricRelabelConfigs
+		swcCopy.metricRelabelRules = mrcs
+	}
+	return &swcCopy, nil
+}
@@ -0,0 +1,157 @@
+package promscrape
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+)
+
+func TestMonitorScrapeWorkConfigs(t *testing.T) {
+	mrcs := []promrelabel.RelabelConfig{
+		{
+			Action:       "drop",
+			SourceLabels: []string{"__name__"},
+			Regex: &promrelabel.MultiLineRegex{
+				S: "foo",
+			},
+		},
+	}
+	metricRelabelConfigs, err := promrelabel.ParseRelabelConfigs(mrcs)
+	if err != nil {
+		t.Fatalf("cannot parse metric relabel configs: %s", err)
+	}
+	var opts promauth.Options
+	ac, err := opts.NewConfig()
+	if err != nil {
+		t.Fatalf("cannot create auth config: %s", err)
+	}
+	swc := &scrapeWorkConfig{
+		jobName:              "k8s",
+		authConfig:           ac,
+		metricRelabelConfigs: metricRelabelConfigs,
+		metricRelabelRules:   mrcs,
+	}
+	mswcs := newMonitorScrapeWorkConfigs(swc, ".")
+
+	// Endpoint without overrides
+	me := &kubernetes.MonitorEndpoint{
+		Key: "servicemonitor/default/foo/0",
+	}
+	swcMonitor, err := mswcs.get(me)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if swcMonitor.authConfig != ac {
+		t.Fatalf("unexpected auth config; got %s; want %s", swcMonitor.authConfig, ac)
+	}
+	if swcMonitor.metricRelabelConfigs.Len() != 1 {
+		t.Fatalf("unexpected number of metric relabel configs; got %d; want 1", swcMonitor.metricRelabelConfigs.Len())
+	}
+
+	// Endpoint with overrides
+	honorTimestamps := true
+	me = &kubernetes.MonitorEndpoint{
+		Key:             "servicemonitor/default/foo/0",
+		HonorLabels:     true,
+		HonorTimestamps: &honorTimestamps,
+		MetricRelabelConfigs: []promrelabel.RelabelConfig{
+			{
+				Action: "labeldrop",
+				Regex: &promrelabel.MultiLineRegex{
+					S: "bar",
+				},
+			},
+		},
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BearerToken: promauth.NewSecret("token"),
+		},
+	}
+	swcMonitor, err = mswcs.get(me)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !swcMonitor.honorLabels || !swcMonitor.honorTimestamps {
+		t.Fatalf("honor_labels and honor_timestamps must be overridden")
+	}
+	if swcMonitor.authConfig == ac {
+		t.Fatalf("auth config must be overridden")
+	}
+	if swcMonitor.metricRelabelConfigs.Len() != 2 {
+		t.Fatalf("unexpected number of metric relabel configs; got %d; want 2", swcMonitor.metricRelabelConfigs.Len())
+	}
+	if swc.metricRelabelConfigs.Len() != 1 || swc.honorLabels {
+		t.Fatalf("the original scrapeWorkConfig must remain unchanged")
+	}
+
+	// The same endpoint must return the cached scrapeWorkConfig
+	swcCached, err := mswcs.get(me)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if swcCached != swcMonitor {
+		t.Fatalf("expecting cached scrapeWorkConfig")
+	}
+
+	// Invalid metricRelabelings
+	me = &kubernetes.MonitorEndpoint{
+		Key: "podmonitor/default/bar/0",
+		MetricRelabelConfigs: []promrelabel.RelabelConfig{
+			{
+				Action: "unknown",
+			},
+		},
+	}
+	if _, err := mswcs.get(me); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestMonitorScrapeWorkConfigsPrune(t *testing.T) {
+	var opts promauth.Options
+	ac, err := opts.NewConfig()
+	if err != nil {
+		t.Fatalf("cannot create auth config: %s", err)
+	}
+	swc := &scrapeWorkConfig{
+		jobName:    "k8s",
+		authConfig: ac,
+	}
+	mswcs := newMonitorScrapeWorkConfigs(swc, ".")
+
+	f := func(sws []*ScrapeWork, keysExpected []string) {
+		t.Helper()
+		mswcs.prune(sws)
+		if len(mswcs.m) != len(keysExpected) {
+			t.Fatalf("unexpected number of cached configs; got %d; want %d", len(mswcs.m), len(keysExpected))
+		}
+		for _, key := range keysExpected {
+			if mswcs.m[key] == nil {
+				t.Fatalf("missing cached config for %q", key)
+			}
+		}
+		if len(mswcs.swKeys) != len(sws) {
+			t.Fatalf("unexpected number of tracked ScrapeWork objects; got %d; want %d", len(mswcs.swKeys), len(sws))
+		}
+	}
+
+	var sws []*ScrapeWork
+	for _, key := range []string{"servicemonitor/default/foo/0", "podmonitor/default/bar/0"} {
+		if _, err := mswcs.get(&kubernetes.MonitorEndpoint{Key: key}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		sw := &ScrapeWork{}
+		mswcs.swKeys[sw] = key
+		sws = append(sws, sw)
+	}
+
+	// all the monitor endpoints have targets
+	f(sws, []string{"servicemonitor/default/foo/0", "podmonitor/default/bar/0"})
+
+	// targets for the podmonitor are gone
+	f(sws[:1], []string{"servicemonitor/default/foo/0"})
+
+	// all the targets are gone
+	f(nil, nil)
+}
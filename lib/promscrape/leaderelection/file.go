@@ -0,0 +1,238 @@
+package leaderelection
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// fileBackend stores the lease in a file shared among vmagent replicas.
+//
+// It is intended for tests and for replicas running on the same host or sharing a filesystem.
+// Concurrent modifications are serialized via lock file, which is created exclusively and contains the unique owner of the lock.
+type fileBackend struct {
+	path     string
+	lockPath string
+
+	// staleLockTimeout is the duration after which the lock file is considered abandoned by crashed replica.
+	staleLockTimeout time.Duration
+}
+
+func newFileBackend(path string) (*fileBackend, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path to the lease file cannot be empty")
+	}
+	fb := &fileBackend{
+		path:             path,
+		lockPath:         path + ".lock",
+		staleLockTimeout: 10 * time.Second,
+	}
+	return fb, nil
+}
+
+// fileLease is the contents of the lease file.
+type fileLease struct {
+	Version uint64      `json:"version"`
+	Record  leaseRecord `json:"record"`
+}
+
+func (fb *fileBackend) String() string {
+	return fmt.Sprintf("file %q", fb.path)
+}
+
+func (fb *fileBackend) get() (*leaseRecord, string, error) {
+	fl, err := fb.read()
+	if err != nil || fl == nil {
+		return nil, "", err
+	}
+	return &fl.Record, strconv.FormatUint(fl.Version, 10), nil
+}
+
+func (fb *fileBackend) create(lr *leaseRecord) error {
+	return fb.withLock(func(lockOwner string) error {
+		fl, err := fb.read()
+		if err != nil {
+			return err
+		}
+		if fl != nil {
+			return errLeaseConflict
+		}
+		return fb.write(lockOwner, &fileLease{
+			Version: 1,
+			Record:  *lr,
+		})
+	})
+}
+
+func (fb *fileBackend) update(lr *leaseRecord, version string) error {
+	return fb.withLock(func(lockOwner string) error {
+		fl, err := fb.read()
+		if err != nil {
+			return err
+		}
+		if fl == nil || strconv.FormatUint(fl.Version, 10) != version {
+			return errLeaseConflict
+		}
+		return fb.write(lockOwner, &fileLease{
+			Version: fl.Version + 1,
+			Record:  *lr,
+		})
+	})
+}
+
+func (fb *fileBackend) read() (*fileLease, error) {
+	data, err := os.ReadFile(fb.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read lease file: %w", err)
+	}
+	var fl fileLease
+	if err := json.Unmarshal(data, &fl); err != nil {
+		return nil, fmt.Errorf("cannot parse lease file %q: %w", fb.path, err)
+	}
+	return &fl, nil
+}
+
+// write writes fl to the lease file if the lock is still held by lockOwner.
+func (fb *fileBackend) write(lockOwner string, fl *fileLease) error {
+	data, err := json.Marshal(fl)
+	if err != nil {
+		return fmt.Errorf("BUG: cannot marshal lease: %w", err)
+	}
+	// Write the lease atomically, so concurrent readers never see partially written file.
+	tmpPath := fb.path + "." + lockOwner + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("cannot write lease file: %w", err)
+	}
+	// Verify the lock hasn't been taken over by another replica while it was held, e.g. because of too long pause.
+	owner, err := fb.readLockOwner(fb.lockPath)
+	if err == nil && owner != lockOwner {
+		err = errLeaseConflict
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, fb.path); err != nil {
+		return fmt.Errorf("cannot rename %q to %q: %w", tmpPath, fb.path, err)
+	}
+	return nil
+}
+
+// withLock calls f under the lock file.
+//
+// f must pass the lock owner to write.
+func (fb *fileBackend) withLock(f func(lockOwner string) error) error {
+	owner, err := newLockOwner()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(fb.staleLockTimeout)
+	for {
+		ok, err := fb.tryLock(owner)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cannot acquire lock file %q in %s", fb.lockPath, fb.staleLockTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() {
+		if err := fb.removeLockIfOwned(owner, owner); err != nil {
+			logger.Errorf("cannot release lock file %q: %s", fb.lockPath, err)
+		}
+	}()
+	return f(owner)
+}
+
+// tryLock tries creating the lock file for the given owner.
+//
+// The lock file left by crashed replica is removed after staleLockTimeout.
+func (fb *fileBackend) tryLock(owner string) (bool, error) {
+	f, err := os.OpenFile(fb.lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		_, err = f.WriteString(owner)
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			_ = os.Remove(fb.lockPath)
+			return false, fmt.Errorf("cannot write lock file %q: %w", fb.lockPath, err)
+		}
+		return true, nil
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return false, fmt.Errorf("cannot create lock file: %w", err)
+	}
+
+	fi, err := os.Stat(fb.lockPath)
+	if err != nil || time.Since(fi.ModTime()) <= fb.staleLockTimeout {
+		// The lock is held by another replica or has been just released.
+		return false, nil
+	}
+	staleOwner, err := fb.readLockOwner(fb.lockPath)
+	if err != nil {
+		return false, nil
+	}
+	// The lock has been left by crashed replica. Remove it only if it hasn't been taken over by another replica in the mean time.
+	if err := fb.removeLockIfOwned(staleOwner, owner); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// removeLockIfOwned removes the lock file if it is owned by the given owner.
+//
+// The lock file is atomically renamed to the path unique for the caller before checking the owner,
+// so concurrently created lock file of another owner cannot be removed. Such lock file is restored.
+func (fb *fileBackend) removeLockIfOwned(owner, caller string) error {
+	tmpPath := fb.lockPath + "." + caller
+	if err := os.Rename(fb.lockPath, tmpPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("cannot rename lock file %q to %q: %w", fb.lockPath, tmpPath, err)
+	}
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+	actualOwner, err := fb.readLockOwner(tmpPath)
+	if err == nil && actualOwner == owner {
+		return nil
+	}
+	// Restore the lock file of another owner unless a new lock file has been already created.
+	if err := os.Link(tmpPath, fb.lockPath); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("cannot restore lock file %q: %w", fb.lockPath, err)
+	}
+	return nil
+}
+
+func (fb *fileBackend) readLockOwner(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read lock file: %w", err)
+	}
+	return string(data), nil
+}
+
+// newLockOwner returns unique owner for the lock file.
+func newLockOwner() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("cannot generate lock owner: %w", err)
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
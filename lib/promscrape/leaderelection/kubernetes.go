@@ -0,0 +1,246 @@
+package leaderelection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+// kubernetesBackend stores the lease in Kubernetes Lease object.
+//
+// See https://kubernetes.io/docs/concepts/architecture/leases/
+type kubernetesBackend struct {
+	apiServer string
+	namespace string
+	name      string
+
+	hc *http.Client
+	ac *promauth.Config
+}
+
+// newKubernetesBackendInCluster returns kubernetesBackend, which accesses Kubernetes API server
+// with the service account of the pod vmagent runs in.
+func newKubernetesBackendInCluster(namespace, name string) (*kubernetesBackend, error) {
+	// See https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#service-account-admission-controller
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	port := os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) == 0 || len(port) == 0 {
+		return nil, fmt.Errorf("cannot find KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT env vars; they must be defined when running in k8s")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine the namespace for the lease: %w; set it explicitly via -promscrape.leaderElection.leaseNamespace", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	opts := &promauth.Options{
+		BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		TLSConfig: &promauth.TLSConfig{
+			CAFile: "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+		},
+	}
+	ac, err := opts.NewConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize service account auth: %w", err)
+	}
+	apiServer := "https://" + net.JoinHostPort(host, port)
+	return newKubernetesBackend(apiServer, ac, namespace, name)
+}
+
+func newKubernetesBackend(apiServer string, ac *promauth.Config, namespace, name string) (*kubernetesBackend, error) {
+	if name == "" {
+		return nil, fmt.Errorf("lease name cannot be empty")
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("lease namespace cannot be empty")
+	}
+	hc := &http.Client{
+		Transport: ac.NewRoundTripper(&http.Transport{
+			DialContext:         netutil.Dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 2,
+		}),
+		Timeout: requestTimeout,
+	}
+	kb := &kubernetesBackend{
+		apiServer: strings.TrimSuffix(apiServer, "/"),
+		namespace: namespace,
+		name:      name,
+		hc:        hc,
+		ac:        ac,
+	}
+	return kb, nil
+}
+
+// requestTimeout is the timeout for requests to Kubernetes API server.
+const requestTimeout = 10 * time.Second
+
+// lease represents Kubernetes Lease object.
+//
+// See https://kubernetes.io/docs/reference/kubernetes-api/cluster-resources/lease-v1/
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// microTimeLayout is the layout for MicroTime fields in Kubernetes API.
+const microTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+func formatMicroTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(microTimeLayout)
+}
+
+func parseMicroTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func (kb *kubernetesBackend) String() string {
+	return fmt.Sprintf("kubernetes lease %s/%s", kb.namespace, kb.name)
+}
+
+func (kb *kubernetesBackend) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", kb.apiServer, url.PathEscape(kb.namespace))
+}
+
+func (kb *kubernetesBackend) leaseURL() string {
+	return kb.leasesURL() + "/" + url.PathEscape(kb.name)
+}
+
+func (kb *kubernetesBackend) get() (*leaseRecord, string, error) {
+	statusCode, data, err := kb.doRequest(http.MethodGet, kb.leaseURL(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code returned from %q: %d; response body: %q", kb.leaseURL(), statusCode, data)
+	}
+	var l lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, "", fmt.Errorf("cannot parse lease obtained from %q: %w", kb.leaseURL(), err)
+	}
+	acquireTime, err := parseMicroTime(l.Spec.AcquireTime)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot parse acquireTime in lease %s: %w", kb, err)
+	}
+	renewTime, err := parseMicroTime(l.Spec.RenewTime)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot parse renewTime in lease %s: %w", kb, err)
+	}
+	lr := &leaseRecord{
+		HolderIdentity:       l.Spec.HolderIdentity,
+		LeaseDurationSeconds: l.Spec.LeaseDurationSeconds,
+		AcquireTime:          acquireTime,
+		RenewTime:            renewTime,
+		LeaseTransitions:     l.Spec.LeaseTransitions,
+	}
+	return lr, l.Metadata.ResourceVersion, nil
+}
+
+func (kb *kubernetesBackend) create(lr *leaseRecord) error {
+	return kb.put(http.MethodPost, kb.leasesURL(), lr, "")
+}
+
+func (kb *kubernetesBackend) update(lr *leaseRecord, version string) error {
+	return kb.put(http.MethodPut, kb.leaseURL(), lr, version)
+}
+
+func (kb *kubernetesBackend) put(method, requestURL string, lr *leaseRecord, version string) error {
+	l := &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:            kb.name,
+			Namespace:       kb.namespace,
+			ResourceVersion: version,
+		},
+		Spec: leaseSpec{
+			HolderIdentity:       lr.HolderIdentity,
+			LeaseDurationSeconds: lr.LeaseDurationSeconds,
+			AcquireTime:          formatMicroTime(lr.AcquireTime),
+			RenewTime:            formatMicroTime(lr.RenewTime),
+			LeaseTransitions:     lr.LeaseTransitions,
+		},
+	}
+	body, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("BUG: cannot marshal lease: %w", err)
+	}
+	statusCode, data, err := kb.doRequest(method, requestURL, body)
+	if err != nil {
+		return err
+	}
+	switch statusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict:
+		return errLeaseConflict
+	default:
+		return fmt.Errorf("unexpected status code returned from %s %q: %d; response body: %q", method, requestURL, statusCode, data)
+	}
+}
+
+func (kb *kubernetesBackend) doRequest(method, requestURL string, body []byte) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot create request for %q: %w", requestURL, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := kb.ac.SetHeaders(req, true); err != nil {
+		return 0, nil, fmt.Errorf("cannot set request headers for %q: %w", requestURL, err)
+	}
+	resp, err := kb.hc.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot perform %s %q: %w", method, requestURL, err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot read response from %q: %w", requestURL, err)
+	}
+	return resp.StatusCode, data, nil
+}
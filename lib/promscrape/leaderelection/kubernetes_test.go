@@ -0,0 +1,124 @@
+package leaderelection
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+// fakeLeaseServer emulates Kubernetes API for a single Lease object.
+type fakeLeaseServer struct {
+	mu              sync.Mutex
+	l               *lease
+	resourceVersion int
+}
+
+func (fls *fakeLeaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fls.mu.Lock()
+	defer fls.mu.Unlock()
+
+	const leasesPath = "/apis/coordination.k8s.io/v1/namespaces/monitoring/leases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == leasesPath+"/vmagent":
+		if fls.l == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(fls.l)
+	case r.Method == http.MethodPost && r.URL.Path == leasesPath:
+		if fls.l != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		fls.store(w, r)
+	case r.Method == http.MethodPut && r.URL.Path == leasesPath+"/vmagent":
+		if fls.l == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fls.store(w, r)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (fls *fakeLeaseServer) store(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var l lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if fls.l != nil && l.Metadata.ResourceVersion != fls.l.Metadata.ResourceVersion {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	fls.resourceVersion++
+	l.Metadata.ResourceVersion = strconv.Itoa(fls.resourceVersion)
+	fls.l = &l
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(fls.l)
+}
+
+func TestKubernetesBackend(t *testing.T) {
+	s := httptest.NewServer(&fakeLeaseServer{})
+	defer s.Close()
+
+	ac, err := (&promauth.Options{}).NewConfig()
+	if err != nil {
+		t.Fatalf("cannot create auth config: %s", err)
+	}
+	kb, err := newKubernetesBackend(s.URL, ac, "monitoring", "vmagent")
+	if err != nil {
+		t.Fatalf("cannot create kubernetes backend: %s", err)
+	}
+
+	lr, _, err := kb.get()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lr != nil {
+		t.Fatalf("expecting nil lease; got %+v", lr)
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	lrNew := &leaseRecord{
+		HolderIdentity:       "replica-1",
+		LeaseDurationSeconds: 15,
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+	if err := kb.create(lrNew); err != nil {
+		t.Fatalf("cannot create lease: %s", err)
+	}
+	if err := kb.create(lrNew); err != errLeaseConflict {
+		t.Fatalf("unexpected error when creating the existing lease; got %v; want %v", err, errLeaseConflict)
+	}
+
+	lr, version, err := kb.get()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lr.HolderIdentity != "replica-1" || lr.LeaseDurationSeconds != 15 || !lr.RenewTime.Equal(now.Truncate(time.Microsecond)) {
+		t.Fatalf("unexpected lease: %+v", lr)
+	}
+
+	lrNew.RenewTime = now.Add(time.Second)
+	if err := kb.update(lrNew, version); err != nil {
+		t.Fatalf("cannot update lease: %s", err)
+	}
+	if err := kb.update(lrNew, version); err != errLeaseConflict {
+		t.Fatalf("unexpected error when updating the lease with stale version; got %v; want %v", err, errLeaseConflict)
+	}
+}
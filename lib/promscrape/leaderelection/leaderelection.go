@@ -0,0 +1,382 @@
+package leaderelection
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+var (
+	backendType = flag.String("promscrape.leaderElection.backend", "", "Optional backend for leader election among vmagent replicas, which scrape the same targets. "+
+		"Only the leader forwards scraped samples to remote storage, while standby replicas keep scraping and take over when the leader stops renewing the lease. "+
+		"Supported values: kubernetes, file. See https://docs.victoriametrics.com/vmagent/#leader-election")
+	leaseName      = flag.String("promscrape.leaderElection.leaseName", "vmagent", "The name of Kubernetes Lease object for -promscrape.leaderElection.backend=kubernetes")
+	leaseNamespace = flag.String("promscrape.leaderElection.leaseNamespace", "", "The namespace of Kubernetes Lease object for -promscrape.leaderElection.backend=kubernetes. "+
+		"By default the namespace of the pod vmagent runs in is used")
+	leaseFile     = flag.String("promscrape.leaderElection.filePath", "", "Path to the lease file shared among vmagent replicas for -promscrape.leaderElection.backend=file")
+	identity      = flag.String("promscrape.leaderElection.identity", "", "Unique identity of the vmagent replica for leader election. By default the hostname is used")
+	leaseDuration = flag.Duration("promscrape.leaderElection.leaseDuration", 15*time.Second, "The duration after which standby vmagent replica takes over the lease "+
+		"if the leader stops renewing it. See -promscrape.leaderElection.backend")
+	retryPeriod = flag.Duration("promscrape.leaderElection.retryPeriod", 2*time.Second, "How frequently the leader renews the lease and standby replicas try acquiring it. "+
+		"Must be smaller than -promscrape.leaderElection.leaseDuration")
+)
+
+// PushDataFunc is the function for pushing scraped data to remote storage.
+type PushDataFunc func(at *auth.Token, wr *prompbmarshal.WriteRequest)
+
+var (
+	globalElector *elector
+	globalWG      sync.WaitGroup
+	globalStopCh  chan struct{}
+)
+
+// MustStart starts leader election if -promscrape.leaderElection.backend is set.
+//
+// It returns pushData wrapper, which forwards data to pushData only while the current replica holds the lease.
+// If leader election is disabled, then pushData is returned as is.
+func MustStart(pushData PushDataFunc) PushDataFunc {
+	if *backendType == "" {
+		return pushData
+	}
+	b, err := newBackend(*backendType)
+	if err != nil {
+		logger.Fatalf("cannot initialize -promscrape.leaderElection.backend=%q: %s", *backendType, err)
+	}
+	id := *identity
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			logger.Fatalf("cannot determine hostname for -promscrape.leaderElection.identity: %s", err)
+		}
+		id = hostname
+	}
+	e, err := newElector(b, id, *leaseDuration, *retryPeriod, pushData)
+	if err != nil {
+		logger.Fatalf("cannot initialize leader election: %s", err)
+	}
+	globalElector = e
+	globalStopCh = make(chan struct{})
+	globalWG.Add(1)
+	go func() {
+		defer globalWG.Done()
+		e.run(globalStopCh)
+	}()
+	logger.Infof("started leader election via %s with identity %q", b, id)
+	return e.pushData
+}
+
+// Stop stops leader election started via MustStart and releases the lease if it is held by the current replica.
+//
+// It must be called after all the scrapers are stopped.
+func Stop() {
+	if globalElector == nil {
+		return
+	}
+	close(globalStopCh)
+	globalWG.Wait()
+	globalElector.release()
+	globalElector = nil
+}
+
+// IsLeader returns true if the current replica forwards scraped data to remote storage.
+//
+// It always returns true if leader election is disabled.
+func IsLeader() bool {
+	e := globalElector
+	return e == nil || e.isLeader.Load()
+}
+
+func newBackend(typ string) (leaseBackend, error) {
+	switch typ {
+	case "kubernetes":
+		return newKubernetesBackendInCluster(*leaseNamespace, *leaseName)
+	case "file":
+		return newFileBackend(*leaseFile)
+	default:
+		return nil, fmt.Errorf("unsupported backend; supported values: kubernetes, file")
+	}
+}
+
+// elector coordinates vmagent replicas via lease stored at leaseBackend.
+//
+// Standby replicas buffer the scraped data for the lease duration, so they could forward the data,
+// which could be missing at remote storage because of the previous leader failure, right after taking over the lease.
+type elector struct {
+	b             leaseBackend
+	identity      string
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+
+	pushDataInner PushDataFunc
+
+	isLeader atomic.Bool
+
+	// lastRenewTime is the local time of the last successful lease renewal by the current replica.
+	lastRenewTime time.Time
+
+	// observedRecord is the last lease record seen by the current replica.
+	observedRecord leaseRecord
+
+	// observedTime is the local time when observedRecord has been changed.
+	//
+	// Local time is used instead of RenewTime from the lease in order to be resilient to clock skew among replicas.
+	observedTime time.Time
+
+	// mu protects pending
+	mu      sync.Mutex
+	pending []*pendingRequest
+}
+
+// pendingRequest is the scraped data held by standby replica.
+type pendingRequest struct {
+	at       *auth.Token
+	tss      []prompbmarshal.TimeSeries
+	pushTime time.Time
+	samples  int
+}
+
+func newElector(b leaseBackend, id string, leaseDuration, retryPeriod time.Duration, pushData PushDataFunc) (*elector, error) {
+	if leaseDuration < time.Second {
+		return nil, fmt.Errorf("lease duration cannot be smaller than 1s; got %s", leaseDuration)
+	}
+	if retryPeriod <= 0 || retryPeriod >= leaseDuration {
+		return nil, fmt.Errorf("retry period must be in the range (0 ... %s); got %s", leaseDuration, retryPeriod)
+	}
+	e := &elector{
+		b:             b,
+		identity:      id,
+		leaseDuration: leaseDuration,
+		retryPeriod:   retryPeriod,
+		pushDataInner: pushData,
+	}
+	return e, nil
+}
+
+func (e *elector) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+	for {
+		e.tick(time.Now())
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *elector) tick(now time.Time) {
+	since, err := e.tryAcquireOrRenew(now)
+	if err != nil {
+		if !errors.Is(err, errLeaseConflict) {
+			electionErrors.Inc()
+			logger.Errorf("cannot acquire or renew the lease at %s: %s", e.b, err)
+		}
+		if e.isLeader.Load() && now.Sub(e.lastRenewTime) >= e.leaseDuration-e.retryPeriod {
+			// Stop forwarding the data before standby replicas can take over the lease.
+			e.stopLeading()
+		}
+		return
+	}
+	if e.observedRecord.HolderIdentity != e.identity {
+		if e.isLeader.Load() {
+			e.stopLeading()
+		}
+		return
+	}
+	e.lastRenewTime = now
+	if !e.isLeader.Load() {
+		e.startLeading(since)
+	}
+}
+
+// tryAcquireOrRenew tries acquiring or renewing the lease at now.
+//
+// It returns the time since which the data may be missing at remote storage if the lease has been just acquired.
+func (e *elector) tryAcquireOrRenew(now time.Time) (time.Time, error) {
+	lr, version, err := e.b.get()
+	if err != nil {
+		return time.Time{}, err
+	}
+	leaseDurationSeconds := int((e.leaseDuration + time.Second - 1) / time.Second)
+	if lr == nil {
+		lrNew := &leaseRecord{
+			HolderIdentity:       e.identity,
+			LeaseDurationSeconds: leaseDurationSeconds,
+			AcquireTime:          now,
+			RenewTime:            now,
+		}
+		if err := e.b.create(lrNew); err != nil {
+			return time.Time{}, err
+		}
+		e.setObservedRecord(lrNew, now)
+		return now.Add(-e.leaseDuration), nil
+	}
+	if !lr.equal(&e.observedRecord) {
+		e.setObservedRecord(lr, now)
+	}
+	holder := lr.HolderIdentity
+	if holder != "" && holder != e.identity && now.Before(e.observedTime.Add(time.Duration(lr.LeaseDurationSeconds)*time.Second)) {
+		// The lease is held by another replica.
+		return time.Time{}, nil
+	}
+
+	lrNew := *lr
+	lrNew.HolderIdentity = e.identity
+	lrNew.LeaseDurationSeconds = leaseDurationSeconds
+	lrNew.RenewTime = now
+	var since time.Time
+	if holder != e.identity {
+		lrNew.AcquireTime = now
+		lrNew.LeaseTransitions++
+		// The previous leader could fail to forward the data scraped after the last observed lease renewal.
+		since = e.observedTime.Add(-e.retryPeriod)
+	}
+	if err := e.b.update(&lrNew, version); err != nil {
+		return time.Time{}, err
+	}
+	e.setObservedRecord(&lrNew, now)
+	return since, nil
+}
+
+func (e *elector) setObservedRecord(lr *leaseRecord, now time.Time) {
+	e.observedRecord = *lr
+	e.observedTime = now
+}
+
+func (e *elector) startLeading(since time.Time) {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.isLeader.Store(true)
+	e.mu.Unlock()
+
+	isLeader.Set(1)
+	leaderTransitions.Inc()
+	logger.Infof("became the leader at %s; forwarding scraped data buffered since %s", e.b, since.Format(time.RFC3339))
+
+	for _, pr := range pending {
+		if pr.pushTime.Before(since) {
+			continue
+		}
+		wr := &prompbmarshal.WriteRequest{
+			Timeseries: pr.tss,
+		}
+		e.pushDataInner(pr.at, wr)
+		replayedSamples.Add(pr.samples)
+	}
+}
+
+func (e *elector) stopLeading() {
+	e.isLeader.Store(false)
+	isLeader.Set(0)
+	logger.Infof("lost the leadership at %s; switching to standby mode", e.b)
+}
+
+// release gives up the lease, so standby replicas could take it over without waiting for the lease expiration.
+func (e *elector) release() {
+	if !e.isLeader.Load() {
+		return
+	}
+	e.stopLeading()
+	lr, version, err := e.b.get()
+	if err != nil {
+		logger.Errorf("cannot release the lease at %s: %s", e.b, err)
+		return
+	}
+	if lr == nil || lr.HolderIdentity != e.identity {
+		return
+	}
+	lrNew := *lr
+	lrNew.HolderIdentity = ""
+	lrNew.RenewTime = time.Now()
+	if err := e.b.update(&lrNew, version); err != nil {
+		logger.Errorf("cannot release the lease at %s: %s", e.b, err)
+	}
+}
+
+// pushData forwards wr to remote storage if the current replica is the leader.
+//
+// Otherwise wr is buffered for the lease duration.
+func (e *elector) pushData(at *auth.Token, wr *prompbmarshal.WriteRequest) {
+	if e.isLeader.Load() {
+		e.pushDataInner(at, wr)
+		return
+	}
+
+	e.mu.Lock()
+	if e.isLeader.Load() {
+		// The leadership has been acquired while waiting for the lock.
+		e.mu.Unlock()
+		e.pushDataInner(at, wr)
+		return
+	}
+	now := time.Now()
+	tss, samples := cloneTimeSeries(wr.Timeseries)
+	e.pending = append(e.pending, &pendingRequest{
+		at:       at,
+		tss:      tss,
+		pushTime: now,
+		samples:  samples,
+	})
+
+	// Drop the data, which cannot be needed after taking over the lease.
+	deadline := now.Add(-e.leaseDuration - 2*e.retryPeriod)
+	n := 0
+	for n < len(e.pending) && e.pending[n].pushTime.Before(deadline) {
+		standbySamplesDropped.Add(e.pending[n].samples)
+		e.pending[n] = nil
+		n++
+	}
+	e.pending = append(e.pending[:0], e.pending[n:]...)
+	e.mu.Unlock()
+}
+
+// cloneTimeSeries returns a deep copy of tss, since the caller may re-use tss after pushData returns.
+func cloneTimeSeries(tss []prompbmarshal.TimeSeries) ([]prompbmarshal.TimeSeries, int) {
+	labelsLen := 0
+	samplesLen := 0
+	for i := range tss {
+		labelsLen += len(tss[i].Labels)
+		samplesLen += len(tss[i].Samples)
+	}
+	labels := make([]prompbmarshal.Label, 0, labelsLen)
+	samples := make([]prompbmarshal.Sample, 0, samplesLen)
+	dst := make([]prompbmarshal.TimeSeries, len(tss))
+	for i := range tss {
+		ts := &tss[i]
+		labelsStart := len(labels)
+		for _, label := range ts.Labels {
+			labels = append(labels, prompbmarshal.Label{
+				Name:  strings.Clone(label.Name),
+				Value: strings.Clone(label.Value),
+			})
+		}
+		samplesStart := len(samples)
+		samples = append(samples, ts.Samples...)
+		dst[i] = prompbmarshal.TimeSeries{
+			Labels:  labels[labelsStart:len(labels):len(labels)],
+			Samples: samples[samplesStart:len(samples):len(samples)],
+		}
+	}
+	return dst, samplesLen
+}
+
+var (
+	isLeader              = metrics.NewGauge(`vm_promscrape_leader_election_is_leader`, nil)
+	leaderTransitions     = metrics.NewCounter(`vm_promscrape_leader_election_transitions_total`)
+	electionErrors        = metrics.NewCounter(`vm_promscrape_leader_election_errors_total`)
+	replayedSamples       = metrics.NewCounter(`vm_promscrape_leader_election_replayed_samples_total`)
+	standbySamplesDropped = metrics.NewCounter(`vm_promscrape_leader_election_standby_samples_dropped_total`)
+)
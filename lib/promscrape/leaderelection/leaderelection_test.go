@@ -0,0 +1,281 @@
+package leaderelection
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+type testPusher struct {
+	mu      sync.Mutex
+	samples []float64
+}
+
+func (tp *testPusher) pushData(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for _, ts := range wr.Timeseries {
+		for _, s := range ts.Samples {
+			tp.samples = append(tp.samples, s.Value)
+		}
+	}
+}
+
+func (tp *testPusher) reset() []float64 {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	samples := tp.samples
+	tp.samples = nil
+	return samples
+}
+
+func newTestElector(t *testing.T, path, id string, tp *testPusher) *elector {
+	t.Helper()
+	b, err := newFileBackend(path)
+	if err != nil {
+		t.Fatalf("cannot create file backend: %s", err)
+	}
+	e, err := newElector(b, id, 10*time.Second, 2*time.Second, tp.pushData)
+	if err != nil {
+		t.Fatalf("cannot create elector: %s", err)
+	}
+	return e
+}
+
+func pushSample(e *elector, v float64) {
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{{
+			Labels: []prompbmarshal.Label{{
+				Name:  "__name__",
+				Value: "foo",
+			}},
+			Samples: []prompbmarshal.Sample{{
+				Value: v,
+			}},
+		}},
+	}
+	e.pushData(nil, wr)
+	// Verify the elector doesn't hold references to wr contents.
+	wr.Timeseries[0].Labels[0].Value = "bar"
+	wr.Timeseries[0].Samples[0].Value = -1
+}
+
+func TestElectorSingleLeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	var tp1, tp2 testPusher
+	e1 := newTestElector(t, path, "replica-1", &tp1)
+	e2 := newTestElector(t, path, "replica-2", &tp2)
+
+	now := time.Now()
+	e1.tick(now)
+	e2.tick(now)
+	if !e1.isLeader.Load() {
+		t.Fatalf("replica-1 must be the leader")
+	}
+	if e2.isLeader.Load() {
+		t.Fatalf("replica-2 mustn't be the leader")
+	}
+
+	pushSample(e1, 1)
+	pushSample(e2, 1)
+	if samples := tp1.reset(); len(samples) != 1 {
+		t.Fatalf("unexpected samples forwarded by the leader: %v", samples)
+	}
+	if samples := tp2.reset(); len(samples) != 0 {
+		t.Fatalf("unexpected samples forwarded by standby replica: %v", samples)
+	}
+
+	// The leader renews the lease, so the standby replica mustn't take it over.
+	for i := 1; i <= 10; i++ {
+		now = now.Add(2 * time.Second)
+		e1.tick(now)
+		e2.tick(now)
+		if !e1.isLeader.Load() || e2.isLeader.Load() {
+			t.Fatalf("unexpected leadership change at iteration %d", i)
+		}
+	}
+}
+
+func TestElectorFailover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	var tp1, tp2 testPusher
+	e1 := newTestElector(t, path, "replica-1", &tp1)
+	e2 := newTestElector(t, path, "replica-2", &tp2)
+
+	start := time.Now()
+	e1.tick(start)
+	e2.tick(start)
+	if !e1.isLeader.Load() {
+		t.Fatalf("replica-1 must be the leader")
+	}
+
+	// The standby replica buffers the scraped data.
+	pushSample(e2, 1)
+	pushSample(e2, 2)
+
+	// The leader stops renewing the lease. The standby must take it over after the lease duration.
+	now := start.Add(8 * time.Second)
+	e2.tick(now)
+	if e2.isLeader.Load() {
+		t.Fatalf("replica-2 mustn't take over the lease before its expiration")
+	}
+	now = start.Add(10 * time.Second)
+	e2.tick(now)
+	if !e2.isLeader.Load() {
+		t.Fatalf("replica-2 must take over the expired lease")
+	}
+
+	// The buffered data must be forwarded after taking over the lease.
+	samples := tp2.reset()
+	if len(samples) != 2 || samples[0] != 1 || samples[1] != 2 {
+		t.Fatalf("unexpected samples replayed after taking over the lease: %v", samples)
+	}
+	pushSample(e2, 3)
+	if samples := tp2.reset(); len(samples) != 1 || samples[0] != 3 {
+		t.Fatalf("unexpected samples forwarded by the new leader: %v", samples)
+	}
+
+	// The previous leader must switch to standby mode on the next tick.
+	e1.tick(now.Add(time.Second))
+	if e1.isLeader.Load() {
+		t.Fatalf("replica-1 must switch to standby mode")
+	}
+	pushSample(e1, 4)
+	if samples := tp1.reset(); len(samples) != 0 {
+		t.Fatalf("unexpected samples forwarded by standby replica: %v", samples)
+	}
+}
+
+func TestElectorRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	var tp1, tp2 testPusher
+	e1 := newTestElector(t, path, "replica-1", &tp1)
+	e2 := newTestElector(t, path, "replica-2", &tp2)
+
+	now := time.Now()
+	e1.tick(now)
+	e2.tick(now)
+	e1.release()
+	if e1.isLeader.Load() {
+		t.Fatalf("replica-1 mustn't be the leader after releasing the lease")
+	}
+
+	// The standby replica must take over the released lease without waiting for its expiration.
+	e2.tick(now.Add(2 * time.Second))
+	if !e2.isLeader.Load() {
+		t.Fatalf("replica-2 must take over the released lease")
+	}
+}
+
+func TestElectorDropsStaleStandbyData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	var tp testPusher
+	e := newTestElector(t, path, "replica-1", &tp)
+
+	pushSample(e, 1)
+	e.mu.Lock()
+	e.pending[0].pushTime = time.Now().Add(-time.Hour)
+	e.mu.Unlock()
+	pushSample(e, 2)
+
+	e.mu.Lock()
+	n := len(e.pending)
+	e.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("unexpected number of pending requests; got %d; want 1", n)
+	}
+}
+
+func TestNewElectorFailure(t *testing.T) {
+	f := func(leaseDuration, retryPeriod time.Duration) {
+		t.Helper()
+		b, err := newFileBackend(filepath.Join(t.TempDir(), "lease.json"))
+		if err != nil {
+			t.Fatalf("cannot create file backend: %s", err)
+		}
+		if _, err := newElector(b, "foo", leaseDuration, retryPeriod, nil); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(100*time.Millisecond, 10*time.Millisecond)
+	f(10*time.Second, 0)
+	f(10*time.Second, 10*time.Second)
+}
+
+func TestFileBackendLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	fb, err := newFileBackend(path)
+	if err != nil {
+		t.Fatalf("cannot create file backend: %s", err)
+	}
+	readLockOwner := func() string {
+		t.Helper()
+		owner, err := fb.readLockOwner(fb.lockPath)
+		if err != nil {
+			t.Fatalf("cannot read lock owner: %s", err)
+		}
+		return owner
+	}
+	lr := &leaseRecord{
+		HolderIdentity: "replica-1",
+	}
+
+	// The lock left by crashed replica must be taken over after staleLockTimeout.
+	if err := os.WriteFile(fb.lockPath, []byte("crashed"), 0644); err != nil {
+		t.Fatalf("cannot write lock file: %s", err)
+	}
+	staleTime := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(fb.lockPath, staleTime, staleTime); err != nil {
+		t.Fatalf("cannot update lock file modification time: %s", err)
+	}
+	if err := fb.create(lr); err != nil {
+		t.Fatalf("cannot create lease: %s", err)
+	}
+	if _, err := os.Stat(fb.lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the lock file must be removed after the lease update; got %v", err)
+	}
+
+	// The lock of another owner mustn't be removed, even if it replaced the stale lock.
+	if err := os.WriteFile(fb.lockPath, []byte("replica-2"), 0644); err != nil {
+		t.Fatalf("cannot write lock file: %s", err)
+	}
+	if err := fb.removeLockIfOwned("crashed", "replica-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if owner := readLockOwner(); owner != "replica-2" {
+		t.Fatalf("unexpected lock owner; got %q; want %q", owner, "replica-2")
+	}
+	if err := os.Remove(fb.lockPath); err != nil {
+		t.Fatalf("cannot remove lock file: %s", err)
+	}
+
+	// The lease mustn't be updated if the lock has been taken over by another replica.
+	err = fb.withLock(func(lockOwner string) error {
+		if err := os.WriteFile(fb.lockPath, []byte("replica-2"), 0644); err != nil {
+			t.Fatalf("cannot write lock file: %s", err)
+		}
+		return fb.write(lockOwner, &fileLease{
+			Version: 2,
+			Record:  *lr,
+		})
+	})
+	if !errors.Is(err, errLeaseConflict) {
+		t.Fatalf("unexpected error; got %v; want %v", err, errLeaseConflict)
+	}
+	if owner := readLockOwner(); owner != "replica-2" {
+		t.Fatalf("unexpected lock owner; got %q; want %q", owner, "replica-2")
+	}
+	_, version, err := fb.get()
+	if err != nil {
+		t.Fatalf("cannot read lease: %s", err)
+	}
+	if version != "1" {
+		t.Fatalf("unexpected lease version; got %s; want 1", version)
+	}
+}
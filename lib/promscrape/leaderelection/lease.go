@@ -0,0 +1,48 @@
+package leaderelection
+
+import (
+	"errors"
+	"time"
+)
+
+// leaseRecord holds the state of the lease shared among vmagent replicas.
+//
+// It mirrors the spec of Kubernetes Lease object.
+// See https://kubernetes.io/docs/reference/kubernetes-api/cluster-resources/lease-v1/
+type leaseRecord struct {
+	HolderIdentity       string    `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          time.Time `json:"acquireTime"`
+	RenewTime            time.Time `json:"renewTime"`
+	LeaseTransitions     int       `json:"leaseTransitions"`
+}
+
+// equal returns true if lr and other are equal.
+func (lr *leaseRecord) equal(other *leaseRecord) bool {
+	return lr.HolderIdentity == other.HolderIdentity && lr.LeaseDurationSeconds == other.LeaseDurationSeconds &&
+		lr.AcquireTime.Equal(other.AcquireTime) && lr.RenewTime.Equal(other.RenewTime) && lr.LeaseTransitions == other.LeaseTransitions
+}
+
+// errLeaseConflict is returned by leaseBackend when the lease has been modified concurrently by another replica.
+var errLeaseConflict = errors.New("the lease has been modified concurrently")
+
+// leaseBackend is a storage for leaseRecord shared among vmagent replicas.
+type leaseBackend interface {
+	// get returns the current lease record and its opaque version.
+	//
+	// nil record is returned if the lease doesn't exist yet.
+	get() (*leaseRecord, string, error)
+
+	// create creates the lease with the given lr.
+	//
+	// errLeaseConflict is returned if the lease already exists.
+	create(lr *leaseRecord) error
+
+	// update replaces the lease with lr if the lease version matches the given version.
+	//
+	// errLeaseConflict is returned if the version doesn't match.
+	update(lr *leaseRecord, version string) error
+
+	// String returns human-readable description of the backend.
+	String() string
+}
@@ -0,0 +1,164 @@
+package probe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config represents `probe` section of `scrape_config`.
+//
+// Targets of the scrape_config with `probe` section are probed directly instead of being scraped.
+// This mimics the most frequently used functionality of https://github.com/prometheus/blackbox_exporter .
+type Config struct {
+	// Prober is the probe type. Supported values: http, tcp and dns.
+	Prober string `yaml:"prober"`
+
+	HTTP *HTTPConfig `yaml:"http,omitempty"`
+	TCP  *TCPConfig  `yaml:"tcp,omitempty"`
+	DNS  *DNSConfig  `yaml:"dns,omitempty"`
+}
+
+// HTTPConfig represents `http` section of the probe config.
+type HTTPConfig struct {
+	Method                     string            `yaml:"method,omitempty"`
+	Headers                    map[string]string `yaml:"headers,omitempty"`
+	Body                       string            `yaml:"body,omitempty"`
+	ValidStatusCodes           []int             `yaml:"valid_status_codes,omitempty"`
+	FailIfSSL                  bool              `yaml:"fail_if_ssl,omitempty"`
+	FailIfNotSSL               bool              `yaml:"fail_if_not_ssl,omitempty"`
+	FailIfBodyMatchesRegexp    []string          `yaml:"fail_if_body_matches_regexp,omitempty"`
+	FailIfBodyNotMatchesRegexp []string          `yaml:"fail_if_body_not_matches_regexp,omitempty"`
+}
+
+// TCPConfig represents `tcp` section of the probe config.
+type TCPConfig struct {
+	// TLS enables TLS handshake after the connection is established.
+	//
+	// TLS settings are taken from `tls_config` section of the scrape_config.
+	TLS bool `yaml:"tls,omitempty"`
+
+	QueryResponse []QueryResponse `yaml:"query_response,omitempty"`
+}
+
+// QueryResponse represents a single query-response step for the tcp probe.
+type QueryResponse struct {
+	// Send is sent to the target. A newline is appended to it.
+	Send string `yaml:"send,omitempty"`
+
+	// Expect is a regexp, which must match a line read from the target.
+	Expect string `yaml:"expect,omitempty"`
+}
+
+// DNSConfig represents `dns` section of the probe config.
+type DNSConfig struct {
+	QueryName string `yaml:"query_name"`
+
+	// QueryType is the DNS record type to query. Supported values: A, AAAA, CNAME, MX, NS and TXT. By default A is used.
+	QueryType string `yaml:"query_type,omitempty"`
+
+	// Transport is the transport to use for DNS queries. Supported values: udp and tcp. By default udp is used.
+	Transport string `yaml:"transport,omitempty"`
+
+	FailIfAnswerMatches      []string `yaml:"fail_if_answer_matches_regexp,omitempty"`
+	FailIfNoneAnswersMatches []string `yaml:"fail_if_none_answers_matches_regexp,omitempty"`
+}
+
+// ParsedConfig is parsed and validated Config.
+type ParsedConfig struct {
+	cfg *Config
+
+	failIfBodyMatches    []*regexp.Regexp
+	failIfBodyNotMatches []*regexp.Regexp
+	expects              []*regexp.Regexp
+	failIfAnswerMatches  []*regexp.Regexp
+	failIfNoneMatches    []*regexp.Regexp
+}
+
+// Parse parses and validates cfg.
+func (cfg *Config) Parse() (*ParsedConfig, error) {
+	pc := &ParsedConfig{
+		cfg: cfg,
+	}
+	var err error
+	switch cfg.Prober {
+	case "http":
+		hc := cfg.HTTP
+		if hc == nil {
+			hc = &HTTPConfig{}
+		}
+		if pc.failIfBodyMatches, err = compileRegexps(hc.FailIfBodyMatchesRegexp); err != nil {
+			return nil, fmt.Errorf("cannot parse `fail_if_body_matches_regexp`: %w", err)
+		}
+		if pc.failIfBodyNotMatches, err = compileRegexps(hc.FailIfBodyNotMatchesRegexp); err != nil {
+			return nil, fmt.Errorf("cannot parse `fail_if_body_not_matches_regexp`: %w", err)
+		}
+	case "tcp":
+		if tc := cfg.TCP; tc != nil {
+			for i, qr := range tc.QueryResponse {
+				var re *regexp.Regexp
+				if qr.Expect != "" {
+					if re, err = regexp.Compile(qr.Expect); err != nil {
+						return nil, fmt.Errorf("cannot parse `expect` at `query_response` #%d: %w", i+1, err)
+					}
+				}
+				pc.expects = append(pc.expects, re)
+			}
+		}
+	case "dns":
+		dc := cfg.DNS
+		if dc == nil || dc.QueryName == "" {
+			return nil, fmt.Errorf("missing `query_name` in `dns` section")
+		}
+		switch strings.ToUpper(dc.QueryType) {
+		case "", "A", "AAAA", "CNAME", "MX", "NS", "TXT":
+		default:
+			return nil, fmt.Errorf("unsupported `query_type`: %q; supported values: A, AAAA, CNAME, MX, NS, TXT", dc.QueryType)
+		}
+		switch dc.Transport {
+		case "", "udp", "tcp":
+		default:
+			return nil, fmt.Errorf("unsupported `transport`: %q; supported values: udp, tcp", dc.Transport)
+		}
+		if pc.failIfAnswerMatches, err = compileRegexps(dc.FailIfAnswerMatches); err != nil {
+			return nil, fmt.Errorf("cannot parse `fail_if_answer_matches_regexp`: %w", err)
+		}
+		if pc.failIfNoneMatches, err = compileRegexps(dc.FailIfNoneAnswersMatches); err != nil {
+			return nil, fmt.Errorf("cannot parse `fail_if_none_answers_matches_regexp`: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported `prober`: %q; supported values: http, tcp, dns", cfg.Prober)
+	}
+	return pc, nil
+}
+
+// Prober returns the prober type for pc.
+func (pc *ParsedConfig) Prober() string {
+	return pc.cfg.Prober
+}
+
+// String returns human-readable representation for pc.
+func (pc *ParsedConfig) String() string {
+	if pc == nil {
+		return ""
+	}
+	data, err := yaml.Marshal(pc.cfg)
+	if err != nil {
+		return fmt.Sprintf("cannot marshal probe config: %s", err)
+	}
+	return string(data)
+}
+
+func compileRegexps(exprs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile regexp %q: %w", expr, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
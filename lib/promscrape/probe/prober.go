@@ -0,0 +1,390 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// Prober probes a single target according to ParsedConfig.
+type Prober struct {
+	ctx         context.Context
+	pc          *ParsedConfig
+	target      string
+	timeout     time.Duration
+	maxBodySize int64
+	ac          *promauth.Config
+	hc          *http.Client
+
+	// dial is used for establishing connections to http and tcp targets.
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// setProxyHeaders sets headers for the direct http proxy connection.
+	setProxyHeaders func(req *http.Request) error
+}
+
+// NewProber returns new Prober for the given target.
+//
+// target must be an url for http prober and host:port for tcp and dns probers.
+// ac is used for setting auth headers and TLS config for the probes.
+// http and tcp probes are performed via proxyURL if it is set, while proxyAC is used for proxy auth.
+// maxBodySize limits the size of the response body read by http prober.
+func NewProber(ctx context.Context, pc *ParsedConfig, target string, timeout time.Duration, ac *promauth.Config,
+	proxyURL *proxy.URL, proxyAC *promauth.Config, denyRedirects bool, maxBodySize int64) (*Prober, error) {
+	var d net.Dialer
+	p := &Prober{
+		ctx:         ctx,
+		pc:          pc,
+		target:      target,
+		timeout:     timeout,
+		maxBodySize: maxBodySize,
+		ac:          ac,
+		dial:        d.DialContext,
+		setProxyHeaders: func(_ *http.Request) error {
+			return nil
+		},
+	}
+	transportAC := ac
+	var proxyURLFunc func(*http.Request) (*url.URL, error)
+	if proxyURL != nil {
+		if pc.cfg.Prober == "http" && strings.HasPrefix(target, "http://") {
+			// Use direct http proxy connection for http targets in the same way as for regular scrapes.
+			if proxyURL.URL.Scheme == "https" {
+				transportAC = proxyAC
+			}
+			proxyURLFunc = http.ProxyURL(proxyURL.URL)
+			p.setProxyHeaders = func(req *http.Request) error {
+				return proxyURL.SetHeaders(proxyAC, req)
+			}
+		} else {
+			// Use HTTP CONNECT or socks5 proxy tunnel.
+			dial, err := proxyURL.NewDialFunc(proxyAC)
+			if err != nil {
+				return nil, fmt.Errorf("cannot create dialer for proxy_url=%q connection: %w", proxyURL, err)
+			}
+			p.dial = dial
+		}
+	}
+	if pc.cfg.Prober == "http" {
+		p.hc = &http.Client{
+			Transport: transportAC.NewRoundTripper(&http.Transport{
+				Proxy:               proxyURLFunc,
+				DialContext:         p.dial,
+				TLSHandshakeTimeout: 10 * time.Second,
+				DisableKeepAlives:   true,
+			}),
+		}
+		if denyRedirects {
+			p.hc.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
+	}
+	return p, nil
+}
+
+// ReadData probes the target and writes the results in Prometheus text exposition format to dst.
+//
+// Probe failures are reported via probe_success=0 metric like blackbox_exporter does,
+// so the returned error is non-nil only if the Prober context is canceled.
+func (p *Prober) ReadData(dst *bytesutil.ByteBuffer) error {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	w := &metricsWriter{
+		dst: dst.B,
+	}
+	startTime := time.Now()
+	var err error
+	switch p.pc.cfg.Prober {
+	case "http":
+		err = p.probeHTTP(ctx, w)
+	case "tcp":
+		err = p.probeTCP(ctx, w)
+	case "dns":
+		err = p.probeDNS(ctx, w)
+	default:
+		err = fmt.Errorf("unsupported prober %q", p.pc.cfg.Prober)
+	}
+	if ctxErr := p.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	w.add("probe_duration_seconds", time.Since(startTime).Seconds())
+	w.add("probe_success", b2f(err == nil))
+	dst.B = w.dst
+	return nil
+}
+
+func (p *Prober) probeHTTP(ctx context.Context, w *metricsWriter) error {
+	hc := p.pc.cfg.HTTP
+	if hc == nil {
+		hc = &HTTPConfig{}
+	}
+	method := hc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if hc.Body != "" {
+		body = strings.NewReader(hc.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.target, body)
+	if err != nil {
+		return fmt.Errorf("cannot create request for %q: %w", p.target, err)
+	}
+	req.Header.Set("User-Agent", "vm_promscrape")
+	for k, v := range hc.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if err := p.ac.SetHeaders(req, true); err != nil {
+		return fmt.Errorf("cannot set request headers for %q: %w", p.target, err)
+	}
+	if err := p.setProxyHeaders(req); err != nil {
+		return fmt.Errorf("cannot set proxy request headers for %q: %w", p.target, err)
+	}
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot perform request to %q: %w", p.target, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.maxBodySize))
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read response body from %q: %w", p.target, err)
+	}
+
+	w.add("probe_http_status_code", float64(resp.StatusCode))
+	w.add("probe_http_content_length", float64(resp.ContentLength))
+	w.add("probe_http_uncompressed_body_length", float64(len(data)))
+	isSSL := resp.TLS != nil
+	w.add("probe_http_ssl", b2f(isSSL))
+	if isSSL {
+		w.add("probe_ssl_earliest_cert_expiry", getEarliestCertExpiry(resp.TLS))
+	}
+	failedDueToRegex := slices.ContainsFunc(p.pc.failIfBodyMatches, func(re *regexp.Regexp) bool {
+		return re.Match(data)
+	}) || slices.ContainsFunc(p.pc.failIfBodyNotMatches, func(re *regexp.Regexp) bool {
+		return !re.Match(data)
+	})
+	w.add("probe_failed_due_to_regex", b2f(failedDueToRegex))
+
+	if !isValidStatusCode(resp.StatusCode, hc.ValidStatusCodes) {
+		return fmt.Errorf("unexpected status code %d returned from %q", resp.StatusCode, p.target)
+	}
+	if isSSL && hc.FailIfSSL {
+		return fmt.Errorf("the response from %q is received over TLS, while `fail_if_ssl` is set", p.target)
+	}
+	if !isSSL && hc.FailIfNotSSL {
+		return fmt.Errorf("the response from %q isn't received over TLS, while `fail_if_not_ssl` is set", p.target)
+	}
+	if failedDueToRegex {
+		return fmt.Errorf("the response body from %q doesn't match the configured regexps", p.target)
+	}
+	return nil
+}
+
+func isValidStatusCode(statusCode int, validStatusCodes []int) bool {
+	if len(validStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(validStatusCodes, statusCode)
+}
+
+func (p *Prober) probeTCP(ctx context.Context, w *metricsWriter) error {
+	tc := p.pc.cfg.TCP
+	if tc == nil {
+		tc = &TCPConfig{}
+	}
+	conn, err := p.dial(ctx, "tcp", p.target)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", p.target, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("cannot set deadline for connection to %q: %w", p.target, err)
+		}
+	}
+	if tc.TLS {
+		tlsCfg, err := p.ac.GetTLSConfig()
+		if err != nil {
+			return fmt.Errorf("cannot initialize TLS config for %q: %w", p.target, err)
+		}
+		tlsCfg = tlsCfg.Clone()
+		if tlsCfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(p.target)
+			if err != nil {
+				return fmt.Errorf("cannot parse host from %q: %w", p.target, err)
+			}
+			tlsCfg.ServerName = host
+		}
+		tlsConn := tls.Client(conn, tlsCfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("cannot perform TLS handshake with %q: %w", p.target, err)
+		}
+		state := tlsConn.ConnectionState()
+		w.add("probe_ssl_earliest_cert_expiry", getEarliestCertExpiry(&state))
+		conn = tlsConn
+	}
+
+	br := bufio.NewReader(conn)
+	for i, qr := range tc.QueryResponse {
+		if re := p.pc.expects[i]; re != nil {
+			if err := expectLine(br, re); err != nil {
+				w.add("probe_failed_due_to_regex", 1)
+				return fmt.Errorf("unexpected response from %q at `query_response` #%d: %w", p.target, i+1, err)
+			}
+		}
+		if qr.Send != "" {
+			if _, err := io.WriteString(conn, qr.Send+"\n"); err != nil {
+				return fmt.Errorf("cannot send data to %q at `query_response` #%d: %w", p.target, i+1, err)
+			}
+		}
+	}
+	if len(tc.QueryResponse) > 0 {
+		w.add("probe_failed_due_to_regex", 0)
+	}
+	return nil
+}
+
+// expectLine reads lines from br until one of them matches re.
+func expectLine(br *bufio.Reader, re *regexp.Regexp) error {
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 && re.MatchString(strings.TrimRight(line, "\r\n")) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot find line matching %q: %w", re, err)
+		}
+	}
+}
+
+func (p *Prober) probeDNS(ctx context.Context, w *metricsWriter) error {
+	dc := p.pc.cfg.DNS
+	transport := dc.Transport
+	if transport == "" {
+		transport = "udp"
+	}
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, transport, p.target)
+		},
+	}
+	startTime := time.Now()
+	answers, err := lookupDNS(ctx, r, dc.QueryName, strings.ToUpper(dc.QueryType))
+	w.add("probe_dns_lookup_time_seconds", time.Since(startTime).Seconds())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %q via %q: %w", dc.QueryName, p.target, err)
+	}
+	w.add("probe_dns_answer_rrs", float64(len(answers)))
+
+	failedDueToRegex := slices.ContainsFunc(p.pc.failIfAnswerMatches, func(re *regexp.Regexp) bool {
+		return slices.ContainsFunc(answers, re.MatchString)
+	}) || slices.ContainsFunc(p.pc.failIfNoneMatches, func(re *regexp.Regexp) bool {
+		return !slices.ContainsFunc(answers, re.MatchString)
+	})
+	w.add("probe_failed_due_to_regex", b2f(failedDueToRegex))
+	if failedDueToRegex {
+		return fmt.Errorf("DNS answers for %q from %q don't match the configured regexps", dc.QueryName, p.target)
+	}
+	return nil
+}
+
+func lookupDNS(ctx context.Context, r *net.Resolver, name, queryType string) ([]string, error) {
+	var answers []string
+	switch queryType {
+	case "", "A", "AAAA":
+		network := "ip4"
+		if queryType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case "MX":
+		mxs, err := r.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = txts
+	default:
+		return nil, fmt.Errorf("unsupported query_type %q", queryType)
+	}
+	return answers, nil
+}
+
+// getEarliestCertExpiry returns the earliest expiration time in unix seconds across the certificates in the peer chain.
+func getEarliestCertExpiry(state *tls.ConnectionState) float64 {
+	earliest := math.Inf(1)
+	for _, cert := range state.PeerCertificates {
+		if t := float64(cert.NotAfter.Unix()); t < earliest {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type metricsWriter struct {
+	dst []byte
+}
+
+func (w *metricsWriter) add(name string, value float64) {
+	w.dst = append(w.dst, name...)
+	w.dst = append(w.dst, ' ')
+	w.dst = strconv.AppendFloat(w.dst, value, 'g', -1, 64)
+	w.dst = append(w.dst, '\n')
+}
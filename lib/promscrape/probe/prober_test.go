@@ -0,0 +1,482 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+func TestConfigParseFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		var cfg Config
+		if err := yaml.UnmarshalStrict([]byte(data), &cfg); err != nil {
+			t.Fatalf("cannot unmarshal config: %s", err)
+		}
+		if _, err := cfg.Parse(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing prober
+	f(`{}`)
+
+	// unsupported prober
+	f(`prober: icmp`)
+
+	// invalid regexps
+	f(`
+prober: http
+http:
+  fail_if_body_matches_regexp: ["("]
+`)
+	f(`
+prober: tcp
+tcp:
+  query_response:
+  - expect: "("
+`)
+
+	// invalid dns configs
+	f(`prober: dns`)
+	f(`
+prober: dns
+dns:
+  query_name: foo
+  query_type: SOA
+`)
+	f(`
+prober: dns
+dns:
+  query_name: foo
+  transport: quic
+`)
+}
+
+func TestProberHTTP(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Foo") != "bar" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte("hello world"))
+	}))
+	defer s.Close()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	f := func(data, target string, resultExpected map[string]float64) {
+		t.Helper()
+		ac := newTestAuthConfig(t)
+		checkProbe(t, data, target, ac, resultExpected)
+	}
+
+	f(`
+prober: http
+http:
+  headers:
+    X-Foo: bar
+`, s.URL, map[string]float64{
+		"probe_http_status_code":              200,
+		"probe_http_content_length":           11,
+		"probe_http_uncompressed_body_length": 11,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       1,
+	})
+
+	// unexpected status code
+	f(`prober: http`, s.URL, map[string]float64{
+		"probe_http_status_code":              400,
+		"probe_http_content_length":           11,
+		"probe_http_uncompressed_body_length": 11,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       0,
+	})
+
+	// valid_status_codes and regexps
+	f(`
+prober: http
+http:
+  valid_status_codes: [400]
+  fail_if_body_not_matches_regexp: ["world"]
+`, s.URL, map[string]float64{
+		"probe_http_status_code":              400,
+		"probe_http_content_length":           11,
+		"probe_http_uncompressed_body_length": 11,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       1,
+	})
+	f(`
+prober: http
+http:
+  valid_status_codes: [400]
+  fail_if_body_matches_regexp: ["world"]
+`, s.URL, map[string]float64{
+		"probe_http_status_code":              400,
+		"probe_http_content_length":           11,
+		"probe_http_uncompressed_body_length": 11,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           1,
+		"probe_success":                       0,
+	})
+
+	// fail_if_not_ssl
+	f(`
+prober: http
+http:
+  valid_status_codes: [400]
+  fail_if_not_ssl: true
+`, s.URL, map[string]float64{
+		"probe_http_status_code":              400,
+		"probe_http_content_length":           11,
+		"probe_http_uncompressed_body_length": 11,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       0,
+	})
+
+	// TLS
+	f(`
+prober: http
+http:
+  fail_if_not_ssl: true
+`, ts.URL, map[string]float64{
+		"probe_http_status_code":              200,
+		"probe_http_content_length":           5,
+		"probe_http_uncompressed_body_length": 5,
+		"probe_http_ssl":                      1,
+		"probe_ssl_earliest_cert_expiry":      float64(ts.Certificate().NotAfter.Unix()),
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       1,
+	})
+
+	// connection error
+	f(`prober: http`, "http://127.0.0.1:1/", map[string]float64{
+		"probe_success": 0,
+	})
+}
+
+func TestProberTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start listener: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				c.Write([]byte("220 ready\r\n"))
+				br := bufio.NewReader(c)
+				line, err := br.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == "PING\n" {
+					c.Write([]byte("PONG\n"))
+				}
+			}()
+		}
+	}()
+	target := ln.Addr().String()
+
+	f := func(data string, resultExpected map[string]float64) {
+		t.Helper()
+		checkProbe(t, data, target, newTestAuthConfig(t), resultExpected)
+	}
+
+	f(`prober: tcp`, map[string]float64{
+		"probe_success": 1,
+	})
+	f(`
+prober: tcp
+tcp:
+  query_response:
+  - expect: "^220"
+  - send: PING
+  - expect: PONG
+`, map[string]float64{
+		"probe_failed_due_to_regex": 0,
+		"probe_success":             1,
+	})
+	f(`
+prober: tcp
+tcp:
+  query_response:
+  - expect: "^220"
+  - send: FOO
+  - expect: PONG
+`, map[string]float64{
+		"probe_failed_due_to_regex": 1,
+		"probe_success":             0,
+	})
+
+	// TLS handshake failure
+	f(`
+prober: tcp
+tcp:
+  tls: true
+`, map[string]float64{
+		"probe_success": 0,
+	})
+}
+
+func TestProberDNS(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start udp listener: %s", err)
+	}
+	defer pc.Close()
+	go serveTestDNS(pc)
+	target := pc.LocalAddr().String()
+
+	f := func(data string, resultExpected map[string]float64) {
+		t.Helper()
+		checkProbe(t, data, target, newTestAuthConfig(t), resultExpected)
+	}
+
+	f(`
+prober: dns
+dns:
+  query_name: example.com
+`, map[string]float64{
+		"probe_dns_answer_rrs":      1,
+		"probe_failed_due_to_regex": 0,
+		"probe_success":             1,
+	})
+	f(`
+prober: dns
+dns:
+  query_name: example.com
+  fail_if_none_answers_matches_regexp: ["^10\\."]
+`, map[string]float64{
+		"probe_dns_answer_rrs":      1,
+		"probe_failed_due_to_regex": 1,
+		"probe_success":             0,
+	})
+
+	// empty answer
+	f(`
+prober: dns
+dns:
+  query_name: example.com
+  query_type: AAAA
+`, map[string]float64{
+		"probe_success": 0,
+	})
+}
+
+// serveTestDNS responds to A queries with 127.0.0.1 and returns empty answers to other queries.
+func serveTestDNS(pc net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		if len(req) < 12 {
+			continue
+		}
+		// Find the end of the question section.
+		i := 12
+		for i < len(req) && req[i] != 0 {
+			i += int(req[i]) + 1
+		}
+		i += 5
+		if i > len(req) {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(req[i-4:])
+
+		resp := append([]byte{}, req[:2]...)
+		resp = append(resp, 0x81, 0x80, 0, 1)
+		if qtype == 1 {
+			resp = append(resp, 0, 1)
+		} else {
+			resp = append(resp, 0, 0)
+		}
+		resp = append(resp, 0, 0, 0, 0)
+		resp = append(resp, req[12:i]...)
+		if qtype == 1 {
+			resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+		}
+		_, _ = pc.WriteTo(resp, addr)
+	}
+}
+
+func TestProberProxy(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	var proxyMethods []string
+	var proxyMethodsLock sync.Mutex
+	ps := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyMethodsLock.Lock()
+		proxyMethods = append(proxyMethods, r.Method)
+		proxyMethodsLock.Unlock()
+
+		if r.Method != http.MethodConnect {
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body) //nolint
+			return
+		}
+		backendConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer backendConn.Close()
+		w.WriteHeader(http.StatusOK)
+		clientConn, clientBuf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer clientConn.Close()
+		go io.Copy(backendConn, clientBuf) //nolint
+		io.Copy(clientConn, backendConn)   //nolint
+	}))
+	defer ps.Close()
+	proxyURL := proxy.MustNewURL(ps.URL)
+
+	f := func(data, target, proxyMethodExpected string, resultExpected map[string]float64) {
+		t.Helper()
+		proxyMethodsLock.Lock()
+		proxyMethods = nil
+		proxyMethodsLock.Unlock()
+
+		ac := newTestAuthConfig(t)
+		checkProbeWithProxy(t, data, target, ac, proxyURL, resultExpected)
+
+		proxyMethodsLock.Lock()
+		defer proxyMethodsLock.Unlock()
+		if len(proxyMethods) != 1 || proxyMethods[0] != proxyMethodExpected {
+			t.Fatalf("unexpected requests to proxy; got %q; want [%q]", proxyMethods, proxyMethodExpected)
+		}
+	}
+
+	// http target is requested via direct proxy connection
+	f(`prober: http`, s.URL, http.MethodGet, map[string]float64{
+		"probe_http_status_code":              200,
+		"probe_http_content_length":           5,
+		"probe_http_uncompressed_body_length": 5,
+		"probe_http_ssl":                      0,
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       1,
+	})
+
+	// https target is requested via proxy tunnel
+	f(`prober: http`, ts.URL, http.MethodConnect, map[string]float64{
+		"probe_http_status_code":              200,
+		"probe_http_content_length":           5,
+		"probe_http_uncompressed_body_length": 5,
+		"probe_http_ssl":                      1,
+		"probe_ssl_earliest_cert_expiry":      float64(ts.Certificate().NotAfter.Unix()),
+		"probe_failed_due_to_regex":           0,
+		"probe_success":                       1,
+	})
+
+	// tcp target is connected via proxy tunnel
+	f(`prober: tcp`, strings.TrimPrefix(s.URL, "http://"), http.MethodConnect, map[string]float64{
+		"probe_success": 1,
+	})
+}
+
+func checkProbe(t *testing.T, data, target string, ac *promauth.Config, resultExpected map[string]float64) {
+	t.Helper()
+	checkProbeWithProxy(t, data, target, ac, nil, resultExpected)
+}
+
+func checkProbeWithProxy(t *testing.T, data, target string, ac *promauth.Config, proxyURL *proxy.URL, resultExpected map[string]float64) {
+	t.Helper()
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict([]byte(data), &cfg); err != nil {
+		t.Fatalf("cannot unmarshal config: %s", err)
+	}
+	pc, err := cfg.Parse()
+	if err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	p, err := NewProber(context.Background(), pc, target, 5*time.Second, ac, proxyURL, ac, false, 1024)
+	if err != nil {
+		t.Fatalf("cannot create prober: %s", err)
+	}
+	var bb bytesutil.ByteBuffer
+	if err := p.ReadData(&bb); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(string(bb.B)), "\n") {
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("unexpected line %q", line)
+		}
+		if strings.HasSuffix(name, "_seconds") {
+			// Skip durations, since they are unpredictable.
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("cannot parse value at line %q: %s", line, err)
+		}
+		result[name] = v
+	}
+	if len(result) != len(resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%v\nwant\n%v", result, resultExpected)
+	}
+	for k, v := range resultExpected {
+		if result[k] != v {
+			t.Fatalf("unexpected value for %s; got %v; want %v\nfull result:\n%s", k, result[k], v, bb.B)
+		}
+	}
+}
+
+func newTestAuthConfig(t *testing.T) *promauth.Config {
+	t.Helper()
+	opts := promauth.Options{
+		TLSConfig: &promauth.TLSConfig{
+			InsecureSkipVerify: true,
+		},
+	}
+	ac, err := opts.NewConfig()
+	if err != nil {
+		t.Fatalf("cannot create auth config: %s", err)
+	}
+	return ac
+}
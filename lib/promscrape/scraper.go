@@ -5,6 +5,8 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/VictoriaMetrics/metrics"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
@@ -24,6 +27,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/docker"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/dockerswarm"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ec2"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/etcd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/eureka"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/hetzner"
@@ -37,6 +41,9 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/puppetdb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/vultr"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/yandexcloud"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/zookeeper"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/leaderelection"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/probe"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 )
 
@@ -65,8 +72,12 @@ func CheckConfig() error {
 // Init initializes Prometheus scraper with config from the `-promscrape.config`.
 //
 // Scraped data is passed to pushData.
+//
+// If -promscrape.leaderElection.backend is set, then scraped data is passed to pushData only while
+// the current replica is the leader.
 func Init(pushData func(at *auth.Token, wr *prompbmarshal.WriteRequest)) {
 	mustInitClusterMemberID()
+	pushData = leaderelection.MustStart(pushData)
 	globalStopChan = make(chan struct{})
 	scraperWG.Add(1)
 	go func() {
@@ -79,6 +90,7 @@ func Init(pushData func(at *auth.Token, wr *prompbmarshal.WriteRequest)) {
 func Stop() {
 	close(globalStopChan)
 	scraperWG.Wait()
+	leaderelection.Stop()
 }
 
 var (
@@ -136,6 +148,7 @@ func runScraper(configFile string, pushData func(at *auth.Token, wr *prompbmarsh
 	scs.add("docker_sd_configs", *docker.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getDockerSDScrapeWork(swsPrev) })
 	scs.add("dockerswarm_sd_configs", *dockerswarm.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getDockerSwarmSDScrapeWork(swsPrev) })
 	scs.add("ec2_sd_configs", *ec2.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getEC2SDScrapeWork(swsPrev) })
+	scs.add("etcd_sd_configs", *etcd.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getEtcdSDScrapeWork(swsPrev) })
 	scs.add("eureka_sd_configs", *eureka.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getEurekaSDScrapeWork(swsPrev) })
 	scs.add("file_sd_configs", *fileSDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getFileSDScrapeWork(swsPrev) })
 	scs.add("gce_sd_configs", *gce.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getGCESDScrapeWork(swsPrev) })
@@ -144,10 +157,12 @@ func runScraper(configFile string, pushData func(at *auth.Token, wr *prompbmarsh
 	scs.add("kubernetes_sd_configs", *kubernetes.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getKubernetesSDScrapeWork(swsPrev) })
 	scs.add("kuma_sd_configs", *kuma.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getKumaSDScrapeWork(swsPrev) })
 	scs.add("marathon_sd_configs", *marathon.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getMarathonSDScrapeWork(swsPrev) })
+	scs.add("nerve_sd_configs", *zookeeper.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getNerveSDScrapeWork(swsPrev) })
 	scs.add("nomad_sd_configs", *nomad.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getNomadSDScrapeWork(swsPrev) })
 	scs.add("openstack_sd_configs", *openstack.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getOpenStackSDScrapeWork(swsPrev) })
 	scs.add("ovhcloud_sd_configs", *ovhcloud.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getOVHCloudSDScrapeWork(swsPrev) })
 	scs.add("puppetdb_sd_configs", *puppetdb.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getPuppetDBSDScrapeWork(swsPrev) })
+	scs.add("serverset_sd_configs", *zookeeper.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getServersetSDScrapeWork(swsPrev) })
 	scs.add("vultr_sd_configs", *vultr.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getVultrSDScrapeWork(swsPrev) })
 	scs.add("yandexcloud_sd_configs", *yandexcloud.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getYandexCloudSDScrapeWork(swsPrev) })
 	scs.add("static_configs", 0, func(cfg *Config, _ []*ScrapeWork) []*ScrapeWork { return cfg.getStaticScrapeWork() })
@@ -457,13 +472,49 @@ func newScraper(sw *ScrapeWork, group string, pushData func(at *auth.Token, wr *
 		cancel:    cancel,
 		stoppedCh: make(chan struct{}),
 	}
+	sc.sw.Config = sw
+	sc.sw.ScrapeGroup = group
+	sc.sw.PushData = pushData
+	if sw.ProbeConfig != nil {
+		target, err := getProbeTarget(sw)
+		if err != nil {
+			return nil, err
+		}
+		p, err := probe.NewProber(ctx, sw.ProbeConfig, target, sw.ScrapeTimeout, sw.AuthConfig, sw.ProxyURL, sw.ProxyAuthConfig, sw.DenyRedirects, sw.MaxScrapeSize)
+		if err != nil {
+			return nil, err
+		}
+		sc.sw.ReadData = func(dst *bytesutil.ByteBuffer) (bool, error) {
+			// Probe results are always written in Prometheus text exposition format.
+			return false, p.ReadData(dst)
+		}
+		return sc, nil
+	}
 	c, err := newClient(ctx, sw)
 	if err != nil {
 		return nil, err
 	}
-	sc.sw.Config = sw
-	sc.sw.ScrapeGroup = group
 	sc.sw.ReadData = c.ReadData
-	sc.sw.PushData = pushData
 	return sc, nil
 }
+
+// getProbeTarget returns the target to probe for sw with non-nil ProbeConfig.
+//
+// http prober uses the ScrapeURL as is, while tcp and dns probers use host:port from the ScrapeURL.
+func getProbeTarget(sw *ScrapeWork) (string, error) {
+	prober := sw.ProbeConfig.Prober()
+	if prober == "http" {
+		return sw.ScrapeURL, nil
+	}
+	u, err := url.Parse(sw.ScrapeURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse target url %q: %w", sw.ScrapeURL, err)
+	}
+	if u.Port() == "" {
+		if prober != "dns" {
+			return "", fmt.Errorf("missing port in the target %q for %s prober", u.Host, prober)
+		}
+		return net.JoinHostPort(u.Hostname(), "53"), nil
+	}
+	return u.Host, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/probe"
 )
 
 func TestScraperReload(t *testing.T) {
@@ -79,3 +80,33 @@ scrape_configs:
     - targets:
         - localhost:8429`, true)
 }
+
+func TestGetProbeTarget(t *testing.T) {
+	f := func(prober, scrapeURL, resultExpected string) {
+		t.Helper()
+		sw := &ScrapeWork{
+			ScrapeURL:   scrapeURL,
+			ProbeConfig: mustParseProbeConfig(&probe.Config{Prober: prober, DNS: &probe.DNSConfig{QueryName: "foo"}}),
+		}
+		result, err := getProbeTarget(sw)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+	f("http", "https://foo.bar/health?x=y", "https://foo.bar/health?x=y")
+	f("tcp", "http://foo.bar:25/", "foo.bar:25")
+	f("dns", "http://8.8.8.8/", "8.8.8.8:53")
+	f("dns", "http://8.8.8.8:5353/", "8.8.8.8:5353")
+
+	// missing port for tcp prober
+	sw := &ScrapeWork{
+		ScrapeURL:   "http://foo.bar/",
+		ProbeConfig: mustParseProbeConfig(&probe.Config{Prober: "tcp"}),
+	}
+	if _, err := getProbeTarget(sw); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
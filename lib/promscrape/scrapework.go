@@ -22,6 +22,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/probe"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus/stream"
@@ -121,6 +122,10 @@ type ScrapeWork struct {
 	// The maximum number of metrics to scrape after relabeling.
 	SampleLimit int
 
+	// Optional list of scrape protocols to negotiate with the target in the order of preference.
+	// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+	ScrapeProtocols []string
+
 	// Whether to disable response compression when querying ScrapeURL.
 	DisableCompression bool
 
@@ -143,6 +148,9 @@ type ScrapeWork struct {
 	// See https://docs.victoriametrics.com/vmagent/#prometheus-staleness-markers
 	NoStaleMarkers bool
 
+	// Optional probe config. If set, the target is probed instead of being scraped.
+	ProbeConfig *probe.ParsedConfig
+
 	// The Tenant Info
 	AuthToken *auth.Token
 
@@ -166,13 +174,13 @@ func (sw *ScrapeWork) key() string {
 	key := fmt.Sprintf("JobNameOriginal=%s, ScrapeURL=%s, ScrapeInterval=%s, ScrapeTimeout=%s, HonorLabels=%v, "+
 		"HonorTimestamps=%v, DenyRedirects=%v, Labels=%s, ExternalLabels=%s, MaxScrapeSize=%d, "+
 		"ProxyURL=%s, ProxyAuthConfig=%s, AuthConfig=%s, MetricRelabelConfigs=%q, "+
-		"SampleLimit=%d, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
-		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v",
+		"SampleLimit=%d, ScrapeProtocols=%q, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
+		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v, ProbeConfig=%q",
 		sw.jobNameOriginal, sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels,
 		sw.HonorTimestamps, sw.DenyRedirects, sw.Labels.String(), sw.ExternalLabels.String(), sw.MaxScrapeSize,
 		sw.ProxyURL.String(), sw.ProxyAuthConfig.String(), sw.AuthConfig.String(), sw.MetricRelabelConfigs.String(),
-		sw.SampleLimit, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
-		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers)
+		sw.SampleLimit, sw.ScrapeProtocols, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
+		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers, sw.ProbeConfig.String())
 	return key
 }
 
@@ -186,7 +194,9 @@ type scrapeWork struct {
 	Config *ScrapeWork
 
 	// ReadData is called for reading the scrape response data into dst.
-	ReadData func(dst *bytesutil.ByteBuffer) error
+	//
+	// It must return true if the data is in Prometheus protobuf format. Otherwise the data must be in text exposition format.
+	ReadData func(dst *bytesutil.ByteBuffer) (bool, error)
 
 	// PushData is called for pushing collected data.
 	PushData func(at *auth.Token, wr *prompbmarshal.WriteRequest)
@@ -224,6 +234,12 @@ type scrapeWork struct {
 	// equals to or exceeds -promscrape.minResponseSizeForStreamParse
 	lastScrapeCompressed []byte
 
+	// lastScrapeSeriesHash contains the hash of series from the last response in protobuf format.
+	// It is used for detecting changes in series without converting protobuf responses to text.
+	// It is valid only if lastScrapeIsProtobuf is set.
+	lastScrapeSeriesHash uint64
+	lastScrapeIsProtobuf bool
+
 	// nextErrorLogTime is the timestamp in millisecond when the next scrape error should be logged.
 	nextErrorLogTime int64
 
@@ -246,6 +262,8 @@ func (sw *scrapeWork) loadLastScrape() string {
 }
 
 func (sw *scrapeWork) storeLastScrape(lastScrape []byte) {
+	sw.lastScrapeIsProtobuf = false
+	sw.lastScrapeSeriesHash = 0
 	mustCompress := minResponseSizeForStreamParse.N > 0 && len(lastScrape) >= minResponseSizeForStreamParse.IntN()
 	if mustCompress {
 		sw.lastScrapeCompressed = encoding.CompressZSTDLevel(sw.lastScrapeCompressed[:0], lastScrape, 1)
@@ -409,9 +427,14 @@ func (sw *scrapeWork) needStreamParseMode(responseSize int) bool {
 // getTargetResponse() fetches response from sw target in the same way as when scraping the target.
 func (sw *scrapeWork) getTargetResponse() ([]byte, error) {
 	var bb bytesutil.ByteBuffer
-	if err := sw.ReadData(&bb); err != nil {
+	isProtobuf, err := sw.ReadData(&bb)
+	if err != nil {
 		return nil, err
 	}
+	if isProtobuf {
+		// Return the response in text exposition format, so it could be inspected by humans.
+		return parser.AppendTextFromProtobuf(nil, bb.B)
+	}
 	return bb.B, nil
 }
 
@@ -423,7 +446,7 @@ func (sw *scrapeWork) scrapeInternal(scrapeTimestamp, realTimestamp int64) error
 	// is occupied during parsing of the read response body below.
 	// This also allows measuring the real scrape duration, which doesn't include
 	// the time needed for processing of the read response.
-	err := sw.ReadData(body)
+	isProtobuf, err := sw.ReadData(body)
 
 	// Measure scrape duration.
 	endTimestamp := time.Now().UnixNano() / 1e6
@@ -437,7 +460,11 @@ func (sw *scrapeWork) scrapeInternal(scrapeTimestamp, realTimestamp int64) error
 	// without sacrificing the performance.
 	processScrapedDataConcurrencyLimitCh <- struct{}{}
 
-	if err == nil && sw.needStreamParseMode(len(body.B)) {
+	if err == nil && isProtobuf {
+		// Process response body in Prometheus protobuf format.
+		// It is always processed at once, since protobuf messages cannot be parsed in streaming manner by the text parser.
+		err = sw.processProtobufData(scrapeTimestamp, realTimestamp, body.B, scrapeDurationSeconds)
+	} else if err == nil && sw.needStreamParseMode(len(body.B)) {
 		// Process response body from scrape target in streaming manner.
 		// This case is optimized for targets exposing more than ten thousand of metrics per target,
 		// such as kube-state-metrics.
@@ -526,6 +553,108 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 	return err
 }
 
+// processProtobufData processes the response body in Prometheus protobuf format.
+//
+// The body is parsed directly into rows. Series from the response are converted to text only if they differ
+// from the series at the previous scrape, since the text is needed for staleness tracking.
+func (sw *scrapeWork) processProtobufData(scrapeTimestamp, realTimestamp int64, body []byte, scrapeDurationSeconds float64) error {
+	up := 1
+	wc := writeRequestCtxPool.Get(sw.prevLabelsLen)
+	err := wc.rows.UnmarshalProtobuf(body)
+	if err != nil {
+		wc.rows.Reset()
+		up = 0
+		scrapesFailed.Inc()
+		err = fmt.Errorf("cannot parse protobuf response from %q: %w", sw.Config.ScrapeURL, err)
+	}
+	lastScrape := sw.loadLastScrape()
+	srcRows := wc.rows.Rows
+	seriesHash, areIdenticalSeries := sw.areIdenticalProtobufSeries(srcRows)
+	samplesScraped := len(srcRows)
+	scrapedSamples.Update(float64(samplesScraped))
+	for i := range srcRows {
+		sw.addRowToTimeseries(wc, &srcRows[i], scrapeTimestamp, true)
+	}
+	samplesPostRelabeling := len(wc.writeRequest.Timeseries)
+	if sw.Config.SampleLimit > 0 && samplesPostRelabeling > sw.Config.SampleLimit {
+		wc.resetNoRows()
+		up = 0
+		scrapesSkippedBySampleLimit.Inc()
+		err = fmt.Errorf("the response from %q exceeds sample_limit=%d; "+
+			"either reduce the sample count for the target or increase sample_limit", sw.Config.ScrapeURL, sw.Config.SampleLimit)
+	}
+	var series []byte
+	if !areIdenticalSeries {
+		series = parser.AppendSeries(nil, srcRows)
+	}
+	seriesString := bytesutil.ToUnsafeString(series)
+	responseSize := len(body)
+	if up == 0 {
+		seriesString = ""
+		responseSize = 0
+	}
+	seriesAdded := 0
+	if !areIdenticalSeries {
+		// The returned value for seriesAdded may be bigger than the real number of added series
+		// if some series were removed during relabeling.
+		// This is a trade-off between performance and accuracy.
+		seriesAdded = sw.getSeriesAdded(lastScrape, seriesString)
+	}
+	samplesDropped := 0
+	if sw.seriesLimitExceeded || !areIdenticalSeries {
+		samplesDropped = sw.applySeriesLimit(wc)
+	}
+	am := &autoMetrics{
+		up:                        up,
+		scrapeDurationSeconds:     scrapeDurationSeconds,
+		scrapeResponseSize:        responseSize,
+		samplesScraped:            samplesScraped,
+		samplesPostRelabeling:     samplesPostRelabeling,
+		seriesAdded:               seriesAdded,
+		seriesLimitSamplesDropped: samplesDropped,
+	}
+	sw.addAutoMetrics(am, wc, scrapeTimestamp)
+	sw.pushData(sw.Config.AuthToken, &wc.writeRequest)
+	sw.prevLabelsLen = len(wc.labels)
+	sw.prevBodyLen = len(body)
+	wc.reset()
+	writeRequestCtxPool.Put(wc)
+	if !areIdenticalSeries {
+		// Send stale markers for disappeared metrics with the real scrape timestamp
+		// in order to guarantee that query doesn't return data after this time for the disappeared metrics.
+		sw.sendStaleSeries(lastScrape, seriesString, realTimestamp, false)
+		sw.storeLastScrape(series)
+		sw.lastScrapeIsProtobuf = true
+		sw.lastScrapeSeriesHash = seriesHash
+	}
+	sw.finalizeLastScrape()
+	tsmGlobal.Update(sw, up == 1, realTimestamp, int64(scrapeDurationSeconds*1000), responseSize, samplesScraped, err)
+	return err
+}
+
+// areIdenticalProtobufSeries returns the hash of series from rows and whether these series are identical
+// to the series from the last response in protobuf format.
+func (sw *scrapeWork) areIdenticalProtobufSeries(rows []parser.Row) (uint64, bool) {
+	if sw.Config.NoStaleMarkers && sw.Config.SeriesLimit <= 0 {
+		// Do not spend CPU time on tracking the changes in series if stale markers are disabled.
+		return 0, true
+	}
+	d := xxhash.New()
+	for i := range rows {
+		r := &rows[i]
+		_, _ = d.WriteString(r.Metric)
+		for _, t := range r.Tags {
+			_, _ = d.WriteString("\x00")
+			_, _ = d.WriteString(t.Key)
+			_, _ = d.WriteString("\x00")
+			_, _ = d.WriteString(t.Value)
+		}
+		_, _ = d.WriteString("\n")
+	}
+	h := d.Sum64()
+	return h, sw.lastScrapeIsProtobuf && h == sw.lastScrapeSeriesHash
+}
+
 func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int64, body *bytesutil.ByteBuffer, scrapeDurationSeconds float64) error {
 	samplesScraped := 0
 	samplesPostRelabeling := 0
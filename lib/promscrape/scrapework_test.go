@@ -8,11 +8,13 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+	"github.com/VictoriaMetrics/easyproto"
 )
 
 func TestIsAutoMetric(t *testing.T) {
@@ -91,9 +93,9 @@ func TestScrapeWorkScrapeInternalFailure(t *testing.T) {
 	}
 
 	readDataCalls := 0
-	sw.ReadData = func(_ *bytesutil.ByteBuffer) error {
+	sw.ReadData = func(_ *bytesutil.ByteBuffer) (bool, error) {
 		readDataCalls++
-		return fmt.Errorf("error when reading data")
+		return false, fmt.Errorf("error when reading data")
 	}
 
 	pushDataCalls := 0
@@ -132,10 +134,10 @@ func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
 		sw.Config = cfg
 
 		readDataCalls := 0
-		sw.ReadData = func(dst *bytesutil.ByteBuffer) error {
+		sw.ReadData = func(dst *bytesutil.ByteBuffer) (bool, error) {
 			readDataCalls++
 			dst.B = append(dst.B, data...)
-			return nil
+			return false, nil
 		}
 
 		pushDataCalls := 0
@@ -490,6 +492,104 @@ func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
 	`)
 }
 
+func TestScrapeWorkScrapeInternalProtobuf(t *testing.T) {
+	marshalGauges := func(names ...string) []byte {
+		var dst []byte
+		var m easyproto.Marshaler
+		for i, name := range names {
+			// MetricFamily{name: name, type: GAUGE, metric: [{label: [{name: "job", value: "x"}], gauge: {value: i+1}}]}
+			m.Reset()
+			mm := m.MessageMarshaler()
+			mm.AppendString(1, name)
+			mm.AppendInt32(3, 1)
+			metric := mm.AppendMessage(4)
+			label := metric.AppendMessage(1)
+			label.AppendString(1, "job")
+			label.AppendString(2, "x")
+			metric.AppendMessage(2).AppendDouble(1, float64(i+1))
+			dst = m.MarshalWithLen(dst)
+		}
+		return dst
+	}
+
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeTimeout: time.Second * 42,
+	}
+	var data []byte
+	sw.ReadData = func(dst *bytesutil.ByteBuffer) (bool, error) {
+		dst.B = append(dst.B, data...)
+		return true, nil
+	}
+	var pushed map[string]float64
+	sw.PushData = func(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+		for _, ts := range wr.Timeseries {
+			key := ts.Labels[0].Value
+			if len(ts.Labels) > 1 {
+				key += "{" + ts.Labels[1].Name + "=" + ts.Labels[1].Value + "}"
+			}
+			pushed[key] = ts.Samples[0].Value
+		}
+	}
+
+	f := func(names []string, pushedExpected map[string]float64, staleExpected []string) {
+		t.Helper()
+		data = marshalGauges(names...)
+		pushed = make(map[string]float64)
+		timestamp := int64(123000)
+		if err := sw.scrapeInternal(timestamp, timestamp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		pushedExpected["scrape_response_size_bytes"] = float64(len(data))
+		for k, vExpected := range pushedExpected {
+			if v, ok := pushed[k]; !ok || v != vExpected {
+				t.Fatalf("unexpected value for %s; got %v; want %v; pushed: %v", k, v, vExpected, pushed)
+			}
+		}
+		var stale []string
+		for k, v := range pushed {
+			if decimal.IsStaleNaN(v) {
+				stale = append(stale, k)
+			}
+		}
+		if strings.Join(stale, ",") != strings.Join(staleExpected, ",") {
+			t.Fatalf("unexpected stale series; got %q; want %q", stale, staleExpected)
+		}
+	}
+
+	common.StartUnmarshalWorkers()
+	defer common.StopUnmarshalWorkers()
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	f([]string{"foo", "bar"}, map[string]float64{
+		"foo{job=x}":          1,
+		"bar{job=x}":          2,
+		"up":                  1,
+		"scrape_series_added": 2,
+	}, nil)
+
+	// the same series
+	f([]string{"foo", "bar"}, map[string]float64{
+		"foo{job=x}":          1,
+		"bar{job=x}":          2,
+		"scrape_series_added": 0,
+	}, nil)
+
+	// the disappeared series must be marked as stale
+	f([]string{"foo"}, map[string]float64{
+		"foo{job=x}":          1,
+		"scrape_series_added": 0,
+	}, []string{"bar{job=x}"})
+
+	// the new series
+	f([]string{"foo", "baz"}, map[string]float64{
+		"foo{job=x}":          1,
+		"baz{job=x}":          2,
+		"scrape_series_added": 1,
+	}, nil)
+}
+
 func TestAddRowToTimeseriesNoRelabeling(t *testing.T) {
 	f := func(row string, cfg *ScrapeWork, dataExpected string) {
 		t.Helper()
@@ -74,9 +74,9 @@ vm_tcplistener_read_timeouts_total{name="https", addr=":443"} 12353
 vm_tcplistener_write_calls_total{name="http", addr=":80"} 3996
 vm_tcplistener_write_calls_total{name="https", addr=":443"} 132356
 `
-	readDataFunc := func(dst *bytesutil.ByteBuffer) error {
+	readDataFunc := func(dst *bytesutil.ByteBuffer) (bool, error) {
 		dst.B = append(dst.B, data...)
-		return nil
+		return false, nil
 	}
 	b.ReportAllocs()
 	b.SetBytes(int64(len(data)))
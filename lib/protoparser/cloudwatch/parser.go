@@ -0,0 +1,272 @@
+package cloudwatch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+// Rows contains rows parsed from CloudWatch Metric Streams JSON records delivered via AWS Firehose.
+//
+// See https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch-metric-streams-formats-json.html
+type Rows struct {
+	Rows []Row
+
+	tagsPool    []Tag
+	samplesPool []Sample
+	dataBuf     []byte
+}
+
+// Reset resets rs, so it can be re-used.
+func (rs *Rows) Reset() {
+	clear(rs.Rows)
+	rs.Rows = rs.Rows[:0]
+
+	clear(rs.tagsPool)
+	rs.tagsPool = rs.tagsPool[:0]
+
+	clear(rs.samplesPool)
+	rs.samplesPool = rs.samplesPool[:0]
+
+	rs.dataBuf = rs.dataBuf[:0]
+}
+
+// Row is a single CloudWatch metric stream record.
+type Row struct {
+	// Tags contains labels for all the Samples.
+	Tags []Tag
+
+	// Samples contains sum, count, min and max samples for the record.
+	Samples []Sample
+
+	// Timestamp is the record timestamp in milliseconds.
+	Timestamp int64
+}
+
+// Tag is a label for the Row.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Sample is a single named value for the Row.
+type Sample struct {
+	Name  string
+	Value float64
+}
+
+var jsonParserPool fastjson.ParserPool
+
+// Unmarshal parses Firehose HTTP delivery request from b to rs.
+//
+// The request has the following format:
+//
+//	{
+//	  "requestId": "<uuid-string>",
+//	  "timestamp": <int64-value>,
+//	  "records": [
+//	    {
+//	      "data": "<base64-encoded-payload>"
+//	    }
+//	  ]
+//	}
+//
+// Every decoded "data" field contains newline-delimited CloudWatch metric stream JSON records.
+//
+// rs is valid until the next Unmarshal or Reset call. b can be re-used after returning from Unmarshal.
+func (rs *Rows) Unmarshal(b []byte) error {
+	rs.Reset()
+
+	p := jsonParserPool.Get()
+	defer jsonParserPool.Put(p)
+
+	v, err := p.ParseBytes(b)
+	if err != nil {
+		return fmt.Errorf("cannot parse Firehose request: %w", err)
+	}
+	records := v.GetArray("records")
+	for i, record := range records {
+		data := record.GetStringBytes("data")
+		if data == nil {
+			return fmt.Errorf("missing `data` field at records[%d]", i)
+		}
+		dataBufLen := len(rs.dataBuf)
+		rs.dataBuf, err = base64.StdEncoding.AppendDecode(rs.dataBuf, data)
+		if err != nil {
+			return fmt.Errorf("cannot decode base64-encoded `data` field at records[%d]: %w", i, err)
+		}
+		if err := rs.unmarshalRecords(rs.dataBuf[dataBufLen:]); err != nil {
+			return fmt.Errorf("cannot parse CloudWatch metric stream records at records[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalRecords parses newline-delimited CloudWatch metric stream JSON records from b to rs.
+//
+// rs is valid until the next UnmarshalRecords, Unmarshal or Reset call. b can be re-used after returning from UnmarshalRecords.
+func (rs *Rows) UnmarshalRecords(b []byte) error {
+	rs.Reset()
+	return rs.unmarshalRecords(b)
+}
+
+func (rs *Rows) unmarshalRecords(b []byte) error {
+	var sc fastjson.Scanner
+	sc.InitBytes(b)
+	for sc.Next() {
+		if err := rs.unmarshalRecord(sc.Value()); err != nil {
+			return err
+		}
+	}
+	if err := sc.Error(); err != nil {
+		return fmt.Errorf("cannot parse JSON record: %w", err)
+	}
+	return nil
+}
+
+// unmarshalRecord parses a single CloudWatch metric stream record from v.
+//
+// The record has the following format:
+//
+//	{
+//	  "metric_stream_name": "MyMetricStream",
+//	  "account_id": "1234567890",
+//	  "region": "us-east-1",
+//	  "namespace": "AWS/EC2",
+//	  "metric_name": "DiskWriteOps",
+//	  "dimensions": {
+//	    "InstanceId": "i-123456789012"
+//	  },
+//	  "timestamp": 1611929698000,
+//	  "value": {
+//	    "max": 3,
+//	    "min": 0,
+//	    "sum": 9,
+//	    "count": 3
+//	  },
+//	  "unit": "Seconds"
+//	}
+func (rs *Rows) unmarshalRecord(v *fastjson.Value) error {
+	namespace := v.GetStringBytes("namespace")
+	if len(namespace) == 0 {
+		return fmt.Errorf("missing `namespace` field in the record %s", v)
+	}
+	metricName := v.GetStringBytes("metric_name")
+	if len(metricName) == 0 {
+		return fmt.Errorf("missing `metric_name` field in the record %s", v)
+	}
+	value := v.Get("value")
+	if value == nil {
+		return fmt.Errorf("missing `value` field in the record %s", v)
+	}
+	valueObj, err := value.Object()
+	if err != nil {
+		return fmt.Errorf("cannot parse `value` field in the record %s: %w", v, err)
+	}
+
+	tagsPool := rs.tagsPool
+	tagsLen := len(tagsPool)
+	if accountID := v.GetStringBytes("account_id"); len(accountID) > 0 {
+		tagsPool = append(tagsPool, Tag{
+			Key:   "account_id",
+			Value: bytesutil.InternBytes(accountID),
+		})
+	}
+	if region := v.GetStringBytes("region"); len(region) > 0 {
+		tagsPool = append(tagsPool, Tag{
+			Key:   "region",
+			Value: bytesutil.InternBytes(region),
+		})
+	}
+	if dimensions := v.GetObject("dimensions"); dimensions != nil {
+		dimensions.Visit(func(k []byte, dv *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			dimValue, errLocal := dv.StringBytes()
+			if errLocal != nil {
+				err = fmt.Errorf("cannot parse dimension %q value: %w", k, errLocal)
+				return
+			}
+			tagsPool = append(tagsPool, Tag{
+				Key:   dimensionLabelName(bytesutil.ToUnsafeString(k)),
+				Value: bytesutil.InternBytes(dimValue),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("cannot parse `dimensions` field in the record %s: %w", v, err)
+		}
+	}
+
+	prefix := seriesPrefix(bytesutil.ToUnsafeString(namespace), bytesutil.ToUnsafeString(metricName))
+	samplesPool := rs.samplesPool
+	samplesLen := len(samplesPool)
+	valueObj.Visit(func(k []byte, sv *fastjson.Value) {
+		if err != nil {
+			return
+		}
+		stat := bytesutil.ToUnsafeString(k)
+		switch stat {
+		case "sum", "count", "min", "max":
+		default:
+			// Skip unsupported stats such as percentiles, since they cannot be aggregated.
+			return
+		}
+		f, errLocal := sv.Float64()
+		if errLocal != nil {
+			err = fmt.Errorf("cannot parse %q value: %w", stat, errLocal)
+			return
+		}
+		samplesPool = append(samplesPool, Sample{
+			Name:  bytesutil.InternString(prefix + "_" + stat),
+			Value: f,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot parse `value` field in the record %s: %w", v, err)
+	}
+
+	var tags []Tag
+	if len(tagsPool) > tagsLen {
+		tags = tagsPool[tagsLen:]
+	}
+	timestamp := v.GetInt64("timestamp")
+	rs.Rows = append(rs.Rows, Row{
+		Tags:      tags,
+		Samples:   samplesPool[samplesLen:],
+		Timestamp: timestamp,
+	})
+	rs.tagsPool = tagsPool
+	rs.samplesPool = samplesPool
+	return nil
+}
+
+// seriesPrefix returns Prometheus-compatible metric name prefix for the given CloudWatch namespace and metricName.
+//
+// For example, seriesPrefix("AWS/EC2", "CPUUtilization") returns "aws_ec2_cpuutilization".
+func seriesPrefix(namespace, metricName string) string {
+	return metricNameSanitizer.Transform(namespace + "/" + metricName)
+}
+
+var metricNameSanitizer = bytesutil.NewFastStringTransformer(func(s string) string {
+	s = unsupportedChars.ReplaceAllLiteralString(s, "_")
+	return strings.ToLower(s)
+})
+
+// dimensionLabelName returns Prometheus-compatible label name for the given CloudWatch dimension name.
+//
+// For example, dimensionLabelName("InstanceId") returns "dimension_InstanceId".
+func dimensionLabelName(name string) string {
+	return dimensionNameSanitizer.Transform(name)
+}
+
+var dimensionNameSanitizer = bytesutil.NewFastStringTransformer(func(s string) string {
+	return "dimension_" + unsupportedChars.ReplaceAllLiteralString(s, "_")
+})
+
+var unsupportedChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
@@ -0,0 +1,151 @@
+package cloudwatch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newFirehoseRequest(records ...string) string {
+	a := make([]string, 0, len(records))
+	for _, r := range records {
+		a = append(a, fmt.Sprintf(`{"data":%q}`, base64.StdEncoding.EncodeToString([]byte(r))))
+	}
+	return fmt.Sprintf(`{"requestId":"foo","timestamp":1611929698000,"records":[%s]}`, strings.Join(a, ","))
+}
+
+func TestRowsUnmarshal_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		var rs Rows
+		if err := rs.Unmarshal([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid JSON
+	f("")
+	f("foo")
+	f(`{"records":[{"data":"foo`)
+
+	// missing data
+	f(`{"records":[{}]}`)
+
+	// invalid base64
+	f(`{"records":[{"data":"!!!"}]}`)
+
+	// invalid records
+	f(newFirehoseRequest(`foo`))
+	f(newFirehoseRequest(`{"metric_name":"foo","value":{"sum":1}}`))
+	f(newFirehoseRequest(`{"namespace":"foo","value":{"sum":1}}`))
+	f(newFirehoseRequest(`{"namespace":"foo","metric_name":"bar"}`))
+	f(newFirehoseRequest(`{"namespace":"foo","metric_name":"bar","value":1}`))
+	f(newFirehoseRequest(`{"namespace":"foo","metric_name":"bar","value":{"sum":"x"}}`))
+	f(newFirehoseRequest(`{"namespace":"foo","metric_name":"bar","dimensions":{"a":1},"value":{"sum":1}}`))
+}
+
+func TestRowsUnmarshal_Success(t *testing.T) {
+	f := func(data string, rowsExpected []Row) {
+		t.Helper()
+
+		var rs Rows
+		if err := rs.Unmarshal([]byte(data)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows\ngot\n%v\nwant\n%v", rs.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rs.Unmarshal([]byte(data)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows at the second unmarshal\ngot\n%v\nwant\n%v", rs.Rows, rowsExpected)
+		}
+
+		rs.Reset()
+		if len(rs.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %v", rs.Rows)
+		}
+	}
+
+	// empty request
+	f(`{}`, nil)
+	f(`{"requestId":"foo","timestamp":123,"records":[]}`, nil)
+	f(newFirehoseRequest(""), nil)
+
+	// single record
+	f(newFirehoseRequest(`{"metric_stream_name":"MyMetricStream","account_id":"1234567890","region":"us-east-1","namespace":"AWS/EC2",`+
+		`"metric_name":"DiskWriteOps","dimensions":{"InstanceId":"i-123456789012"},"timestamp":1611929698000,`+
+		`"value":{"max":3,"min":0,"sum":9,"count":3},"unit":"Seconds"}`), []Row{
+		{
+			Tags: []Tag{
+				{Key: "account_id", Value: "1234567890"},
+				{Key: "region", Value: "us-east-1"},
+				{Key: "dimension_InstanceId", Value: "i-123456789012"},
+			},
+			Samples: []Sample{
+				{Name: "aws_ec2_diskwriteops_max", Value: 3},
+				{Name: "aws_ec2_diskwriteops_min", Value: 0},
+				{Name: "aws_ec2_diskwriteops_sum", Value: 9},
+				{Name: "aws_ec2_diskwriteops_count", Value: 3},
+			},
+			Timestamp: 1611929698000,
+		},
+	})
+
+	// multiple newline-delimited records in multiple Firehose records
+	f(newFirehoseRequest(
+		`{"namespace":"AWS/ApplicationELB","metric_name":"RequestCount","dimensions":{"LoadBalancer":"app/foo","AvailabilityZone":"us-east-1a"},"timestamp":1000,"value":{"sum":10,"count":2}}
+{"namespace":"Custom Namespace","metric_name":"foo.bar","timestamp":2000,"value":{"min":1.5,"p99":5}}
+`,
+		`{"namespace":"AWS/EC2","metric_name":"CPUUtilization","timestamp":3000,"value":{"max":1e2}}`,
+	), []Row{
+		{
+			Tags: []Tag{
+				{Key: "dimension_LoadBalancer", Value: "app/foo"},
+				{Key: "dimension_AvailabilityZone", Value: "us-east-1a"},
+			},
+			Samples: []Sample{
+				{Name: "aws_applicationelb_requestcount_sum", Value: 10},
+				{Name: "aws_applicationelb_requestcount_count", Value: 2},
+			},
+			Timestamp: 1000,
+		},
+		{
+			Samples: []Sample{
+				{Name: "custom_namespace_foo_bar_min", Value: 1.5},
+			},
+			Timestamp: 2000,
+		},
+		{
+			Samples: []Sample{
+				{Name: "aws_ec2_cpuutilization_max", Value: 100},
+			},
+			Timestamp: 3000,
+		},
+	})
+}
+
+func TestRowsUnmarshalRecords(t *testing.T) {
+	var rs Rows
+	data := `{"namespace":"AWS/EC2","metric_name":"CPUUtilization","timestamp":3000,"value":{"sum":5}}`
+	if err := rs.UnmarshalRecords([]byte(data)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rowsExpected := []Row{
+		{
+			Samples: []Sample{
+				{Name: "aws_ec2_cpuutilization_sum", Value: 5},
+			},
+			Timestamp: 3000,
+		},
+	}
+	if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+		t.Fatalf("unexpected rows\ngot\n%v\nwant\n%v", rs.Rows, rowsExpected)
+	}
+}
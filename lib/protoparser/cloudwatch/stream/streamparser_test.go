@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/cloudwatch"
+)
+
+func TestParseFailure(t *testing.T) {
+	f := func(req string) {
+		t.Helper()
+
+		callback := func(_ []cloudwatch.Row) error {
+			panic(fmt.Errorf("unexpected call into callback"))
+		}
+		r := bytes.NewReader([]byte(req))
+		if err := Parse(r, false, callback); err == nil {
+			t.Fatalf("expecting non-empty error")
+		}
+	}
+	f("")
+	f("foo")
+	f(`{"records":[{"data":"foo"}]}`)
+}
+
+func TestParseSuccess(t *testing.T) {
+	f := func(req string, expectedRows []cloudwatch.Row) {
+		t.Helper()
+
+		callback := func(rows []cloudwatch.Row) error {
+			if !reflect.DeepEqual(rows, expectedRows) {
+				return fmt.Errorf("unexpected rows\ngot\n%v\nwant\n%v", rows, expectedRows)
+			}
+			return nil
+		}
+
+		// Parse from uncompressed reader
+		r := bytes.NewReader([]byte(req))
+		if err := Parse(r, false, callback); err != nil {
+			t.Fatalf("unexpected error when parsing uncompressed request: %s", err)
+		}
+
+		var bb bytes.Buffer
+		zw := gzip.NewWriter(&bb)
+		if _, err := zw.Write([]byte(req)); err != nil {
+			t.Fatalf("cannot compress request: %s", err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("cannot close compressed writer: %s", err)
+		}
+		if err := Parse(&bb, true, callback); err != nil {
+			t.Fatalf("unexpected error when parsing compressed request: %s", err)
+		}
+	}
+
+	f(`{}`, nil)
+
+	data := base64.StdEncoding.EncodeToString([]byte(`{"namespace":"AWS/EC2","metric_name":"CPUUtilization","dimensions":{"InstanceId":"i-1"},"timestamp":1000,"value":{"sum":5,"count":2}}`))
+	f(`{"requestId":"foo","timestamp":1000,"records":[{"data":"`+data+`"}]}`, []cloudwatch.Row{
+		{
+			Tags: []cloudwatch.Tag{
+				{Key: "dimension_InstanceId", Value: "i-1"},
+			},
+			Samples: []cloudwatch.Sample{
+				{Name: "aws_ec2_cpuutilization_sum", Value: 5},
+				{Name: "aws_ec2_cpuutilization_count", Value: 2},
+			},
+			Timestamp: 1000,
+		},
+	})
+}
@@ -0,0 +1,176 @@
+package collectd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	typesDBPaths = flagutil.NewArrayString("collectd.typesDB", "Optional path to types.db file with data source names for collectd types. "+
+		"The built-in types.db with common multi-value types is used by default. See https://collectd.org/documentation/manpages/types.db.html . "+
+		"See also https://docs.victoriametrics.com/#how-to-send-data-from-collectd")
+	authFile = flag.String("collectd.authFile", "", "Optional path to a file with 'user: password' lines used for verifying signed and decrypting encrypted collectd packets. "+
+		"The file has the same format as AuthFile option of collectd network plugin. See also -collectd.securityLevel")
+	securityLevel = flag.String("collectd.securityLevel", "none", "The minimum security level for the accepted collectd packets. "+
+		"Supported values: none, sign, encrypt. Values from packets with lower security level are rejected. See also -collectd.authFile")
+)
+
+// SecurityLevel is the minimum security level for the accepted collectd packets.
+type SecurityLevel int
+
+const (
+	// SecurityLevelNone accepts all the packets.
+	//
+	// Signed packets are verified and encrypted packets are decrypted if the corresponding user is known.
+	SecurityLevelNone SecurityLevel = iota
+
+	// SecurityLevelSign accepts only signed or encrypted packets.
+	SecurityLevelSign
+
+	// SecurityLevelEncrypt accepts only encrypted packets.
+	SecurityLevelEncrypt
+)
+
+// String returns string representation of sl.
+func (sl SecurityLevel) String() string {
+	switch sl {
+	case SecurityLevelNone:
+		return "none"
+	case SecurityLevelSign:
+		return "sign"
+	case SecurityLevelEncrypt:
+		return "encrypt"
+	default:
+		return fmt.Sprintf("SecurityLevel(%d)", int(sl))
+	}
+}
+
+// ParseSecurityLevel parses security level from s.
+func ParseSecurityLevel(s string) (SecurityLevel, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return SecurityLevelNone, nil
+	case "sign":
+		return SecurityLevelSign, nil
+	case "encrypt":
+		return SecurityLevelEncrypt, nil
+	default:
+		return 0, fmt.Errorf("unsupported security level %q; supported values: none, sign, encrypt", s)
+	}
+}
+
+// Config contains settings for parsing collectd packets.
+type Config struct {
+	// TypesDB contains data source names for collectd types.
+	TypesDB TypesDB
+
+	// Users contains passwords for users, which can sign or encrypt packets.
+	Users map[string]string
+
+	// SecurityLevel is the minimum security level for the accepted packets.
+	SecurityLevel SecurityLevel
+}
+
+// NewConfig returns new Config with the built-in types.db and without users.
+func NewConfig() *Config {
+	return &Config{
+		TypesDB: newDefaultTypesDB(),
+		Users:   make(map[string]string),
+	}
+}
+
+var globalConfig atomic.Pointer[Config]
+
+// MustInit initializes collectd parser config from command-line flags.
+//
+// It must be called before starting collectd listener.
+func MustInit() {
+	cfg, err := loadConfig(*typesDBPaths, *authFile, *securityLevel)
+	if err != nil {
+		logger.Fatalf("cannot initialize collectd parser: %s", err)
+	}
+	globalConfig.Store(cfg)
+}
+
+// GetConfig returns the config initialized by MustInit.
+//
+// The config with default settings is returned if MustInit wasn't called.
+func GetConfig() *Config {
+	cfg := globalConfig.Load()
+	if cfg == nil {
+		cfg = NewConfig()
+		globalConfig.CompareAndSwap(nil, cfg)
+		cfg = globalConfig.Load()
+	}
+	return cfg
+}
+
+func loadConfig(typesDBPaths []string, authFile, securityLevel string) (*Config, error) {
+	cfg := NewConfig()
+	for _, path := range typesDBPaths {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read -collectd.typesDB=%q: %w", path, err)
+		}
+		if err := cfg.TypesDB.Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("cannot parse -collectd.typesDB=%q: %w", path, err)
+		}
+	}
+	if authFile != "" {
+		data, err := fscore.ReadFileOrHTTP(authFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read -collectd.authFile=%q: %w", authFile, err)
+		}
+		users, err := parseAuthFile(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse -collectd.authFile=%q: %w", authFile, err)
+		}
+		cfg.Users = users
+	}
+	sl, err := ParseSecurityLevel(securityLevel)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -collectd.securityLevel: %w", err)
+	}
+	if sl != SecurityLevelNone && len(cfg.Users) == 0 {
+		return nil, fmt.Errorf("-collectd.authFile must contain at least a single user when -collectd.securityLevel=%s", sl)
+	}
+	cfg.SecurityLevel = sl
+	return cfg, nil
+}
+
+// parseAuthFile parses `user: password` lines from data.
+//
+// See AuthFile option at https://collectd.org/documentation/manpages/collectd.conf.html#plugin-network
+func parseAuthFile(data string) (map[string]string, error) {
+	users := make(map[string]string)
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		user, password, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing `:` delimiter between user and password", n+1)
+		}
+		user = strings.TrimSpace(user)
+		password = strings.TrimSpace(password)
+		if user == "" {
+			return nil, fmt.Errorf("line %d: user cannot be empty", n+1)
+		}
+		if password == "" {
+			return nil, fmt.Errorf("line %d: password cannot be empty for user %q", n+1, user)
+		}
+		users[user] = password
+	}
+	return users, nil
+}
@@ -0,0 +1,115 @@
+package collectd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypesDBParse_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		tdb := make(TypesDB)
+		if err := tdb.Parse(data); err == nil {
+			t.Fatalf("expecting non-nil error for types.db %q", data)
+		}
+	}
+	f("foo")
+	f("foo bar")
+	f("foo bar:GAUGE:0")
+	f("foo bar:UNKNOWN:0:U")
+	f("foo ,")
+}
+
+func TestTypesDBParse_Success(t *testing.T) {
+	f := func(data string, tdbExpected TypesDB) {
+		t.Helper()
+		tdb := make(TypesDB)
+		if err := tdb.Parse(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(tdb, tdbExpected) {
+			t.Fatalf("unexpected types.db;\ngot\n%v\nwant\n%v", tdb, tdbExpected)
+		}
+	}
+	f("", TypesDB{})
+	f(`
+# comment
+if_octets		rx:DERIVE:0:U, tx:DERIVE:0:U
+gauge   value:GAUGE:U:U
+load  shortterm:GAUGE:0:5000,midterm:GAUGE:0:5000 , longterm:GAUGE:0:5000
+`, TypesDB{
+		"if_octets": {"rx", "tx"},
+		"gauge":     {"value"},
+		"load":      {"shortterm", "midterm", "longterm"},
+	})
+}
+
+func TestParseAuthFile_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseAuthFile(data); err == nil {
+			t.Fatalf("expecting non-nil error for auth file %q", data)
+		}
+	}
+	f("foo")
+	f(": bar")
+	f("foo:")
+}
+
+func TestParseAuthFile_Success(t *testing.T) {
+	f := func(data string, usersExpected map[string]string) {
+		t.Helper()
+		users, err := parseAuthFile(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(users, usersExpected) {
+			t.Fatalf("unexpected users;\ngot\n%v\nwant\n%v", users, usersExpected)
+		}
+	}
+	f("", map[string]string{})
+	f(`
+# comment
+alice: secret
+bob:pass:word
+`, map[string]string{
+		"alice": "secret",
+		"bob":   "pass:word",
+	})
+}
+
+func TestLoadConfig_Failure(t *testing.T) {
+	f := func(typesDBPaths []string, authFile, securityLevel string) {
+		t.Helper()
+		if _, err := loadConfig(typesDBPaths, authFile, securityLevel); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f([]string{"testdata/missing.db"}, "", "none")
+	f(nil, "testdata/missing", "none")
+	f(nil, "", "foobar")
+
+	// security level requires users
+	f(nil, "", "sign")
+}
+
+func TestLoadConfig_Success(t *testing.T) {
+	cfg, err := loadConfig([]string{"testdata/types.db"}, "testdata/auth_file", "encrypt")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.SecurityLevel != SecurityLevelEncrypt {
+		t.Fatalf("unexpected security level; got %s; want %s", cfg.SecurityLevel, SecurityLevelEncrypt)
+	}
+	if !reflect.DeepEqual(cfg.Users, map[string]string{"alice": "secret"}) {
+		t.Fatalf("unexpected users: %v", cfg.Users)
+	}
+	if dsNames := cfg.TypesDB["custom"]; !reflect.DeepEqual(dsNames, []string{"foo", "bar"}) {
+		t.Fatalf("unexpected data sources for custom type: %v", dsNames)
+	}
+
+	// The built-in types must be preserved
+	if dsNames := cfg.TypesDB["if_octets"]; !reflect.DeepEqual(dsNames, []string{"rx", "tx"}) {
+		t.Fatalf("unexpected data sources for if_octets type: %v", dsNames)
+	}
+}
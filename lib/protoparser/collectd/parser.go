@@ -0,0 +1,355 @@
+package collectd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+// Rows contains rows parsed from collectd packet.
+type Rows struct {
+	Rows []Row
+
+	tagsPool []Tag
+
+	// buf is used as a temporary buffer for constructing metric names.
+	buf []byte
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Row is a single value from collectd value list.
+type Row struct {
+	Metric string
+	Tags   []Tag
+	Value  float64
+
+	// Timestamp is the timestamp in milliseconds. It is set to 0 if the packet has no time part.
+	Timestamp int64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Value = 0
+	r.Timestamp = 0
+}
+
+// Tag is a collectd tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
+
+// Part types for collectd binary protocol.
+//
+// See https://collectd.org/wiki/index.php/Binary_protocol
+const (
+	partHost           = 0x0000
+	partTime           = 0x0001
+	partPlugin         = 0x0002
+	partPluginInstance = 0x0003
+	partType           = 0x0004
+	partTypeInstance   = 0x0005
+	partValues         = 0x0006
+	partInterval       = 0x0007
+	partTimeHR         = 0x0008
+	partIntervalHR     = 0x0009
+	partSignSHA256     = 0x0200
+	partEncrAES256     = 0x0210
+)
+
+// Data source types for collectd values.
+const (
+	dsTypeCounter  = 0
+	dsTypeGauge    = 1
+	dsTypeDerive   = 2
+	dsTypeAbsolute = 3
+)
+
+// valueList holds the state of value list identifiers, which are set by the preceding parts in the packet.
+type valueList struct {
+	host           string
+	plugin         string
+	pluginInstance string
+	typ            string
+	typeInstance   string
+	timestamp      int64
+}
+
+// Unmarshal unmarshals collectd binary protocol packet from data according to cfg.
+//
+// Every value from values parts is converted into a separate row with the name compatible with collectd_exporter:
+// collectd_<plugin>_<type>[_<dsname>][_total]. See https://github.com/prometheus/collectd_exporter
+//
+// See https://collectd.org/wiki/index.php/Binary_protocol
+func (rs *Rows) Unmarshal(data []byte, cfg *Config) error {
+	rs.Reset()
+	return rs.unmarshalParts(data, cfg, SecurityLevelNone, valueList{})
+}
+
+func (rs *Rows) unmarshalParts(data []byte, cfg *Config, level SecurityLevel, vl valueList) error {
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("too short part header; got %d bytes; want 4 bytes", len(data))
+		}
+		kind := binary.BigEndian.Uint16(data)
+		partLen := int(binary.BigEndian.Uint16(data[2:]))
+		if partLen < 4 || partLen > len(data) {
+			return fmt.Errorf("invalid length for part 0x%04x: %d bytes; remaining packet size: %d bytes", kind, partLen, len(data))
+		}
+		part := data[4:partLen]
+		tail := data[partLen:]
+		var err error
+		switch kind {
+		case partHost:
+			vl.host, err = unmarshalString(part)
+		case partPlugin:
+			vl.plugin, err = unmarshalString(part)
+		case partPluginInstance:
+			vl.pluginInstance, err = unmarshalString(part)
+		case partType:
+			vl.typ, err = unmarshalString(part)
+		case partTypeInstance:
+			vl.typeInstance, err = unmarshalString(part)
+		case partTime:
+			var n uint64
+			n, err = unmarshalNumeric(part)
+			vl.timestamp = int64(n) * 1e3
+		case partTimeHR:
+			var n uint64
+			n, err = unmarshalNumeric(part)
+			vl.timestamp = hrToMillis(n)
+		case partInterval, partIntervalHR:
+			// The interval is validated, but it isn't needed for the ingested samples.
+			_, err = unmarshalNumeric(part)
+		case partValues:
+			if level < cfg.SecurityLevel {
+				return fmt.Errorf("values part has security level %s, while -collectd.securityLevel=%s", level, cfg.SecurityLevel)
+			}
+			err = rs.unmarshalValues(part, cfg, &vl)
+		case partSignSHA256:
+			ok, err := verifySignature(part, tail, cfg)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// The user is unknown, so the signature cannot be verified.
+				// Process the remaining parts as unsigned.
+				break
+			}
+			// The signature covers all the remaining parts.
+			return rs.unmarshalParts(tail, cfg, SecurityLevelSign, valueList{})
+		case partEncrAES256:
+			plaintext, err := decryptPart(part, cfg)
+			if err != nil {
+				return err
+			}
+			if err := rs.unmarshalParts(plaintext, cfg, SecurityLevelEncrypt, valueList{}); err != nil {
+				return fmt.Errorf("cannot unmarshal encrypted parts: %w", err)
+			}
+		default:
+			// Skip unsupported parts such as notifications.
+		}
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal part 0x%04x: %w", kind, err)
+		}
+		data = tail
+	}
+	return nil
+}
+
+func (rs *Rows) unmarshalValues(part []byte, cfg *Config, vl *valueList) error {
+	if len(part) < 2 {
+		return fmt.Errorf("missing the number of values")
+	}
+	n := int(binary.BigEndian.Uint16(part))
+	part = part[2:]
+	if len(part) != 9*n {
+		return fmt.Errorf("unexpected size for %d values; got %d bytes; want %d bytes", n, len(part), 9*n)
+	}
+	if vl.plugin == "" || vl.typ == "" {
+		return fmt.Errorf("missing plugin or type part before values part")
+	}
+	dsTypes := part[:n]
+	values := part[n:]
+	for i := 0; i < n; i++ {
+		b := values[8*i : 8*i+8]
+		var v float64
+		dsType := dsTypes[i]
+		switch dsType {
+		case dsTypeCounter, dsTypeAbsolute:
+			v = float64(binary.BigEndian.Uint64(b))
+		case dsTypeGauge:
+			// Gauge values are encoded in little-endian byte order.
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case dsTypeDerive:
+			v = float64(int64(binary.BigEndian.Uint64(b)))
+		default:
+			return fmt.Errorf("unsupported data source type %d for value #%d", dsType, i)
+		}
+		dsName := cfg.TypesDB.dsName(vl.typ, i, n)
+		rs.appendRow(vl, dsName, dsType, v)
+	}
+	return nil
+}
+
+func (rs *Rows) appendRow(vl *valueList, dsName string, dsType byte, v float64) {
+	if cap(rs.Rows) > len(rs.Rows) {
+		rs.Rows = rs.Rows[:len(rs.Rows)+1]
+	} else {
+		rs.Rows = append(rs.Rows, Row{})
+	}
+	r := &rs.Rows[len(rs.Rows)-1]
+
+	b := append(rs.buf[:0], "collectd_"...)
+	b = append(b, vl.plugin...)
+	b = append(b, '_')
+	b = append(b, vl.typ...)
+	if dsName != "value" {
+		b = append(b, '_')
+		b = append(b, dsName...)
+	}
+	if dsType == dsTypeCounter || dsType == dsTypeDerive {
+		b = append(b, "_total"...)
+	}
+	rs.buf = b
+	r.Metric = promrelabel.SanitizeMetricName(bytesutil.InternBytes(b))
+	r.Value = v
+	r.Timestamp = vl.timestamp
+
+	// Labels are compatible with collectd_exporter.
+	// See https://github.com/prometheus/collectd_exporter
+	tagsStart := len(rs.tagsPool)
+	if vl.pluginInstance != "" {
+		rs.addTag(promrelabel.SanitizeLabelName(vl.plugin), vl.pluginInstance)
+	}
+	if vl.typeInstance != "" {
+		if vl.pluginInstance == "" {
+			rs.addTag(promrelabel.SanitizeLabelName(vl.plugin), vl.typeInstance)
+		} else {
+			rs.addTag("type", vl.typeInstance)
+		}
+	}
+	if vl.host != "" {
+		rs.addTag("instance", vl.host)
+	}
+	if tags := rs.tagsPool[tagsStart:]; len(tags) > 0 {
+		r.Tags = tags[:len(tags):len(tags)]
+	}
+}
+
+func (rs *Rows) addTag(key, value string) {
+	if cap(rs.tagsPool) > len(rs.tagsPool) {
+		rs.tagsPool = rs.tagsPool[:len(rs.tagsPool)+1]
+	} else {
+		rs.tagsPool = append(rs.tagsPool, Tag{})
+	}
+	tag := &rs.tagsPool[len(rs.tagsPool)-1]
+	tag.Key = key
+	tag.Value = value
+}
+
+// verifySignature verifies HMAC-SHA256 signature from part for the signed data.
+//
+// It returns false if the signature cannot be verified because the user is unknown and cfg allows unsigned packets.
+func verifySignature(part, signedData []byte, cfg *Config) (bool, error) {
+	if len(part) < sha256.Size+1 {
+		return false, fmt.Errorf("too short signature part; got %d bytes; want at least %d bytes", len(part), sha256.Size+1)
+	}
+	signature := part[:sha256.Size]
+	user := part[sha256.Size:]
+	password, ok := cfg.Users[string(user)]
+	if !ok {
+		if cfg.SecurityLevel == SecurityLevelNone {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot verify signature for unknown user %q", user)
+	}
+	h := hmac.New(sha256.New, []byte(password))
+	h.Write(user)
+	h.Write(signedData)
+	if !hmac.Equal(h.Sum(nil), signature) {
+		return false, fmt.Errorf("signature mismatch for user %q", user)
+	}
+	return true, nil
+}
+
+// decryptPart decrypts AES-256-OFB encrypted part and returns the decrypted parts.
+func decryptPart(part []byte, cfg *Config) ([]byte, error) {
+	if len(part) < 2 {
+		return nil, fmt.Errorf("missing user name length in encrypted part")
+	}
+	userLen := int(binary.BigEndian.Uint16(part))
+	part = part[2:]
+	if len(part) < userLen+aes.BlockSize+sha1.Size {
+		return nil, fmt.Errorf("too short encrypted part; got %d bytes; want at least %d bytes", len(part), userLen+aes.BlockSize+sha1.Size)
+	}
+	user := part[:userLen]
+	iv := part[userLen : userLen+aes.BlockSize]
+	encrypted := part[userLen+aes.BlockSize:]
+	password, ok := cfg.Users[string(user)]
+	if !ok {
+		return nil, fmt.Errorf("cannot decrypt packet for unknown user %q", user)
+	}
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize AES cipher: %w", err)
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewOFB(block, iv).XORKeyStream(decrypted, encrypted)
+	checksum := decrypted[:sha1.Size]
+	plaintext := decrypted[sha1.Size:]
+	if h := sha1.Sum(plaintext); !hmac.Equal(h[:], checksum) {
+		return nil, fmt.Errorf("checksum mismatch for encrypted packet from user %q; possible password mismatch", user)
+	}
+	return plaintext, nil
+}
+
+func unmarshalString(part []byte) (string, error) {
+	if len(part) == 0 || part[len(part)-1] != 0 {
+		return "", fmt.Errorf("string must be terminated by null byte")
+	}
+	return bytesutil.InternBytes(part[:len(part)-1]), nil
+}
+
+func unmarshalNumeric(part []byte) (uint64, error) {
+	if len(part) != 8 {
+		return 0, fmt.Errorf("unexpected size for numeric part; got %d bytes; want 8 bytes", len(part))
+	}
+	return binary.BigEndian.Uint64(part), nil
+}
+
+// hrToMillis converts high-resolution time in 2^-30 seconds to milliseconds.
+func hrToMillis(n uint64) int64 {
+	secs := n >> 30
+	frac := n & (1<<30 - 1)
+	return int64(secs*1e3 + (frac*1e3)>>30)
+}
@@ -0,0 +1,348 @@
+package collectd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshal_Success(t *testing.T) {
+	f := func(data []byte, cfg *Config, rowsExpected []Row) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(data, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rows.Unmarshal(data, cfg); err != nil {
+			t.Fatalf("unexpected error on the second unmarshal: %s", err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows on the second unmarshal;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+	}
+
+	cfg := NewConfig()
+	cfg.Users["alice"] = "secret"
+
+	// empty packet
+	f(nil, cfg, nil)
+
+	// single gauge value with time_hr
+	loadPacket := appendStringPart(nil, partHost, "host1")
+	loadPacket = appendNumericPart(loadPacket, partTimeHR, 1700000000<<30|1<<29)
+	loadPacket = appendNumericPart(loadPacket, partIntervalHR, 10<<30)
+	loadPacket = appendStringPart(loadPacket, partPlugin, "load")
+	loadPacket = appendStringPart(loadPacket, partType, "load")
+	loadPacket = appendValuesPart(loadPacket, []byte{dsTypeGauge, dsTypeGauge, dsTypeGauge}, []float64{0.5, 1.25, 2})
+	loadRows := []Row{
+		{
+			Metric:    "collectd_load_load_shortterm",
+			Tags:      []Tag{{Key: "instance", Value: "host1"}},
+			Value:     0.5,
+			Timestamp: 1700000000500,
+		},
+		{
+			Metric:    "collectd_load_load_midterm",
+			Tags:      []Tag{{Key: "instance", Value: "host1"}},
+			Value:     1.25,
+			Timestamp: 1700000000500,
+		},
+		{
+			Metric:    "collectd_load_load_longterm",
+			Tags:      []Tag{{Key: "instance", Value: "host1"}},
+			Value:     2,
+			Timestamp: 1700000000500,
+		},
+	}
+	f(loadPacket, cfg, loadRows)
+
+	// multiple value lists with plugin and type instances, derive and counter values
+	data := appendStringPart(nil, partHost, "host.example.com")
+	data = appendNumericPart(data, partTime, 1700000000)
+	data = appendNumericPart(data, partInterval, 10)
+	data = appendStringPart(data, partPlugin, "interface")
+	data = appendStringPart(data, partPluginInstance, "eth0")
+	data = appendStringPart(data, partType, "if_octets")
+	data = appendValuesPart(data, []byte{dsTypeDerive, dsTypeDerive}, []float64{-10, 20})
+	data = appendStringPart(data, partPlugin, "cpu")
+	data = appendStringPart(data, partPluginInstance, "")
+	data = appendStringPart(data, partType, "cpu")
+	data = appendStringPart(data, partTypeInstance, "idle")
+	data = appendValuesPart(data, []byte{dsTypeCounter}, []float64{12345})
+	// notification parts must be skipped
+	data = appendStringPart(data, 0x0100, "some message")
+	data = appendNumericPart(data, 0x0101, 4)
+	data = appendStringPart(data, partPlugin, "df-root")
+	data = appendStringPart(data, partPluginInstance, "sda")
+	data = appendStringPart(data, partType, "custom_type")
+	data = appendValuesPart(data, []byte{dsTypeAbsolute, dsTypeGauge}, []float64{7, 8})
+	f(data, cfg, []Row{
+		{
+			Metric: "collectd_interface_if_octets_rx_total",
+			Tags: []Tag{
+				{Key: "interface", Value: "eth0"},
+				{Key: "instance", Value: "host.example.com"},
+			},
+			Value:     -10,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric: "collectd_interface_if_octets_tx_total",
+			Tags: []Tag{
+				{Key: "interface", Value: "eth0"},
+				{Key: "instance", Value: "host.example.com"},
+			},
+			Value:     20,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric: "collectd_cpu_cpu_total",
+			Tags: []Tag{
+				{Key: "cpu", Value: "idle"},
+				{Key: "instance", Value: "host.example.com"},
+			},
+			Value:     12345,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric: "collectd_df_root_custom_type_0",
+			Tags: []Tag{
+				{Key: "df_root", Value: "sda"},
+				{Key: "type", Value: "idle"},
+				{Key: "instance", Value: "host.example.com"},
+			},
+			Value:     7,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric: "collectd_df_root_custom_type_1",
+			Tags: []Tag{
+				{Key: "df_root", Value: "sda"},
+				{Key: "type", Value: "idle"},
+				{Key: "instance", Value: "host.example.com"},
+			},
+			Value:     8,
+			Timestamp: 1700000000000,
+		},
+	})
+
+	// custom types.db
+	cfgCustom := NewConfig()
+	if err := cfgCustom.TypesDB.Parse("custom_type   foo:ABSOLUTE:0:U, bar:GAUGE:U:U"); err != nil {
+		t.Fatalf("cannot parse types.db: %s", err)
+	}
+	data = appendStringPart(nil, partPlugin, "x")
+	data = appendStringPart(data, partType, "custom_type")
+	data = appendValuesPart(data, []byte{dsTypeAbsolute, dsTypeGauge}, []float64{7, 8})
+	f(data, cfgCustom, []Row{
+		{
+			Metric: "collectd_x_custom_type_foo",
+			Value:  7,
+		},
+		{
+			Metric: "collectd_x_custom_type_bar",
+			Value:  8,
+		},
+	})
+
+	// signed packet
+	signedPacket := appendSignaturePart(nil, "alice", "secret", loadPacket)
+	f(signedPacket, cfg, loadRows)
+
+	// unsigned parts before the signature aren't applied to the signed values
+	unsignedPrefix := appendStringPart(nil, partPluginInstance, "attacker")
+	unsignedPrefix = appendStringPart(unsignedPrefix, partTypeInstance, "attacker")
+	f(append(unsignedPrefix, signedPacket...), cfg, loadRows)
+
+	// signed packet from unknown user is accepted as unsigned with security level none
+	f(appendSignaturePart(nil, "bob", "secret", loadPacket), cfg, loadRows)
+
+	// encrypted packet
+	encryptedPacket := appendEncryptedPart(nil, "alice", "secret", loadPacket)
+	f(encryptedPacket, cfg, loadRows)
+
+	// signed and encrypted packets are accepted with security level sign
+	cfgSign := NewConfig()
+	cfgSign.Users["alice"] = "secret"
+	cfgSign.SecurityLevel = SecurityLevelSign
+	f(signedPacket, cfgSign, loadRows)
+	f(encryptedPacket, cfgSign, loadRows)
+
+	// encrypted packets are accepted with security level encrypt
+	cfgEncrypt := NewConfig()
+	cfgEncrypt.Users["alice"] = "secret"
+	cfgEncrypt.SecurityLevel = SecurityLevelEncrypt
+	f(encryptedPacket, cfgEncrypt, loadRows)
+
+	// packets without values are accepted with any security level
+	f(appendStringPart(nil, partHost, "foo"), cfgEncrypt, nil)
+}
+
+func TestRowsUnmarshal_Failure(t *testing.T) {
+	f := func(data []byte, cfg *Config) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(data, cfg); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	cfg := NewConfig()
+	cfg.Users["alice"] = "secret"
+
+	valuesPacket := appendStringPart(nil, partPlugin, "load")
+	valuesPacket = appendStringPart(valuesPacket, partType, "load")
+	valuesPacket = appendValuesPart(valuesPacket, []byte{dsTypeGauge}, []float64{1})
+
+	// too short part header
+	f([]byte{0, 0, 0}, cfg)
+
+	// invalid part length
+	f([]byte{0, 0, 0, 2}, cfg)
+	f([]byte{0, 0, 0, 10, 'a', 0}, cfg)
+
+	// string without null byte
+	f([]byte{0, 0, 0, 5, 'a'}, cfg)
+
+	// invalid numeric part size
+	f([]byte{0, 1, 0, 6, 0, 1}, cfg)
+
+	// missing plugin and type
+	f(appendValuesPart(nil, []byte{dsTypeGauge}, []float64{1}), cfg)
+
+	// unsupported data source type
+	data := appendStringPart(nil, partPlugin, "load")
+	data = appendStringPart(data, partType, "load")
+	f(appendValuesPart(data, []byte{5}, []float64{1}), cfg)
+
+	// invalid values part size
+	data = appendStringPart(nil, partPlugin, "load")
+	data = appendStringPart(data, partType, "load")
+	data = append(data, 0, partValues, 0, 7, 0, 2, 1)
+	f(data, cfg)
+
+	// signature mismatch
+	f(appendSignaturePart(nil, "alice", "invalid", valuesPacket), cfg)
+
+	// tampered signed data
+	signed := appendSignaturePart(nil, "alice", "secret", valuesPacket)
+	signed[len(signed)-1] ^= 1
+	f(signed, cfg)
+
+	// encrypted packet for unknown user
+	f(appendEncryptedPart(nil, "bob", "secret", valuesPacket), cfg)
+
+	// encrypted packet with invalid password
+	f(appendEncryptedPart(nil, "alice", "invalid", valuesPacket), cfg)
+
+	// unsigned values with security level sign
+	cfgSign := NewConfig()
+	cfgSign.Users["alice"] = "secret"
+	cfgSign.SecurityLevel = SecurityLevelSign
+	f(valuesPacket, cfgSign)
+
+	// signed values cannot rely on plugin and type from unsigned parts before the signature
+	unsignedPrefix := appendStringPart(nil, partPlugin, "load")
+	unsignedPrefix = appendStringPart(unsignedPrefix, partType, "load")
+	signedValues := appendSignaturePart(nil, "alice", "secret", appendValuesPart(nil, []byte{dsTypeGauge}, []float64{1}))
+	f(append(unsignedPrefix, signedValues...), cfgSign)
+
+	// signed packet from unknown user with security level sign
+	f(appendSignaturePart(nil, "bob", "secret", valuesPacket), cfgSign)
+
+	// signed values with security level encrypt
+	cfgEncrypt := NewConfig()
+	cfgEncrypt.Users["alice"] = "secret"
+	cfgEncrypt.SecurityLevel = SecurityLevelEncrypt
+	f(appendSignaturePart(nil, "alice", "secret", valuesPacket), cfgEncrypt)
+}
+
+func TestHRToMillis(t *testing.T) {
+	f := func(n uint64, msExpected int64) {
+		t.Helper()
+		ms := hrToMillis(n)
+		if ms != msExpected {
+			t.Fatalf("unexpected result for hrToMillis(%d); got %d; want %d", n, ms, msExpected)
+		}
+	}
+	f(0, 0)
+	f(1<<30, 1000)
+	f(1700000000<<30, 1700000000000)
+	f(1700000000<<30|1<<28, 1700000000250)
+}
+
+func appendStringPart(dst []byte, kind uint16, s string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, kind)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(4+len(s)+1))
+	dst = append(dst, s...)
+	return append(dst, 0)
+}
+
+func appendNumericPart(dst []byte, kind uint16, n uint64) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, kind)
+	dst = binary.BigEndian.AppendUint16(dst, 12)
+	return binary.BigEndian.AppendUint64(dst, n)
+}
+
+func appendValuesPart(dst []byte, dsTypes []byte, values []float64) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, partValues)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(6+9*len(values)))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(values)))
+	dst = append(dst, dsTypes...)
+	for i, v := range values {
+		switch dsTypes[i] {
+		case dsTypeGauge:
+			dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+		case dsTypeDerive:
+			dst = binary.BigEndian.AppendUint64(dst, uint64(int64(v)))
+		default:
+			dst = binary.BigEndian.AppendUint64(dst, uint64(v))
+		}
+	}
+	return dst
+}
+
+func appendSignaturePart(dst []byte, user, password string, data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(password))
+	h.Write([]byte(user))
+	h.Write(data)
+	dst = binary.BigEndian.AppendUint16(dst, partSignSHA256)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(4+sha256.Size+len(user)))
+	dst = h.Sum(dst)
+	dst = append(dst, user...)
+	return append(dst, data...)
+}
+
+func appendEncryptedPart(dst []byte, user, password string, data []byte) []byte {
+	checksum := sha1.Sum(data)
+	plaintext := append(checksum[:], data...)
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+	encrypted := make([]byte, len(plaintext))
+	cipher.NewOFB(block, iv).XORKeyStream(encrypted, plaintext)
+
+	dst = binary.BigEndian.AppendUint16(dst, partEncrAES256)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(4+2+len(user)+len(iv)+len(encrypted)))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(user)))
+	dst = append(dst, user...)
+	dst = append(dst, iv...)
+	return append(dst, encrypted...)
+}
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/collectd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+)
+
+// maxPacketSize is the maximum size of collectd packet.
+//
+// collectd packets are sent over UDP, so they cannot exceed 64KiB.
+const maxPacketSize = 64 * 1024
+
+// Parse parses a single collectd binary protocol packet from r and calls callback for the parsed rows.
+//
+// The packet is parsed according to the config initialized by collectd.MustInit.
+//
+// callback shouldn't hold rows after returning.
+func Parse(r io.Reader, callback func(rows []collectd.Row) error) error {
+	wcr := writeconcurrencylimiter.GetReader(r)
+	defer writeconcurrencylimiter.PutReader(wcr)
+	r = wcr
+
+	bb := packetBufPool.Get()
+	defer packetBufPool.Put(bb)
+
+	readCalls.Inc()
+	lr := io.LimitReader(r, maxPacketSize+1)
+	n, err := bb.ReadFrom(lr)
+	if err != nil {
+		readErrors.Inc()
+		return fmt.Errorf("cannot read collectd packet: %w", err)
+	}
+	if n > maxPacketSize {
+		readErrors.Inc()
+		return fmt.Errorf("too big collectd packet; mustn't exceed %d bytes", maxPacketSize)
+	}
+
+	rows := getRows()
+	defer putRows(rows)
+
+	if err := rows.Unmarshal(bb.B, collectd.GetConfig()); err != nil {
+		unmarshalErrors.Inc()
+		return fmt.Errorf("cannot unmarshal collectd packet: %w", err)
+	}
+	rowsRead.Add(len(rows.Rows))
+
+	// Fill in missing timestamps
+	currentTimestamp := int64(fasttime.UnixTimestamp()) * 1e3
+	for i := range rows.Rows {
+		r := &rows.Rows[i]
+		if r.Timestamp == 0 {
+			r.Timestamp = currentTimestamp
+		}
+	}
+
+	if err := callback(rows.Rows); err != nil {
+		return fmt.Errorf("error when processing imported data: %w", err)
+	}
+	return nil
+}
+
+var packetBufPool bytesutil.ByteBufferPool
+
+func getRows() *collectd.Rows {
+	v := rowsPool.Get()
+	if v == nil {
+		return &collectd.Rows{}
+	}
+	return v.(*collectd.Rows)
+}
+
+func putRows(rows *collectd.Rows) {
+	rows.Reset()
+	rowsPool.Put(rows)
+}
+
+var rowsPool sync.Pool
+
+var (
+	readCalls       = metrics.NewCounter(`vm_protoparser_read_calls_total{type="collectd"}`)
+	readErrors      = metrics.NewCounter(`vm_protoparser_read_errors_total{type="collectd"}`)
+	rowsRead        = metrics.NewCounter(`vm_protoparser_rows_read_total{type="collectd"}`)
+	unmarshalErrors = metrics.NewCounter(`vm_protoparser_unmarshal_errors_total{type="collectd"}`)
+)
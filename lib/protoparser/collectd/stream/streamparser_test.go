@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/collectd"
+)
+
+func TestParse(t *testing.T) {
+	f := func(data []byte, rowsExpected []collectd.Row) {
+		t.Helper()
+		var rows []collectd.Row
+		err := Parse(bytes.NewReader(data), func(rs []collectd.Row) error {
+			for _, r := range rs {
+				r.Tags = append([]collectd.Tag{}, r.Tags...)
+				rows = append(rows, r)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rows, rowsExpected)
+		}
+	}
+
+	// missing timestamp.
+	// Note that this test may be flaky due to timing issues.
+	data := appendStringPart(nil, 0x0000, "host1")
+	data = appendStringPart(data, 0x0002, "memory")
+	data = appendStringPart(data, 0x0004, "memory")
+	data = appendStringPart(data, 0x0005, "free")
+	data = binary.BigEndian.AppendUint16(data, 0x0006)
+	data = binary.BigEndian.AppendUint16(data, 6+9)
+	data = binary.BigEndian.AppendUint16(data, 1)
+	data = append(data, 1)
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(1024))
+	f(data, []collectd.Row{{
+		Metric: "collectd_memory_memory",
+		Tags: []collectd.Tag{
+			{Key: "memory", Value: "free"},
+			{Key: "instance", Value: "host1"},
+		},
+		Value:     1024,
+		Timestamp: int64(fasttime.UnixTimestamp()) * 1000,
+	}})
+}
+
+func TestParse_Failure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		err := Parse(bytes.NewReader(data), func(_ []collectd.Row) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid packet
+	f([]byte{0, 0, 0})
+
+	// too big packet
+	f(make([]byte, maxPacketSize+1))
+}
+
+func appendStringPart(dst []byte, kind uint16, s string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, kind)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(4+len(s)+1))
+	dst = append(dst, s...)
+	return append(dst, 0)
+}
@@ -0,0 +1,74 @@
+package collectd
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed types.db
+var defaultTypesDB string
+
+// TypesDB maps collectd types to names of their data sources.
+//
+// See https://collectd.org/documentation/manpages/types.db.html
+type TypesDB map[string][]string
+
+// Parse parses types.db contents from data and adds the parsed types to tdb.
+//
+// Types from data override the existing types in tdb.
+func (tdb TypesDB) Parse(data string) error {
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: missing data source specifications for type %q", n+1, fields[0])
+		}
+		typ := fields[0]
+		var dsNames []string
+		for _, spec := range strings.Split(strings.Join(fields[1:], ""), ",") {
+			if len(spec) == 0 {
+				continue
+			}
+			a := strings.Split(spec, ":")
+			if len(a) != 4 {
+				return fmt.Errorf("line %d: cannot parse data source specification %q for type %q; want name:type:min:max", n+1, spec, typ)
+			}
+			switch strings.ToUpper(a[1]) {
+			case "COUNTER", "GAUGE", "DERIVE", "ABSOLUTE":
+			default:
+				return fmt.Errorf("line %d: unsupported data source type %q for type %q; supported types: COUNTER, GAUGE, DERIVE, ABSOLUTE", n+1, a[1], typ)
+			}
+			dsNames = append(dsNames, a[0])
+		}
+		if len(dsNames) == 0 {
+			return fmt.Errorf("line %d: missing data source specifications for type %q", n+1, typ)
+		}
+		tdb[typ] = dsNames
+	}
+	return nil
+}
+
+// dsName returns the name for the data source at index idx of the given typ with n data sources.
+func (tdb TypesDB) dsName(typ string, idx, n int) string {
+	dsNames := tdb[typ]
+	if len(dsNames) == n {
+		return dsNames[idx]
+	}
+	if n == 1 {
+		return "value"
+	}
+	return strconv.Itoa(idx)
+}
+
+func newDefaultTypesDB() TypesDB {
+	tdb := make(TypesDB)
+	if err := tdb.Parse(defaultTypesDB); err != nil {
+		panic(fmt.Errorf("BUG: cannot parse default types.db: %w", err))
+	}
+	return tdb
+}
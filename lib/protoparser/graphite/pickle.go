@@ -0,0 +1,746 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/valyala/fastjson/fastfloat"
+)
+
+// UnmarshalPickle unmarshals graphite rows from data encoded with the carbon pickle protocol.
+//
+// data must contain a single pickled list of (path, (timestamp, value)) tuples without the length prefix.
+// Only the restricted subset of pickle opcodes needed for such lists is supported.
+// Opcodes, which may result in arbitrary code execution such as GLOBAL, REDUCE or BUILD, are rejected.
+//
+// See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-pickle-protocol
+//
+// data shouldn't be modified when rs is in use.
+func (rs *Rows) UnmarshalPickle(data []byte) error {
+	pd := getPickleDecoder()
+	defer putPickleDecoder(pd)
+
+	v, err := pd.decode(data)
+	if err != nil {
+		return fmt.Errorf("cannot decode pickle data: %w", err)
+	}
+	items, ok := pickleItems(v)
+	if !ok {
+		return fmt.Errorf("unexpected pickled object; got %s; want list of (path, (timestamp, value)) tuples", pickleTypeName(v))
+	}
+	rs.Rows, rs.tagsPool = unmarshalPickleRows(rs.Rows[:0], items, rs.tagsPool[:0])
+	return nil
+}
+
+func unmarshalPickleRows(dst []Row, items []any, tagsPool []Tag) ([]Row, []Tag) {
+	for _, item := range items {
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, Row{})
+		}
+		r := &dst[len(dst)-1]
+		var err error
+		tagsPool, err = r.unmarshalPickle(item, tagsPool)
+		if err != nil {
+			dst = dst[:len(dst)-1]
+			logger.Errorf("cannot unmarshal Graphite pickle item: %s", err)
+			invalidLines.Inc()
+		}
+	}
+	return dst, tagsPool
+}
+
+func (r *Row) unmarshalPickle(item any, tagsPool []Tag) ([]Tag, error) {
+	r.reset()
+	metricAndDatapoint, ok := pickleItems(item)
+	if !ok || len(metricAndDatapoint) != 2 {
+		return tagsPool, fmt.Errorf("unexpected item %s; want (path, (timestamp, value)) tuple", pickleTypeName(item))
+	}
+	metricAndTags, ok := metricAndDatapoint[0].(string)
+	if !ok {
+		return tagsPool, fmt.Errorf("unexpected path %s; want string", pickleTypeName(metricAndDatapoint[0]))
+	}
+	datapoint, ok := pickleItems(metricAndDatapoint[1])
+	if !ok || len(datapoint) != 2 {
+		return tagsPool, fmt.Errorf("unexpected datapoint %s for path %q; want (timestamp, value) tuple", pickleTypeName(metricAndDatapoint[1]), metricAndTags)
+	}
+	tagsPool, err := r.UnmarshalMetricAndTags(metricAndTags, tagsPool)
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse metric and tags from %q: %w", metricAndTags, err)
+	}
+	ts, err := pickleNumber(datapoint[0])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse timestamp for path %q: %w", metricAndTags, err)
+	}
+	r.Timestamp = int64(ts)
+	v, err := pickleNumber(datapoint[1])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse value for path %q: %w", metricAndTags, err)
+	}
+	r.Value = v
+	return tagsPool, nil
+}
+
+// pickleList is a mutable pickled list.
+//
+// It is stored by pointer, since it may be referred from memo and modified later by APPEND and APPENDS opcodes.
+type pickleList struct {
+	items []any
+}
+
+// pickleTuple is an immutable pickled tuple.
+type pickleTuple []any
+
+// pickleNone is pickled None value.
+type pickleNone struct{}
+
+// pickleMark is a stack marker pushed by MARK opcode.
+type pickleMark struct{}
+
+func pickleItems(v any) ([]any, bool) {
+	switch t := v.(type) {
+	case *pickleList:
+		return t.items, true
+	case pickleTuple:
+		return t, true
+	default:
+		return nil, false
+	}
+}
+
+func pickleNumber(v any) (float64, error) {
+	switch t := v.(type) {
+	case int64:
+		return float64(t), nil
+	case float64:
+		return t, nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return fastfloat.Parse(t)
+	default:
+		return 0, fmt.Errorf("unexpected %s; want number", pickleTypeName(v))
+	}
+}
+
+func pickleTypeName(v any) string {
+	switch v.(type) {
+	case *pickleList:
+		return "list"
+	case pickleTuple:
+		return "tuple"
+	case pickleNone:
+		return "None"
+	case pickleMark:
+		return "mark"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// pickleDecoder decodes a restricted subset of Python pickle protocols 0-5.
+type pickleDecoder struct {
+	stack []any
+	marks []int
+	memo  map[uint32]any
+}
+
+func (pd *pickleDecoder) reset() {
+	clear(pd.stack)
+	pd.stack = pd.stack[:0]
+	pd.marks = pd.marks[:0]
+	clear(pd.memo)
+}
+
+func getPickleDecoder() *pickleDecoder {
+	v := pickleDecoderPool.Get()
+	if v == nil {
+		return &pickleDecoder{
+			memo: make(map[uint32]any),
+		}
+	}
+	return v.(*pickleDecoder)
+}
+
+func putPickleDecoder(pd *pickleDecoder) {
+	pd.reset()
+	pickleDecoderPool.Put(pd)
+}
+
+var pickleDecoderPool sync.Pool
+
+// Pickle opcodes.
+//
+// See https://github.com/python/cpython/blob/main/Lib/pickle.py
+const (
+	opMark           = '('
+	opStop           = '.'
+	opPop            = '0'
+	opPopMark        = '1'
+	opDup            = '2'
+	opFloat          = 'F'
+	opInt            = 'I'
+	opBinInt         = 'J'
+	opBinInt1        = 'K'
+	opLong           = 'L'
+	opBinInt2        = 'M'
+	opNone           = 'N'
+	opString         = 'S'
+	opBinString      = 'T'
+	opShortBinString = 'U'
+	opUnicode        = 'V'
+	opBinUnicode     = 'X'
+	opAppend         = 'a'
+	opAppends        = 'e'
+	opGet            = 'g'
+	opBinGet         = 'h'
+	opLongBinGet     = 'j'
+	opList           = 'l'
+	opEmptyList      = ']'
+	opPut            = 'p'
+	opBinPut         = 'q'
+	opLongBinPut     = 'r'
+	opTuple          = 't'
+	opEmptyTuple     = ')'
+	opBinFloat       = 'G'
+
+	// protocol 2
+	opProto    = 0x80
+	opTuple1   = 0x85
+	opTuple2   = 0x86
+	opTuple3   = 0x87
+	opNewTrue  = 0x88
+	opNewFalse = 0x89
+	opLong1    = 0x8a
+	opLong4    = 0x8b
+
+	// protocol 3
+	opBinBytes      = 'B'
+	opShortBinBytes = 'C'
+
+	// protocol 4
+	opShortBinUnicode = 0x8c
+	opBinUnicode8     = 0x8d
+	opBinBytes8       = 0x8e
+	opMemoize         = 0x94
+	opFrame           = 0x95
+)
+
+func (pd *pickleDecoder) decode(data []byte) (any, error) {
+	src := data
+	for len(src) > 0 {
+		op := src[0]
+		src = src[1:]
+		var err error
+		switch op {
+		case opStop:
+			if len(pd.stack) != 1 {
+				return nil, fmt.Errorf("unexpected number of items on the stack at STOP opcode; got %d; want 1", len(pd.stack))
+			}
+			if len(src) > 0 {
+				return nil, fmt.Errorf("unexpected trailing data after STOP opcode: %d bytes", len(src))
+			}
+			v := pd.stack[0]
+			if _, ok := v.(pickleMark); ok {
+				return nil, fmt.Errorf("unexpected mark at the top of the stack at STOP opcode")
+			}
+			return v, nil
+		case opProto:
+			if len(src) < 1 {
+				return nil, errUnexpectedPickleEnd
+			}
+			if src[0] > 5 {
+				return nil, fmt.Errorf("unsupported pickle protocol version: %d", src[0])
+			}
+			src = src[1:]
+		case opFrame:
+			// Frames are just hints for buffering, so their contents can be read inline.
+			if len(src) < 8 {
+				return nil, errUnexpectedPickleEnd
+			}
+			src = src[8:]
+		case opMark:
+			pd.marks = append(pd.marks, len(pd.stack))
+			pd.stack = append(pd.stack, pickleMark{})
+		case opPop:
+			if _, err = pd.pop(); err != nil {
+				return nil, err
+			}
+		case opPopMark:
+			if _, err = pd.popMark(); err != nil {
+				return nil, err
+			}
+		case opDup:
+			v, err := pd.top()
+			if err != nil {
+				return nil, err
+			}
+			pd.stack = append(pd.stack, v)
+		case opNone:
+			pd.stack = append(pd.stack, pickleNone{})
+		case opNewTrue:
+			pd.stack = append(pd.stack, true)
+		case opNewFalse:
+			pd.stack = append(pd.stack, false)
+		case opInt:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			switch line {
+			case "00":
+				pd.stack = append(pd.stack, false)
+			case "01":
+				pd.stack = append(pd.stack, true)
+			default:
+				n, err := strconv.ParseInt(line, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse INT opcode arg: %w", err)
+				}
+				pd.stack = append(pd.stack, n)
+			}
+		case opLong:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			line = strings.TrimSuffix(line, "L")
+			if n, err := strconv.ParseInt(line, 10, 64); err == nil {
+				pd.stack = append(pd.stack, n)
+				break
+			}
+			f, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse LONG opcode arg: %w", err)
+			}
+			pd.stack = append(pd.stack, f)
+		case opBinInt:
+			if len(src) < 4 {
+				return nil, errUnexpectedPickleEnd
+			}
+			pd.stack = append(pd.stack, int64(int32(binary.LittleEndian.Uint32(src))))
+			src = src[4:]
+		case opBinInt1:
+			if len(src) < 1 {
+				return nil, errUnexpectedPickleEnd
+			}
+			pd.stack = append(pd.stack, int64(src[0]))
+			src = src[1:]
+		case opBinInt2:
+			if len(src) < 2 {
+				return nil, errUnexpectedPickleEnd
+			}
+			pd.stack = append(pd.stack, int64(binary.LittleEndian.Uint16(src)))
+			src = src[2:]
+		case opLong1, opLong4:
+			var n uint64
+			if op == opLong1 {
+				if len(src) < 1 {
+					return nil, errUnexpectedPickleEnd
+				}
+				n = uint64(src[0])
+				src = src[1:]
+			} else {
+				if len(src) < 4 {
+					return nil, errUnexpectedPickleEnd
+				}
+				n = uint64(binary.LittleEndian.Uint32(src))
+				src = src[4:]
+			}
+			if uint64(len(src)) < n {
+				return nil, errUnexpectedPickleEnd
+			}
+			pd.stack = append(pd.stack, decodePickleLong(src[:n]))
+			src = src[n:]
+		case opFloat:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse FLOAT opcode arg: %w", err)
+			}
+			pd.stack = append(pd.stack, f)
+		case opBinFloat:
+			if len(src) < 8 {
+				return nil, errUnexpectedPickleEnd
+			}
+			pd.stack = append(pd.stack, math.Float64frombits(binary.BigEndian.Uint64(src)))
+			src = src[8:]
+		case opString:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			s, err := unquotePickleString(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse STRING opcode arg: %w", err)
+			}
+			pd.stack = append(pd.stack, s)
+		case opUnicode:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			s, err := unescapePickleUnicode(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse UNICODE opcode arg: %w", err)
+			}
+			pd.stack = append(pd.stack, s)
+		case opShortBinString, opShortBinBytes, opShortBinUnicode:
+			if len(src) < 1 {
+				return nil, errUnexpectedPickleEnd
+			}
+			n := uint64(src[0])
+			if src, err = pd.pushBytes(src[1:], n); err != nil {
+				return nil, err
+			}
+		case opBinString, opBinBytes, opBinUnicode:
+			if len(src) < 4 {
+				return nil, errUnexpectedPickleEnd
+			}
+			n := uint64(binary.LittleEndian.Uint32(src))
+			if src, err = pd.pushBytes(src[4:], n); err != nil {
+				return nil, err
+			}
+		case opBinUnicode8, opBinBytes8:
+			if len(src) < 8 {
+				return nil, errUnexpectedPickleEnd
+			}
+			n := binary.LittleEndian.Uint64(src)
+			if src, err = pd.pushBytes(src[8:], n); err != nil {
+				return nil, err
+			}
+		case opEmptyList:
+			pd.stack = append(pd.stack, &pickleList{})
+		case opList:
+			items, err := pd.popMark()
+			if err != nil {
+				return nil, err
+			}
+			pd.stack = append(pd.stack, &pickleList{
+				items: items,
+			})
+		case opAppend:
+			v, err := pd.pop()
+			if err != nil {
+				return nil, err
+			}
+			if err := pd.appendToList(v); err != nil {
+				return nil, err
+			}
+		case opAppends:
+			items, err := pd.popMark()
+			if err != nil {
+				return nil, err
+			}
+			if err := pd.appendToList(items...); err != nil {
+				return nil, err
+			}
+		case opEmptyTuple:
+			pd.stack = append(pd.stack, pickleTuple{})
+		case opTuple:
+			items, err := pd.popMark()
+			if err != nil {
+				return nil, err
+			}
+			pd.stack = append(pd.stack, pickleTuple(items))
+		case opTuple1, opTuple2, opTuple3:
+			n := int(op-opTuple1) + 1
+			if len(pd.stack) < n {
+				return nil, errPickleStackUnderflow
+			}
+			items := make([]any, n)
+			copy(items, pd.stack[len(pd.stack)-n:])
+			for _, item := range items {
+				if _, ok := item.(pickleMark); ok {
+					return nil, fmt.Errorf("unexpected mark inside TUPLE%d", n)
+				}
+			}
+			pd.stack = pd.stack[:len(pd.stack)-n]
+			pd.stack = append(pd.stack, pickleTuple(items))
+		case opPut:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			idx, err := strconv.ParseUint(line, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse PUT opcode arg: %w", err)
+			}
+			if err := pd.put(uint32(idx)); err != nil {
+				return nil, err
+			}
+		case opBinPut:
+			if len(src) < 1 {
+				return nil, errUnexpectedPickleEnd
+			}
+			if err := pd.put(uint32(src[0])); err != nil {
+				return nil, err
+			}
+			src = src[1:]
+		case opLongBinPut:
+			if len(src) < 4 {
+				return nil, errUnexpectedPickleEnd
+			}
+			if err := pd.put(binary.LittleEndian.Uint32(src)); err != nil {
+				return nil, err
+			}
+			src = src[4:]
+		case opMemoize:
+			if err := pd.put(uint32(len(pd.memo))); err != nil {
+				return nil, err
+			}
+		case opGet:
+			var line string
+			if line, src, err = readPickleLine(src); err != nil {
+				return nil, err
+			}
+			idx, err := strconv.ParseUint(line, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse GET opcode arg: %w", err)
+			}
+			if err := pd.get(uint32(idx)); err != nil {
+				return nil, err
+			}
+		case opBinGet:
+			if len(src) < 1 {
+				return nil, errUnexpectedPickleEnd
+			}
+			if err := pd.get(uint32(src[0])); err != nil {
+				return nil, err
+			}
+			src = src[1:]
+		case opLongBinGet:
+			if len(src) < 4 {
+				return nil, errUnexpectedPickleEnd
+			}
+			if err := pd.get(binary.LittleEndian.Uint32(src)); err != nil {
+				return nil, err
+			}
+			src = src[4:]
+		default:
+			return nil, fmt.Errorf("unsupported pickle opcode 0x%02x at position %d", op, len(data)-len(src)-1)
+		}
+	}
+	return nil, errUnexpectedPickleEnd
+}
+
+var (
+	errUnexpectedPickleEnd  = fmt.Errorf("unexpected end of pickle data")
+	errPickleStackUnderflow = fmt.Errorf("pickle stack underflow")
+)
+
+func (pd *pickleDecoder) pushBytes(src []byte, n uint64) ([]byte, error) {
+	if uint64(len(src)) < n {
+		return src, errUnexpectedPickleEnd
+	}
+	pd.stack = append(pd.stack, bytesutil.ToUnsafeString(src[:n]))
+	return src[n:], nil
+}
+
+func (pd *pickleDecoder) top() (any, error) {
+	if len(pd.stack) == 0 {
+		return nil, errPickleStackUnderflow
+	}
+	v := pd.stack[len(pd.stack)-1]
+	if _, ok := v.(pickleMark); ok {
+		return nil, fmt.Errorf("unexpected mark at the top of the stack")
+	}
+	return v, nil
+}
+
+func (pd *pickleDecoder) pop() (any, error) {
+	v, err := pd.top()
+	if err != nil {
+		return nil, err
+	}
+	pd.stack[len(pd.stack)-1] = nil
+	pd.stack = pd.stack[:len(pd.stack)-1]
+	return v, nil
+}
+
+// popMark pops all the items on the stack up to the last mark and returns them.
+func (pd *pickleDecoder) popMark() ([]any, error) {
+	if len(pd.marks) == 0 {
+		return nil, fmt.Errorf("missing MARK opcode")
+	}
+	n := pd.marks[len(pd.marks)-1]
+	pd.marks = pd.marks[:len(pd.marks)-1]
+	items := append([]any{}, pd.stack[n+1:]...)
+	clear(pd.stack[n:])
+	pd.stack = pd.stack[:n]
+	return items, nil
+}
+
+func (pd *pickleDecoder) appendToList(items ...any) error {
+	v, err := pd.top()
+	if err != nil {
+		return err
+	}
+	pl, ok := v.(*pickleList)
+	if !ok {
+		return fmt.Errorf("cannot append items to %s; want list", pickleTypeName(v))
+	}
+	pl.items = append(pl.items, items...)
+	return nil
+}
+
+func (pd *pickleDecoder) put(idx uint32) error {
+	v, err := pd.top()
+	if err != nil {
+		return err
+	}
+	pd.memo[idx] = v
+	return nil
+}
+
+func (pd *pickleDecoder) get(idx uint32) error {
+	v, ok := pd.memo[idx]
+	if !ok {
+		return fmt.Errorf("missing memo entry for index %d", idx)
+	}
+	pd.stack = append(pd.stack, v)
+	return nil
+}
+
+func readPickleLine(src []byte) (string, []byte, error) {
+	n := 0
+	for n < len(src) && src[n] != '\n' {
+		n++
+	}
+	if n == len(src) {
+		return "", src, errUnexpectedPickleEnd
+	}
+	line := bytesutil.ToUnsafeString(src[:n])
+	line = strings.TrimSuffix(line, "\r")
+	return line, src[n+1:], nil
+}
+
+// decodePickleLong decodes little-endian two's complement integer from b.
+//
+// Integers, which do not fit int64, are returned as float64.
+func decodePickleLong(b []byte) any {
+	if len(b) == 0 {
+		return int64(0)
+	}
+	if len(b) <= 8 {
+		var n uint64
+		for i := len(b) - 1; i >= 0; i-- {
+			n = n<<8 | uint64(b[i])
+		}
+		if b[len(b)-1]&0x80 != 0 && len(b) < 8 {
+			// Sign-extend negative numbers.
+			n |= math.MaxUint64 << (8 * len(b))
+		}
+		return int64(n)
+	}
+	negative := b[len(b)-1]&0x80 != 0
+	f := float64(0)
+	for i := len(b) - 1; i >= 0; i-- {
+		c := b[i]
+		if negative {
+			c = ^c
+		}
+		f = f*256 + float64(c)
+	}
+	if negative {
+		f = -(f + 1)
+	}
+	return f
+}
+
+// unquotePickleString unquotes Python string repr such as 'foo\\nbar' used in protocol 0 STRING opcode.
+func unquotePickleString(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("string must be quoted; got %q", s)
+	}
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			b = append(b, s[0])
+			s = s[1:]
+			continue
+		}
+		if len(s) < 2 {
+			return "", fmt.Errorf("unexpected trailing backslash")
+		}
+		switch s[1] {
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case '\\', '\'', '"':
+			b = append(b, s[1])
+		case 'x':
+			if len(s) < 4 {
+				return "", fmt.Errorf("too short \\x escape sequence")
+			}
+			n, err := strconv.ParseUint(s[2:4], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("cannot parse \\x escape sequence: %w", err)
+			}
+			b = append(b, byte(n))
+			s = s[4:]
+			continue
+		default:
+			return "", fmt.Errorf("unsupported escape sequence \\%c", s[1])
+		}
+		s = s[2:]
+	}
+	return string(b), nil
+}
+
+// unescapePickleUnicode decodes raw-unicode-escape string used in protocol 0 UNICODE opcode.
+func unescapePickleUnicode(s string) (string, error) {
+	if !strings.Contains(s, `\u`) && !strings.Contains(s, `\U`) {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		if len(s) >= 2 && s[0] == '\\' && (s[1] == 'u' || s[1] == 'U') {
+			n := 4
+			if s[1] == 'U' {
+				n = 8
+			}
+			if len(s) < 2+n {
+				return "", fmt.Errorf("too short \\%c escape sequence", s[1])
+			}
+			r, err := strconv.ParseUint(s[2:2+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("cannot parse \\%c escape sequence: %w", s[1], err)
+			}
+			b = append(b, string(rune(r))...)
+			s = s[2+n:]
+			continue
+		}
+		b = append(b, s[0])
+		s = s[1:]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,161 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalPickle_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalPickle([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", data)
+		}
+	}
+
+	// empty data
+	f("")
+
+	// missing STOP opcode
+	f("\x80\x02]q\x00")
+
+	// trailing data after STOP
+	f("\x80\x02]q\x00.foo")
+
+	// unsupported protocol
+	f("\x80\x06]q\x00.")
+
+	// GLOBAL opcode must be rejected in order to avoid code execution
+	f("\x80\x02cposix\nsystem\nq\x00.")
+
+	// REDUCE opcode must be rejected
+	f("\x80\x02]q\x00)R.")
+
+	// dicts aren't supported
+	f("\x80\x02}q\x00X\x01\x00\x00\x00aq\x01K\x01s.")
+
+	// not a list at the top level
+	f("\x80\x02K\x01.")
+
+	// truncated string
+	f("\x80\x02]q\x00X\x07\x00\x00\x00foo.")
+
+	// stack underflow
+	f("\x80\x02\x86.")
+
+	// missing mark
+	f("\x80\x02]q\x00e.")
+
+	// missing memo entry
+	f("\x80\x02h\x05.")
+
+	// append to non-list
+	f("\x80\x02K\x01K\x02a.")
+}
+
+func TestRowsUnmarshalPickle_Success(t *testing.T) {
+	f := func(data string, rowsExpected []Row) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalPickle([]byte(data)); err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", data, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rows.UnmarshalPickle([]byte(data)); err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", data, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows on the second unmarshal;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+	}
+
+	// empty list
+	f("\x80\x02]q\x00.", nil)
+
+	// The following data is generated with Python's pickle.dumps for protocols 0 to 5:
+	//
+	// [('foo.bar', (1700000000, 1.5)), ('baz;tag1=v1;tag2=v2', (1700000001.7, 42)), ('x', [123, '3.25'])]
+	rowsExpected := []Row{
+		{
+			Metric:    "foo.bar",
+			Value:     1.5,
+			Timestamp: 1700000000,
+		},
+		{
+			Metric: "baz",
+			Tags: []Tag{
+				{
+					Key:   "tag1",
+					Value: "v1",
+				},
+				{
+					Key:   "tag2",
+					Value: "v2",
+				},
+			},
+			Value:     42,
+			Timestamp: 1700000001,
+		},
+		{
+			Metric:    "x",
+			Value:     3.25,
+			Timestamp: 123,
+		},
+	}
+	f("(lp0\n(Vfoo.bar\np1\n(I1700000000\nF1.5\ntp2\ntp3\na(Vbaz;tag1=v1;tag2=v2\np4\n(F1700000001.7\nI42\ntp5\ntp6\na(Vx\np7\n(lp8\nI123\naV3.25\np9\natp10\na.", rowsExpected)
+	f("]q\x00((X\x07\x00\x00\x00foo.barq\x01(J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00tq\x02tq\x03(X\x13\x00\x00\x00baz;tag1=v1;tag2=v2q\x04(GA\xd9T\xfc@l\xcc\xcdK*tq\x05tq\x06(X\x01\x00\x00\x00xq\x07]q\x08(K{X\x04\x00\x00\x003.25q\x09etq\ne.", rowsExpected)
+	f("\x80\x02]q\x00(X\x07\x00\x00\x00foo.barq\x01J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x13\x00\x00\x00baz;tag1=v1;tag2=v2q\x04GA\xd9T\xfc@l\xcc\xcdK*\x86q\x05\x86q\x06X\x01\x00\x00\x00xq\x07]q\x08(K{X\x04\x00\x00\x003.25q\x09e\x86q\ne.", rowsExpected)
+	f("\x80\x03]q\x00(X\x07\x00\x00\x00foo.barq\x01J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x13\x00\x00\x00baz;tag1=v1;tag2=v2q\x04GA\xd9T\xfc@l\xcc\xcdK*\x86q\x05\x86q\x06X\x01\x00\x00\x00xq\x07]q\x08(K{X\x04\x00\x00\x003.25q\x09e\x86q\ne.", rowsExpected)
+	f("\x80\x04\x95Y\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x07foo.bar\x94J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94\x8c\x13baz;tag1=v1;tag2=v2\x94GA\xd9T\xfc@l\xcc\xcdK*\x86\x94\x86\x94\x8c\x01x\x94]\x94(K{\x8c\x043.25\x94e\x86\x94e.", rowsExpected)
+	f("\x80\x05\x95Y\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x07foo.bar\x94J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94\x8c\x13baz;tag1=v1;tag2=v2\x94GA\xd9T\xfc@l\xcc\xcdK*\x86\x94\x86\x94\x8c\x01x\x94]\x94(K{\x8c\x043.25\x94e\x86\x94e.", rowsExpected)
+
+	// Python 2 STRING opcode
+	f("(lp0\n(S'foo.bar'\np1\n(I1\nF2.5\ntp2\ntp3\na.", []Row{{
+		Metric:    "foo.bar",
+		Value:     2.5,
+		Timestamp: 1,
+	}})
+
+	// escaped UNICODE opcode
+	f("(lp0\n(Va'\"\\u000a\np1\n(I1\nI2\ntp2\ntp3\na.", []Row{{
+		Metric:    "a'\"\n",
+		Value:     2,
+		Timestamp: 1,
+	}})
+
+	// negative values
+	f("\x80\x02]q\x00X\x01\x00\x00\x00aq\x01K\x01J\xd4\xfe\xff\xff\x86q\x02\x86q\x03a.", []Row{{
+		Metric:    "a",
+		Value:     -300,
+		Timestamp: 1,
+	}})
+	f("(lp0\n(Va\np1\n(I1\nI-300\ntp2\ntp3\na.", []Row{{
+		Metric:    "a",
+		Value:     -300,
+		Timestamp: 1,
+	}})
+
+	// LONG1 opcode
+	f("\x80\x02]q\x00X\x01\x00\x00\x00aq\x01K\x01\x8a\x06\x00\x00\x00\x00\x00\xff\x86q\x02\x86q\x03a.", []Row{{
+		Metric:    "a",
+		Value:     -(1 << 40),
+		Timestamp: 1,
+	}})
+	f("\x80\x02]q\x00X\x01\x00\x00\x00aq\x01J\xfb\xff\xff\xff\x8a\x09\x00\x00\x00\x00\x00\x00\x00\x00@\x86q\x02\x86q\x03a.", []Row{{
+		Metric:    "a",
+		Value:     1 << 70,
+		Timestamp: -5,
+	}})
+
+	// invalid items are skipped: [('a', (1, 5)), ('b',), ('', (1, 2)), ('c', (1, None))]
+	f("\x80\x02]q\x00(X\x01\x00\x00\x00aq\x01K\x01K\x05\x86q\x02\x86q\x03X\x01\x00\x00\x00bq\x04\x85q\x05X\x00\x00\x00\x00q\x06K\x01K\x02\x86q\x07\x86q\x08X\x01\x00\x00\x00cq\x09K\x01N\x86q\n\x86q\x0be.", []Row{{
+		Metric:    "a",
+		Value:     5,
+		Timestamp: 1,
+	}})
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/graphite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var maxPickleMessageSize = flagutil.NewBytes("graphite.maxPickleMessageSize", 1024*1024, "The maximum size in bytes of a single Graphite pickle message. "+
+	"See https://docs.victoriametrics.com/#how-to-send-data-from-graphite-compatible-agents-such-as-statsd")
+
+// ParsePickle parses Graphite pickle messages from r and calls callback for the parsed rows.
+//
+// Every message must be prefixed with its length encoded as 4-byte big-endian unsigned integer.
+// See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-pickle-protocol
+//
+// The callback can be called concurrently multiple times for streamed data from r.
+//
+// callback shouldn't hold rows after returning.
+func ParsePickle(r io.Reader, callback func(rows []graphite.Row) error) error {
+	wcr := writeconcurrencylimiter.GetReader(r)
+	defer writeconcurrencylimiter.PutReader(wcr)
+	r = wcr
+
+	ctx := getStreamContext(r)
+	defer putStreamContext(ctx)
+
+	for ctx.readPickle() {
+		uw := getUnmarshalWork()
+		uw.ctx = ctx
+		uw.callback = callback
+		uw.isPickle = true
+		uw.reqBuf, ctx.reqBuf = ctx.reqBuf, uw.reqBuf
+		ctx.wg.Add(1)
+		common.ScheduleUnmarshalWork(uw)
+		wcr.DecConcurrency()
+	}
+	ctx.wg.Wait()
+	if err := ctx.Error(); err != nil {
+		return err
+	}
+	return ctx.callbackErr
+}
+
+func (ctx *streamContext) readPickle() bool {
+	readCalls.Inc()
+	if ctx.err != nil || ctx.hasCallbackError() {
+		return false
+	}
+	ctx.reqBuf, ctx.err = readPickleMessage(ctx.br, ctx.reqBuf[:0])
+	if ctx.err != nil {
+		if ctx.err != io.EOF {
+			readErrors.Inc()
+			ctx.err = fmt.Errorf("cannot read graphite pickle protocol data: %w", ctx.err)
+		}
+		return false
+	}
+	return true
+}
+
+func readPickleMessage(r io.Reader, dst []byte) ([]byte, error) {
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return dst, fmt.Errorf("cannot read message size: %w", err)
+		}
+		// Return io.EOF as is, so the caller could detect the end of stream.
+		return dst, err
+	}
+	size := binary.BigEndian.Uint32(sizeBuf[:])
+	if size == 0 {
+		return dst, fmt.Errorf("unexpected zero message size")
+	}
+	if maxSize := maxPickleMessageSize.IntN(); int64(size) > int64(maxSize) {
+		tooBigPickleMessages.Inc()
+		return dst, fmt.Errorf("too big message size: %d bytes; the maximum supported size is %d bytes; "+
+			"increase -graphite.maxPickleMessageSize command-line flag value if needed", size, maxSize)
+	}
+	dstLen := len(dst)
+	dst = bytesutil.ResizeWithCopyMayOverallocate(dst, dstLen+int(size))
+	if _, err := io.ReadFull(r, dst[dstLen:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return dst[:dstLen], fmt.Errorf("cannot read message with size %d bytes: %w", size, err)
+	}
+	return dst, nil
+}
+
+var (
+	tooBigPickleMessages  = metrics.NewCounter(`vm_protoparser_graphite_pickle_too_big_messages_total`)
+	invalidPickleMessages = metrics.NewCounter(`vm_rows_invalid_total{type="graphite_pickle"}`)
+)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/graphite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
@@ -137,6 +138,7 @@ type unmarshalWork struct {
 	ctx      *streamContext
 	callback func(rows []graphite.Row) error
 	reqBuf   []byte
+	isPickle bool
 }
 
 func (uw *unmarshalWork) reset() {
@@ -144,6 +146,7 @@ func (uw *unmarshalWork) reset() {
 	uw.ctx = nil
 	uw.callback = nil
 	uw.reqBuf = uw.reqBuf[:0]
+	uw.isPickle = false
 }
 
 func (uw *unmarshalWork) runCallback(rows []graphite.Row) {
@@ -160,7 +163,15 @@ func (uw *unmarshalWork) runCallback(rows []graphite.Row) {
 
 // Unmarshal implements common.UnmarshalWork
 func (uw *unmarshalWork) Unmarshal() {
-	uw.rows.Unmarshal(bytesutil.ToUnsafeString(uw.reqBuf))
+	if uw.isPickle {
+		if err := uw.rows.UnmarshalPickle(uw.reqBuf); err != nil {
+			// Do not stop reading the stream, since the next pickle messages may be valid.
+			invalidPickleMessages.Inc()
+			logger.Errorf("cannot unmarshal Graphite pickle message: %s", err)
+		}
+	} else {
+		uw.rows.Unmarshal(bytesutil.ToUnsafeString(uw.reqBuf))
+	}
 	rows := uw.rows.Rows
 	rowsRead.Add(len(rows))
 
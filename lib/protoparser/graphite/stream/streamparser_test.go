@@ -1,11 +1,16 @@
 package stream
 
 import (
+	"bytes"
+	"encoding/binary"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/graphite"
 )
 
@@ -76,3 +81,87 @@ func TestStreamContextRead(t *testing.T) {
 		}},
 	})
 }
+
+func TestParsePickle(t *testing.T) {
+	f := func(messages []string, rowsExpected []graphite.Row) {
+		t.Helper()
+		var data []byte
+		for _, msg := range messages {
+			data = binary.BigEndian.AppendUint32(data, uint32(len(msg)))
+			data = append(data, msg...)
+		}
+		var rows []graphite.Row
+		var mu sync.Mutex
+		err := ParsePickle(bytes.NewReader(data), func(rs []graphite.Row) error {
+			mu.Lock()
+			for _, r := range rs {
+				r.Metric = strings.Clone(r.Metric)
+				if len(r.Tags) > 0 {
+					r.Tags = append([]graphite.Tag{}, r.Tags...)
+				}
+				rows = append(rows, r)
+			}
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Metric < rows[j].Metric
+		})
+		if !reflect.DeepEqual(rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rows, rowsExpected)
+		}
+	}
+
+	common.StartUnmarshalWorkers()
+	defer common.StopUnmarshalWorkers()
+
+	// [('foo;bar=baz', (1700000000, 1.5))]
+	msg1 := "\x80\x02]q\x00X\x0b\x00\x00\x00foo;bar=bazq\x01J\x00\xf1SeG?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03a."
+	// [('aaa', (1700000010, 2))]
+	msg2 := "\x80\x02]q\x00X\x03\x00\x00\x00aaaq\x01J\x0a\xf1SeK\x02\x86q\x02\x86q\x03a."
+	// invalid message must be skipped
+	msg3 := "\x80\x02cposix\nsystem\nq\x00."
+	f([]string{msg1, msg3, msg2}, []graphite.Row{
+		{
+			Metric:    "aaa",
+			Value:     2,
+			Timestamp: 1700000010 * 1000,
+		},
+		{
+			Metric: "foo",
+			Tags: []graphite.Tag{{
+				Key:   "bar",
+				Value: "baz",
+			}},
+			Value:     1.5,
+			Timestamp: 1700000000 * 1000,
+		},
+	})
+}
+
+func TestParsePickle_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		err := ParsePickle(strings.NewReader(data), func(_ []graphite.Row) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// truncated size
+	f("\x00\x00")
+
+	// zero size
+	f("\x00\x00\x00\x00")
+
+	// too big size
+	f("\xff\x00\x00\x00")
+
+	// truncated message
+	f("\x00\x00\x00\x10\x80\x02")
+}
@@ -1,10 +1,13 @@
 package firehose
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
 )
 
@@ -26,3 +29,36 @@ func WriteSuccessResponse(w http.ResponseWriter, r *http.Request) {
 	h.Set("Content-Length", fmt.Sprintf("%d", len(body)))
 	w.Write([]byte(body))
 }
+
+// WriteErrorResponse writes error response for AWS Firehose request and logs the error.
+//
+// The response contains errorMessage field, so Firehose could show it in the delivery error logs.
+// The status code is taken from httpserver.ErrorWithStatusCode if err contains it. Otherwise http.StatusBadRequest is used.
+//
+// See https://docs.aws.amazon.com/firehose/latest/dev/httpdeliveryrequestresponse.html#responseformat
+func WriteErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	requestID := r.Header.Get("X-Amz-Firehose-Request-Id")
+	if requestID == "" {
+		// This isn't a AWS firehose request - fall back to the usual error response.
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	remoteAddr := httpserver.GetQuotedRemoteAddr(r)
+	requestURI := httpserver.GetRequestURI(r)
+	logger.Warnf("remoteAddr: %s; requestURI: %s; %s", remoteAddr, requestURI, err)
+
+	statusCode := http.StatusBadRequest
+	var esc *httpserver.ErrorWithStatusCode
+	if errors.As(err, &esc) {
+		statusCode = esc.StatusCode
+	}
+
+	body := fmt.Sprintf(`{"requestId":%s,"timestamp":%d,"errorMessage":%s}`, stringsutil.JSONString(requestID), time.Now().UnixMilli(), stringsutil.JSONString(err.Error()))
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.WriteHeader(statusCode)
+	w.Write([]byte(body))
+}
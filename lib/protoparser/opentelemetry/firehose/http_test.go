@@ -0,0 +1,61 @@
+package firehose
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+)
+
+func TestWriteSuccessResponse(t *testing.T) {
+	f := func(requestID string, statusCodeExpected int, bodyExpectedRe string) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if requestID != "" {
+			r.Header.Set("X-Amz-Firehose-Request-Id", requestID)
+		}
+		w := httptest.NewRecorder()
+		WriteSuccessResponse(w, r)
+		if w.Code != statusCodeExpected {
+			t.Fatalf("unexpected status code; got %d; want %d", w.Code, statusCodeExpected)
+		}
+		if body := w.Body.String(); !regexp.MustCompile(bodyExpectedRe).MatchString(body) {
+			t.Fatalf("unexpected response body; got %q; want matching %q", body, bodyExpectedRe)
+		}
+	}
+	f("", http.StatusOK, `^$`)
+	f("foo", http.StatusOK, `^\{"requestId":"foo","timestamp":\d+\}$`)
+}
+
+func TestWriteErrorResponse(t *testing.T) {
+	f := func(requestID string, err error, statusCodeExpected int, bodyExpectedRe string) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if requestID != "" {
+			r.Header.Set("X-Amz-Firehose-Request-Id", requestID)
+		}
+		w := httptest.NewRecorder()
+		WriteErrorResponse(w, r, err)
+		if w.Code != statusCodeExpected {
+			t.Fatalf("unexpected status code; got %d; want %d", w.Code, statusCodeExpected)
+		}
+		if body := w.Body.String(); !regexp.MustCompile(bodyExpectedRe).MatchString(body) {
+			t.Fatalf("unexpected response body; got %q; want matching %q", body, bodyExpectedRe)
+		}
+	}
+
+	// non-Firehose request
+	f("", fmt.Errorf("foo"), http.StatusBadRequest, `foo`)
+
+	// Firehose request
+	f("bar", fmt.Errorf(`foo "baz"`), http.StatusBadRequest, `^\{"requestId":"bar","timestamp":\d+,"errorMessage":"foo \\"baz\\""\}$`)
+	f("bar", &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("retry later"),
+		StatusCode: http.StatusTooManyRequests,
+	}, http.StatusTooManyRequests, `^\{"requestId":"bar","timestamp":\d+,"errorMessage":"retry later"\}$`)
+}
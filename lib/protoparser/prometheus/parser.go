@@ -114,6 +114,12 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 	r.reset()
 	s = skipLeadingWhitespace(s)
 	n := strings.IndexByte(s, '{')
+	if n >= 0 {
+		if m := nextWhitespace(s); m >= 0 && m < n && len(skipLeadingWhitespace(s[m:n])) > 0 {
+			// The '{' belongs to OpenMetrics exemplar after the value, e.g. `foo 123 # {trace_id="abc"} 1`.
+			n = -1
+		}
+	}
 	if n >= 0 {
 		// Tags found. Parse them.
 		r.Metric = skipTrailingWhitespace(s[:n])
@@ -440,6 +446,17 @@ func GetRowsDiff(s1, s2 string) string {
 	}
 }
 
+// AppendSeries appends series from rows to dst in Prometheus text exposition format and returns the result.
+//
+// The appended rows have default value 0 and have no timestamps, so the result can be passed to GetRowsDiff.
+func AppendSeries(dst []byte, rows []Row) []byte {
+	for i := range rows {
+		dst = marshalMetricNameWithTags(dst, &rows[i])
+		dst = append(dst, " 0\n"...)
+	}
+	return dst
+}
+
 type linesIterator struct {
 	rows     []Row
 	a        []string
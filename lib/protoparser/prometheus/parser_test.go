@@ -24,6 +24,21 @@ func TestGetRowsDiff(t *testing.T) {
 	f("foo{bar=\"baz\"} 123\nx 3.4 5\ny 5 6", "x 34 342", "foo{bar=\"baz\"} 0\ny 0\n")
 }
 
+func TestAppendSeries(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		var rows Rows
+		rows.Unmarshal(s)
+		result := AppendSeries(nil, rows.Rows)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result for AppendSeries(%q); got %q; want %q", s, result, resultExpected)
+		}
+	}
+	f("", "")
+	f("foo 123", "foo 0\n")
+	f("foo{bar=\"b\\\"az\"} 1 123\nx 3.4", "foo{bar=\"b\\\"az\"} 0\nx 0\n")
+}
+
 func TestAreIdenticalSeriesFast(t *testing.T) {
 	f := func(s1, s2 string, resultExpected bool) {
 		t.Helper()
@@ -394,6 +409,24 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 		},
 	})
 
+	// OpenMetrics created timestamps and EOF marker
+	f(`# TYPE foo counter
+foo_total 17.0 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67
+foo_created 1520430000.123
+# EOF`, &Rows{
+		Rows: []Row{
+			{
+				Metric:    "foo_total",
+				Value:     17,
+				Timestamp: 1520879607789,
+			},
+			{
+				Metric: "foo_created",
+				Value:  1520430000.123,
+			},
+		},
+	})
+
 	// "Infinity" word - this has been added in OpenMetrics.
 	// See https://github.com/OpenObservability/OpenMetrics/blob/master/OpenMetrics.md
 	// Checks for https://github.com/VictoriaMetrics/VictoriaMetrics/issues/924
@@ -0,0 +1,838 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// IsProtobufContentType returns true if contentType corresponds to the Prometheus protobuf exposition format.
+//
+// See https://github.com/prometheus/docs/blob/main/content/docs/instrumenting/exposition_formats.md#protobuf-format
+func IsProtobufContentType(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType != "application/vnd.google.protobuf" {
+		return false
+	}
+	return params["proto"] == "io.prometheus.client.MetricFamily" && (params["encoding"] == "" || params["encoding"] == "delimited")
+}
+
+// AppendTextFromProtobuf converts length-delimited io.prometheus.client.MetricFamily messages from src
+// to Prometheus text exposition format, appends the result to dst and returns it.
+//
+// See Rows.UnmarshalProtobuf for details on the conversion.
+func AppendTextFromProtobuf(dst, src []byte) ([]byte, error) {
+	var rs Rows
+	if err := rs.UnmarshalProtobuf(src); err != nil {
+		return dst, err
+	}
+	for i := range rs.Rows {
+		r := &rs.Rows[i]
+		dst = marshalMetricNameWithTags(dst, r)
+		dst = append(dst, ' ')
+		dst = append(dst, formatFloat(r.Value)...)
+		if r.Timestamp != 0 {
+			dst = append(dst, ' ')
+			dst = strconv.AppendInt(dst, r.Timestamp, 10)
+		}
+		dst = append(dst, '\n')
+	}
+	return dst, nil
+}
+
+// UnmarshalProtobuf unmarshals length-delimited io.prometheus.client.MetricFamily messages from src into rs.
+//
+// Counters, summaries and histograms with created timestamps get additional `<name>_created` samples
+// with the created timestamp in seconds. Native histograms are converted to buckets with `vmrange` labels,
+// while classic histograms are converted to buckets with `le` labels. Exemplars are skipped.
+//
+// rs refers to src, so src mustn't be changed while rs is in use.
+//
+// See https://github.com/prometheus/client_model/blob/master/io/prometheus/client/metrics.proto
+func (rs *Rows) UnmarshalProtobuf(src []byte) error {
+	rs.Reset()
+	var m pbMetric
+	for len(src) > 0 {
+		n, tail, ok := easyproto.UnmarshalMessageLen(src)
+		if !ok {
+			return fmt.Errorf("cannot read MetricFamily message length")
+		}
+		if n > len(tail) {
+			return fmt.Errorf("too short buffer for MetricFamily message; got %d bytes; want at least %d bytes", len(tail), n)
+		}
+		if err := rs.unmarshalMetricFamily(tail[:n], &m); err != nil {
+			return fmt.Errorf("cannot unmarshal MetricFamily: %w", err)
+		}
+		src = tail[n:]
+	}
+	return nil
+}
+
+// Metric types from io.prometheus.client.MetricType
+const (
+	pbMetricTypeCounter        = 0
+	pbMetricTypeGauge          = 1
+	pbMetricTypeSummary        = 2
+	pbMetricTypeUntyped        = 3
+	pbMetricTypeHistogram      = 4
+	pbMetricTypeGaugeHistogram = 5
+)
+
+func (rs *Rows) unmarshalMetricFamily(src []byte, m *pbMetric) error {
+	// message MetricFamily {
+	//   string     name   = 1;
+	//   string     help   = 2;
+	//   MetricType type   = 3;
+	//   repeated Metric metric = 4;
+	//   string     unit   = 5;
+	// }
+	var name string
+	var metricType int32
+	var fc easyproto.FieldContext
+	tail := src
+	for len(tail) > 0 {
+		var err error
+		tail, err = fc.NextField(tail)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			s, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read name")
+			}
+			name = s
+		case 3:
+			n, ok := fc.Int32()
+			if !ok {
+				return fmt.Errorf("cannot read type for %q", name)
+			}
+			metricType = n
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("missing metric name")
+	}
+	var names pbMetricNames
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum != 4 {
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return fmt.Errorf("cannot read metric data for %q", name)
+		}
+		if err := m.unmarshalProtobuf(data); err != nil {
+			return fmt.Errorf("cannot unmarshal metric for %q: %w", name, err)
+		}
+		names.init(name, metricType)
+		rs.appendMetric(m, &names, metricType)
+	}
+	return nil
+}
+
+// pbMetricNames contains names for series generated from a single MetricFamily.
+//
+// The names are initialized only once per MetricFamily in order to avoid memory allocations per every sample.
+type pbMetricNames struct {
+	name    string
+	bucket  string
+	sum     string
+	count   string
+	created string
+}
+
+func (mn *pbMetricNames) init(name string, metricType int32) {
+	if mn.name != "" {
+		return
+	}
+	mn.name = name
+	switch metricType {
+	case pbMetricTypeCounter:
+		mn.created = strings.TrimSuffix(name, "_total") + "_created"
+	case pbMetricTypeSummary, pbMetricTypeHistogram, pbMetricTypeGaugeHistogram:
+		mn.bucket = name + "_bucket"
+		mn.sum = name + "_sum"
+		mn.count = name + "_count"
+		mn.created = name + "_created"
+	}
+}
+
+type pbMetric struct {
+	labels []Tag
+
+	hasTimestamp bool
+	timestamp    int64
+
+	// valueFieldNum is the Metric field number for the message with the value - gauge, counter or untyped.
+	// It is set to 0 if the value is missing.
+	valueFieldNum uint32
+	value         float64
+
+	hasCreated bool
+	created    float64
+
+	hasSummary bool
+	quantiles  []pbQuantile
+
+	hasHistogram bool
+	histogram    pbHistogram
+
+	count float64
+	sum   float64
+}
+
+type pbQuantile struct {
+	quantile float64
+	value    float64
+}
+
+type pbBucket struct {
+	upperBound float64
+	count      float64
+}
+
+type pbSpan struct {
+	offset int32
+	length uint32
+}
+
+type pbHistogram struct {
+	buckets []pbBucket
+
+	schema        int32
+	zeroThreshold float64
+	zeroCount     float64
+
+	negativeSpans  []pbSpan
+	negativeDeltas []int64
+	negativeCounts []float64
+
+	positiveSpans  []pbSpan
+	positiveDeltas []int64
+	positiveCounts []float64
+}
+
+func (m *pbMetric) reset() {
+	clear(m.labels)
+	m.labels = m.labels[:0]
+
+	m.hasTimestamp = false
+	m.timestamp = 0
+
+	m.valueFieldNum = 0
+	m.value = 0
+
+	m.hasCreated = false
+	m.created = 0
+
+	m.hasSummary = false
+	m.quantiles = m.quantiles[:0]
+
+	m.hasHistogram = false
+	h := &m.histogram
+	h.buckets = h.buckets[:0]
+	h.schema = 0
+	h.zeroThreshold = 0
+	h.zeroCount = 0
+	h.negativeSpans = h.negativeSpans[:0]
+	h.negativeDeltas = h.negativeDeltas[:0]
+	h.negativeCounts = h.negativeCounts[:0]
+	h.positiveSpans = h.positiveSpans[:0]
+	h.positiveDeltas = h.positiveDeltas[:0]
+	h.positiveCounts = h.positiveCounts[:0]
+
+	m.count = 0
+	m.sum = 0
+}
+
+func (m *pbMetric) unmarshalProtobuf(src []byte) error {
+	// message Metric {
+	//   repeated LabelPair label        = 1;
+	//   Gauge              gauge        = 2;
+	//   Counter            counter      = 3;
+	//   Summary            summary      = 4;
+	//   Untyped            untyped      = 5;
+	//   Histogram          histogram    = 7;
+	//   int64              timestamp_ms = 6;
+	// }
+	m.reset()
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read label")
+			}
+			if err := m.unmarshalLabel(data); err != nil {
+				return fmt.Errorf("cannot unmarshal label: %w", err)
+			}
+		case 2, 5:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read gauge or untyped value")
+			}
+			if err := m.unmarshalValue(data, fc.FieldNum, 0); err != nil {
+				return fmt.Errorf("cannot unmarshal gauge or untyped value: %w", err)
+			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read counter")
+			}
+			if err := m.unmarshalValue(data, fc.FieldNum, 3); err != nil {
+				return fmt.Errorf("cannot unmarshal counter: %w", err)
+			}
+		case 4:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read summary")
+			}
+			if err := m.unmarshalSummary(data); err != nil {
+				return fmt.Errorf("cannot unmarshal summary: %w", err)
+			}
+		case 6:
+			ts, ok := fc.Int64()
+			if !ok {
+				return fmt.Errorf("cannot read timestamp_ms")
+			}
+			m.hasTimestamp = true
+			m.timestamp = ts
+		case 7:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read histogram")
+			}
+			if err := m.unmarshalHistogram(data); err != nil {
+				return fmt.Errorf("cannot unmarshal histogram: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *pbMetric) unmarshalLabel(src []byte) error {
+	// message LabelPair {
+	//   string name  = 1;
+	//   string value = 2;
+	// }
+	var name, value string
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			s, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read label name")
+			}
+			name = s
+		case 2:
+			s, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read label value")
+			}
+			value = s
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("label name cannot be empty")
+	}
+	m.labels = append(m.labels, Tag{
+		Key:   name,
+		Value: value,
+	})
+	return nil
+}
+
+// unmarshalValue unmarshals Gauge, Untyped or Counter message from src.
+//
+// valueFieldNum is the Metric field number for the message. createdFieldNum is the field number for created_timestamp.
+// It is set to 0 for messages without created timestamp.
+func (m *pbMetric) unmarshalValue(src []byte, valueFieldNum, createdFieldNum uint32) error {
+	// message Gauge {
+	//   double value = 1;
+	// }
+	//
+	// message Counter {
+	//   double                    value             = 1;
+	//   Exemplar                  exemplar          = 2;
+	//   google.protobuf.Timestamp created_timestamp = 3;
+	// }
+	m.valueFieldNum = valueFieldNum
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch {
+		case fc.FieldNum == 1:
+			v, ok := fc.Double()
+			if !ok {
+				return fmt.Errorf("cannot read value")
+			}
+			m.value = v
+		case createdFieldNum > 0 && fc.FieldNum == createdFieldNum:
+			if err := m.unmarshalCreated(&fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *pbMetric) unmarshalCreated(fc *easyproto.FieldContext) error {
+	// message Timestamp {
+	//   int64 seconds = 1;
+	//   int32 nanos   = 2;
+	// }
+	data, ok := fc.MessageData()
+	if !ok {
+		return fmt.Errorf("cannot read created_timestamp")
+	}
+	var secs int64
+	var nsecs int32
+	var tfc easyproto.FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = tfc.NextField(data)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field in created_timestamp: %w", err)
+		}
+		switch tfc.FieldNum {
+		case 1:
+			secs, ok = tfc.Int64()
+			if !ok {
+				return fmt.Errorf("cannot read created_timestamp seconds")
+			}
+		case 2:
+			nsecs, ok = tfc.Int32()
+			if !ok {
+				return fmt.Errorf("cannot read created_timestamp nanos")
+			}
+		}
+	}
+	m.hasCreated = true
+	m.created = float64(secs) + float64(nsecs)/1e9
+	return nil
+}
+
+func (m *pbMetric) unmarshalSummary(src []byte) error {
+	// message Summary {
+	//   uint64                    sample_count      = 1;
+	//   double                    sample_sum        = 2;
+	//   repeated Quantile         quantile          = 3;
+	//   google.protobuf.Timestamp created_timestamp = 4;
+	// }
+	m.hasSummary = true
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			n, ok := fc.Uint64()
+			if !ok {
+				return fmt.Errorf("cannot read sample_count")
+			}
+			m.count = float64(n)
+		case 2:
+			v, ok := fc.Double()
+			if !ok {
+				return fmt.Errorf("cannot read sample_sum")
+			}
+			m.sum = v
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read quantile")
+			}
+			// message Quantile {
+			//   double quantile = 1;
+			//   double value    = 2;
+			// }
+			var q pbQuantile
+			var qfc easyproto.FieldContext
+			for len(data) > 0 {
+				data, err = qfc.NextField(data)
+				if err != nil {
+					return fmt.Errorf("cannot read the next field in quantile: %w", err)
+				}
+				switch qfc.FieldNum {
+				case 1:
+					q.quantile, ok = qfc.Double()
+				case 2:
+					q.value, ok = qfc.Double()
+				}
+				if !ok {
+					return fmt.Errorf("cannot read quantile value")
+				}
+			}
+			m.quantiles = append(m.quantiles, q)
+		case 4:
+			if err := m.unmarshalCreated(&fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *pbMetric) unmarshalHistogram(src []byte) error {
+	// message Histogram {
+	//   uint64                    sample_count           = 1;
+	//   double                    sample_count_float     = 4;
+	//   double                    sample_sum             = 2;
+	//   repeated Bucket           bucket                 = 3;
+	//   google.protobuf.Timestamp created_timestamp      = 15;
+	//   sint32                    schema                 = 5;
+	//   double                    zero_threshold         = 6;
+	//   uint64                    zero_count             = 7;
+	//   double                    zero_count_float       = 8;
+	//   repeated BucketSpan       negative_span          = 9;
+	//   repeated sint64           negative_delta         = 10;
+	//   repeated double           negative_count         = 11;
+	//   repeated BucketSpan       positive_span          = 12;
+	//   repeated sint64           positive_delta         = 13;
+	//   repeated double           positive_count         = 14;
+	//   repeated Exemplar         exemplars              = 16;
+	// }
+	m.hasHistogram = true
+	h := &m.histogram
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			if n > 0 {
+				m.count = float64(n)
+			}
+		case 4:
+			var f float64
+			f, ok = fc.Double()
+			if f > 0 {
+				m.count = f
+			}
+		case 2:
+			m.sum, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				if err := h.unmarshalBucket(data); err != nil {
+					return fmt.Errorf("cannot unmarshal bucket: %w", err)
+				}
+			}
+		case 15:
+			if err := m.unmarshalCreated(&fc); err != nil {
+				return err
+			}
+		case 5:
+			h.schema, ok = fc.Sint32()
+		case 6:
+			h.zeroThreshold, ok = fc.Double()
+		case 7:
+			var n uint64
+			n, ok = fc.Uint64()
+			if n > 0 {
+				h.zeroCount = float64(n)
+			}
+		case 8:
+			var f float64
+			f, ok = fc.Double()
+			if f > 0 {
+				h.zeroCount = f
+			}
+		case 9, 12:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				span, err := unmarshalSpan(data)
+				if err != nil {
+					return fmt.Errorf("cannot unmarshal bucket span: %w", err)
+				}
+				if fc.FieldNum == 9 {
+					h.negativeSpans = append(h.negativeSpans, span)
+				} else {
+					h.positiveSpans = append(h.positiveSpans, span)
+				}
+			}
+		case 10:
+			h.negativeDeltas, ok = fc.UnpackSint64s(h.negativeDeltas)
+		case 11:
+			h.negativeCounts, ok = fc.UnpackDoubles(h.negativeCounts)
+		case 13:
+			h.positiveDeltas, ok = fc.UnpackSint64s(h.positiveDeltas)
+		case 14:
+			h.positiveCounts, ok = fc.UnpackDoubles(h.positiveCounts)
+		}
+		if !ok {
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func (h *pbHistogram) unmarshalBucket(src []byte) error {
+	// message Bucket {
+	//   uint64   cumulative_count       = 1;
+	//   double   cumulative_count_float = 4;
+	//   double   upper_bound            = 2;
+	//   Exemplar exemplar               = 3;
+	// }
+	var b pbBucket
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			if n > 0 {
+				b.count = float64(n)
+			}
+		case 4:
+			var f float64
+			f, ok = fc.Double()
+			if f > 0 {
+				b.count = f
+			}
+		case 2:
+			b.upperBound, ok = fc.Double()
+		}
+		if !ok {
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	h.buckets = append(h.buckets, b)
+	return nil
+}
+
+func unmarshalSpan(src []byte) (pbSpan, error) {
+	// message BucketSpan {
+	//   sint32 offset = 1;
+	//   uint32 length = 2;
+	// }
+	var span pbSpan
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return span, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			span.offset, ok = fc.Sint32()
+		case 2:
+			span.length, ok = fc.Uint32()
+		}
+		if !ok {
+			return span, fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	return span, nil
+}
+
+// isNative returns true if h contains native histogram data.
+//
+// The logic has been copied from Prometheus - see isNativeHistogram at
+// https://github.com/prometheus/prometheus/blob/main/model/textparse/protobufparse.go
+func (h *pbHistogram) isNative() bool {
+	return h.zeroThreshold > 0 || h.zeroCount > 0 || len(h.negativeSpans) > 0 || len(h.positiveSpans) > 0
+}
+
+func (rs *Rows) appendMetric(m *pbMetric, names *pbMetricNames, metricType int32) {
+	switch metricType {
+	case pbMetricTypeCounter:
+		if m.valueFieldNum != 3 {
+			return
+		}
+		rs.appendRow(m, names.name, "", "", m.value)
+		rs.appendCreated(m, names)
+	case pbMetricTypeGauge:
+		if m.valueFieldNum != 2 {
+			return
+		}
+		rs.appendRow(m, names.name, "", "", m.value)
+	case pbMetricTypeUntyped:
+		if m.valueFieldNum != 5 {
+			return
+		}
+		rs.appendRow(m, names.name, "", "", m.value)
+	case pbMetricTypeSummary:
+		if !m.hasSummary {
+			return
+		}
+		for _, q := range m.quantiles {
+			rs.appendRow(m, names.name, "quantile", formatFloat(q.quantile), q.value)
+		}
+		rs.appendRow(m, names.sum, "", "", m.sum)
+		rs.appendRow(m, names.count, "", "", m.count)
+		rs.appendCreated(m, names)
+	case pbMetricTypeHistogram, pbMetricTypeGaugeHistogram:
+		if !m.hasHistogram {
+			return
+		}
+		h := &m.histogram
+		if h.isNative() {
+			rs.appendNativeBuckets(m, names.bucket)
+		} else {
+			hasInf := false
+			for _, b := range h.buckets {
+				if math.IsInf(b.upperBound, 1) {
+					hasInf = true
+				}
+				rs.appendRow(m, names.bucket, "le", formatFloat(b.upperBound), b.count)
+			}
+			if !hasInf {
+				rs.appendRow(m, names.bucket, "le", "+Inf", m.count)
+			}
+		}
+		rs.appendRow(m, names.sum, "", "", m.sum)
+		rs.appendRow(m, names.count, "", "", m.count)
+		rs.appendCreated(m, names)
+	default:
+		unsupportedProtobufMetricTypes.Inc()
+	}
+}
+
+var unsupportedProtobufMetricTypes = metrics.NewCounter(`vm_protoparser_unsupported_protobuf_metric_types_total{type="promscrape"}`)
+
+// appendNativeBuckets appends native histogram buckets from m as VictoriaMetrics histogram buckets with `vmrange` labels.
+//
+// See https://prometheus.io/docs/specs/native_histograms/ and https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+func (rs *Rows) appendNativeBuckets(m *pbMetric, name string) {
+	h := &m.histogram
+	if h.zeroCount > 0 {
+		vmrange := fmt.Sprintf("%.3e...%.3e", 0.0, h.zeroThreshold)
+		rs.appendRow(m, name, "vmrange", vmrange, h.zeroCount)
+	}
+	// The upper bound of the bucket with index idx equals to 2^(idx*2^-schema)
+	ratio := math.Exp2(-float64(h.schema))
+	rs.appendSpans(m, name, h.positiveSpans, h.positiveDeltas, h.positiveCounts, func(idx int32) string {
+		lowerBound := math.Exp2(float64(idx-1) * ratio)
+		upperBound := math.Exp2(float64(idx) * ratio)
+		return fmt.Sprintf("%.3e...%.3e", lowerBound, upperBound)
+	})
+	rs.appendSpans(m, name, h.negativeSpans, h.negativeDeltas, h.negativeCounts, func(idx int32) string {
+		lowerBound := -math.Exp2(float64(idx) * ratio)
+		upperBound := -math.Exp2(float64(idx-1) * ratio)
+		return fmt.Sprintf("%.3e...%.3e", lowerBound, upperBound)
+	})
+}
+
+func (rs *Rows) appendSpans(m *pbMetric, name string, spans []pbSpan, deltas []int64, counts []float64, getVMRange func(idx int32) string) {
+	// Integer histograms contain delta-encoded bucket counts in deltas,
+	// while float histograms contain absolute bucket counts in counts.
+	isFloat := len(counts) > 0
+	var idx int32
+	var count int64
+	n := 0
+	for _, span := range spans {
+		idx += span.offset
+		for i := uint32(0); i < span.length; i++ {
+			var v float64
+			if isFloat {
+				if n >= len(counts) {
+					return
+				}
+				v = counts[n]
+			} else {
+				if n >= len(deltas) {
+					return
+				}
+				count += deltas[n]
+				v = float64(count)
+			}
+			if v > 0 {
+				rs.appendRow(m, name, "vmrange", getVMRange(idx), v)
+			}
+			n++
+			idx++
+		}
+	}
+}
+
+func (rs *Rows) appendCreated(m *pbMetric, names *pbMetricNames) {
+	if !m.hasCreated {
+		return
+	}
+	rs.appendRow(m, names.created, "", "", m.created)
+}
+
+func (rs *Rows) appendRow(m *pbMetric, name, extraLabelName, extraLabelValue string, value float64) {
+	tagsStart := len(rs.tagsPool)
+	rs.tagsPool = append(rs.tagsPool, m.labels...)
+	if extraLabelName != "" {
+		rs.tagsPool = append(rs.tagsPool, Tag{
+			Key:   extraLabelName,
+			Value: extraLabelValue,
+		})
+	}
+	var timestamp int64
+	if m.hasTimestamp {
+		timestamp = m.timestamp
+	}
+	rs.Rows = append(rs.Rows, Row{
+		Metric:    name,
+		Tags:      rs.tagsPool[tagsStart:len(rs.tagsPool):len(rs.tagsPool)],
+		Value:     value,
+		Timestamp: timestamp,
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,315 @@
+package prometheus
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestIsProtobufContentType(t *testing.T) {
+	f := func(contentType string, resultExpected bool) {
+		t.Helper()
+		result := IsProtobufContentType(contentType)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v", contentType, result, resultExpected)
+		}
+	}
+	f("", false)
+	f("text/plain; version=0.0.4", false)
+	f("application/openmetrics-text; version=1.0.0; charset=utf-8", false)
+	f("application/vnd.google.protobuf", false)
+	f("application/vnd.google.protobuf; proto=foo.Bar; encoding=delimited", false)
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=text", false)
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited", true)
+	f("application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily", true)
+}
+
+func TestAppendTextFromProtobuf_Failure(t *testing.T) {
+	f := func(src []byte) {
+		t.Helper()
+		if _, err := AppendTextFromProtobuf(nil, src); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// too short message
+	f([]byte{10})
+	f([]byte{10, 1, 2})
+
+	// missing metric name
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendInt32(3, pbMetricTypeGauge)
+	}))
+
+	// invalid metric
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendBytes(4, []byte{1, 2, 3})
+	}))
+
+	// empty label name
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		m := mm.AppendMessage(4)
+		m.AppendMessage(1).AppendString(2, "bar")
+	}))
+}
+
+func TestAppendTextFromProtobuf_Success(t *testing.T) {
+	f := func(src []byte, resultExpected string) {
+		t.Helper()
+		result, err := AppendTextFromProtobuf(nil, src)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+
+		// Verify the result can be parsed
+		var rows Rows
+		rows.UnmarshalWithErrLogger(string(result), func(s string) {
+			t.Fatalf("unexpected error when parsing the result: %s", s)
+		})
+	}
+
+	f(nil, "")
+
+	// counter with labels, timestamp and created timestamp
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "http_requests_total")
+		mm.AppendString(2, "The total number of requests")
+		mm.AppendInt32(3, pbMetricTypeCounter)
+		m := mm.AppendMessage(4)
+		appendLabel(m, "path", "/foo")
+		appendLabel(m, "code", `a"b\c`+"\n")
+		c := m.AppendMessage(3)
+		c.AppendDouble(1, 123.5)
+		e := c.AppendMessage(2)
+		appendLabel(e, "trace_id", "abc")
+		e.AppendDouble(2, 1)
+		ct := c.AppendMessage(3)
+		ct.AppendInt64(1, 1700000000)
+		ct.AppendInt32(2, 500000000)
+		m.AppendInt64(6, 1700000001234)
+	}), `http_requests_total{path="/foo",code="a\"b\\c\n"} 123.5 1700000001234
+http_requests_created{path="/foo",code="a\"b\\c\n"} 1.7000000005e+09 1700000001234
+`)
+
+	// gauge and untyped in multiple families
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "temperature")
+		mm.AppendInt32(3, pbMetricTypeGauge)
+		mm.AppendMessage(4).AppendMessage(2).AppendDouble(1, -1.5)
+		m := mm.AppendMessage(4)
+		appendLabel(m, "room", "kitchen")
+		m.AppendMessage(2).AppendDouble(1, math.Inf(1))
+	}, func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(3, pbMetricTypeUntyped)
+		mm.AppendMessage(4).AppendMessage(5).AppendDouble(1, 0)
+	}), `temperature -1.5
+temperature{room="kitchen"} +Inf
+foo 0
+`)
+
+	// metric without value for the given type is skipped
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(3, pbMetricTypeCounter)
+		mm.AppendMessage(4).AppendMessage(2).AppendDouble(1, 1)
+	}), ``)
+
+	// summary
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "rpc_duration_seconds")
+		mm.AppendInt32(3, pbMetricTypeSummary)
+		m := mm.AppendMessage(4)
+		appendLabel(m, "service", "a")
+		s := m.AppendMessage(4)
+		s.AppendUint64(1, 10)
+		s.AppendDouble(2, 1.25)
+		q := s.AppendMessage(3)
+		q.AppendDouble(1, 0.5)
+		q.AppendDouble(2, 0.1)
+		q = s.AppendMessage(3)
+		q.AppendDouble(1, 0.99)
+		q.AppendDouble(2, 0.3)
+		s.AppendMessage(4).AppendInt64(1, 1600000000)
+	}), `rpc_duration_seconds{service="a",quantile="0.5"} 0.1
+rpc_duration_seconds{service="a",quantile="0.99"} 0.3
+rpc_duration_seconds_sum{service="a"} 1.25
+rpc_duration_seconds_count{service="a"} 10
+rpc_duration_seconds_created{service="a"} 1.6e+09
+`)
+
+	// classic histogram without +Inf bucket
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "request_size_bytes")
+		mm.AppendInt32(3, pbMetricTypeHistogram)
+		h := mm.AppendMessage(4).AppendMessage(7)
+		h.AppendUint64(1, 5)
+		h.AppendDouble(2, 1234)
+		b := h.AppendMessage(3)
+		b.AppendUint64(1, 2)
+		b.AppendDouble(2, 100)
+		b = h.AppendMessage(3)
+		b.AppendUint64(1, 4)
+		b.AppendDouble(2, 1000)
+	}), `request_size_bytes_bucket{le="100"} 2
+request_size_bytes_bucket{le="1000"} 4
+request_size_bytes_bucket{le="+Inf"} 5
+request_size_bytes_sum 1234
+request_size_bytes_count 5
+`)
+
+	// classic histogram with +Inf bucket and float counts
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(3, pbMetricTypeGaugeHistogram)
+		h := mm.AppendMessage(4).AppendMessage(7)
+		h.AppendDouble(4, 3.5)
+		h.AppendDouble(2, 10)
+		b := h.AppendMessage(3)
+		b.AppendDouble(4, 1.5)
+		b.AppendDouble(2, 0.1)
+		b = h.AppendMessage(3)
+		b.AppendDouble(4, 3.5)
+		b.AppendDouble(2, math.Inf(1))
+	}), `foo_bucket{le="0.1"} 1.5
+foo_bucket{le="+Inf"} 3.5
+foo_sum 10
+foo_count 3.5
+`)
+
+	// native histogram with integer counts
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "latency_seconds")
+		mm.AppendInt32(3, pbMetricTypeHistogram)
+		m := mm.AppendMessage(4)
+		appendLabel(m, "job", "x")
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 12)
+		h.AppendDouble(2, 5.5)
+		h.AppendSint32(5, 0)
+		h.AppendDouble(6, 0.001)
+		h.AppendUint64(7, 2)
+		span := h.AppendMessage(9)
+		span.AppendSint32(1, 0)
+		span.AppendUint32(2, 1)
+		h.AppendSint64s(10, []int64{1})
+		span = h.AppendMessage(12)
+		span.AppendSint32(1, 1)
+		span.AppendUint32(2, 2)
+		span = h.AppendMessage(12)
+		span.AppendSint32(1, 1)
+		span.AppendUint32(2, 1)
+		h.AppendSint64s(13, []int64{3, -3, 6})
+		h.AppendMessage(15).AppendInt64(1, 1600000000)
+	}), `latency_seconds_bucket{job="x",vmrange="0.000e+00...1.000e-03"} 2
+latency_seconds_bucket{job="x",vmrange="1.000e+00...2.000e+00"} 3
+latency_seconds_bucket{job="x",vmrange="8.000e+00...1.600e+01"} 6
+latency_seconds_bucket{job="x",vmrange="-1.000e+00...-5.000e-01"} 1
+latency_seconds_sum{job="x"} 5.5
+latency_seconds_count{job="x"} 12
+latency_seconds_created{job="x"} 1.6e+09
+`)
+
+	// native float histogram with schema=1
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(3, pbMetricTypeHistogram)
+		h := mm.AppendMessage(4).AppendMessage(7)
+		h.AppendDouble(4, 2.5)
+		h.AppendDouble(2, 3)
+		h.AppendSint32(5, 1)
+		span := h.AppendMessage(12)
+		span.AppendSint32(1, 2)
+		span.AppendUint32(2, 2)
+		h.AppendDoubles(14, []float64{1.5, 1})
+	}), `foo_bucket{vmrange="1.414e+00...2.000e+00"} 1.5
+foo_bucket{vmrange="2.000e+00...2.828e+00"} 1
+foo_sum 3
+foo_count 2.5
+`)
+}
+
+func TestRowsUnmarshalProtobuf(t *testing.T) {
+	src := marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(3, pbMetricTypeSummary)
+		m := mm.AppendMessage(4)
+		appendLabel(m, "job", "x")
+		s := m.AppendMessage(4)
+		s.AppendUint64(1, 3)
+		s.AppendDouble(2, 4.5)
+		q := s.AppendMessage(3)
+		q.AppendDouble(1, 0.5)
+		q.AppendDouble(2, 1.5)
+		m.AppendInt64(6, 1700000000000)
+	}, func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "bar")
+		mm.AppendInt32(3, pbMetricTypeGauge)
+		mm.AppendMessage(4).AppendMessage(2).AppendDouble(1, 2)
+	})
+
+	var rs Rows
+	if err := rs.UnmarshalProtobuf(src); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rowsExpected := []Row{
+		{
+			Metric:    "foo",
+			Tags:      []Tag{{Key: "job", Value: "x"}, {Key: "quantile", Value: "0.5"}},
+			Value:     1.5,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric:    "foo_sum",
+			Tags:      []Tag{{Key: "job", Value: "x"}},
+			Value:     4.5,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric:    "foo_count",
+			Tags:      []Tag{{Key: "job", Value: "x"}},
+			Value:     3,
+			Timestamp: 1700000000000,
+		},
+		{
+			Metric: "bar",
+			Tags:   []Tag{},
+			Value:  2,
+		},
+	}
+	if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+		t.Fatalf("unexpected rows\ngot\n%+v\nwant\n%+v", rs.Rows, rowsExpected)
+	}
+
+	// rs must be reset on the next call
+	if err := rs.UnmarshalProtobuf(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rs.Rows) != 0 {
+		t.Fatalf("unexpected rows after unmarshaling empty data: %+v", rs.Rows)
+	}
+}
+
+func marshalMetricFamilies(fs ...func(mm *easyproto.MessageMarshaler)) []byte {
+	var dst []byte
+	var m easyproto.Marshaler
+	for _, f := range fs {
+		m.Reset()
+		f(m.MessageMarshaler())
+		dst = m.MarshalWithLen(dst)
+	}
+	return dst
+}
+
+func appendLabel(mm *easyproto.MessageMarshaler, name, value string) {
+	label := mm.AppendMessage(1)
+	label.AppendString(1, name)
+	label.AppendString(2, value)
+}
@@ -69,12 +69,17 @@ func (as *avgAggrState) flushState(ctx *flushCtx) {
 		sv := v.(*avgStateValue)
 		sv.mu.Lock()
 		avg := sv.sum / float64(sv.count)
+		count := sv.count
 		// Mark the entry as deleted, so it won't be updated anymore by concurrent pushSample() calls.
 		sv.deleted = true
 		sv.mu.Unlock()
 
 		key := k.(string)
-		ctx.appendSeries(key, "avg", avg)
+		ctx.appendWeightedSeries(key, "avg", avg, float64(count))
 		return true
 	})
 }
+
+func (as *avgAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -0,0 +1,107 @@
+package streamaggr
+
+import (
+	"math"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// countApproxAggrState calculates output=count_series_approx and count_unique_approx,
+// e.g. the estimated number of unique series or unique sample values.
+//
+// It uses hyperLogLog, so memory usage per each output series is bounded regardless of the number of unique items.
+type countApproxAggrState struct {
+	m sync.Map
+
+	// isSeries is set to true if count_series_approx() must be calculated instead of count_unique_approx().
+	isSeries bool
+}
+
+type countApproxStateValue struct {
+	mu      sync.Mutex
+	hll     hyperLogLog
+	deleted bool
+}
+
+func newCountApproxAggrState(isSeries bool) *countApproxAggrState {
+	return &countApproxAggrState{
+		isSeries: isSeries,
+	}
+}
+
+func (as *countApproxAggrState) pushSamples(samples []pushSample) {
+	var buf [8]byte
+	for i := range samples {
+		s := &samples[i]
+		inputKey, outputKey := getInputOutputKey(s.key)
+
+		var h uint64
+		if as.isSeries {
+			h = xxhash.Sum64(bytesutil.ToUnsafeBytes(inputKey))
+		} else {
+			b := encoding.MarshalUint64(buf[:0], math.Float64bits(s.value))
+			h = xxhash.Sum64(b)
+		}
+
+	again:
+		v, ok := as.m.Load(outputKey)
+		if !ok {
+			// The entry is missing in the map. Try creating it.
+			v = &countApproxStateValue{}
+			outputKey = bytesutil.InternString(outputKey)
+			vNew, loaded := as.m.LoadOrStore(outputKey, v)
+			if loaded {
+				// Use the entry created by a concurrent goroutine.
+				v = vNew
+			}
+		}
+		sv := v.(*countApproxStateValue)
+		sv.mu.Lock()
+		deleted := sv.deleted
+		if !deleted {
+			sv.hll.add(h)
+		}
+		sv.mu.Unlock()
+		if deleted {
+			// The entry has been deleted by the concurrent call to flushState
+			// Try obtaining and updating the entry again.
+			goto again
+		}
+	}
+}
+
+func (as *countApproxAggrState) flushState(ctx *flushCtx) {
+	suffix := as.getSuffix()
+
+	m := &as.m
+	m.Range(func(k, v any) bool {
+		// Atomically delete the entry from the map, so new entry is created for the next flush.
+		m.Delete(k)
+
+		sv := v.(*countApproxStateValue)
+		sv.mu.Lock()
+		n := sv.hll.count()
+		// Mark the entry as deleted, so it won't be updated anymore by concurrent pushSample() calls.
+		sv.deleted = true
+		sv.mu.Unlock()
+
+		key := k.(string)
+		ctx.appendSeries(key, suffix, float64(n))
+		return true
+	})
+}
+
+func (as *countApproxAggrState) getSuffix() string {
+	if as.isSeries {
+		return "count_series_approx"
+	}
+	return "count_unique_approx"
+}
+
+func (as *countApproxAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -75,3 +75,7 @@ func (as *countSamplesAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *countSamplesAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
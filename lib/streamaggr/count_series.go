@@ -84,3 +84,7 @@ func (as *countSeriesAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *countSeriesAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
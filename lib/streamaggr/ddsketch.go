@@ -0,0 +1,187 @@
+package streamaggr
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// defaultDDSketchRelativeAccuracy is the default relative accuracy for ddsketch_bucket output.
+const defaultDDSketchRelativeAccuracy = 0.01
+
+// ddSketchMinValue is the minimum absolute value tracked by ddSketch.
+//
+// Values with smaller absolute values are counted in the zero bucket.
+const ddSketchMinValue = 1e-9
+
+// ddSketchMaxBuckets is the maximum number of buckets per each sign in ddSketch.
+//
+// The buckets for the smallest absolute values are collapsed when the number of buckets exceeds this limit.
+// This limits memory usage per sketch at the cost of accuracy for the smallest absolute values.
+const ddSketchMaxBuckets = 2048
+
+// ddSketch is DDSketch with logarithmic bucket mapping.
+//
+// See https://arxiv.org/abs/1908.10693
+//
+// Sketches with the same relative accuracy have identical bucket bounds,
+// so they can be merged by summing counts for buckets with the same bounds.
+type ddSketch struct {
+	m *ddSketchMapping
+
+	zeroCount uint64
+	pos       ddSketchStore
+	neg       ddSketchStore
+}
+
+// ddSketchMapping maps values to bucket indexes for the given relative accuracy.
+type ddSketchMapping struct {
+	gamma    float64
+	logGamma float64
+
+	// vmranges caches vmrange label values per each bucket index.
+	vmranges sync.Map
+}
+
+var ddSketchMappings sync.Map
+
+// getDDSketchMapping returns mapping for the given relative accuracy.
+func getDDSketchMapping(relativeAccuracy float64) *ddSketchMapping {
+	if v, ok := ddSketchMappings.Load(relativeAccuracy); ok {
+		return v.(*ddSketchMapping)
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	m := &ddSketchMapping{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+	}
+	v, _ := ddSketchMappings.LoadOrStore(relativeAccuracy, m)
+	return v.(*ddSketchMapping)
+}
+
+// index returns the index of the bucket (gamma^(index-1) ... gamma^index] for the given positive v.
+func (m *ddSketchMapping) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / m.logGamma))
+}
+
+// vmrange returns vmrange label value for the bucket with the given index.
+//
+// Negative buckets are returned if isNeg is set.
+func (m *ddSketchMapping) vmrange(index int, isNeg bool) string {
+	key := index << 1
+	if isNeg {
+		key |= 1
+	}
+	if v, ok := m.vmranges.Load(key); ok {
+		return v.(string)
+	}
+	start := math.Pow(m.gamma, float64(index-1))
+	end := start * m.gamma
+	var s string
+	if isNeg {
+		s = fmt.Sprintf("%.3e...%.3e", -end, -start)
+	} else {
+		s = fmt.Sprintf("%.3e...%.3e", start, end)
+	}
+	m.vmranges.Store(key, s)
+	return s
+}
+
+var ddSketchZeroBucketRange = fmt.Sprintf("0...%.3e", ddSketchMinValue)
+
+func newDDSketch(m *ddSketchMapping) *ddSketch {
+	return &ddSketch{
+		m: m,
+	}
+}
+
+// update adds v to s.
+func (s *ddSketch) update(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	switch {
+	case v >= ddSketchMinValue:
+		s.pos.add(s.m.index(v), 1)
+	case v <= -ddSketchMinValue:
+		s.neg.add(s.m.index(-v), 1)
+	default:
+		s.zeroCount++
+	}
+}
+
+// visitNonZeroBuckets calls f for every non-empty bucket in s.
+func (s *ddSketch) visitNonZeroBuckets(f func(vmrange string, count uint64)) {
+	s.neg.visit(func(index int, count uint64) {
+		f(s.m.vmrange(index, true), count)
+	})
+	if s.zeroCount > 0 {
+		f(ddSketchZeroBucketRange, s.zeroCount)
+	}
+	s.pos.visit(func(index int, count uint64) {
+		f(s.m.vmrange(index, false), count)
+	})
+}
+
+// ddSketchStore holds bucket counts for contiguous bucket indexes starting from offset.
+type ddSketchStore struct {
+	offset int
+	counts []uint64
+}
+
+func (ss *ddSketchStore) add(index int, count uint64) {
+	if len(ss.counts) == 0 {
+		ss.offset = index
+		ss.counts = append(ss.counts[:0], count)
+		return
+	}
+	if index < ss.offset {
+		n := ss.offset - index
+		if len(ss.counts)+n > ddSketchMaxBuckets {
+			// Collapse the value into the lowest bucket.
+			ss.counts[0] += count
+			return
+		}
+		counts := make([]uint64, len(ss.counts)+n)
+		copy(counts[n:], ss.counts)
+		ss.counts = counts
+		ss.offset = index
+		ss.counts[0] += count
+		return
+	}
+	idx := index - ss.offset
+	if idx >= len(ss.counts) {
+		if idx >= ddSketchMaxBuckets {
+			// Collapse the lowest buckets in order to free space for the new bucket.
+			shift := idx - ddSketchMaxBuckets + 1
+			if shift >= len(ss.counts) {
+				total := uint64(0)
+				for _, n := range ss.counts {
+					total += n
+				}
+				ss.counts = ss.counts[:1]
+				ss.counts[0] = total
+				ss.offset = index - ddSketchMaxBuckets + 1
+			} else {
+				for _, n := range ss.counts[:shift] {
+					ss.counts[shift] += n
+				}
+				ss.counts = append(ss.counts[:0], ss.counts[shift:]...)
+				ss.offset += shift
+			}
+			idx = index - ss.offset
+		}
+		for len(ss.counts) <= idx {
+			ss.counts = append(ss.counts, 0)
+		}
+	}
+	ss.counts[idx] += count
+}
+
+func (ss *ddSketchStore) visit(f func(index int, count uint64)) {
+	for i, count := range ss.counts {
+		if count > 0 {
+			f(ss.offset+i, count)
+		}
+	}
+}
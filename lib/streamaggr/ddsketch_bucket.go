@@ -0,0 +1,88 @@
+package streamaggr
+
+import (
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+// ddSketchBucketAggrState calculates output=ddsketch_bucket, e.g. DDSketch buckets over the input samples.
+//
+// The buckets are exposed as VictoriaMetrics histogram buckets with `vmrange` label.
+// Buckets with the same relative accuracy have identical bounds, so they can be merged
+// across multiple aggregators by summing them by `vmrange` label.
+type ddSketchBucketAggrState struct {
+	m sync.Map
+
+	mapping *ddSketchMapping
+}
+
+type ddSketchBucketStateValue struct {
+	mu      sync.Mutex
+	s       *ddSketch
+	deleted bool
+}
+
+func newDDSketchBucketAggrState(relativeAccuracy float64) *ddSketchBucketAggrState {
+	return &ddSketchBucketAggrState{
+		mapping: getDDSketchMapping(relativeAccuracy),
+	}
+}
+
+func (as *ddSketchBucketAggrState) pushSamples(samples []pushSample) {
+	for i := range samples {
+		s := &samples[i]
+		outputKey := getOutputKey(s.key)
+
+	again:
+		v, ok := as.m.Load(outputKey)
+		if !ok {
+			// The entry is missing in the map. Try creating it.
+			v = &ddSketchBucketStateValue{
+				s: newDDSketch(as.mapping),
+			}
+			outputKey = bytesutil.InternString(outputKey)
+			vNew, loaded := as.m.LoadOrStore(outputKey, v)
+			if loaded {
+				// Use the entry created by a concurrent goroutine.
+				v = vNew
+			}
+		}
+		sv := v.(*ddSketchBucketStateValue)
+		sv.mu.Lock()
+		deleted := sv.deleted
+		if !deleted {
+			sv.s.update(s.value)
+		}
+		sv.mu.Unlock()
+		if deleted {
+			// The entry has been deleted by the concurrent call to flushState
+			// Try obtaining and updating the entry again.
+			goto again
+		}
+	}
+}
+
+func (as *ddSketchBucketAggrState) flushState(ctx *flushCtx) {
+	m := &as.m
+	m.Range(func(k, v any) bool {
+		// Atomically delete the entry from the map, so new entry is created for the next flush.
+		m.Delete(k)
+
+		sv := v.(*ddSketchBucketStateValue)
+		sv.mu.Lock()
+		// Mark the entry as deleted, so it won't be updated anymore by concurrent pushSample() calls.
+		sv.deleted = true
+		sv.mu.Unlock()
+
+		key := k.(string)
+		sv.s.visitNonZeroBuckets(func(vmrange string, count uint64) {
+			ctx.appendSeriesWithExtraLabel(key, "ddsketch_bucket", float64(count), "vmrange", vmrange)
+		})
+		return true
+	})
+}
+
+func (as *ddSketchBucketAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
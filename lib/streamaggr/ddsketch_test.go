@@ -0,0 +1,147 @@
+package streamaggr
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDDSketchRelativeAccuracy(t *testing.T) {
+	f := func(relativeAccuracy float64, values []float64) {
+		t.Helper()
+
+		m := getDDSketchMapping(relativeAccuracy)
+		s := newDDSketch(m)
+		for _, v := range values {
+			s.update(v)
+		}
+
+		// Every value must be within relativeAccuracy from the middle of its bucket.
+		for _, v := range values {
+			absV := math.Abs(v)
+			if absV < ddSketchMinValue {
+				continue
+			}
+			idx := m.index(absV)
+			start := math.Pow(m.gamma, float64(idx-1))
+			end := start * m.gamma
+			if absV <= start || absV > end*(1+1e-12) {
+				t.Fatalf("value %v is outside its bucket %v...%v", v, start, end)
+			}
+			mid := 2 * start * end / (start + end)
+			if math.Abs(absV-mid) > relativeAccuracy*absV*(1+1e-9) {
+				t.Fatalf("value %v is too far from the middle %v of the bucket %v...%v", v, mid, start, end)
+			}
+		}
+
+		var valuesCount uint64
+		s.visitNonZeroBuckets(func(_ string, count uint64) {
+			valuesCount += count
+		})
+		if valuesCount != uint64(len(values)) {
+			t.Fatalf("unexpected number of values in buckets; got %d; want %d", valuesCount, len(values))
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = r.ExpFloat64() * 100
+	}
+	f(0.01, values)
+	f(0.05, values)
+	f(0.001, values)
+
+	for i := range values {
+		values[i] = r.NormFloat64() * 1e3
+	}
+	f(0.01, values)
+	f(0.01, []float64{0, 1e-10, -1e-10, 1, 1e10})
+}
+
+func TestDDSketchMerge(t *testing.T) {
+	// Sketches with the same relative accuracy must have identical bucket bounds,
+	// so the union of values gives the same buckets as the sum of per-sketch buckets.
+	m := getDDSketchMapping(0.01)
+	r := rand.New(rand.NewSource(1))
+	s1 := newDDSketch(m)
+	s2 := newDDSketch(m)
+	sAll := newDDSketch(m)
+	for i := 0; i < 1000; i++ {
+		v := r.NormFloat64() * 100
+		if i%2 == 0 {
+			s1.update(v)
+		} else {
+			s2.update(v)
+		}
+		sAll.update(v)
+	}
+
+	merged := make(map[string]uint64)
+	visit := func(vmrange string, count uint64) {
+		merged[vmrange] += count
+	}
+	s1.visitNonZeroBuckets(visit)
+	s2.visitNonZeroBuckets(visit)
+
+	expected := make(map[string]uint64)
+	sAll.visitNonZeroBuckets(func(vmrange string, count uint64) {
+		expected[vmrange] = count
+	})
+	if len(merged) != len(expected) {
+		t.Fatalf("unexpected number of merged buckets; got %d; want %d", len(merged), len(expected))
+	}
+	for vmrange, count := range expected {
+		if merged[vmrange] != count {
+			t.Fatalf("unexpected count for vmrange=%q; got %d; want %d", vmrange, merged[vmrange], count)
+		}
+	}
+}
+
+func TestDDSketchMaxBuckets(t *testing.T) {
+	s := newDDSketch(getDDSketchMapping(0.001))
+	values := []float64{1e-8, 1e-5, 1, 1e5, 1e10, 1e15, 1e-3, 1e18}
+	for _, v := range values {
+		s.update(v)
+	}
+	buckets := 0
+	var valuesCount uint64
+	var lastEnd float64
+	s.visitNonZeroBuckets(func(vmrange string, count uint64) {
+		buckets++
+		valuesCount += count
+		_, lastEnd = mustParseVMRange(t, vmrange)
+	})
+	if valuesCount != uint64(len(values)) {
+		t.Fatalf("unexpected number of values in buckets; got %d; want %d", valuesCount, len(values))
+	}
+	if n := len(s.pos.counts); n > ddSketchMaxBuckets {
+		t.Fatalf("too many buckets; got %d; want up to %d", n, ddSketchMaxBuckets)
+	}
+	// The biggest value must remain accurate, while the smallest values are collapsed.
+	if lastEnd < 1e18 || lastEnd > 1e18*1.002 {
+		t.Fatalf("unexpected upper bound for the biggest bucket: %v", lastEnd)
+	}
+	if buckets >= len(values) {
+		t.Fatalf("expecting collapsed buckets; got %d buckets for %d values", buckets, len(values))
+	}
+}
+
+func mustParseVMRange(t *testing.T, vmrange string) (float64, float64) {
+	t.Helper()
+	n := strings.Index(vmrange, "...")
+	if n < 0 {
+		t.Fatalf("missing ... in vmrange=%q", vmrange)
+	}
+	start, err := strconv.ParseFloat(vmrange[:n], 64)
+	if err != nil {
+		t.Fatalf("cannot parse start in vmrange=%q: %s", vmrange, err)
+	}
+	end, err := strconv.ParseFloat(vmrange[n+3:], 64)
+	if err != nil {
+		t.Fatalf("cannot parse end in vmrange=%q: %s", vmrange, err)
+	}
+	return start, end
+}
@@ -0,0 +1,213 @@
+package streamaggr
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+)
+
+// aggregatorStatus contains the status of a single aggregator for /stream-agg page.
+type aggregatorStatus struct {
+	name     string
+	path     string
+	url      string
+	position int
+
+	match         string
+	by            []string
+	without       []string
+	intervals     []time.Duration
+	window        time.Duration
+	dedupInterval time.Duration
+
+	outputs []outputStatus
+
+	matchedSamples      uint64
+	ignoredOldSamples   uint64
+	dedupDroppedSamples uint64
+}
+
+// outputStatus contains the status of a single output for /stream-agg page.
+type outputStatus struct {
+	output     string
+	activeKeys int
+}
+
+func (a *aggregator) getStatus(path string) *aggregatorStatus {
+	outputs := make([]outputStatus, len(a.aggrOutputs))
+	for i := range a.aggrOutputs {
+		ao := &a.aggrOutputs[i]
+		outputs[i] = outputStatus{
+			output:     ao.name,
+			activeKeys: ao.as.itemsCount(),
+		}
+	}
+	return &aggregatorStatus{
+		name:     a.name,
+		path:     path,
+		url:      a.alias,
+		position: a.aggrID,
+
+		match:         a.match.String(),
+		by:            removeUnderscoreName(a.by),
+		without:       a.without,
+		intervals:     a.intervals,
+		window:        a.window,
+		dedupInterval: a.dedupInterval,
+
+		outputs: outputs,
+
+		matchedSamples:      a.matchedSamples.Get(),
+		ignoredOldSamples:   a.ignoredOldSamples.Get(),
+		dedupDroppedSamples: a.dedupDroppedSamples.Get(),
+	}
+}
+
+// WriteAggregatorsStatus writes /stream-agg page with the status for the given sas to w.
+//
+// nil entries in sas are skipped.
+func WriteAggregatorsStatus(w http.ResponseWriter, r *http.Request, sas []*Aggregators) {
+	var ass []*aggregatorStatus
+	for _, sa := range sas {
+		if sa == nil {
+			continue
+		}
+		for _, a := range sa.as {
+			ass = append(ass, a.getStatus(sa.filePath))
+		}
+	}
+	if r.FormValue("format") == "json" {
+		httpserver.EnableCORS(w, r)
+		w.Header().Set("Content-Type", "application/json")
+		WriteAggregatorsStatusJSON(w, ass)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	WriteAggregatorsStatusHTML(w, ass)
+}
+
+// WriteDryRun writes /stream-agg-dry-run page to w.
+//
+// The page shows the output series, which are produced by the stream aggregation config passed in `config` query arg
+// for the input samples in Prometheus text exposition format passed in `metrics` query arg.
+//
+// opts are used for initializing the aggregators. The aggregation state isn't persisted during the dry run.
+func WriteDryRun(w http.ResponseWriter, r *http.Request, opts *Options) {
+	config := r.FormValue("config")
+	metrics := r.FormValue("metrics")
+	format := r.FormValue("format")
+
+	var tss []prompbmarshal.TimeSeries
+	var err error
+	if config != "" || metrics != "" {
+		tss, err = dryRun(config, metrics, opts)
+	}
+	if format == "json" {
+		httpserver.EnableCORS(w, r)
+		w.Header().Set("Content-Type", "application/json")
+		WriteDryRunJSON(w, tss, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	WriteDryRunHTML(w, config, metrics, tss, err)
+}
+
+// dryRun returns the output series for the given stream aggregation config and the input metrics in Prometheus text exposition format.
+//
+// Samples without timestamps in metrics get the current timestamp.
+func dryRun(config, metrics string, opts *Options) ([]prompbmarshal.TimeSeries, error) {
+	tss, err := parseDryRunMetrics(metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	var optsLocal Options
+	if opts != nil {
+		optsLocal = *opts
+	}
+	optsLocal.FlushOnShutdown = true
+	optsLocal.NoAlignFlushToInterval = true
+	optsLocal.IgnoreFirstIntervals = 0
+	optsLocal.StateDir = ""
+
+	var tssOutput []prompbmarshal.TimeSeries
+	var tssOutputLock sync.Mutex
+	pushFunc := func(tss []prompbmarshal.TimeSeries) {
+		tssOutputLock.Lock()
+		for _, ts := range tss {
+			tssOutput = append(tssOutput, prompbmarshal.TimeSeries{
+				Labels:  append(ts.Labels[:0:0], ts.Labels...),
+				Samples: append(ts.Samples[:0:0], ts.Samples...),
+			})
+		}
+		tssOutputLock.Unlock()
+	}
+	sas, err := loadFromData([]byte(config), "dry-run", pushFunc, &optsLocal, "dry-run")
+	if err != nil {
+		return nil, err
+	}
+	sas.Push(tss, nil)
+	sas.MustStop()
+
+	sort.Slice(tssOutput, func(i, j int) bool {
+		return promrelabel.LabelsToString(tssOutput[i].Labels) < promrelabel.LabelsToString(tssOutput[j].Labels)
+	})
+	return tssOutput, nil
+}
+
+func parseDryRunMetrics(s string) ([]prompbmarshal.TimeSeries, error) {
+	var rows prometheus.Rows
+	var err error
+	rows.UnmarshalWithErrLogger(s, func(errStr string) {
+		if err == nil {
+			err = fmt.Errorf("cannot parse metrics: %s", errStr)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nowMsec := time.Now().UnixMilli()
+	tss := make([]prompbmarshal.TimeSeries, 0, len(rows.Rows))
+	for _, row := range rows.Rows {
+		labels := make([]prompbmarshal.Label, 0, len(row.Tags)+1)
+		labels = append(labels, prompbmarshal.Label{
+			Name:  "__name__",
+			Value: row.Metric,
+		})
+		for _, tag := range row.Tags {
+			labels = append(labels, prompbmarshal.Label{
+				Name:  tag.Key,
+				Value: tag.Value,
+			})
+		}
+		timestamp := row.Timestamp
+		if timestamp == 0 {
+			timestamp = nowMsec
+		}
+		tss = append(tss, prompbmarshal.TimeSeries{
+			Labels: labels,
+			Samples: []prompbmarshal.Sample{{
+				Value:     row.Value,
+				Timestamp: timestamp,
+			}},
+		})
+	}
+	return tss, nil
+}
+
+func durationsString(a []time.Duration) string {
+	ss := make([]string, len(a))
+	for i, d := range a {
+		ss[i] = d.String()
+	}
+	return strings.Join(ss, ", ")
+}
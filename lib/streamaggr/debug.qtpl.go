@@ -0,0 +1,555 @@
+// Code generated by qtc from "debug.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line debug.qtpl:1
+package streamaggr
+
+//line debug.qtpl:1
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/htmlcomponents"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+//line debug.qtpl:12
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line debug.qtpl:12
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line debug.qtpl:12
+func StreamAggregatorsStatusHTML(qw422016 *qt422016.Writer, ass []*aggregatorStatus) {
+//line debug.qtpl:12
+	qw422016.N().S(`<!DOCTYPE html><html lang="en"><head>`)
+//line debug.qtpl:16
+	htmlcomponents.StreamCommonHeader(qw422016)
+//line debug.qtpl:16
+	qw422016.N().S(`<title>Stream aggregation</title></head><body>`)
+//line debug.qtpl:20
+	htmlcomponents.StreamNavbar(qw422016)
+//line debug.qtpl:20
+	qw422016.N().S(`<div class="container-fluid"><a href="https://docs.victoriametrics.com/stream-aggregation/" target="_blank">Stream aggregation docs</a>`)
+//line debug.qtpl:22
+	qw422016.N().S(` `)
+//line debug.qtpl:22
+	qw422016.N().S(`<a href="stream-agg-dry-run">Dry run</a>`)
+//line debug.qtpl:23
+	qw422016.N().S(` `)
+//line debug.qtpl:23
+	qw422016.N().S(`<a href="stream-agg?format=json">JSON</a><div class="row"><main class="col-12">`)
+//line debug.qtpl:27
+	if len(ass) == 0 {
+//line debug.qtpl:27
+		qw422016.N().S(`<div class="m-3">There are no configured stream aggregators</div>`)
+//line debug.qtpl:29
+	} else {
+//line debug.qtpl:29
+		qw422016.N().S(`<table class="table table-striped table-hover table-bordered table-sm"><thead><tr><th scope="col">Config</th><th scope="col">Name</th><th scope="col">Match</th><th scope="col">Grouping</th><th scope="col">Interval</th><th scope="col" title="the number of output keys per each output">Outputs</th><th scope="col">Matched samples</th><th scope="col" title="samples dropped because of timestamps older than the current aggregation interval">Dropped too old</th><th scope="col" title="samples dropped during de-duplication">Dropped by dedup</th></tr></thead><tbody>`)
+//line debug.qtpl:45
+		for _, as := range ass {
+//line debug.qtpl:45
+			qw422016.N().S(`<tr><td><samp>`)
+//line debug.qtpl:47
+			qw422016.E().S(as.path)
+//line debug.qtpl:47
+			qw422016.N().S(`</samp> (url=`)
+//line debug.qtpl:47
+			qw422016.E().S(as.url)
+//line debug.qtpl:47
+			qw422016.N().S(`, position=`)
+//line debug.qtpl:47
+			qw422016.N().D(as.position)
+//line debug.qtpl:47
+			qw422016.N().S(`)</td><td>`)
+//line debug.qtpl:48
+			qw422016.E().S(as.name)
+//line debug.qtpl:48
+			qw422016.N().S(`</td><td><samp>`)
+//line debug.qtpl:49
+			qw422016.E().S(as.match)
+//line debug.qtpl:49
+			qw422016.N().S(`</samp></td><td>`)
+//line debug.qtpl:51
+			if len(as.by) > 0 {
+//line debug.qtpl:51
+				qw422016.N().S(`by:`)
+//line debug.qtpl:52
+				qw422016.N().S(` `)
+//line debug.qtpl:52
+				qw422016.N().S(`<samp>`)
+//line debug.qtpl:52
+				qw422016.E().S(strings.Join(as.by, ", "))
+//line debug.qtpl:52
+				qw422016.N().S(`</samp>`)
+//line debug.qtpl:53
+			} else if len(as.without) > 0 {
+//line debug.qtpl:53
+				qw422016.N().S(`without:`)
+//line debug.qtpl:54
+				qw422016.N().S(` `)
+//line debug.qtpl:54
+				qw422016.N().S(`<samp>`)
+//line debug.qtpl:54
+				qw422016.E().S(strings.Join(as.without, ", "))
+//line debug.qtpl:54
+				qw422016.N().S(`</samp>`)
+//line debug.qtpl:55
+			} else {
+//line debug.qtpl:55
+				qw422016.N().S(`by time only`)
+//line debug.qtpl:57
+			}
+//line debug.qtpl:57
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:60
+			qw422016.E().S(durationsString(as.intervals))
+//line debug.qtpl:61
+			if as.window > 0 {
+//line debug.qtpl:62
+				qw422016.N().S(` `)
+//line debug.qtpl:62
+				qw422016.N().S(`(window=`)
+//line debug.qtpl:62
+				qw422016.E().S(as.window.String())
+//line debug.qtpl:62
+				qw422016.N().S(`)`)
+//line debug.qtpl:63
+			}
+//line debug.qtpl:64
+			if as.dedupInterval > 0 {
+//line debug.qtpl:65
+				qw422016.N().S(` `)
+//line debug.qtpl:65
+				qw422016.N().S(`(dedup_interval=`)
+//line debug.qtpl:65
+				qw422016.E().S(as.dedupInterval.String())
+//line debug.qtpl:65
+				qw422016.N().S(`)`)
+//line debug.qtpl:66
+			}
+//line debug.qtpl:66
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:69
+			for _, os := range as.outputs {
+//line debug.qtpl:69
+				qw422016.N().S(`<div><samp>`)
+//line debug.qtpl:70
+				qw422016.E().S(os.output)
+//line debug.qtpl:70
+				qw422016.N().S(`</samp>:`)
+//line debug.qtpl:70
+				qw422016.N().D(os.activeKeys)
+//line debug.qtpl:70
+				qw422016.N().S(`</div>`)
+//line debug.qtpl:71
+			}
+//line debug.qtpl:71
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:73
+			qw422016.N().DUL(as.matchedSamples)
+//line debug.qtpl:73
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:74
+			qw422016.N().DUL(as.ignoredOldSamples)
+//line debug.qtpl:74
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:75
+			qw422016.N().DUL(as.dedupDroppedSamples)
+//line debug.qtpl:75
+			qw422016.N().S(`</td></tr>`)
+//line debug.qtpl:77
+		}
+//line debug.qtpl:77
+		qw422016.N().S(`</tbody></table>`)
+//line debug.qtpl:80
+	}
+//line debug.qtpl:80
+	qw422016.N().S(`</main></div></div></body></html>`)
+//line debug.qtpl:86
+}
+
+//line debug.qtpl:86
+func WriteAggregatorsStatusHTML(qq422016 qtio422016.Writer, ass []*aggregatorStatus) {
+//line debug.qtpl:86
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line debug.qtpl:86
+	StreamAggregatorsStatusHTML(qw422016, ass)
+//line debug.qtpl:86
+	qt422016.ReleaseWriter(qw422016)
+//line debug.qtpl:86
+}
+
+//line debug.qtpl:86
+func AggregatorsStatusHTML(ass []*aggregatorStatus) string {
+//line debug.qtpl:86
+	qb422016 := qt422016.AcquireByteBuffer()
+//line debug.qtpl:86
+	WriteAggregatorsStatusHTML(qb422016, ass)
+//line debug.qtpl:86
+	qs422016 := string(qb422016.B)
+//line debug.qtpl:86
+	qt422016.ReleaseByteBuffer(qb422016)
+//line debug.qtpl:86
+	return qs422016
+//line debug.qtpl:86
+}
+
+//line debug.qtpl:88
+func StreamAggregatorsStatusJSON(qw422016 *qt422016.Writer, ass []*aggregatorStatus) {
+//line debug.qtpl:88
+	qw422016.N().S(`{"status": "success","data": [`)
+//line debug.qtpl:92
+	for i, as := range ass {
+//line debug.qtpl:92
+		qw422016.N().S(`{"name":`)
+//line debug.qtpl:94
+		qw422016.N().Q(as.name)
+//line debug.qtpl:94
+		qw422016.N().S(`,"path":`)
+//line debug.qtpl:95
+		qw422016.N().Q(as.path)
+//line debug.qtpl:95
+		qw422016.N().S(`,"url":`)
+//line debug.qtpl:96
+		qw422016.N().Q(as.url)
+//line debug.qtpl:96
+		qw422016.N().S(`,"position":`)
+//line debug.qtpl:97
+		qw422016.N().D(as.position)
+//line debug.qtpl:97
+		qw422016.N().S(`,"match":`)
+//line debug.qtpl:98
+		qw422016.N().Q(as.match)
+//line debug.qtpl:98
+		qw422016.N().S(`,"by":`)
+//line debug.qtpl:99
+		streamstringsJSON(qw422016, as.by)
+//line debug.qtpl:99
+		qw422016.N().S(`,"without":`)
+//line debug.qtpl:100
+		streamstringsJSON(qw422016, as.without)
+//line debug.qtpl:100
+		qw422016.N().S(`,"intervals": [`)
+//line debug.qtpl:102
+		for j, d := range as.intervals {
+//line debug.qtpl:103
+			qw422016.N().Q(d.String())
+//line debug.qtpl:104
+			if j+1 < len(as.intervals) {
+//line debug.qtpl:104
+				qw422016.N().S(`,`)
+//line debug.qtpl:104
+			}
+//line debug.qtpl:105
+		}
+//line debug.qtpl:105
+		qw422016.N().S(`],"window":`)
+//line debug.qtpl:107
+		qw422016.N().Q(as.window.String())
+//line debug.qtpl:107
+		qw422016.N().S(`,"dedupInterval":`)
+//line debug.qtpl:108
+		qw422016.N().Q(as.dedupInterval.String())
+//line debug.qtpl:108
+		qw422016.N().S(`,"outputs": [`)
+//line debug.qtpl:110
+		for j, os := range as.outputs {
+//line debug.qtpl:110
+			qw422016.N().S(`{"output":`)
+//line debug.qtpl:112
+			qw422016.N().Q(os.output)
+//line debug.qtpl:112
+			qw422016.N().S(`,"activeKeys":`)
+//line debug.qtpl:113
+			qw422016.N().D(os.activeKeys)
+//line debug.qtpl:113
+			qw422016.N().S(`}`)
+//line debug.qtpl:115
+			if j+1 < len(as.outputs) {
+//line debug.qtpl:115
+				qw422016.N().S(`,`)
+//line debug.qtpl:115
+			}
+//line debug.qtpl:116
+		}
+//line debug.qtpl:116
+		qw422016.N().S(`],"matchedSamples":`)
+//line debug.qtpl:118
+		qw422016.N().DUL(as.matchedSamples)
+//line debug.qtpl:118
+		qw422016.N().S(`,"ignoredOldSamples":`)
+//line debug.qtpl:119
+		qw422016.N().DUL(as.ignoredOldSamples)
+//line debug.qtpl:119
+		qw422016.N().S(`,"dedupDroppedSamples":`)
+//line debug.qtpl:120
+		qw422016.N().DUL(as.dedupDroppedSamples)
+//line debug.qtpl:120
+		qw422016.N().S(`}`)
+//line debug.qtpl:122
+		if i+1 < len(ass) {
+//line debug.qtpl:122
+			qw422016.N().S(`,`)
+//line debug.qtpl:122
+		}
+//line debug.qtpl:123
+	}
+//line debug.qtpl:123
+	qw422016.N().S(`]}`)
+//line debug.qtpl:126
+}
+
+//line debug.qtpl:126
+func WriteAggregatorsStatusJSON(qq422016 qtio422016.Writer, ass []*aggregatorStatus) {
+//line debug.qtpl:126
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line debug.qtpl:126
+	StreamAggregatorsStatusJSON(qw422016, ass)
+//line debug.qtpl:126
+	qt422016.ReleaseWriter(qw422016)
+//line debug.qtpl:126
+}
+
+//line debug.qtpl:126
+func AggregatorsStatusJSON(ass []*aggregatorStatus) string {
+//line debug.qtpl:126
+	qb422016 := qt422016.AcquireByteBuffer()
+//line debug.qtpl:126
+	WriteAggregatorsStatusJSON(qb422016, ass)
+//line debug.qtpl:126
+	qs422016 := string(qb422016.B)
+//line debug.qtpl:126
+	qt422016.ReleaseByteBuffer(qb422016)
+//line debug.qtpl:126
+	return qs422016
+//line debug.qtpl:126
+}
+
+//line debug.qtpl:128
+func StreamDryRunHTML(qw422016 *qt422016.Writer, config, metrics string, tss []prompbmarshal.TimeSeries, err error) {
+//line debug.qtpl:128
+	qw422016.N().S(`<!DOCTYPE html><html lang="en"><head>`)
+//line debug.qtpl:132
+	htmlcomponents.StreamCommonHeader(qw422016)
+//line debug.qtpl:132
+	qw422016.N().S(`<title>Stream aggregation dry run</title></head><body>`)
+//line debug.qtpl:136
+	htmlcomponents.StreamNavbar(qw422016)
+//line debug.qtpl:136
+	qw422016.N().S(`<div class="container-fluid"><a href="https://docs.victoriametrics.com/stream-aggregation/" target="_blank">Stream aggregation docs</a>`)
+//line debug.qtpl:138
+	qw422016.N().S(` `)
+//line debug.qtpl:138
+	qw422016.N().S(`<a href="stream-agg">Stream aggregators</a><br>`)
+//line debug.qtpl:141
+	if err != nil {
+//line debug.qtpl:142
+		htmlcomponents.StreamErrorNotification(qw422016, err)
+//line debug.qtpl:143
+	}
+//line debug.qtpl:143
+	qw422016.N().S(`<div class="m-3"><form method="POST"><div>Stream aggregation config:<br/><textarea name="config" style="width: 100%; height: 15em; font-family: monospace" class="m-1">`)
+//line debug.qtpl:149
+	qw422016.E().S(config)
+//line debug.qtpl:149
+	qw422016.N().S(`</textarea></div><div>Input samples in Prometheus text exposition format:<br/><textarea name="metrics" style="width: 100%; height: 10em; font-family: monospace" class="m-1">`)
+//line debug.qtpl:153
+	qw422016.E().S(metrics)
+//line debug.qtpl:153
+	qw422016.N().S(`</textarea></div><input type="submit" value="Submit" class="btn btn-primary m-1" /></form></div><div class="row"><main class="col-12"><table class="table table-striped table-hover table-bordered table-sm"><thead><tr><th scope="col" style="width: 70%">Output series</th><th scope="col" style="width: 15%">Value</th><th scope="col" style="width: 15%">Timestamp</th></tr></thead><tbody>`)
+//line debug.qtpl:170
+	for _, ts := range tss {
+//line debug.qtpl:171
+		for _, s := range ts.Samples {
+//line debug.qtpl:171
+			qw422016.N().S(`<tr><td><samp>`)
+//line debug.qtpl:173
+			qw422016.E().S(promrelabel.LabelsToString(ts.Labels))
+//line debug.qtpl:173
+			qw422016.N().S(`</samp></td><td>`)
+//line debug.qtpl:174
+			qw422016.N().F(s.Value)
+//line debug.qtpl:174
+			qw422016.N().S(`</td><td>`)
+//line debug.qtpl:175
+			qw422016.N().DL(s.Timestamp)
+//line debug.qtpl:175
+			qw422016.N().S(`</td></tr>`)
+//line debug.qtpl:177
+		}
+//line debug.qtpl:178
+	}
+//line debug.qtpl:178
+	qw422016.N().S(`</tbody></table></main></div></div></body></html>`)
+//line debug.qtpl:186
+}
+
+//line debug.qtpl:186
+func WriteDryRunHTML(qq422016 qtio422016.Writer, config, metrics string, tss []prompbmarshal.TimeSeries, err error) {
+//line debug.qtpl:186
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line debug.qtpl:186
+	StreamDryRunHTML(qw422016, config, metrics, tss, err)
+//line debug.qtpl:186
+	qt422016.ReleaseWriter(qw422016)
+//line debug.qtpl:186
+}
+
+//line debug.qtpl:186
+func DryRunHTML(config, metrics string, tss []prompbmarshal.TimeSeries, err error) string {
+//line debug.qtpl:186
+	qb422016 := qt422016.AcquireByteBuffer()
+//line debug.qtpl:186
+	WriteDryRunHTML(qb422016, config, metrics, tss, err)
+//line debug.qtpl:186
+	qs422016 := string(qb422016.B)
+//line debug.qtpl:186
+	qt422016.ReleaseByteBuffer(qb422016)
+//line debug.qtpl:186
+	return qs422016
+//line debug.qtpl:186
+}
+
+//line debug.qtpl:188
+func StreamDryRunJSON(qw422016 *qt422016.Writer, tss []prompbmarshal.TimeSeries, err error) {
+//line debug.qtpl:188
+	qw422016.N().S(`{`)
+//line debug.qtpl:190
+	if err != nil {
+//line debug.qtpl:190
+		qw422016.N().S(`"status": "error","error":`)
+//line debug.qtpl:192
+		qw422016.N().Q(fmt.Sprintf("Error: %s", err))
+//line debug.qtpl:193
+	} else {
+//line debug.qtpl:193
+		qw422016.N().S(`"status": "success","data": [`)
+//line debug.qtpl:196
+		for i, ts := range tss {
+//line debug.qtpl:196
+			qw422016.N().S(`{"metric":`)
+//line debug.qtpl:198
+			qw422016.N().Q(promrelabel.LabelsToString(ts.Labels))
+//line debug.qtpl:198
+			qw422016.N().S(`,"samples": [`)
+//line debug.qtpl:200
+			for j, s := range ts.Samples {
+//line debug.qtpl:200
+				qw422016.N().S(`[`)
+//line debug.qtpl:201
+				qw422016.N().DL(s.Timestamp)
+//line debug.qtpl:201
+				qw422016.N().S(`,`)
+//line debug.qtpl:201
+				qw422016.N().Q(fmt.Sprintf("%g", s.Value))
+//line debug.qtpl:201
+				qw422016.N().S(`]`)
+//line debug.qtpl:202
+				if j+1 < len(ts.Samples) {
+//line debug.qtpl:202
+					qw422016.N().S(`,`)
+//line debug.qtpl:202
+				}
+//line debug.qtpl:203
+			}
+//line debug.qtpl:203
+			qw422016.N().S(`]}`)
+//line debug.qtpl:206
+			if i+1 < len(tss) {
+//line debug.qtpl:206
+				qw422016.N().S(`,`)
+//line debug.qtpl:206
+			}
+//line debug.qtpl:207
+		}
+//line debug.qtpl:207
+		qw422016.N().S(`]`)
+//line debug.qtpl:209
+	}
+//line debug.qtpl:209
+	qw422016.N().S(`}`)
+//line debug.qtpl:211
+}
+
+//line debug.qtpl:211
+func WriteDryRunJSON(qq422016 qtio422016.Writer, tss []prompbmarshal.TimeSeries, err error) {
+//line debug.qtpl:211
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line debug.qtpl:211
+	StreamDryRunJSON(qw422016, tss, err)
+//line debug.qtpl:211
+	qt422016.ReleaseWriter(qw422016)
+//line debug.qtpl:211
+}
+
+//line debug.qtpl:211
+func DryRunJSON(tss []prompbmarshal.TimeSeries, err error) string {
+//line debug.qtpl:211
+	qb422016 := qt422016.AcquireByteBuffer()
+//line debug.qtpl:211
+	WriteDryRunJSON(qb422016, tss, err)
+//line debug.qtpl:211
+	qs422016 := string(qb422016.B)
+//line debug.qtpl:211
+	qt422016.ReleaseByteBuffer(qb422016)
+//line debug.qtpl:211
+	return qs422016
+//line debug.qtpl:211
+}
+
+//line debug.qtpl:213
+func streamstringsJSON(qw422016 *qt422016.Writer, a []string) {
+//line debug.qtpl:213
+	qw422016.N().S(`[`)
+//line debug.qtpl:215
+	for i, s := range a {
+//line debug.qtpl:216
+		qw422016.N().Q(s)
+//line debug.qtpl:217
+		if i+1 < len(a) {
+//line debug.qtpl:217
+			qw422016.N().S(`,`)
+//line debug.qtpl:217
+		}
+//line debug.qtpl:218
+	}
+//line debug.qtpl:218
+	qw422016.N().S(`]`)
+//line debug.qtpl:220
+}
+
+//line debug.qtpl:220
+func writestringsJSON(qq422016 qtio422016.Writer, a []string) {
+//line debug.qtpl:220
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line debug.qtpl:220
+	streamstringsJSON(qw422016, a)
+//line debug.qtpl:220
+	qt422016.ReleaseWriter(qw422016)
+//line debug.qtpl:220
+}
+
+//line debug.qtpl:220
+func stringsJSON(a []string) string {
+//line debug.qtpl:220
+	qb422016 := qt422016.AcquireByteBuffer()
+//line debug.qtpl:220
+	writestringsJSON(qb422016, a)
+//line debug.qtpl:220
+	qs422016 := string(qb422016.B)
+//line debug.qtpl:220
+	qt422016.ReleaseByteBuffer(qb422016)
+//line debug.qtpl:220
+	return qs422016
+//line debug.qtpl:220
+}
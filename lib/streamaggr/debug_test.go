@@ -0,0 +1,227 @@
+package streamaggr
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestDryRunSuccess(t *testing.T) {
+	f := func(config, metrics, outputMetricsExpected string) {
+		t.Helper()
+
+		tss, err := dryRun(config, metrics, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, ts := range tss {
+			if len(ts.Samples) != 1 || ts.Samples[0].Timestamp <= 0 {
+				t.Fatalf("unexpected samples for %s: %v", ts.Labels, ts.Samples)
+			}
+		}
+		outputMetrics := timeSeriessToString(tss)
+		if outputMetrics != outputMetricsExpected {
+			t.Fatalf("unexpected output metrics;\ngot\n%s\nwant\n%s", outputMetrics, outputMetricsExpected)
+		}
+	}
+
+	// empty input
+	f(``, ``, ``)
+	f(`
+- interval: 1m
+  outputs: [count_samples]
+`, ``, ``)
+
+	// samples without timestamps
+	f(`
+- interval: 1m
+  by: [job]
+  outputs: [sum_samples, count_series]
+`, `
+foo{job="a",instance="x"} 1
+foo{job="a",instance="y"} 2
+foo{job="b",instance="x"} 3
+`, `foo:1m_by_job_count_series{job="a"} 2
+foo:1m_by_job_count_series{job="b"} 1
+foo:1m_by_job_sum_samples{job="a"} 3
+foo:1m_by_job_sum_samples{job="b"} 3
+`)
+
+	// samples with timestamps and match filter
+	f(`
+- interval: 1m
+  match: 'foo{job="a"}'
+  without: [instance]
+  outputs: [last]
+`, `
+foo{job="a",instance="x"} 1 1000
+foo{job="a",instance="x"} 5 2000
+foo{job="b",instance="x"} 3 3000
+bar{job="a"} 4
+`, `foo:1m_without_instance_last{job="a"} 5
+`)
+
+	// de-duplication
+	f(`
+- interval: 1m
+  dedup_interval: 30s
+  outputs: [count_samples]
+`, `
+foo 1 1000
+foo 2 1000
+foo 3 2000
+`, `foo:1m_count_samples 1
+`)
+}
+
+func TestDryRunFailure(t *testing.T) {
+	f := func(config, metrics string) {
+		t.Helper()
+
+		_, err := dryRun(config, metrics, nil)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid config
+	f(`foobar`, `foo 1`)
+	f(`
+- interval: 1m
+  outputs: [unknown_output]
+`, `foo 1`)
+
+	// invalid metrics
+	f(`
+- interval: 1m
+  outputs: [count_samples]
+`, `foo{bar 1`)
+}
+
+func TestWriteAggregatorsStatus(t *testing.T) {
+	pushFunc := func(_ []prompbmarshal.TimeSeries) {}
+	a, err := LoadFromData([]byte(`
+- name: foo-aggr
+  match: '{__name__=~"foo|bar"}'
+  interval: 1m
+  dedup_interval: 30s
+  by: [job]
+  outputs: [sum_samples, count_series]
+- interval: 5m
+  without: [instance]
+  outputs: [last]
+`), pushFunc, nil, "some_alias")
+	if err != nil {
+		t.Fatalf("cannot initialize aggregators: %s", err)
+	}
+	defer a.MustStop()
+
+	a.Push(prompbmarshal.MustParsePromMetrics(`
+foo{job="a",instance="x"} 1
+foo{job="a",instance="x"} 2
+foo{job="b",instance="x"} 3
+baz{job="a",instance="x"} 4
+`, 0), nil)
+
+	r := httptest.NewRequest("GET", "/stream-agg?format=json", nil)
+	w := httptest.NewRecorder()
+	WriteAggregatorsStatus(w, r, []*Aggregators{nil, a})
+
+	var resp struct {
+		Status string
+		Data   []struct {
+			Name      string
+			URL       string
+			Position  int
+			Match     string
+			By        []string
+			Without   []string
+			Intervals []string
+			Outputs   []struct {
+				Output     string
+				ActiveKeys int
+			}
+			MatchedSamples      uint64
+			IgnoredOldSamples   uint64
+			DedupDroppedSamples uint64
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("cannot parse response %q: %s", w.Body.String(), err)
+	}
+	if resp.Status != "success" || len(resp.Data) != 2 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+
+	as := resp.Data[0]
+	if as.Name != "foo-aggr" || as.URL != "some_alias" || as.Position != 1 || strings.Join(as.Intervals, ",") != "1m0s" {
+		t.Fatalf("unexpected status for the first aggregator: %s", w.Body.String())
+	}
+	if as.Match != `{__name__=~"foo|bar"}` || strings.Join(as.By, ",") != "job" || len(as.Without) != 0 {
+		t.Fatalf("unexpected filters for the first aggregator: %s", w.Body.String())
+	}
+	if as.MatchedSamples != 3 || as.DedupDroppedSamples != 1 {
+		t.Fatalf("unexpected sample counters for the first aggregator: %s", w.Body.String())
+	}
+	if len(as.Outputs) != 2 || as.Outputs[0].Output != "sum_samples" || as.Outputs[1].Output != "count_series" {
+		t.Fatalf("unexpected outputs for the first aggregator: %s", w.Body.String())
+	}
+
+	as = resp.Data[1]
+	if as.Name != "none" || as.Position != 2 || strings.Join(as.Without, ",") != "instance" {
+		t.Fatalf("unexpected status for the second aggregator: %s", w.Body.String())
+	}
+	if as.MatchedSamples != 4 || as.IgnoredOldSamples != 0 {
+		t.Fatalf("unexpected sample counters for the second aggregator: %s", w.Body.String())
+	}
+	if len(as.Outputs) != 1 || as.Outputs[0].ActiveKeys != 3 {
+		t.Fatalf("unexpected outputs for the second aggregator: %s", w.Body.String())
+	}
+
+	// Verify the HTML page
+	r = httptest.NewRequest("GET", "/stream-agg", nil)
+	w = httptest.NewRecorder()
+	WriteAggregatorsStatus(w, r, []*Aggregators{a})
+	if !strings.Contains(w.Body.String(), "foo-aggr") {
+		t.Fatalf("missing aggregator name at the HTML page: %s", w.Body.String())
+	}
+}
+
+func TestWriteDryRun(t *testing.T) {
+	f := func(config, metrics, format string, bodyExpected []string) {
+		t.Helper()
+
+		args := url.Values{}
+		args.Set("config", config)
+		args.Set("metrics", metrics)
+		args.Set("format", format)
+		r := httptest.NewRequest("POST", "/stream-agg-dry-run", strings.NewReader(args.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		WriteDryRun(w, r, nil)
+		body := w.Body.String()
+		if format == "json" {
+			var v any
+			if err := json.Unmarshal([]byte(body), &v); err != nil {
+				t.Fatalf("cannot parse JSON response %q: %s", body, err)
+			}
+		}
+		for _, s := range bodyExpected {
+			if !strings.Contains(body, s) {
+				t.Fatalf("missing %q in the response\n%s", s, body)
+			}
+		}
+	}
+
+	config := `
+- interval: 1m
+  outputs: [sum_samples]
+`
+	f(config, `foo 1 1000`, "json", []string{`"status": "success"`, `"metric":"foo:1m_sum_samples"`, `,"1"]`})
+	f(config, `foo{`, "json", []string{`"status": "error"`, `cannot parse metrics`})
+	f(config, `foo 1 1000`, "", []string{`foo:1m_sum_samples`, `<textarea name="metrics"`})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
 )
 
@@ -64,7 +65,9 @@ func (da *dedupAggr) itemsCount() uint64 {
 	return n
 }
 
-func (da *dedupAggr) pushSamples(samples []pushSample) {
+// pushSamples pushes samples to da and returns the number of samples dropped as duplicates.
+func (da *dedupAggr) pushSamples(samples []pushSample) int {
+	duplicates := 0
 	pss := getPerShardSamples()
 	shards := pss.shards
 	for _, sample := range samples {
@@ -76,9 +79,10 @@ func (da *dedupAggr) pushSamples(samples []pushSample) {
 		if len(shardSamples) == 0 {
 			continue
 		}
-		da.shards[i].pushSamples(shardSamples)
+		duplicates += da.shards[i].pushSamples(shardSamples)
 	}
 	putPerShardSamples(pss)
+	return duplicates
 }
 
 func getDedupFlushCtx() *dedupFlushCtx {
@@ -155,7 +159,7 @@ func putPerShardSamples(pss *perShardSamples) {
 
 var perShardSamplesPool sync.Pool
 
-func (das *dedupAggrShard) pushSamples(samples []pushSample) {
+func (das *dedupAggrShard) pushSamples(samples []pushSample) int {
 	das.mu.Lock()
 	defer das.mu.Unlock()
 
@@ -164,6 +168,7 @@ func (das *dedupAggrShard) pushSamples(samples []pushSample) {
 		m = make(map[string]*dedupAggrSample, len(samples))
 		das.m = m
 	}
+	duplicates := 0
 	samplesBuf := das.samplesBuf
 	for _, sample := range samples {
 		s, ok := m[sample.key]
@@ -180,12 +185,15 @@ func (das *dedupAggrShard) pushSamples(samples []pushSample) {
 			das.sizeBytes.Add(uint64(len(key)) + uint64(unsafe.Sizeof(key)+unsafe.Sizeof(s)+unsafe.Sizeof(*s)))
 			continue
 		}
+		// Either the existing sample or the pushed sample is dropped.
+		duplicates++
 		if !isDuplicate(s, sample) {
 			s.value = sample.value
 			s.timestamp = sample.timestamp
 		}
 	}
 	das.samplesBuf = samplesBuf
+	return duplicates
 }
 
 // isDuplicate returns true if b is duplicate of a
@@ -240,3 +248,47 @@ func (das *dedupAggrShard) flush(ctx *dedupFlushCtx, f func(samples []pushSample
 	f(dstSamples)
 	ctx.samples = dstSamples
 }
+
+func (da *dedupAggr) marshalState(dst []byte) []byte {
+	for i := range da.shards {
+		das := &da.shards[i]
+		das.mu.Lock()
+		for key, s := range das.m {
+			inputKey, outputKey := getInputOutputKey(key)
+			dst = marshalStateKey(dst, inputKey)
+			dst = marshalStateKey(dst, outputKey)
+			dst = marshalStateFloat64(dst, s.value)
+			dst = encoding.MarshalVarInt64(dst, s.timestamp)
+		}
+		das.mu.Unlock()
+	}
+	return dst
+}
+
+func (da *dedupAggr) unmarshalState(src []byte) (func(), error) {
+	var samples []pushSample
+	var buf []byte
+	sr := &stateReader{
+		src: src,
+	}
+	for len(sr.src) > 0 && sr.err == nil {
+		inputKey := sr.readKey()
+		outputKey := sr.readKey()
+		bufLen := len(buf)
+		buf = encoding.MarshalVarUint64(buf, uint64(len(inputKey)))
+		buf = append(buf, inputKey...)
+		buf = append(buf, outputKey...)
+		samples = append(samples, pushSample{
+			key:       bytesutil.ToUnsafeString(buf[bufLen:]),
+			value:     sr.readFloat64(),
+			timestamp: sr.readInt64(),
+		})
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	apply := func() {
+		da.pushSamples(samples)
+	}
+	return apply, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/metrics"
 )
@@ -106,6 +107,68 @@ func (as *histogramBucketAggrState) flushState(ctx *flushCtx) {
 	})
 }
 
+func (as *histogramBucketAggrState) marshalState(dst []byte) []byte {
+	as.m.Range(func(k, v any) bool {
+		sv := v.(*histogramBucketStateValue)
+
+		sv.mu.Lock()
+		if !sv.deleted {
+			dst = marshalStateKey(dst, k.(string))
+			dst = encoding.MarshalVarUint64(dst, sv.deleteDeadline)
+			bucketsLen := 0
+			sv.h.VisitNonZeroBuckets(func(_ string, _ uint64) {
+				bucketsLen++
+			})
+			dst = encoding.MarshalVarUint64(dst, uint64(bucketsLen))
+			sv.h.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+				dst = encoding.MarshalBytes(dst, []byte(vmrange))
+				dst = encoding.MarshalVarUint64(dst, count)
+			})
+		}
+		sv.mu.Unlock()
+		return true
+	})
+	return dst
+}
+
+func (as *histogramBucketAggrState) unmarshalState(src []byte) (func(), error) {
+	type entry struct {
+		key string
+		sv  *histogramBucketStateValue
+	}
+	var entries []entry
+	sr := &stateReader{
+		src: src,
+	}
+	for len(sr.src) > 0 && sr.err == nil {
+		key := sr.readKey()
+		sv := &histogramBucketStateValue{
+			deleteDeadline: sr.readUint64(),
+		}
+		bucketsLen := sr.readUint64()
+		for i := uint64(0); i < bucketsLen && sr.err == nil; i++ {
+			vmrange := sr.readBytes()
+			count := sr.readUint64()
+			if sr.err == nil {
+				sr.err = updateHistogramBucket(&sv.h, string(vmrange), count)
+			}
+		}
+		entries = append(entries, entry{
+			key: key,
+			sv:  sv,
+		})
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	apply := func() {
+		for _, e := range entries {
+			as.m.Store(e.key, e.sv)
+		}
+	}
+	return apply, nil
+}
+
 func roundDurationToSecs(d time.Duration) uint64 {
 	if d < 0 {
 		return 0
@@ -113,3 +176,7 @@ func roundDurationToSecs(d time.Duration) uint64 {
 	secs := d.Seconds()
 	return uint64(math.Ceil(secs))
 }
+
+func (as *histogramBucketAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -0,0 +1,87 @@
+package streamaggr
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of bits from the hash used for register index in hyperLogLog.
+//
+// The standard error of the estimation is 1.04/sqrt(2^hllPrecision) ~= 0.81%.
+const hllPrecision = 14
+
+const hllRegistersCount = 1 << hllPrecision
+
+// hllSparseMaxItems is the maximum number of hashes hyperLogLog stores as is before switching to registers.
+//
+// This keeps exact counts and low memory usage for small cardinalities.
+const hllSparseMaxItems = 1024
+
+// hyperLogLog estimates the number of unique hashes.
+//
+// It uses up to hllRegistersCount bytes of memory regardless of the number of unique hashes.
+//
+// See https://en.wikipedia.org/wiki/HyperLogLog
+type hyperLogLog struct {
+	// sparse contains unique hashes until their number exceeds hllSparseMaxItems.
+	sparse map[uint64]struct{}
+
+	// registers is initialized when the number of unique hashes exceeds hllSparseMaxItems.
+	registers []uint8
+}
+
+// add adds the hash h to hll.
+func (hll *hyperLogLog) add(h uint64) {
+	if hll.registers != nil {
+		hll.addRegister(h)
+		return
+	}
+	if hll.sparse == nil {
+		hll.sparse = make(map[uint64]struct{})
+	}
+	hll.sparse[h] = struct{}{}
+	if len(hll.sparse) <= hllSparseMaxItems {
+		return
+	}
+
+	// Switch to registers.
+	hll.registers = make([]uint8, hllRegistersCount)
+	for h := range hll.sparse {
+		hll.addRegister(h)
+	}
+	hll.sparse = nil
+}
+
+func (hll *hyperLogLog) addRegister(h uint64) {
+	idx := h >> (64 - hllPrecision)
+	// Set the lowest bit in the remaining bits, so rho never exceeds 64-hllPrecision+1.
+	w := h<<hllPrecision | 1<<(hllPrecision-1)
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if rho > hll.registers[idx] {
+		hll.registers[idx] = rho
+	}
+}
+
+// count returns the estimated number of unique hashes added to hll.
+func (hll *hyperLogLog) count() uint64 {
+	if hll.registers == nil {
+		return uint64(len(hll.sparse))
+	}
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range hll.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(hllRegistersCount)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Use linear counting for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
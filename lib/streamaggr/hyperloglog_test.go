@@ -0,0 +1,45 @@
+package streamaggr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	f := func(n int, maxRelativeError float64) {
+		t.Helper()
+
+		var hll hyperLogLog
+		var buf []byte
+		for i := 0; i < n; i++ {
+			buf = encoding.MarshalUint64(buf[:0], uint64(i))
+			h := xxhash.Sum64(buf)
+			// Add every item twice in order to verify duplicates are ignored.
+			hll.add(h)
+			hll.add(h)
+		}
+		count := hll.count()
+		relativeError := math.Abs(float64(count)-float64(n)) / float64(n)
+		if relativeError > maxRelativeError {
+			t.Fatalf("too big relative error for n=%d; got %.4f; want up to %.4f; count=%d", n, relativeError, maxRelativeError, count)
+		}
+		if n > hllSparseMaxItems && len(hll.registers) != hllRegistersCount {
+			t.Fatalf("expecting switching to registers for n=%d", n)
+		}
+	}
+
+	// Small cardinalities are counted exactly.
+	f(1, 0)
+	f(100, 0)
+	f(hllSparseMaxItems, 0)
+
+	// Big cardinalities are estimated.
+	f(hllSparseMaxItems+1, 0.03)
+	f(10_000, 0.03)
+	f(100_000, 0.03)
+	f(1_000_000, 0.03)
+}
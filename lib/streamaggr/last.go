@@ -80,3 +80,7 @@ func (as *lastAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *lastAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -77,3 +77,7 @@ func (as *maxAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *maxAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
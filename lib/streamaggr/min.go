@@ -76,3 +76,7 @@ func (as *minAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *minAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
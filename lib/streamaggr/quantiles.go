@@ -89,3 +89,7 @@ func (as *quantilesAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *quantilesAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 )
 
@@ -139,12 +140,14 @@ func (as *rateAggrState) flushState(ctx *flushCtx) {
 		}
 
 		result := sumRate
+		weight := 1.0
 		if as.isAvg {
 			result /= float64(countSeries)
+			weight = float64(countSeries)
 		}
 
 		key := k.(string)
-		ctx.appendSeries(key, suffix, result)
+		ctx.appendWeightedSeries(key, suffix, result, weight)
 		return true
 	})
 }
@@ -181,3 +184,73 @@ func (as *rateAggrState) removeOldEntries(currentTime uint64) {
 		return true
 	})
 }
+
+func (as *rateAggrState) marshalState(dst []byte) []byte {
+	as.m.Range(func(k, v any) bool {
+		sv := v.(*rateStateValue)
+
+		sv.mu.Lock()
+		if !sv.deleted {
+			dst = marshalStateKey(dst, k.(string))
+			dst = encoding.MarshalVarUint64(dst, sv.deleteDeadline)
+			dst = encoding.MarshalVarUint64(dst, uint64(len(sv.lastValues)))
+			for inputKey, lv := range sv.lastValues {
+				dst = marshalStateKey(dst, inputKey)
+				dst = marshalStateFloat64(dst, lv.value)
+				dst = encoding.MarshalVarInt64(dst, lv.timestamp)
+				dst = encoding.MarshalVarUint64(dst, lv.deleteDeadline)
+				dst = marshalStateFloat64(dst, lv.increase)
+				dst = encoding.MarshalVarInt64(dst, lv.prevTimestamp)
+			}
+		}
+		sv.mu.Unlock()
+		return true
+	})
+	return dst
+}
+
+func (as *rateAggrState) unmarshalState(src []byte) (func(), error) {
+	type entry struct {
+		key string
+		sv  *rateStateValue
+	}
+	var entries []entry
+	sr := &stateReader{
+		src: src,
+	}
+	for len(sr.src) > 0 && sr.err == nil {
+		key := sr.readKey()
+		sv := &rateStateValue{
+			deleteDeadline: sr.readUint64(),
+			lastValues:     make(map[string]rateLastValueState),
+		}
+		lastValuesLen := sr.readUint64()
+		for i := uint64(0); i < lastValuesLen && sr.err == nil; i++ {
+			inputKey := sr.readKey()
+			sv.lastValues[inputKey] = rateLastValueState{
+				value:          sr.readFloat64(),
+				timestamp:      sr.readInt64(),
+				deleteDeadline: sr.readUint64(),
+				increase:       sr.readFloat64(),
+				prevTimestamp:  sr.readInt64(),
+			}
+		}
+		entries = append(entries, entry{
+			key: key,
+			sv:  sv,
+		})
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	apply := func() {
+		for _, e := range entries {
+			as.m.Store(e.key, e.sv)
+		}
+	}
+	return apply, nil
+}
+
+func (as *rateAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -0,0 +1,375 @@
+package streamaggr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// stateFileMagic is written at the beginning of every file with the persisted aggregator state.
+const stateFileMagic = "vmstreamaggr"
+
+// stateFormatVersion is the version of the persisted aggregator state.
+//
+// It must be incremented on every incompatible change in the marshaled state,
+// so the state persisted by previous releases is discarded instead of being misinterpreted.
+const stateFormatVersion = 1
+
+// aggrStatePersister must be implemented by aggrState, which can be persisted across restarts.
+//
+// See Options.StateDir.
+type aggrStatePersister interface {
+	// marshalState appends the current state to dst and returns the result.
+	marshalState(dst []byte) []byte
+
+	// unmarshalState decodes the state from src, which has been obtained via marshalState.
+	//
+	// The decoded state is applied to the aggrState by calling the returned function.
+	// This allows discarding the decoded state if states for other outputs cannot be decoded.
+	// The returned function must be called before pushing samples to the aggrState.
+	unmarshalState(src []byte) (func(), error)
+}
+
+// getStatePath returns path to the file with the persisted state for the aggregator at the given position in the config at filePath.
+func getStatePath(stateDir, filePath, alias string, aggrID int) string {
+	h := xxhash.Sum64([]byte(alias + "\x00" + filePath))
+	return filepath.Join(stateDir, fmt.Sprintf("%016X_%d.bin", h, aggrID))
+}
+
+// getStateFingerprint returns fingerprint for the aggregator settings, which affect the persisted state.
+//
+// The state persisted with another fingerprint cannot be restored, since it may contain series keys
+// or values incompatible with the current settings.
+//
+// Outputs aren't included in the fingerprint, since the state is persisted individually per each output.
+func getStateFingerprint(cfg *Config, interval, dedupInterval, stalenessInterval time.Duration, by, without, dropInputLabels []string) uint64 {
+	fp := struct {
+		Interval            time.Duration
+		DedupInterval       time.Duration
+		StalenessInterval   time.Duration
+		Match               *promrelabel.IfExpression
+		By                  []string
+		Without             []string
+		DropInputLabels     []string
+		InputRelabelConfigs []promrelabel.RelabelConfig
+	}{
+		Interval:            interval,
+		DedupInterval:       dedupInterval,
+		StalenessInterval:   stalenessInterval,
+		Match:               cfg.Match,
+		By:                  by,
+		Without:             without,
+		DropInputLabels:     dropInputLabels,
+		InputRelabelConfigs: cfg.InputRelabelConfigs,
+	}
+	data, err := json.Marshal(&fp)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal state fingerprint: %s", err)
+	}
+	return xxhash.Sum64(data)
+}
+
+// mustSaveState saves the state of a to a.statePath.
+func (a *aggregator) mustSaveState() {
+	startTime := time.Now()
+	data := a.marshalState(nil)
+	fs.MustWriteAtomic(a.statePath, data, true)
+	logger.Infof("saved stream aggregation state to %q in %.3f seconds; size: %d bytes", a.statePath, time.Since(startTime).Seconds(), len(data))
+}
+
+// loadState restores the state of a from a.statePath if it exists.
+//
+// The state file is removed after loading, so it isn't loaded again on config reload.
+// Incompatible state is logged and discarded.
+func (a *aggregator) loadState() {
+	data, err := os.ReadFile(a.statePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("cannot read stream aggregation state; starting with empty state: %s", err)
+		}
+		return
+	}
+	if err := os.Remove(a.statePath); err != nil {
+		logger.Errorf("cannot remove stream aggregation state file: %s", err)
+	}
+	if err := a.unmarshalState(data); err != nil {
+		logger.Warnf("discarding stream aggregation state at %q: %s", a.statePath, err)
+		return
+	}
+	logger.Infof("restored stream aggregation state from %q", a.statePath)
+}
+
+func (a *aggregator) marshalState(dst []byte) []byte {
+	dst = append(dst, stateFileMagic...)
+	dst = encoding.MarshalVarUint64(dst, stateFormatVersion)
+	dst = encoding.MarshalUint64(dst, a.stateFingerprint)
+
+	var outputs []*aggrOutput
+	for i := range a.aggrOutputs {
+		ao := &a.aggrOutputs[i]
+		if _, ok := ao.as.(aggrStatePersister); ok {
+			outputs = append(outputs, ao)
+		}
+	}
+	dst = encoding.MarshalVarUint64(dst, uint64(len(outputs)))
+	bb := bbPool.Get()
+	for _, ao := range outputs {
+		bb.B = ao.as.(aggrStatePersister).marshalState(bb.B[:0])
+		dst = encoding.MarshalBytes(dst, []byte(ao.name))
+		dst = encoding.MarshalBytes(dst, bb.B)
+	}
+	bb.B = bb.B[:0]
+	if a.da != nil {
+		bb.B = a.da.marshalState(bb.B)
+	}
+	dst = encoding.MarshalBytes(dst, bb.B)
+	bbPool.Put(bb)
+	return dst
+}
+
+func (a *aggregator) unmarshalState(src []byte) error {
+	if !strings.HasPrefix(string(src), stateFileMagic) {
+		return fmt.Errorf("missing %q header", stateFileMagic)
+	}
+	sr := &stateReader{
+		src: src[len(stateFileMagic):],
+	}
+	version := sr.readUint64()
+	if sr.err == nil && version != stateFormatVersion {
+		return fmt.Errorf("unsupported state format version %d; want %d", version, stateFormatVersion)
+	}
+	if sr.err == nil && len(sr.src) < 8 {
+		sr.err = fmt.Errorf("cannot read state fingerprint")
+	}
+	if sr.err != nil {
+		return sr.err
+	}
+	fingerprint := encoding.UnmarshalUint64(sr.src)
+	sr.src = sr.src[8:]
+	if fingerprint != a.stateFingerprint {
+		return fmt.Errorf("the state has been saved for incompatible aggregation settings")
+	}
+
+	outputsStates := make(map[string][]byte)
+	outputsLen := sr.readUint64()
+	for i := uint64(0); i < outputsLen && sr.err == nil; i++ {
+		output := sr.readBytes()
+		outputsStates[string(output)] = sr.readBytes()
+	}
+	dedupState := sr.readBytes()
+	if sr.err != nil {
+		return sr.err
+	}
+	if len(sr.src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after reading the state; len(tail)=%d", len(sr.src))
+	}
+
+	// Decode states for all the outputs before applying them,
+	// so the aggregator isn't left with partially restored state on error.
+	var applyFuncs []func()
+	for i := range a.aggrOutputs {
+		ao := &a.aggrOutputs[i]
+		asp, ok := ao.as.(aggrStatePersister)
+		if !ok {
+			continue
+		}
+		data, ok := outputsStates[ao.name]
+		if !ok {
+			// The output has been added after the state was saved.
+			continue
+		}
+		apply, err := asp.unmarshalState(data)
+		if err != nil {
+			return fmt.Errorf("cannot restore state for output %q: %w", ao.name, err)
+		}
+		applyFuncs = append(applyFuncs, apply)
+	}
+	if a.da != nil && len(dedupState) > 0 {
+		apply, err := a.da.unmarshalState(dedupState)
+		if err != nil {
+			return fmt.Errorf("cannot restore deduplication state: %w", err)
+		}
+		applyFuncs = append(applyFuncs, apply)
+	}
+	for _, apply := range applyFuncs {
+		apply()
+	}
+	return nil
+}
+
+// stateReader reads the state marshaled by aggrStatePersister.
+//
+// The first error is stored in err, while the subsequent reads return zero values.
+type stateReader struct {
+	src []byte
+	err error
+
+	labels []prompbmarshal.Label
+}
+
+func (sr *stateReader) readUint64() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	n, nSize := encoding.UnmarshalVarUint64(sr.src)
+	if nSize <= 0 {
+		sr.err = fmt.Errorf("cannot read uint64")
+		return 0
+	}
+	sr.src = sr.src[nSize:]
+	return n
+}
+
+func (sr *stateReader) readInt64() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	n, nSize := encoding.UnmarshalVarInt64(sr.src)
+	if nSize <= 0 {
+		sr.err = fmt.Errorf("cannot read int64")
+		return 0
+	}
+	sr.src = sr.src[nSize:]
+	return n
+}
+
+func (sr *stateReader) readFloat64() float64 {
+	if sr.err != nil {
+		return 0
+	}
+	if len(sr.src) < 8 {
+		sr.err = fmt.Errorf("cannot read float64")
+		return 0
+	}
+	n := encoding.UnmarshalUint64(sr.src)
+	sr.src = sr.src[8:]
+	return math.Float64frombits(n)
+}
+
+func (sr *stateReader) readBytes() []byte {
+	if sr.err != nil {
+		return nil
+	}
+	b, nSize := encoding.UnmarshalBytes(sr.src)
+	if nSize <= 0 {
+		sr.err = fmt.Errorf("cannot read bytes")
+		return nil
+	}
+	sr.src = sr.src[nSize:]
+	return b
+}
+
+// readLabels reads labels written by marshalStateLabels.
+//
+// The returned labels are valid until the next readLabels call.
+func (sr *stateReader) readLabels() []prompbmarshal.Label {
+	labelsLen := sr.readUint64()
+	if sr.err == nil && labelsLen > uint64(len(sr.src)) {
+		sr.err = fmt.Errorf("too big number of labels: %d", labelsLen)
+	}
+	labels := sr.labels[:0]
+	for i := uint64(0); i < labelsLen && sr.err == nil; i++ {
+		name := sr.readBytes()
+		value := sr.readBytes()
+		labels = append(labels, prompbmarshal.Label{
+			Name:  string(name),
+			Value: string(value),
+		})
+	}
+	sr.labels = labels
+	return labels
+}
+
+// readKey reads the key written by marshalStateKey and returns it in the compressed form used by aggrState.
+func (sr *stateReader) readKey() string {
+	labels := sr.readLabels()
+	if sr.err != nil {
+		return ""
+	}
+	return string(lc.Compress(nil, labels))
+}
+
+// marshalStateKey appends labels for the compressed key to dst and returns the result.
+//
+// Compressed keys cannot be persisted as is, since label indexes at lc change across restarts.
+func marshalStateKey(dst []byte, key string) []byte {
+	labels := promutils.GetLabels()
+	labels.Labels = decompressLabels(labels.Labels[:0], key)
+	dst = marshalStateLabels(dst, labels.Labels)
+	promutils.PutLabels(labels)
+	return dst
+}
+
+func marshalStateLabels(dst []byte, labels []prompbmarshal.Label) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(labels)))
+	for _, label := range labels {
+		dst = encoding.MarshalBytes(dst, []byte(label.Name))
+		dst = encoding.MarshalBytes(dst, []byte(label.Value))
+	}
+	return dst
+}
+
+func marshalStateFloat64(dst []byte, f float64) []byte {
+	return encoding.MarshalUint64(dst, math.Float64bits(f))
+}
+
+// updateHistogramBucket adds count hits to the bucket with the given vmrange at h.
+//
+// vmrange must be obtained from metrics.Histogram.VisitNonZeroBuckets.
+func updateHistogramBucket(h *metrics.Histogram, vmrange string, count uint64) error {
+	n := strings.Index(vmrange, "...")
+	if n < 0 {
+		return fmt.Errorf("missing `...` in vmrange=%q", vmrange)
+	}
+	start, err := strconv.ParseFloat(vmrange[:n], 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse vmrange=%q: %w", vmrange, err)
+	}
+	end, err := strconv.ParseFloat(vmrange[n+len("..."):], 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse vmrange=%q: %w", vmrange, err)
+	}
+
+	// Pick a value in the middle of the bucket, so it isn't affected by rounding of bucket bounds in vmrange.
+	var v float64
+	switch {
+	case start == 0:
+		v = end / 2
+	case math.IsInf(end, 1):
+		v = start * 2
+	default:
+		v = math.Sqrt(start * end)
+	}
+
+	// Add count hits with O(log(count)) merges instead of count updates.
+	pow := &metrics.Histogram{}
+	pow.Update(v)
+	for count > 0 {
+		if count&1 != 0 {
+			h.Merge(pow)
+		}
+		count >>= 1
+		if count > 0 {
+			next := &metrics.Histogram{}
+			next.Merge(pow)
+			next.Merge(pow)
+			pow = next
+		}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package streamaggr
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestAggregatorsStateRestore(t *testing.T) {
+	f := func(configBefore, inputMetricsBefore, configAfter, inputMetricsAfter, outputMetricsExpected string) {
+		t.Helper()
+
+		stateDir := t.TempDir()
+
+		// Initialize Aggregators, push inputMetricsBefore and stop them without flushing the incomplete state.
+		pushFunc := func(_ []prompbmarshal.TimeSeries) {}
+		opts := &Options{
+			NoAlignFlushToInterval: true,
+			StateDir:               stateDir,
+		}
+		a, err := LoadFromData([]byte(configBefore), pushFunc, opts, "some_alias")
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+		offsetMsecs := time.Now().UnixMilli()
+		a.Push(prompbmarshal.MustParsePromMetrics(inputMetricsBefore, offsetMsecs), nil)
+		a.MustStop()
+
+		// Initialize Aggregators with the persisted state, push inputMetricsAfter and flush the state on shutdown.
+		var tssOutput []prompbmarshal.TimeSeries
+		var tssOutputLock sync.Mutex
+		pushFunc = func(tss []prompbmarshal.TimeSeries) {
+			tssOutputLock.Lock()
+			tssOutput = appendClonedTimeseries(tssOutput, tss)
+			tssOutputLock.Unlock()
+		}
+		opts = &Options{
+			FlushOnShutdown:        true,
+			NoAlignFlushToInterval: true,
+			StateDir:               stateDir,
+		}
+		a, err = LoadFromData([]byte(configAfter), pushFunc, opts, "some_alias")
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+		a.Push(prompbmarshal.MustParsePromMetrics(inputMetricsAfter, offsetMsecs), nil)
+		a.MustStop()
+
+		outputMetrics := timeSeriessToString(tssOutput)
+		if outputMetrics != outputMetricsExpected {
+			t.Fatalf("unexpected output metrics;\ngot\n%s\nwant\n%s", outputMetrics, outputMetricsExpected)
+		}
+	}
+
+	// total and increase continue from the persisted state
+	config := `
+- interval: 1m
+  by: [job]
+  outputs: [total_prometheus, increase_prometheus]
+`
+	f(config, `
+foo{job="a",instance="x"} 1 0
+foo{job="a",instance="x"} 5 10
+foo{job="a",instance="y"} 10 10
+`, config, `
+foo{job="a",instance="x"} 8 20
+foo{job="a",instance="y"} 12 20
+`, `foo:1m_by_job_increase_prometheus{job="a"} 9
+foo:1m_by_job_total_prometheus{job="a"} 9
+`)
+
+	// histogram_bucket continues from the persisted state
+	config = `
+- interval: 1m
+  outputs: [histogram_bucket]
+`
+	f(config, `
+foo 1 0
+foo 1 10
+foo 1e25 10
+`, config, `
+foo 1 20
+`, `foo:1m_histogram_bucket{vmrange="1.000e+18...+Inf"} 1
+foo:1m_histogram_bucket{vmrange="8.799e-01...1.000e+00"} 3
+`)
+
+	// de-duplication state is persisted
+	config = `
+- interval: 1m
+  dedup_interval: 30s
+  outputs: [sum_samples]
+`
+	f(config, `
+foo 1 0
+bar 2 0
+`, config, `
+foo 3 10
+`, `bar:1m_sum_samples 2
+foo:1m_sum_samples 3
+`)
+
+	// new outputs start with empty state, while the state for the remaining outputs is restored
+	f(`
+- interval: 1m
+  outputs: [total_prometheus]
+`, `
+foo 1 0
+foo 5 10
+`, `
+- interval: 1m
+  outputs: [total_prometheus, increase_prometheus]
+`, `
+foo 8 20
+`, `foo:1m_increase_prometheus 0
+foo:1m_total_prometheus 7
+`)
+
+	// the state is discarded on incompatible config change
+	f(`
+- interval: 1m
+  outputs: [total_prometheus]
+`, `
+foo 1 0
+foo 5 10
+`, `
+- interval: 1m
+  without: [instance]
+  outputs: [total_prometheus]
+`, `
+foo 8 20
+`, `foo:1m_without_instance_total_prometheus 0
+`)
+}
+
+func TestAggregatorsStateDiscardInvalid(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		stateDir := t.TempDir()
+		config := `
+- interval: 1m
+  outputs: [total_prometheus]
+`
+		statePath := getStatePath(stateDir, "inmemory", "some_alias", 1)
+		if err := os.WriteFile(statePath, []byte(data), 0o644); err != nil {
+			t.Fatalf("cannot write state file: %s", err)
+		}
+
+		var tssOutput []prompbmarshal.TimeSeries
+		pushFunc := func(tss []prompbmarshal.TimeSeries) {
+			tssOutput = appendClonedTimeseries(tssOutput, tss)
+		}
+		opts := &Options{
+			FlushOnShutdown:        true,
+			NoAlignFlushToInterval: true,
+			StateDir:               stateDir,
+		}
+		a, err := LoadFromData([]byte(config), pushFunc, opts, "some_alias")
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+		if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+			t.Fatalf("expecting the state file to be removed after loading; got %v", err)
+		}
+		a.MustStop()
+
+		if len(tssOutput) > 0 {
+			t.Fatalf("unexpected output metrics for discarded state: %s", timeSeriessToString(tssOutput))
+		}
+		if _, err := os.Stat(statePath); err != nil {
+			t.Fatalf("expecting the state file to be saved on shutdown: %s", err)
+		}
+	}
+
+	f("")
+	f("foobar")
+	f(stateFileMagic)
+	f(stateFileMagic + "\x02")
+	f(stateFileMagic + "\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+}
+
+func TestAggregatorUnmarshalStateAtomic(t *testing.T) {
+	config := `
+- interval: 1m
+  outputs: [total_prometheus, increase_prometheus]
+`
+	pushFunc := func(_ []prompbmarshal.TimeSeries) {}
+	newAggregator := func() (*Aggregators, *aggregator) {
+		t.Helper()
+		a, err := LoadFromData([]byte(config), pushFunc, nil, "some_alias")
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+		return a, a.as[0]
+	}
+
+	a, ag := newAggregator()
+	defer a.MustStop()
+	a.Push(prompbmarshal.MustParsePromMetrics(`
+foo 1 0
+foo 5 10
+`, time.Now().UnixMilli()), nil)
+
+	// Marshal the state with the truncated state for the last output.
+	data := append([]byte{}, stateFileMagic...)
+	data = encoding.MarshalVarUint64(data, stateFormatVersion)
+	data = encoding.MarshalUint64(data, ag.stateFingerprint)
+	data = encoding.MarshalVarUint64(data, uint64(len(ag.aggrOutputs)))
+	for i := range ag.aggrOutputs {
+		ao := &ag.aggrOutputs[i]
+		state := ao.as.(aggrStatePersister).marshalState(nil)
+		if i == len(ag.aggrOutputs)-1 {
+			state = state[:len(state)-1]
+		}
+		data = encoding.MarshalBytes(data, []byte(ao.name))
+		data = encoding.MarshalBytes(data, state)
+	}
+	data = encoding.MarshalBytes(data, nil)
+
+	aNew, agNew := newAggregator()
+	defer aNew.MustStop()
+	if err := agNew.unmarshalState(data); err == nil {
+		t.Fatalf("expecting non-nil error for truncated state")
+	}
+
+	// The state mustn't be restored for any output.
+	for i := range agNew.aggrOutputs {
+		ao := &agNew.aggrOutputs[i]
+		if state := ao.as.(aggrStatePersister).marshalState(nil); len(state) > 0 {
+			t.Fatalf("unexpected state restored for output %q: %X", ao.name, state)
+		}
+	}
+}
+
+func TestGetStatePath(t *testing.T) {
+	f := func(filePath, alias string, aggrID int) string {
+		t.Helper()
+		path := getStatePath("state", filePath, alias, aggrID)
+		if filepath.Dir(path) != "state" {
+			t.Fatalf("unexpected directory for %q", path)
+		}
+		return path
+	}
+
+	path := f("config.yml", "global", 1)
+	if path != f("config.yml", "global", 1) {
+		t.Fatalf("state path must be stable")
+	}
+	if path == f("config.yml", "global", 2) {
+		t.Fatalf("state path must depend on aggregator position")
+	}
+	if path == f("config.yml", "1:secret-url", 1) {
+		t.Fatalf("state path must depend on alias")
+	}
+	if path == f("another.yml", "global", 1) {
+		t.Fatalf("state path must depend on config path")
+	}
+}
+
+func TestUpdateHistogramBucket(t *testing.T) {
+	f := func(values []float64, counts uint64) {
+		t.Helper()
+
+		var hExpected metrics.Histogram
+		for i := uint64(0); i < counts; i++ {
+			for _, v := range values {
+				hExpected.Update(v)
+			}
+		}
+
+		var h metrics.Histogram
+		var err error
+		hExpected.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+			if err == nil {
+				err = updateHistogramBucket(&h, vmrange, count)
+			}
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		bucketsExpected := make(map[string]uint64)
+		hExpected.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+			bucketsExpected[vmrange] = count
+		})
+		buckets := make(map[string]uint64)
+		h.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+			buckets[vmrange] = count
+		})
+		if len(buckets) != len(bucketsExpected) {
+			t.Fatalf("unexpected buckets;\ngot\n%v\nwant\n%v", buckets, bucketsExpected)
+		}
+		for vmrange, count := range bucketsExpected {
+			if buckets[vmrange] != count {
+				t.Fatalf("unexpected count for vmrange=%q; got %d; want %d", vmrange, buckets[vmrange], count)
+			}
+		}
+	}
+
+	f([]float64{1}, 1)
+	f([]float64{0, 1e-12, 0.5, 1, 10, 123.456, 1e20}, 1)
+	f([]float64{0.001, 2, 3e5}, 1000)
+	f([]float64{42}, 12345)
+}
+
+func TestAggrStatePersisterMarshalUnmarshal(t *testing.T) {
+	f := func(output string) {
+		t.Helper()
+
+		newState := func() aggrStatePersister {
+			as, err := newAggrState(output, make(map[string]struct{}), time.Minute, 0)
+			if err != nil {
+				t.Fatalf("cannot create aggregation state: %s", err)
+			}
+			return as.(aggrStatePersister)
+		}
+
+		// Use a single series, so the marshaled state doesn't depend on map iteration order.
+		buf := compressLabels(nil, []prompbmarshal.Label{{Name: "instance", Value: "x"}}, []prompbmarshal.Label{{Name: "__name__", Value: "foo"}})
+		key := string(buf)
+		as := newState()
+		as.(aggrState).pushSamples([]pushSample{
+			{key: key, value: 1, timestamp: 1000},
+			{key: key, value: 5, timestamp: 2000},
+			{key: key, value: 3, timestamp: 3000},
+		})
+		data := as.marshalState(nil)
+
+		asNew := newState()
+		apply, err := asNew.unmarshalState(data)
+		if err != nil {
+			t.Fatalf("cannot unmarshal state: %s", err)
+		}
+		if dataNew := asNew.marshalState(nil); len(dataNew) > 0 {
+			t.Fatalf("the state must be applied only after calling the returned function; got\n%X", dataNew)
+		}
+		apply()
+		dataNew := asNew.marshalState(nil)
+		if string(data) != string(dataNew) {
+			t.Fatalf("unexpected state after unmarshaling;\ngot\n%X\nwant\n%X", dataNew, data)
+		}
+
+		// Truncated state must be rejected.
+		if _, err := newState().unmarshalState(data[:len(data)-1]); err == nil {
+			t.Fatalf("expecting non-nil error for truncated state")
+		}
+	}
+
+	f("total")
+	f("increase_prometheus")
+	f("rate_sum")
+	f("rate_avg")
+	f("histogram_bucket")
+}
@@ -78,3 +78,7 @@ func (as *stddevAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *stddevAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
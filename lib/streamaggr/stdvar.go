@@ -77,3 +77,7 @@ func (as *stdvarAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *stdvarAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
@@ -30,6 +31,10 @@ var supportedOutputs = []string{
 	"avg",
 	"count_samples",
 	"count_series",
+	"count_series_approx",
+	"count_unique_approx",
+	"ddsketch_bucket",
+	"ddsketch_bucket(relative_accuracy)",
 	"histogram_bucket",
 	"increase",
 	"increase_prometheus",
@@ -140,6 +145,15 @@ type Options struct {
 	//
 	// By default, aggregates samples are dropped, while the remaining samples are written to the corresponding -remoteWrite.url.
 	KeepInput bool
+
+	// StateDir is an optional path to directory for persisting aggregation state across restarts.
+	//
+	// The state for total, total_prometheus, increase, increase_prometheus, rate_avg, rate_sum and histogram_bucket outputs
+	// together with the deduplication state is saved to StateDir when the Aggregators are stopped
+	// and is restored when the Aggregators with compatible config are created.
+	//
+	// By default, the aggregation state isn't persisted.
+	StateDir string
 }
 
 // Config is a configuration for a single stream aggregation.
@@ -155,7 +169,21 @@ type Config struct {
 	Match *promrelabel.IfExpression `yaml:"match,omitempty"`
 
 	// Interval is the interval between aggregations.
-	Interval string `yaml:"interval"`
+	//
+	// See also Intervals.
+	Interval string `yaml:"interval,omitempty"`
+
+	// Intervals is an optional list of intervals between aggregations, which can be set instead of Interval.
+	//
+	// The outputs are calculated individually per each interval during a single pass over the input samples.
+	// All the intervals must be multiples of the smallest interval.
+	Intervals []string `yaml:"intervals,omitempty"`
+
+	// Window is an optional sliding window for calculating outputs.
+	//
+	// If set, then the outputs are calculated over the last Window and are emitted every Interval.
+	// Window can be used only with the following outputs: avg, count_samples, max, min, rate_avg, rate_sum and sum_samples.
+	Window string `yaml:"window,omitempty"`
 
 	// NoAlighFlushToInterval disables aligning of flushes to multiples of Interval.
 	// By default flushes are aligned to Interval.
@@ -186,6 +214,9 @@ type Config struct {
 	// - avg - the average value across all the samples
 	// - count_samples - counts the input samples
 	// - count_series - counts the number of unique input series
+	// - count_series_approx - estimates the number of unique input series with bounded memory usage
+	// - count_unique_approx - estimates the number of unique sample values with bounded memory usage
+	// - ddsketch_bucket(relative_accuracy) - creates mergeable DDSketch buckets for input samples
 	// - histogram_bucket - creates VictoriaMetrics histogram for input samples
 	// - increase - calculates the increase over input series
 	// - increase_prometheus - calculates the increase over input series, ignoring the first sample in new time series
@@ -369,6 +400,11 @@ func (a *Aggregators) Push(tss []prompbmarshal.TimeSeries, matchIdxs []byte) []b
 
 // aggregator aggregates input series according to the config passed to NewAggregator
 type aggregator struct {
+	// name, alias and aggrID identify the aggregator in metrics and at /stream-agg page.
+	name   string
+	alias  string
+	aggrID int
+
 	match *promrelabel.IfExpression
 
 	dropInputLabels []string
@@ -384,8 +420,24 @@ type aggregator struct {
 	aggregateOnlyByTime bool
 
 	// interval is the interval between flushes
+	//
+	// It equals to the smallest interval in intervals.
 	interval time.Duration
 
+	// intervals contains all the aggregation intervals
+	intervals []time.Duration
+
+	// window is an optional sliding window for outputs
+	window time.Duration
+
+	// alignFlushToInterval is set to true if flushes are aligned to interval
+	alignFlushToInterval bool
+
+	// flushes is the number of flushes performed by the aggregator.
+	//
+	// It is used for determining outputs to flush when alignFlushToInterval is false.
+	flushes uint64
+
 	// dedupInterval is optional deduplication interval for incoming samples
 	dedupInterval time.Duration
 
@@ -398,7 +450,15 @@ type aggregator struct {
 	// minTimestamp is used for ignoring old samples when ignoreOldSamples is set
 	minTimestamp atomic.Int64
 
-	// suffix contains a suffix, which should be added to aggregate metric names
+	// statePath is the path to file for persisting the aggregator state across restarts.
+	//
+	// The state isn't persisted if statePath is empty. See Options.StateDir.
+	statePath string
+
+	// stateFingerprint is used for detecting the state persisted with incompatible settings.
+	stateFingerprint uint64
+
+	// suffix contains a suffix, which should be added to aggregate metric names for the smallest interval
 	//
 	// It contains the interval, labels in (by, without), plus output name.
 	// For example, foo_bar metric name is transformed to foo_bar:1m_by_job
@@ -412,16 +472,29 @@ type aggregator struct {
 	dedupFlushDuration *metrics.Histogram
 	samplesLag         *metrics.Histogram
 
-	flushTimeouts      *metrics.Counter
-	dedupFlushTimeouts *metrics.Counter
-	ignoredOldSamples  *metrics.Counter
-	ignoredNaNSamples  *metrics.Counter
-	matchedSamples     *metrics.Counter
+	flushTimeouts       *metrics.Counter
+	dedupFlushTimeouts  *metrics.Counter
+	dedupDroppedSamples *metrics.Counter
+	ignoredOldSamples   *metrics.Counter
+	ignoredNaNSamples   *metrics.Counter
+	matchedSamples      *metrics.Counter
 }
 
 type aggrOutput struct {
 	as aggrState
 
+	// name is the unique name of the output among the aggregator outputs.
+	//
+	// It equals to the output name from the config for the smallest interval.
+	// Otherwise it contains the interval, e.g. `1h:sum_samples`.
+	name string
+
+	// suffix is the metric name suffix for the output series, e.g. `:1m_by_job_`
+	suffix string
+
+	// flushEvery is the number of the smallest intervals between flushes for the output.
+	flushEvery uint64
+
 	outputSamples *metrics.Counter
 }
 
@@ -433,6 +506,9 @@ type aggrState interface {
 
 	// flushState must flush aggrState data to ctx.
 	flushState(ctx *flushCtx)
+
+	// itemsCount must return the number of output keys currently tracked by aggrState.
+	itemsCount() int
 }
 
 // PushFunc is called by Aggregators when it needs to push its state to metrics storage
@@ -444,16 +520,58 @@ type PushFunc func(tss []prompbmarshal.TimeSeries)
 //
 // The returned aggregator must be stopped when no longer needed by calling MustStop().
 func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set, opts *Options, alias string, aggrID int) (*aggregator, error) {
-	// check cfg.Interval
-	if cfg.Interval == "" {
+	// check cfg.Interval and cfg.Intervals
+	intervalStrs := cfg.Intervals
+	if cfg.Interval != "" {
+		if len(cfg.Intervals) > 0 {
+			return nil, fmt.Errorf("`interval` and `intervals` options cannot be set simultaneously")
+		}
+		intervalStrs = []string{cfg.Interval}
+	}
+	if len(intervalStrs) == 0 {
 		return nil, fmt.Errorf("missing `interval` option")
 	}
-	interval, err := time.ParseDuration(cfg.Interval)
-	if err != nil {
-		return nil, fmt.Errorf("cannot parse `interval: %q`: %w", cfg.Interval, err)
+	intervals := make([]time.Duration, len(intervalStrs))
+	for i, s := range intervalStrs {
+		interval, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `interval: %q`: %w", s, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("aggregation interval cannot be smaller than 1s; got %s", interval)
+		}
+		if slices.Contains(intervals[:i], interval) {
+			return nil, fmt.Errorf("`intervals` list contains duplicate interval %s", interval)
+		}
+		intervals[i] = interval
+	}
+	interval := slices.Min(intervals)
+	maxInterval := slices.Max(intervals)
+	for _, d := range intervals {
+		if d%interval != 0 {
+			return nil, fmt.Errorf("interval=%s must be a multiple of the smallest interval=%s", d, interval)
+		}
 	}
-	if interval < time.Second {
-		return nil, fmt.Errorf("aggregation interval cannot be smaller than 1s; got %s", interval)
+
+	// check cfg.Window
+	var window time.Duration
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `window: %q`: %w", cfg.Window, err)
+		}
+		if d < maxInterval {
+			return nil, fmt.Errorf("window=%s cannot be smaller than interval=%s", d, maxInterval)
+		}
+		if d%interval != 0 {
+			return nil, fmt.Errorf("window=%s must be a multiple of interval=%s", d, interval)
+		}
+		for _, output := range cfg.Outputs {
+			if !isWindowOutput(output) {
+				return nil, fmt.Errorf("`window` cannot be used with `outputs: [%s]`; supported outputs: %s", output, windowOutputs())
+			}
+		}
+		window = d
 	}
 
 	if opts == nil {
@@ -477,25 +595,27 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 	}
 
 	// check cfg.StalenessInterval
-	stalenessInterval := interval * 2
+	stalenessInterval := maxInterval * 2
 	if cfg.StalenessInterval != "" {
-		stalenessInterval, err = time.ParseDuration(cfg.StalenessInterval)
+		si, err := time.ParseDuration(cfg.StalenessInterval)
 		if err != nil {
 			return nil, fmt.Errorf("cannot parse `staleness_interval: %q`: %w", cfg.StalenessInterval, err)
 		}
-		if stalenessInterval < interval {
-			return nil, fmt.Errorf("staleness_interval=%s cannot be smaller than interval=%s", cfg.StalenessInterval, cfg.Interval)
+		if si < maxInterval {
+			return nil, fmt.Errorf("staleness_interval=%s cannot be smaller than interval=%s", cfg.StalenessInterval, maxInterval)
 		}
+		stalenessInterval = si
 	}
 
 	// check cfg.IgnoreFirstSampleInterval
 	// by default, it equals to the staleness interval to have backward compatibility, see https://github.com/VictoriaMetrics/VictoriaMetrics/issues/7116
 	ignoreFirstSampleInterval := stalenessInterval
 	if cfg.IgnoreFirstSampleInterval != "" {
-		ignoreFirstSampleInterval, err = time.ParseDuration(cfg.IgnoreFirstSampleInterval)
+		d, err := time.ParseDuration(cfg.IgnoreFirstSampleInterval)
 		if err != nil {
 			return nil, fmt.Errorf("cannot parse `ignore_first_sample_interval: %q`: %w", cfg.IgnoreFirstSampleInterval, err)
 		}
+		ignoreFirstSampleInterval = d
 	}
 
 	// Check cfg.DropInputLabels
@@ -539,7 +659,12 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 			return nil, fmt.Errorf("`outputs` list must contain only a single entry if `keep_metric_names` is set; got %q; "+
 				"see https://docs.victoriametrics.com/stream-aggregation/#output-metric-names", cfg.Outputs)
 		}
-		if cfg.Outputs[0] == "histogram_bucket" || strings.HasPrefix(cfg.Outputs[0], "quantiles(") && strings.Contains(cfg.Outputs[0], ",") {
+		if len(intervals) > 1 {
+			return nil, fmt.Errorf("`intervals` list must contain only a single entry if `keep_metric_names` is set; got %q; "+
+				"see https://docs.victoriametrics.com/stream-aggregation/#output-metric-names", intervalStrs)
+		}
+		if cfg.Outputs[0] == "histogram_bucket" || strings.HasPrefix(cfg.Outputs[0], "ddsketch_bucket") ||
+			strings.HasPrefix(cfg.Outputs[0], "quantiles(") && strings.Contains(cfg.Outputs[0], ",") {
 			return nil, fmt.Errorf("`keep_metric_names` cannot be applied to `outputs: %q`, since they can generate multiple time series; "+
 				"see https://docs.victoriametrics.com/stream-aggregation/#output-metric-names", cfg.Outputs)
 		}
@@ -564,37 +689,85 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 	}
 	metricLabels := fmt.Sprintf(`name=%q,path=%q,url=%q,position="%d"`, name, path, alias, aggrID)
 
+	// initialize suffixes to add to metric names after aggregation
+	getSuffix := func(intervalStr string) string {
+		suffix := ":" + intervalStr
+		if window > 0 {
+			suffix = ":" + cfg.Window + "_every_" + intervalStr
+		}
+		if labels := removeUnderscoreName(by); len(labels) > 0 {
+			suffix += fmt.Sprintf("_by_%s", strings.Join(labels, "_"))
+		}
+		if labels := removeUnderscoreName(without); len(labels) > 0 {
+			suffix += fmt.Sprintf("_without_%s", strings.Join(labels, "_"))
+		}
+		return suffix + "_"
+	}
+	suffix := getSuffix(intervalStrs[slices.Index(intervals, interval)])
+
 	// initialize aggrOutputs
 	if len(cfg.Outputs) == 0 {
 		return nil, fmt.Errorf("`outputs` list must contain at least a single entry from the list %s; "+
 			"see https://docs.victoriametrics.com/stream-aggregation/", supportedOutputs)
 	}
-	aggrOutputs := make([]aggrOutput, len(cfg.Outputs))
+	var aggrOutputs []aggrOutput
+	newAggrOutput := func(as aggrState, name, suffix string, flushEvery uint64) aggrOutput {
+		return aggrOutput{
+			as:         as,
+			name:       name,
+			suffix:     suffix,
+			flushEvery: flushEvery,
+
+			outputSamples: ms.NewCounter(fmt.Sprintf(`vm_streamaggr_output_samples_total{output=%q,%s}`, name, metricLabels)),
+		}
+	}
 	outputsSeen := make(map[string]struct{}, len(cfg.Outputs))
-	for i, output := range cfg.Outputs {
+	for _, output := range cfg.Outputs {
 		as, err := newAggrState(output, outputsSeen, stalenessInterval, ignoreFirstSampleInterval)
 		if err != nil {
 			return nil, err
 		}
-		aggrOutputs[i] = aggrOutput{
-			as: as,
-
-			outputSamples: ms.NewCounter(fmt.Sprintf(`vm_streamaggr_output_samples_total{output=%q,%s}`, output, metricLabels)),
+		if len(intervals) == 1 && window == 0 {
+			aggrOutputs = append(aggrOutputs, newAggrOutput(as, output, suffix, 1))
+			continue
+		}
+		if isWindowOutput(output) {
+			// Calculate the output for all the intervals and windows from the values for the smallest interval.
+			specs := make([]windowSpec, len(intervals))
+			for i, d := range intervals {
+				specs[i] = windowSpec{
+					suffix: getSuffix(intervalStrs[i]),
+					every:  uint64(d / interval),
+					panes:  int(window / interval),
+				}
+			}
+			as = newWindowAggrState(as, output, specs)
+			aggrOutputs = append(aggrOutputs, newAggrOutput(as, output, suffix, 1))
+			continue
+		}
+		// The output cannot be obtained from the values for the smallest interval,
+		// so it is calculated individually per each interval.
+		for i, d := range intervals {
+			if i > 0 {
+				as, err = newAggrState(output, make(map[string]struct{}), stalenessInterval, ignoreFirstSampleInterval)
+				if err != nil {
+					logger.Panicf("BUG: cannot create state for the already verified output %q: %s", output, err)
+				}
+			}
+			name := output
+			if d != interval {
+				name = intervalStrs[i] + ":" + output
+			}
+			aggrOutputs = append(aggrOutputs, newAggrOutput(as, name, getSuffix(intervalStrs[i]), uint64(d/interval)))
 		}
 	}
 
-	// initialize suffix to add to metric names after aggregation
-	suffix := ":" + cfg.Interval
-	if labels := removeUnderscoreName(by); len(labels) > 0 {
-		suffix += fmt.Sprintf("_by_%s", strings.Join(labels, "_"))
-	}
-	if labels := removeUnderscoreName(without); len(labels) > 0 {
-		suffix += fmt.Sprintf("_without_%s", strings.Join(labels, "_"))
-	}
-	suffix += "_"
-
 	// initialize the aggregator
 	a := &aggregator{
+		name:   name,
+		alias:  alias,
+		aggrID: aggrID,
+
 		match: cfg.Match,
 
 		dropInputLabels:  dropInputLabels,
@@ -609,6 +782,8 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 		aggregateOnlyByTime: aggregateOnlyByTime,
 
 		interval:      interval,
+		intervals:     intervals,
+		window:        window,
 		dedupInterval: dedupInterval,
 
 		aggrOutputs: aggrOutputs,
@@ -621,11 +796,12 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 		dedupFlushDuration: ms.NewHistogram(fmt.Sprintf(`vm_streamaggr_dedup_flush_duration_seconds{%s}`, metricLabels)),
 		samplesLag:         ms.NewHistogram(fmt.Sprintf(`vm_streamaggr_samples_lag_seconds{%s}`, metricLabels)),
 
-		matchedSamples:     ms.NewCounter(fmt.Sprintf(`vm_streamaggr_matched_samples_total{%s}`, metricLabels)),
-		flushTimeouts:      ms.NewCounter(fmt.Sprintf(`vm_streamaggr_flush_timeouts_total{%s}`, metricLabels)),
-		dedupFlushTimeouts: ms.NewCounter(fmt.Sprintf(`vm_streamaggr_dedup_flush_timeouts_total{%s}`, metricLabels)),
-		ignoredNaNSamples:  ms.NewCounter(fmt.Sprintf(`vm_streamaggr_ignored_samples_total{reason="nan",%s}`, metricLabels)),
-		ignoredOldSamples:  ms.NewCounter(fmt.Sprintf(`vm_streamaggr_ignored_samples_total{reason="too_old",%s}`, metricLabels)),
+		matchedSamples:      ms.NewCounter(fmt.Sprintf(`vm_streamaggr_matched_samples_total{%s}`, metricLabels)),
+		flushTimeouts:       ms.NewCounter(fmt.Sprintf(`vm_streamaggr_flush_timeouts_total{%s}`, metricLabels)),
+		dedupFlushTimeouts:  ms.NewCounter(fmt.Sprintf(`vm_streamaggr_dedup_flush_timeouts_total{%s}`, metricLabels)),
+		dedupDroppedSamples: ms.NewCounter(fmt.Sprintf(`vm_streamaggr_ignored_samples_total{reason="dedup",%s}`, metricLabels)),
+		ignoredNaNSamples:   ms.NewCounter(fmt.Sprintf(`vm_streamaggr_ignored_samples_total{reason="nan",%s}`, metricLabels)),
+		ignoredOldSamples:   ms.NewCounter(fmt.Sprintf(`vm_streamaggr_ignored_samples_total{reason="too_old",%s}`, metricLabels)),
 	}
 
 	if dedupInterval > 0 {
@@ -641,10 +817,18 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 		})
 	}
 
+	if opts.StateDir != "" {
+		fs.MustMkdirIfNotExist(opts.StateDir)
+		a.statePath = getStatePath(opts.StateDir, path, alias, aggrID)
+		a.stateFingerprint = getStateFingerprint(cfg, interval, dedupInterval, stalenessInterval, by, without, dropInputLabels)
+		a.loadState()
+	}
+
 	alignFlushToInterval := !opts.NoAlignFlushToInterval
 	if v := cfg.NoAlignFlushToInterval; v != nil {
 		alignFlushToInterval = !*v
 	}
+	a.alignFlushToInterval = alignFlushToInterval
 
 	skipIncompleteFlush := !opts.FlushOnShutdown
 	if v := cfg.FlushOnShutdown; v != nil {
@@ -695,6 +879,29 @@ func newAggrState(output string, outputsSeen map[string]struct{}, stalenessInter
 		return newQuantilesAggrState(phis), nil
 	}
 
+	if output == "ddsketch_bucket" || strings.HasPrefix(output, "ddsketch_bucket(") {
+		relativeAccuracy := defaultDDSketchRelativeAccuracy
+		if output != "ddsketch_bucket" {
+			if !strings.HasSuffix(output, ")") {
+				return nil, fmt.Errorf("missing closing brace for `ddsketch_bucket()` output")
+			}
+			arg := strings.TrimSpace(output[len("ddsketch_bucket(") : len(output)-1])
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse relative_accuracy=%q for ddsketch_bucket(%s): %w", arg, arg, err)
+			}
+			if v < 0.001 || v > 0.5 {
+				return nil, fmt.Errorf("relative_accuracy inside ddsketch_bucket(%s) must be in the range [0.001..0.5]; got %v", arg, v)
+			}
+			relativeAccuracy = v
+		}
+		if _, ok := outputsSeen["ddsketch_bucket()"]; ok {
+			return nil, fmt.Errorf("`outputs` list contains duplicated `ddsketch_bucket` function")
+		}
+		outputsSeen["ddsketch_bucket()"] = struct{}{}
+		return newDDSketchBucketAggrState(relativeAccuracy), nil
+	}
+
 	switch output {
 	case "avg":
 		return newAvgAggrState(), nil
@@ -702,6 +909,10 @@ func newAggrState(output string, outputsSeen map[string]struct{}, stalenessInter
 		return newCountSamplesAggrState(), nil
 	case "count_series":
 		return newCountSeriesAggrState(), nil
+	case "count_series_approx":
+		return newCountApproxAggrState(true), nil
+	case "count_unique_approx":
+		return newCountApproxAggrState(false), nil
 	case "histogram_bucket":
 		return newHistogramBucketAggrState(stalenessInterval), nil
 	case "increase":
@@ -769,16 +980,16 @@ func (a *aggregator) runFlusher(pushFunc PushFunc, alignFlushToInterval, skipInc
 		defer t.Stop()
 
 		if alignFlushToInterval && skipIncompleteFlush {
-			a.flush(nil, 0)
+			a.flush(nil, 0, false)
 			ignoreFirstIntervals--
 		}
 
 		for tickerWait(t) {
 			if ignoreFirstIntervals > 0 {
-				a.flush(nil, 0)
+				a.flush(nil, 0, false)
 				ignoreFirstIntervals--
 			} else {
-				a.flush(pushFunc, flushTimeMsec)
+				a.flush(pushFunc, flushTimeMsec, false)
 			}
 
 			if alignFlushToInterval {
@@ -802,14 +1013,14 @@ func (a *aggregator) runFlusher(pushFunc PushFunc, alignFlushToInterval, skipInc
 			if ct.After(flushDeadline) {
 				// It is time to flush the aggregated state
 				if alignFlushToInterval && skipIncompleteFlush && !isSkippedFirstFlush {
-					a.flush(nil, 0)
+					a.flush(nil, 0, false)
 					ignoreFirstIntervals--
 					isSkippedFirstFlush = true
 				} else if ignoreFirstIntervals > 0 {
-					a.flush(nil, 0)
+					a.flush(nil, 0, false)
 					ignoreFirstIntervals--
 				} else {
-					a.flush(pushFunc, flushTimeMsec)
+					a.flush(pushFunc, flushTimeMsec, false)
 				}
 				for ct.After(flushDeadline) {
 					flushDeadline = flushDeadline.Add(a.interval)
@@ -827,7 +1038,7 @@ func (a *aggregator) runFlusher(pushFunc PushFunc, alignFlushToInterval, skipInc
 
 	if !skipIncompleteFlush && ignoreFirstIntervals <= 0 {
 		a.dedupFlush()
-		a.flush(pushFunc, flushTimeMsec)
+		a.flush(pushFunc, flushTimeMsec, true)
 	}
 }
 
@@ -854,9 +1065,23 @@ func (a *aggregator) dedupFlush() {
 // flush flushes aggregator state to pushFunc.
 //
 // If pushFunc is nil, then the aggregator state is just reset.
-func (a *aggregator) flush(pushFunc PushFunc, flushTimeMsec int64) {
+//
+// Outputs for intervals bigger than a.interval are flushed only when the corresponding interval ends
+// unless isLast is set.
+func (a *aggregator) flush(pushFunc PushFunc, flushTimeMsec int64, isLast bool) {
 	startTime := time.Now()
 
+	// Determine the index of the current flush, so outputs for bigger intervals are flushed at their multiples.
+	var flushIdx uint64
+	if a.alignFlushToInterval {
+		intervalMsec := a.interval.Milliseconds()
+		flushIdx = uint64((flushTimeMsec + intervalMsec/2) / intervalMsec)
+	} else {
+		a.flushes++
+		flushIdx = a.flushes
+	}
+	flushAll := isLast || pushFunc == nil
+
 	// Update minTimestamp before flushing samples to the storage,
 	// since the flush durtion can be quite long.
 	// This should prevent from dropping samples with old timestamps when the flush takes long time.
@@ -865,6 +1090,9 @@ func (a *aggregator) flush(pushFunc PushFunc, flushTimeMsec int64) {
 	var wg sync.WaitGroup
 	for i := range a.aggrOutputs {
 		ao := &a.aggrOutputs[i]
+		if !flushAll && flushIdx%ao.flushEvery != 0 {
+			continue
+		}
 		flushConcurrencyCh <- struct{}{}
 		wg.Add(1)
 		go func(ao *aggrOutput) {
@@ -874,6 +1102,8 @@ func (a *aggregator) flush(pushFunc PushFunc, flushTimeMsec int64) {
 			}()
 
 			ctx := getFlushCtx(a, ao, pushFunc, flushTimeMsec)
+			ctx.flushIdx = flushIdx
+			ctx.flushAll = flushAll
 			ao.as.flushState(ctx)
 			ctx.flushSeries()
 			putFlushCtx(ctx)
@@ -896,9 +1126,14 @@ var flushConcurrencyCh = make(chan struct{}, cgroup.AvailableCPUs())
 // MustStop stops the aggregator.
 //
 // The aggregator stops pushing the aggregated metrics after this call.
+// The aggregator state is saved to a.statePath if it is set.
 func (a *aggregator) MustStop() {
 	close(a.stopCh)
 	a.wg.Wait()
+
+	if a.statePath != "" {
+		a.mustSaveState()
+	}
 }
 
 // Push pushes tss to a.
@@ -979,7 +1214,8 @@ func (a *aggregator) Push(tss []prompbmarshal.TimeSeries, matchIdxs []byte) {
 	ctx.buf = buf
 
 	if a.da != nil {
-		a.da.pushSamples(samples)
+		n := a.da.pushSamples(samples)
+		a.dedupDroppedSamples.Add(n)
 	} else {
 		a.pushSamples(samples)
 	}
@@ -1100,6 +1336,7 @@ func getFlushCtx(a *aggregator, ao *aggrOutput, pushFunc PushFunc, flushTimestam
 	ctx.ao = ao
 	ctx.pushFunc = pushFunc
 	ctx.flushTimestamp = flushTimestamp
+	ctx.suffix = ao.suffix
 	return ctx
 }
 
@@ -1116,6 +1353,18 @@ type flushCtx struct {
 	pushFunc       PushFunc
 	flushTimestamp int64
 
+	// suffix is the metric name suffix for the output series.
+	suffix string
+
+	// flushIdx is the index of the current flush. See aggregator.flush.
+	flushIdx uint64
+
+	// flushAll is set to true if outputs for all the intervals must be flushed.
+	flushAll bool
+
+	// paneFunc is set by windowAggrState for obtaining values for the smallest interval instead of output series.
+	paneFunc func(key string, value, weight float64)
+
 	tss     []prompbmarshal.TimeSeries
 	labels  []prompbmarshal.Label
 	samples []prompbmarshal.Sample
@@ -1126,6 +1375,10 @@ func (ctx *flushCtx) reset() {
 	ctx.ao = nil
 	ctx.pushFunc = nil
 	ctx.flushTimestamp = 0
+	ctx.suffix = ""
+	ctx.flushIdx = 0
+	ctx.flushAll = false
+	ctx.paneFunc = nil
 	ctx.resetSeries()
 }
 
@@ -1182,11 +1435,23 @@ func (ctx *flushCtx) flushSeries() {
 }
 
 func (ctx *flushCtx) appendSeries(key, suffix string, value float64) {
+	ctx.appendWeightedSeries(key, suffix, value, 1)
+}
+
+// appendWeightedSeries appends series for the given key with the given value.
+//
+// The weight is used when merging values for multiple intervals. See windowAggrState.
+func (ctx *flushCtx) appendWeightedSeries(key, suffix string, value, weight float64) {
+	if ctx.paneFunc != nil {
+		ctx.paneFunc(key, value, weight)
+		return
+	}
+
 	labelsLen := len(ctx.labels)
 	samplesLen := len(ctx.samples)
 	ctx.labels = decompressLabels(ctx.labels, key)
 	if !ctx.a.keepMetricNames {
-		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.a.suffix, suffix)
+		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.suffix, suffix)
 	}
 	ctx.samples = append(ctx.samples, prompbmarshal.Sample{
 		Timestamp: ctx.flushTimestamp,
@@ -1208,7 +1473,7 @@ func (ctx *flushCtx) appendSeriesWithExtraLabel(key, suffix string, value float6
 	samplesLen := len(ctx.samples)
 	ctx.labels = decompressLabels(ctx.labels, key)
 	if !ctx.a.keepMetricNames {
-		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.a.suffix, suffix)
+		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.suffix, suffix)
 	}
 	ctx.labels = append(ctx.labels, prompbmarshal.Label{
 		Name:  extraName,
@@ -1294,3 +1559,12 @@ func sortAndRemoveDuplicates(a []string) []string {
 }
 
 var bbPool bytesutil.ByteBufferPool
+
+func syncMapLen(m *sync.Map) int {
+	n := 0
+	m.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
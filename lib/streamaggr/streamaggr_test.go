@@ -193,6 +193,110 @@ func TestAggregatorsFailure(t *testing.T) {
 - interval: 1m
   outputs: ["quantiles(0.5)", "quantiles(0.9)"]
 `)
+
+	// Invalid ddsketch_bucket()
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket("]
+`)
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket()"]
+`)
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket(foo)"]
+`)
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket(0)"]
+`)
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket(0.9)"]
+`)
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket", "ddsketch_bucket(0.05)"]
+`)
+
+	// keep_metric_names is set for ddsketch_bucket
+	f(`
+- interval: 1m
+  keep_metric_names: true
+  outputs: ["ddsketch_bucket"]
+`)
+
+	// interval and intervals are set simultaneously
+	f(`
+- interval: 1m
+  intervals: [1m, 5m]
+  outputs: [sum_samples]
+`)
+
+	// invalid intervals
+	f(`
+- intervals: [1m, foo]
+  outputs: [sum_samples]
+`)
+	f(`
+- intervals: [1m, 500ms]
+  outputs: [sum_samples]
+`)
+
+	// duplicate intervals
+	f(`
+- intervals: [1m, 60s]
+  outputs: [sum_samples]
+`)
+
+	// intervals aren't multiples of the smallest interval
+	f(`
+- intervals: [1m, 90s]
+  outputs: [sum_samples]
+`)
+
+	// keep_metric_names is set for multiple intervals
+	f(`
+- intervals: [1m, 5m]
+  keep_metric_names: true
+  outputs: [sum_samples]
+`)
+
+	// staleness_interval is smaller than the biggest interval
+	f(`
+- intervals: [1m, 5m]
+  staleness_interval: 2m
+  outputs: [total]
+`)
+
+	// invalid window
+	f(`
+- interval: 1m
+  window: foo
+  outputs: [sum_samples]
+`)
+
+	// window is smaller than interval
+	f(`
+- interval: 1m
+  window: 30s
+  outputs: [sum_samples]
+`)
+
+	// window isn't a multiple of interval
+	f(`
+- interval: 1m
+  window: 90s
+  outputs: [sum_samples]
+`)
+
+	// window is set for unsupported output
+	f(`
+- interval: 30s
+  window: 5m
+  outputs: [sum_samples, count_series]
+`)
 }
 
 func TestAggregatorsEqual(t *testing.T) {
@@ -828,6 +932,98 @@ cpu_usage:1m_without_cpu_histogram_bucket{vmrange="2.448e+01...2.783e+01"} 1
 cpu_usage:1m_without_cpu_histogram_bucket{vmrange="8.799e+01...1.000e+02"} 1
 `, "1111111")
 
+	// ddsketch_bucket output
+	f(`
+- interval: 1m
+  outputs: ["ddsketch_bucket(0.1)"]
+`, `
+cpu_usage{cpu="1"} 12.5
+cpu_usage{cpu="1"} 13.3
+cpu_usage{cpu="1"} 13
+cpu_usage{cpu="1"} 12
+cpu_usage{cpu="1"} 14
+cpu_usage{cpu="1"} 25
+cpu_usage{cpu="2"} 90
+cpu_usage{cpu="2"} 0
+cpu_usage{cpu="2"} -5
+`, `cpu_usage:1m_ddsketch_bucket{cpu="1",vmrange="1.111e+01...1.358e+01"} 4
+cpu_usage:1m_ddsketch_bucket{cpu="1",vmrange="1.358e+01...1.660e+01"} 1
+cpu_usage:1m_ddsketch_bucket{cpu="1",vmrange="2.480e+01...3.031e+01"} 1
+cpu_usage:1m_ddsketch_bucket{cpu="2",vmrange="-6.086e+00...-4.980e+00"} 1
+cpu_usage:1m_ddsketch_bucket{cpu="2",vmrange="0...1.000e-09"} 1
+cpu_usage:1m_ddsketch_bucket{cpu="2",vmrange="8.266e+01...1.010e+02"} 1
+`, "111111111")
+
+	// multiple intervals
+	f(`
+- intervals: [1m, 5m]
+  by: [job]
+  outputs: [sum_samples, avg, count_series]
+`, `
+foo{job="a",instance="x"} 1
+foo{job="a",instance="y"} 2
+foo{job="b",instance="x"} 3
+`, `foo:1m_by_job_avg{job="a"} 1.5
+foo:1m_by_job_avg{job="b"} 3
+foo:1m_by_job_count_series{job="a"} 2
+foo:1m_by_job_count_series{job="b"} 1
+foo:1m_by_job_sum_samples{job="a"} 3
+foo:1m_by_job_sum_samples{job="b"} 3
+foo:5m_by_job_avg{job="a"} 1.5
+foo:5m_by_job_avg{job="b"} 3
+foo:5m_by_job_count_series{job="a"} 2
+foo:5m_by_job_count_series{job="b"} 1
+foo:5m_by_job_sum_samples{job="a"} 3
+foo:5m_by_job_sum_samples{job="b"} 3
+`, "111")
+
+	// sliding window
+	f(`
+- interval: 30s
+  window: 5m
+  outputs: [max, count_samples]
+`, `
+foo 1
+foo 5
+bar 2
+`, `bar:5m_every_30s_count_samples 1
+bar:5m_every_30s_max 2
+foo:5m_every_30s_count_samples 2
+foo:5m_every_30s_max 5
+`, "111")
+
+	// ddsketch_bucket output without cpu and with default relative accuracy
+	f(`
+- interval: 1m
+  without: [cpu]
+  outputs: [ddsketch_bucket]
+`, `
+cpu_usage{cpu="1"} 12.5
+cpu_usage{cpu="1"} 13.3
+cpu_usage{cpu="2"} 12.6
+cpu_usage{cpu="2"} 90
+`, `cpu_usage:1m_without_cpu_ddsketch_bucket{vmrange="1.243e+01...1.268e+01"} 2
+cpu_usage:1m_without_cpu_ddsketch_bucket{vmrange="1.320e+01...1.346e+01"} 1
+cpu_usage:1m_without_cpu_ddsketch_bucket{vmrange="8.825e+01...9.003e+01"} 1
+`, "1111")
+
+	// count_series_approx and count_unique_approx outputs
+	f(`
+- interval: 1m
+  by: [cpu]
+  outputs: [count_series_approx, count_unique_approx]
+`, `
+cpu_usage{cpu="1",instance="a"} 12.5
+cpu_usage{cpu="1",instance="b"} 12.5
+cpu_usage{cpu="1",instance="c"} 13
+cpu_usage{cpu="1",instance="c"} 13
+cpu_usage{cpu="2",instance="a"} 90
+`, `cpu_usage:1m_by_cpu_count_series_approx{cpu="1"} 3
+cpu_usage:1m_by_cpu_count_series_approx{cpu="2"} 1
+cpu_usage:1m_by_cpu_count_unique_approx{cpu="1"} 2
+cpu_usage:1m_by_cpu_count_unique_approx{cpu="2"} 1
+`, "11111")
+
 	// quantiles output
 	f(`
 - interval: 1m
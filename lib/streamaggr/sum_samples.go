@@ -75,3 +75,7 @@ func (as *sumSamplesAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *sumSamplesAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
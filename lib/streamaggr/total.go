@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 )
 
@@ -184,3 +185,71 @@ func (as *totalAggrState) removeOldEntries(currentTime uint64) {
 		return true
 	})
 }
+
+func (as *totalAggrState) marshalState(dst []byte) []byte {
+	as.m.Range(func(k, v any) bool {
+		sv := v.(*totalStateValue)
+
+		sv.mu.Lock()
+		if !sv.deleted {
+			dst = marshalStateKey(dst, k.(string))
+			dst = marshalStateFloat64(dst, sv.total)
+			dst = encoding.MarshalVarUint64(dst, sv.deleteDeadline)
+			dst = encoding.MarshalVarUint64(dst, uint64(len(sv.lastValues)))
+			for inputKey, lv := range sv.lastValues {
+				dst = marshalStateKey(dst, inputKey)
+				dst = marshalStateFloat64(dst, lv.value)
+				dst = encoding.MarshalVarInt64(dst, lv.timestamp)
+				dst = encoding.MarshalVarUint64(dst, lv.deleteDeadline)
+			}
+		}
+		sv.mu.Unlock()
+		return true
+	})
+	return dst
+}
+
+func (as *totalAggrState) unmarshalState(src []byte) (func(), error) {
+	type entry struct {
+		key string
+		sv  *totalStateValue
+	}
+	var entries []entry
+	sr := &stateReader{
+		src: src,
+	}
+	for len(sr.src) > 0 && sr.err == nil {
+		key := sr.readKey()
+		sv := &totalStateValue{
+			total:          sr.readFloat64(),
+			deleteDeadline: sr.readUint64(),
+			lastValues:     make(map[string]totalLastValueState),
+		}
+		lastValuesLen := sr.readUint64()
+		for i := uint64(0); i < lastValuesLen && sr.err == nil; i++ {
+			inputKey := sr.readKey()
+			sv.lastValues[inputKey] = totalLastValueState{
+				value:          sr.readFloat64(),
+				timestamp:      sr.readInt64(),
+				deleteDeadline: sr.readUint64(),
+			}
+		}
+		entries = append(entries, entry{
+			key: key,
+			sv:  sv,
+		})
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	apply := func() {
+		for _, e := range entries {
+			as.m.Store(e.key, e.sv)
+		}
+	}
+	return apply, nil
+}
+
+func (as *totalAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
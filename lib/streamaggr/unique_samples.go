@@ -79,3 +79,7 @@ func (as *uniqueSamplesAggrState) flushState(ctx *flushCtx) {
 		return true
 	})
 }
+
+func (as *uniqueSamplesAggrState) itemsCount() int {
+	return syncMapLen(&as.m)
+}
@@ -0,0 +1,374 @@
+package streamaggr
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// windowMergeFuncs contains merge functions for outputs, which can be calculated over multiple intervals
+// and over sliding windows by merging per-interval values.
+//
+// See windowAggrState.
+var windowMergeFuncs = map[string]windowMergeFunc{
+	"avg":           mergeWindowAvg,
+	"count_samples": mergeWindowSum,
+	"max":           mergeWindowMax,
+	"min":           mergeWindowMin,
+	"rate_avg":      mergeWindowAvg,
+	"rate_sum":      mergeWindowAvg,
+	"sum_samples":   mergeWindowSum,
+}
+
+// windowMergeFunc merges src into dst.
+type windowMergeFunc func(dst *windowPane, src windowPane)
+
+// windowPane contains the output value for a single output key over the smallest aggregation interval.
+type windowPane struct {
+	value float64
+
+	// weight is the weight of the value when merging it with other values.
+	//
+	// Zero weight means the pane has no value.
+	weight float64
+}
+
+func (p *windowPane) isEmpty() bool {
+	return p.weight == 0
+}
+
+func mergeWindowSum(dst *windowPane, src windowPane) {
+	dst.value += src.value
+	dst.weight += src.weight
+}
+
+func mergeWindowMax(dst *windowPane, src windowPane) {
+	if dst.isEmpty() || src.value > dst.value {
+		dst.value = src.value
+	}
+	dst.weight += src.weight
+}
+
+func mergeWindowMin(dst *windowPane, src windowPane) {
+	if dst.isEmpty() || src.value < dst.value {
+		dst.value = src.value
+	}
+	dst.weight += src.weight
+}
+
+// mergeWindowAvg calculates weighted average of the merged values.
+func mergeWindowAvg(dst *windowPane, src windowPane) {
+	weight := dst.weight + src.weight
+	dst.value = (dst.value*dst.weight + src.value*src.weight) / weight
+	dst.weight = weight
+}
+
+// windowSpec describes output series calculated by windowAggrState.
+type windowSpec struct {
+	// suffix is the metric name suffix for the output series, e.g. ":1h_by_job_"
+	suffix string
+
+	// every is the number of the smallest aggregation intervals between emitting the output series.
+	every uint64
+
+	// panes is the number of the smallest aggregation intervals in the sliding window.
+	//
+	// It is set to zero for tumbling windows.
+	panes int
+}
+
+// windowAggrState calculates output over multiple aggregation intervals and sliding windows
+// by merging the values calculated by inner aggrState over the smallest aggregation interval.
+//
+// Values for coarser tumbling windows are accumulated from the values for the smallest interval,
+// while sliding windows keep the values for the last panes intervals.
+type windowAggrState struct {
+	inner aggrState
+
+	// output is the output name, which is added to the metric name of output series.
+	output string
+
+	merge windowMergeFunc
+	specs []windowSpec
+
+	// ringSize is the number of the smallest aggregation intervals in the sliding window.
+	//
+	// It is set to zero if there are no sliding windows.
+	ringSize int
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// flushes is the number of flushState calls.
+	flushes uint64
+
+	m map[string]*windowStateValue
+
+	// panes contains values obtained from inner on the last flushState call.
+	panes map[string]windowPane
+}
+
+type windowStateValue struct {
+	// ring contains values for the last ringSize flushes.
+	ring []windowPane
+
+	// accs contains accumulated values per each tumbling windowSpec.
+	accs []windowPane
+}
+
+func newWindowAggrState(inner aggrState, output string, specs []windowSpec) *windowAggrState {
+	ringSize := 0
+	for _, spec := range specs {
+		if spec.panes > ringSize {
+			ringSize = spec.panes
+		}
+	}
+	return &windowAggrState{
+		inner:    inner,
+		output:   output,
+		merge:    windowMergeFuncs[output],
+		specs:    specs,
+		ringSize: ringSize,
+		m:        make(map[string]*windowStateValue),
+		panes:    make(map[string]windowPane),
+	}
+}
+
+func (as *windowAggrState) pushSamples(samples []pushSample) {
+	as.inner.pushSamples(samples)
+}
+
+func (as *windowAggrState) flushState(ctx *flushCtx) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	// Obtain the values for the last interval from the inner state.
+	panes := as.panes
+	ctx.paneFunc = func(key string, value, weight float64) {
+		panes[key] = windowPane{
+			value:  value,
+			weight: weight,
+		}
+	}
+	as.inner.flushState(ctx)
+	ctx.paneFunc = nil
+	if ctx.pushFunc == nil {
+		// Drop the values for the incomplete interval.
+		clear(panes)
+	}
+
+	ringPos := 0
+	if as.ringSize > 0 {
+		ringPos = int(as.flushes % uint64(as.ringSize))
+	}
+	as.flushes++
+
+	for key := range panes {
+		if _, ok := as.m[key]; !ok {
+			as.m[key] = &windowStateValue{
+				ring: make([]windowPane, as.ringSize),
+				accs: make([]windowPane, len(as.specs)),
+			}
+		}
+	}
+	for key, sv := range as.m {
+		p := panes[key]
+		if as.ringSize > 0 {
+			sv.ring[ringPos] = p
+		}
+		if !p.isEmpty() {
+			for i, spec := range as.specs {
+				if spec.panes == 0 {
+					as.merge(&sv.accs[i], p)
+				}
+			}
+		}
+	}
+	clear(panes)
+
+	// Emit output series for windows, which must be flushed now.
+	suffix := ctx.suffix
+	for i, spec := range as.specs {
+		if !ctx.flushAll && ctx.flushIdx%spec.every != 0 {
+			continue
+		}
+		ctx.suffix = spec.suffix
+		for key, sv := range as.m {
+			var p windowPane
+			if spec.panes == 0 {
+				p = sv.accs[i]
+				sv.accs[i] = windowPane{}
+			} else {
+				for j := 0; j < spec.panes; j++ {
+					pos := (ringPos - j + as.ringSize) % as.ringSize
+					if !sv.ring[pos].isEmpty() {
+						as.merge(&p, sv.ring[pos])
+					}
+				}
+			}
+			if p.isEmpty() {
+				continue
+			}
+			ctx.appendSeries(key, as.output, p.value)
+		}
+	}
+	ctx.suffix = suffix
+
+	// Remove entries without values.
+	for key, sv := range as.m {
+		if sv.isEmpty() {
+			delete(as.m, key)
+		}
+	}
+}
+
+func (sv *windowStateValue) isEmpty() bool {
+	for i := range sv.ring {
+		if !sv.ring[i].isEmpty() {
+			return false
+		}
+	}
+	for i := range sv.accs {
+		if !sv.accs[i].isEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+func (as *windowAggrState) itemsCount() int {
+	as.mu.Lock()
+	n := len(as.m)
+	as.mu.Unlock()
+	return max(n, as.inner.itemsCount())
+}
+
+// marshalState implements aggrStatePersister.
+//
+// The state of the inner aggrState is persisted only if it implements aggrStatePersister.
+func (as *windowAggrState) marshalState(dst []byte) []byte {
+	bb := bbPool.Get()
+	if asp, ok := as.inner.(aggrStatePersister); ok {
+		bb.B = asp.marshalState(bb.B[:0])
+	}
+	dst = encoding.MarshalBytes(dst, bb.B)
+	bb.B = as.marshalSpecs(bb.B[:0])
+	dst = encoding.MarshalBytes(dst, bb.B)
+	bbPool.Put(bb)
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	dst = encoding.MarshalVarUint64(dst, as.flushes)
+	for key, sv := range as.m {
+		dst = marshalStateKey(dst, key)
+		for _, p := range sv.ring {
+			dst = marshalWindowPane(dst, p)
+		}
+		for _, p := range sv.accs {
+			dst = marshalWindowPane(dst, p)
+		}
+	}
+	return dst
+}
+
+// unmarshalState implements aggrStatePersister.
+//
+// The window state is discarded if intervals or window have been changed since the state was saved,
+// while the inner state is restored.
+func (as *windowAggrState) unmarshalState(src []byte) (func(), error) {
+	sr := &stateReader{
+		src: src,
+	}
+	innerState := sr.readBytes()
+	specs := sr.readBytes()
+	if sr.err != nil {
+		return nil, sr.err
+	}
+
+	var innerApply func()
+	if asp, ok := as.inner.(aggrStatePersister); ok {
+		apply, err := asp.unmarshalState(innerState)
+		if err != nil {
+			return nil, err
+		}
+		innerApply = apply
+	}
+
+	var m map[string]*windowStateValue
+	var flushes uint64
+	if string(specs) == string(as.marshalSpecs(nil)) {
+		flushes = sr.readUint64()
+		m = make(map[string]*windowStateValue)
+		for len(sr.src) > 0 && sr.err == nil {
+			key := sr.readKey()
+			sv := &windowStateValue{
+				ring: make([]windowPane, as.ringSize),
+				accs: make([]windowPane, len(as.specs)),
+			}
+			for i := range sv.ring {
+				sv.ring[i] = sr.readWindowPane()
+			}
+			for i := range sv.accs {
+				sv.accs[i] = sr.readWindowPane()
+			}
+			m[key] = sv
+		}
+		if sr.err != nil {
+			return nil, sr.err
+		}
+	}
+
+	apply := func() {
+		if innerApply != nil {
+			innerApply()
+		}
+		if m != nil {
+			as.mu.Lock()
+			as.flushes = flushes
+			as.m = m
+			as.mu.Unlock()
+		}
+	}
+	return apply, nil
+}
+
+// marshalSpecs appends the settings, which define the layout of the window state, to dst.
+func (as *windowAggrState) marshalSpecs(dst []byte) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(as.ringSize))
+	dst = encoding.MarshalVarUint64(dst, uint64(len(as.specs)))
+	for _, spec := range as.specs {
+		dst = encoding.MarshalVarUint64(dst, spec.every)
+		dst = encoding.MarshalVarUint64(dst, uint64(spec.panes))
+	}
+	return dst
+}
+
+func marshalWindowPane(dst []byte, p windowPane) []byte {
+	dst = marshalStateFloat64(dst, p.value)
+	return marshalStateFloat64(dst, p.weight)
+}
+
+func (sr *stateReader) readWindowPane() windowPane {
+	return windowPane{
+		value:  sr.readFloat64(),
+		weight: sr.readFloat64(),
+	}
+}
+
+// isWindowOutput returns true if the given output can be calculated by windowAggrState.
+func isWindowOutput(output string) bool {
+	_, ok := windowMergeFuncs[output]
+	return ok
+}
+
+// windowOutputs returns the sorted list of outputs, which can be calculated by windowAggrState.
+func windowOutputs() []string {
+	outputs := make([]string, 0, len(windowMergeFuncs))
+	for output := range windowMergeFuncs {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
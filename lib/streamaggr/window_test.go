@@ -0,0 +1,261 @@
+package streamaggr
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestAggregatorWindows(t *testing.T) {
+	f := func(config string, inputs, outputsExpected []string) {
+		t.Helper()
+
+		var tssOutput []prompbmarshal.TimeSeries
+		var tssOutputLock sync.Mutex
+		pushFunc := func(tss []prompbmarshal.TimeSeries) {
+			tssOutputLock.Lock()
+			for _, ts := range tss {
+				tssOutput = append(tssOutput, prompbmarshal.TimeSeries{
+					Labels:  append(ts.Labels[:0:0], ts.Labels...),
+					Samples: append(ts.Samples[:0:0], ts.Samples...),
+				})
+			}
+			tssOutputLock.Unlock()
+		}
+		opts := &Options{
+			NoAlignFlushToInterval: true,
+		}
+		sas, err := LoadFromData([]byte(config), pushFunc, opts, "some_alias")
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+		defer sas.MustStop()
+		a := sas.as[0]
+
+		for i, input := range inputs {
+			nowMsec := time.Now().UnixMilli()
+			sas.Push(prompbmarshal.MustParsePromMetrics(input, nowMsec), nil)
+			a.flush(pushFunc, nowMsec, false)
+
+			tssOutputLock.Lock()
+			output := timeSeriessToString(tssOutput)
+			tssOutput = tssOutput[:0]
+			tssOutputLock.Unlock()
+			if output != outputsExpected[i] {
+				t.Fatalf("unexpected output for flush #%d;\ngot\n%s\nwant\n%s", i+1, output, outputsExpected[i])
+			}
+		}
+	}
+
+	// coarser intervals are built from the smallest interval
+	f(`
+- intervals: [1m, 3m]
+  outputs: [sum_samples, max, total]
+`, []string{
+		"foo 1",
+		"foo 5\nfoo 2",
+		"foo 3",
+		"foo 4",
+	}, []string{
+		`foo:1m_max 1
+foo:1m_sum_samples 1
+foo:1m_total 0
+`,
+		`foo:1m_max 5
+foo:1m_sum_samples 7
+foo:1m_total 6
+`,
+		`foo:1m_max 3
+foo:1m_sum_samples 3
+foo:1m_total 7
+foo:3m_max 5
+foo:3m_sum_samples 11
+foo:3m_total 7
+`,
+		`foo:1m_max 4
+foo:1m_sum_samples 4
+foo:1m_total 8
+`,
+	})
+
+	// sliding window
+	f(`
+- interval: 1m
+  window: 3m
+  by: [job]
+  outputs: [sum_samples, avg]
+`, []string{
+		`foo{job="a"} 1`,
+		`foo{job="a"} 2` + "\n" + `foo{job="a"} 4`,
+		`foo{job="a"} 3` + "\n" + `foo{job="b"} 10`,
+		`foo{job="b"} 20`,
+		``,
+		``,
+	}, []string{
+		`foo:3m_every_1m_by_job_avg{job="a"} 1
+foo:3m_every_1m_by_job_sum_samples{job="a"} 1
+`,
+		`foo:3m_every_1m_by_job_avg{job="a"} 2.3333333333333335
+foo:3m_every_1m_by_job_sum_samples{job="a"} 7
+`,
+		`foo:3m_every_1m_by_job_avg{job="a"} 2.5
+foo:3m_every_1m_by_job_avg{job="b"} 10
+foo:3m_every_1m_by_job_sum_samples{job="a"} 10
+foo:3m_every_1m_by_job_sum_samples{job="b"} 10
+`,
+		`foo:3m_every_1m_by_job_avg{job="a"} 3
+foo:3m_every_1m_by_job_avg{job="b"} 15
+foo:3m_every_1m_by_job_sum_samples{job="a"} 9
+foo:3m_every_1m_by_job_sum_samples{job="b"} 30
+`,
+		`foo:3m_every_1m_by_job_avg{job="a"} 3
+foo:3m_every_1m_by_job_avg{job="b"} 15
+foo:3m_every_1m_by_job_sum_samples{job="a"} 3
+foo:3m_every_1m_by_job_sum_samples{job="b"} 30
+`,
+		`foo:3m_every_1m_by_job_avg{job="b"} 20
+foo:3m_every_1m_by_job_sum_samples{job="b"} 20
+`,
+	})
+
+	// sliding windows for multiple intervals
+	f(`
+- intervals: [1m, 2m]
+  window: 2m
+  outputs: [count_samples]
+`, []string{
+		"foo 1",
+		"foo 1\nfoo 1",
+		"foo 1",
+	}, []string{
+		`foo:2m_every_1m_count_samples 1
+`,
+		`foo:2m_every_1m_count_samples 3
+foo:2m_every_2m_count_samples 3
+`,
+		`foo:2m_every_1m_count_samples 3
+`,
+	})
+}
+
+func TestAggregatorWindowsStateRestore(t *testing.T) {
+	f := func(configBefore, configAfter string, inputsBefore, inputsAfter, outputsExpected []string) {
+		t.Helper()
+
+		var tssOutput []prompbmarshal.TimeSeries
+		var tssOutputLock sync.Mutex
+		pushFunc := func(tss []prompbmarshal.TimeSeries) {
+			tssOutputLock.Lock()
+			tssOutput = appendClonedTimeseries(tssOutput, tss)
+			tssOutputLock.Unlock()
+		}
+		newAggregators := func(config string) *Aggregators {
+			t.Helper()
+			opts := &Options{
+				NoAlignFlushToInterval: true,
+			}
+			sas, err := LoadFromData([]byte(config), pushFunc, opts, "some_alias")
+			if err != nil {
+				t.Fatalf("cannot initialize aggregators: %s", err)
+			}
+			return sas
+		}
+		pushAndFlush := func(sas *Aggregators, input string) string {
+			t.Helper()
+			nowMsec := time.Now().UnixMilli()
+			sas.Push(prompbmarshal.MustParsePromMetrics(input, nowMsec), nil)
+			sas.as[0].flush(pushFunc, nowMsec, false)
+
+			tssOutputLock.Lock()
+			defer tssOutputLock.Unlock()
+			output := timeSeriessToString(tssOutput)
+			tssOutput = tssOutput[:0]
+			return output
+		}
+
+		sas := newAggregators(configBefore)
+		for _, input := range inputsBefore {
+			pushAndFlush(sas, input)
+		}
+		data := sas.as[0].marshalState(nil)
+		sas.MustStop()
+
+		sas = newAggregators(configAfter)
+		defer sas.MustStop()
+		if err := sas.as[0].unmarshalState(data); err != nil {
+			t.Fatalf("cannot unmarshal state: %s", err)
+		}
+		for i, input := range inputsAfter {
+			output := pushAndFlush(sas, input)
+			if output != outputsExpected[i] {
+				t.Fatalf("unexpected output for flush #%d;\ngot\n%s\nwant\n%s", i+1, output, outputsExpected[i])
+			}
+		}
+	}
+
+	// sliding window continues from the persisted state
+	config := `
+- interval: 1m
+  window: 3m
+  outputs: [sum_samples]
+`
+	f(config, config, []string{
+		"foo 1",
+		"foo 2",
+	}, []string{
+		"foo 4",
+		"foo 8",
+	}, []string{
+		`foo:3m_every_1m_sum_samples 7
+`,
+		`foo:3m_every_1m_sum_samples 14
+`,
+	})
+
+	// coarser intervals continue accumulating the persisted values
+	config = `
+- intervals: [1m, 3m]
+  outputs: [sum_samples]
+`
+	f(config, config, []string{
+		"foo 1",
+	}, []string{
+		"foo 2",
+		"foo 4",
+		"foo 8",
+	}, []string{
+		`foo:1m_sum_samples 2
+`,
+		`foo:1m_sum_samples 4
+`,
+		`foo:1m_sum_samples 8
+foo:3m_sum_samples 15
+`,
+	})
+
+	// the window state is discarded if intervals or window have been changed
+	f(config, `
+- interval: 1m
+  window: 2m
+  outputs: [sum_samples]
+`, []string{
+		"foo 1",
+		"foo 2",
+	}, []string{
+		"foo 4",
+	}, []string{
+		`foo:2m_every_1m_sum_samples 4
+`,
+	})
+}
+
+func TestWindowOutputs(t *testing.T) {
+	outputs := strings.Join(windowOutputs(), ",")
+	outputsExpected := "avg,count_samples,max,min,rate_avg,rate_sum,sum_samples"
+	if outputs != outputsExpected {
+		t.Fatalf("unexpected window outputs; got %s; want %s", outputs, outputsExpected)
+	}
+}